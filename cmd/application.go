@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
+	_ "github.com/lib/pq"
 	"go.bryk.io/pkg/errors"
 
 	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
@@ -16,6 +20,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/core/config"
 	coreconstants "github.com/sky-as-code/nikki-erp/modules/core/constants"
 	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
 )
 
 type ModuleLoader interface {
@@ -62,6 +67,72 @@ func (this *Application) Start() {
 }
 
 func (this *Application) GenSql(moduleName string, dialect string) string {
+	registry, registeredCount := this.finalizedRegistryOf(moduleName)
+	prefixes := schemaPrefixesOf(moduleName)
+	queries, err := orm.GenCreateSql(registry, dialect, prefixes...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate create SQL: %v\n", err)
+		os.Exit(1)
+	}
+	exitIfNoPrefixMatched(moduleName, registeredCount, len(queries))
+	return strings.Join(queries, ";\n")
+}
+
+// DiffSql renders the migration from the database at dsn to the module's schemas. The steps
+// that can lose data or fail on existing rows come last and commented out, each under the
+// reason it is destructive, so that the output applied as is never does either.
+func (this *Application) DiffSql(moduleName string, dialect string, dsn string) string {
+	if dialect != orm.DialectPostgres {
+		fmt.Fprintf(os.Stderr, "-diffsql: dialect '%s' is not supported\n", dialect)
+		os.Exit(1)
+	}
+	registry, registeredCount := this.finalizedRegistryOf(moduleName)
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	client := orm.NewPgClient(db)
+	defer client.Close()
+
+	prefixes := schemaPrefixesOf(moduleName)
+	steps, err := orm.GenDiffSql(context.Background(), client, registry, dialect, prefixes...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate diff SQL: %v\n", err)
+		os.Exit(1)
+	}
+	// Steps are no measure here: a module in sync with the database has none.
+	exitIfNoPrefixMatched(moduleName, registeredCount, countSchemasMatching(registry, prefixes))
+	return renderMigrationSteps(steps)
+}
+
+// GenOpenApi renders the OpenAPI document of the resource engines. It must run after Start, by
+// which time every module has mounted its engines.
+func (this *Application) GenOpenApi() ([]byte, error) {
+	return json.MarshalIndent(dynamicresource.OpenApiDocument(this.config), "", "  ")
+}
+
+func renderMigrationSteps(steps []orm.MigrationStep) string {
+	var safe, destructive strings.Builder
+	for _, step := range steps {
+		if !step.Destructive {
+			safe.WriteString(step.Sql + ";\n")
+			continue
+		}
+		fmt.Fprintf(&destructive, "-- %s\n-- %s;\n", step.Reason, step.Sql)
+	}
+	if destructive.Len() == 0 {
+		return safe.String()
+	}
+	return safe.String() +
+		"\n-- DESTRUCTIVE: the changes below can lose data or fail on existing rows.\n" +
+		"-- Review each one and uncomment those that are intended.\n" +
+		destructive.String()
+}
+
+// finalizedRegistryOf registers the models of moduleName and its dependencies and finalizes
+// their relations, exiting on failure. It also returns how many schemas moduleName added.
+func (this *Application) finalizedRegistryOf(moduleName string) (*dmodel.SchemaRegistry, int) {
 	registeredCount, err := this.registerModelsUpTo(moduleName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to register models: %v\n", err)
@@ -78,25 +149,22 @@ func (this *Application) GenSql(moduleName string, dialect string) string {
 		fmt.Fprintf(os.Stderr, "failed to validate relations: %v\n", err)
 		os.Exit(1)
 	}
+	return registry, registeredCount
+}
 
-	prefixes := schemaPrefixesOf(moduleName)
-	queries, err := orm.GenCreateSql(registry, dialect, prefixes...)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to generate create SQL: %v\n", err)
-		os.Exit(1)
-	}
-
-	// A module that registered schemas but matched no prefix means schemaPrefixesOf is wrong
-	// for it, which would otherwise surface as a silently empty migration. A module that
-	// registered nothing at all (report, for example) legitimately produces no SQL.
-	if len(queries) == 0 && registeredCount > 0 {
-		fmt.Fprintf(os.Stderr,
-			"module '%s' registered %d schema(s) but none match [%s]; "+
-				"fix schemaPrefixesOf in cmd/application.go\n",
-			moduleName, registeredCount, strings.Join(prefixes, ", "))
-		os.Exit(1)
-	}
-	return strings.Join(queries, ";\n")
+// exitIfNoPrefixMatched fails when a module registered schemas but matched no prefix, which
+// means schemaPrefixesOf is wrong for it and would otherwise surface as a silently empty
+// migration. A module that registered nothing at all (report, for example) legitimately
+// produces no SQL.
+func exitIfNoPrefixMatched(moduleName string, registeredCount int, matchedCount int) {
+	if matchedCount > 0 || registeredCount == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr,
+		"module '%s' registered %d schema(s) but none match [%s]; "+
+			"fix schemaPrefixesOf in cmd/application.go\n",
+		moduleName, registeredCount, strings.Join(schemaPrefixesOf(moduleName), ", "))
+	os.Exit(1)
 }
 
 // schemaPrefixesOf maps a module name to the prefixes its schemas are named with, so
@@ -191,6 +259,20 @@ func countSchemas(registry *dmodel.SchemaRegistry) int {
 	return count
 }
 
+func countSchemasMatching(registry *dmodel.SchemaRegistry, prefixes []string) int {
+	count := 0
+	_ = registry.ForEach(func(schemaName string, _ *dmodel.ModelSchema) error {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(schemaName, prefix) {
+				count++
+				break
+			}
+		}
+		return nil
+	})
+	return count
+}
+
 // dependencyClosure returns root and everything it transitively depends on, ordered
 // dependencies-first, so "core" registers its base schemas before any module that extends
 // them by name. Modules outside root's closure are excluded entirely.
@@ -17,6 +17,8 @@ type StartableApp interface {
 	Start()
 	Logger() logging.LoggerService
 	GenSql(module string, dialect string) string
+	DiffSql(module string, dialect string, dsn string) string
+	GenOpenApi() ([]byte, error)
 }
 
 type MainParam struct {
@@ -28,8 +30,13 @@ type BeforeHttpStartFn func() error
 
 func Main(param MainParam) {
 	isCreateSql := flag.Bool("createsql", false, "Generate CREATE SQL for model schemas and write to stdout")
-	module := flag.String("module", "", "Module name (required when -createsql is set)")
-	dialect := flag.String("dialect", "", "SQL dialect (required when -createsql is set)")
+	isDiffSql := flag.Bool("diffsql", false,
+		"Generate the SQL that migrates the database at -dsn to the model schemas and write to stdout")
+	module := flag.String("module", "", "Module name (required when -createsql or -diffsql is set)")
+	dialect := flag.String("dialect", "", "SQL dialect (required when -createsql or -diffsql is set)")
+	dsn := flag.String("dsn", "", "Database connection string (required when -diffsql is set)")
+	openApiFile := flag.String("openapi", "",
+		"Generate the OpenAPI document of the resource engines and write it to the given file")
 	job := flag.String("job", "", "Run job")
 	jobArgs := flag.String("jobArgs", "", "Job Args")
 	flag.Parse()
@@ -38,6 +45,14 @@ func Main(param MainParam) {
 		runCreateSql(param.CreateAppFn, *module, *dialect)
 		return
 	}
+	if *isDiffSql {
+		runDiffSql(param.CreateAppFn, *module, *dialect, *dsn)
+		return
+	}
+	if *openApiFile != "" {
+		runGenOpenApi(param.CreateAppFn, *openApiFile)
+		return
+	}
 
 	logging.InitSubModule()
 
@@ -53,6 +68,8 @@ func Main(param MainParam) {
 		return
 	}
 
+	jobQueue := startJobQueue(app.Logger())
+
 	var server *httpserver.HttpServer
 	go func() {
 		var err error
@@ -74,6 +91,9 @@ func Main(param MainParam) {
 
 	<-awaitOsTerminateSignal()
 	server.Shutdown()
+	if jobQueue != nil {
+		jobQueue.Stop()
+	}
 }
 
 func runCreateSql(createAppFn CreateAppFn, module string, dialect string) {
@@ -86,6 +106,35 @@ func runCreateSql(createAppFn CreateAppFn, module string, dialect string) {
 	fmt.Print(sql)
 }
 
+func runDiffSql(createAppFn CreateAppFn, module string, dialect string, dsn string) {
+	if module == "" || dialect == "" || dsn == "" {
+		fmt.Fprintln(os.Stderr, "error: -diffsql requires -module, -dialect and -dsn to have values")
+		os.Exit(1)
+	}
+	app := createAppFn(nil)
+	sql := app.DiffSql(module, dialect, dsn)
+	fmt.Print(sql)
+}
+
+// runGenOpenApi initializes every module, as serving the application would, since the document
+// describes the routes the engines are mounted under. The file is written rather than printed
+// because module initialization logs to stdout.
+func runGenOpenApi(createAppFn CreateAppFn, outputFile string) {
+	logging.InitSubModule()
+	app := createAppFn(logging.Logger())
+	app.Start()
+
+	content, err := app.GenOpenApi()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate the OpenAPI document: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(outputFile, content, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the OpenAPI document: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func runHandleJob(jobName string, jobArgs *string) {
 	err := deps.Invoke(func(jobManager *job.JobManager) error {
 		jobManager.HandleJob(jobName, jobArgs)
@@ -97,6 +146,21 @@ func runHandleJob(jobName string, jobArgs *string) {
 	}
 }
 
+// startJobQueue starts the workers that process queued jobs. They run in every instance that
+// serves HTTP, which is where the jobs are enqueued.
+func startJobQueue(logger logging.LoggerService) *job.JobQueue {
+	var jobQueue *job.JobQueue
+	err := deps.Invoke(func(jq *job.JobQueue) {
+		jobQueue = jq
+	})
+	if err != nil {
+		logger.Error("failed to start the job queue", err)
+		return nil
+	}
+	jobQueue.Start()
+	return jobQueue
+}
+
 func awaitOsTerminateSignal() chan os.Signal {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
@@ -369,6 +369,9 @@ func populateDbMetadata(schema *ModelSchema) error {
 	if err != nil {
 		return err
 	}
+	if err := validateFullTextSearchForDb(schema, columnSet); err != nil {
+		return err
+	}
 	if len(primaryKeys) == 0 {
 		return errors.Errorf("populateDbMetadata: model '%s' must define at least one primary key column", name)
 	}
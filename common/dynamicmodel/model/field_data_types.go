@@ -1804,6 +1804,9 @@ func tryConvertStringArrayValue(value any) (value, error) {
 	switch typed := value.(type) {
 	case []byte:
 		return scanPostgresStringArrayBytes(typed)
+	case string:
+		// The same array text, from a driver that reads text columns as string (SQLite).
+		return scanPostgresStringArrayBytes([]byte(typed))
 	case []string:
 		return Value(typed), nil
 	case *[]string:
@@ -1831,6 +1834,8 @@ func tryConvertInt64ArrayValue(value any) (value, error) {
 	switch typed := value.(type) {
 	case []byte:
 		return scanPostgresInt64ArrayBytes(typed)
+	case string:
+		return scanPostgresInt64ArrayBytes([]byte(typed))
 	case []int64:
 		return Value(typed), nil
 	case *[]int64:
@@ -1862,6 +1867,8 @@ func tryConvertInt32ArrayValue(value any) (value, error) {
 			return Value(nil), err
 		}
 		return Value(parsed), nil
+	case string:
+		return tryConvertInt32ArrayValue([]byte(typed))
 	case []int32:
 		return Value(typed), nil
 	case *[]int32:
@@ -1878,6 +1885,8 @@ func tryConvertBoolArrayValue(value any) (value, error) {
 	switch typed := value.(type) {
 	case []byte:
 		return scanPostgresBoolArrayBytes(typed)
+	case string:
+		return scanPostgresBoolArrayBytes([]byte(typed))
 	case []bool:
 		return Value(typed), nil
 	case *[]bool:
@@ -1894,6 +1903,8 @@ func tryConvertDecimalArrayValue(value any) (value, error) {
 	switch typed := value.(type) {
 	case []byte:
 		return scanPostgresDecimalArrayBytes(typed)
+	case string:
+		return scanPostgresDecimalArrayBytes([]byte(typed))
 	case []decimal.Decimal:
 		return Value(typed), nil
 	case *[]decimal.Decimal:
@@ -1995,6 +2006,8 @@ func tryConvertLangJsonArrayValue(val any) (value, error) {
 	switch typed := val.(type) {
 	case []byte:
 		return scanPostgresLangJsonArrayBytes(typed)
+	case string:
+		return scanPostgresLangJsonArrayBytes([]byte(typed))
 	case []any:
 		return Value(typed), nil
 	default:
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// FullTextWeight ranks a match in one searchable field against the others: A counts most, D least.
+type FullTextWeight string
+
+const (
+	FullTextWeightA FullTextWeight = "A"
+	FullTextWeightB FullTextWeight = "B"
+	FullTextWeightC FullTextWeight = "C"
+	FullTextWeightD FullTextWeight = "D"
+)
+
+func (this FullTextWeight) IsValid() bool {
+	switch this {
+	case FullTextWeightA, FullTextWeightB, FullTextWeightC, FullTextWeightD:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultFullTextConfig only lowercases, without stemming or stop words, so it suits every language.
+const DefaultFullTextConfig = "simple"
+
+var fullTextConfigRegex = regexp.MustCompile(`^[a-z_]+$`)
+
+type FullTextFieldParam struct {
+	Field string
+	// Language picks the translation of a LangJson field to index, and is required for one.
+	// Declare the field once per language to index several, each with its own Weight and Config.
+	Language model.LanguageCode
+	// Weight defaults to FullTextWeightD.
+	Weight FullTextWeight
+	// Config is the PostgreSQL text search configuration that splits and stems the text, such as
+	// "english". Defaults to DefaultFullTextConfig.
+	Config string
+}
+
+type FullTextSearchParam struct {
+	Fields []FullTextFieldParam
+	// Trigram also indexes the text by trigrams, so that a search term with a typo in it still
+	// finds the records spelling it right.
+	Trigram bool
+}
+
+// Configs returns the distinct text search configurations of the fields, sorted.
+func (this FullTextSearchParam) Configs() []string {
+	seen := make(map[string]struct{}, len(this.Fields))
+	configs := make([]string, 0, len(this.Fields))
+	for _, field := range this.Fields {
+		if _, ok := seen[field.Config]; ok {
+			continue
+		}
+		seen[field.Config] = struct{}{}
+		configs = append(configs, field.Config)
+	}
+	sort.Strings(configs)
+	return configs
+}
+
+// FullTextSearch makes the given fields searchable by the "q" of a search, which ranks the
+// records it matches by relevance. The migration script generates a tsvector column over the
+// fields with a GIN index on it and, with Trigram, a trigram index as well.
+// A schema has one full-text search; calling this again replaces it.
+func (this *ModelSchemaBuilder) FullTextSearch(param FullTextSearchParam) *ModelSchemaBuilder {
+	if len(param.Fields) == 0 {
+		panic(errors.New("FullTextSearch: field list must not be empty"))
+	}
+	fields := make([]FullTextFieldParam, len(param.Fields))
+	for i, field := range param.Fields {
+		fields[i] = mustNormalizeFullTextField(field)
+	}
+	this.schema.fullTextSearch = &FullTextSearchParam{Fields: fields, Trigram: param.Trigram}
+	return this
+}
+
+func mustNormalizeFullTextField(field FullTextFieldParam) FullTextFieldParam {
+	out := FullTextFieldParam{
+		Field:    strings.TrimSpace(field.Field),
+		Language: strings.TrimSpace(field.Language),
+		Weight:   field.Weight,
+		Config:   strings.TrimSpace(field.Config),
+	}
+	if out.Field == "" {
+		panic(errors.New("FullTextSearch: field name must not be empty"))
+	}
+	if out.Language != "" {
+		canonical, err := model.ToBCP47LanguageCode(out.Language)
+		if err != nil || out.Language == model.LanguageCodeRef {
+			panic(errors.Errorf("FullTextSearch: field '%s': invalid language '%s'", out.Field, out.Language))
+		}
+		out.Language = canonical
+	}
+	if out.Weight == "" {
+		out.Weight = FullTextWeightD
+	}
+	if !out.Weight.IsValid() {
+		panic(errors.Errorf("FullTextSearch: field '%s': weight must be one of A, B, C or D", out.Field))
+	}
+	if out.Config == "" {
+		out.Config = DefaultFullTextConfig
+	}
+	if !fullTextConfigRegex.MatchString(out.Config) {
+		panic(errors.Errorf(
+			"FullTextSearch: field '%s': invalid text search configuration '%s'", out.Field, out.Config))
+	}
+	return out
+}
+
+// FullTextSearch returns the schema's full-text search, or nil when it declares none.
+func (this ModelSchema) FullTextSearch() *FullTextSearchParam {
+	return this.fullTextSearch
+}
+
+var fullTextSearchableTypes = map[string]struct{}{
+	FieldDataTypeNameString:     {},
+	FieldDataTypeNameEmail:      {},
+	FieldDataTypeNamePhone:      {},
+	FieldDataTypeNameUrl:        {},
+	FieldDataTypeNameSlug:       {},
+	FieldDataTypeNameEnumString: {},
+	FieldDataTypeNameLangJson:   {},
+}
+
+// validateFullTextSearchForDb checks that every searchable field is a text column, and that
+// LangJson fields, and only they, name the translation to index.
+func validateFullTextSearchForDb(schema *ModelSchema, columnSet map[string]struct{}) error {
+	if schema.fullTextSearch == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(schema.fullTextSearch.Fields))
+	for _, param := range schema.fullTextSearch.Fields {
+		if _, ok := columnSet[param.Field]; !ok {
+			return errors.Errorf(
+				"validateFullTextSearchForDb: model '%s': unknown column '%s' in full-text search",
+				schema.Name(), param.Field)
+		}
+		field := schema.fields[param.Field]
+		if _, ok := fullTextSearchableTypes[field.ColumnType()]; !ok || field.IsArray() {
+			return errors.Errorf(
+				"validateFullTextSearchForDb: model '%s': field '%s' of type '%s' is not searchable text",
+				schema.Name(), param.Field, field.ColumnType())
+		}
+		isLangJson := field.ColumnType() == FieldDataTypeNameLangJson
+		if isLangJson && param.Language == "" {
+			return errors.Errorf(
+				"validateFullTextSearchForDb: model '%s': LangJson field '%s' must name the language to index",
+				schema.Name(), param.Field)
+		}
+		if !isLangJson && param.Language != "" {
+			return errors.Errorf(
+				"validateFullTextSearchForDb: model '%s': field '%s' has no translations to pick a language from",
+				schema.Name(), param.Field)
+		}
+		key := param.Field + "|" + param.Language
+		if _, ok := seen[key]; ok {
+			return errors.Errorf(
+				"validateFullTextSearchForDb: model '%s': field '%s' is declared twice in full-text search",
+				schema.Name(), param.Field)
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
@@ -56,6 +56,13 @@ func (this *ModelSchemaBuilder) ShouldBuildDb() *ModelSchemaBuilder {
 	return this
 }
 
+// TrackHistory makes the repository log every single-record insert, update and delete in the
+// change history, within the transaction of the mutation itself. Bulk inserts are not logged.
+func (this *ModelSchemaBuilder) TrackHistory() *ModelSchemaBuilder {
+	this.schema.trackHistory = true
+	return this
+}
+
 func (this *ModelSchemaBuilder) Field(fieldBuilder *FieldBuilder) *ModelSchemaBuilder {
 	if fieldBuilder == nil {
 		return this
@@ -107,6 +114,10 @@ func (this *ModelSchemaBuilder) Extend(builder *ModelSchemaBuilder) *ModelSchema
 	this.schema.compositeUniques = append(this.schema.compositeUniques, builder.schema.compositeUniques...)
 	this.schema.partialUniques = append(this.schema.partialUniques, builder.schema.partialUniques...)
 	this.schema.searchIndexGroups = append(this.schema.searchIndexGroups, builder.schema.searchIndexGroups...)
+	if this.schema.fullTextSearch == nil {
+		this.schema.fullTextSearch = builder.schema.fullTextSearch
+	}
+	this.schema.trackHistory = this.schema.trackHistory || builder.schema.trackHistory
 	this.schema.exclusiveRequiredFieldGroups = append(
 		this.schema.exclusiveRequiredFieldGroups, builder.schema.exclusiveRequiredFieldGroups...)
 	// Inherited only when this schema has not declared its own, so a concrete model always wins
@@ -477,6 +488,11 @@ func assertVirtualFieldNotIndexed(schema *ModelSchema, name string) error {
 	for _, param := range schema.searchIndexGroups {
 		groups["search index"] = append(groups["search index"], param.Fields)
 	}
+	if schema.fullTextSearch != nil {
+		for _, param := range schema.fullTextSearch.Fields {
+			groups["full-text search"] = append(groups["full-text search"], []string{param.Field})
+		}
+	}
 
 	for kind, fieldGroups := range groups {
 		for _, group := range fieldGroups {
@@ -18,6 +18,7 @@ type modelJsonDto struct {
 	Description         any    `json:"description"`
 	TableName           string `json:"table_name"`
 	ShouldBuildDb       bool   `json:"should_build_db"`
+	TrackHistory        bool   `json:"track_history"`
 	RecordLabelField    string `json:"record_label_field"`
 	RecordSubLabelField string `json:"record_sub_label_field"`
 
@@ -29,6 +30,7 @@ type modelJsonDto struct {
 	CompositeUniques        []compositeUniqueDto `json:"composite_uniques"`
 	PartialUniques          []partialUniqueDto   `json:"partial_uniques"`
 	SearchIndexes           []searchIndexDto     `json:"search_indexes"`
+	FullTextSearch          *fullTextSearchDto   `json:"full_text_search"`
 	ExclusiveRequiredFields [][]string           `json:"exclusive_required_fields"`
 
 	EdgesTo   []edgeJsonDto `json:"edges_to"`
@@ -86,6 +88,18 @@ type searchIndexDto struct {
 	Fields    []string `json:"fields"`
 }
 
+type fullTextSearchDto struct {
+	Fields  []fullTextFieldDto `json:"fields"`
+	Trigram bool               `json:"trigram"`
+}
+
+type fullTextFieldDto struct {
+	Field    string `json:"field"`
+	Language string `json:"language"`
+	Weight   string `json:"weight"`
+	Config   string `json:"config"`
+}
+
 type edgeJsonDto struct {
 	Edge  string `json:"edge"`
 	Label any    `json:"label"`
@@ -171,6 +185,9 @@ func buildFromDto(dto *modelJsonDto) *ModelSchemaBuilder {
 	if dto.ShouldBuildDb {
 		builder.ShouldBuildDb()
 	}
+	if dto.TrackHistory {
+		builder.TrackHistory()
+	}
 	if dto.RecordLabelField != "" {
 		builder.RecordLabelField(dto.RecordLabelField)
 	}
@@ -214,6 +231,18 @@ func applyConstraints(builder *ModelSchemaBuilder, dto *modelJsonDto) {
 			Fields:    index.Fields,
 		})
 	}
+	if dto.FullTextSearch != nil {
+		fields := make([]FullTextFieldParam, len(dto.FullTextSearch.Fields))
+		for i, field := range dto.FullTextSearch.Fields {
+			fields[i] = FullTextFieldParam{
+				Field:    field.Field,
+				Language: field.Language,
+				Weight:   FullTextWeight(field.Weight),
+				Config:   field.Config,
+			}
+		}
+		builder.FullTextSearch(FullTextSearchParam{Fields: fields, Trigram: dto.FullTextSearch.Trigram})
+	}
 	for _, group := range dto.ExclusiveRequiredFields {
 		builder.ExclusiveRequiredFields(group...)
 	}
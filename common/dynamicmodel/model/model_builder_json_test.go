@@ -15,6 +15,7 @@ func TestParseModelJson_BasicShape(t *testing.T) {
 		"name": "test_basic",
 		"table_name": "test_basics",
 		"should_build_db": true,
+		"track_history": true,
 		"record_label_field": "name",
 		"fields": [
 			{"name": "id", "data_type": "ulid", "primary_key": true, "use_type_default": true},
@@ -25,6 +26,7 @@ func TestParseModelJson_BasicShape(t *testing.T) {
 
 	assert.Equal(t, "test_basic", schema.Name())
 	assert.Equal(t, "test_basics", schema.TableName())
+	assert.True(t, schema.TrackHistory())
 	assert.Equal(t, []string{"id", "name"}, schema.FieldNames())
 
 	idField, ok := schema.Field("id")
@@ -225,6 +227,57 @@ func TestParseModelJson_Constraints(t *testing.T) {
 	assert.Len(t, schema.SearchIndexGroups(), 1)
 }
 
+func TestParseModelJson_FullTextSearch(t *testing.T) {
+	schema := ParseModelJson(`{
+		"name": "test_full_text",
+		"table_name": "test_full_texts",
+		"should_build_db": true,
+		"fields": [
+			{"name": "id", "data_type": "ulid", "primary_key": true, "use_type_default": true},
+			{"name": "title", "data_type": {"type": "string", "min": 1, "max": 200}},
+			{"name": "name", "data_type": {"type": "langjson", "min": 1, "max": 200}}
+		],
+		"full_text_search": {
+			"fields": [
+				{"field": "title", "weight": "A", "config": "english"},
+				{"field": "name", "language": "vi-vn"}
+			],
+			"trigram": true
+		}
+	}`).Build()
+
+	assert.Equal(t, &FullTextSearchParam{
+		Fields: []FullTextFieldParam{
+			{Field: "title", Weight: FullTextWeightA, Config: "english"},
+			{Field: "name", Language: "vi-VN", Weight: FullTextWeightD, Config: DefaultFullTextConfig},
+		},
+		Trigram: true,
+	}, schema.FullTextSearch())
+}
+
+func TestFullTextSearch_RejectsWhatCannotBeIndexed(t *testing.T) {
+	build := func(field FullTextFieldParam) func() {
+		return func() {
+			DefineModel("test_full_text_rejects").
+				ShouldBuildDb().
+				Field(DefineField().Name("id").DataType(FieldDataTypeUlid()).PrimaryKey()).
+				Field(DefineField().Name("title").DataType(FieldDataTypeString(1, 200))).
+				Field(DefineField().Name("name").DataType(FieldDataTypeLangJson(1, 200))).
+				Field(DefineField().Name("rank").DataType(FieldDataTypeInt32(0, 10))).
+				FullTextSearch(FullTextSearchParam{Fields: []FullTextFieldParam{field}}).
+				Build()
+		}
+	}
+
+	assert.NotPanics(t, build(FullTextFieldParam{Field: "title"}))
+	assert.Panics(t, build(FullTextFieldParam{Field: "name"}), "a LangJson field names its language")
+	assert.Panics(t, build(FullTextFieldParam{Field: "title", Language: "en-US"}), "plain text has no languages")
+	assert.Panics(t, build(FullTextFieldParam{Field: "rank"}), "numbers are not text")
+	assert.Panics(t, build(FullTextFieldParam{Field: "missing"}))
+	assert.Panics(t, build(FullTextFieldParam{Field: "title", Weight: "E"}))
+	assert.Panics(t, build(FullTextFieldParam{Field: "title", Config: "english; drop table"}))
+}
+
 func TestParseModelJson_DefaultValue(t *testing.T) {
 	schema := ParseModelJson(`{
 		"name": "test_default",
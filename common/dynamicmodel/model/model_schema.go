@@ -87,9 +87,11 @@ type ModelSchema struct {
 	label             model.LangJson
 	partialUniques    []PartialUniqueParam
 	searchIndexGroups []SearchIndexGroupParam
+	fullTextSearch    *FullTextSearchParam
 	primaryKeys       []string
 	tableName         string
 	tenantKey         *string
+	trackHistory      bool
 
 	// Computed fields
 
@@ -185,6 +187,12 @@ func (this ModelSchema) RecordSubLabelField() string {
 	return this.recordSubLabelField
 }
 
+// TrackHistory tells whether every insert, update and delete of a record is logged with the fields
+// it changed, their old and new values, who changed them and when.
+func (this ModelSchema) TrackHistory() bool {
+	return this.trackHistory
+}
+
 func (this ModelSchema) Fields() map[string]*ModelField {
 	return this.fields
 }
@@ -422,7 +430,11 @@ func (this *ModelSchema) Validate(input DynamicFields, forEdit ...bool) (Dynamic
 		}
 	}
 
-	this.appendExclusiveFieldErrors(&errs, result)
+	// An edit is usually partial, and the group's stored values are what decide it, so the
+	// update flow checks it with ValidateExclusiveFields once it has read the record.
+	if !isForEdit {
+		this.appendExclusiveFieldErrors(&errs, result)
+	}
 
 	if errs.Count() > 0 {
 		return nil, errs
@@ -445,6 +457,15 @@ func (this *ModelSchema) InjectServiceFields(ctx context.Context, result Dynamic
 	}
 }
 
+// ValidateExclusiveFields checks the exclusive field groups of a whole record. An edit is checked
+// on the stored record with the edit applied, which is the record as it will be written: an edit
+// that sets one field of a group while another is stored would otherwise persist both.
+func (this *ModelSchema) ValidateExclusiveFields(record DynamicFields) ft.ClientErrors {
+	var errs ft.ClientErrors
+	this.appendExclusiveFieldErrors(&errs, record)
+	return errs
+}
+
 func (this *ModelSchema) appendExclusiveFieldErrors(errs *ft.ClientErrors, result DynamicFields) {
 	for _, group := range this.exclusiveRequiredFieldGroups {
 		if len(group) < 2 {
@@ -789,6 +810,11 @@ func (this *ModelField) Default() *value {
 	return this.defaultValue
 }
 
+// UsesTypeDefault reports whether an absent value falls back to the data type's default.
+func (this *ModelField) UsesTypeDefault() bool {
+	return this.useTypeDefault
+}
+
 func (this *ModelField) DefaultFn() func() any {
 	return this.defaultFn
 }
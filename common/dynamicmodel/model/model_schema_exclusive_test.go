@@ -0,0 +1,57 @@
+package model
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exclusiveTestSchemaJson = `{
+	"name": "test_exclusive",
+	"table_name": "test_exclusives",
+	"fields": [
+		{"name": "id", "data_type": "ulid", "primary_key": true, "use_type_default": true},
+		{"name": "user_id", "data_type": "ulid"},
+		{"name": "group_id", "data_type": "ulid"},
+		{"name": "note", "data_type": {"type": "string", "min": 0, "max": 100}}
+	],
+	"exclusive_required_fields": [["user_id", "group_id"]]
+}`
+
+const exclusiveTestUlid = "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+
+func TestExclusiveFields_RequiredOnCreate(t *testing.T) {
+	schema := ParseModelJson(exclusiveTestSchemaJson).Build()
+
+	_, errs := schema.Validate(DynamicFields{"note": "no receiver"})
+	assert.Equal(t, 1, errs.Count())
+
+	_, errs = schema.Validate(DynamicFields{"user_id": exclusiveTestUlid, "group_id": exclusiveTestUlid})
+	assert.Equal(t, 1, errs.Count(), "both fields of the group is a conflict")
+}
+
+// A partial edit cannot be judged on its own fields: the group is checked once the edit is
+// applied to the stored record.
+func TestExclusiveFields_EditIsCheckedOnTheMergedRecord(t *testing.T) {
+	schema := ParseModelJson(exclusiveTestSchemaJson).Build()
+	stored := DynamicFields{"id": exclusiveTestUlid, "user_id": exclusiveTestUlid, "group_id": nil}
+
+	_, errs := schema.Validate(DynamicFields{"id": exclusiveTestUlid, "note": "edited"}, true)
+	assert.Zero(t, errs.Count(), "%v", errs)
+
+	merged := func(edit DynamicFields) DynamicFields {
+		record := maps.Clone(stored)
+		maps.Copy(record, edit)
+		return record
+	}
+	assert.Empty(t, schema.ValidateExclusiveFields(merged(DynamicFields{"note": "edited"})),
+		"an edit leaving the group alone keeps the stored choice")
+	assert.Len(t, schema.ValidateExclusiveFields(merged(DynamicFields{"group_id": exclusiveTestUlid})), 1,
+		"setting the other field while one is stored is a conflict")
+	assert.Len(t, schema.ValidateExclusiveFields(merged(DynamicFields{"user_id": nil})), 1,
+		"clearing the only value leaves the group empty")
+	assert.Empty(t, schema.ValidateExclusiveFields(
+		merged(DynamicFields{"user_id": nil, "group_id": exclusiveTestUlid})),
+		"switching sends both fields")
+}
@@ -0,0 +1,398 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/computed"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// DateTrunc buckets a date or date-time group-by field to the start of its day, week or month.
+type DateTrunc string
+
+const (
+	DateTruncDay   DateTrunc = "day"
+	DateTruncWeek  DateTrunc = "week"
+	DateTruncMonth DateTrunc = "month"
+)
+
+// IsValid reports whether the value is one of the supported buckets.
+func (this DateTrunc) IsValid() bool {
+	switch this {
+	case DateTruncDay, DateTruncWeek, DateTruncMonth:
+		return true
+	}
+	return false
+}
+
+// AggregateGroupBy is one GROUP BY key: a column of the root schema, or of a schema one edge
+// away ("peer.title").
+type AggregateGroupBy struct {
+	Field string `json:"field"`
+	// Trunc is optional, and only accepted on a date or date-time field.
+	Trunc DateTrunc `json:"trunc,omitempty"`
+}
+
+// Key names the result column carrying the group value: the field path, suffixed with the bucket
+// when truncated ("created_at:month"), so that one field grouped at two grains keeps both.
+func (this AggregateGroupBy) Key() string {
+	if this.Trunc == "" {
+		return this.Field
+	}
+	return this.Field + ":" + string(this.Trunc)
+}
+
+// AggregateMeasure is one aggregate value computed per group, returned under Name.
+type AggregateMeasure struct {
+	Name     string                     `json:"name"`
+	Function computed.AggregateFunction `json:"function"`
+	// Field is the aggregated column. It is required by every function but count, which counts
+	// rows and takes none.
+	Field string `json:"field,omitempty"`
+}
+
+// AggregateHaving keeps the groups whose measure compares to Value; it becomes a HAVING clause.
+type AggregateHaving struct {
+	Measure  string          `json:"measure"`
+	Operator dmodel.Operator `json:"operator"`
+	Value    any             `json:"value"`
+}
+
+// SqlAggregateGraphOpts holds the parameters of SqlAggregateGraph.
+type SqlAggregateGraphOpts struct {
+	// GroupBy may be empty, which aggregates every matching row into a single group.
+	GroupBy  []AggregateGroupBy
+	Measures []AggregateMeasure
+	Having   []AggregateHaving
+	// Limit caps the number of groups returned. 0 means no limit.
+	Limit int
+	// Optional language code used when filtering LangJson fields.
+	Language *model.LanguageCode
+}
+
+var measureNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+func (this *PgQueryBuilder) SqlAggregateGraph(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlAggregateGraphOpts,
+) (*string, *ft.ClientErrors, error) {
+	sql, cErrs, err := this.buildSqlAggregateGraph(schema, registry, graph, opts)
+	return stringSqlGraphOutcome(sql, cErrs, err)
+}
+
+// buildSqlAggregateGraph compiles:
+//
+//	SELECT <group exprs>, <measure exprs> FROM <root> [JOIN ...] WHERE <graph>
+//	GROUP BY <group exprs> HAVING <having> ORDER BY <group exprs> LIMIT n
+//
+// The graph selects root rows, exactly as in a search. When it filters through a one:many or
+// many:many edge, joining for it would repeat each root row once per matching child and inflate
+// every measure, so the filter moves into a "root keys IN (SELECT ...)" semi-join instead. Group
+// and measure paths are joined on the outer query; a measure on a one:many edge therefore
+// aggregates the children of the matching roots.
+func (this *PgQueryBuilder) buildSqlAggregateGraph(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlAggregateGraphOpts,
+) (string, ft.ClientErrors, error) {
+	if cErrs := validateAggregateShape(opts); len(cErrs) > 0 {
+		return "", cErrs, nil
+	}
+	// The order of a search graph means nothing to a grouped result, and planning it could
+	// join an edge nothing else needs.
+	graph = withoutOrder(graph)
+	filterPlanner, err := this.planGraphJoins(schema, registry, graph, SqlSelectGraphOpts{})
+	if err != nil {
+		return "", nil, err
+	}
+	semiJoin := filterPlanner.needsDistinct()
+	outerGraph := graph
+	if semiJoin {
+		outerGraph = nil
+	}
+	planner, err := this.planGraphJoins(schema, registry, outerGraph, SqlSelectGraphOpts{
+		Columns: ToSelectColumns(aggregatePaths(opts)),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	sb := this.dialectOrDefault().flavor().NewSelectBuilder()
+	groupExprs, selects, cErrs, err := this.aggregateGroupExprs(planner, opts.GroupBy)
+	if err != nil || len(cErrs) > 0 {
+		return "", cErrs, err
+	}
+	measureExprs, measureFields, cErrs, err := this.aggregateMeasureExprs(planner, opts.Measures)
+	if err != nil || len(cErrs) > 0 {
+		return "", cErrs, err
+	}
+	for i, measure := range opts.Measures {
+		selects = append(selects, fmt.Sprintf("%s AS %s", measureExprs[i], pgQuote(measure.Name)))
+	}
+	sb.Select(selects...)
+	this.applyFromWithJoins(sb, schema, planner)
+	this.appendPlannerM2MTenantWheres(sb, planner)
+
+	if semiJoin {
+		inner, innerCErrs, err := this.buildSqlSelectGraph(schema, registry, graph, SqlSelectGraphOpts{
+			Columns:  ToSelectColumns(schema.PrimaryKeys()),
+			Language: opts.Language,
+		})
+		if err != nil || len(innerCErrs) > 0 {
+			return "", innerCErrs, err
+		}
+		sb.Where(fmt.Sprintf("(%s) IN (%s)", strings.Join(planner.primaryKeySelectRefs(), ", "), inner))
+	} else if graph != nil {
+		ctx := &graphSelectCtx{planner: planner, language: opts.Language}
+		predicate, graphCErrs, err := this.graphExpression(
+			ctx, schema, sb, graph.GetCondition(), graph.GetAnd(), graph.GetOr())
+		if err != nil || len(graphCErrs) > 0 {
+			return "", graphCErrs, err
+		}
+		if len(predicate) > 0 {
+			sb.Where(predicate)
+		}
+	}
+
+	if len(groupExprs) > 0 {
+		sb.GroupBy(groupExprs...)
+	}
+	havings, cErrs, err := this.aggregateHavings(sb, opts, measureExprs, measureFields)
+	if err != nil || len(cErrs) > 0 {
+		return "", cErrs, err
+	}
+	if len(havings) > 0 {
+		sb.Having(havings...)
+	}
+	if len(groupExprs) > 0 {
+		orderExprs := make([]string, len(groupExprs))
+		for i, expr := range groupExprs {
+			orderExprs[i] = expr + " ASC"
+		}
+		sb.OrderBy(orderExprs...)
+	}
+	this.applyPagination(sb, 0, opts.Limit)
+
+	sql, args := sb.Build()
+	out, ierr := this.interpolate(sql, args)
+	if ierr != nil {
+		return "", nil, errors.Wrap(ierr, "buildSqlAggregateGraph: interpolate")
+	}
+	return out, nil, nil
+}
+
+// validateAggregateShape checks what can be checked before any field is resolved: the names, the
+// functions and the operators.
+func validateAggregateShape(opts SqlAggregateGraphOpts) ft.ClientErrors {
+	var cErrs ft.ClientErrors
+	if len(opts.Measures) == 0 {
+		cErrs.Append(*ft.NewValidationError("measures", ft.ErrorKey("err_aggregate_no_measure"),
+			"at least one measure is required"))
+		return cErrs
+	}
+	keys := map[string]bool{}
+	for i, group := range opts.GroupBy {
+		if group.Trunc != "" && !group.Trunc.IsValid() {
+			cErrs.Append(*ft.NewValidationError(fmt.Sprintf("group_by[%d].trunc", i),
+				ft.ErrorKey("err_invalid_date_trunc"), "trunc must be one of day, week or month"))
+		}
+		if keys[group.Key()] {
+			cErrs.Append(*clientErrorDuplicateAggregateKey(fmt.Sprintf("group_by[%d]", i)))
+		}
+		keys[group.Key()] = true
+	}
+	for i, measure := range opts.Measures {
+		prefix := fmt.Sprintf("measures[%d]", i)
+		if !measureNameRegex.MatchString(measure.Name) {
+			cErrs.Append(*ft.NewValidationError(prefix+".name", ft.ErrorKey("err_invalid_measure_name"),
+				"measure name must be lowercase letters, digits and underscores, starting with a letter"))
+		} else if keys[measure.Name] {
+			cErrs.Append(*clientErrorDuplicateAggregateKey(prefix + ".name"))
+		}
+		keys[measure.Name] = true
+		if !measure.Function.IsValid() {
+			cErrs.Append(*ft.NewValidationError(prefix+".function", ft.ErrorKey("err_invalid_aggregate_function"),
+				"function must be one of count, count_distinct, sum, avg, min or max"))
+			continue
+		}
+		if measure.Function == computed.AggCount && measure.Field != "" {
+			cErrs.Append(*ft.NewValidationError(prefix+".field", ft.ErrorKey("err_aggregate_field_not_allowed"),
+				"count counts rows and takes no field; use count_distinct to count values"))
+		}
+		if measure.Function != computed.AggCount && measure.Field == "" {
+			cErrs.Append(*dmodel.NewMissingFieldErr(prefix + ".field"))
+		}
+	}
+	for i, having := range opts.Having {
+		prefix := fmt.Sprintf("having[%d]", i)
+		if measureIndex(opts.Measures, having.Measure) < 0 {
+			cErrs.Append(*ft.NewValidationError(prefix+".measure", ft.ErrorKey("err_unknown_measure"),
+				"having must name one of the measures"))
+		}
+		if !isComparisonOperator(having.Operator) {
+			cErrs.Append(*ft.NewValidationError(prefix+".operator", ft.ErrorKey("err_invalid_having_operator"),
+				"having supports =, !=, >, >=, < and <= only"))
+		}
+	}
+	return cErrs
+}
+
+func clientErrorDuplicateAggregateKey(field string) *ft.ClientErrorItem {
+	return ft.NewValidationError(field, ft.ErrorKey("err_duplicate_aggregate_key"),
+		"every group and measure must have a distinct name")
+}
+
+func isComparisonOperator(op dmodel.Operator) bool {
+	switch op {
+	case dmodel.Equals, dmodel.NotEquals, dmodel.GreaterThan, dmodel.GreaterEqual, dmodel.LessThan, dmodel.LessEqual:
+		return true
+	}
+	return false
+}
+
+// withoutOrder returns the graph's filter alone.
+func withoutOrder(graph *dmodel.SearchGraph) *dmodel.SearchGraph {
+	if graph == nil {
+		return nil
+	}
+	filter := *graph
+	return filter.Order(nil)
+}
+
+// aggregatePaths lists the field paths the outer query must join for.
+func aggregatePaths(opts SqlAggregateGraphOpts) []string {
+	paths := make([]string, 0, len(opts.GroupBy)+len(opts.Measures))
+	for _, group := range opts.GroupBy {
+		paths = append(paths, group.Field)
+	}
+	for _, measure := range opts.Measures {
+		if measure.Field != "" {
+			paths = append(paths, measure.Field)
+		}
+	}
+	return paths
+}
+
+// aggregateGroupExprs returns the GROUP BY expressions, and the select items that project them
+// under their keys.
+func (this *PgQueryBuilder) aggregateGroupExprs(
+	planner *joinPlanner, groups []AggregateGroupBy,
+) (exprs []string, selects []string, cErrs ft.ClientErrors, err error) {
+	for i, group := range groups {
+		field, ref, err := planner.resolveFieldSqlRef(group.Field, MaxSelectGraphColumnDots)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if columnCategoryFor(field.ColumnType()) == columnJSON {
+			cErrs.Append(*clientErrorAggregateFieldType(fmt.Sprintf("group_by[%d].field", i), "grouped by"))
+			continue
+		}
+		expr := ref
+		if group.Trunc != "" {
+			switch field.ColumnType() {
+			case dmodel.FieldDataTypeNameModelDate:
+				expr = this.dialectOrDefault().dateTrunc(group.Trunc, ref, true)
+			case dmodel.FieldDataTypeNameModelDateTime:
+				expr = this.dialectOrDefault().dateTrunc(group.Trunc, ref, false)
+			default:
+				cErrs.Append(*ft.NewValidationError(fmt.Sprintf("group_by[%d].trunc", i),
+					ft.ErrorKey("err_invalid_date_trunc"), "trunc applies to a date or date-time field only"))
+				continue
+			}
+		}
+		exprs = append(exprs, expr)
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, pgQuote(group.Key())))
+	}
+	return exprs, selects, cErrs, nil
+}
+
+// aggregateMeasureExprs returns one aggregate call per measure, and the field each one aggregates
+// (nil for count).
+func (this *PgQueryBuilder) aggregateMeasureExprs(
+	planner *joinPlanner, measures []AggregateMeasure,
+) (exprs []string, fields []*dmodel.ModelField, cErrs ft.ClientErrors, err error) {
+	exprs = make([]string, len(measures))
+	fields = make([]*dmodel.ModelField, len(measures))
+	for i, measure := range measures {
+		if measure.Function == computed.AggCount {
+			exprs[i] = aggregateCall(measure.Function, "")
+			continue
+		}
+		field, ref, err := planner.resolveFieldSqlRef(measure.Field, MaxSelectGraphColumnDots)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !measureAppliesTo(measure.Function, field) {
+			cErrs.Append(*clientErrorAggregateFieldType(
+				fmt.Sprintf("measures[%d].field", i), string(measure.Function)+" applied"))
+			continue
+		}
+		exprs[i] = aggregateCall(measure.Function, ref)
+		fields[i] = field
+	}
+	return exprs, fields, cErrs, nil
+}
+
+// measureAppliesTo reports whether PostgreSQL can aggregate the field with the function. sum and
+// avg need a number; nothing aggregates JSON, an array or, but for counting, a boolean.
+func measureAppliesTo(function computed.AggregateFunction, field *dmodel.ModelField) bool {
+	if field.IsArray() {
+		return false
+	}
+	category := columnCategoryFor(field.ColumnType())
+	switch function {
+	case computed.AggSum, computed.AggAvg:
+		return category == columnInt || category == columnNumeric
+	case computed.AggMin, computed.AggMax:
+		return category != columnJSON && category != columnBool
+	default:
+		return category != columnJSON
+	}
+}
+
+func clientErrorAggregateFieldType(field string, usage string) *ft.ClientErrorItem {
+	return ft.NewValidationError(field, ft.ErrorKey("err_aggregate_field_type"),
+		fmt.Sprintf("a field of this type cannot be %s", usage))
+}
+
+// aggregateHavings compiles the HAVING filters. A count, sum or average is compared as a number;
+// a min or max keeps its field's type, and its value is converted like a filter on that field.
+func (this *PgQueryBuilder) aggregateHavings(
+	sb *sqlbuilder.SelectBuilder, opts SqlAggregateGraphOpts, measureExprs []string, measureFields []*dmodel.ModelField,
+) ([]string, ft.ClientErrors, error) {
+	havings := make([]string, 0, len(opts.Having))
+	for i, having := range opts.Having {
+		m := measureIndex(opts.Measures, having.Measure)
+		measure := opts.Measures[m]
+		if measure.Function == computed.AggMin || measure.Function == computed.AggMax {
+			predicate, cErrs, err := this.comparisonPredicate(sb, measureExprs[m], measureFields[m], having.Operator, having.Value)
+			if err != nil || len(cErrs) > 0 {
+				return nil, cErrs, err
+			}
+			havings = append(havings, predicate)
+			continue
+		}
+		v, ok := unwrapValue(reflect.ValueOf(derefConditionOperand(having.Value)))
+		if !ok || !valueAllowed(columnNumeric, v) {
+			return nil, ft.ClientErrors{
+				*dmodel.NewInvalidDataTypeErr(fmt.Sprintf("having[%d].value", i), "numeric"),
+			}, nil
+		}
+		havings = append(havings, comparisonExpr(sb, measureExprs[m], having.Operator, v.Interface()))
+	}
+	return havings, nil, nil
+}
+
+func measureIndex(measures []AggregateMeasure, name string) int {
+	for i, measure := range measures {
+		if measure.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,167 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+const (
+	// FullTextVectorColumn is the generated tsvector column a schema's full-text search matches.
+	FullTextVectorColumn = "_search_vector"
+	// FullTextTrigramColumn is the generated text column the trigram index covers.
+	FullTextTrigramColumn = "_search_text"
+	// SearchRankColumn carries each row's relevance in a ranked search; see SqlSelectGraphOpts.TextQuery.
+	SearchRankColumn = "_search_rank"
+)
+
+// defineFullTextColumns adds the generated columns of the schema's full-text search. PostgreSQL
+// keeps them in step with the fields on every write, so no code path can forget to.
+func (this *PgQueryBuilder) defineFullTextColumns(builder *sqlbuilder.CreateTableBuilder, schema *dmodel.ModelSchema) {
+	for _, def := range fullTextColumnDefs(schema) {
+		builder.Define(pgQuote(def.Name), def.Type, def.Constraint)
+	}
+}
+
+func fullTextColumnDefs(schema *dmodel.ModelSchema) []columnDef {
+	fts := schema.FullTextSearch()
+	if fts == nil {
+		return nil
+	}
+	vectors := make([]string, len(fts.Fields))
+	texts := make([]string, len(fts.Fields))
+	for i, field := range fts.Fields {
+		text := fullTextSourceExpr(field)
+		vectors[i] = fmt.Sprintf("setweight(to_tsvector(%s::regconfig, %s), %s)",
+			pgStringLiteral(field.Config), text, pgStringLiteral(string(field.Weight)))
+		texts[i] = text
+	}
+	out := []columnDef{{
+		Name:       FullTextVectorColumn,
+		Type:       "tsvector",
+		Constraint: fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", strings.Join(vectors, " || ")),
+		Generated:  true,
+	}}
+	if fts.Trigram {
+		out = append(out, columnDef{
+			Name:       FullTextTrigramColumn,
+			Type:       "text",
+			Constraint: fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", strings.Join(texts, " || ' ' || ")),
+			Generated:  true,
+		})
+	}
+	return out
+}
+
+// fullTextSourceExpr is the text of one searchable field: the column itself, or one translation
+// of a LangJson column. A NULL would blank out the whole concatenation, hence the coalesce.
+func fullTextSourceExpr(field dmodel.FullTextFieldParam) string {
+	ref := pgQuote(field.Field)
+	if field.Language != "" {
+		ref = fmt.Sprintf("(%s ->> %s)", ref, pgStringLiteral(field.Language))
+	}
+	return fmt.Sprintf("coalesce(%s, '')", ref)
+}
+
+// fullTextIndexSqls indexes the generated columns: GIN over the tsvector, and GIN with
+// gin_trgm_ops over the text when the schema asks for trigrams. The extension is created on the
+// way, as the operator class comes from pg_trgm.
+func (this *PgQueryBuilder) fullTextIndexSqls(schema *dmodel.ModelSchema) ([]string, error) {
+	fts := schema.FullTextSearch()
+	if fts == nil {
+		return nil, nil
+	}
+	tableRef := pgQuoteTable(strings.Split(schema.TableName(), ".")...)
+	vectorIndex := toSnakeLower(schema.TableName() + FullTextVectorColumn + "_idx")
+	if err := mustFitIdentifier("fullTextIndexSqls", schema.TableName(), vectorIndex); err != nil {
+		return nil, err
+	}
+	out := []string{fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s)",
+		pgQuote(vectorIndex), tableRef, pgQuote(FullTextVectorColumn))}
+	if !fts.Trigram {
+		return out, nil
+	}
+	trigramIndex := toSnakeLower(schema.TableName() + FullTextTrigramColumn + "_trgm_idx")
+	if err := mustFitIdentifier("fullTextIndexSqls", schema.TableName(), trigramIndex); err != nil {
+		return nil, err
+	}
+	return append(out,
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s gin_trgm_ops)",
+			pgQuote(trigramIndex), tableRef, pgQuote(FullTextTrigramColumn)),
+	), nil
+}
+
+// fullTextColumns lists the schema's columns by name. It stands in for the wildcard on a schema
+// with a full-text search, whose generated columns are no fields and must not reach the caller.
+func fullTextColumns(schema *dmodel.ModelSchema) []SelectColumn {
+	columns := schema.Columns()
+	out := make([]SelectColumn, len(columns))
+	for i, column := range columns {
+		out[i] = SelectColumn(column.Name())
+	}
+	return out
+}
+
+// textSearchTsquery ORs the query as parsed by each configuration the fields are indexed with,
+// so that a term "english" stems in one field and "simple" keeps whole in another matches both.
+// websearch_to_tsquery never fails on user input: quotes, "or" and a leading "-" are its syntax,
+// and anything else is read as plain words.
+func textSearchTsquery(sb *sqlbuilder.SelectBuilder, fts *dmodel.FullTextSearchParam, query string) string {
+	configs := fts.Configs()
+	parts := make([]string, len(configs))
+	for i, config := range configs {
+		parts[i] = fmt.Sprintf("websearch_to_tsquery(%s::regconfig, %s)", pgStringLiteral(config), sb.Var(query))
+	}
+	return strings.Join(parts, " || ")
+}
+
+func requireFullTextSearch(caller string, schema *dmodel.ModelSchema) (*dmodel.FullTextSearchParam, error) {
+	fts := schema.FullTextSearch()
+	if fts == nil {
+		return nil, errors.Errorf("%s: schema '%s' declares no full-text search", caller, schema.Name())
+	}
+	return fts, nil
+}
+
+// applyTextSearchWhere keeps the rows matching query. With trigrams, a row whose text is merely
+// similar to the query word by word matches too, which is what tolerates a typo.
+func (this *PgQueryBuilder) applyTextSearchWhere(
+	sb *sqlbuilder.SelectBuilder, schema *dmodel.ModelSchema, planner *joinPlanner, query string,
+) error {
+	if query == "" {
+		return nil
+	}
+	fts, err := requireFullTextSearch("applyTextSearchWhere", schema)
+	if err != nil {
+		return err
+	}
+	match := fmt.Sprintf("%s @@ (%s)", planner.rootColumnRef(FullTextVectorColumn), textSearchTsquery(sb, fts, query))
+	if fts.Trigram {
+		match = fmt.Sprintf("(%s OR %s <%% %s)", match, sb.Var(query), planner.rootColumnRef(FullTextTrigramColumn))
+	}
+	sb.Where(match)
+	return nil
+}
+
+// textSearchRank scores a row against query: ts_rank weighs the matched terms by the weights of
+// the fields they are in, and with trigrams the word similarity is added, so that a close
+// misspelling still ranks above a distant one.
+func (this *PgQueryBuilder) textSearchRank(
+	sb *sqlbuilder.SelectBuilder, schema *dmodel.ModelSchema, planner *joinPlanner, query string,
+) (string, error) {
+	fts, err := requireFullTextSearch("textSearchRank", schema)
+	if err != nil {
+		return "", err
+	}
+	rank := fmt.Sprintf("ts_rank(%s, %s)", planner.rootColumnRef(FullTextVectorColumn), textSearchTsquery(sb, fts, query))
+	if fts.Trigram {
+		rank = fmt.Sprintf("(%s + word_similarity(%s, %s))",
+			rank, sb.Var(query), planner.rootColumnRef(FullTextTrigramColumn))
+	}
+	return rank, nil
+}
@@ -0,0 +1,150 @@
+package orm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+// KeysetOpts switches a graph select from OFFSET paging to keyset paging: instead of skipping
+// Page*Size rows, the query seeks past the last row of the previous page by its sort key. The
+// cost of a page no longer grows with its depth, and rows written between two pages neither
+// repeat nor go missing.
+type KeysetOpts struct {
+	// After holds the sort-key values of the previous page's last row, one per item of
+	// KeysetOrder, as the text the database gave for them in the KeysetColumn columns. A nil
+	// entry is a NULL. An empty After asks for the first page.
+	After []*string
+}
+
+const keysetColumnPrefix = "_keyset_"
+
+// KeysetColumn names the result column that carries the i-th sort-key value of each row, as text,
+// so that the caller can build the next page's After from the last row.
+func KeysetColumn(i int) string {
+	return keysetColumnPrefix + strconv.Itoa(i)
+}
+
+// IsKeysetColumn reports whether a result column is one of the KeysetColumn ones, which the
+// caller strips from the rows it returns.
+func IsKeysetColumn(name string) bool {
+	return strings.HasPrefix(name, keysetColumnPrefix)
+}
+
+// KeysetOrder is the order a keyset page is sorted by: the requested one, tie-broken by every
+// primary key it does not already name. Without the tiebreak, rows sharing a sort value would
+// have no position to seek past.
+func KeysetOrder(schema *dmodel.ModelSchema, order dmodel.SearchOrder) dmodel.SearchOrder {
+	out := make(dmodel.SearchOrder, 0, len(order)+1)
+	named := map[string]bool{}
+	for _, item := range order {
+		if len(item) == 0 || item[0] == "" {
+			continue
+		}
+		out = append(out, item)
+		named[item.Field()] = true
+	}
+	for _, key := range schema.PrimaryKeys() {
+		if !named[key] {
+			out = append(out, dmodel.NewSearchOrderItem(key, dmodel.Asc))
+		}
+	}
+	return out
+}
+
+// orderKey is one resolved ORDER BY item.
+type orderKey struct {
+	ref  string
+	desc bool
+	// notNull is set for the root's primary keys, which spares the seek a NULL branch.
+	notNull bool
+}
+
+func (this orderKey) sql(dialect sqlDialect) string {
+	if this.desc {
+		return this.ref + " DESC" + dialect.nullsOrder(true)
+	}
+	return this.ref + " ASC" + dialect.nullsOrder(false)
+}
+
+func orderKeysSql(dialect sqlDialect, keys []orderKey) []string {
+	exprs := make([]string, len(keys))
+	for i, key := range keys {
+		exprs[i] = key.sql(dialect)
+	}
+	return exprs
+}
+
+// keysetSelectRefs projects each sort key as text. Text survives the round trip through the
+// cursor unchanged, and compares back against the column once the database casts the literal to
+// the column's type.
+func keysetSelectRefs(dialect sqlDialect, keys []orderKey) []string {
+	refs := make([]string, len(keys))
+	for i, key := range keys {
+		refs[i] = fmt.Sprintf("%s AS %s", dialect.textCast(key.ref), pgQuote(KeysetColumn(i)))
+	}
+	return refs
+}
+
+// keysetSeekPredicate selects the rows that sort after the given key values:
+//
+//	k0 after v0
+//	OR (k0 = v0 AND k1 after v1)
+//	OR (k0 = v0 AND k1 = v1 AND k2 after v2) ...
+//
+// PostgreSQL sorts NULLs last ascending and first descending, and "after" follows suit.
+func keysetSeekPredicate(sb *sqlbuilder.SelectBuilder, keys []orderKey, after []*string) (string, error) {
+	if len(after) == 0 {
+		return "", nil
+	}
+	if len(after) != len(keys) {
+		return "", errors.Errorf("keysetSeekPredicate: %d values for %d sort keys", len(after), len(keys))
+	}
+
+	branches := make([]string, 0, len(keys))
+	for i, key := range keys {
+		terms := make([]string, 0, i+1)
+		for j := range i {
+			terms = append(terms, keysetEqual(sb, keys[j], after[j]))
+		}
+		next := keysetAfter(sb, key, after[i])
+		if next == "" {
+			// Nothing sorts after a NULL in an ascending key but other NULLs, which the later
+			// keys tell apart.
+			continue
+		}
+		terms = append(terms, next)
+		branches = append(branches, sb.And(terms...))
+	}
+	if len(branches) == 0 {
+		return "FALSE", nil
+	}
+	return sb.Or(branches...), nil
+}
+
+func keysetEqual(sb *sqlbuilder.SelectBuilder, key orderKey, value *string) string {
+	if value == nil {
+		return sb.IsNull(key.ref)
+	}
+	return sb.Equal(key.ref, *value)
+}
+
+func keysetAfter(sb *sqlbuilder.SelectBuilder, key orderKey, value *string) string {
+	switch {
+	case key.desc && value == nil:
+		return sb.IsNotNull(key.ref)
+	case key.desc:
+		return sb.LessThan(key.ref, *value)
+	case value == nil:
+		return ""
+	case key.notNull:
+		return sb.GreaterThan(key.ref, *value)
+	default:
+		return sb.Or(sb.GreaterThan(key.ref, *value), sb.IsNull(key.ref))
+	}
+}
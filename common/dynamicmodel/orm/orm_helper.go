@@ -11,8 +11,22 @@ import (
 const (
 	DialectMySql    = "mysql"
 	DialectPostgres = "postgres"
+	// DialectSqlite is named after the driver, as ent names it.
+	DialectSqlite = "sqlite3"
 )
 
+// NewQueryBuilder returns the query builder of a configured dialect.
+func NewQueryBuilder(dialect string) (QueryBuilder, error) {
+	switch dialect {
+	case DialectPostgres:
+		return NewPgQueryBuilder(), nil
+	case DialectSqlite:
+		return NewSqliteQueryBuilder(), nil
+	default:
+		return nil, errors.Errorf("NewQueryBuilder: dialect '%s' is not supported", dialect)
+	}
+}
+
 // GenCreateSql generates CREATE TABLE statements for the schemas in registry, in
 // FK-dependency order.
 //
@@ -32,12 +46,12 @@ func GenCreateSql(registry *model.SchemaRegistry, dialect string, schemaPrefixes
 	if registry == nil {
 		return nil, errors.New("GenCreateSql: schema registry is required")
 	}
-	if dialect != DialectPostgres {
-		return nil, errors.Errorf("GenCreateSql: dialect '%s' is not supported", dialect)
+	builder, err := NewQueryBuilder(dialect)
+	if err != nil {
+		return nil, errors.Wrap(err, "GenCreateSql")
 	}
-	builder := NewPgQueryBuilder()
 	var results []string
-	err := registry.ForEachOrder(func(schemaName string, s *model.ModelSchema) error {
+	err = registry.ForEachOrder(func(schemaName string, s *model.ModelSchema) error {
 		if !matchesAnyPrefix(schemaName, schemaPrefixes) {
 			return nil
 		}
@@ -28,6 +28,8 @@ var _ QueryBuilder = (*PgQueryBuilder)(nil)
 // PgQueryBuilder implements QueryBuilder for PostgreSQL.
 type PgQueryBuilder struct {
 	predefinedPredicates map[string]map[string]PredefinedPredicateTreatment
+	// dialect is nil for PostgreSQL; SqliteQueryBuilder sets its own.
+	dialect sqlDialect
 }
 
 func NewPgQueryBuilder() QueryBuilder {
@@ -36,6 +38,14 @@ func NewPgQueryBuilder() QueryBuilder {
 	}
 }
 
+// dialectOrDefault is the dialect the builder writes, PostgreSQL unless set.
+func (this *PgQueryBuilder) dialectOrDefault() sqlDialect {
+	if this.dialect == nil {
+		return pgDialect{}
+	}
+	return this.dialect
+}
+
 func (this *PgQueryBuilder) SqlCreateTable(
 	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
 ) ([]string, *ft.ClientErrors, error) {
@@ -51,18 +61,24 @@ func (this *PgQueryBuilder) SqlCreateTable(
 	if err != nil {
 		return nil, nil, err
 	}
+	fullTextSqls, err := this.fullTextIndexSqls(schema)
+	if err != nil {
+		return nil, nil, err
+	}
 	out := append([]string{createSql}, indexSqls...)
 	out = append(out, searchIndexSqls...)
+	out = append(out, fullTextSqls...)
 	return out, nil, nil
 }
 
 func (this *PgQueryBuilder) buildCreateTableSql(
 	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
 ) (string, error) {
-	builder := sqlbuilder.PostgreSQL.NewCreateTableBuilder().CreateTable(pgQuote(schema.TableName()))
+	builder := this.dialectOrDefault().flavor().NewCreateTableBuilder().CreateTable(pgQuote(schema.TableName()))
 	if err := this.defineColumns(builder, schema); err != nil {
 		return "", err
 	}
+	this.defineFullTextColumns(builder, schema)
 	if err := this.defineKeys(builder, schema); err != nil {
 		return "", err
 	}
@@ -247,17 +263,50 @@ func isLowerOrDigitAscii(r rune) bool {
 	return isLowerAscii(r) || (r >= '0' && r <= '9')
 }
 
+// columnDef is one column of a CREATE TABLE, also what an ALTER TABLE ... ADD COLUMN adds.
+type columnDef struct {
+	Name string
+	Type string
+	// Constraint is what follows the type: NULL, NOT NULL, or the expression of a generated column.
+	Constraint string
+	Generated  bool
+}
+
+func (this columnDef) sql() string {
+	return fmt.Sprintf("%s %s %s", pgQuote(this.Name), this.Type, this.Constraint)
+}
+
+// tableConstraint is a named table constraint; Definition is what follows "CONSTRAINT <name>".
+type tableConstraint struct {
+	Name       string
+	Definition string
+	ForeignKey bool
+}
+
 func (this *PgQueryBuilder) defineColumns(
 	builder *sqlbuilder.CreateTableBuilder, schema *dmodel.ModelSchema,
 ) error {
-	for _, col := range schema.Columns() {
-		pgType, err := resolveModelFieldToPgType(col)
+	defs, err := modelColumnDefs(this.dialectOrDefault(), schema)
+	if err != nil {
+		return err
+	}
+	for _, def := range defs {
+		builder.Define(pgQuote(def.Name), def.Type, def.Constraint)
+	}
+	return nil
+}
+
+func modelColumnDefs(dialect sqlDialect, schema *dmodel.ModelSchema) ([]columnDef, error) {
+	columns := schema.Columns()
+	out := make([]columnDef, len(columns))
+	for i, col := range columns {
+		colType, err := dialect.columnType(col)
 		if err != nil {
-			return errors.Wrapf(err, "defineColumns: column '%s'", col.Name())
+			return nil, errors.Wrapf(err, "defineColumns: column '%s'", col.Name())
 		}
-		builder.Define(pgQuote(col.Name()), pgType, col.ColumnNullable())
+		out[i] = columnDef{Name: col.Name(), Type: colType, Constraint: col.ColumnNullable()}
 	}
-	return nil
+	return out, nil
 }
 
 func (this *PgQueryBuilder) defineKeys(
@@ -266,7 +315,32 @@ func (this *PgQueryBuilder) defineKeys(
 	if keys := schema.PrimaryKeys(); len(keys) > 0 {
 		builder.Define("PRIMARY KEY", fmt.Sprintf("(%s)", strings.Join(pgQuoteArr(keys), ", ")))
 	}
+	uniques, err := uniqueConstraints(schema)
+	if err != nil {
+		return err
+	}
+	for _, unique := range uniques {
+		builder.Define("CONSTRAINT "+pgQuote(unique.Name), unique.Definition)
+	}
+	return nil
+}
+
+// primaryKeyConstraint is the constraint PostgreSQL creates for the unnamed PRIMARY KEY of
+// CREATE TABLE, named after the table.
+func primaryKeyConstraint(schema *dmodel.ModelSchema) *tableConstraint {
+	keys := schema.PrimaryKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+	return &tableConstraint{
+		Name:       schema.TableName() + "_pkey",
+		Definition: fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pgQuoteArr(keys), ", ")),
+	}
+}
+
+func uniqueConstraints(schema *dmodel.ModelSchema) ([]tableConstraint, error) {
 	tenantKey := schema.TenantKey()
+	var out []tableConstraint
 	for _, unique := range schema.AllUniqueGroups() {
 		if len(unique.Fields) == 0 {
 			continue
@@ -274,12 +348,14 @@ func (this *PgQueryBuilder) defineKeys(
 		effectiveUnique := prependTenantKey(tenantKey, unique.Fields)
 		name := resolveCompositeUniqueIndexName(schema.TableName(), unique, effectiveUnique)
 		if err := mustFitIdentifier("defineKeys", schema.TableName(), name); err != nil {
-			return err
+			return nil, err
 		}
-		cols := fmt.Sprintf("(%s)", strings.Join(pgQuoteArr(effectiveUnique), ", "))
-		builder.Define(fmt.Sprintf("CONSTRAINT %s UNIQUE", pgQuote(name)), cols)
+		out = append(out, tableConstraint{
+			Name:       name,
+			Definition: fmt.Sprintf("UNIQUE (%s)", strings.Join(pgQuoteArr(effectiveUnique), ", ")),
+		})
 	}
-	return nil
+	return out, nil
 }
 
 // resolveCompositeUniqueIndexName derives the UNIQUE constraint name. An explicit IndexName
@@ -297,36 +373,56 @@ func resolveCompositeUniqueIndexName(
 func (this *PgQueryBuilder) defineForeignKeys(
 	builder *sqlbuilder.CreateTableBuilder, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
 ) error {
-	if err := this.defineForeignKeysDeclaredOnSchema(builder, schema, registry); err != nil {
+	fks, err := foreignKeyConstraints(schema, registry)
+	if err != nil {
 		return err
 	}
-	return this.defineForeignKeysFromParentOneToMany(builder, schema, registry)
+	for _, fk := range fks {
+		builder.Define("CONSTRAINT "+pgQuote(fk.Name), fk.Definition)
+	}
+	return nil
 }
 
-func (this *PgQueryBuilder) defineForeignKeysDeclaredOnSchema(
-	builder *sqlbuilder.CreateTableBuilder, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
-) error {
+func foreignKeyConstraints(schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry) ([]tableConstraint, error) {
+	declared, err := foreignKeysDeclaredOnSchema(schema, registry)
+	if err != nil {
+		return nil, err
+	}
+	fromParents, err := foreignKeysFromParentOneToMany(schema, registry)
+	if err != nil {
+		return nil, err
+	}
+	return append(declared, fromParents...), nil
+}
+
+func foreignKeysDeclaredOnSchema(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+) ([]tableConstraint, error) {
+	var out []tableConstraint
 	for _, rel := range schema.ToRelations() {
 		if !isFkOwnerRelationType(rel.RelationType) {
 			continue
 		}
 		refSchema := registry.Get(rel.DestSchemaName)
 		if refSchema == nil {
-			return errors.Errorf(
+			return nil, errors.Errorf(
 				"defineForeignKeys: referenced schema not found for relation '%s'", rel.Edge,
 			)
 		}
-		if err := this.appendCompositeForeignKey(builder, schema, refSchema, rel); err != nil {
-			return err
+		fk, err := compositeForeignKey(schema, refSchema, rel)
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, fk)
 	}
-	return nil
+	return out, nil
 }
 
-func (this *PgQueryBuilder) defineForeignKeysFromParentOneToMany(
-	builder *sqlbuilder.CreateTableBuilder, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
-) error {
-	return registry.ForEach(func(_ string, parentSch *dmodel.ModelSchema) error {
+func foreignKeysFromParentOneToMany(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+) ([]tableConstraint, error) {
+	var out []tableConstraint
+	err := registry.ForEach(func(_ string, parentSch *dmodel.ModelSchema) error {
 		for _, rel := range parentSch.ToRelations() {
 			if rel.RelationType != dmodel.RelationTypeOneToMany || rel.DestSchemaName != schema.Name() {
 				continue
@@ -334,12 +430,15 @@ func (this *PgQueryBuilder) defineForeignKeysFromParentOneToMany(
 			if schemaAlreadyDeclaresFkToParent(schema, parentSch, rel) {
 				continue
 			}
-			if err := this.appendCompositeForeignKey(builder, schema, parentSch, rel); err != nil {
+			fk, err := compositeForeignKey(schema, parentSch, rel)
+			if err != nil {
 				return err
 			}
+			out = append(out, fk)
 		}
 		return nil
 	})
+	return out, err
 }
 
 func schemaAlreadyDeclaresFkToParent(
@@ -356,15 +455,12 @@ func schemaAlreadyDeclaresFkToParent(
 	return false
 }
 
-func (this *PgQueryBuilder) appendCompositeForeignKey(
-	builder *sqlbuilder.CreateTableBuilder,
-	fkOwner *dmodel.ModelSchema,
-	refSchema *dmodel.ModelSchema,
-	rel dmodel.ModelRelation,
-) error {
+func compositeForeignKey(
+	fkOwner *dmodel.ModelSchema, refSchema *dmodel.ModelSchema, rel dmodel.ModelRelation,
+) (tableConstraint, error) {
 	pairs := rel.EffectiveForeignKeys()
 	if len(pairs) == 0 {
-		return errors.Errorf("appendCompositeForeignKey: relation '%s' has no FK columns", rel.Edge)
+		return tableConstraint{}, errors.Errorf("compositeForeignKey: relation '%s' has no FK columns", rel.Edge)
 	}
 	fkCols := make([]string, len(pairs))
 	refCols := make([]string, len(pairs))
@@ -376,12 +472,13 @@ func (this *PgQueryBuilder) appendCompositeForeignKey(
 	if len(pairs) > 1 {
 		suffix = fmt.Sprintf("comp_%s", rel.Edge)
 	}
-	fkName := pgQuote(fmt.Sprintf("%s_%s_fkey", fkOwner.TableName(), suffix))
-	fkBody := fmt.Sprintf("(%s) REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
-		strings.Join(fkCols, ", "), pgQuote(refSchema.TableName()), strings.Join(refCols, ", "),
-		rel.OnUpdate.Sql(), rel.OnDelete.Sql())
-	builder.Define(fmt.Sprintf("CONSTRAINT %s FOREIGN KEY", fkName), fkBody)
-	return nil
+	return tableConstraint{
+		Name: fmt.Sprintf("%s_%s_fkey", fkOwner.TableName(), suffix),
+		Definition: fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
+			strings.Join(fkCols, ", "), pgQuote(refSchema.TableName()), strings.Join(refCols, ", "),
+			rel.OnUpdate.Sql(), rel.OnDelete.Sql()),
+		ForeignKey: true,
+	}, nil
 }
 
 func (this *PgQueryBuilder) SqlSelectGraph(
@@ -394,12 +491,18 @@ func (this *PgQueryBuilder) SqlSelectGraph(
 func (this *PgQueryBuilder) buildSqlSelectGraph(
 	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlSelectGraphOpts,
 ) (string, ft.ClientErrors, error) {
+	if opts.TextQuery != "" && opts.Keyset != nil {
+		return "", nil, errors.New("buildSqlSelectGraph: a relevance order cannot be paged by keyset")
+	}
+	if len(opts.Columns) == 0 && schema.FullTextSearch() != nil {
+		opts.Columns = fullTextColumns(schema)
+	}
 	planner, err := this.planGraphJoins(schema, registry, graph, opts)
 	if err != nil {
 		return "", nil, err
 	}
 	ctx := &graphSelectCtx{planner: planner, language: opts.Language}
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb := this.dialectOrDefault().flavor().NewSelectBuilder()
 	// A one:many or many:many join repeats each root row once per matching child. DISTINCT
 	// restores the caller's grain; the explicit DISTINCT:: token remains the other way to ask
 	// for it. A many:one join is cardinality-preserving and pays nothing here.
@@ -411,16 +514,34 @@ func (this *PgQueryBuilder) buildSqlSelectGraph(
 	// The order has to be resolved before the projection is written: under SELECT DISTINCT,
 	// PostgreSQL requires every ORDER BY expression to appear in the select list, and a sort on a
 	// joined column would otherwise be absent from it.
-	var orderExprs []string
+	var order dmodel.SearchOrder
 	if graph != nil {
-		var err error
-		orderExprs, err = this.orderExprs(ctx, schema, graph.GetOrder())
+		order = graph.GetOrder()
+	}
+	// Relevance ties are common, so a ranked search breaks them on the primary keys, as a keyset
+	// does, to keep one OFFSET page from overlapping the next.
+	if opts.Keyset != nil || opts.TextQuery != "" {
+		order = KeysetOrder(schema, order)
+	}
+	orderKeys, err := this.orderKeys(ctx, schema, order)
+	if err != nil {
+		return "", nil, err
+	}
+	orderExprs := orderKeysSql(this.dialectOrDefault(), orderKeys)
+	extraRefs := orderRefsForDistinct(isDistinct, orderKeys)
+	if opts.Keyset != nil {
+		extraRefs = append(extraRefs, keysetSelectRefs(this.dialectOrDefault(), orderKeys)...)
+	}
+	if opts.TextQuery != "" {
+		// Ordered by its output name, which SELECT DISTINCT accepts without repeating the expression.
+		rank, err := this.textSearchRank(sb, schema, planner, opts.TextQuery)
 		if err != nil {
 			return "", nil, err
 		}
+		extraRefs = append(extraRefs, fmt.Sprintf("%s AS %s", rank, pgQuote(SearchRankColumn)))
+		orderExprs = append([]string{pgQuote(SearchRankColumn) + " DESC"}, orderExprs...)
 	}
-	if err := this.applySelectColumns(sb, planner, opts.Columns, opts.ComputedContext,
-		orderRefsForDistinct(isDistinct, orderExprs)...); err != nil {
+	if err := this.applySelectColumns(sb, planner, opts.Columns, opts.ComputedContext, extraRefs...); err != nil {
 		return "", nil, err
 	}
 	this.applyFromWithJoins(sb, schema, planner)
@@ -437,13 +558,30 @@ func (this *PgQueryBuilder) buildSqlSelectGraph(
 		if len(predicate) > 0 {
 			sb.Where(predicate)
 		}
-		if len(orderExprs) > 0 {
-			sb.OrderBy(orderExprs...)
+	}
+	if err := this.applyTextSearchWhere(sb, schema, planner, opts.TextQuery); err != nil {
+		return "", nil, err
+	}
+	if opts.Keyset != nil {
+		seek, err := keysetSeekPredicate(sb, orderKeys, opts.Keyset.After)
+		if err != nil {
+			return "", nil, err
 		}
+		if len(seek) > 0 {
+			sb.Where(seek)
+		}
+	}
+	if len(orderExprs) > 0 {
+		sb.OrderBy(orderExprs...)
+	}
+	if opts.Keyset != nil {
+		// The seek has already skipped the earlier pages.
+		this.applyPagination(sb, 0, opts.Size)
+	} else {
+		this.applyPagination(sb, opts.Page, opts.Size)
 	}
-	this.applyPagination(sb, opts.Page, opts.Size)
 	sql, args := sb.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	if ierr != nil {
 		return "", nil, errors.Wrap(ierr, "buildSqlSelectGraph: interpolate")
 	}
@@ -465,7 +603,7 @@ func (this *PgQueryBuilder) buildSqlExistsGraph(
 		return "", nil, err
 	}
 	ctx := &graphSelectCtx{planner: planner}
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb := this.dialectOrDefault().flavor().NewSelectBuilder()
 	sb.Select("1")
 	this.applyFromWithJoins(sb, schema, planner)
 	this.appendPlannerM2MTenantWheres(sb, planner)
@@ -483,7 +621,7 @@ func (this *PgQueryBuilder) buildSqlExistsGraph(
 		}
 	}
 	innerSql, args := sb.Build()
-	innerOut, ierr := interpolate(innerSql, args)
+	innerOut, ierr := this.interpolate(innerSql, args)
 	if ierr != nil {
 		return "", nil, errors.Wrap(ierr, "buildSqlExistsGraph: interpolate inner")
 	}
@@ -511,7 +649,7 @@ func (this *PgQueryBuilder) buildSqlCountGraph(
 		return this.buildSqlCountGraphAsDistinctSubquery(schema, graph, opts, planner)
 	}
 	ctx := &graphSelectCtx{planner: planner, language: opts.Language}
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb := this.dialectOrDefault().flavor().NewSelectBuilder()
 	sb.Select("COUNT(*)")
 	this.applyFromWithJoins(sb, schema, planner)
 	this.appendPlannerM2MTenantWheres(sb, planner)
@@ -528,8 +666,11 @@ func (this *PgQueryBuilder) buildSqlCountGraph(
 			sb.Where(predicate)
 		}
 	}
+	if err := this.applyTextSearchWhere(sb, schema, planner, opts.TextQuery); err != nil {
+		return "", nil, err
+	}
 	sql, args := sb.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	if ierr != nil {
 		return "", nil, errors.Wrap(ierr, "buildSqlCountGraph: interpolate")
 	}
@@ -544,7 +685,7 @@ func (this *PgQueryBuilder) buildSqlCountGraphAsDistinctSubquery(
 	schema *dmodel.ModelSchema, graph *dmodel.SearchGraph, opts SqlSelectGraphOpts, planner *joinPlanner,
 ) (string, ft.ClientErrors, error) {
 	ctx := &graphSelectCtx{planner: planner, language: opts.Language}
-	inner := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	inner := this.dialectOrDefault().flavor().NewSelectBuilder()
 	inner.Distinct()
 	if err := this.applySelectColumns(inner, planner, opts.Columns, opts.ComputedContext); err != nil {
 		return "", nil, err
@@ -564,8 +705,11 @@ func (this *PgQueryBuilder) buildSqlCountGraphAsDistinctSubquery(
 			inner.Where(predicate)
 		}
 	}
+	if err := this.applyTextSearchWhere(inner, schema, planner, opts.TextQuery); err != nil {
+		return "", nil, err
+	}
 	raw, args := inner.Build()
-	innerSQL, ierr := interpolate(raw, args)
+	innerSQL, ierr := this.interpolate(raw, args)
 	if ierr != nil {
 		return "", nil, errors.Wrap(ierr, "buildSqlCountGraphAsDistinctSubquery")
 	}
@@ -657,7 +801,7 @@ func (this *PgQueryBuilder) buildInsertSql(schema *dmodel.ModelSchema, rows []ro
 	if len(rows) == 0 {
 		return "", errors.New("buildInsertSql: no rows provided")
 	}
-	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+	ib := this.dialectOrDefault().flavor().NewInsertBuilder()
 	ib.InsertInto(this.tableExpression(schema))
 	ib.Cols(pgQuoteArr(rows[0].columns)...)
 	for _, row := range rows {
@@ -670,7 +814,7 @@ func (this *PgQueryBuilder) buildInsertSql(schema *dmodel.ModelSchema, rows []ro
 		// }
 	}
 	sql, args := ib.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	if ierr != nil {
 		return "", errors.Wrap(ierr, "buildInsertSql: interpolate")
 	}
@@ -713,7 +857,7 @@ func (this *PgQueryBuilder) SqlUpdateEqual(
 func (this *PgQueryBuilder) buildUpdateSql(
 	schema *dmodel.ModelSchema, target rowData, lookup rowData,
 ) (string, error) {
-	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+	ub := this.dialectOrDefault().flavor().NewUpdateBuilder()
 	ub.Update(this.tableExpression(schema))
 	assignments := make([]string, len(target.columns))
 	for i, col := range target.columns {
@@ -728,7 +872,7 @@ func (this *PgQueryBuilder) buildUpdateSql(
 		}
 	}
 	sql, args := ub.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	if ierr != nil {
 		return "", errors.Wrap(ierr, "buildUpdateSql: interpolate")
 	}
@@ -753,13 +897,13 @@ func (this *PgQueryBuilder) SqlDeleteEqual(schema *dmodel.ModelSchema, filters d
 		return nil, nil, errors.New("SqlDeleteEqual: no filters provided")
 	}
 
-	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+	db := this.dialectOrDefault().flavor().NewDeleteBuilder()
 	db.DeleteFrom(this.tableExpression(schema))
 	for i, col := range row.columns {
 		db.Where(db.Equal(pgQuote(col), row.values[i]))
 	}
 	sql, args := db.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	var interpErr error
 	if ierr != nil {
 		interpErr = errors.Wrap(ierr, "SqlDeleteEqual: interpolate")
@@ -773,7 +917,7 @@ func (this *PgQueryBuilder) SqlDeleteOrAndEquals(
 	if len(filters) == 0 {
 		return nil, nil, errors.New("SqlDeleteOrAndEquals: no filters provided")
 	}
-	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+	db := this.dialectOrDefault().flavor().NewDeleteBuilder()
 	db.DeleteFrom(this.tableExpression(schema))
 	orClauses := make([]string, 0, len(filters))
 	for _, f := range filters {
@@ -800,7 +944,7 @@ func (this *PgQueryBuilder) SqlDeleteOrAndEquals(
 	}
 	db.Where(db.Or(orClauses...))
 	sql, args := db.Build()
-	out, ierr := interpolate(sql, args)
+	out, ierr := this.interpolate(sql, args)
 	return stringSqlOutcome(out, ierr)
 }
 
@@ -863,7 +1007,7 @@ func (this *PgQueryBuilder) SqlExistsMany(
 	parts := make([]string, 0, len(prepared))
 	argIdx := 1
 	for _, row := range prepared {
-		part := buildExistsCaseSql(tableRef, row.columns, argIdx)
+		part := this.buildExistsCaseSql(tableRef, row.columns, argIdx, "")
 		parts = append(parts, part)
 		args = append(args, row.values...)
 		argIdx += len(row.values)
@@ -895,10 +1039,10 @@ func (this *PgQueryBuilder) buildUniqueCheckPart(
 
 	ignoreCurrentRowCond := this.buildIgnoreCurrentRowCond(schema, data)
 	if ignoreCurrentRowCond != "" {
-		return buildExistsCaseSqlWithOptCond(tableRef, columns, argIdx, ignoreCurrentRowCond), values, nil, nil
+		return this.buildExistsCaseSql(tableRef, columns, argIdx, ignoreCurrentRowCond), values, nil, nil
 	}
 
-	return buildExistsCaseSql(tableRef, columns, argIdx), values, nil, nil
+	return this.buildExistsCaseSql(tableRef, columns, argIdx, ""), values, nil, nil
 }
 
 func (this *PgQueryBuilder) buildIgnoreCurrentRowCond(schema *dmodel.ModelSchema, data dmodel.DynamicFields) string {
@@ -1172,21 +1316,26 @@ func (this *PgQueryBuilder) comparisonPredicate(
 	if len(cErrs) > 0 {
 		return "", cErrs, nil
 	}
+	return comparisonExpr(sb, quotedField, op, converted), nil, nil
+}
+
+// comparisonExpr binds an already converted value to one of the six comparison operators.
+func comparisonExpr(sb *sqlbuilder.SelectBuilder, expr string, op dmodel.Operator, converted any) string {
 	switch op {
 	case dmodel.Equals:
-		return sb.Equal(quotedField, converted), nil, nil
+		return sb.Equal(expr, converted)
 	case dmodel.NotEquals:
-		return sb.NotEqual(quotedField, converted), nil, nil
+		return sb.NotEqual(expr, converted)
 	case dmodel.GreaterThan:
-		return sb.GreaterThan(quotedField, converted), nil, nil
+		return sb.GreaterThan(expr, converted)
 	case dmodel.GreaterEqual:
-		return sb.GreaterEqualThan(quotedField, converted), nil, nil
+		return sb.GreaterEqualThan(expr, converted)
 	case dmodel.LessThan:
-		return sb.LessThan(quotedField, converted), nil, nil
+		return sb.LessThan(expr, converted)
 	case dmodel.LessEqual:
-		return sb.LessEqualThan(quotedField, converted), nil, nil
+		return sb.LessEqualThan(expr, converted)
 	default:
-		panic("comparisonPredicate: unsupported operator (internal)")
+		panic("comparisonExpr: unsupported operator (internal)")
 	}
 }
 
@@ -1216,7 +1365,7 @@ func (this *PgQueryBuilder) stringPredicate(
 ) (string, ft.ClientErrors, error) {
 	expr := quotedField
 	if isLangJsonField(field) {
-		expr = langJsonStringPredicateExpr(quotedField, language)
+		expr = this.dialectOrDefault().langJsonText(quotedField, language)
 	} else if columnCategoryFor(field.ColumnType()) != columnString {
 		return "", nil, errors.Errorf(
 			"stringPredicate: operator '%s' requires string field '%s'", op, field.Name())
@@ -1234,9 +1383,9 @@ func (this *PgQueryBuilder) stringPredicate(
 	}
 	switch op {
 	case dmodel.NotContains, dmodel.NotStartsWith, dmodel.NotEndsWith:
-		return sb.NotILike(expr, pattern), nil, nil
+		return this.dialectOrDefault().likeExpr(sb, expr, pattern, true), nil, nil
 	default:
-		return sb.ILike(expr, pattern), nil, nil
+		return this.dialectOrDefault().likeExpr(sb, expr, pattern, false), nil, nil
 	}
 }
 
@@ -1244,11 +1393,9 @@ func isLangJsonField(field *dmodel.ModelField) bool {
 	return field != nil && field.ColumnType() == "nikkiLangJson"
 }
 
-func langJsonStringPredicateExpr(sqlRef string, language *cmodel.LanguageCode) string {
-	if language == nil || strings.TrimSpace(string(*language)) == "" {
-		return fmt.Sprintf("(%s)::text", sqlRef)
-	}
-	return fmt.Sprintf("(%s ->> %s)", sqlRef, pgStringLiteral(string(*language)))
+// isAnyLanguage reports whether a LangJson filter is to match any of the translations.
+func isAnyLanguage(language *cmodel.LanguageCode) bool {
+	return language == nil || strings.TrimSpace(string(*language)) == ""
 }
 
 func (this *PgQueryBuilder) convertStringPredicateValue(field *dmodel.ModelField, value any) (
@@ -1293,10 +1440,10 @@ func (this *PgQueryBuilder) convertValues(field *dmodel.ModelField, values []any
 	return converted, nil, nil
 }
 
-func (this *PgQueryBuilder) orderExprs(
+func (this *PgQueryBuilder) orderKeys(
 	ctx *graphSelectCtx, schema *dmodel.ModelSchema, order dmodel.SearchOrder,
-) ([]string, error) {
-	exprs := make([]string, 0, len(order))
+) ([]orderKey, error) {
+	keys := make([]orderKey, 0, len(order))
 	for _, item := range order {
 		if len(item) == 0 || item[0] == "" {
 			continue
@@ -1311,13 +1458,13 @@ func (this *PgQueryBuilder) orderExprs(
 		if field == nil || field.IsVirtual() {
 			return nil, wrapClientSqlErrors(clientErrorsFieldNotSortable(fieldName))
 		}
-		dir := "ASC"
-		if item.Direction() == dmodel.Desc {
-			dir = "DESC"
-		}
-		exprs = append(exprs, fmt.Sprintf("%s %s", ref, dir))
+		keys = append(keys, orderKey{
+			ref:     ref,
+			desc:    item.Direction() == dmodel.Desc,
+			notNull: field.IsPrimaryKey() && !strings.Contains(fieldName, "."),
+		})
 	}
-	return exprs, nil
+	return keys, nil
 }
 
 func (this *PgQueryBuilder) resolveOrderField(
@@ -1390,7 +1537,7 @@ func (this *PgQueryBuilder) convertValue(field *dmodel.ModelField, value any) (a
 		return nil, clientErrorsVirtualFieldUnavailable(field.Name()), nil
 	}
 	if field.IsArray() {
-		return convertArrayFieldValue(field, value)
+		return this.convertArrayFieldValue(field, value)
 	}
 	if value == nil {
 		if field.IsNullable() {
@@ -1434,7 +1581,7 @@ func (this *PgQueryBuilder) convertValue(field *dmodel.ModelField, value any) (a
 			)
 		}
 
-		return t, nil, nil
+		return this.dialectOrDefault().timeValue(field, t), nil, nil
 	}
 
 	return v.Interface(), nil, nil
@@ -1450,7 +1597,7 @@ func fieldTypeLabel(field *dmodel.ModelField) string {
 	return field.ColumnType()
 }
 
-func convertArrayFieldValue(field *dmodel.ModelField, value any) (any, ft.ClientErrors, error) {
+func (this *PgQueryBuilder) convertArrayFieldValue(field *dmodel.ModelField, value any) (any, ft.ClientErrors, error) {
 	if value == nil {
 		if field.IsNullable() {
 			return nil, nil, nil
@@ -1468,7 +1615,7 @@ func convertArrayFieldValue(field *dmodel.ModelField, value any) (any, ft.Client
 		return nil, ft.ClientErrors{*dmodel.NewInvalidDataTypeErr(field.Name(), "array")}, nil
 	}
 	cat := columnCategoryFor(field.ColumnType())
-	raw, err := buildPgArrayRaw(field, cat, v)
+	raw, err := this.dialectOrDefault().arrayValue(field, cat, v)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "convertArrayFieldValue: field '%s'", field.Name())
 	}
@@ -1722,21 +1869,10 @@ func prependTenantKey(tenantKey string, fields []string) []string {
 	return append(cols, fields...)
 }
 
-func buildExistsCaseSql(tableRef string, columns []string, argIdx int) string {
-	conds := make([]string, len(columns))
-	for i, col := range columns {
-		conds[i] = fmt.Sprintf("%s = $%d", pgQuote(col), argIdx+i)
-	}
-	whereClause := strings.Join(conds, " AND ")
-	return fmt.Sprintf(
-		"SELECT CASE WHEN EXISTS (SELECT 1 FROM %s WHERE %s) THEN 1 ELSE 0 END",
-		tableRef, whereClause)
-}
-
-func buildExistsCaseSqlWithOptCond(tableRef string, columns []string, argIdx int, optCond string) string {
+func (this *PgQueryBuilder) buildExistsCaseSql(tableRef string, columns []string, argIdx int, optCond string) string {
 	conds := make([]string, len(columns))
 	for i, col := range columns {
-		conds[i] = fmt.Sprintf("%s = $%d", pgQuote(col), argIdx+i)
+		conds[i] = fmt.Sprintf("%s = %s", pgQuote(col), this.dialectOrDefault().placeholder(argIdx+i))
 	}
 
 	whereClause := strings.Join(conds, " AND ")
@@ -1749,11 +1885,11 @@ func buildExistsCaseSqlWithOptCond(tableRef string, columns []string, argIdx int
 		tableRef, whereClause)
 }
 
-func interpolate(sql string, args []interface{}) (string, error) {
+func (this *PgQueryBuilder) interpolate(sql string, args []interface{}) (string, error) {
 	if len(args) == 0 {
 		return sql, nil
 	}
-	return sqlbuilder.PostgreSQL.Interpolate(sql, args)
+	return this.dialectOrDefault().flavor().Interpolate(sql, args)
 }
 
 func stringPattern(value string, op dmodel.Operator) string {
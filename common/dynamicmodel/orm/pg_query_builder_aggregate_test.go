@@ -0,0 +1,231 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/computed"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+)
+
+const aggregateTestSchemaName = "test_aggregate_order"
+
+// aggregateSchema builds an order-like table with a column of each kind the aggregate action
+// treats differently.
+func aggregateSchema(t *testing.T) (*dmodel.ModelSchema, *dmodel.SchemaRegistry) {
+	t.Helper()
+	registry := dmodel.GetSchemaRegistry()
+	if existing := registry.Get(aggregateTestSchemaName); existing != nil {
+		return existing, registry
+	}
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(aggregateTestSchemaName).
+			TableName("test_aggregate_orders").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+			Field(dmodel.DefineField().Name("status").
+				DataType(dmodel.FieldDataTypeString(0, 20))).
+			Field(dmodel.DefineField().Name("amount").
+				DataType(dmodel.FieldDataTypeDecimal("0", "1000000", 2))).
+			Field(dmodel.DefineField().Name("is_paid").
+				DataType(dmodel.FieldDataTypeBoolean())).
+			Field(dmodel.DefineField().Name("placed_on").
+				DataType(dmodel.FieldDataTypeDate())).
+			Field(dmodel.DefineField().Name("placed_at").
+				DataType(dmodel.FieldDataTypeDateTime()))))
+	return registry.Get(aggregateTestSchemaName), registry
+}
+
+func aggregateSql(t *testing.T, graph *dmodel.SearchGraph, opts SqlAggregateGraphOpts) string {
+	t.Helper()
+	schema, registry := aggregateSchema(t)
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlAggregateGraph(schema, registry, graph, opts)
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	require.NotNil(t, sql)
+	return *sql
+}
+
+func aggregateClientErrors(t *testing.T, opts SqlAggregateGraphOpts) ft.ClientErrors {
+	t.Helper()
+	schema, registry := aggregateSchema(t)
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlAggregateGraph(schema, registry, nil, opts)
+	require.NoError(t, err)
+	require.Nil(t, sql)
+	require.NotNil(t, cErrs)
+	return *cErrs
+}
+
+func TestAggregate_GroupsFiltersAndOrdersByTheKeys(t *testing.T) {
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("is_paid", dmodel.Equals, true)
+	graph.OrderBy("amount", dmodel.Desc)
+
+	sql := aggregateSql(t, graph, SqlAggregateGraphOpts{
+		GroupBy: []AggregateGroupBy{{Field: "status"}},
+		Measures: []AggregateMeasure{
+			{Name: "orders", Function: computed.AggCount},
+			{Name: "total", Function: computed.AggSum, Field: "amount"},
+			{Name: "order_days", Function: computed.AggCountDistinct, Field: "placed_on"},
+		},
+		Limit: 100,
+	})
+
+	assert.Equal(t,
+		`SELECT "status" AS "status", COUNT(*) AS "orders", SUM("amount") AS "total", `+
+			`COUNT(DISTINCT "placed_on") AS "order_days" FROM "test_aggregate_orders" WHERE "is_paid" = TRUE `+
+			`GROUP BY "status" ORDER BY "status" ASC LIMIT 100`,
+		sql)
+}
+
+func TestAggregate_WithoutGroupsIsOneRow(t *testing.T) {
+	sql := aggregateSql(t, nil, SqlAggregateGraphOpts{
+		Measures: []AggregateMeasure{{Name: "average", Function: computed.AggAvg, Field: "amount"}},
+	})
+
+	assert.Equal(t, `SELECT AVG("amount") AS "average" FROM "test_aggregate_orders"`, sql)
+}
+
+func TestAggregate_DateTruncation(t *testing.T) {
+	sql := aggregateSql(t, nil, SqlAggregateGraphOpts{
+		GroupBy: []AggregateGroupBy{
+			{Field: "placed_on", Trunc: DateTruncWeek},
+			{Field: "placed_at", Trunc: DateTruncMonth},
+		},
+		Measures: []AggregateMeasure{{Name: "orders", Function: computed.AggCount}},
+	})
+
+	assert.Contains(t, sql, `date_trunc('week', "placed_on")::date AS "placed_on:week"`)
+	assert.Contains(t, sql, `date_trunc('month', "placed_at") AS "placed_at:month"`)
+	assert.Contains(t, sql, `GROUP BY date_trunc('week', "placed_on")::date, date_trunc('month', "placed_at")`)
+}
+
+func TestAggregate_Having(t *testing.T) {
+	sql := aggregateSql(t, nil, SqlAggregateGraphOpts{
+		GroupBy: []AggregateGroupBy{{Field: "status"}},
+		Measures: []AggregateMeasure{
+			{Name: "total", Function: computed.AggSum, Field: "amount"},
+			{Name: "last_order", Function: computed.AggMax, Field: "placed_on"},
+		},
+		Having: []AggregateHaving{
+			{Measure: "total", Operator: dmodel.GreaterEqual, Value: 1500.5},
+			{Measure: "last_order", Operator: dmodel.LessThan, Value: "2026-01-01"},
+		},
+	})
+
+	assert.Contains(t, sql, `HAVING SUM("amount") >= 1500.5 AND MAX("placed_on") < '2026-01-01`)
+}
+
+func TestAggregate_RejectsWhatCannotBeAggregated(t *testing.T) {
+	cases := map[string]struct {
+		opts SqlAggregateGraphOpts
+		key  string
+	}{
+		"no measure": {
+			opts: SqlAggregateGraphOpts{GroupBy: []AggregateGroupBy{{Field: "status"}}},
+			key:  "common:err_aggregate_no_measure",
+		},
+		"sum of text": {
+			opts: SqlAggregateGraphOpts{Measures: []AggregateMeasure{
+				{Name: "total", Function: computed.AggSum, Field: "status"},
+			}},
+			key: "common:err_aggregate_field_type",
+		},
+		"max of a boolean": {
+			opts: SqlAggregateGraphOpts{Measures: []AggregateMeasure{
+				{Name: "paid", Function: computed.AggMax, Field: "is_paid"},
+			}},
+			key: "common:err_aggregate_field_type",
+		},
+		"truncated text": {
+			opts: SqlAggregateGraphOpts{
+				GroupBy:  []AggregateGroupBy{{Field: "status", Trunc: DateTruncDay}},
+				Measures: []AggregateMeasure{{Name: "orders", Function: computed.AggCount}},
+			},
+			key: "common:err_invalid_date_trunc",
+		},
+		"measure named like a group": {
+			opts: SqlAggregateGraphOpts{
+				GroupBy:  []AggregateGroupBy{{Field: "status"}},
+				Measures: []AggregateMeasure{{Name: "status", Function: computed.AggCount}},
+			},
+			key: "common:err_duplicate_aggregate_key",
+		},
+		"unknown function": {
+			opts: SqlAggregateGraphOpts{Measures: []AggregateMeasure{
+				{Name: "middle", Function: computed.AggregateFunction("median"), Field: "amount"},
+			}},
+			key: "common:err_invalid_aggregate_function",
+		},
+		"having on no measure": {
+			opts: SqlAggregateGraphOpts{
+				Measures: []AggregateMeasure{{Name: "orders", Function: computed.AggCount}},
+				Having:   []AggregateHaving{{Measure: "total", Operator: dmodel.GreaterThan, Value: 1}},
+			},
+			key: "common:err_unknown_measure",
+		},
+		"having with a string operator": {
+			opts: SqlAggregateGraphOpts{
+				Measures: []AggregateMeasure{{Name: "orders", Function: computed.AggCount}},
+				Having:   []AggregateHaving{{Measure: "orders", Operator: dmodel.Contains, Value: 1}},
+			},
+			key: "common:err_invalid_having_operator",
+		},
+		"unknown field": {
+			opts: SqlAggregateGraphOpts{Measures: []AggregateMeasure{
+				{Name: "total", Function: computed.AggSum, Field: "nope"},
+			}},
+			key: "common:err_unknown_schema_field",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cErrs := aggregateClientErrors(t, tc.opts)
+			require.NotEmpty(t, cErrs)
+			assert.Equal(t, tc.key, cErrs[0].Key)
+		})
+	}
+}
+
+// Joining for a filter on a one:many edge would repeat each parent once per matching child and
+// count it as many times, so the filter selects the parents in a subquery instead.
+func TestAggregate_FanOutFilterBecomesASemiJoin(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("children.label", dmodel.Equals, "x")
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlAggregateGraph(schema, registry, graph, SqlAggregateGraphOpts{
+		GroupBy:  []AggregateGroupBy{{Field: "peer.title"}},
+		Measures: []AggregateMeasure{{Name: "parents", Function: computed.AggCount}},
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Regexp(t, `^SELECT \w+\."title" AS "peer\.title", COUNT\(\*\) AS "parents" FROM "test_distinct_parents" AS t0 `+
+		`LEFT JOIN "test_distinct_peers" AS \w+ ON .* WHERE \(t0\."id"\) IN \(SELECT DISTINCT `, *sql)
+	assert.NotRegexp(t, `LEFT JOIN "test_distinct_children" AS \w+ ON .* WHERE \(t0`, *sql,
+		"the children are joined inside the subquery only")
+}
+
+func TestAggregate_ManyToOneFilterStaysInline(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("peer.title", dmodel.Equals, "x")
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlAggregateGraph(schema, registry, graph, SqlAggregateGraphOpts{
+		GroupBy:  []AggregateGroupBy{{Field: "code"}},
+		Measures: []AggregateMeasure{{Name: "parents", Function: computed.AggCount}},
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.NotContains(t, *sql, " IN (")
+	assert.Regexp(t, `WHERE \w+\."title" = E'x' GROUP BY t0\."code"`, *sql)
+}
@@ -116,7 +116,7 @@ func (this *computedSubqueryEmit) render() (string, ft.ClientErrors, error) {
 }
 
 func (this *computedSubqueryEmit) renderAggregate(node *computed.AggregateExpr) (string, ft.ClientErrors, error) {
-	selectExpr, err := aggregateSelectExpr(node)
+	selectExpr, err := aggregateSelectExpr(this.builder.dialectOrDefault(), node)
 	if err != nil {
 		return "", nil, err
 	}
@@ -148,7 +148,7 @@ func (this *computedSubqueryEmit) renderLookup(node *computed.LookupExpr) (strin
 func (this *computedSubqueryEmit) subquerySql(
 	selectExpr string, filter *dmodel.SearchNode, orderBy []computed.OrderBy,
 ) (string, ft.ClientErrors, error) {
-	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	sb := this.builder.dialectOrDefault().flavor().NewSelectBuilder()
 	sb.Select(selectExpr)
 	sb.From(this.builder.tableExpression(this.sourceSchema))
 
@@ -163,7 +163,7 @@ func (this *computedSubqueryEmit) subquerySql(
 	}
 
 	sql, args := sb.Build()
-	out, err := interpolate(sql, args)
+	out, err := this.builder.interpolate(sql, args)
 	if err != nil {
 		return "", nil, errors.Wrap(err, "computed subquery: interpolate")
 	}
@@ -330,28 +330,34 @@ func substituteConditionValues(
 
 // aggregateSelectExpr renders the aggregate function call: COUNT(*), COUNT(DISTINCT col), or
 // FN(operand) where the operand is a column or the SQL-compiled inner expression.
-func aggregateSelectExpr(node *computed.AggregateExpr) (string, error) {
-	switch node.Function {
-	case computed.AggCount:
-		return "COUNT(*)", nil
-	case computed.AggCountDistinct:
-		return "COUNT(DISTINCT " + pgQuote(node.Field) + ")", nil
-	}
+func aggregateSelectExpr(dialect sqlDialect, node *computed.AggregateExpr) (string, error) {
 	operand := pgQuote(node.Field)
-	if node.Expr != nil {
-		compiled, err := computedInnerSqlExpr(node.Expr)
+	if node.Expr != nil && node.Function != computed.AggCount && node.Function != computed.AggCountDistinct {
+		compiled, err := computedInnerSqlExpr(dialect, node.Expr)
 		if err != nil {
 			return "", err
 		}
 		operand = compiled
 	}
-	return strings.ToUpper(string(node.Function)) + "(" + operand + ")", nil
+	return aggregateCall(node.Function, operand), nil
+}
+
+// aggregateCall applies an aggregate function to an operand that is already SQL. COUNT ignores the
+// operand and counts rows.
+func aggregateCall(function computed.AggregateFunction, operand string) string {
+	switch function {
+	case computed.AggCount:
+		return "COUNT(*)"
+	case computed.AggCountDistinct:
+		return "COUNT(DISTINCT " + operand + ")"
+	}
+	return strings.ToUpper(string(function)) + "(" + operand + ")"
 }
 
 // computedInnerSqlExpr compiles the restricted inner-expression subset to SQL. Only the shapes
 // finalize-time validation admits can appear (field refs, scalar literals, arithmetic, negate,
 // coalesce/nullif); anything else is an internal error, not a client one.
-func computedInnerSqlExpr(expr computed.Expr) (string, error) {
+func computedInnerSqlExpr(dialect sqlDialect, expr computed.Expr) (string, error) {
 	switch node := expr.(type) {
 	case computed.FieldExpr:
 		return pgQuote(node.Name), nil
@@ -361,28 +367,28 @@ func computedInnerSqlExpr(expr computed.Expr) (string, error) {
 		}
 		return sqlLiteralForLinkedSubquery(node.Value)
 	case computed.BinaryExpr:
-		return computedInnerBinarySql(node)
+		return computedInnerBinarySql(dialect, node)
 	case computed.UnaryExpr:
 		if node.Op != computed.OpNegate {
 			return "", errors.Errorf("computed inner expression: operator %q cannot compile to SQL", node.Op)
 		}
-		operand, err := computedInnerSqlExpr(node.Operand)
+		operand, err := computedInnerSqlExpr(dialect, node.Operand)
 		if err != nil {
 			return "", err
 		}
 		return "(- " + operand + ")", nil
 	case computed.FunctionExpr:
-		return computedInnerFunctionSql(node)
+		return computedInnerFunctionSql(dialect, node)
 	}
 	return "", errors.Errorf("computed inner expression: %T cannot compile to SQL", expr)
 }
 
-func computedInnerBinarySql(node computed.BinaryExpr) (string, error) {
-	left, err := computedInnerSqlExpr(node.Left)
+func computedInnerBinarySql(dialect sqlDialect, node computed.BinaryExpr) (string, error) {
+	left, err := computedInnerSqlExpr(dialect, node.Left)
 	if err != nil {
 		return "", err
 	}
-	right, err := computedInnerSqlExpr(node.Right)
+	right, err := computedInnerSqlExpr(dialect, node.Right)
 	if err != nil {
 		return "", err
 	}
@@ -393,7 +399,7 @@ func computedInnerBinarySql(node computed.BinaryExpr) (string, error) {
 	if node.Op == computed.OpDivide {
 		// The Go evaluator divides in decimal; casting the dividend keeps SQL from
 		// integer-dividing and silently disagreeing with it.
-		left = "(" + left + ")::numeric"
+		left = dialect.decimalDividend(left)
 	}
 	return "(" + left + " " + operator + " " + right + ")", nil
 }
@@ -414,10 +420,10 @@ func computedInnerSqlOperator(op computed.BinaryOperator) (string, error) {
 	return "", errors.Errorf("computed inner expression: operator %q cannot compile to SQL", op)
 }
 
-func computedInnerFunctionSql(node computed.FunctionExpr) (string, error) {
+func computedInnerFunctionSql(dialect sqlDialect, node computed.FunctionExpr) (string, error) {
 	args := make([]string, len(node.Args))
 	for i, arg := range node.Args {
-		compiled, err := computedInnerSqlExpr(arg)
+		compiled, err := computedInnerSqlExpr(dialect, arg)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,123 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+// fullTextSchema builds an article table searchable by its title, its Vietnamese name and its body.
+func fullTextSchema(trigram bool) *dmodel.ModelSchema {
+	return dmodel.DefineModel("test_fulltext_article").
+		TableName("test_fulltext_articles").
+		ShouldBuildDb().
+		Field(dmodel.DefineField().Name("id").
+			DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+		Field(dmodel.DefineField().Name("title").
+			DataType(dmodel.FieldDataTypeString(1, 200))).
+		Field(dmodel.DefineField().Name("name").
+			DataType(dmodel.FieldDataTypeLangJson(1, 200))).
+		Field(dmodel.DefineField().Name("body").
+			DataType(dmodel.FieldDataTypeString(0, 10000))).
+		FullTextSearch(dmodel.FullTextSearchParam{
+			Fields: []dmodel.FullTextFieldParam{
+				{Field: "title", Weight: dmodel.FullTextWeightA, Config: "english"},
+				{Field: "name", Language: "vi-VN", Weight: dmodel.FullTextWeightB},
+				{Field: "body"},
+			},
+			Trigram: trigram,
+		}).
+		Build()
+}
+
+func fullTextSelectSql(t *testing.T, schema *dmodel.ModelSchema, opts SqlSelectGraphOpts) string {
+	t.Helper()
+	sql, cErrs, err := NewPgQueryBuilder().SqlSelectGraph(schema, dmodel.GetSchemaRegistry(), nil, opts)
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	return *sql
+}
+
+func TestFullText_CreateTableGeneratesTheSearchColumns(t *testing.T) {
+	sqls, _, err := NewPgQueryBuilder().SqlCreateTable(fullTextSchema(true), dmodel.GetSchemaRegistry())
+	require.NoError(t, err)
+
+	assert.Contains(t, sqls[0], `"_search_vector" tsvector GENERATED ALWAYS AS (`+
+		`setweight(to_tsvector('english'::regconfig, coalesce("title", '')), 'A') || `+
+		`setweight(to_tsvector('simple'::regconfig, coalesce(("name" ->> 'vi-VN'), '')), 'B') || `+
+		`setweight(to_tsvector('simple'::regconfig, coalesce("body", '')), 'D')) STORED`)
+	assert.Contains(t, sqls[0], `"_search_text" text GENERATED ALWAYS AS (`+
+		`coalesce("title", '') || ' ' || coalesce(("name" ->> 'vi-VN'), '') || ' ' || coalesce("body", '')) STORED`)
+	assert.Equal(t, []string{
+		`CREATE INDEX "test_fulltext_articles_search_vector_idx" ON "test_fulltext_articles" USING GIN ("_search_vector")`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX "test_fulltext_articles_search_text_trgm_idx" ON "test_fulltext_articles" ` +
+			`USING GIN ("_search_text" gin_trgm_ops)`,
+	}, sqls[1:])
+}
+
+func TestFullText_TrigramIsOptIn(t *testing.T) {
+	sqls, _, err := NewPgQueryBuilder().SqlCreateTable(fullTextSchema(false), dmodel.GetSchemaRegistry())
+	require.NoError(t, err)
+
+	assert.NotContains(t, sqls[0], "_search_text")
+	assert.Len(t, sqls, 2, "CREATE TABLE and the GIN index only")
+}
+
+func TestFullText_RanksMatchesAheadOfTheGraphOrder(t *testing.T) {
+	sql := fullTextSelectSql(t, fullTextSchema(false), SqlSelectGraphOpts{
+		Columns:   ToSelectColumns([]string{"id", "title"}),
+		Size:      20,
+		TextQuery: "it's late",
+	})
+
+	tsquery := `websearch_to_tsquery('english'::regconfig, E'it\'s late') || ` +
+		`websearch_to_tsquery('simple'::regconfig, E'it\'s late')`
+	assert.Equal(t,
+		`SELECT "id", "title", ts_rank("_search_vector", `+tsquery+`) AS "_search_rank" `+
+			`FROM "test_fulltext_articles" WHERE "_search_vector" @@ (`+tsquery+`) `+
+			`ORDER BY "_search_rank" DESC, "id" ASC LIMIT 20`,
+		sql)
+}
+
+func TestFullText_TrigramsTolerateTypos(t *testing.T) {
+	sql := fullTextSelectSql(t, fullTextSchema(true), SqlSelectGraphOpts{
+		Columns:   ToSelectColumns([]string{"id"}),
+		TextQuery: "recieve",
+	})
+
+	assert.Contains(t, sql, `+ word_similarity(E'recieve', "_search_text")) AS "_search_rank"`)
+	assert.Contains(t, sql, `OR E'recieve' <% "_search_text")`)
+}
+
+// The generated columns are no fields: a wildcard would hand a tsvector to the caller.
+func TestFullText_WildcardListsTheFields(t *testing.T) {
+	sql := fullTextSelectSql(t, fullTextSchema(false), SqlSelectGraphOpts{})
+
+	assert.Equal(t, `SELECT "id", "title", "name", "body" FROM "test_fulltext_articles"`, sql)
+}
+
+func TestFullText_CountMatchesTheSameRows(t *testing.T) {
+	sql, cErrs, err := NewPgQueryBuilder().SqlCountGraph(
+		fullTextSchema(false), dmodel.GetSchemaRegistry(), nil, SqlSelectGraphOpts{TextQuery: "late"})
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+
+	assert.Contains(t, *sql, `SELECT COUNT(*) FROM "test_fulltext_articles" WHERE "_search_vector" @@ (`)
+	assert.NotContains(t, *sql, "ts_rank")
+}
+
+func TestFullText_Rejections(t *testing.T) {
+	builder := NewPgQueryBuilder()
+
+	_, _, err := builder.SqlSelectGraph(fullTextSchema(false), dmodel.GetSchemaRegistry(), nil,
+		SqlSelectGraphOpts{TextQuery: "late", Size: 10, Keyset: &KeysetOpts{}})
+	assert.ErrorContains(t, err, "cannot be paged by keyset")
+
+	unsearchable, registry := aggregateSchema(t)
+	_, _, err = builder.SqlSelectGraph(unsearchable, registry, nil, SqlSelectGraphOpts{TextQuery: "late"})
+	assert.ErrorContains(t, err, "declares no full-text search")
+}
@@ -79,6 +79,18 @@ func (p *joinPlanner) ensureRootAliased() {
 	}
 }
 
+// rootColumnRef refers to a root column that is no field, such as a generated search column. It
+// is qualified whenever the root is aliased, which takes no field lookup to decide.
+func (p *joinPlanner) rootColumnRef(col string) string {
+	if p.usesJoins() {
+		p.ensureRootAliased()
+	}
+	if p.rootAlias != "" {
+		return fmt.Sprintf("%s.%s", p.rootAlias, pgQuote(col))
+	}
+	return pgQuote(col)
+}
+
 func (p *joinPlanner) allocJoinedAlias() string {
 	alias := fmt.Sprintf("t%d", p.nextTableIdx)
 	p.nextTableIdx++
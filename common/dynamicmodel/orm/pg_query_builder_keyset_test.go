@@ -0,0 +1,137 @@
+package orm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+// Keyset paging seeks past the previous page's last row instead of counting rows off with OFFSET.
+// These tests reuse the schemas of the DISTINCT tests: a parent with a many:one peer and one:many
+// children.
+
+func keysetSelectSql(t *testing.T, graph *dmodel.SearchGraph, after ...*string) string {
+	t.Helper()
+	schema, registry := distinctSchemas(t)
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlSelectGraph(schema, registry, graph, SqlSelectGraphOpts{
+		Columns: ToSelectColumns([]string{"id", "code"}),
+		Page:    3,
+		Size:    20,
+		Keyset:  &KeysetOpts{After: after},
+	})
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	require.NotNil(t, sql)
+	return *sql
+}
+
+func keysetValue(value string) *string {
+	return &value
+}
+
+func TestKeyset_OrderIsTieBrokenByPrimaryKey(t *testing.T) {
+	schema, _ := distinctSchemas(t)
+
+	order := KeysetOrder(schema, dmodel.NewSearchOrder("code", dmodel.Desc))
+
+	assert.Equal(t, dmodel.SearchOrder{{"code", "desc"}, {"id", "asc"}}, order)
+}
+
+func TestKeyset_PrimaryKeyNamedByTheCallerIsNotRepeated(t *testing.T) {
+	schema, _ := distinctSchemas(t)
+
+	order := KeysetOrder(schema, dmodel.NewSearchOrder("id", dmodel.Desc))
+
+	assert.Equal(t, dmodel.SearchOrder{{"id", "desc"}}, order)
+}
+
+func TestKeyset_FirstPageIsSortedAndLimitedWithoutOffset(t *testing.T) {
+	sql := keysetSelectSql(t, nil)
+
+	assert.Contains(t, sql, `"id"::text AS "_keyset_0"`)
+	assert.Contains(t, sql, `ORDER BY "id" ASC`)
+	assert.Contains(t, sql, "LIMIT 20")
+	assert.NotContains(t, sql, "OFFSET")
+	assert.NotContains(t, sql, "WHERE")
+}
+
+func TestKeyset_AscendingSeekLetsNullsFollow(t *testing.T) {
+	graph := dmodel.NewSearchGraph()
+	graph.OrderBy("code", dmodel.Asc)
+
+	sql := keysetSelectSql(t, graph, keysetValue("B-7"), keysetValue("01J0ID"))
+
+	assert.Contains(t, sql, `("code" > E'B-7' OR "code" IS NULL)`)
+	assert.Contains(t, sql, `("code" = E'B-7' AND "id" > E'01J0ID')`)
+	assert.Contains(t, sql, `ORDER BY "code" ASC, "id" ASC`)
+}
+
+func TestKeyset_DescendingSeek(t *testing.T) {
+	graph := dmodel.NewSearchGraph()
+	graph.OrderBy("code", dmodel.Desc)
+
+	sql := keysetSelectSql(t, graph, keysetValue("B-7"), keysetValue("01J0ID"))
+
+	assert.Contains(t, sql, `"code" < E'B-7'`)
+	assert.NotContains(t, sql, `"code" IS NULL`, "NULLs sort first descending, so they are behind")
+	assert.Contains(t, sql, `ORDER BY "code" DESC, "id" ASC`)
+}
+
+func TestKeyset_SeekFromANullSortValue(t *testing.T) {
+	asc := dmodel.NewSearchGraph()
+	asc.OrderBy("code", dmodel.Asc)
+	desc := dmodel.NewSearchGraph()
+	desc.OrderBy("code", dmodel.Desc)
+
+	ascSql := keysetSelectSql(t, asc, nil, keysetValue("01J0ID"))
+	descSql := keysetSelectSql(t, desc, nil, keysetValue("01J0ID"))
+
+	// Ascending, only the other NULLs are left.
+	assert.Contains(t, ascSql, `WHERE (("code" IS NULL AND "id" > E'01J0ID'))`)
+	// Descending, every non-NULL value is still ahead.
+	assert.Contains(t, descSql, `"code" IS NOT NULL`)
+	assert.Contains(t, descSql, `OR ("code" IS NULL AND "id" > E'01J0ID')`)
+}
+
+func TestKeyset_SortOnAJoinedColumn(t *testing.T) {
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("peer.title", dmodel.Equals, "x")
+	graph.OrderBy("peer.title", dmodel.Desc)
+
+	sql := keysetSelectSql(t, graph, keysetValue("Acme"), keysetValue("01J0ID"))
+
+	selectClause := sql[:strings.Index(sql, " FROM ")]
+	assert.Regexp(t, `"title"::text AS "_keyset_0"`, selectClause)
+	assert.Regexp(t, `\."id"::text AS "_keyset_1"`, selectClause)
+	assert.Regexp(t, `\."title" < E'Acme'`, sql)
+	assert.Regexp(t, `\."title" = E'Acme' AND \w+\."id" > E'01J0ID'`, sql)
+}
+
+func TestKeyset_ValuesMustMatchTheSortKeys(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+
+	_, _, err := (&PgQueryBuilder{}).SqlSelectGraph(schema, registry, nil, SqlSelectGraphOpts{
+		Size:   20,
+		Keyset: &KeysetOpts{After: []*string{keysetValue("a"), keysetValue("b")}},
+	})
+
+	assert.Error(t, err)
+}
+
+func TestKeyset_CountIgnoresTheSeek(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+
+	sql, cErrs, err := (&PgQueryBuilder{}).SqlCountGraph(schema, registry, nil, SqlSelectGraphOpts{
+		Size:   20,
+		Keyset: &KeysetOpts{After: []*string{keysetValue("01J0ID")}},
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.NotContains(t, *sql, "WHERE")
+}
@@ -0,0 +1,577 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+// LiveTable is a table as introspection found it in the database.
+type LiveTable struct {
+	Columns map[string]LiveColumn
+	// Constraints maps the name of each PRIMARY KEY, UNIQUE and FOREIGN KEY constraint to its
+	// definition as pg_get_constraintdef prints it.
+	Constraints map[string]string
+	// Indexes holds the names of the indexes that back no constraint.
+	Indexes map[string]struct{}
+}
+
+type LiveColumn struct {
+	// Type is the column type as format_type prints it, such as "character varying(100)".
+	Type      string
+	NotNull   bool
+	Generated bool
+}
+
+// MigrationStep is one statement of a schema migration.
+type MigrationStep struct {
+	Sql string
+	// Destructive marks a step that can lose data or fail on the rows the table holds: a drop,
+	// a narrowing type change, a new NOT NULL, or a constraint re-created with a new definition.
+	Destructive bool
+	// Reason says what makes a destructive step destructive.
+	Reason string
+
+	phase migrationPhase
+}
+
+// migrationPhase orders the steps so that each one finds what it depends on: tables before the
+// columns added to them, keys before the foreign keys referencing them, and foreign keys dropped
+// before the keys they reference.
+type migrationPhase int
+
+const (
+	phaseCreateTable migrationPhase = iota
+	phaseAddColumn
+	phaseAlterColumn
+	phaseAddKey
+	phaseAddForeignKey
+	phaseCreateIndex
+	phaseDropForeignKey
+	phaseDropIndex
+	phaseDropKey
+	phaseReAddKey
+	phaseReAddForeignKey
+	phaseDropColumn
+)
+
+// GenDiffSql introspects the tables of the schemas in registry and returns the steps that bring
+// the database to them, in the order to run them. schemaPrefixes scope the schemas as for
+// GenCreateSql.
+//
+// Only the tables of those schemas are read, so a table that no schema declares any more is
+// left alone, as is a schema other than the connection's current one.
+func GenDiffSql(
+	ctx context.Context, client DbClient, registry *dmodel.SchemaRegistry, dialect string, schemaPrefixes ...string,
+) ([]MigrationStep, error) {
+	if registry == nil {
+		return nil, errors.New("GenDiffSql: schema registry is required")
+	}
+	if dialect != DialectPostgres {
+		return nil, errors.Errorf("GenDiffSql: dialect '%s' is not supported", dialect)
+	}
+	var tableNames []string
+	err := registry.ForEachOrder(func(schemaName string, s *dmodel.ModelSchema) error {
+		if matchesAnyPrefix(schemaName, schemaPrefixes) {
+			tableNames = append(tableNames, s.TableName())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	live, err := IntrospectPg(ctx, client, tableNames)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSchemas(registry, live, schemaPrefixes...)
+}
+
+const pgTableOfCurrentSchema = `
+	JOIN pg_catalog.pg_class c ON c.oid = %s
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = current_schema() AND c.relname = $1`
+
+var (
+	pgTableExistsSql = `SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = current_schema() AND c.relname = $1 AND c.relkind IN ('r', 'p'))`
+
+	pgColumnsSql = `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, a.attgenerated <> ''
+	FROM pg_catalog.pg_attribute a` + fmt.Sprintf(pgTableOfCurrentSchema, "a.attrelid") + `
+	AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`
+
+	pgConstraintsSql = `SELECT con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_catalog.pg_constraint con` + fmt.Sprintf(pgTableOfCurrentSchema, "con.conrelid") + `
+	AND con.contype IN ('p', 'u', 'f')`
+
+	// A foreign key also records the referenced table's index in conindid, hence the conrelid check.
+	pgIndexesSql = `SELECT i.relname FROM pg_catalog.pg_index x
+	JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid` + fmt.Sprintf(pgTableOfCurrentSchema, "x.indrelid") + `
+	AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint con
+		WHERE con.conindid = x.indexrelid AND con.conrelid = x.indrelid)`
+)
+
+// IntrospectPg reads the given tables from the current schema of the database. A table that
+// does not exist is missing from the result.
+func IntrospectPg(ctx context.Context, client DbClient, tableNames []string) (map[string]*LiveTable, error) {
+	out := make(map[string]*LiveTable, len(tableNames))
+	for _, tableName := range tableNames {
+		var exists bool
+		if err := client.QueryRow(ctx, pgTableExistsSql, tableName).Scan(&exists); err != nil {
+			return nil, errors.Wrapf(err, "IntrospectPg: table '%s'", tableName)
+		}
+		if !exists {
+			continue
+		}
+		table, err := introspectPgTable(ctx, client, tableName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "IntrospectPg: table '%s'", tableName)
+		}
+		out[tableName] = table
+	}
+	return out, nil
+}
+
+func introspectPgTable(ctx context.Context, client DbClient, tableName string) (*LiveTable, error) {
+	table := &LiveTable{
+		Columns:     map[string]LiveColumn{},
+		Constraints: map[string]string{},
+		Indexes:     map[string]struct{}{},
+	}
+	err := scanRows(ctx, client, pgColumnsSql, tableName, func(scan func(...any) error) error {
+		var name string
+		var column LiveColumn
+		if err := scan(&name, &column.Type, &column.NotNull, &column.Generated); err != nil {
+			return err
+		}
+		table.Columns[name] = column
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(ctx, client, pgConstraintsSql, tableName, func(scan func(...any) error) error {
+		var name, def string
+		if err := scan(&name, &def); err != nil {
+			return err
+		}
+		table.Constraints[name] = def
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(ctx, client, pgIndexesSql, tableName, func(scan func(...any) error) error {
+		var name string
+		if err := scan(&name); err != nil {
+			return err
+		}
+		table.Indexes[name] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func scanRows(
+	ctx context.Context, client DbClient, query string, tableName string, fn func(scan func(...any) error) error,
+) error {
+	rows, err := client.Query(ctx, query, tableName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DiffSchemas compares the schemas in registry, scoped by schemaPrefixes, with the tables in
+// live and returns the steps that bring the tables to the schemas. A table missing from live
+// is created whole, as GenCreateSql would.
+//
+// Constraints and indexes are matched by name, which the schemas derive from their columns, so
+// a new unique group shows up as one constraint added and another dropped. A constraint whose
+// definition changed under the same name, such as a foreign key's ON DELETE, is re-created. An
+// index is not compared beyond its name, nor is the expression of a generated column: to
+// re-declare a full-text search, drop its columns and run the diff again.
+func DiffSchemas(
+	registry *dmodel.SchemaRegistry, live map[string]*LiveTable, schemaPrefixes ...string,
+) ([]MigrationStep, error) {
+	builder := NewPgQueryBuilder().(*PgQueryBuilder)
+	var steps []MigrationStep
+	err := registry.ForEachOrder(func(schemaName string, s *dmodel.ModelSchema) error {
+		if !matchesAnyPrefix(schemaName, schemaPrefixes) {
+			return nil
+		}
+		var tableSteps []MigrationStep
+		var err error
+		if table, ok := live[s.TableName()]; ok {
+			tableSteps, err = diffTable(builder, s, registry, table)
+		} else {
+			tableSteps, err = createTableSteps(builder, s, registry)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "DiffSchemas: schema '%s'", schemaName)
+		}
+		steps = append(steps, tableSteps...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].phase < steps[j].phase })
+	return steps, nil
+}
+
+func createTableSteps(
+	builder *PgQueryBuilder, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+) ([]MigrationStep, error) {
+	sqls, _, err := builder.SqlCreateTable(schema, registry)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]MigrationStep, len(sqls))
+	for i, sql := range sqls {
+		steps[i] = MigrationStep{Sql: sql, phase: phaseCreateTable}
+	}
+	return steps, nil
+}
+
+func diffTable(
+	builder *PgQueryBuilder, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, table *LiveTable,
+) ([]MigrationStep, error) {
+	tableRef := pgQuote(schema.TableName())
+	columnSteps, err := diffColumns(schema, tableRef, table)
+	if err != nil {
+		return nil, err
+	}
+	constraintSteps, err := diffConstraints(schema, registry, tableRef, table)
+	if err != nil {
+		return nil, err
+	}
+	indexSteps, err := diffIndexes(builder, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	steps := append(columnSteps, constraintSteps...)
+	return append(steps, indexSteps...), nil
+}
+
+func diffColumns(schema *dmodel.ModelSchema, tableRef string, table *LiveTable) ([]MigrationStep, error) {
+	defs, err := modelColumnDefs(pgDialect{}, schema)
+	if err != nil {
+		return nil, err
+	}
+	defs = append(defs, fullTextColumnDefs(schema)...)
+	var steps []MigrationStep
+	declared := make(map[string]struct{}, len(defs))
+	for _, def := range defs {
+		declared[def.Name] = struct{}{}
+		column, ok := table.Columns[def.Name]
+		if !ok {
+			step := MigrationStep{
+				Sql:   fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableRef, def.sql()),
+				phase: phaseAddColumn,
+			}
+			if def.Constraint == "NOT NULL" {
+				step.Destructive = true
+				step.Reason = fmt.Sprintf("adds NOT NULL column '%s' without a default, which fails while the table has rows", def.Name)
+			}
+			steps = append(steps, step)
+			continue
+		}
+		if def.Generated {
+			continue
+		}
+		steps = append(steps, alterColumnSteps(tableRef, def, column)...)
+	}
+	for _, name := range sortedKeys(table.Columns) {
+		if _, ok := declared[name]; ok {
+			continue
+		}
+		steps = append(steps, MigrationStep{
+			Sql:         fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableRef, pgQuote(name)),
+			Destructive: true,
+			Reason:      fmt.Sprintf("drops column '%s' with its data", name),
+			phase:       phaseDropColumn,
+		})
+	}
+	return steps, nil
+}
+
+func alterColumnSteps(tableRef string, def columnDef, column LiveColumn) []MigrationStep {
+	var steps []MigrationStep
+	colRef := pgQuote(def.Name)
+	if liveType := normalizePgType(column.Type); liveType != normalizePgType(def.Type) {
+		step := MigrationStep{
+			Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s",
+				tableRef, colRef, def.Type, colRef, def.Type),
+			phase: phaseAlterColumn,
+		}
+		if !isWideningPgType(liveType, normalizePgType(def.Type)) {
+			step.Destructive = true
+			step.Reason = fmt.Sprintf("changes column '%s' from %s to %s, which can fail or lose precision",
+				def.Name, column.Type, def.Type)
+		}
+		steps = append(steps, step)
+	}
+	wantNotNull := def.Constraint == "NOT NULL"
+	switch {
+	case wantNotNull && !column.NotNull:
+		steps = append(steps, MigrationStep{
+			Sql:         fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", tableRef, colRef),
+			Destructive: true,
+			Reason:      fmt.Sprintf("makes column '%s' NOT NULL, which fails while a row holds NULL", def.Name),
+			phase:       phaseAlterColumn,
+		})
+	case !wantNotNull && column.NotNull:
+		steps = append(steps, MigrationStep{
+			Sql:   fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", tableRef, colRef),
+			phase: phaseAlterColumn,
+		})
+	}
+	return steps
+}
+
+var pgTypeAliases = map[string]string{
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+	"varchar":     "character varying",
+	"int":         "integer",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"bool":        "boolean",
+	"decimal":     "numeric",
+}
+
+// normalizePgType spells a type the way format_type prints it, so that the type of a schema
+// compares with the type of a live column.
+func normalizePgType(pgType string) string {
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	array := strings.HasSuffix(t, "[]")
+	t = strings.TrimSuffix(t, "[]")
+	base, modifier := splitPgTypeModifier(t)
+	if alias, ok := pgTypeAliases[base]; ok {
+		base = alias
+	}
+	t = base + modifier
+	if array {
+		t += "[]"
+	}
+	return t
+}
+
+var pgTypeModifierRegex = regexp.MustCompile(`^(.*?)\s*(\(\s*\d+\s*(?:,\s*\d+\s*)?\))$`)
+
+func splitPgTypeModifier(pgType string) (base string, modifier string) {
+	match := pgTypeModifierRegex.FindStringSubmatch(pgType)
+	if match == nil {
+		return pgType, ""
+	}
+	return match[1], strings.ReplaceAll(match[2], " ", "")
+}
+
+// pgWideningTypes lists the changes of base type that keep every value as it is.
+var pgWideningTypes = map[[2]string]struct{}{
+	{"integer", "bigint"}:              {},
+	{"integer", "numeric"}:             {},
+	{"bigint", "numeric"}:              {},
+	{"character varying", "text"}:      {},
+	{"character", "character varying"}: {},
+}
+
+// isWideningPgType reports whether a column of type from can turn into type to without losing
+// or rejecting any value: a wider base type, or the same base type with a larger length or no
+// length at all. Both types are normalized.
+func isWideningPgType(from, to string) bool {
+	if strings.HasSuffix(from, "[]") != strings.HasSuffix(to, "[]") {
+		return false
+	}
+	fromBase, fromModifier := splitPgTypeModifier(strings.TrimSuffix(from, "[]"))
+	toBase, toModifier := splitPgTypeModifier(strings.TrimSuffix(to, "[]"))
+	if fromBase != toBase {
+		_, ok := pgWideningTypes[[2]string{fromBase, toBase}]
+		return ok && toModifier == ""
+	}
+	if toModifier == "" {
+		return true
+	}
+	if fromModifier == "" {
+		return false
+	}
+	fromLen, fromScale := parsePgTypeModifier(fromModifier)
+	toLen, toScale := parsePgTypeModifier(toModifier)
+	return toScale >= fromScale && toLen-toScale >= fromLen-fromScale
+}
+
+// parsePgTypeModifier reads "(n)" or "(p,s)", with the scale 0 when absent.
+func parsePgTypeModifier(modifier string) (int, int) {
+	parts := strings.Split(strings.Trim(modifier, "()"), ",")
+	length, _ := strconv.Atoi(parts[0])
+	scale := 0
+	if len(parts) > 1 {
+		scale, _ = strconv.Atoi(parts[1])
+	}
+	return length, scale
+}
+
+func diffConstraints(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, tableRef string, table *LiveTable,
+) ([]MigrationStep, error) {
+	declared, err := uniqueConstraints(schema)
+	if err != nil {
+		return nil, err
+	}
+	if pk := primaryKeyConstraint(schema); pk != nil {
+		declared = append([]tableConstraint{*pk}, declared...)
+	}
+	fks, err := foreignKeyConstraints(schema, registry)
+	if err != nil {
+		return nil, err
+	}
+	declared = append(declared, fks...)
+
+	var steps []MigrationStep
+	names := make(map[string]struct{}, len(declared))
+	for _, constraint := range declared {
+		names[constraint.Name] = struct{}{}
+		addSql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s",
+			tableRef, pgQuote(constraint.Name), constraint.Definition)
+		liveDef, ok := table.Constraints[constraint.Name]
+		if !ok {
+			steps = append(steps, MigrationStep{
+				Sql: addSql, phase: constraintPhase(constraint.ForeignKey, phaseAddForeignKey, phaseAddKey),
+			})
+			continue
+		}
+		if normalizeConstraintDef(liveDef) == normalizeConstraintDef(constraint.Definition) {
+			continue
+		}
+		reason := fmt.Sprintf("re-creates constraint '%s', changed from %s", constraint.Name, liveDef)
+		steps = append(steps,
+			dropConstraintStep(tableRef, constraint.Name, constraint.ForeignKey, reason),
+			MigrationStep{
+				Sql:         addSql,
+				Destructive: true,
+				Reason:      reason,
+				phase:       constraintPhase(constraint.ForeignKey, phaseReAddForeignKey, phaseReAddKey),
+			})
+	}
+	for _, name := range sortedKeys(table.Constraints) {
+		if _, ok := names[name]; ok {
+			continue
+		}
+		isForeignKey := strings.HasPrefix(table.Constraints[name], "FOREIGN KEY")
+		steps = append(steps, dropConstraintStep(tableRef, name, isForeignKey,
+			fmt.Sprintf("drops constraint '%s' (%s)", name, table.Constraints[name])))
+	}
+	return steps, nil
+}
+
+func dropConstraintStep(tableRef string, name string, isForeignKey bool, reason string) MigrationStep {
+	return MigrationStep{
+		Sql:         fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", tableRef, pgQuote(name)),
+		Destructive: true,
+		Reason:      reason,
+		phase:       constraintPhase(isForeignKey, phaseDropForeignKey, phaseDropKey),
+	}
+}
+
+// normalizeConstraintDef brings a constraint definition as the builder writes it and as
+// pg_get_constraintdef prints it to the same spelling: no identifier quotes, no default NO
+// ACTION, and no space before a column list.
+func normalizeConstraintDef(def string) string {
+	s := strings.ReplaceAll(def, `"`, "")
+	s = strings.ReplaceAll(s, " ON UPDATE NO ACTION", "")
+	s = strings.ReplaceAll(s, " ON DELETE NO ACTION", "")
+	s = strings.ReplaceAll(s, " (", "(")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var createIndexNameRegex = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX "([^"]+)"`)
+
+func diffIndexes(builder *PgQueryBuilder, schema *dmodel.ModelSchema, table *LiveTable) ([]MigrationStep, error) {
+	sqls, err := declaredIndexSqls(builder, schema)
+	if err != nil {
+		return nil, err
+	}
+	var steps []MigrationStep
+	names := make(map[string]struct{}, len(sqls))
+	// A statement other than an index, such as CREATE EXTENSION, is a prerequisite of the index
+	// after it, so it runs only when that index is to be created.
+	var prerequisites []string
+	for _, sql := range sqls {
+		match := createIndexNameRegex.FindStringSubmatch(sql)
+		if match == nil {
+			prerequisites = append(prerequisites, sql)
+			continue
+		}
+		names[match[1]] = struct{}{}
+		if _, ok := table.Indexes[match[1]]; !ok {
+			for _, prerequisite := range append(prerequisites, sql) {
+				steps = append(steps, MigrationStep{Sql: prerequisite, phase: phaseCreateIndex})
+			}
+		}
+		prerequisites = nil
+	}
+	for _, name := range sortedKeys(table.Indexes) {
+		if _, ok := names[name]; ok {
+			continue
+		}
+		steps = append(steps, MigrationStep{
+			Sql:         fmt.Sprintf("DROP INDEX %s", pgQuote(name)),
+			Destructive: true,
+			Reason:      fmt.Sprintf("drops index '%s'", name),
+			phase:       phaseDropIndex,
+		})
+	}
+	return steps, nil
+}
+
+func declaredIndexSqls(builder *PgQueryBuilder, schema *dmodel.ModelSchema) ([]string, error) {
+	partialUniques, err := builder.partialUniqueIndexSqls(schema)
+	if err != nil {
+		return nil, err
+	}
+	searchIndexes, err := builder.searchIndexSqls(schema)
+	if err != nil {
+		return nil, err
+	}
+	fullTextIndexes, err := builder.fullTextIndexSqls(schema)
+	if err != nil {
+		return nil, err
+	}
+	out := append(partialUniques, searchIndexes...)
+	return append(out, fullTextIndexes...), nil
+}
+
+// constraintPhase picks the phase of a constraint step: foreign keys have their own.
+func constraintPhase(isForeignKey bool, foreignKeyPhase, keyPhase migrationPhase) migrationPhase {
+	if isForeignKey {
+		return foreignKeyPhase
+	}
+	return keyPhase
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
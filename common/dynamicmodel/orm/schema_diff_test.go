@@ -0,0 +1,288 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+// The diff is tested against hand-built LiveTables, the shape IntrospectPg reads from
+// pg_catalog, so no database is needed.
+
+func diffCustomerSchema() *dmodel.ModelSchema {
+	return dmodel.DefineModel("diff_customer").
+		ShouldBuildDb().
+		TableName("diff_customers").
+		Field(dmodel.DefineField().Name("id").
+			DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+		Field(dmodel.DefineField().Name("code").
+			DataType(dmodel.FieldDataTypeString(1, 50)).RequiredForCreate().Unique()).
+		Build()
+}
+
+func diffOrderSchema(extra ...*dmodel.FieldBuilder) *dmodel.ModelSchema {
+	builder := dmodel.DefineModel("diff_order").
+		ShouldBuildDb().
+		TableName("diff_orders").
+		Field(dmodel.DefineField().Name("id").
+			DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+		Field(dmodel.DefineField().Name("customer_id").
+			DataType(dmodel.FieldDataTypeUlid())).
+		Field(dmodel.DefineField().Name("status").
+			DataType(dmodel.FieldDataTypeString(1, 20)).RequiredForCreate()).
+		Field(dmodel.DefineField().Name("total").
+			DataType(dmodel.FieldDataTypeInt64(0, 1000000000))).
+		Field(dmodel.DefineField().Name("placed_at").
+			DataType(dmodel.FieldDataTypeDateTime())).
+		SearchIndex("status").
+		EdgeTo(dmodel.Edge("customer").ManyToOne("diff_customer", dmodel.DynamicFields{"customer_id": "id"}))
+	for _, field := range extra {
+		builder.Field(field)
+	}
+	return builder.Build()
+}
+
+func diffRegistry(t *testing.T, schemas ...*dmodel.ModelSchema) *dmodel.SchemaRegistry {
+	t.Helper()
+	registry := dmodel.NewSchemaRegistry()
+	for _, schema := range schemas {
+		require.NoError(t, registry.Register(schema))
+	}
+	require.NoError(t, registry.FinalizeRelations())
+	return registry
+}
+
+// liveTableOf returns the table as the CREATE TABLE of schema left it in the database.
+func liveTableOf(t *testing.T, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry) *LiveTable {
+	t.Helper()
+	table := &LiveTable{
+		Columns:     map[string]LiveColumn{},
+		Constraints: map[string]string{},
+		Indexes:     map[string]struct{}{},
+	}
+	defs, err := modelColumnDefs(pgDialect{}, schema)
+	require.NoError(t, err)
+	for _, def := range append(defs, fullTextColumnDefs(schema)...) {
+		table.Columns[def.Name] = LiveColumn{
+			Type: normalizePgType(def.Type), NotNull: def.Constraint == "NOT NULL", Generated: def.Generated,
+		}
+	}
+	constraints, err := uniqueConstraints(schema)
+	require.NoError(t, err)
+	fks, err := foreignKeyConstraints(schema, registry)
+	require.NoError(t, err)
+	for _, constraint := range append(append(constraints, fks...), *primaryKeyConstraint(schema)) {
+		table.Constraints[constraint.Name] = constraint.Definition
+	}
+	sqls, err := declaredIndexSqls(NewPgQueryBuilder().(*PgQueryBuilder), schema)
+	require.NoError(t, err)
+	for _, sql := range sqls {
+		if match := createIndexNameRegex.FindStringSubmatch(sql); match != nil {
+			table.Indexes[match[1]] = struct{}{}
+		}
+	}
+	return table
+}
+
+func diffSqls(steps []MigrationStep, destructive bool) []string {
+	var out []string
+	for _, step := range steps {
+		if step.Destructive == destructive {
+			out = append(out, step.Sql)
+		}
+	}
+	return out
+}
+
+func TestDiffSchemas_NothingToDoWhenInSync(t *testing.T) {
+	customer, order := diffCustomerSchema(), diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+		"diff_orders":    liveTableOf(t, order, registry),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+}
+
+// pg_get_constraintdef leaves out the quotes and the default NO ACTION that the builder writes.
+func TestDiffSchemas_ReadsConstraintsAsPostgresPrintsThem(t *testing.T) {
+	customer, order := diffCustomerSchema(), diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+	live := liveTableOf(t, order, registry)
+	live.Constraints["diff_orders_customer_id_fkey"] = "FOREIGN KEY (customer_id) REFERENCES diff_customers(id)"
+	live.Constraints["diff_orders_pkey"] = "PRIMARY KEY (id)"
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+		"diff_orders":    live,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+}
+
+func TestDiffSchemas_CreatesMissingTablesWhole(t *testing.T) {
+	customer, order := diffCustomerSchema(), diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+	})
+	require.NoError(t, err)
+
+	createSqls, _, err := NewPgQueryBuilder().SqlCreateTable(order, registry)
+	require.NoError(t, err)
+	assert.Equal(t, createSqls, diffSqls(steps, false))
+	assert.Empty(t, diffSqls(steps, true))
+}
+
+func TestDiffSchemas_AddsNewColumns(t *testing.T) {
+	customer := diffCustomerSchema()
+	order := diffOrderSchema(
+		dmodel.DefineField().Name("note").DataType(dmodel.FieldDataTypeString(0, 500)),
+		dmodel.DefineField().Name("channel").DataType(dmodel.FieldDataTypeString(1, 20)).RequiredForCreate(),
+	)
+	registry := diffRegistry(t, customer, order)
+	live := liveTableOf(t, order, registry)
+	delete(live.Columns, "note")
+	delete(live.Columns, "channel")
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+		"diff_orders":    live,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{`ALTER TABLE "diff_orders" ADD COLUMN "note" character varying NULL`},
+		diffSqls(steps, false))
+	require.Len(t, diffSqls(steps, true), 1, "a NOT NULL column without default fails on a table with rows")
+	assert.Equal(t, `ALTER TABLE "diff_orders" ADD COLUMN "channel" character varying NOT NULL`, steps[1].Sql)
+	assert.Contains(t, steps[1].Reason, "fails while the table has rows")
+}
+
+func TestDiffSchemas_AltersColumns(t *testing.T) {
+	customer, order := diffCustomerSchema(), diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+	live := liveTableOf(t, order, registry)
+	live.Columns["total"] = LiveColumn{Type: "integer"}
+	live.Columns["status"] = LiveColumn{Type: "character varying(20)"}
+	live.Columns["placed_at"] = LiveColumn{Type: "text", NotNull: true}
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+		"diff_orders":    live,
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		`ALTER TABLE "diff_orders" ALTER COLUMN "status" TYPE character varying USING "status"::character varying`,
+		`ALTER TABLE "diff_orders" ALTER COLUMN "total" TYPE bigint USING "total"::bigint`,
+		`ALTER TABLE "diff_orders" ALTER COLUMN "placed_at" DROP NOT NULL`,
+	}, diffSqls(steps, false))
+	assert.ElementsMatch(t, []string{
+		`ALTER TABLE "diff_orders" ALTER COLUMN "status" SET NOT NULL`,
+		`ALTER TABLE "diff_orders" ALTER COLUMN "placed_at" TYPE timestamptz USING "placed_at"::timestamptz`,
+	}, diffSqls(steps, true))
+}
+
+func TestDiffSchemas_FlagsDropsAndOrdersThem(t *testing.T) {
+	customer, order := diffCustomerSchema(), diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+	live := liveTableOf(t, order, registry)
+	live.Columns["legacy_code"] = LiveColumn{Type: "character varying"}
+	live.Constraints["diff_orders_legacy_code_ukey"] = "UNIQUE (legacy_code)"
+	live.Constraints["diff_orders_legacy_code_fkey"] = "FOREIGN KEY (legacy_code) REFERENCES diff_customers(code)"
+	live.Indexes["legacy_code_idx"] = struct{}{}
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveTableOf(t, customer, registry),
+		"diff_orders":    live,
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, diffSqls(steps, false))
+	assert.Equal(t, []string{
+		`ALTER TABLE "diff_orders" DROP CONSTRAINT "diff_orders_legacy_code_fkey"`,
+		`DROP INDEX "legacy_code_idx"`,
+		`ALTER TABLE "diff_orders" DROP CONSTRAINT "diff_orders_legacy_code_ukey"`,
+		`ALTER TABLE "diff_orders" DROP COLUMN "legacy_code"`,
+	}, diffSqls(steps, true))
+}
+
+func TestDiffSchemas_ReplacesKeysAfterTheDrops(t *testing.T) {
+	customer := diffCustomerSchema()
+	order := diffOrderSchema()
+	registry := diffRegistry(t, customer, order)
+	liveCustomer := liveTableOf(t, customer, registry)
+	liveCustomer.Constraints["diff_customers_name_ukey"] = "UNIQUE (name)"
+	delete(liveCustomer.Constraints, "diff_customers_code_ukey")
+	liveOrder := liveTableOf(t, order, registry)
+	liveOrder.Constraints["diff_orders_customer_id_fkey"] =
+		"FOREIGN KEY (customer_id) REFERENCES diff_customers(id) ON DELETE CASCADE"
+	delete(liveOrder.Indexes, "status_idx")
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{
+		"diff_customers": liveCustomer,
+		"diff_orders":    liveOrder,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "diff_customers" ADD CONSTRAINT "diff_customers_code_ukey" UNIQUE ("code")`,
+		`CREATE INDEX "status_idx" ON "diff_orders" ("status")`,
+	}, diffSqls(steps, false))
+	assert.Equal(t, []string{
+		`ALTER TABLE "diff_orders" DROP CONSTRAINT "diff_orders_customer_id_fkey"`,
+		`ALTER TABLE "diff_customers" DROP CONSTRAINT "diff_customers_name_ukey"`,
+		`ALTER TABLE "diff_orders" ADD CONSTRAINT "diff_orders_customer_id_fkey" FOREIGN KEY ("customer_id") ` +
+			`REFERENCES "diff_customers" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION`,
+	}, diffSqls(steps, true))
+}
+
+func TestDiffSchemas_FullTextIndexBringsItsExtension(t *testing.T) {
+	schema := fullTextSchema(true)
+	registry := diffRegistry(t, schema)
+	live := liveTableOf(t, schema, registry)
+	delete(live.Columns, FullTextTrigramColumn)
+	delete(live.Indexes, "test_fulltext_articles_search_text_trgm_idx")
+
+	steps, err := DiffSchemas(registry, map[string]*LiveTable{"test_fulltext_articles": live})
+	require.NoError(t, err)
+
+	sqls := diffSqls(steps, false)
+	require.Len(t, sqls, 3)
+	assert.Contains(t, sqls[0], `ALTER TABLE "test_fulltext_articles" ADD COLUMN "_search_text" text GENERATED ALWAYS AS (`)
+	assert.Equal(t, []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX "test_fulltext_articles_search_text_trgm_idx" ON "test_fulltext_articles" ` +
+			`USING GIN ("_search_text" gin_trgm_ops)`,
+	}, sqls[1:])
+}
+
+func TestIsWideningPgType(t *testing.T) {
+	cases := []struct {
+		from, to string
+		widening bool
+	}{
+		{"integer", "bigint", true},
+		{"bigint", "integer", false},
+		{"bigint", "numeric", true},
+		{"numeric", "bigint", false},
+		{"character varying(20)", "character varying", true},
+		{"character varying(20)", "character varying(50)", true},
+		{"character varying(50)", "character varying(20)", false},
+		{"character varying", "character varying(50)", false},
+		{"numeric(10,2)", "numeric(12,2)", true},
+		{"numeric(10,2)", "numeric(10,4)", false},
+		{"character varying", "character varying[]", false},
+		{"text", "character varying", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.widening, isWideningPgType(normalizePgType(c.from), normalizePgType(c.to)),
+			"%s -> %s", c.from, c.to)
+	}
+}
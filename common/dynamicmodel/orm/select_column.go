@@ -134,17 +134,15 @@ func buildAggregateSelectExpr(
 // orderRefsForDistinct returns the ORDER BY refs that must be forced into the select list.
 //
 // Under SELECT DISTINCT, PostgreSQL rejects an ORDER BY expression that is not projected. The
-// refs are already-built SQL ("t1.\"label\" ASC"), so the direction suffix is trimmed off.
-func orderRefsForDistinct(isDistinct bool, orderExprs []string) []string {
+// refs are taken from the resolved keys rather than from the ORDER BY items, which carry a
+// direction and, on SQLite, a NULLS placement that no select list accepts.
+func orderRefsForDistinct(isDistinct bool, keys []orderKey) []string {
 	if !isDistinct {
 		return nil
 	}
-	refs := make([]string, 0, len(orderExprs))
-	for _, expr := range orderExprs {
-		if ref := strings.TrimSpace(strings.TrimSuffix(
-			strings.TrimSuffix(strings.TrimSpace(expr), " DESC"), " ASC")); ref != "" {
-			refs = append(refs, ref)
-		}
+	refs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		refs = append(refs, key.ref)
 	}
 	return refs
 }
@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	cmodel "github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// sqlDialect is what a query builder's SQL depends on besides the schema: the builder flavor,
+// the column types, how values are written, and the few expressions each database spells its
+// own way. Everything else PostgreSQL and SQLite share.
+type sqlDialect interface {
+	flavor() sqlbuilder.Flavor
+	// columnType is the DDL type of a model column.
+	columnType(field *dmodel.ModelField) (string, error)
+	// arrayValue is the value written for an array field, already known to be a slice.
+	arrayValue(field *dmodel.ModelField, cat columnCategory, v reflect.Value) (any, error)
+	// timeValue is the value written for a date, time or date-time field.
+	timeValue(field *dmodel.ModelField, t time.Time) any
+	// likeExpr matches expr against a LIKE pattern, ignoring case.
+	likeExpr(sb *sqlbuilder.SelectBuilder, expr string, pattern string, negate bool) string
+	// langJsonText is the text a string operator matches in a LangJson column: one translation
+	// when a language is given, the whole document otherwise.
+	langJsonText(sqlRef string, language *cmodel.LanguageCode) string
+	textCast(expr string) string
+	// nullsOrder completes an ORDER BY item so that NULLs sort last ascending and first
+	// descending, the order the keyset seek assumes.
+	nullsOrder(desc bool) string
+	dateTrunc(trunc DateTrunc, ref string, asDate bool) string
+	// decimalDividend keeps a division from being done on integers.
+	decimalDividend(expr string) string
+	// placeholder is the numbered parameter of a statement executed with separate arguments.
+	placeholder(idx int) string
+}
+
+// pgDialect is PostgreSQL, which the builder was first written for.
+type pgDialect struct{}
+
+func (pgDialect) flavor() sqlbuilder.Flavor {
+	return sqlbuilder.PostgreSQL
+}
+
+func (pgDialect) columnType(field *dmodel.ModelField) (string, error) {
+	return resolveModelFieldToPgType(field)
+}
+
+func (pgDialect) arrayValue(field *dmodel.ModelField, cat columnCategory, v reflect.Value) (any, error) {
+	return buildPgArrayRaw(field, cat, v)
+}
+
+func (pgDialect) timeValue(_ *dmodel.ModelField, t time.Time) any {
+	return t
+}
+
+func (pgDialect) likeExpr(sb *sqlbuilder.SelectBuilder, expr string, pattern string, negate bool) string {
+	if negate {
+		return sb.NotILike(expr, pattern)
+	}
+	return sb.ILike(expr, pattern)
+}
+
+func (pgDialect) langJsonText(sqlRef string, language *cmodel.LanguageCode) string {
+	if isAnyLanguage(language) {
+		return fmt.Sprintf("(%s)::text", sqlRef)
+	}
+	return fmt.Sprintf("(%s ->> %s)", sqlRef, pgStringLiteral(string(*language)))
+}
+
+func (pgDialect) textCast(expr string) string {
+	return expr + "::text"
+}
+
+// nullsOrder adds nothing: PostgreSQL's default NULL placement is the one the seek assumes.
+func (pgDialect) nullsOrder(bool) string {
+	return ""
+}
+
+func (pgDialect) dateTrunc(trunc DateTrunc, ref string, asDate bool) string {
+	expr := fmt.Sprintf("date_trunc(%s, %s)", pgStringLiteral(string(trunc)), ref)
+	if asDate {
+		// date_trunc answers a timestamp; a date bucket is given back as a date.
+		return expr + "::date"
+	}
+	return expr
+}
+
+func (pgDialect) decimalDividend(expr string) string {
+	return "(" + expr + ")::numeric"
+}
+
+func (pgDialect) placeholder(idx int) string {
+	return fmt.Sprintf("$%d", idx)
+}
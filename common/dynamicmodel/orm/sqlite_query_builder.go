@@ -0,0 +1,246 @@
+package orm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	cmodel "github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// Ensure interface implementation at compile time.
+var _ QueryBuilder = (*SqliteQueryBuilder)(nil)
+
+// SqliteQueryBuilder implements QueryBuilder for SQLite, so that a small installation or a test
+// can run on an embedded database. It writes the statements PgQueryBuilder writes, in SQLite's
+// spelling:
+//   - JSON and LangJson columns are TEXT, and a LangJson filter reads a translation with json_extract.
+//   - Array columns are TEXT holding PostgreSQL array text ({"a","b"}), which the model's array
+//     scanners already read, so a repository reads either database the same way.
+//   - Date-times are stored as UTC text that sorts in time order; dates and times as ISO text.
+//   - String operators use LIKE, which SQLite matches case-insensitively for ASCII only.
+//
+// Full-text search has no SQLite counterpart here and is refused.
+type SqliteQueryBuilder struct {
+	PgQueryBuilder
+}
+
+func NewSqliteQueryBuilder() QueryBuilder {
+	return &SqliteQueryBuilder{
+		PgQueryBuilder: PgQueryBuilder{
+			predefinedPredicates: make(map[string]map[string]PredefinedPredicateTreatment),
+			dialect:              sqliteDialect{},
+		},
+	}
+}
+
+// NewSqliteClient wraps a SQLite database. Nothing PgClient does is particular to PostgreSQL,
+// so SQLite shares it.
+func NewSqliteClient(db *sql.DB) DbClient {
+	return NewPgClient(db)
+}
+
+func (this *SqliteQueryBuilder) SqlCreateTable(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+) ([]string, *ft.ClientErrors, error) {
+	if schema.FullTextSearch() != nil {
+		return nil, nil, errors.Errorf(
+			"SqliteQueryBuilder.SqlCreateTable: schema '%s': full-text search requires PostgreSQL", schema.Name())
+	}
+	return this.PgQueryBuilder.SqlCreateTable(schema, registry)
+}
+
+func (this *SqliteQueryBuilder) SqlSelectGraph(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlSelectGraphOpts,
+) (*string, *ft.ClientErrors, error) {
+	if opts.TextQuery != "" {
+		return nil, nil, errors.New("SqliteQueryBuilder.SqlSelectGraph: full-text search requires PostgreSQL")
+	}
+	return this.PgQueryBuilder.SqlSelectGraph(schema, registry, graph, opts)
+}
+
+func (this *SqliteQueryBuilder) SqlCountGraph(
+	schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlSelectGraphOpts,
+) (*string, *ft.ClientErrors, error) {
+	if opts.TextQuery != "" {
+		return nil, nil, errors.New("SqliteQueryBuilder.SqlCountGraph: full-text search requires PostgreSQL")
+	}
+	return this.PgQueryBuilder.SqlCountGraph(schema, registry, graph, opts)
+}
+
+// sqliteDateTimeLayout is fixed-width, so date-times compare in time order as text.
+const sqliteDateTimeLayout = "2006-01-02 15:04:05.000000"
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) flavor() sqlbuilder.Flavor {
+	return sqlbuilder.SQLite
+}
+
+// columnType maps through the PostgreSQL type, so both builders accept the same column types.
+func (sqliteDialect) columnType(field *dmodel.ModelField) (string, error) {
+	if field.IsVirtual() {
+		return "", errors.Errorf("sqliteDialect.columnType: virtual field '%s' has no SQL type", field.Name())
+	}
+	if field.IsArray() {
+		return "TEXT", nil
+	}
+	pgType, err := resolveGenericToPgType(field.ColumnType())
+	if err != nil {
+		return "", err
+	}
+	switch pgType {
+	case "bigint":
+		return "BIGINT", nil
+	case "integer":
+		return "INTEGER", nil
+	case "numeric":
+		return "NUMERIC", nil
+	case "boolean":
+		return "BOOLEAN", nil
+	case "date":
+		return "DATE", nil
+	case "timestamptz":
+		return "TIMESTAMP", nil
+	default:
+		// character varying, uuid, time without time zone and jsonb.
+		return "TEXT", nil
+	}
+}
+
+func (sqliteDialect) arrayValue(field *dmodel.ModelField, cat columnCategory, v reflect.Value) (any, error) {
+	items := make([]string, v.Len())
+	for i := range items {
+		item, err := sqliteArrayElementText(field, cat, v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return "{" + strings.Join(items, ",") + "}", nil
+}
+
+var sqliteArrayElementEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// sqliteArrayElementText writes one element of PostgreSQL array text, quoted whatever its type,
+// which the array parsers accept for numbers and booleans as well.
+func sqliteArrayElementText(field *dmodel.ModelField, cat columnCategory, elem reflect.Value) (string, error) {
+	ev, ok := unwrapValue(elem)
+	if !ok {
+		return "NULL", nil
+	}
+	var text string
+	switch cat {
+	case columnString:
+		if ev.Kind() != reflect.String {
+			return "", errors.Errorf("sqliteArrayElementText: expected string element, got %s", ev.Kind())
+		}
+		text = ev.String()
+	case columnBool:
+		if ev.Kind() != reflect.Bool {
+			return "", errors.Errorf("sqliteArrayElementText: expected bool element, got %s", ev.Kind())
+		}
+		text = "f"
+		if ev.Bool() {
+			text = "t"
+		}
+	case columnInt, columnNumeric:
+		number, err := pgArrayElementSQL(cat, ev)
+		if err != nil {
+			return "", err
+		}
+		text = number
+	case columnTime:
+		t, err := modelTimeFromReflect(ev)
+		if err != nil {
+			return "", err
+		}
+		text = sqliteTimeText(field, t)
+	case columnJSON:
+		raw, err := json.Marshal(ev.Interface())
+		if err != nil {
+			return "", errors.Errorf("sqliteArrayElementText: cannot marshal json element: %v", err)
+		}
+		text = string(raw)
+	default:
+		return "", errors.Errorf("sqliteArrayElementText: unsupported column category %v", cat)
+	}
+	return `"` + sqliteArrayElementEscaper.Replace(text) + `"`, nil
+}
+
+func (sqliteDialect) timeValue(field *dmodel.ModelField, t time.Time) any {
+	return sqliteTimeText(field, t)
+}
+
+func sqliteTimeText(field *dmodel.ModelField, t time.Time) string {
+	switch field.ColumnType() {
+	case "date", dmodel.FieldDataTypeNameModelDate:
+		return t.Format(time.DateOnly)
+	case "time", dmodel.FieldDataTypeNameModelTime:
+		return t.Format(time.TimeOnly)
+	default:
+		return t.UTC().Format(sqliteDateTimeLayout)
+	}
+}
+
+func (sqliteDialect) likeExpr(sb *sqlbuilder.SelectBuilder, expr string, pattern string, negate bool) string {
+	if negate {
+		return sb.NotLike(expr, pattern)
+	}
+	return sb.Like(expr, pattern)
+}
+
+func (sqliteDialect) langJsonText(sqlRef string, language *cmodel.LanguageCode) string {
+	if isAnyLanguage(language) {
+		return fmt.Sprintf("(%s)", sqlRef)
+	}
+	// The key is quoted in the path, as a language code such as en-US is not a bare JSON path label.
+	path := fmt.Sprintf(`$."%s"`, string(*language))
+	return fmt.Sprintf("json_extract(%s, %s)", sqlRef, pgStringLiteral(path))
+}
+
+func (sqliteDialect) textCast(expr string) string {
+	return "CAST(" + expr + " AS TEXT)"
+}
+
+// nullsOrder spells out PostgreSQL's placement, as SQLite sorts NULLs first ascending.
+func (sqliteDialect) nullsOrder(desc bool) string {
+	if desc {
+		return " NULLS FIRST"
+	}
+	return " NULLS LAST"
+}
+
+// dateTrunc buckets with the date functions; a week starts on Monday, as with date_trunc.
+func (sqliteDialect) dateTrunc(trunc DateTrunc, ref string, asDate bool) string {
+	var bucket string
+	switch trunc {
+	case DateTruncWeek:
+		bucket = fmt.Sprintf("date(%s, '-6 days', 'weekday 1')", ref)
+	case DateTruncMonth:
+		bucket = fmt.Sprintf("date(%s, 'start of month')", ref)
+	default:
+		bucket = fmt.Sprintf("date(%s)", ref)
+	}
+	if asDate {
+		return bucket
+	}
+	return "datetime(" + bucket + ")"
+}
+
+// decimalDividend casts to REAL: a NUMERIC cast keeps a whole number an integer.
+func (sqliteDialect) decimalDividend(expr string) string {
+	return "CAST(" + expr + " AS REAL)"
+}
+
+func (sqliteDialect) placeholder(idx int) string {
+	return fmt.Sprintf("?%d", idx)
+}
@@ -0,0 +1,233 @@
+package orm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/computed"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	cmodel "github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// The SQLite builder shares PgQueryBuilder's statements and differs in the dialect only. These
+// tests pin the SQLite spelling where it differs, and reuse the DISTINCT tests' schemas for joins.
+
+const sqliteItemSchema = "test_sqlite_item"
+
+func sqliteItemSchemaOf(t *testing.T) (*dmodel.ModelSchema, *dmodel.SchemaRegistry) {
+	t.Helper()
+	registry := dmodel.GetSchemaRegistry()
+	if existing := registry.Get(sqliteItemSchema); existing != nil {
+		return existing, registry
+	}
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(sqliteItemSchema).
+			TableName("test_sqlite_items").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+			Field(dmodel.DefineField().Name("name").
+				DataType(dmodel.FieldDataTypeString(0, 50))).
+			Field(dmodel.DefineField().Name("tags").
+				DataType(dmodel.FieldDataTypeString(0, 50).ArrayType())).
+			Field(dmodel.DefineField().Name("scores").
+				DataType(dmodel.FieldDataTypeInt64(0, 100).ArrayType())).
+			Field(dmodel.DefineField().Name("title").
+				DataType(dmodel.FieldDataTypeLangJson(0, 50))).
+			Field(dmodel.DefineField().Name("meta").
+				DataType(dmodel.FieldDataTypeJsonMap())).
+			Field(dmodel.DefineField().Name("is_archived").
+				DataType(dmodel.FieldDataTypeBoolean())).
+			Field(dmodel.DefineField().Name("due_date").
+				DataType(dmodel.FieldDataTypeDate())).
+			Field(dmodel.DefineField().Name("due_at").
+				DataType(dmodel.FieldDataTypeDateTime()))))
+	return registry.Get(sqliteItemSchema), registry
+}
+
+func sqliteSelectSql(
+	t *testing.T, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+	graph *dmodel.SearchGraph, opts SqlSelectGraphOpts,
+) string {
+	t.Helper()
+	sql, cErrs, err := NewSqliteQueryBuilder().SqlSelectGraph(schema, registry, graph, opts)
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	require.NotNil(t, sql)
+	return *sql
+}
+
+func TestSqlite_CreateTableUsesSqliteTypes(t *testing.T) {
+	schema, registry := sqliteItemSchemaOf(t)
+
+	sqls, cErrs, err := NewSqliteQueryBuilder().SqlCreateTable(schema, registry)
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	require.NotEmpty(t, sqls)
+	create := sqls[0]
+	assert.Contains(t, create, `"id" TEXT NOT NULL`)
+	assert.Contains(t, create, `"tags" TEXT`)
+	assert.Contains(t, create, `"scores" TEXT`)
+	assert.Contains(t, create, `"title" TEXT`)
+	assert.Contains(t, create, `"meta" TEXT`)
+	assert.Contains(t, create, `"is_archived" BOOLEAN`)
+	assert.Contains(t, create, `"due_date" DATE`)
+	assert.Contains(t, create, `"due_at" TIMESTAMP`)
+	assert.Contains(t, create, `PRIMARY KEY ("id")`)
+	assert.NotContains(t, create, "character varying")
+	assert.NotContains(t, create, "[]")
+}
+
+func TestSqlite_CreateTableRefusesFullTextSearch(t *testing.T) {
+	_, _, err := NewSqliteQueryBuilder().SqlCreateTable(fullTextSchema(false), dmodel.GetSchemaRegistry())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "full-text search requires PostgreSQL")
+}
+
+func TestSqlite_SelectRefusesTextQuery(t *testing.T) {
+	schema, registry := sqliteItemSchemaOf(t)
+
+	_, _, err := NewSqliteQueryBuilder().SqlSelectGraph(schema, registry, nil, SqlSelectGraphOpts{TextQuery: "x"})
+	require.Error(t, err)
+
+	_, _, err = NewSqliteQueryBuilder().SqlCountGraph(schema, registry, nil, SqlSelectGraphOpts{TextQuery: "x"})
+	require.Error(t, err)
+}
+
+// Arrays are written as PostgreSQL array text, the form the model's array scanners read back.
+func TestSqlite_InsertWritesArraysAndJsonAsText(t *testing.T) {
+	schema, _ := sqliteItemSchemaOf(t)
+
+	sql, cErrs, err := NewSqliteQueryBuilder().SqlInsertBulk(schema, []dmodel.DynamicFields{
+		{"id": "01A", "tags": []string{"a", `b"c`}, "scores": []int64{1, 2}, "meta": map[string]any{"k": "v"}},
+		{"id": "01B", "tags": []string{}, "scores": []int64{3}, "meta": map[string]any{}},
+	}, true)
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Equal(t,
+		`INSERT INTO "test_sqlite_items" ("id", "meta", "scores", "tags") VALUES `+
+			`('01A', '{"k":"v"}', '{"1","2"}', '{"a","b\"c"}'), ('01B', '{}', '{"3"}', '{}')  ON CONFLICT DO NOTHING`,
+		*sql)
+}
+
+func TestSqlite_DateTimeIsWrittenAsSortableUtcText(t *testing.T) {
+	schema, _ := sqliteItemSchemaOf(t)
+	dueAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("ICT", 7*3600))
+
+	sql, cErrs, err := NewSqliteQueryBuilder().SqlInsert(schema, dmodel.DynamicFields{
+		"id": "01A", "due_at": cmodel.WrapModelDateTime(dueAt), "due_date": "2026-03-01",
+	}, false)
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Contains(t, *sql, `VALUES ('2026-03-01 02:30:00.000000', '2026-03-01', '01A')`)
+}
+
+func TestSqlite_StringOperatorsUseLike(t *testing.T) {
+	schema, registry := sqliteItemSchemaOf(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("name", dmodel.Contains, "pen")
+
+	sql := sqliteSelectSql(t, schema, registry, graph, SqlSelectGraphOpts{})
+
+	assert.Contains(t, sql, `WHERE "name" LIKE '%pen%'`)
+	assert.NotContains(t, sql, "ILIKE")
+}
+
+func TestSqlite_LangJsonFilterReadsOneTranslation(t *testing.T) {
+	schema, registry := sqliteItemSchemaOf(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("title", dmodel.StartsWith, "Pen")
+	language := cmodel.LanguageCode("en-US")
+
+	sql := sqliteSelectSql(t, schema, registry, graph, SqlSelectGraphOpts{Language: &language})
+	assert.Contains(t, sql, `WHERE json_extract("title", '$."en-US"') LIKE 'Pen%'`)
+
+	sql = sqliteSelectSql(t, schema, registry, graph, SqlSelectGraphOpts{})
+	assert.Contains(t, sql, `WHERE ("title") LIKE 'Pen%'`)
+}
+
+// SQLite sorts NULLs first ascending; the keyset seek assumes PostgreSQL's order, so it is spelled out.
+func TestSqlite_KeysetSpellsOutNullOrder(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+	graph := dmodel.NewSearchGraph()
+	graph.OrderBy("code", dmodel.Asc)
+
+	sql := sqliteSelectSql(t, schema, registry, graph, SqlSelectGraphOpts{
+		Columns: ToSelectColumns([]string{"id", "code"}),
+		Size:    20,
+		Keyset:  &KeysetOpts{},
+	})
+
+	assert.Contains(t, sql, `CAST("code" AS TEXT) AS "_keyset_0"`)
+	assert.Contains(t, sql, `ORDER BY "code" ASC NULLS LAST, "id" ASC NULLS LAST LIMIT 20`)
+}
+
+// Under DISTINCT the sort column is projected as well, and there only the column is valid SQL.
+func TestSqlite_DistinctProjectsTheSortColumnWithoutItsNullOrder(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("children.label", dmodel.Equals, "x")
+	graph.OrderBy("code", dmodel.Desc)
+
+	sql := sqliteSelectSql(t, schema, registry, graph, SqlSelectGraphOpts{
+		Columns: ToSelectColumns([]string{"id"}),
+	})
+
+	assert.Regexp(t, `^SELECT DISTINCT \w+\."id", \w+\."code" FROM `, sql)
+	assert.Regexp(t, `ORDER BY \w+\."code" DESC NULLS FIRST$`, sql)
+}
+
+func TestSqlite_FanOutJoinIsCountedDistinct(t *testing.T) {
+	schema, registry := distinctSchemas(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("children.label", dmodel.Equals, "x")
+
+	sql, cErrs, err := NewSqliteQueryBuilder().SqlCountGraph(schema, registry, graph, SqlSelectGraphOpts{})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Regexp(t, `^SELECT COUNT\(\*\) FROM \(SELECT DISTINCT .* LEFT JOIN "test_distinct_children" AS \w+ ON `, *sql)
+	assert.Contains(t, *sql, `."label" = 'x'`, "SQLite string literals have no E prefix")
+}
+
+func TestSqlite_AggregateTruncatesWithDateFunctions(t *testing.T) {
+	schema, registry := sqliteItemSchemaOf(t)
+
+	sql, cErrs, err := NewSqliteQueryBuilder().SqlAggregateGraph(schema, registry, nil, SqlAggregateGraphOpts{
+		GroupBy: []AggregateGroupBy{
+			{Field: "due_date", Trunc: DateTruncWeek},
+			{Field: "due_at", Trunc: DateTruncMonth},
+		},
+		Measures: []AggregateMeasure{{Name: "items", Function: computed.AggCount}},
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Contains(t, *sql, `date("due_date", '-6 days', 'weekday 1') AS "due_date:week"`)
+	assert.Contains(t, *sql, `datetime(date("due_at", 'start of month')) AS "due_at:month"`)
+	assert.NotContains(t, *sql, "date_trunc")
+}
+
+func TestSqlite_ExistsManyUsesNumberedPlaceholders(t *testing.T) {
+	schema, _ := sqliteItemSchemaOf(t)
+
+	data, cErrs, err := NewSqliteQueryBuilder().SqlExistsMany(schema, []dmodel.DynamicFields{
+		{"id": "01A"}, {"id": "01B"},
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+	assert.Equal(t,
+		`SELECT CASE WHEN EXISTS (SELECT 1 FROM "test_sqlite_items" WHERE "id" = ?1) THEN 1 ELSE 0 END UNION ALL `+
+			`SELECT CASE WHEN EXISTS (SELECT 1 FROM "test_sqlite_items" WHERE "id" = ?2) THEN 1 ELSE 0 END`,
+		data.Sql)
+	assert.Equal(t, []any{"01A", "01B"}, data.Args)
+}
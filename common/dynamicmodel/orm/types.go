@@ -43,7 +43,7 @@ type SqlSelectGraphOpts struct {
 	// Columns limits which columns to fetch; empty means all columns (*).
 	Columns []SelectColumn
 	// Page is the 0-based page index used to compute the OFFSET (OFFSET = Page * Size).
-	// Ignored when Size is 0.
+	// Ignored when Size is 0 or Keyset is set.
 	Page int
 	// Size sets the LIMIT clause. 0 means no limit/offset is applied.
 	Size int
@@ -52,6 +52,12 @@ type SqlSelectGraphOpts struct {
 	// ComputedContext binds the request's whitelisted context values for SQL-computed fields
 	// (aggregate/exists/lookup). Consumed when the projection names such a field.
 	ComputedContext map[string]any
+	// Keyset, when set, pages by seeking past a sort key instead of by OFFSET; see KeysetOpts.
+	Keyset *KeysetOpts
+	// TextQuery, when set, keeps the rows matching it in the schema's full-text search and orders
+	// them by relevance ahead of the graph's order, projecting the score as SearchRankColumn.
+	// A score makes no stable seek key, so it cannot be combined with Keyset.
+	TextQuery string
 }
 
 // SqlCheckUniqueCollisionsData holds parameterized SQL and arguments from SqlCheckUniqueCollisions.
@@ -79,6 +85,12 @@ type QueryBuilder interface {
 		schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlSelectGraphOpts,
 	) (
 		*string, *ft.ClientErrors, error)
+	// SqlAggregateGraph builds a GROUP BY query over the rows the graph selects, one result row per
+	// group carrying the group keys and the measures; see SqlAggregateGraphOpts.
+	SqlAggregateGraph(
+		schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry, graph *dmodel.SearchGraph, opts SqlAggregateGraphOpts,
+	) (
+		*string, *ft.ClientErrors, error)
 	// SqlInsert builds INSERT for one row. When onConflictPkDoNothing is true, appends
 	// ON CONFLICT (<schema primary keys>) DO NOTHING.
 	SqlInsert(schema *dmodel.ModelSchema, data dmodel.DynamicFields, ignoreConflict bool) (
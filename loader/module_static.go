@@ -10,6 +10,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/apptrait"
 	"github.com/sky-as-code/nikki-erp/modules/contacts"
 	"github.com/sky-as-code/nikki-erp/modules/core"
+	"github.com/sky-as-code/nikki-erp/modules/document"
 	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
 	"github.com/sky-as-code/nikki-erp/modules/essential"
 	"github.com/sky-as-code/nikki-erp/modules/iam"
@@ -19,6 +20,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/inventory"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice"
 	"github.com/sky-as-code/nikki-erp/modules/purchase"
+	"github.com/sky-as-code/nikki-erp/modules/webhook"
 )
 
 type StaticModuleLoader struct {
@@ -45,6 +47,7 @@ func (this StaticModuleLoader) getStaticModules() []modules.InCodeModule {
 		essential.ModuleSingleton,
 		core.ModuleSingleton,
 		contacts.ModuleSingleton,
+		document.ModuleSingleton,
 		dynamicresource.ModuleSingleton,
 		// helpdesk.ModuleSingleton,
 		iam.ModuleSingleton,
@@ -52,6 +55,7 @@ func (this StaticModuleLoader) getStaticModules() []modules.InCodeModule {
 		paymentinvoice.ModuleSingleton,
 		purchase.ModuleSingleton,
 		settings.ModuleSingleton,
+		webhook.ModuleSingleton,
 	}
 
 	return modules
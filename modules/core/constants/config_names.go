@@ -17,6 +17,7 @@ const (
 	DbMaxIdleConns        ConfigName = "CORE.DB.MAX_IDLE_CONNS"
 	DbMaxOpenConns        ConfigName = "CORE.DB.MAX_OPEN_CONNS"
 	DbConnMaxLifetimeSecs ConfigName = "CORE.DB.CONN_MAX_LIFETIME_SECS"
+	DbCursorSecret        ConfigName = "CORE.DB.CURSOR_SECRET"
 
 	// Database Postgres-specific
 	// DbPgSslMode ConfigName = "DB_PG_SSL_MODE"
@@ -59,6 +60,15 @@ const (
 	HttpCorsHeaders    ConfigName = "CORE.HTTP.CORS_HEADERS"
 	HttpCorsMethods    ConfigName = "CORE.HTTP.CORS_METHODS"
 
+	// Cron job runs
+	JobRunRetentionDays ConfigName = "CORE.JOB_RUNS.RETENTION_DAYS"
+
+	// Job queue
+	JobQueueWorkers         ConfigName = "CORE.JOB_QUEUE.WORKERS"
+	JobQueuePollIntervalMs  ConfigName = "CORE.JOB_QUEUE.POLL_INTERVAL_MS"
+	JobQueueBackoffBaseSecs ConfigName = "CORE.JOB_QUEUE.BACKOFF_BASE_SECS"
+	JobQueueBackoffCapSecs  ConfigName = "CORE.JOB_QUEUE.BACKOFF_CAP_SECS"
+
 	//Pub sub Redis
 	PubSubRedisHost     ConfigName = "CORE.PUB_SUB.REDIS_HOST"
 	PubSubRedisPort     ConfigName = "CORE.PUB_SUB.REDIS_PORT"
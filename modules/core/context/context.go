@@ -2,6 +2,7 @@ package context
 
 import (
 	"context"
+	"time"
 
 	"github.com/labstack/echo/v5"
 	"go.bryk.io/pkg/errors"
@@ -86,6 +87,18 @@ func CloneRequestContext(ctx Context) Context {
 	}
 }
 
+// DetachRequestContext is CloneRequestContext for work that outlives the request, such as an
+// export finishing in the background. The copy keeps the caller's identity and context values,
+// but is not cancelled when the request ends, only when timeout passes or cancel is called. It
+// carries no transaction: the request commits or rolls back its own before the work is done.
+func DetachRequestContext(ctx Context, timeout time.Duration) (Context, context.CancelFunc) {
+	inner, cancel := context.WithTimeout(context.WithoutCancel(ctx.InnerContext()), timeout)
+	detached := CloneRequestContext(ctx).(*RequestContext)
+	detached.Context = inner
+	detached.repoTrx = nil
+	return detached, cancel
+}
+
 // Returns pointer to an instance of RequestContext if it exists, otherwise returns an error.
 func AsRequestContext(echoCtx *echo.Context) (Context, error) {
 	reqCtx, isReqCtx := echoCtx.Request().Context().(Context)
@@ -3,6 +3,7 @@ package context
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -95,3 +96,23 @@ func TestCloneOfAnEmptyContext(t *testing.T) {
 	assert.Empty(t, clone.GetModuleName())
 	assert.Equal(t, model.Id(""), clone.GetPermissions().UserId)
 }
+
+// A detached context serves work that goes on after the response is sent, so ending the request
+// must not end it; the identity it runs under must still be the caller's.
+func TestDetachOutlivesTheRequest(t *testing.T) {
+	inner, endRequest := context.WithCancel(context.Background())
+	original := NewRequestContextM(inner, "inventory")
+	original.SetDbTranx(&fakeDbTransaction{})
+	original.SetPermissions(ContextPermissions{UserId: model.Id("01JQZ0X0000000000000000001")})
+
+	detached, cancel := DetachRequestContext(original, time.Minute)
+	defer cancel()
+	endRequest()
+
+	assert.Error(t, original.Err())
+	assert.NoError(t, detached.Err(), "the request ending must not cancel the detached work")
+	assert.Equal(t, model.Id("01JQZ0X0000000000000000001"), detached.GetPermissions().UserId)
+	assert.Nil(t, detached.GetDbTranx(), "the request's transaction is done with before the work is")
+	_, hasDeadline := detached.Deadline()
+	assert.True(t, hasDeadline)
+}
@@ -29,6 +29,14 @@ func InitDbClient(opts ClientOptions) (orm.DbClient, error) {
 		setConnOptions(conn, opts)
 		return orm.NewPgClient(conn), nil
 
+	case orm.DialectSqlite:
+		conn, err := sql.Open(orm.DialectSqlite, buildSqliteDsn(opts.DialectOptions))
+		if err != nil {
+			return nil, err
+		}
+		setConnOptions(conn, opts)
+		return orm.NewSqliteClient(conn), nil
+
 	default:
 		return nil, errors.Errorf("unsupported dialect: %s", opts.DialectName)
 	}
@@ -65,6 +73,9 @@ func OpenConnection(opts ClientOptions) (*sql.DB, error) {
 	case orm.DialectPostgres:
 		dsn = buildPgDsn(opts.DialectOptions)
 		conn, err = sql.Open(orm.DialectPostgres, dsn)
+	case orm.DialectSqlite:
+		dsn = buildSqliteDsn(opts.DialectOptions)
+		conn, err = sql.Open(orm.DialectSqlite, dsn)
 	default:
 		return nil, errors.Errorf("unsupported dialect: %s", opts.DialectName)
 	}
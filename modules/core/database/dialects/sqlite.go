@@ -0,0 +1,15 @@
+package dialects
+
+import (
+	"fmt"
+)
+
+// buildSqliteDsn opens the database file named by Database. ":memory:" gives a throwaway one,
+// but each pooled connection opens its own, so it needs CORE.DB.MAX_OPEN_CONNS at 1. Host, user,
+// password and TLS do not apply to an embedded database.
+//
+// SQLite leaves foreign keys unenforced unless asked, and fails a write at once when another
+// connection holds the lock rather than waiting for it.
+func buildSqliteDsn(opts DialectOptions) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000", opts.Database)
+}
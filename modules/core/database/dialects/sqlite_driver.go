@@ -0,0 +1,8 @@
+// The SQLite driver is built with cgo. Without cgo it still compiles, and opening a database
+// fails with an error saying cgo is needed, so the default build carries it either way. To run
+// on SQLite, set CORE.DB.DIALECT to "sqlite3" and CORE.DB.DATABASE to the database file.
+package dialects
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
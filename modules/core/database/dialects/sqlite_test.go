@@ -0,0 +1,177 @@
+package dialects
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	cmodel "github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// The SQLite query builder's own tests only read the SQL it writes. These run that SQL on an
+// in-memory database through the driver this package registers, so a statement SQLite does not
+// accept, or a value it stores in a form the filters cannot match, fails here rather than at an
+// installation running on SQLite.
+
+const (
+	sqliteShelfSchema = "test_sqlite_db_shelf"
+	sqliteBookSchema  = "test_sqlite_db_book"
+)
+
+func sqliteShelfSchemaOf(t *testing.T) (*dmodel.ModelSchema, *dmodel.ModelSchema, *dmodel.SchemaRegistry) {
+	t.Helper()
+	registry := dmodel.GetSchemaRegistry()
+	if registry.Get(sqliteShelfSchema) != nil {
+		return registry.Get(sqliteShelfSchema), registry.Get(sqliteBookSchema), registry
+	}
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(sqliteShelfSchema).
+			TableName("test_sqlite_db_shelves").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+			Field(dmodel.DefineField().Name("title").
+				DataType(dmodel.FieldDataTypeLangJson(0, 50))).
+			Field(dmodel.DefineField().Name("tags").
+				DataType(dmodel.FieldDataTypeString(0, 50).ArrayType())).
+			EdgeFrom(dmodel.Edge("books").Existing(sqliteBookSchema, "shelf"))))
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(sqliteBookSchema).
+			TableName("test_sqlite_db_books").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate().PrimaryKey()).
+			Field(dmodel.DefineField().Name("shelf_id").
+				DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate()).
+			Field(dmodel.DefineField().Name("name").
+				DataType(dmodel.FieldDataTypeString(0, 50))).
+			EdgeTo(dmodel.Edge("shelf").ManyToOne(
+				sqliteShelfSchema, dmodel.DynamicFields{"shelf_id": "id"}))))
+
+	require.NoError(t, registry.FinalizeRelations())
+	return registry.Get(sqliteShelfSchema), registry.Get(sqliteBookSchema), registry
+}
+
+// openSqliteShelves creates both tables in a fresh in-memory database and stores two shelves
+// with a book each. The pool is held to one connection that is never closed, since every
+// connection to ":memory:" opens a database of its own.
+func openSqliteShelves(t *testing.T) (orm.DbClient, *dmodel.ModelSchema, *dmodel.SchemaRegistry) {
+	t.Helper()
+	shelfSchema, bookSchema, registry := sqliteShelfSchemaOf(t)
+	client, err := InitDbClient(ClientOptions{
+		DialectOptions: DialectOptions{Database: ":memory:"},
+		DialectName:    orm.DialectSqlite,
+		MaxIdleConns:   1,
+		MaxOpenConns:   1,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	builder := orm.NewSqliteQueryBuilder()
+	for _, schema := range []*dmodel.ModelSchema{shelfSchema, bookSchema} {
+		statements, cErrs, err := builder.SqlCreateTable(schema, registry)
+		require.NoError(t, err)
+		require.Nil(t, cErrs)
+		for _, statement := range statements {
+			_, err = client.Exec(context.Background(), statement)
+			require.NoError(t, err, statement)
+		}
+	}
+
+	sqliteInsert(t, client, shelfSchema,
+		dmodel.DynamicFields{"id": "01SHELFA", "title": cmodel.LangJson{"en-US": "Poetry", "vi-VN": "Thơ"},
+			"tags": []string{"a", "b"}},
+		dmodel.DynamicFields{"id": "01SHELFB", "title": cmodel.LangJson{"en-US": "Prose"}, "tags": []string{"b"}},
+	)
+	sqliteInsert(t, client, bookSchema,
+		dmodel.DynamicFields{"id": "01BOOKA", "shelf_id": "01SHELFA", "name": "Odes"},
+		dmodel.DynamicFields{"id": "01BOOKB", "shelf_id": "01SHELFB", "name": "Essays"},
+	)
+	return client, shelfSchema, registry
+}
+
+func sqliteInsert(t *testing.T, client orm.DbClient, schema *dmodel.ModelSchema, rows ...dmodel.DynamicFields) {
+	t.Helper()
+	for _, row := range rows {
+		statement, cErrs, err := orm.NewSqliteQueryBuilder().SqlInsert(schema, row, false)
+		require.NoError(t, err)
+		require.Nil(t, cErrs)
+		_, err = client.Exec(context.Background(), *statement)
+		require.NoError(t, err, *statement)
+	}
+}
+
+// sqliteSelectIds runs a graph select over the shelves and returns the ids it found, in order.
+func sqliteSelectIds(
+	t *testing.T, client orm.DbClient, schema *dmodel.ModelSchema, registry *dmodel.SchemaRegistry,
+	graph *dmodel.SearchGraph, opts orm.SqlSelectGraphOpts,
+) []string {
+	t.Helper()
+	opts.Columns = orm.ToSelectColumns([]string{"id"})
+	graph.OrderBy("id", dmodel.Asc)
+	statement, cErrs, err := orm.NewSqliteQueryBuilder().SqlSelectGraph(schema, registry, graph, opts)
+	require.NoError(t, err)
+	require.Nil(t, cErrs)
+
+	rows, err := client.Query(context.Background(), *statement)
+	require.NoError(t, err, *statement)
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	return ids
+}
+
+func TestSqlite_SelectJoinsAnEdge(t *testing.T) {
+	client, schema, registry := openSqliteShelves(t)
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("books.name", dmodel.Equals, "Odes")
+
+	assert.Equal(t, []string{"01SHELFA"},
+		sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{}))
+}
+
+func TestSqlite_LangJsonFilterMatchesOneTranslation(t *testing.T) {
+	client, schema, registry := openSqliteShelves(t)
+	english := cmodel.LanguageCode("en-US")
+	vietnamese := cmodel.LanguageCode("vi-VN")
+
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("title", dmodel.StartsWith, "P")
+	assert.Equal(t, []string{"01SHELFA", "01SHELFB"},
+		sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{Language: &english}))
+
+	graph = dmodel.NewSearchGraph()
+	graph.NewCondition("title", dmodel.StartsWith, "P")
+	assert.Empty(t, sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{Language: &vietnamese}))
+
+	graph = dmodel.NewSearchGraph()
+	graph.NewCondition("title", dmodel.Contains, "Thơ")
+	assert.Equal(t, []string{"01SHELFA"},
+		sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{}))
+}
+
+// An array is stored as PostgreSQL array text, and a filter on it compares the same text.
+func TestSqlite_ArrayFilterMatchesTheStoredArray(t *testing.T) {
+	client, schema, registry := openSqliteShelves(t)
+
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition("tags", dmodel.Equals, []string{"a", "b"})
+	assert.Equal(t, []string{"01SHELFA"},
+		sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{}))
+
+	graph = dmodel.NewSearchGraph()
+	graph.NewCondition("tags", dmodel.NotEquals, []string{"a", "b"})
+	assert.Equal(t, []string{"01SHELFB"},
+		sqliteSelectIds(t, client, schema, registry, graph, orm.SqlSelectGraphOpts{}))
+}
@@ -65,7 +65,9 @@ func InitSubModule(params InitParams) error {
 		deps.Register(func() (orm.DbClient, error) {
 			return dialects.InitDbClient(entDriverOpts)
 		}),
-		deps.Register(orm.NewPgQueryBuilder),
+		deps.Register(func() (orm.QueryBuilder, error) {
+			return orm.NewQueryBuilder(dbDialect)
+		}),
 		deps.Register(func() *EntClientOptions {
 			return &EntClientOptions{
 				Driver:       driver,
@@ -43,6 +43,12 @@ const (
 	// field name, so reusing the column name would make the query field collide with it.
 	FieldIncludeArchived = "include_archived"
 
+	// FieldCursor is the search query parameter carrying the previous page's next_cursor.
+	FieldCursor = "cursor"
+
+	// FieldTextQuery is the search query parameter carrying a full-text query.
+	FieldTextQuery = "q"
+
 	// FieldContext is the search query parameter carrying whitelisted context values for
 	// SQL-computed fields (aggregate/exists/lookup), not a model column.
 	FieldContext = "context"
@@ -33,6 +33,7 @@ func NewBaseDynamicRepository(param dyn.NewBaseRepoParam) dyn.BaseDynamicReposit
 		logger:          param.Logger,
 		schema:          param.Schema,
 		sqlDebugEnabled: sqlDebugEnabled,
+		cursorCodec:     searchCursorCodec{secret: []byte(param.ConfigSvc.GetStr(c.DbCursorSecret))},
 	}
 }
 
@@ -42,6 +43,7 @@ type BaseDynamicRepositoryImpl struct {
 	logger          logging.LoggerService
 	schema          *dmodel.ModelSchema
 	sqlDebugEnabled bool
+	cursorCodec     searchCursorCodec
 }
 
 func (this *BaseDynamicRepositoryImpl) Schema() *dmodel.ModelSchema {
@@ -150,6 +152,17 @@ func (this *BaseDynamicRepositoryImpl) CheckUniqueCollisions(ctx corectx.Context
 
 func (this *BaseDynamicRepositoryImpl) DeleteOne(
 	ctx corectx.Context, keys dmodel.DynamicFields,
+) (*dyn.OpResult[int], error) {
+	if this.schema.TrackHistory() {
+		return withChangeTransaction(this, ctx, func(tranxCtx corectx.Context) (*dyn.OpResult[int], error) {
+			return this.deleteOneTracked(tranxCtx, keys)
+		})
+	}
+	return this.deleteOne(ctx, keys)
+}
+
+func (this *BaseDynamicRepositoryImpl) deleteOne(
+	ctx corectx.Context, keys dmodel.DynamicFields,
 ) (*dyn.OpResult[int], error) {
 	keys = injectTenantFilter(ctx, this.schema, keys)
 	if err := this.validateKeyMap(keys); err != nil {
@@ -248,6 +261,17 @@ func scanExistsManyRows(rows *sql.Rows, keys []dmodel.DynamicFields) (*dyn.OpRes
 // On success, Data holds the inserted field map; HasData is true when Data is non-nil.
 func (this *BaseDynamicRepositoryImpl) Insert(ctx corectx.Context, data dmodel.DynamicFields) (
 	*dyn.OpResult[int], error,
+) {
+	if this.schema.TrackHistory() {
+		return withChangeTransaction(this, ctx, func(tranxCtx corectx.Context) (*dyn.OpResult[int], error) {
+			return this.insertTracked(tranxCtx, data)
+		})
+	}
+	return this.insert(ctx, data)
+}
+
+func (this *BaseDynamicRepositoryImpl) insert(ctx corectx.Context, data dmodel.DynamicFields) (
+	*dyn.OpResult[int], error,
 ) {
 	// TODO: Extract later
 	// if err := this.ensureTenantKeyIn(data); err != nil {
@@ -353,7 +377,7 @@ func (this *BaseDynamicRepositoryImpl) getOneWithNestedColumns(
 		Fields: plan.MainColumns,
 		Page:   0,
 		Size:   1,
-	})
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -460,7 +484,7 @@ func (this *BaseDynamicRepositoryImpl) CountM2m(
 		filter.Merge(constraints)
 	}
 	graph := filterToAndGraph(filter)
-	total, countClientErrs, err := this.countRowsMatchingGraphOnSchema(ctx, link.ThroughSchema, graph, nil, nil)
+	total, countClientErrs, err := this.countRowsMatchingGraphOnSchema(ctx, link.ThroughSchema, graph, nil, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -783,6 +807,7 @@ func appendMissingKeyErrors(errs *ft.ClientErrors, fieldPrefix string, keys dmod
 // When the schema is tenant-scoped, the tenant key is automatically injected from ctx domain constraints.
 // When param.IncludeArchived is false, "is_archived = false" is prepended to the graph.
 // Data uses PagedResult: Total is from COUNT when Size > 0, otherwise len(Items).
+// A first page, and any page reached through param.Cursor, carries NextCursor unless it is the last.
 func (this *BaseDynamicRepositoryImpl) Search(ctx corectx.Context, param dyn.RepoSearchParam) (
 	*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error,
 ) {
@@ -793,6 +818,12 @@ func (this *BaseDynamicRepositoryImpl) Search(ctx corectx.Context, param dyn.Rep
 			ClientErrors: ft.ClientErrors{*vErr},
 		}, nil
 	}
+	param.TextQuery = strings.TrimSpace(param.TextQuery)
+	if tErr := this.validateTextQuery(param); tErr != nil {
+		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+			ClientErrors: ft.ClientErrors{*tErr},
+		}, nil
+	}
 	// Injected before the branch so both the plain and the nested-column paths inherit it.
 	// param is a value copy, and searchWithNestedColumns also takes one, so this cannot
 	// leak back to the caller.
@@ -802,11 +833,17 @@ func (this *BaseDynamicRepositoryImpl) Search(ctx corectx.Context, param dyn.Rep
 		return this.searchWithNestedColumns(ctx, param)
 	}
 	merged := this.injectTenantIntoGraph(ctx, param.Graph)
+	paging, pagingErr := this.pagingFor(merged, param)
+	if pagingErr != nil {
+		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+			ClientErrors: ft.ClientErrors{*pagingErr},
+		}, nil
+	}
 	page := param.Page
 	size := param.Size
 	var total int
 	total, countClientErrs, err := this.countRowsMatchingGraph(
-		ctx, merged, param.Language, this.ensurePrimaryKeyColumns(param.Fields))
+		ctx, merged, param.Language, param.TextQuery, this.ensurePrimaryKeyColumns(param.Fields))
 	if err != nil {
 		return nil, err
 	}
@@ -816,11 +853,12 @@ func (this *BaseDynamicRepositoryImpl) Search(ctx corectx.Context, param dyn.Rep
 		}, nil
 	}
 	rows, scanClientErrs, err := this.runSelectGraphScan(ctx, merged, dyn.RepoSearchParam{
-		Fields:   this.ensurePrimaryKeyColumns(param.Fields),
-		Page:     param.Page,
-		Size:     param.Size,
-		Language: param.Language,
-	})
+		Fields:    this.ensurePrimaryKeyColumns(param.Fields),
+		Page:      param.Page,
+		Size:      param.Size,
+		Language:  param.Language,
+		TextQuery: param.TextQuery,
+	}, paging.keyset)
 	if err != nil {
 		return nil, err
 	}
@@ -829,14 +867,19 @@ func (this *BaseDynamicRepositoryImpl) Search(ctx corectx.Context, param dyn.Rep
 			ClientErrors: scanClientErrs,
 		}, nil
 	}
+	nextCursor, err := this.nextSearchCursor(rows, paging, size)
+	if err != nil {
+		return nil, err
+	}
 	if size <= 0 {
 		total = len(rows)
 	}
 	paged := dyn.PagedResultData[dmodel.DynamicFields]{
-		Items: rows,
-		Total: total,
-		Page:  page,
-		Size:  size,
+		Items:      rows,
+		Total:      total,
+		Page:       page,
+		Size:       size,
+		NextCursor: nextCursor,
 	}
 	return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
 		Data:    paged,
@@ -852,7 +895,14 @@ func (this *BaseDynamicRepositoryImpl) searchWithNestedColumns(
 		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{ClientErrors: cErrs}, nil
 	}
 	merged := this.injectTenantIntoGraph(ctx, param.Graph)
-	total, countClientErrs, err := this.countRowsMatchingGraph(ctx, merged, param.Language, plan.MainColumns)
+	paging, pagingErr := this.pagingFor(merged, param)
+	if pagingErr != nil {
+		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+			ClientErrors: ft.ClientErrors{*pagingErr},
+		}, nil
+	}
+	total, countClientErrs, err := this.countRowsMatchingGraph(
+		ctx, merged, param.Language, param.TextQuery, plan.MainColumns)
 	if err != nil {
 		return nil, err
 	}
@@ -862,11 +912,12 @@ func (this *BaseDynamicRepositoryImpl) searchWithNestedColumns(
 		}, nil
 	}
 	rows, scanClientErrs, err := this.runSelectGraphScan(ctx, merged, dyn.RepoSearchParam{
-		Fields:   plan.MainColumns,
-		Page:     param.Page,
-		Size:     param.Size,
-		Language: param.Language,
-	})
+		Fields:    plan.MainColumns,
+		Page:      param.Page,
+		Size:      param.Size,
+		Language:  param.Language,
+		TextQuery: param.TextQuery,
+	}, paging.keyset)
 	if err != nil {
 		return nil, err
 	}
@@ -875,6 +926,10 @@ func (this *BaseDynamicRepositoryImpl) searchWithNestedColumns(
 			ClientErrors: scanClientErrs,
 		}, nil
 	}
+	nextCursor, err := this.nextSearchCursor(rows, paging, param.Size)
+	if err != nil {
+		return nil, err
+	}
 	if err := this.hydrateNestedEdgesForRows(ctx, rows, plan.EdgeLeafColumns); err != nil {
 		return nil, err
 	}
@@ -882,10 +937,11 @@ func (this *BaseDynamicRepositoryImpl) searchWithNestedColumns(
 		total = len(rows)
 	}
 	paged := dyn.PagedResultData[dmodel.DynamicFields]{
-		Items: rows,
-		Total: total,
-		Page:  param.Page,
-		Size:  param.Size,
+		Items:      rows,
+		Total:      total,
+		Page:       param.Page,
+		Size:       param.Size,
+		NextCursor: nextCursor,
 	}
 	return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
 		Data:    paged,
@@ -893,6 +949,118 @@ func (this *BaseDynamicRepositoryImpl) searchWithNestedColumns(
 	}, nil
 }
 
+// searchPaging is how one search pages: by keyset when keyset is set, by OFFSET otherwise.
+type searchPaging struct {
+	keyset *orm.KeysetOpts
+	order  dmodel.SearchOrder
+}
+
+// pagingFor seeks by keyset on a first page and on every page reached through a cursor, which
+// is what lets each of them hand out the next cursor. A page picked by number keeps to OFFSET, as
+// the cursor of the page before it is not known.
+func (this *BaseDynamicRepositoryImpl) pagingFor(
+	graph *dmodel.SearchGraph, param dyn.RepoSearchParam,
+) (searchPaging, *ft.ClientErrorItem) {
+	if param.Cursor != "" && param.Page > 0 {
+		return searchPaging{}, clientErrorCursorWithPage()
+	}
+	// A relevance score makes no seek key; a ranked search always pages by OFFSET.
+	if param.Size <= 0 || param.Page > 0 || param.TextQuery != "" {
+		return searchPaging{}, nil
+	}
+
+	var order dmodel.SearchOrder
+	if graph != nil {
+		order = graph.GetOrder()
+	}
+	paging := searchPaging{keyset: &orm.KeysetOpts{}, order: orm.KeysetOrder(this.schema, order)}
+	if param.Cursor != "" {
+		after, ok := this.cursorCodec.decode(param.Cursor, this.schema.Name(), paging.order)
+		if !ok {
+			return searchPaging{}, clientErrorInvalidCursor()
+		}
+		paging.keyset.After = after
+	}
+	return paging, nil
+}
+
+// nextSearchCursor strips the keyset columns from the rows, and encodes the cursor of the page
+// after them. A short page is the last one, and has none.
+func (this *BaseDynamicRepositoryImpl) nextSearchCursor(
+	rows []dmodel.DynamicFields, paging searchPaging, size int,
+) (string, error) {
+	if paging.keyset == nil {
+		return "", nil
+	}
+	after := takeKeysetValues(rows, len(paging.order))
+	if len(rows) < size {
+		return "", nil
+	}
+	cursor, err := this.cursorCodec.encode(this.schema.Name(), paging.order, after)
+	return cursor, errors.Wrap(err, "nextSearchCursor")
+}
+
+// Aggregate groups the records matching param.Graph and computes the measures per group.
+// The tenant key and the archived filter are injected into the graph exactly as in Search.
+// One group more than param.Limit is fetched, to tell a truncated result from a complete one.
+func (this *BaseDynamicRepositoryImpl) Aggregate(ctx corectx.Context, param dyn.RepoAggregateParam) (
+	*dyn.OpResult[dyn.AggregateResultData], error,
+) {
+	graph := this.injectIsArchivedIntoGraph(param.Graph, param.IncludeArchived)
+	graph = this.injectTenantIntoGraph(ctx, graph)
+	limit := param.Limit
+	if limit > 0 {
+		limit++
+	}
+	sqlQuery, qbClientErrs, err := this.queryBuilder.SqlAggregateGraph(
+		this.schema, dmodel.GetSchemaRegistry(), graph, orm.SqlAggregateGraphOpts{
+			GroupBy:  param.GroupBy,
+			Measures: param.Measures,
+			Having:   param.Having,
+			Limit:    limit,
+			Language: param.Language,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if qbClientErrs != nil && qbClientErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.AggregateResultData]{ClientErrors: *qbClientErrs}, nil
+	}
+
+	this.logQuery(*sqlQuery)
+	rows, err := this.queryAndScan(ctx, *sqlQuery, this.aggregateGroupFields(param.GroupBy))
+	if err != nil {
+		return nil, err
+	}
+	truncated := param.Limit > 0 && len(rows) > param.Limit
+	if truncated {
+		rows = rows[:param.Limit]
+	}
+	if rows == nil {
+		rows = []dmodel.DynamicFields{}
+	}
+	return &dyn.OpResult[dyn.AggregateResultData]{
+		Data:    dyn.AggregateResultData{Items: rows, Truncated: truncated},
+		HasData: len(rows) != 0,
+	}, nil
+}
+
+// aggregateGroupFields maps each group key that is a plain root column to its field, so that its
+// values are converted as a search converts them. Date buckets, joined columns and measures are
+// returned as the driver scans them.
+func (this *BaseDynamicRepositoryImpl) aggregateGroupFields(groups []orm.AggregateGroupBy) map[string]*dmodel.ModelField {
+	out := make(map[string]*dmodel.ModelField, len(groups))
+	for _, group := range groups {
+		if group.Trunc != "" {
+			continue
+		}
+		if field, ok := this.schema.Column(group.Field); ok {
+			out[group.Key()] = field
+		}
+	}
+	return out
+}
+
 type nestedSelectPlan struct {
 	MainColumns     []string
 	EdgeLeafColumns map[string][]string
@@ -1322,16 +1490,17 @@ func (this *BaseDynamicRepositoryImpl) selectRowsByAnyFilter(
 }
 
 func (this *BaseDynamicRepositoryImpl) countRowsMatchingGraph(
-	ctx corectx.Context, graph *dmodel.SearchGraph, language *model.LanguageCode, selectColumns []string,
+	ctx corectx.Context, graph *dmodel.SearchGraph, language *model.LanguageCode, textQuery string,
+	selectColumns []string,
 ) (int, ft.ClientErrors, error) {
-	return this.countRowsMatchingGraphOnSchema(ctx, this.schema, graph, language, selectColumns)
+	return this.countRowsMatchingGraphOnSchema(ctx, this.schema, graph, language, textQuery, selectColumns)
 }
 
 func (this *BaseDynamicRepositoryImpl) countRowsMatchingGraphOnSchema(
 	ctx corectx.Context, schema *dmodel.ModelSchema, graph *dmodel.SearchGraph, language *model.LanguageCode,
-	selectColumns []string,
+	textQuery string, selectColumns []string,
 ) (int, ft.ClientErrors, error) {
-	opts := orm.SqlSelectGraphOpts{Language: language}
+	opts := orm.SqlSelectGraphOpts{Language: language, TextQuery: textQuery}
 	if len(selectColumns) > 0 {
 		opts.Columns = orm.ToSelectColumns(selectColumns)
 	}
@@ -1350,7 +1519,7 @@ func (this *BaseDynamicRepositoryImpl) countRowsMatchingGraphOnSchema(
 }
 
 func (this *BaseDynamicRepositoryImpl) runSelectGraphScan(
-	ctx corectx.Context, graph *dmodel.SearchGraph, param dyn.RepoSearchParam,
+	ctx corectx.Context, graph *dmodel.SearchGraph, param dyn.RepoSearchParam, keyset *orm.KeysetOpts,
 ) ([]dmodel.DynamicFields, ft.ClientErrors, error) {
 	sqlQuery, qbClientErrs, err := this.queryBuilder.SqlSelectGraph(
 		this.schema, dmodel.GetSchemaRegistry(), graph, orm.SqlSelectGraphOpts{
@@ -1359,6 +1528,8 @@ func (this *BaseDynamicRepositoryImpl) runSelectGraphScan(
 			Size:            param.Size,
 			Language:        param.Language,
 			ComputedContext: param.ComputedContext,
+			Keyset:          keyset,
+			TextQuery:       param.TextQuery,
 		})
 	if err != nil {
 		return nil, nil, err
@@ -1375,6 +1546,9 @@ func (this *BaseDynamicRepositoryImpl) runSelectGraphScan(
 	if rows == nil {
 		return []dmodel.DynamicFields{}, nil, nil
 	}
+	if param.TextQuery != "" {
+		dropSearchRank(rows)
+	}
 	return rows, nil, nil
 }
 
@@ -1430,6 +1604,19 @@ func (this *BaseDynamicRepositoryImpl) m2mThroughFilterForLink(
 // If the schema defines "updated_at", sets current UTC timestamp.
 func (this *BaseDynamicRepositoryImpl) Update(ctx corectx.Context, data dmodel.DynamicFields) (
 	*dyn.OpResult[dmodel.DynamicFields], error,
+) {
+	if this.schema.TrackHistory() {
+		return withChangeTransaction(this, ctx, func(tranxCtx corectx.Context) (*dyn.OpResult[dmodel.DynamicFields], error) {
+			return this.updateTracked(tranxCtx, data)
+		})
+	}
+	result, _, err := this.update(ctx, data)
+	return result, err
+}
+
+// update also returns the number of rows it changed, which is zero when the etag no longer matches.
+func (this *BaseDynamicRepositoryImpl) update(ctx corectx.Context, data dmodel.DynamicFields) (
+	*dyn.OpResult[dmodel.DynamicFields], int64, error,
 ) {
 	filters := this.extractKeyMap(data)
 	filters = injectTenantFilter(ctx, this.schema, filters)
@@ -1440,18 +1627,22 @@ func (this *BaseDynamicRepositoryImpl) Update(ctx corectx.Context, data dmodel.D
 	}
 	sqlQuery, qbClientErrs, err := this.queryBuilder.SqlUpdateEqual(this.schema, data, filters)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if qbClientErrs != nil && qbClientErrs.Count() > 0 {
-		return &dyn.OpResult[dmodel.DynamicFields]{ClientErrors: *qbClientErrs}, nil
+		return &dyn.OpResult[dmodel.DynamicFields]{ClientErrors: *qbClientErrs}, 0, nil
 	}
 
 	this.logQuery(*sqlQuery)
-	_, err = this.ExtractClient(ctx).Exec(ctx, *sqlQuery)
+	result, err := this.ExtractClient(ctx).Exec(ctx, *sqlQuery)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
 	}
-	return &dyn.OpResult[dmodel.DynamicFields]{Data: data, HasData: true}, nil
+	return &dyn.OpResult[dmodel.DynamicFields]{Data: data, HasData: true}, n, nil
 }
 
 func (this *BaseDynamicRepositoryImpl) logQuery(query string) {
@@ -0,0 +1,367 @@
+package baserepo
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/common/util"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// historyIgnoredFields change on every update, so recording them would only repeat what the
+// history row itself says: when, and by whom.
+var historyIgnoredFields = map[string]struct{}{
+	basemodel.FieldEtag:      {},
+	basemodel.FieldUpdatedAt: {},
+	basemodel.FieldUpdatedBy: {},
+}
+
+// withChangeTransaction runs a tracked mutation and the history row it writes in one transaction.
+// It joins the caller's transaction when there is one, and otherwise begins its own, on a clone so
+// the committed transaction is not left on the caller's context.
+func withChangeTransaction[T any](
+	this *BaseDynamicRepositoryImpl, ctx corectx.Context,
+	body func(tranxCtx corectx.Context) (*dyn.OpResult[T], error),
+) (*dyn.OpResult[T], error) {
+	if ctx.GetDbTranx() != nil {
+		return body(ctx)
+	}
+
+	tranx, err := this.BeginTransaction(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "withChangeTransaction")
+	}
+	defer tranx.Rollback()
+
+	tranxCtx := corectx.CloneRequestContext(ctx)
+	tranxCtx.SetDbTranx(tranx)
+
+	result, err := body(tranxCtx)
+	if err != nil || result == nil || result.ClientErrors.Count() > 0 {
+		return result, err
+	}
+	if err := tranx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "withChangeTransaction")
+	}
+	return result, nil
+}
+
+func (this *BaseDynamicRepositoryImpl) insertTracked(ctx corectx.Context, data dmodel.DynamicFields) (
+	*dyn.OpResult[int], error,
+) {
+	result, err := this.insert(ctx, data)
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData {
+		return result, err
+	}
+	changes := this.fieldChanges(nil, data)
+	return result, this.recordChange(ctx, dyn.ChangeOperationCreate, data, changes)
+}
+
+func (this *BaseDynamicRepositoryImpl) updateTracked(ctx corectx.Context, data dmodel.DynamicFields) (
+	*dyn.OpResult[dmodel.DynamicFields], error,
+) {
+	keys := this.primaryKeysOf(data)
+	before, err := this.readForHistory(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result, affected, err := this.update(ctx, data)
+	if err != nil || result.ClientErrors.Count() > 0 || affected == 0 || before == nil {
+		return result, err
+	}
+	changes := this.fieldChanges(before, data)
+	if len(changes) == 0 {
+		return result, nil
+	}
+	return result, this.recordChange(ctx, dyn.ChangeOperationUpdate, keys, changes)
+}
+
+func (this *BaseDynamicRepositoryImpl) deleteOneTracked(ctx corectx.Context, keys dmodel.DynamicFields) (
+	*dyn.OpResult[int], error,
+) {
+	before, err := this.readForHistory(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := this.deleteOne(ctx, keys)
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData || before == nil {
+		return result, err
+	}
+	changes := make(map[string]dyn.FieldChange, len(before))
+	for name, change := range this.fieldChanges(nil, before) {
+		changes[name] = dyn.FieldChange{Old: change.New}
+	}
+	return result, this.recordChange(ctx, dyn.ChangeOperationDelete, before, changes)
+}
+
+// readForHistory reads the record as it is before a mutation, every column of it, or nil when the
+// keys match no record of the caller's tenant.
+func (this *BaseDynamicRepositoryImpl) readForHistory(
+	ctx corectx.Context, keys dmodel.DynamicFields,
+) (dmodel.DynamicFields, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	columns := make([]string, 0, len(this.schema.Columns()))
+	for _, field := range this.schema.Columns() {
+		columns = append(columns, field.Name())
+	}
+	rows, err := this.selectRowsByFilter(ctx, this.schema, keys, columns, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "readForHistory")
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return rows[0], nil
+}
+
+func (this *BaseDynamicRepositoryImpl) primaryKeysOf(data dmodel.DynamicFields) dmodel.DynamicFields {
+	keys := make(dmodel.DynamicFields, len(this.schema.PrimaryKeys()))
+	for _, name := range this.schema.PrimaryKeys() {
+		if value, ok := data[name]; ok && value != nil {
+			keys[name] = value
+		}
+	}
+	return keys
+}
+
+// fieldChanges lists the columns of after whose value differs from before. A nil before lists
+// every column after has a value for, as a creation does.
+func (this *BaseDynamicRepositoryImpl) fieldChanges(
+	before dmodel.DynamicFields, after dmodel.DynamicFields,
+) map[string]dyn.FieldChange {
+	changes := map[string]dyn.FieldChange{}
+	for name, newValue := range after {
+		field, isColumn := this.schema.Column(name)
+		if !isColumn || field.IsTenantKey() {
+			continue
+		}
+		if _, ignored := historyIgnoredFields[name]; ignored {
+			continue
+		}
+		var oldValue any
+		if before != nil {
+			oldValue = before[name]
+		}
+		if sameFieldValue(field, oldValue, newValue) {
+			continue
+		}
+		if field.DataType().String() == dmodel.FieldDataTypeNameSecret {
+			changes[name] = dyn.FieldChange{}
+			continue
+		}
+		changes[name] = dyn.FieldChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// sameFieldValue compares two values of a field once converted to its type, through their JSON
+// form, so that "12.5" given by a client matches the 12.50 read from the database.
+func sameFieldValue(field *dmodel.ModelField, left any, right any) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	leftJson, leftErr := json.Marshal(convertForHistory(field, left))
+	rightJson, rightErr := json.Marshal(convertForHistory(field, right))
+	return leftErr == nil && rightErr == nil && string(leftJson) == string(rightJson)
+}
+
+func convertForHistory(field *dmodel.ModelField, value any) any {
+	converted, err := field.DataType().TryConvert(value, field.DataType().Options())
+	if err != nil || converted.Get() == nil {
+		return value
+	}
+	return *converted.Get()
+}
+
+// historyRecordKey is the text a record is found by in the history: its primary key, or for a
+// composite one its key columns as a JSON object, whose keys JSON writes sorted.
+func historyRecordKey(schema *dmodel.ModelSchema, data dmodel.DynamicFields) (string, error) {
+	primaryKeys := schema.PrimaryKeys()
+	if len(primaryKeys) == 0 {
+		return "", errors.Errorf("historyRecordKey: schema '%s' has no primary key", schema.Name())
+	}
+	keys := make(map[string]any, len(primaryKeys))
+	for _, name := range primaryKeys {
+		value, ok := data[name]
+		if !ok || value == nil {
+			return "", errors.Errorf("historyRecordKey: '%s' of schema '%s' is required", name, schema.Name())
+		}
+		keys[name] = value
+	}
+	if len(primaryKeys) == 1 {
+		return fmt.Sprint(keys[primaryKeys[0]]), nil
+	}
+	raw, err := json.Marshal(keys)
+	if err != nil {
+		return "", errors.Wrap(err, "historyRecordKey")
+	}
+	return string(raw), nil
+}
+
+func changeHistorySchema() (*dmodel.ModelSchema, error) {
+	schema := dmodel.GetSchema(dyn.ChangeHistorySchemaName)
+	if schema == nil {
+		return nil, errors.Errorf("schema '%s' is not registered", dyn.ChangeHistorySchemaName)
+	}
+	return schema, nil
+}
+
+// recordChange writes the history row of one mutation, naming the action from the context, and the
+// user from its permissions.
+func (this *BaseDynamicRepositoryImpl) recordChange(
+	ctx corectx.Context, operation string, keySource dmodel.DynamicFields, changes map[string]dyn.FieldChange,
+) error {
+	historySchema, err := changeHistorySchema()
+	if err != nil {
+		return errors.Wrap(err, "recordChange")
+	}
+	recordKey, err := historyRecordKey(this.schema, keySource)
+	if err != nil {
+		return errors.Wrap(err, "recordChange")
+	}
+	id, err := model.NewId()
+	if err != nil {
+		return errors.Wrap(err, "recordChange")
+	}
+	action := dyn.ChangeActionOf(ctx)
+	if action == "" {
+		action = operation
+	}
+	changeMap := make(map[string]any, len(changes))
+	for name, change := range changes {
+		changeMap[name] = change
+	}
+
+	row := dmodel.DynamicFields{
+		dyn.ChangeHistoryFieldId:         *id,
+		dyn.ChangeHistoryFieldSchemaName: this.schema.Name(),
+		dyn.ChangeHistoryFieldRecordKey:  recordKey,
+		dyn.ChangeHistoryFieldOperation:  operation,
+		dyn.ChangeHistoryFieldAction:     action,
+		dyn.ChangeHistoryFieldChangedAt:  model.NewModelDateTime(),
+		dyn.ChangeHistoryFieldChanges:    changeMap,
+	}
+	if actorId := ctx.GetPermissions().UserId; actorId != "" {
+		row[dyn.ChangeHistoryFieldActorId] = actorId
+	}
+
+	sqlQuery, qbClientErrs, err := this.queryBuilder.SqlInsert(historySchema, row, false)
+	if err != nil {
+		return errors.Wrap(err, "recordChange")
+	}
+	if qbClientErrs != nil && qbClientErrs.Count() > 0 {
+		return errors.Wrap(qbClientErrs.ToError(), "recordChange: invalid history row")
+	}
+	this.logQuery(*sqlQuery)
+	_, err = this.ExtractClient(ctx).Exec(ctx, *sqlQuery)
+	return errors.Wrap(err, "recordChange")
+}
+
+func (this *BaseDynamicRepositoryImpl) ChangeHistory(
+	ctx corectx.Context, param dyn.RepoChangeHistoryParam,
+) (*dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]], error) {
+	size := param.Size
+	if size <= 0 {
+		size = model.MODEL_RULE_PAGE_DEFAULT_SIZE
+	}
+	empty := dyn.PagedResultData[dyn.ChangeHistoryEntry]{
+		Items: []dyn.ChangeHistoryEntry{}, Page: param.Page, Size: size,
+	}
+	if !this.schema.TrackHistory() {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{Data: empty}, nil
+	}
+
+	recordKey, err := historyRecordKey(this.schema, param.Keys)
+	if err != nil {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{ClientErrors: ft.ClientErrors{
+			*ft.NewAnonymousValidationError(ft.ErrorKey("err_missing_record_key"), err.Error()),
+		}}, nil
+	}
+	historySchema, err := changeHistorySchema()
+	if err != nil {
+		return nil, errors.Wrap(err, "ChangeHistory")
+	}
+
+	graph := dmodel.NewSearchGraph()
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(dyn.ChangeHistoryFieldSchemaName, dmodel.Equals, this.schema.Name()),
+		*dmodel.NewSearchNode().NewCondition(dyn.ChangeHistoryFieldRecordKey, dmodel.Equals, recordKey),
+	)
+	graph.OrderBy(dyn.ChangeHistoryFieldChangedAt, dmodel.Desc).OrderBy(dyn.ChangeHistoryFieldId, dmodel.Desc)
+
+	total, cErrs, err := this.countRowsMatchingGraphOnSchema(ctx, historySchema, graph, nil, "", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "ChangeHistory")
+	}
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{ClientErrors: cErrs}, nil
+	}
+	if total == 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{Data: empty}, nil
+	}
+
+	sqlQuery, qbClientErrs, err := this.queryBuilder.SqlSelectGraph(
+		historySchema, dmodel.GetSchemaRegistry(), graph, orm.SqlSelectGraphOpts{Page: param.Page, Size: size})
+	if err != nil {
+		return nil, errors.Wrap(err, "ChangeHistory")
+	}
+	if qbClientErrs != nil && qbClientErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{ClientErrors: *qbClientErrs}, nil
+	}
+	this.logQuery(*sqlQuery)
+	rows, err := this.queryAndScan(ctx, *sqlQuery, this.selectFieldsForSchema(historySchema, nil))
+	if err != nil {
+		return nil, errors.Wrap(err, "ChangeHistory")
+	}
+
+	page := empty
+	page.Total = total
+	page.Items = make([]dyn.ChangeHistoryEntry, 0, len(rows))
+	for _, row := range rows {
+		page.Items = append(page.Items, changeHistoryEntryOf(row))
+	}
+	return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{
+		Data: page, HasData: len(page.Items) > 0,
+	}, nil
+}
+
+func changeHistoryEntryOf(row dmodel.DynamicFields) dyn.ChangeHistoryEntry {
+	entry := dyn.ChangeHistoryEntry{
+		Id:        model.Id(historyText(row, dyn.ChangeHistoryFieldId)),
+		Operation: historyText(row, dyn.ChangeHistoryFieldOperation),
+		Action:    historyText(row, dyn.ChangeHistoryFieldAction),
+		Changes:   map[string]dyn.FieldChange{},
+	}
+	if actorId := historyText(row, dyn.ChangeHistoryFieldActorId); actorId != "" {
+		entry.ActorId = util.ToPtr(model.Id(actorId))
+	}
+	if changedAt, ok := row[dyn.ChangeHistoryFieldChangedAt].(model.ModelDateTime); ok {
+		entry.ChangedAt = changedAt
+	}
+	changes, _ := row[dyn.ChangeHistoryFieldChanges].(map[string]any)
+	for name, raw := range changes {
+		change, _ := raw.(map[string]any)
+		entry.Changes[name] = dyn.FieldChange{Old: change["old"], New: change["new"]}
+	}
+	return entry
+}
+
+// historyText reads a text column, which the scan hands back as a string or as a model.Id.
+func historyText(row dmodel.DynamicFields, name string) string {
+	if value := row[name]; value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
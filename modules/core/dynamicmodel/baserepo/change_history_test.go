@@ -0,0 +1,148 @@
+package baserepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	historyRepoSchemaName = "test_baserepo_history"
+	historyLineSchemaName = "test_baserepo_history_line"
+)
+
+// historyRepo builds a repository over a tracked schema holding every kind of field the change
+// list treats apart. The schemas are registered, as that is what resolves their primary keys.
+func historyRepo(t *testing.T) *BaseDynamicRepositoryImpl {
+	t.Helper()
+	registry := dmodel.GetSchemaRegistry()
+	if registry.Get(historyRepoSchemaName) == nil {
+		require.NoError(t, dmodel.RegisterSchemaB(historySchemaBuilder()))
+		require.NoError(t, dmodel.RegisterSchemaB(
+			dmodel.DefineModel(historyLineSchemaName).
+				TableName("test_baserepo_history_lines").
+				ShouldBuildDb().
+				Field(dmodel.DefineField().Name("order_id").
+					DataType(dmodel.FieldDataTypeUlid()).PrimaryKey()).
+				Field(dmodel.DefineField().Name("line_no").
+					DataType(dmodel.FieldDataTypeInt64(1, 1000)).PrimaryKey()).
+				Field(dmodel.DefineField().Name("qty").
+					DataType(dmodel.FieldDataTypeInt64(0, 1000)))))
+	}
+	return &BaseDynamicRepositoryImpl{schema: registry.Get(historyRepoSchemaName)}
+}
+
+func historySchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.DefineModel(historyRepoSchemaName).
+		TableName("test_baserepo_histories").
+		ShouldBuildDb().
+		TrackHistory().
+		Field(dmodel.DefineField().Name("id").
+			DataType(dmodel.FieldDataTypeUlid()).PrimaryKey()).
+		Field(dmodel.DefineField().Name(basemodel.FieldEtag).
+			DataType(dmodel.FieldDataTypeEtag())).
+		Field(dmodel.DefineField().Name(basemodel.FieldUpdatedAt).
+			DataType(dmodel.FieldDataTypeDateTime())).
+		Field(dmodel.DefineField().Name("name").
+			DataType(dmodel.FieldDataTypeString(0, 100))).
+		Field(dmodel.DefineField().Name("price").
+			DataType(dmodel.FieldDataTypeDecimal("0", "1000000", 2))).
+		Field(dmodel.DefineField().Name("password").
+			DataType(dmodel.FieldDataTypeSecret(8, 100)))
+}
+
+func TestFieldChanges_RecordsOnlyWhatMoved(t *testing.T) {
+	repo := historyRepo(t)
+	before := dmodel.DynamicFields{
+		"id": "01J000000000000000000000", "name": "Bolt", "price": "12.50", basemodel.FieldEtag: "1",
+	}
+
+	changes := repo.fieldChanges(before, dmodel.DynamicFields{
+		"id":                     "01J000000000000000000000",
+		"name":                   "Nut",
+		"price":                  "12.5",
+		basemodel.FieldEtag:      "2",
+		basemodel.FieldUpdatedAt: model.NewModelDateTime(),
+		"not_a_column":           "x",
+	})
+
+	assert.Equal(t, map[string]dyn.FieldChange{
+		"name": {Old: "Bolt", New: "Nut"},
+	}, changes, "an equal decimal, the etag and the update stamp are not changes")
+}
+
+func TestFieldChanges_MasksSecrets(t *testing.T) {
+	repo := historyRepo(t)
+
+	changes := repo.fieldChanges(
+		dmodel.DynamicFields{"password": "old-password"},
+		dmodel.DynamicFields{"password": "new-password"},
+	)
+	assert.Equal(t, map[string]dyn.FieldChange{"password": {}}, changes)
+
+	unchanged := repo.fieldChanges(
+		dmodel.DynamicFields{"password": "same-password"},
+		dmodel.DynamicFields{"password": "same-password"},
+	)
+	assert.Empty(t, unchanged)
+}
+
+func TestFieldChanges_WithoutBeforeListsEveryValue(t *testing.T) {
+	repo := historyRepo(t)
+
+	changes := repo.fieldChanges(nil, dmodel.DynamicFields{"id": "01J000000000000000000000", "name": "Bolt"})
+	assert.Equal(t, map[string]dyn.FieldChange{
+		"id":   {New: "01J000000000000000000000"},
+		"name": {New: "Bolt"},
+	}, changes)
+}
+
+func TestHistoryRecordKey(t *testing.T) {
+	single := historyRepo(t).schema
+	key, err := historyRecordKey(single, dmodel.DynamicFields{"id": model.Id("01J000000000000000000000")})
+	require.NoError(t, err)
+	assert.Equal(t, "01J000000000000000000000", key)
+
+	composite := dmodel.GetSchemaRegistry().Get(historyLineSchemaName)
+	key, err = historyRecordKey(composite, dmodel.DynamicFields{"order_id": "01J1", "line_no": 3, "qty": 9})
+	require.NoError(t, err)
+	assert.Equal(t, `{"line_no":3,"order_id":"01J1"}`, key, "keys are sorted so one record has one key")
+
+	_, err = historyRecordKey(composite, dmodel.DynamicFields{"order_id": "01J1"})
+	assert.Error(t, err)
+
+	_, err = historyRecordKey(dmodel.DefineModel("test_baserepo_history_keyless").Build(), dmodel.DynamicFields{})
+	assert.Error(t, err, "a schema without a primary key has no history to read")
+}
+
+func TestChangeHistoryEntryOf(t *testing.T) {
+	changedAt := model.NewModelDateTime()
+
+	entry := changeHistoryEntryOf(dmodel.DynamicFields{
+		dyn.ChangeHistoryFieldId:        model.Id("01J2"),
+		dyn.ChangeHistoryFieldOperation: dyn.ChangeOperationUpdate,
+		dyn.ChangeHistoryFieldAction:    "approve",
+		dyn.ChangeHistoryFieldActorId:   "01J3",
+		dyn.ChangeHistoryFieldChangedAt: changedAt,
+		dyn.ChangeHistoryFieldChanges: map[string]any{
+			"status": map[string]any{"old": "draft", "new": "approved"},
+		},
+	})
+
+	assert.Equal(t, model.Id("01J2"), entry.Id)
+	assert.Equal(t, "approve", entry.Action)
+	require.NotNil(t, entry.ActorId)
+	assert.Equal(t, model.Id("01J3"), *entry.ActorId)
+	assert.Equal(t, changedAt, entry.ChangedAt)
+	assert.Equal(t, map[string]dyn.FieldChange{"status": {Old: "draft", New: "approved"}}, entry.Changes)
+
+	system := changeHistoryEntryOf(dmodel.DynamicFields{dyn.ChangeHistoryFieldOperation: dyn.ChangeOperationCreate})
+	assert.Nil(t, system.ActorId, "a change made by the system has no actor")
+	assert.NotNil(t, system.Changes)
+}
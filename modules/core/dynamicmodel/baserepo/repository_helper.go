@@ -112,10 +112,11 @@ func Search[TDomain any, TDomainPtr dyn.DynamicModelPtr[TDomain]](
 		items[i] = m
 	}
 	out := dyn.PagedResultData[TDomain]{
-		Items: items,
-		Total: paged.Total,
-		Page:  paged.Page,
-		Size:  paged.Size,
+		Items:      items,
+		Total:      paged.Total,
+		Page:       paged.Page,
+		Size:       paged.Size,
+		NextCursor: paged.NextCursor,
 	}
 
 	return &dyn.OpResult[dyn.PagedResultData[TDomain]]{
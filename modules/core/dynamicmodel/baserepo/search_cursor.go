@@ -0,0 +1,135 @@
+package baserepo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// searchCursor is what a next_cursor carries: where the page it follows ended, and the search it
+// belongs to. The schema and the order are kept so that a cursor replayed against another
+// resource or another sort is refused, instead of seeking by values that mean something else.
+type searchCursor struct {
+	Schema string    `json:"s"`
+	Order  []string  `json:"o"`
+	After  []*string `json:"a"`
+}
+
+// searchCursorCodec turns a searchCursor into the opaque token handed to clients and back.
+//
+// The token is signed because its values are compared against columns the caller may not be
+// allowed to filter on: an unsigned one would let a client probe them by editing the token.
+type searchCursorCodec struct {
+	secret []byte
+}
+
+func (this searchCursorCodec) encode(schema string, order dmodel.SearchOrder, after []*string) (string, error) {
+	payload, err := json.Marshal(searchCursor{
+		Schema: schema,
+		Order:  orderFingerprint(order),
+		After:  after,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(this.sign(encoded)), nil
+}
+
+// decode returns the values to seek past, or false when the token was not issued for this schema
+// and order, or not by this deployment.
+func (this searchCursorCodec) decode(token string, schema string, order dmodel.SearchOrder) ([]*string, bool) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, false
+	}
+	givenSig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(givenSig, this.sign(encoded)) {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, false
+	}
+
+	fingerprint := orderFingerprint(order)
+	if cursor.Schema != schema || len(cursor.Order) != len(fingerprint) || len(cursor.After) != len(fingerprint) {
+		return nil, false
+	}
+	for i := range fingerprint {
+		if cursor.Order[i] != fingerprint[i] {
+			return nil, false
+		}
+	}
+	return cursor.After, true
+}
+
+func (this searchCursorCodec) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, this.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+func orderFingerprint(order dmodel.SearchOrder) []string {
+	out := make([]string, len(order))
+	for i, item := range order {
+		out[i] = fmt.Sprintf("%s:%s", item.Field(), item.Direction())
+	}
+	return out
+}
+
+// takeKeysetValues removes the orm.KeysetColumn columns from every row, and returns the last
+// row's, which the next page seeks past.
+func takeKeysetValues(rows []dmodel.DynamicFields, keyCount int) []*string {
+	var last []*string
+	for i, row := range rows {
+		if i == len(rows)-1 {
+			last = make([]*string, keyCount)
+			for k := range keyCount {
+				if value, ok := row[orm.KeysetColumn(k)]; ok {
+					text := fmt.Sprint(value)
+					last[k] = &text
+				}
+			}
+		}
+		for col := range row {
+			if orm.IsKeysetColumn(col) {
+				delete(row, col)
+			}
+		}
+	}
+	return last
+}
+
+func clientErrorInvalidCursor() *ft.ClientErrorItem {
+	return ft.NewValidationError(
+		basemodel.FieldCursor, ft.ErrorKey("err_invalid_cursor"),
+		"cursor is malformed, or was issued for a different search",
+	)
+}
+
+func clientErrorCursorWithPage() *ft.ClientErrorItem {
+	return ft.NewValidationError(
+		basemodel.FieldCursor, ft.ErrorKey("err_cursor_with_page"),
+		"cursor cannot be combined with a page other than 0",
+	)
+}
+
+func clientErrorCursorWithTextQuery() *ft.ClientErrorItem {
+	return ft.NewValidationError(
+		basemodel.FieldCursor, ft.ErrorKey("err_cursor_with_text_query"),
+		"a full-text search is ordered by relevance and pages by page number, not by cursor",
+	)
+}
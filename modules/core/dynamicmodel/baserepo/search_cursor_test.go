@@ -0,0 +1,121 @@
+package baserepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+)
+
+var cursorOrder = dmodel.SearchOrder{{"sku", "desc"}, {"id", "asc"}}
+
+func cursorValue(value string) *string {
+	return &value
+}
+
+func TestSearchCursor_RoundTrip(t *testing.T) {
+	codec := searchCursorCodec{secret: []byte("s3cret")}
+	after := []*string{nil, cursorValue("01J0ID")}
+
+	token, err := codec.encode("inventory.stock_move_line", cursorOrder, after)
+	require.NoError(t, err)
+	decoded, ok := codec.decode(token, "inventory.stock_move_line", cursorOrder)
+
+	require.True(t, ok)
+	assert.Equal(t, after, decoded)
+}
+
+func TestSearchCursor_TamperedTokenIsRefused(t *testing.T) {
+	codec := searchCursorCodec{secret: []byte("s3cret")}
+	token, err := codec.encode("inventory.stock_move_line", cursorOrder, []*string{cursorValue("B"), cursorValue("01J0ID")})
+	require.NoError(t, err)
+
+	forged, err := codec.encode("inventory.stock_move_line", cursorOrder, []*string{cursorValue("A"), cursorValue("01J0ID")})
+	require.NoError(t, err)
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	_, ok := codec.decode(payload+"."+signature, "inventory.stock_move_line", cursorOrder)
+	assert.False(t, ok)
+
+	_, ok = searchCursorCodec{secret: []byte("other")}.decode(token, "inventory.stock_move_line", cursorOrder)
+	assert.False(t, ok, "a cursor signed by another deployment")
+
+	_, ok = codec.decode("not-a-cursor", "inventory.stock_move_line", cursorOrder)
+	assert.False(t, ok)
+}
+
+// A cursor holds the values of one particular sort; seeking by them under another would skip or
+// repeat rows without a word.
+func TestSearchCursor_OtherSearchIsRefused(t *testing.T) {
+	codec := searchCursorCodec{secret: []byte("s3cret")}
+	token, err := codec.encode("inventory.stock_move_line", cursorOrder, []*string{cursorValue("B"), cursorValue("01J0ID")})
+	require.NoError(t, err)
+
+	_, ok := codec.decode(token, "inventory.stock_move_line", dmodel.SearchOrder{{"sku", "asc"}, {"id", "asc"}})
+	assert.False(t, ok, "another direction")
+
+	_, ok = codec.decode(token, "inventory.stock_move", cursorOrder)
+	assert.False(t, ok, "another schema")
+}
+
+func TestTakeKeysetValues_StripsTheKeysetColumns(t *testing.T) {
+	rows := []dmodel.DynamicFields{
+		{"id": "01J0A", "_keyset_0": "A", "_keyset_1": "01J0A"},
+		{"id": "01J0B", "_keyset_1": "01J0B"},
+	}
+
+	after := takeKeysetValues(rows, 2)
+
+	assert.Equal(t, []*string{nil, cursorValue("01J0B")}, after)
+	assert.Equal(t, dmodel.DynamicFields{"id": "01J0A"}, rows[0])
+	assert.Equal(t, dmodel.DynamicFields{"id": "01J0B"}, rows[1])
+}
+
+func TestPagingFor(t *testing.T) {
+	repo := virtualRepo(t)
+
+	paging, cErr := repo.pagingFor(nil, dyn.RepoSearchParam{Size: 20})
+	require.Nil(t, cErr)
+	require.NotNil(t, paging.keyset, "a first page hands out a cursor")
+	assert.Equal(t, dmodel.SearchOrder{{"id", "asc"}}, paging.order)
+
+	paging, cErr = repo.pagingFor(nil, dyn.RepoSearchParam{Page: 2, Size: 20})
+	require.Nil(t, cErr)
+	assert.Nil(t, paging.keyset, "a page picked by number keeps to OFFSET")
+
+	_, cErr = repo.pagingFor(nil, dyn.RepoSearchParam{Page: 2, Size: 20, Cursor: "x.y"})
+	require.NotNil(t, cErr)
+	assert.Equal(t, "common:err_cursor_with_page", cErr.Key)
+
+	_, cErr = repo.pagingFor(nil, dyn.RepoSearchParam{Size: 20, Cursor: "x.y"})
+	require.NotNil(t, cErr)
+	assert.Equal(t, "common:err_invalid_cursor", cErr.Key)
+
+	paging, cErr = repo.pagingFor(nil, dyn.RepoSearchParam{Size: 20, TextQuery: "late"})
+	require.Nil(t, cErr)
+	assert.Nil(t, paging.keyset, "a ranked search keeps to OFFSET")
+}
+
+func TestValidateTextQuery(t *testing.T) {
+	repo := virtualRepo(t)
+
+	assert.Nil(t, repo.validateTextQuery(dyn.RepoSearchParam{}))
+
+	cErr := repo.validateTextQuery(dyn.RepoSearchParam{TextQuery: "late"})
+	require.NotNil(t, cErr)
+	assert.Equal(t, "common:err_full_text_search_unsupported", cErr.Key)
+	assert.Equal(t, "q", cErr.Field)
+}
+
+func TestDropSearchRank(t *testing.T) {
+	rows := []dmodel.DynamicFields{{"id": "01J0A", "_search_rank": 0.6}}
+
+	dropSearchRank(rows)
+
+	assert.Equal(t, dmodel.DynamicFields{"id": "01J0A"}, rows[0])
+}
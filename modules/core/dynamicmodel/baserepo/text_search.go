@@ -0,0 +1,35 @@
+package baserepo
+
+import (
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// validateTextQuery refuses a full-text query the schema cannot answer, and a cursor alongside
+// one: the score it is ordered by is no seek key.
+func (this *BaseDynamicRepositoryImpl) validateTextQuery(param dyn.RepoSearchParam) *ft.ClientErrorItem {
+	if param.TextQuery == "" {
+		return nil
+	}
+	if this.schema.FullTextSearch() == nil {
+		return ft.NewValidationError(
+			basemodel.FieldTextQuery, ft.ErrorKey("err_full_text_search_unsupported"),
+			"{{schema}} has no full-text search",
+			map[string]any{"schema": this.schema.Name()},
+		)
+	}
+	if param.Cursor != "" {
+		return clientErrorCursorWithTextQuery()
+	}
+	return nil
+}
+
+// dropSearchRank strips the relevance score the query builder projects to order by.
+func dropSearchRank(rows []dmodel.DynamicFields) {
+	for _, row := range rows {
+		delete(row, orm.SearchRankColumn)
+	}
+}
@@ -0,0 +1,126 @@
+package dynamicmodel
+
+import (
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+)
+
+// The change history is one table shared by every schema declaring TrackHistory. The repository
+// writes a row per insert, update and delete, in the transaction of the mutation itself.
+const (
+	ChangeHistorySchemaName = "core_change_history"
+
+	ChangeHistoryFieldId         = "id"
+	ChangeHistoryFieldSchemaName = "schema_name"
+	ChangeHistoryFieldRecordKey  = "record_key"
+	ChangeHistoryFieldOperation  = "operation"
+	ChangeHistoryFieldAction     = "action"
+	ChangeHistoryFieldActorId    = "actor_id"
+	ChangeHistoryFieldChangedAt  = "changed_at"
+	ChangeHistoryFieldChanges    = "changes"
+)
+
+const (
+	ChangeOperationCreate = "create"
+	ChangeOperationUpdate = "update"
+	ChangeOperationDelete = "delete"
+)
+
+func ChangeHistorySchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.DefineModel(ChangeHistorySchemaName).
+		TableName("core_change_histories").
+		ShouldBuildDb().
+		Field(
+			dmodel.DefineField().Name(ChangeHistoryFieldId).
+				DataType(dmodel.FieldDataTypeUlid()).
+				PrimaryKey(),
+		).
+		Field(
+			dmodel.DefineField().Name(ChangeHistoryFieldSchemaName).
+				DataType(dmodel.FieldDataTypeString(1, model.MODEL_RULE_SHORT_NAME_LENGTH)).
+				RequiredForCreate(),
+		).
+		Field(
+			// The primary key of the record, or for a composite one its columns as a JSON object.
+			// No foreign key: the history of a record outlives the record.
+			dmodel.DefineField().Name(ChangeHistoryFieldRecordKey).
+				DataType(dmodel.FieldDataTypeString(1, model.MODEL_RULE_DESC_LENGTH)).
+				RequiredForCreate(),
+		).
+		Field(
+			dmodel.DefineField().Name(ChangeHistoryFieldOperation).
+				DataType(dmodel.FieldDataTypeEnumString([]string{
+					ChangeOperationCreate, ChangeOperationUpdate, ChangeOperationDelete,
+				})).
+				RequiredForCreate(),
+		).
+		Field(
+			// The resource action that made the change, such as "update" or "approve".
+			dmodel.DefineField().Name(ChangeHistoryFieldAction).
+				DataType(dmodel.FieldDataTypeString(1, model.MODEL_RULE_SHORT_NAME_LENGTH)).
+				RequiredForCreate(),
+		).
+		Field(
+			// Empty when the change was made by the system rather than a user.
+			dmodel.DefineField().Name(ChangeHistoryFieldActorId).
+				DataType(dmodel.FieldDataTypeUlid()),
+		).
+		Field(
+			dmodel.DefineField().Name(ChangeHistoryFieldChangedAt).
+				DataType(dmodel.FieldDataTypeDateTime()).
+				RequiredForCreate(),
+		).
+		Field(
+			// Keyed by field name, each value holds "old" and "new".
+			dmodel.DefineField().Name(ChangeHistoryFieldChanges).
+				DataType(dmodel.FieldDataTypeJsonMap()),
+		).
+		SearchIndexGroup(dmodel.SearchIndexGroupParam{
+			IndexName: "core_change_histories_record",
+			Fields: []string{
+				ChangeHistoryFieldSchemaName, ChangeHistoryFieldRecordKey, ChangeHistoryFieldChangedAt,
+			},
+		})
+}
+
+// ChangeHistoryEntry is one mutation of a record, as kept in the change history.
+type ChangeHistoryEntry struct {
+	Id        model.Id               `json:"id"`
+	Operation string                 `json:"operation"`
+	Action    string                 `json:"action"`
+	ActorId   *model.Id              `json:"actor_id"`
+	ChangedAt model.ModelDateTime    `json:"changed_at"`
+	Changes   map[string]FieldChange `json:"changes"`
+}
+
+// FieldChange is the value of a field before and after a mutation. Old is nil on create and New
+// is nil on delete. Both are nil for a secret field, whose change is recorded but not its value.
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type RepoChangeHistoryParam struct {
+	// Keys identify the record, as the primary key columns of its schema.
+	Keys dmodel.DynamicFields
+	Page int
+	Size int
+}
+
+type changeActionCtxKey struct{}
+
+// WithChangeAction returns a copy of ctx naming the action the changes made with it are recorded
+// under. The resource engine sets it for every action it runs; a change made outside any action
+// is recorded under its operation.
+func WithChangeAction(ctx corectx.Context, action string) corectx.Context {
+	scoped := corectx.CloneRequestContext(ctx)
+	scoped.WithValue(changeActionCtxKey{}, action)
+	return scoped
+}
+
+// ChangeActionOf returns the action set by WithChangeAction, or empty.
+func ChangeActionOf(ctx corectx.Context) string {
+	action, _ := ctx.Value(changeActionCtxKey{}).(string)
+	return action
+}
@@ -3,6 +3,7 @@ package dynamicmodel
 import (
 	"github.com/sky-as-code/nikki-erp/common/array"
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
 	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	"github.com/sky-as-code/nikki-erp/common/model"
 )
@@ -66,6 +67,10 @@ type SearchQuery struct {
 	Fields []string `json:"fields" query:"fields"`
 	Page   int      `json:"page" query:"page"`
 	Size   int      `json:"size" query:"size"`
+	// Optional cursor from the previous page's next_cursor, to page on from there instead of by number
+	Cursor *string `json:"cursor" query:"cursor"`
+	// Optional full-text query; the matching records come most relevant first
+	TextQuery *string `json:"q" query:"q"`
 	// Optional search graph for advanced search
 	Graph *dmodel.SearchGraph `json:"graph" query:"graph"`
 	// Optional language code to filter fields with LangJson type
@@ -96,6 +101,42 @@ func (SearchQuery) GetSchema() *dmodel.ModelSchema {
 	)
 }
 
+// AggregateQuery groups the records the graph selects and computes measures per group.
+// The graph is filtered and scoped exactly as a SearchQuery's; its order is ignored, as groups
+// always come sorted by their keys.
+type AggregateQuery struct {
+	GroupBy  []orm.AggregateGroupBy `json:"group_by" query:"group_by"`
+	Measures []orm.AggregateMeasure `json:"measures" query:"measures"`
+	// Optional HAVING-style filters on the measures
+	Having []orm.AggregateHaving `json:"having" query:"having"`
+	Graph  *dmodel.SearchGraph   `json:"graph" query:"graph"`
+	// Maximum number of groups to return
+	Limit int `json:"limit" query:"limit"`
+	// Optional language code to filter fields with LangJson type
+	Language *model.LanguageCode `json:"language" query:"language"`
+
+	// Optional flag to include archived records in the aggregation.
+	// When omitted, crud.Aggregate treats it as false, as crud.Search does.
+	IncludeArchived *bool `json:"include_archived" query:"include_archived"`
+}
+
+func (AggregateQuery) GetSchema() *dmodel.ModelSchema {
+	return dmodel.GetOrRegisterSchema(
+		"core.crud_aggregate_query",
+		func() *dmodel.ModelSchemaBuilder {
+			return AggregateQuerySchemaBuilder()
+		},
+	)
+}
+
+// AggregateResultData holds one item per group, keyed by the group keys and the measure names.
+type AggregateResultData struct {
+	Items []dmodel.DynamicFields `json:"items"`
+
+	// Truncated is set when more groups matched than the limit let through.
+	Truncated bool `json:"truncated"`
+}
+
 type OpResult[TData any] struct {
 	// The result data when success. It is only meaningful if HasData is true and ClientErrors is nil.
 	// Otherwise, it could be nil or an empty struct.
@@ -129,6 +170,10 @@ type PagedResultData[T any] struct {
 	Page  int `json:"page"`
 	Size  int `json:"size"`
 
+	// Opaque cursor for the page after this one, to be sent back as the "cursor" query.
+	// It is empty on the last page, and on a page picked by a "page" other than 0.
+	NextCursor string `json:"next_cursor,omitempty"`
+
 	// Determines the fields to be returned in the response.
 	// If the request does not specify the fields, the view "auto" will be used,
 	// which will return all fields that user has permission to view.
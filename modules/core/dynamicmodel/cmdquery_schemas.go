@@ -8,6 +8,27 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
 )
 
+// AggregateQuerySchemaBuilder leaves group_by, measures and having to the query builder, which
+// knows which fields can be grouped and aggregated.
+func AggregateQuerySchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.DefineModel("_").
+		Field(dmodel.DefineField().
+			Name("group_by").
+			DataType(dmodel.FieldDataTypeModel())).
+		Field(dmodel.DefineField().
+			Name("measures").
+			DataType(dmodel.FieldDataTypeModel())).
+		Field(dmodel.DefineField().
+			Name("having").
+			DataType(dmodel.FieldDataTypeModel())).
+		Field(DefineFieldSearchGraph()).
+		Field(dmodel.DefineField().
+			Name("limit").
+			DataType(dmodel.FieldDataTypeInt32(model.MODEL_RULE_PAGE_MIN_SIZE, model.MODEL_RULE_PAGE_MAX_SIZE)).
+			Default(model.MODEL_RULE_PAGE_MAX_SIZE)).
+		Field(DefineFieldIncludeArchived())
+}
+
 func DeleteOneQuerySchemaBuilder() *dmodel.ModelSchemaBuilder {
 	return dmodel.DefineModel("_").
 		Field(dmodel.DefineField().
@@ -55,6 +76,8 @@ func SearchQuerySchemaBuilder() *dmodel.ModelSchemaBuilder {
 		Field(DefineFieldSearchGraph()).
 		Field(DefineFieldSearchPage()).
 		Field(DefineFieldSearchSize()).
+		Field(DefineFieldSearchCursor()).
+		Field(DefineFieldSearchTextQuery()).
 		Field(DefineFieldSearchName()).
 		Field(DefineFieldIncludeArchived()).
 		Field(DefineFieldSearchContext())
@@ -97,6 +120,22 @@ func DefineFieldSearchSize() *dmodel.FieldBuilder {
 		Default(model.MODEL_RULE_PAGE_DEFAULT_SIZE)
 }
 
+// DefineFieldSearchCursor defines the "cursor" search query field. The token is opaque here; the
+// repository verifies it against the search it is sent with.
+func DefineFieldSearchCursor() *dmodel.FieldBuilder {
+	return dmodel.DefineField().
+		Name(basemodel.FieldCursor).
+		DataType(dmodel.FieldDataTypeString(1, 4096))
+}
+
+// DefineFieldSearchTextQuery defines the "q" search query field, matched against the schema's
+// full-text search.
+func DefineFieldSearchTextQuery() *dmodel.FieldBuilder {
+	return dmodel.DefineField().
+		Name(basemodel.FieldTextQuery).
+		DataType(dmodel.FieldDataTypeString(1, 200))
+}
+
 func DefineFieldSearchGraph() *dmodel.FieldBuilder {
 	return dmodel.DefineField().
 		Name(basemodel.FieldGraph).
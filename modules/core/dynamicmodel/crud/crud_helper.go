@@ -2,6 +2,7 @@ package crud
 
 import (
 	stdErr "errors"
+	"maps"
 
 	"go.bryk.io/pkg/errors"
 
@@ -646,12 +647,51 @@ func Search[TDomain any, TDomainPtr dyn.DynamicModelPtr[TDomain]](
 		Size:            sanitizedQuery.Size,
 		Graph:           sanitizedQuery.Graph,
 		Language:        sanitizedQuery.Language,
+		Cursor:          util.ValueOrZeroOf(sanitizedQuery.Cursor),
+		TextQuery:       util.ValueOrZeroOf(sanitizedQuery.TextQuery),
 		IncludeArchived: includeArchived,
 		ComputedContext: sanitizedQuery.Context,
 	})
 	return result, errors.Wrap(err, "Search")
 }
 
+type AggregateParam struct {
+	Action       string
+	DbRepoGetter dyn.DynamicModelRepository
+	Query        dyn.AggregateQuery
+}
+
+func Aggregate(ctx corectx.Context, param AggregateParam) (result *dyn.OpResult[dyn.AggregateResultData], err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), param.Action); e != nil {
+			err = e
+		}
+	}()
+
+	sanitized, cErrs := param.Query.GetSchema().ValidateStruct(param.Query)
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.AggregateResultData]{ClientErrors: cErrs}, nil
+	}
+	query := *(sanitized.(*dyn.AggregateQuery))
+
+	includeArchived := query.IncludeArchived
+	if includeArchived == nil {
+		// Same default as Search: a report counts active records unless asked otherwise.
+		includeArchived = util.ToPtr(false)
+	}
+
+	result, err = param.DbRepoGetter.GetBaseRepo().Aggregate(ctx, dyn.RepoAggregateParam{
+		Graph:           query.Graph,
+		GroupBy:         query.GroupBy,
+		Measures:        query.Measures,
+		Having:          query.Having,
+		Limit:           query.Limit,
+		Language:        query.Language,
+		IncludeArchived: includeArchived,
+	})
+	return result, errors.Wrap(err, "Aggregate")
+}
+
 func validateUniques(ctx corectx.Context, data dmodel.DynamicFields, dbRepo dyn.BaseDynamicRepository, vErrs *ft.ClientErrors) error {
 	collRes, err := dbRepo.CheckUniqueCollisions(ctx, data)
 	if err != nil {
@@ -843,6 +883,12 @@ func runUpdateValidationFlow[TDomain any, TDomainPtr dyn.DynamicModelPtr[TDomain
 			foundModel.SetFieldData(dbRecord)
 			return nil
 		}).
+		Step(func(vErrs *ft.ClientErrors) error {
+			merged := maps.Clone(foundModel.GetFieldData())
+			maps.Copy(merged, inputModel.GetFieldData())
+			vErrs.Concat(schema.ValidateExclusiveFields(merged))
+			return nil
+		}).
 		Step(func(vErrs *ft.ClientErrors) error {
 			err := validateUniques(ctx, inputModel.GetFieldData(), dynamicRepo, vErrs)
 			return errors.Wrap(err, "Update.ValidateUniques")
@@ -36,6 +36,11 @@ type BaseDynamicRepository interface {
 	QueryFunc(ctx corectx.Context, sqlFuncName string, sqlFuncArgs ...any) (*sql.Rows, error)
 
 	CheckUniqueCollisions(ctx corectx.Context, data dmodel.DynamicFields) (*OpResult[[][]string], error)
+	// ChangeHistory reads the change history of one record, the latest change first. It is empty
+	// for a schema that does not declare TrackHistory.
+	ChangeHistory(ctx corectx.Context, param RepoChangeHistoryParam) (*OpResult[PagedResultData[ChangeHistoryEntry]], error)
+	// Aggregate groups the matching records and computes aggregate measures per group.
+	Aggregate(ctx corectx.Context, param RepoAggregateParam) (*OpResult[AggregateResultData], error)
 	CountM2m(ctx corectx.Context, param RepoCountM2mParam) (*OpResult[int], error)
 	DeleteOne(ctx corectx.Context, keys dmodel.DynamicFields) (*OpResult[int], error)
 	Exists(ctx corectx.Context, keys []dmodel.DynamicFields) (*OpResult[RepoExistsResult], error)
@@ -75,6 +80,14 @@ type RepoSearchParam struct {
 	Graph    *dmodel.SearchGraph
 	Language *model.LanguageCode
 
+	// Cursor is the NextCursor of the page before, to seek past its last row rather than skip
+	// Page*Size rows. It cannot be combined with a Page other than 0.
+	Cursor string
+
+	// TextQuery keeps the records matching it in the schema's full-text search, most relevant
+	// first. Such a search pages by Page only, so it cannot be combined with Cursor.
+	TextQuery string
+
 	// ComputedContext carries the request's whitelisted context values for SQL-computed
 	// fields; the query builder binds them into "${ctx.key}" filter references.
 	ComputedContext map[string]any
@@ -99,6 +112,20 @@ type RepoSearchParam struct {
 	IncludeArchived *bool
 }
 
+// RepoAggregateParam groups the rows Graph selects and computes Measures per group.
+// The tenant key and IncludeArchived are injected into Graph exactly as for RepoSearchParam.
+type RepoAggregateParam struct {
+	Graph    *dmodel.SearchGraph
+	GroupBy  []orm.AggregateGroupBy
+	Measures []orm.AggregateMeasure
+	Having   []orm.AggregateHaving
+	// Limit caps the number of groups returned; 0 means no cap.
+	Limit    int
+	Language *model.LanguageCode
+
+	IncludeArchived *bool
+}
+
 type RepoManageM2mParam struct {
 	DestSchemaName string
 	SrcId          model.Id
@@ -164,6 +164,8 @@ type RestSearchResponse[TItem any] struct {
 	Page  int     `json:"page"`
 	Size  int     `json:"size"`
 
+	NextCursor string `json:"next_cursor,omitempty"`
+
 	DesiredFields []string `json:"desired_fields"`
 	MaskedFields  []string `json:"masked_fields"`
 	SchemaEtag    string   `json:"schema_etag"`
@@ -179,6 +181,7 @@ func NewSearchResponseDyn[TItem dmodel.DynamicModelGetter](
 		Total:         data.Total,
 		Page:          data.Page,
 		Size:          data.Size,
+		NextCursor:    data.NextCursor,
 		DesiredFields: data.DesiredFields,
 		MaskedFields:  data.MaskedFields,
 		SchemaEtag:    data.SchemaEtag,
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
 	"github.com/sky-as-code/nikki-erp/common/semver"
 	"github.com/sky-as-code/nikki-erp/modules"
 	"github.com/sky-as-code/nikki-erp/modules/core/authtoken"
@@ -61,7 +62,10 @@ func (*CoreModule) Version() semver.SemVer {
 // module's Init(). Registering the base schemas here is what makes them resolvable by name
 // when other modules parse JSON models that extend them.
 func (*CoreModule) RegisterModels() error {
-	return basemodel.RegisterJsonBaseSchemas()
+	return errors.Join(
+		basemodel.RegisterJsonBaseSchemas(),
+		dmodel.RegisterSchemaB(coredyn.ChangeHistorySchemaBuilder()),
+	)
 }
 
 // Init implements NikkiModule.
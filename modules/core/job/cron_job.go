@@ -1,46 +1,158 @@
 package job
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/sky-as-code/nikki-erp/modules/core/constants"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/distributedlock"
 	"github.com/sky-as-code/nikki-erp/modules/core/logging"
 	"go.uber.org/dig"
 )
 
 type CronjobRegistry interface {
+	// Register schedules a job to run once per tick across the whole cluster: every instance
+	// fires it, and the one that claims the tick first runs it.
 	Register(crontab string, jobName string, job JobHandleFn, timeout ...time.Duration) error
+
+	// RegisterOnEveryNode schedules a job that runs on every instance at every tick, for work
+	// about the instance itself, such as clearing a local cache.
+	RegisterOnEveryNode(crontab string, jobName string, job JobHandleFn, timeout ...time.Duration) error
 }
 
+// tickTolerance is how far apart two instances' clocks may be and still agree on which tick
+// they are firing for. Crontabs here have minute resolution, so a tick is named by the minute
+// nearest the moment it fired.
+const tickTolerance = time.Minute
+
 type CronJob struct {
 	logger    logging.LoggerService
 	scheduler gocron.Scheduler
+	locker    distributedlock.DistributedLock
+	runs      JobRunRecorder
+
+	// node names this instance in the job-run records.
+	node string
+
+	// now is injected so a tick can be tested against a fixed clock.
+	now func() time.Time
+}
+
+type cronSpec struct {
+	jobName   string
+	handle    JobHandleFn
+	timeout   time.Duration
+	everyNode bool
 }
 
 func (this *CronJob) Register(crontab string,
 	jobName string,
 	job JobHandleFn, timeout ...time.Duration) error {
+	return this.schedule(crontab, newCronSpec(jobName, job, false, timeout))
+}
+
+func (this *CronJob) RegisterOnEveryNode(crontab string,
+	jobName string,
+	job JobHandleFn, timeout ...time.Duration) error {
+	return this.schedule(crontab, newCronSpec(jobName, job, true, timeout))
+}
+
+func newCronSpec(jobName string, job JobHandleFn, everyNode bool, timeout []time.Duration) cronSpec {
+	spec := cronSpec{
+		jobName:   jobName,
+		handle:    job,
+		timeout:   constants.BackgroudJobTimeout,
+		everyNode: everyNode,
+	}
+	if len(timeout) > 0 {
+		spec.timeout = timeout[0]
+	}
+	return spec
+}
+
+func (this *CronJob) schedule(crontab string, spec cronSpec) error {
 	_, err := this.scheduler.NewJob(
 		gocron.CronJob(crontab, false),
-		gocron.NewTask(func() {
-			defer func() {
-				if r := recover(); r != nil {
-					this.logger.Errorf("[CRONJOB] %s panicked: %v", jobName, r)
-				}
-			}()
-			jobTimeout := constants.BackgroudJobTimeout
-			if len(timeout) > 0 {
-				jobTimeout = timeout[0]
-			}
-
-			_ = Handle(this.logger, jobName, job, jobTimeout, nil)
-		}),
+		gocron.NewTask(func() { this.tick(spec) }),
 	)
-
 	return err
 }
 
+// tick runs one firing of a job, if this instance is the one to run it.
+func (this *CronJob) tick(spec cronSpec) {
+	tick := this.now().Round(tickTolerance)
+	if !spec.everyNode && !this.claim(spec, tick) {
+		return
+	}
+
+	run := JobRun{
+		JobName:   spec.jobName,
+		Node:      this.node,
+		TickAt:    tick,
+		StartedAt: this.now(),
+		Outcome:   JobRunOutcomeRunning,
+	}
+	runId, err := this.runs.Started(context.Background(), run)
+	if err != nil {
+		// The record is for whoever investigates later; the job itself must not wait on it.
+		this.logger.Warnf("[CRONJOB] %s: the run could not be recorded: %s", spec.jobName, err.Error())
+	}
+
+	outcome, detail := this.execute(spec)
+
+	if runId == "" {
+		return
+	}
+	err = this.runs.Finished(context.Background(), runId, this.now(), outcome, detail)
+	if err != nil {
+		this.logger.Warnf("[CRONJOB] %s: the run's outcome could not be recorded: %s", spec.jobName, err.Error())
+	}
+}
+
+// claim takes the cluster-wide lock on one tick of a job.
+//
+// The lock is named by the tick rather than by the job alone, and is left to expire rather than
+// released: an instance whose clock runs a little behind fires after the run has finished, and
+// a released lock on the job would let it run the same tick again. It outlives the job's timeout
+// plus the clock tolerance, so no instance can still be firing for the tick when it goes.
+//
+// When the lock cannot be reached the tick is skipped. Every job registered here already runs
+// again on its next tick, while running on every instance at once is what this lock exists to
+// prevent.
+func (this *CronJob) claim(spec cronSpec, tick time.Time) bool {
+	key := fmt.Sprintf("cronjob:%s:%d", spec.jobName, tick.Unix())
+	acquired, err := this.locker.Acquire(context.Background(), key, spec.timeout+tickTolerance)
+	if err != nil {
+		this.logger.Errorf("[CRONJOB] %s: skipped, the lock could not be reached: %s", spec.jobName, err.Error())
+		return false
+	}
+	return acquired
+}
+
+// execute runs the job and reports how it ended.
+func (this *CronJob) execute(spec cronSpec) (outcome string, detail string) {
+	defer func() {
+		if r := recover(); r != nil {
+			this.logger.Errorf("[CRONJOB] %s panicked: %v", spec.jobName, r)
+			outcome, detail = JobRunOutcomePanicked, fmt.Sprint(r)
+		}
+	}()
+
+	err := Handle(this.logger, spec.jobName, spec.handle, spec.timeout, nil)
+	switch {
+	case err == nil:
+		return JobRunOutcomeSucceeded, ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return JobRunOutcomeTimedOut, err.Error()
+	default:
+		return JobRunOutcomeFailed, err.Error()
+	}
+}
+
 func (this *CronJob) Start() error {
 	this.scheduler.Start()
 	this.logger.Infof("CronJob server started")
@@ -55,7 +167,11 @@ type initCronJobResult struct {
 	Registry CronjobRegistry
 }
 
-func initCronJob(logger logging.LoggerService) initCronJobResult {
+func initCronJob(
+	logger logging.LoggerService,
+	locker distributedlock.DistributedLock,
+	runs JobRunRecorder,
+) initCronJobResult {
 	scheduler, err := gocron.NewScheduler()
 	if err != nil {
 		logger.Errorf("Fail to init CronJob")
@@ -65,6 +181,10 @@ func initCronJob(logger logging.LoggerService) initCronJobResult {
 	cronJob := &CronJob{
 		logger:    logger,
 		scheduler: scheduler,
+		locker:    locker,
+		runs:      runs,
+		node:      nodeName(),
+		now:       time.Now,
 	}
 
 	return initCronJobResult{
@@ -72,3 +192,13 @@ func initCronJob(logger logging.LoggerService) initCronJobResult {
 		Registry: cronJob,
 	}
 }
+
+// nodeName identifies this instance: the host, which is the pod in a cluster, and the process,
+// which tells two instances on one host apart.
+func nodeName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
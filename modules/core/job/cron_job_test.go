@@ -0,0 +1,192 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+)
+
+// memoryLock stands in for Redis, shared between the instances of one test cluster.
+type memoryLock struct {
+	mu   sync.Mutex
+	held map[string]time.Duration
+	err  error
+}
+
+func (this *memoryLock) Acquire(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.err != nil {
+		return false, this.err
+	}
+	if _, taken := this.held[key]; taken {
+		return false, nil
+	}
+	this.held[key] = ttl
+	return true, nil
+}
+
+func (this *memoryLock) AcquireWithRetry(
+	ctx context.Context, key string, ttl time.Duration, _ int, _ time.Duration,
+) (bool, error) {
+	return this.Acquire(ctx, key, ttl)
+}
+
+func (this *memoryLock) Release(_ context.Context, key string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.held, key)
+	return nil
+}
+
+type memoryRuns struct {
+	runs []JobRun
+}
+
+func (this *memoryRuns) Started(_ context.Context, run JobRun) (string, error) {
+	run.Id = string(rune('a' + len(this.runs)))
+	this.runs = append(this.runs, run)
+	return run.Id, nil
+}
+
+func (this *memoryRuns) Finished(_ context.Context, runId string, endedAt time.Time, outcome string, detail string) error {
+	for i := range this.runs {
+		if this.runs[i].Id == runId {
+			this.runs[i].EndedAt = &endedAt
+			this.runs[i].Outcome = outcome
+			this.runs[i].Detail = detail
+		}
+	}
+	return nil
+}
+
+func newTestNode(node string, lock *memoryLock, runs *memoryRuns, at time.Time) *CronJob {
+	return &CronJob{
+		logger: logging.NewLogger(logging.LevelError),
+		locker: lock,
+		runs:   runs,
+		node:   node,
+		now:    func() time.Time { return at },
+	}
+}
+
+func countingJob(count *int) JobHandleFn {
+	return func(context.Context, *string) error {
+		*count++
+		return nil
+	}
+}
+
+// Three instances firing for the same tick, their clocks a few seconds apart, run the job once.
+func TestATickRunsOnOneInstanceOnly(t *testing.T) {
+	lock := &memoryLock{held: map[string]time.Duration{}}
+	runs := &memoryRuns{}
+	tick := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	ran := 0
+	spec := newCronSpec("sweep", countingJob(&ran), false, []time.Duration{2 * time.Minute})
+	newTestNode("a", lock, runs, tick.Add(-2*time.Second)).tick(spec)
+	newTestNode("b", lock, runs, tick).tick(spec)
+	newTestNode("c", lock, runs, tick.Add(3*time.Second)).tick(spec)
+
+	assert.Equal(t, 1, ran)
+	require.Len(t, runs.runs, 1)
+	assert.Equal(t, "a", runs.runs[0].Node)
+	assert.Equal(t, tick, runs.runs[0].TickAt)
+	assert.Equal(t, JobRunOutcomeSucceeded, runs.runs[0].Outcome)
+
+	// The lock outlives the job's timeout, so no late instance can still be firing for the tick.
+	assert.Equal(t, 2*time.Minute+tickTolerance, lock.held[fmt.Sprintf("cronjob:sweep:%d", tick.Unix())])
+}
+
+func TestTheNextTickRunsAgain(t *testing.T) {
+	lock := &memoryLock{held: map[string]time.Duration{}}
+	runs := &memoryRuns{}
+	tick := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	ran := 0
+	spec := newCronSpec("sweep", countingJob(&ran), false, nil)
+	newTestNode("a", lock, runs, tick).tick(spec)
+	newTestNode("b", lock, runs, tick.Add(time.Minute)).tick(spec)
+
+	assert.Equal(t, 2, ran)
+}
+
+func TestAnEveryNodeJobTakesNoLock(t *testing.T) {
+	lock := &memoryLock{held: map[string]time.Duration{}}
+	runs := &memoryRuns{}
+	tick := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	ran := 0
+	spec := newCronSpec("local-cache", countingJob(&ran), true, nil)
+	newTestNode("a", lock, runs, tick).tick(spec)
+	newTestNode("b", lock, runs, tick).tick(spec)
+
+	assert.Equal(t, 2, ran)
+	assert.Empty(t, lock.held)
+}
+
+// Without the lock there is no telling whether another instance is running the tick, so none does.
+func TestAnUnreachableLockSkipsTheTick(t *testing.T) {
+	lock := &memoryLock{held: map[string]time.Duration{}, err: errors.New("connection refused")}
+	runs := &memoryRuns{}
+
+	ran := 0
+	newTestNode("a", lock, runs, time.Now()).tick(newCronSpec("sweep", countingJob(&ran), false, nil))
+
+	assert.Zero(t, ran)
+	assert.Empty(t, runs.runs)
+}
+
+func TestARunRecordsHowItEnded(t *testing.T) {
+	runs := &memoryRuns{}
+	node := newTestNode("a", nil, runs, time.Now())
+
+	node.tick(newCronSpec("failing", func(context.Context, *string) error {
+		return errors.New("the gateway is down")
+	}, true, nil))
+	node.tick(newCronSpec("slow", func(ctx context.Context, _ *string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, true, []time.Duration{time.Millisecond}))
+	node.tick(newCronSpec("panicking", func(context.Context, *string) error {
+		panic("nil map")
+	}, true, nil))
+
+	require.Len(t, runs.runs, 3)
+	assert.Equal(t, JobRunOutcomeFailed, runs.runs[0].Outcome)
+	assert.Equal(t, "the gateway is down", runs.runs[0].Detail)
+	assert.Equal(t, JobRunOutcomeTimedOut, runs.runs[1].Outcome)
+	assert.Equal(t, JobRunOutcomePanicked, runs.runs[2].Outcome)
+	assert.Equal(t, "nil map", runs.runs[2].Detail)
+	for _, run := range runs.runs {
+		assert.NotNil(t, run.EndedAt)
+	}
+}
+
+type recordingRunDeleter struct {
+	befores []time.Time
+}
+
+func (this *recordingRunDeleter) DeleteRunsBefore(_ context.Context, before time.Time) (int64, error) {
+	this.befores = append(this.befores, before)
+	return 0, nil
+}
+
+func TestPruningDeletesTheRunsPastTheRetention(t *testing.T) {
+	clock := time.Date(2026, 10, 19, 3, 40, 0, 0, time.UTC)
+	deleter := &recordingRunDeleter{}
+	pruning := &jobRunPruning{runs: deleter, retention: 30 * 24 * time.Hour, now: func() time.Time { return clock }}
+
+	require.NoError(t, pruning.prune(context.Background(), nil))
+
+	assert.Equal(t, []time.Time{time.Date(2026, 9, 19, 3, 40, 0, 0, time.UTC)}, deleter.befores)
+}
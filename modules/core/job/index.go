@@ -1,24 +1,65 @@
 package job
 
 import (
+	stdErr "errors"
+	"time"
+
+	"go.uber.org/dig"
+
 	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	c "github.com/sky-as-code/nikki-erp/modules/core/constants"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/distributedlock"
 	"github.com/sky-as-code/nikki-erp/modules/core/logging"
 )
 
 var jobManagerSingleton *JobManager
 var cronjobSingleton *CronJob
+var jobQueueSingleton *JobQueue
 
 func InitSubModule() error {
 	err := deps.Register(
-		func(logger logging.LoggerService) initCronJobResult {
-			return initCronJob(logger)
+		NewJobRunStore,
+		func(logger logging.LoggerService, locker distributedlock.DistributedLock, runs *JobRunStore) initCronJobResult {
+			return initCronJob(logger, locker, runs)
 		},
 		func(logger logging.LoggerService) initJobManagerResult {
 			return initJobManger(logger)
 		},
+		NewQueuedJobStore,
+		initJobQueue,
+		NewQueuedJobAdminService,
+		NewJobRunAdminService,
 	)
 
-	return err
+	return stdErr.Join(err,
+		deps.Invoke(registerQueueRoutes),
+		deps.Invoke(registerJobRunRoutes),
+		deps.Invoke(registerJobRunPruning),
+	)
+}
+
+type initJobQueueResult struct {
+	dig.Out
+
+	JobQueue *JobQueue
+	Enqueuer JobEnqueuer
+	Registry JobQueueRegistry
+}
+
+func initJobQueue(logger logging.LoggerService, cfg config.ConfigService, store *QueuedJobStore) initJobQueueResult {
+	queue := NewJobQueue(logger, store, JobQueueSettings{
+		Workers:      cfg.GetInt(c.JobQueueWorkers, 4),
+		PollInterval: time.Duration(cfg.GetInt(c.JobQueuePollIntervalMs, 1000)) * time.Millisecond,
+		BackoffBase:  time.Duration(cfg.GetInt(c.JobQueueBackoffBaseSecs, 10)) * time.Second,
+		BackoffCap:   time.Duration(cfg.GetInt(c.JobQueueBackoffCapSecs, 3600)) * time.Second,
+	})
+
+	return initJobQueueResult{
+		JobQueue: queue,
+		Enqueuer: queue,
+		Registry: queue,
+	}
 }
 
 func GetJobManger() *JobManager {
@@ -35,6 +76,20 @@ func GetJobManger() *JobManager {
 	return jobManagerSingleton
 }
 
+func GetJobQueue() *JobQueue {
+	if jobQueueSingleton != nil {
+		return jobQueueSingleton
+	}
+
+	var jobQueue *JobQueue
+	if err := deps.Invoke(func(jq *JobQueue) { jobQueue = jq }); err != nil {
+		panic(err)
+	}
+
+	jobQueueSingleton = jobQueue
+	return jobQueueSingleton
+}
+
 func GetCronjob() *CronJob {
 	if cronjobSingleton != nil {
 		return cronjobSingleton
@@ -0,0 +1,197 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	c "github.com/sky-as-code/nikki-erp/modules/core/constants"
+)
+
+// How a cron run ended. A run still recorded as running either is running or belonged to an
+// instance that stopped mid-run.
+const (
+	JobRunOutcomeRunning   = "running"
+	JobRunOutcomeSucceeded = "succeeded"
+	JobRunOutcomeFailed    = "failed"
+	JobRunOutcomeTimedOut  = "timed_out"
+	JobRunOutcomePanicked  = "panicked"
+)
+
+// JobRun is one run of a cron job on one instance, as the admin API shows it. Table: core_job_runs.
+type JobRun struct {
+	Id      string `json:"id"`
+	JobName string `json:"job_name"`
+	Node    string `json:"node"`
+
+	// TickAt is the tick the run was fired for, which is what the cluster lock is taken on.
+	TickAt time.Time `json:"tick_at"`
+
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Outcome   string     `json:"outcome"`
+
+	// Detail is the error or panic a run ended with.
+	Detail string `json:"detail,omitempty"`
+}
+
+// JobRunRecorder keeps the record of cron runs.
+type JobRunRecorder interface {
+	// Started records a run as it begins and returns its id.
+	Started(ctx context.Context, run JobRun) (string, error)
+
+	// Finished records how a started run ended.
+	Finished(ctx context.Context, runId string, endedAt time.Time, outcome string, detail string) error
+}
+
+// JobRunFilter narrows ListRuns. Zero values do not filter.
+type JobRunFilter struct {
+	JobName string
+	Node    string
+	Outcome string
+
+	// Limit bounds the result, newest first. It defaults to defaultJobRunLimit and is capped at
+	// maxJobRunLimit.
+	Limit int
+}
+
+const (
+	defaultJobRunLimit = 100
+	maxJobRunLimit     = 500
+)
+
+// JobRunStore keeps cron runs in core_job_runs.
+//
+// It writes SQL directly rather than through a resource engine: the scheduler belongs to core,
+// which every module that defines engines depends on.
+type JobRunStore struct {
+	client orm.DbClient
+}
+
+func NewJobRunStore(client orm.DbClient) *JobRunStore {
+	return &JobRunStore{client: client}
+}
+
+func (this *JobRunStore) Started(ctx context.Context, run JobRun) (string, error) {
+	id, err := model.NewId()
+	if err != nil {
+		return "", errors.Wrap(err, "JobRunStore.Started")
+	}
+	_, err = this.client.Exec(ctx,
+		`INSERT INTO "core_job_runs" ("id", "job_name", "node", "tick_at", "started_at", "outcome")
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		*id, run.JobName, run.Node, run.TickAt, run.StartedAt, run.Outcome,
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "JobRunStore.Started")
+	}
+	return *id, nil
+}
+
+func (this *JobRunStore) Finished(
+	ctx context.Context, runId string, endedAt time.Time, outcome string, detail string,
+) error {
+	_, err := this.client.Exec(ctx,
+		`UPDATE "core_job_runs" SET "ended_at" = $2, "outcome" = $3, "detail" = $4 WHERE "id" = $1`,
+		runId, endedAt, outcome, nullableString(detail),
+	)
+	return errors.Wrap(err, "JobRunStore.Finished")
+}
+
+// ListRuns returns the runs a filter selects, newest first.
+func (this *JobRunStore) ListRuns(ctx context.Context, filter JobRunFilter) ([]JobRun, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultJobRunLimit
+	}
+	limit = min(limit, maxJobRunLimit)
+
+	rows, err := this.client.Query(ctx,
+		`SELECT "id", "job_name", "node", "tick_at", "started_at", "ended_at", "outcome", "detail"
+		FROM "core_job_runs"
+		WHERE ($1 = '' OR "job_name" = $1)
+			AND ($2 = '' OR "node" = $2)
+			AND ($3 = '' OR "outcome" = $3)
+		ORDER BY "started_at" DESC
+		LIMIT $4`,
+		filter.JobName, filter.Node, filter.Outcome, limit,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "JobRunStore.ListRuns")
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		var endedAt sql.NullTime
+		var detail sql.NullString
+		err := rows.Scan(&run.Id, &run.JobName, &run.Node, &run.TickAt, &run.StartedAt, &endedAt, &run.Outcome, &detail)
+		if err != nil {
+			return nil, errors.Wrap(err, "JobRunStore.ListRuns")
+		}
+		if endedAt.Valid {
+			run.EndedAt = &endedAt.Time
+		}
+		run.Detail = detail.String
+		runs = append(runs, run)
+	}
+	return runs, errors.Wrap(rows.Err(), "JobRunStore.ListRuns")
+}
+
+// DeleteRunsBefore deletes the runs started before a moment and answers how many went.
+func (this *JobRunStore) DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := this.client.Exec(ctx, `DELETE FROM "core_job_runs" WHERE "started_at" < $1`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "JobRunStore.DeleteRunsBefore")
+	}
+	deleted, err := result.RowsAffected()
+	return deleted, errors.Wrap(err, "JobRunStore.DeleteRunsBefore")
+}
+
+// Every tick of every job adds a run, so a job on a one-minute crontab alone adds some 43,000 a
+// month. Runs past the retention are deleted once a day, at an hour away from the module sweeps.
+const (
+	cronJobRunPruning    = "40 3 * * *"
+	jobNameJobRunPruning = "core-job-runs-pruning"
+
+	defaultJobRunRetentionDays = 30
+)
+
+// jobRunDeleter is the part of JobRunStore the pruning writes.
+type jobRunDeleter interface {
+	DeleteRunsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// jobRunPruning deletes the runs older than the retention.
+type jobRunPruning struct {
+	runs      jobRunDeleter
+	retention time.Duration
+
+	// now is injected so the cutoff can be tested against a fixed clock.
+	now func() time.Time
+}
+
+func (this *jobRunPruning) prune(ctx context.Context, _ *string) error {
+	_, err := this.runs.DeleteRunsBefore(ctx, this.now().Add(-this.retention))
+	return err
+}
+
+func registerJobRunPruning(registry CronjobRegistry, cfg config.ConfigService, store *JobRunStore) error {
+	days := cfg.GetInt(c.JobRunRetentionDays, defaultJobRunRetentionDays)
+	pruning := &jobRunPruning{
+		runs:      store,
+		retention: time.Duration(days) * 24 * time.Hour,
+		now:       time.Now,
+	}
+	return registry.Register(cronJobRunPruning, jobNameJobRunPruning, pruning.prune)
+}
+
+func nullableString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
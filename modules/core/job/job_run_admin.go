@@ -0,0 +1,99 @@
+package job
+
+import (
+	"context"
+	"slices"
+
+	"github.com/labstack/echo/v5"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/httpserver"
+	m "github.com/sky-as-code/nikki-erp/modules/core/httpserver/middlewares"
+	"github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+)
+
+// The record of cron runs is one record for the whole deployment, granted at domain scope only.
+const (
+	ResourceCodeJobRun = "core_job_run"
+
+	ActionReadJobRun = "read"
+)
+
+type ListJobRunsQuery struct {
+	JobName string `json:"job_name" query:"job_name"`
+	Node    string `json:"node" query:"node"`
+	Outcome string `json:"outcome" query:"outcome"`
+	Limit   int    `json:"limit" query:"limit"`
+}
+
+type ListJobRunsResultData struct {
+	Items []JobRun `json:"items"`
+}
+
+var jobRunOutcomes = []string{
+	JobRunOutcomeRunning,
+	JobRunOutcomeSucceeded,
+	JobRunOutcomeFailed,
+	JobRunOutcomeTimedOut,
+	JobRunOutcomePanicked,
+}
+
+// jobRunLister is the part of JobRunStore the admin API reads.
+type jobRunLister interface {
+	ListRuns(ctx context.Context, filter JobRunFilter) ([]JobRun, error)
+}
+
+// JobRunAdminService lets an administrator see which instance ran each cron tick and how it
+// ended.
+type JobRunAdminService struct {
+	runs jobRunLister
+}
+
+func NewJobRunAdminService(store *JobRunStore) *JobRunAdminService {
+	return &JobRunAdminService{runs: store}
+}
+
+// ListRuns answers the most recent runs, newest first. An outcome that no run can have is refused
+// rather than answered with nothing, which would read as a job that never failed.
+func (this *JobRunAdminService) ListRuns(
+	ctx corectx.Context, query ListJobRunsQuery,
+) (*dyn.OpResult[ListJobRunsResultData], error) {
+	if cErrs := requestguard.AssertPermission(ctx, requestguard.PermFor(
+		ActionReadJobRun, ResourceCodeJobRun, requestguard.ResourceScopeDomain,
+	)); cErrs != nil {
+		return &dyn.OpResult[ListJobRunsResultData]{ClientErrors: *cErrs}, nil
+	}
+	if query.Outcome != "" && !slices.Contains(jobRunOutcomes, query.Outcome) {
+		return &dyn.OpResult[ListJobRunsResultData]{ClientErrors: ft.ClientErrors{*ft.NewValidationError(
+			"outcome", "core.err_job_run_outcome_unknown", "outcome must be one of the outcomes a run can have",
+		)}}, nil
+	}
+
+	runs, err := this.runs.ListRuns(ctx.InnerContext(), JobRunFilter{
+		JobName: query.JobName,
+		Node:    query.Node,
+		Outcome: query.Outcome,
+		Limit:   query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dyn.OpResult[ListJobRunsResultData]{
+		Data:    ListJobRunsResultData{Items: runs},
+		HasData: true,
+	}, nil
+}
+
+// registerJobRunRoutes exposes the record of cron runs at /v1/core/jobs/runs.
+func registerJobRunRoutes(route *echo.Group, admin *JobRunAdminService) {
+	routeV1 := route.Group("/v1/core/jobs/runs", m.SmokeAuthz())
+
+	routeV1.GET("", func(echoCtx *echo.Context) error {
+		return httpserver.ServeRequest2(echoCtx, admin.ListRuns,
+			httpserver.ItsMeMario[ListJobRunsQuery], httpserver.ItsMeMario[ListJobRunsResultData],
+			httpserver.JsonOk,
+		)
+	})
+}
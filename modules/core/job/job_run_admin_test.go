@@ -0,0 +1,95 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ds "github.com/sky-as-code/nikki-erp/common/datastructure"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+)
+
+// The record of cron runs is how an administrator finds out which instance ran a tick and why it
+// failed. What they ask for must reach the store as asked, and a filter that can match nothing must
+// not be answered as if no run had failed.
+
+// recordingRunLister answers its runs and keeps the filter it was asked with.
+type recordingRunLister struct {
+	runs    []JobRun
+	filters []JobRunFilter
+}
+
+func (this *recordingRunLister) ListRuns(_ context.Context, filter JobRunFilter) ([]JobRun, error) {
+	this.filters = append(this.filters, filter)
+	return this.runs, nil
+}
+
+func jobRunReaderContext(entitlements ...string) corectx.Context {
+	ctx := corectx.NewRequestContext(context.Background())
+	grants := ds.NewSet[string]()
+	grants.AddMany(entitlements...)
+	ctx.SetPermissions(corectx.ContextPermissions{UserId: "01ADMIN0000000000000000000", Entitlements: grants})
+	return ctx
+}
+
+var readJobRuns = requestguard.BuildExpression(
+	ActionReadJobRun, ResourceCodeJobRun, requestguard.ResourceScopeDomain, nil)
+
+func TestListRunsPassesTheFilterToTheStore(t *testing.T) {
+	failed := JobRun{Id: "r1", JobName: "sweep", Node: "a", Outcome: JobRunOutcomeFailed,
+		StartedAt: time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC), Detail: "boom"}
+	lister := &recordingRunLister{runs: []JobRun{failed}}
+	admin := &JobRunAdminService{runs: lister}
+
+	result, err := admin.ListRuns(jobRunReaderContext(readJobRuns), ListJobRunsQuery{
+		JobName: "sweep", Node: "a", Outcome: JobRunOutcomeFailed, Limit: 20,
+	})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, []JobRun{failed}, result.Data.Items)
+	assert.Equal(t, []JobRunFilter{{JobName: "sweep", Node: "a", Outcome: JobRunOutcomeFailed, Limit: 20}},
+		lister.filters)
+}
+
+func TestListRunsWithoutAFilterListsEveryRun(t *testing.T) {
+	lister := &recordingRunLister{}
+	admin := &JobRunAdminService{runs: lister}
+
+	result, err := admin.ListRuns(jobRunReaderContext(readJobRuns), ListJobRunsQuery{})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, []JobRunFilter{{}}, lister.filters, "zero values do not filter")
+}
+
+func TestListRunsRefusesAnOutcomeNoRunCanHave(t *testing.T) {
+	lister := &recordingRunLister{}
+	admin := &JobRunAdminService{runs: lister}
+
+	for _, outcome := range []string{"failure", "FAILED", "canceled"} {
+		result, err := admin.ListRuns(jobRunReaderContext(readJobRuns), ListJobRunsQuery{Outcome: outcome})
+
+		require.NoError(t, err)
+		require.Equal(t, 1, result.ClientErrors.Count(), outcome)
+		assert.Equal(t, "outcome", result.ClientErrors[0].Field)
+	}
+	assert.Empty(t, lister.filters)
+}
+
+func TestListRunsNeedsTheReadPermission(t *testing.T) {
+	lister := &recordingRunLister{}
+	admin := &JobRunAdminService{runs: lister}
+	readOtherResource := requestguard.BuildExpression(
+		ActionReadJobRun, "core_other_record", requestguard.ResourceScopeDomain, nil)
+
+	result, err := admin.ListRuns(jobRunReaderContext(readOtherResource), ListJobRunsQuery{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.ClientErrors.Count())
+	assert.Empty(t, lister.filters)
+}
@@ -0,0 +1,347 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/constants"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+)
+
+// Statuses of a queued job. Pending and running jobs are live: they hold their unique key.
+const (
+	QueuedJobStatusPending   = "pending"
+	QueuedJobStatusRunning   = "running"
+	QueuedJobStatusSucceeded = "succeeded"
+	QueuedJobStatusFailed    = "failed"
+	QueuedJobStatusCanceled  = "canceled"
+)
+
+const (
+	defaultMaxAttempts = 5
+
+	// leaseMargin is added to the longest handler timeout to make the lease on a running job. A job
+	// still running past its lease belonged to an instance that stopped mid-run, and is taken over.
+	leaseMargin = time.Minute
+)
+
+// QueuedJobHandleFn processes one queued job. The payload is what the job was enqueued with.
+type QueuedJobHandleFn func(ctx context.Context, payload []byte) error
+
+// EnqueueOptions tunes one enqueued job. Zero values take the defaults.
+type EnqueueOptions struct {
+	// Delay postpones the first attempt.
+	Delay time.Duration
+
+	// Priority orders due jobs, higher first.
+	Priority int
+
+	// MaxAttempts bounds the attempts before the job is failed. It defaults to defaultMaxAttempts.
+	MaxAttempts int
+
+	// UniqueKey, when set, keeps a second job of the same type and key from being enqueued while
+	// the first is pending or running.
+	UniqueKey string
+}
+
+type EnqueueResult struct {
+	JobId string
+
+	// Duplicate is set when a live job with the same unique key already existed. JobId is then
+	// that job's.
+	Duplicate bool
+}
+
+// JobEnqueuer puts work on the queue for a worker to pick up.
+type JobEnqueuer interface {
+	Enqueue(ctx context.Context, jobType string, payload []byte, opts EnqueueOptions) (*EnqueueResult, error)
+}
+
+// JobQueueRegistry tells the workers how to process each type of job.
+type JobQueueRegistry interface {
+	Register(jobType string, handleFn QueuedJobHandleFn, timeout ...time.Duration)
+}
+
+// QueuedJobType names a type of queued job and the payload it carries, so that the producer and
+// the handler cannot disagree on either.
+//
+//	var sendReceipt = job.NewQueuedJobType[SendReceiptPayload]("paymentinvoice.send_receipt")
+//	sendReceipt.Register(registry, handleSendReceipt)
+//	sendReceipt.Enqueue(ctx, enqueuer, SendReceiptPayload{OrderId: id}, job.EnqueueOptions{})
+type QueuedJobType[TPayload any] struct {
+	name string
+}
+
+func NewQueuedJobType[TPayload any](name string) QueuedJobType[TPayload] {
+	return QueuedJobType[TPayload]{name: name}
+}
+
+func (this QueuedJobType[TPayload]) Name() string {
+	return this.name
+}
+
+func (this QueuedJobType[TPayload]) Enqueue(
+	ctx context.Context, enqueuer JobEnqueuer, payload TPayload, opts EnqueueOptions,
+) (*EnqueueResult, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("enqueue %s: %w", this.name, err)
+	}
+	return enqueuer.Enqueue(ctx, this.name, raw, opts)
+}
+
+func (this QueuedJobType[TPayload]) Register(
+	registry JobQueueRegistry, handleFn func(ctx context.Context, payload TPayload) error, timeout ...time.Duration,
+) {
+	registry.Register(this.name, func(ctx context.Context, raw []byte) error {
+		var payload TPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", this.name, err)
+		}
+		return handleFn(ctx, payload)
+	}, timeout...)
+}
+
+// ClaimedJob is a job a worker has taken to run.
+type ClaimedJob struct {
+	Id          string
+	JobType     string
+	Payload     []byte
+	Attempts    int
+	MaxAttempts int
+}
+
+// queueStore is where the queue keeps its jobs. QueuedJobStore is the one in use; the workers
+// depend on this much of it so they can be tested without a database.
+type queueStore interface {
+	Enqueue(ctx context.Context, jobType string, payload []byte, opts EnqueueOptions) (*EnqueueResult, error)
+
+	// Claim takes the most urgent due job of the given types for the node, or returns nil when
+	// there is none.
+	Claim(ctx context.Context, node string, jobTypes []string, lease time.Duration) (*ClaimedJob, error)
+
+	// The following finish a claimed job. Each is ignored once the node no longer holds the job:
+	// it was canceled, or its lease expired and another node took it over.
+	Succeed(ctx context.Context, jobId string, node string) error
+	Retry(ctx context.Context, jobId string, node string, lastError string, after time.Duration) error
+	Fail(ctx context.Context, jobId string, node string, lastError string) error
+}
+
+type queuedHandler struct {
+	handle  QueuedJobHandleFn
+	timeout time.Duration
+}
+
+// JobQueue runs the workers that process queued jobs.
+//
+// Jobs are kept in Postgres rather than in memory, so that they survive a restart and are shared
+// by every instance: whichever worker polls first takes a due job.
+type JobQueue struct {
+	logger   logging.LoggerService
+	store    queueStore
+	node     string
+	handlers map[string]queuedHandler
+
+	workers      int
+	pollInterval time.Duration
+	backoffBase  time.Duration
+	backoffCap   time.Duration
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+type JobQueueSettings struct {
+	Workers      int
+	PollInterval time.Duration
+	BackoffBase  time.Duration
+	BackoffCap   time.Duration
+}
+
+func NewJobQueue(logger logging.LoggerService, store queueStore, settings JobQueueSettings) *JobQueue {
+	return &JobQueue{
+		logger:       logger,
+		store:        store,
+		node:         nodeName(),
+		handlers:     map[string]queuedHandler{},
+		workers:      settings.Workers,
+		pollInterval: settings.PollInterval,
+		backoffBase:  settings.BackoffBase,
+		backoffCap:   settings.BackoffCap,
+	}
+}
+
+func (this *JobQueue) Enqueue(
+	ctx context.Context, jobType string, payload []byte, opts EnqueueOptions,
+) (*EnqueueResult, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	return this.store.Enqueue(ctx, jobType, payload, opts)
+}
+
+// Register must be called before Start: the workers only claim the types they know.
+func (this *JobQueue) Register(jobType string, handleFn QueuedJobHandleFn, timeout ...time.Duration) {
+	if _, ok := this.handlers[jobType]; ok {
+		panic(fmt.Sprintf("[JobQueue Register] duplicate job type: %s", jobType))
+	}
+
+	handler := queuedHandler{
+		handle:  handleFn,
+		timeout: constants.BackgroudJobTimeout,
+	}
+	if len(timeout) > 0 {
+		handler.timeout = timeout[0]
+	}
+
+	this.handlers[jobType] = handler
+	this.logger.Infof("[JobQueue] Register job type %s", jobType)
+}
+
+// Start spawns the workers. It does nothing when no job type is registered.
+func (this *JobQueue) Start() {
+	if len(this.handlers) == 0 || this.workers <= 0 {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	this.stop = stop
+	for range this.workers {
+		this.wg.Add(1)
+		go this.work(ctx)
+	}
+	this.logger.Infof("[JobQueue] %d workers started", this.workers)
+}
+
+// Stop stops the workers from claiming more jobs and waits for the jobs they are running to end.
+// A job is not interrupted, since what it has done by then is unknown; the process being killed
+// before it ends leaves it to be taken over once its lease expires.
+func (this *JobQueue) Stop() {
+	if this.stop == nil {
+		return
+	}
+	this.stop()
+	this.wg.Wait()
+	this.logger.Infof("[JobQueue] workers stopped")
+}
+
+func (this *JobQueue) work(ctx context.Context) {
+	defer this.wg.Done()
+	for {
+		// A worker that found a job looks for the next one straight away, so a backlog drains
+		// at the speed of the workers rather than of the poll.
+		if this.runNext(ctx) {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(this.pollInterval):
+		}
+	}
+}
+
+// runNext claims one due job and runs it, and reports whether there was one.
+func (this *JobQueue) runNext(ctx context.Context) bool {
+	claimed, err := this.store.Claim(ctx, this.node, this.jobTypes(), this.lease())
+	if err != nil {
+		if ctx.Err() == nil {
+			this.logger.Errorf("[JobQueue] claiming a job failed: %s", err.Error())
+		}
+		return false
+	}
+	if claimed == nil {
+		return false
+	}
+
+	this.run(*claimed)
+	return true
+}
+
+func (this *JobQueue) run(claimed ClaimedJob) {
+	// The job was running when its last holder stopped, and that holder's attempt counted.
+	if claimed.Attempts > claimed.MaxAttempts {
+		this.finish(claimed, errors.New("abandoned mid-run by a stopped instance on the last attempt"))
+		return
+	}
+
+	handler := this.handlers[claimed.JobType]
+	this.finish(claimed, this.execute(claimed, handler))
+}
+
+// execute runs the handler, turning a panic into an error so that the job is retried like any
+// other failure and the worker survives.
+func (this *JobQueue) execute(claimed ClaimedJob, handler queuedHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			this.logger.Errorf("[JobQueue] %s(%s) panicked: %v", claimed.JobType, claimed.Id, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), handler.timeout)
+	defer cancel()
+	return handler.handle(ctx, claimed.Payload)
+}
+
+func (this *JobQueue) finish(claimed ClaimedJob, jobErr error) {
+	// The job's outcome must be written even while the workers are stopping.
+	ctx := context.Background()
+
+	var err error
+	switch {
+	case jobErr == nil:
+		err = this.store.Succeed(ctx, claimed.Id, this.node)
+	case claimed.Attempts >= claimed.MaxAttempts:
+		this.logger.Errorf("[JobQueue] %s(%s) failed for good after %d attempts: %s",
+			claimed.JobType, claimed.Id, claimed.Attempts, jobErr.Error())
+		err = this.store.Fail(ctx, claimed.Id, this.node, jobErr.Error())
+	default:
+		after := this.backoff(claimed.Attempts)
+		this.logger.Warnf("[JobQueue] %s(%s) attempt %d failed, retrying in %s: %s",
+			claimed.JobType, claimed.Id, claimed.Attempts, after, jobErr.Error())
+		err = this.store.Retry(ctx, claimed.Id, this.node, jobErr.Error(), after)
+	}
+	if err != nil {
+		// The job stays running until its lease expires, and is then taken over and run again.
+		this.logger.Errorf("[JobQueue] %s(%s): the outcome could not be recorded: %s",
+			claimed.JobType, claimed.Id, err.Error())
+	}
+}
+
+// backoff is how long a job waits after its nth failed attempt: the base, doubled per attempt,
+// up to the cap.
+func (this *JobQueue) backoff(attempt int) time.Duration {
+	delay := float64(this.backoffBase) * math.Pow(2, float64(attempt-1))
+	if delay > float64(this.backoffCap) {
+		return this.backoffCap
+	}
+	return time.Duration(delay)
+}
+
+func (this *JobQueue) jobTypes() []string {
+	types := make([]string, 0, len(this.handlers))
+	for jobType := range this.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+// lease is how long a job may stay running before it is taken to be abandoned. It is one value
+// for every type, from the longest timeout, which keeps the claim a single query.
+func (this *JobQueue) lease() time.Duration {
+	longest := time.Duration(0)
+	for _, handler := range this.handlers {
+		longest = max(longest, handler.timeout)
+	}
+	return longest + leaseMargin
+}
@@ -0,0 +1,152 @@
+package job
+
+import (
+	stdErr "errors"
+
+	"github.com/labstack/echo/v5"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/httpserver"
+	m "github.com/sky-as-code/nikki-erp/modules/core/httpserver/middlewares"
+	"github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+)
+
+// The queue is one for the whole deployment, so its permissions are granted at domain scope only.
+const (
+	ResourceCodeQueuedJob = "core_queued_job"
+
+	ActionReadQueuedJob   = "read"
+	ActionRetryQueuedJob  = "retry"
+	ActionCancelQueuedJob = "cancel"
+)
+
+type ListQueuedJobsQuery struct {
+	Status  string `json:"status" query:"status"`
+	JobType string `json:"job_type" query:"job_type"`
+	Page    int    `json:"page" query:"page"`
+	Size    int    `json:"size" query:"size"`
+}
+
+type ListQueuedJobsResultData = dyn.PagedResultData[QueuedJob]
+
+type QueuedJobCommand struct {
+	Id string `json:"id" param:"id"`
+}
+
+type QueuedJobCommandResultData struct {
+	Id string `json:"id"`
+}
+
+// QueuedJobAdminService lets an administrator see the queue and put right what went wrong in it.
+type QueuedJobAdminService struct {
+	store *QueuedJobStore
+}
+
+func NewQueuedJobAdminService(store *QueuedJobStore) *QueuedJobAdminService {
+	return &QueuedJobAdminService{store: store}
+}
+
+func (this *QueuedJobAdminService) ListJobs(
+	ctx corectx.Context, query ListQueuedJobsQuery,
+) (*dyn.OpResult[ListQueuedJobsResultData], error) {
+	if cErrs := assertQueuePermission(ctx, ActionReadQueuedJob); cErrs != nil {
+		return &dyn.OpResult[ListQueuedJobsResultData]{ClientErrors: *cErrs}, nil
+	}
+
+	size := query.Size
+	if size <= 0 {
+		size = defaultQueuedJobPageSize
+	}
+	jobs, total, err := this.store.ListJobs(ctx.InnerContext(), QueuedJobFilter{
+		Status:  query.Status,
+		JobType: query.JobType,
+		Page:    query.Page,
+		Size:    size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dyn.OpResult[ListQueuedJobsResultData]{
+		Data:    ListQueuedJobsResultData{Items: jobs, Total: total, Page: query.Page, Size: size},
+		HasData: true,
+	}, nil
+}
+
+// RetryJob runs a failed or canceled job again, with its attempts reset.
+func (this *QueuedJobAdminService) RetryJob(
+	ctx corectx.Context, cmd QueuedJobCommand,
+) (*dyn.OpResult[QueuedJobCommandResultData], error) {
+	if cErrs := assertQueuePermission(ctx, ActionRetryQueuedJob); cErrs != nil {
+		return &dyn.OpResult[QueuedJobCommandResultData]{ClientErrors: *cErrs}, nil
+	}
+	return commandResult(cmd.Id, this.store.RetryJob(ctx.InnerContext(), cmd.Id))
+}
+
+// CancelJob keeps a pending job from running, or a running one from being retried.
+func (this *QueuedJobAdminService) CancelJob(
+	ctx corectx.Context, cmd QueuedJobCommand,
+) (*dyn.OpResult[QueuedJobCommandResultData], error) {
+	if cErrs := assertQueuePermission(ctx, ActionCancelQueuedJob); cErrs != nil {
+		return &dyn.OpResult[QueuedJobCommandResultData]{ClientErrors: *cErrs}, nil
+	}
+	return commandResult(cmd.Id, this.store.CancelJob(ctx.InnerContext(), cmd.Id))
+}
+
+func assertQueuePermission(ctx corectx.Context, action string) *ft.ClientErrors {
+	return requestguard.AssertPermission(ctx, requestguard.PermFor(
+		action, ResourceCodeQueuedJob, requestguard.ResourceScopeDomain,
+	))
+}
+
+// commandResult turns the store's refusals into the client errors they are.
+func commandResult(jobId string, err error) (*dyn.OpResult[QueuedJobCommandResultData], error) {
+	var cErr *ft.ClientErrorItem
+	switch {
+	case err == nil:
+		return &dyn.OpResult[QueuedJobCommandResultData]{
+			Data:    QueuedJobCommandResultData{Id: jobId},
+			HasData: true,
+		}, nil
+	case stdErr.Is(err, ErrQueuedJobNotFound):
+		cErr = ft.NewAnonymousNotFoundError()
+	case stdErr.Is(err, ErrQueuedJobWrongStatus):
+		cErr = ft.NewAnonymousBusinessViolation(
+			"core.err_queued_job_wrong_status",
+			"only a failed or canceled job can be retried, and only a pending or running job canceled",
+		)
+	case stdErr.Is(err, ErrQueuedJobKeyTaken):
+		cErr = ft.NewAnonymousBusinessViolation(
+			"core.err_queued_job_key_taken",
+			"another job with the same unique key is already queued",
+		)
+	default:
+		return nil, err
+	}
+	return &dyn.OpResult[QueuedJobCommandResultData]{ClientErrors: ft.ClientErrors{*cErr}}, nil
+}
+
+// registerQueueRoutes exposes the admin API at /v1/core/jobs.
+func registerQueueRoutes(route *echo.Group, admin *QueuedJobAdminService) {
+	routeV1 := route.Group("/v1/core/jobs", m.SmokeAuthz())
+
+	routeV1.GET("", func(echoCtx *echo.Context) error {
+		return httpserver.ServeRequest2(echoCtx, admin.ListJobs,
+			httpserver.ItsMeMario[ListQueuedJobsQuery], httpserver.ItsMeMario[ListQueuedJobsResultData],
+			httpserver.JsonOk,
+		)
+	})
+	routeV1.POST("/:id/retry", func(echoCtx *echo.Context) error {
+		return httpserver.ServeRequest2(echoCtx, admin.RetryJob,
+			httpserver.ItsMeMario[QueuedJobCommand], httpserver.ItsMeMario[QueuedJobCommandResultData],
+			httpserver.JsonOk,
+		)
+	})
+	routeV1.POST("/:id/cancel", func(echoCtx *echo.Context) error {
+		return httpserver.ServeRequest2(echoCtx, admin.CancelJob,
+			httpserver.ItsMeMario[QueuedJobCommand], httpserver.ItsMeMario[QueuedJobCommandResultData],
+			httpserver.JsonOk,
+		)
+	})
+}
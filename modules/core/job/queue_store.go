@@ -0,0 +1,286 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	stdErr "errors"
+	"time"
+
+	"github.com/lib/pq"
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// QueuedJob is one job on the queue, as the admin API shows it. Table: core_queued_jobs.
+type QueuedJob struct {
+	Id          string     `json:"id"`
+	JobType     string     `json:"job_type"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Priority    int        `json:"priority"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	UniqueKey   *string    `json:"unique_key,omitempty"`
+	LastError   *string    `json:"last_error,omitempty"`
+	LockedBy    *string    `json:"locked_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// QueuedJobFilter narrows ListJobs. Zero values do not filter.
+type QueuedJobFilter struct {
+	Status  string
+	JobType string
+	Page    int
+	Size    int
+}
+
+const defaultQueuedJobPageSize = 50
+
+// Why RetryJob or CancelJob did not change a job.
+var (
+	ErrQueuedJobNotFound    = stdErr.New("queued job not found")
+	ErrQueuedJobWrongStatus = stdErr.New("queued job is not in a status that allows this")
+	ErrQueuedJobKeyTaken    = stdErr.New("another live job holds the same unique key")
+)
+
+// QueuedJobStore keeps the queue in core_queued_jobs.
+//
+// Like JobRunStore, it writes SQL directly. Every time comparison uses the database's clock, so
+// that instances whose clocks disagree still agree on which jobs are due and which leases expired.
+type QueuedJobStore struct {
+	client orm.DbClient
+}
+
+func NewQueuedJobStore(client orm.DbClient) *QueuedJobStore {
+	return &QueuedJobStore{client: client}
+}
+
+// Enqueue inserts a job unless a live one holds its unique key, in which case it returns that one.
+func (this *QueuedJobStore) Enqueue(
+	ctx context.Context, jobType string, payload []byte, opts EnqueueOptions,
+) (*EnqueueResult, error) {
+	id, err := model.NewId()
+	if err != nil {
+		return nil, errors.Wrap(err, "QueuedJobStore.Enqueue")
+	}
+
+	// The live job that holds the key may finish between the two statements, freeing the key, so
+	// the insert is tried once more before giving up.
+	for range 2 {
+		var insertedId string
+		err = this.client.QueryRow(ctx,
+			`INSERT INTO "core_queued_jobs" (
+				"id", "job_type", "payload", "status", "priority", "attempts", "max_attempts",
+				"run_at", "unique_key", "created_at", "updated_at"
+			) VALUES ($1, $2, $3, 'pending', $4, 0, $5, now() + make_interval(secs => $6), $7, now(), now())
+			ON CONFLICT ("job_type", "unique_key") WHERE "status" IN ('pending', 'running') DO NOTHING
+			RETURNING "id"`,
+			*id, jobType, string(payload), opts.Priority, opts.MaxAttempts,
+			opts.Delay.Seconds(), nullableString(opts.UniqueKey),
+		).Scan(&insertedId)
+		if err == nil {
+			return &EnqueueResult{JobId: insertedId}, nil
+		}
+		if !stdErr.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(err, "QueuedJobStore.Enqueue")
+		}
+
+		var liveId string
+		err = this.client.QueryRow(ctx,
+			`SELECT "id" FROM "core_queued_jobs"
+			WHERE "job_type" = $1 AND "unique_key" = $2 AND "status" IN ('pending', 'running')`,
+			jobType, opts.UniqueKey,
+		).Scan(&liveId)
+		if err == nil {
+			return &EnqueueResult{JobId: liveId, Duplicate: true}, nil
+		}
+		if !stdErr.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(err, "QueuedJobStore.Enqueue")
+		}
+	}
+	return nil, errors.New("QueuedJobStore.Enqueue: the unique key kept changing hands")
+}
+
+// Claim takes a due pending job, or a running one whose lease expired, counting it as an attempt.
+// SKIP LOCKED lets the workers of every instance poll at once without waiting on each other or
+// taking the same job.
+func (this *QueuedJobStore) Claim(
+	ctx context.Context, node string, jobTypes []string, lease time.Duration,
+) (*ClaimedJob, error) {
+	var claimed ClaimedJob
+	var payload string
+	err := this.client.QueryRow(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'running', "attempts" = "attempts" + 1,
+			"locked_by" = $1, "locked_at" = now(), "updated_at" = now()
+		WHERE "id" = (
+			SELECT "id" FROM "core_queued_jobs"
+			WHERE "job_type" = ANY($2)
+				AND (("status" = 'pending' AND "run_at" <= now())
+					OR ("status" = 'running' AND "locked_at" < now() - make_interval(secs => $3)))
+			ORDER BY "priority" DESC, "run_at"
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING "id", "job_type", "payload", "attempts", "max_attempts"`,
+		node, pq.Array(jobTypes), lease.Seconds(),
+	).Scan(&claimed.Id, &claimed.JobType, &payload, &claimed.Attempts, &claimed.MaxAttempts)
+	if stdErr.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "QueuedJobStore.Claim")
+	}
+	claimed.Payload = []byte(payload)
+	return &claimed, nil
+}
+
+func (this *QueuedJobStore) Succeed(ctx context.Context, jobId string, node string) error {
+	_, err := this.client.Exec(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'succeeded', "locked_at" = NULL, "finished_at" = now(), "updated_at" = now()
+		WHERE "id" = $1 AND "locked_by" = $2 AND "status" = 'running'`,
+		jobId, node,
+	)
+	return errors.Wrap(err, "QueuedJobStore.Succeed")
+}
+
+func (this *QueuedJobStore) Retry(
+	ctx context.Context, jobId string, node string, lastError string, after time.Duration,
+) error {
+	_, err := this.client.Exec(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'pending', "last_error" = $3, "run_at" = now() + make_interval(secs => $4),
+			"locked_at" = NULL, "updated_at" = now()
+		WHERE "id" = $1 AND "locked_by" = $2 AND "status" = 'running'`,
+		jobId, node, lastError, after.Seconds(),
+	)
+	return errors.Wrap(err, "QueuedJobStore.Retry")
+}
+
+func (this *QueuedJobStore) Fail(ctx context.Context, jobId string, node string, lastError string) error {
+	_, err := this.client.Exec(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'failed', "last_error" = $3, "locked_at" = NULL, "finished_at" = now(), "updated_at" = now()
+		WHERE "id" = $1 AND "locked_by" = $2 AND "status" = 'running'`,
+		jobId, node, lastError,
+	)
+	return errors.Wrap(err, "QueuedJobStore.Fail")
+}
+
+// ListJobs returns a page of the jobs a filter selects, newest first, and how many it selects.
+func (this *QueuedJobStore) ListJobs(ctx context.Context, filter QueuedJobFilter) ([]QueuedJob, int, error) {
+	size := filter.Size
+	if size <= 0 {
+		size = defaultQueuedJobPageSize
+	}
+	page := max(filter.Page, 0)
+
+	rows, err := this.client.Query(ctx,
+		`SELECT "id", "job_type", "payload", "status", "priority", "attempts", "max_attempts", "run_at",
+			"unique_key", "last_error", "locked_by", "created_at", "updated_at", "finished_at",
+			count(*) OVER ()
+		FROM "core_queued_jobs"
+		WHERE ($1 = '' OR "status" = $1)
+			AND ($2 = '' OR "job_type" = $2)
+		ORDER BY "created_at" DESC, "id" DESC
+		LIMIT $3 OFFSET $4`,
+		filter.Status, filter.JobType, size, page*size,
+	)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "QueuedJobStore.ListJobs")
+	}
+	defer rows.Close()
+
+	jobs := []QueuedJob{}
+	total := 0
+	for rows.Next() {
+		var job QueuedJob
+		var uniqueKey, lastError, lockedBy sql.NullString
+		var finishedAt sql.NullTime
+		err := rows.Scan(
+			&job.Id, &job.JobType, &job.Payload, &job.Status, &job.Priority, &job.Attempts, &job.MaxAttempts,
+			&job.RunAt, &uniqueKey, &lastError, &lockedBy, &job.CreatedAt, &job.UpdatedAt, &finishedAt,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "QueuedJobStore.ListJobs")
+		}
+		job.UniqueKey = optionalString(uniqueKey)
+		job.LastError = optionalString(lastError)
+		job.LockedBy = optionalString(lockedBy)
+		if finishedAt.Valid {
+			job.FinishedAt = &finishedAt.Time
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, total, errors.Wrap(rows.Err(), "QueuedJobStore.ListJobs")
+}
+
+// RetryJob puts a failed or canceled job back on the queue, due now and with its attempts reset.
+func (this *QueuedJobStore) RetryJob(ctx context.Context, jobId string) error {
+	result, err := this.client.Exec(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'pending', "attempts" = 0, "run_at" = now(),
+			"locked_by" = NULL, "locked_at" = NULL, "finished_at" = NULL, "updated_at" = now()
+		WHERE "id" = $1 AND "status" IN ('failed', 'canceled')`,
+		jobId,
+	)
+	var pqErr *pq.Error
+	if stdErr.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrQueuedJobKeyTaken
+	}
+	if err != nil {
+		return errors.Wrap(err, "QueuedJobStore.RetryJob")
+	}
+	return this.explainUnchanged(ctx, result, jobId)
+}
+
+// CancelJob keeps a pending job from running. A running job is not interrupted, but its outcome is
+// no longer recorded and it is not retried.
+func (this *QueuedJobStore) CancelJob(ctx context.Context, jobId string) error {
+	result, err := this.client.Exec(ctx,
+		`UPDATE "core_queued_jobs"
+		SET "status" = 'canceled', "locked_at" = NULL, "finished_at" = now(), "updated_at" = now()
+		WHERE "id" = $1 AND "status" IN ('pending', 'running')`,
+		jobId,
+	)
+	if err != nil {
+		return errors.Wrap(err, "QueuedJobStore.CancelJob")
+	}
+	return this.explainUnchanged(ctx, result, jobId)
+}
+
+// explainUnchanged tells a job that does not exist from one in the wrong status, when an update
+// guarded by status changed nothing.
+func (this *QueuedJobStore) explainUnchanged(ctx context.Context, result sql.Result, jobId string) error {
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "QueuedJobStore.explainUnchanged")
+	}
+	if changed > 0 {
+		return nil
+	}
+
+	var status string
+	err = this.client.QueryRow(ctx, `SELECT "status" FROM "core_queued_jobs" WHERE "id" = $1`, jobId).Scan(&status)
+	if stdErr.Is(err, sql.ErrNoRows) {
+		return ErrQueuedJobNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "QueuedJobStore.explainUnchanged")
+	}
+	return ErrQueuedJobWrongStatus
+}
+
+func optionalString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
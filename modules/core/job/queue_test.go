@@ -0,0 +1,189 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+)
+
+// memoryQueue records what the workers tell the store, and hands out the jobs it is given.
+type memoryQueue struct {
+	due      []ClaimedJob
+	enqueued []EnqueueOptions
+	payloads [][]byte
+	outcomes map[string]string
+	retryIn  map[string]time.Duration
+	errors   map[string]string
+}
+
+func newMemoryQueue(due ...ClaimedJob) *memoryQueue {
+	return &memoryQueue{
+		due:      due,
+		outcomes: map[string]string{},
+		retryIn:  map[string]time.Duration{},
+		errors:   map[string]string{},
+	}
+}
+
+func (this *memoryQueue) Enqueue(
+	_ context.Context, _ string, payload []byte, opts EnqueueOptions,
+) (*EnqueueResult, error) {
+	this.enqueued = append(this.enqueued, opts)
+	this.payloads = append(this.payloads, payload)
+	return &EnqueueResult{JobId: "j1"}, nil
+}
+
+func (this *memoryQueue) Claim(context.Context, string, []string, time.Duration) (*ClaimedJob, error) {
+	if len(this.due) == 0 {
+		return nil, nil
+	}
+	claimed := this.due[0]
+	this.due = this.due[1:]
+	return &claimed, nil
+}
+
+func (this *memoryQueue) Succeed(_ context.Context, jobId string, _ string) error {
+	this.outcomes[jobId] = QueuedJobStatusSucceeded
+	return nil
+}
+
+func (this *memoryQueue) Retry(_ context.Context, jobId string, _ string, lastError string, after time.Duration) error {
+	this.outcomes[jobId] = QueuedJobStatusPending
+	this.retryIn[jobId] = after
+	this.errors[jobId] = lastError
+	return nil
+}
+
+func (this *memoryQueue) Fail(_ context.Context, jobId string, _ string, lastError string) error {
+	this.outcomes[jobId] = QueuedJobStatusFailed
+	this.errors[jobId] = lastError
+	return nil
+}
+
+func newTestQueue(store *memoryQueue) *JobQueue {
+	return NewJobQueue(logging.NewLogger(logging.LevelError), store, JobQueueSettings{
+		Workers:      1,
+		PollInterval: time.Millisecond,
+		BackoffBase:  10 * time.Second,
+		BackoffCap:   time.Minute,
+	})
+}
+
+func TestTheBackoffDoublesUpToTheCap(t *testing.T) {
+	queue := newTestQueue(newMemoryQueue())
+
+	assert.Equal(t, 10*time.Second, queue.backoff(1))
+	assert.Equal(t, 20*time.Second, queue.backoff(2))
+	assert.Equal(t, 40*time.Second, queue.backoff(3))
+	assert.Equal(t, time.Minute, queue.backoff(4))
+	assert.Equal(t, time.Minute, queue.backoff(30))
+}
+
+type receiptPayload struct {
+	OrderId string `json:"order_id"`
+}
+
+func TestATypedJobGetsThePayloadItWasEnqueuedWith(t *testing.T) {
+	store := newMemoryQueue()
+	queue := newTestQueue(store)
+	receipts := NewQueuedJobType[receiptPayload]("send_receipt")
+
+	var received receiptPayload
+	receipts.Register(queue, func(_ context.Context, payload receiptPayload) error {
+		received = payload
+		return nil
+	})
+	_, err := receipts.Enqueue(context.Background(), queue, receiptPayload{OrderId: "01JORDER"}, EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultMaxAttempts, store.enqueued[0].MaxAttempts)
+
+	store.due = []ClaimedJob{{Id: "j1", JobType: "send_receipt", Payload: store.payloads[0], Attempts: 1, MaxAttempts: 5}}
+	require.True(t, queue.runNext(context.Background()))
+
+	assert.Equal(t, "01JORDER", received.OrderId)
+	assert.Equal(t, QueuedJobStatusSucceeded, store.outcomes["j1"])
+	assert.False(t, queue.runNext(context.Background()), "the queue is empty")
+}
+
+func TestAFailedAttemptIsRetriedUntilTheLastOne(t *testing.T) {
+	store := newMemoryQueue(
+		ClaimedJob{Id: "j1", JobType: "flaky", Attempts: 2, MaxAttempts: 5},
+		ClaimedJob{Id: "j2", JobType: "flaky", Attempts: 5, MaxAttempts: 5},
+	)
+	queue := newTestQueue(store)
+	queue.Register("flaky", func(context.Context, []byte) error {
+		return errors.New("the mail server is down")
+	})
+
+	queue.runNext(context.Background())
+	queue.runNext(context.Background())
+
+	assert.Equal(t, QueuedJobStatusPending, store.outcomes["j1"])
+	assert.Equal(t, 20*time.Second, store.retryIn["j1"])
+	assert.Equal(t, QueuedJobStatusFailed, store.outcomes["j2"])
+	assert.Equal(t, "the mail server is down", store.errors["j2"])
+}
+
+func TestAPanicIsRetriedLikeAFailure(t *testing.T) {
+	store := newMemoryQueue(ClaimedJob{Id: "j1", JobType: "panicking", Attempts: 1, MaxAttempts: 5})
+	queue := newTestQueue(store)
+	queue.Register("panicking", func(context.Context, []byte) error {
+		panic("nil map")
+	})
+
+	queue.runNext(context.Background())
+
+	assert.Equal(t, QueuedJobStatusPending, store.outcomes["j1"])
+	assert.Equal(t, "panic: nil map", store.errors["j1"])
+}
+
+// A job taken over from a stopped instance has had its last attempt already, even though it never
+// finished.
+func TestAnAbandonedLastAttemptIsNotRunAgain(t *testing.T) {
+	store := newMemoryQueue(ClaimedJob{Id: "j1", JobType: "sweep", Attempts: 6, MaxAttempts: 5})
+	queue := newTestQueue(store)
+	ran := 0
+	queue.Register("sweep", func(context.Context, []byte) error {
+		ran++
+		return nil
+	})
+
+	queue.runNext(context.Background())
+
+	assert.Zero(t, ran)
+	assert.Equal(t, QueuedJobStatusFailed, store.outcomes["j1"])
+}
+
+func TestTheLeaseOutlivesTheLongestTimeout(t *testing.T) {
+	queue := newTestQueue(newMemoryQueue())
+	queue.Register("quick", func(context.Context, []byte) error { return nil }, time.Minute)
+	queue.Register("slow", func(context.Context, []byte) error { return nil }, 10*time.Minute)
+
+	assert.Equal(t, 10*time.Minute+leaseMargin, queue.lease())
+}
+
+func TestStopWaitsForTheJobsBeingRun(t *testing.T) {
+	store := newMemoryQueue(
+		ClaimedJob{Id: "j1", JobType: "sweep", Attempts: 1, MaxAttempts: 5},
+		ClaimedJob{Id: "j2", JobType: "sweep", Attempts: 1, MaxAttempts: 5},
+	)
+	queue := newTestQueue(store)
+	done := make(chan struct{}, 2)
+	queue.Register("sweep", func(context.Context, []byte) error {
+		done <- struct{}{}
+		return nil
+	})
+
+	queue.Start()
+	<-done
+	<-done
+	queue.Stop()
+
+	assert.Len(t, store.outcomes, 2)
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+func NewDocumentRenderApplicationServiceImpl(
+	renderSvc itRendering.DocumentRenderDomainService,
+) itRendering.DocumentRenderAppService {
+	return &DocumentRenderApplicationServiceImpl{renderSvc: renderSvc}
+}
+
+// DocumentRenderApplicationServiceImpl is the capability boundary other modules bind to.
+//
+// It stays a thin delegation on purpose: when Document is split into its own service, this is the
+// type a REST client replaces, and any logic living here would have to be duplicated.
+type DocumentRenderApplicationServiceImpl struct {
+	renderSvc itRendering.DocumentRenderDomainService
+}
+
+func (this *DocumentRenderApplicationServiceImpl) RenderPdf(
+	ctx corectx.Context, query itRendering.RenderPdfQuery,
+) (*itRendering.RenderPdfResult, error) {
+	return this.renderSvc.RenderPdf(ctx, query)
+}
@@ -0,0 +1,11 @@
+package constants
+
+// Action codes beyond the engine's built-in CRUD.
+//
+// There are none of document's own. Uploading a logo or a font is editing a template, so it
+// carries "update"; rendering is reached through the invoice's and the purchase order's own
+// download actions, which carry "read" on those resources. A document the caller can already read
+// grants no new power by being printed.
+const (
+	ActionUploadAsset = "upload_asset"
+)
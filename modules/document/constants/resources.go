@@ -0,0 +1,9 @@
+package constants
+
+import "github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+
+// Resource codes for authorization. Each is byte-identical to its schema name, because the dynamic
+// resource engine asserts against the schema name of the engine handling the request.
+const (
+	DocumentTemplateResource = models.DocumentTemplateSchemaName
+)
@@ -0,0 +1,159 @@
+package models
+
+import (
+	_ "embed"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// DocumentTemplate is how one organization wants one kind of printed document to look: the company
+// block, the free text above and below the lines, the colour, the paper, the logo and the font.
+// Table: document_templates.
+//
+// It holds no layout. Where the lines go and how a total is set is the renderer's, and the same for
+// every organization; what differs between organizations is identity and wording. A template that
+// could move the columns would be a template that could hide one.
+//
+// The logo and the font are not stored in the row. Their bytes live in file storage and the row
+// keeps the object keys, which upload_asset alone writes.
+const (
+	DocumentTemplateSchemaName = "document_template"
+
+	DocumentTemplateFieldId             = basemodel.FieldId
+	DocumentTemplateFieldEtag           = basemodel.FieldEtag
+	DocumentTemplateFieldOrgId          = basemodel.FieldOrgId
+	DocumentTemplateFieldDocumentType   = "document_type"
+	DocumentTemplateFieldCompanyName    = "company_name"
+	DocumentTemplateFieldCompanyAddress = "company_address"
+	DocumentTemplateFieldCompanyTaxCode = "company_tax_code"
+	DocumentTemplateFieldHeaderText     = "header_text"
+	DocumentTemplateFieldFooterText     = "footer_text"
+	DocumentTemplateFieldAccentColor    = "accent_color"
+	DocumentTemplateFieldPaperSize      = "paper_size"
+	DocumentTemplateFieldLogoObjectKey  = "logo_object_key"
+	DocumentTemplateFieldFontObjectKey  = "font_object_key"
+)
+
+type DocumentType string
+
+const (
+	DocumentTypeInvoice       = DocumentType("invoice")
+	DocumentTypePurchaseOrder = DocumentType("purchase_order")
+)
+
+type PaperSize string
+
+const (
+	PaperSizeA4     = PaperSize("a4")
+	PaperSizeLetter = PaperSize("letter")
+)
+
+//go:embed document_template.json
+var documentTemplateSchemaJson string
+
+func DocumentTemplateSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(documentTemplateSchemaJson)
+}
+
+type DocumentTemplate struct {
+	basemodel.DynamicModelBase
+}
+
+func NewDocumentTemplate() *DocumentTemplate {
+	return &DocumentTemplate{basemodel.NewDynamicModel()}
+}
+
+func NewDocumentTemplateFrom(src dmodel.DynamicFields) *DocumentTemplate {
+	return &DocumentTemplate{basemodel.NewDynamicModel(src)}
+}
+
+func (this DocumentTemplate) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(DocumentTemplateFieldOrgId)
+}
+
+func (this *DocumentTemplate) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(DocumentTemplateFieldOrgId, v)
+}
+
+func (this DocumentTemplate) GetDocumentType() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldDocumentType)
+}
+
+func (this *DocumentTemplate) SetDocumentType(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldDocumentType, v)
+}
+
+func (this DocumentTemplate) GetCompanyName() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldCompanyName)
+}
+
+func (this *DocumentTemplate) SetCompanyName(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldCompanyName, v)
+}
+
+func (this DocumentTemplate) GetCompanyAddress() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldCompanyAddress)
+}
+
+func (this *DocumentTemplate) SetCompanyAddress(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldCompanyAddress, v)
+}
+
+func (this DocumentTemplate) GetCompanyTaxCode() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldCompanyTaxCode)
+}
+
+func (this *DocumentTemplate) SetCompanyTaxCode(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldCompanyTaxCode, v)
+}
+
+func (this DocumentTemplate) GetHeaderText() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldHeaderText)
+}
+
+func (this *DocumentTemplate) SetHeaderText(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldHeaderText, v)
+}
+
+func (this DocumentTemplate) GetFooterText() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldFooterText)
+}
+
+func (this *DocumentTemplate) SetFooterText(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldFooterText, v)
+}
+
+func (this DocumentTemplate) GetAccentColor() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldAccentColor)
+}
+
+func (this *DocumentTemplate) SetAccentColor(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldAccentColor, v)
+}
+
+func (this DocumentTemplate) GetPaperSize() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldPaperSize)
+}
+
+func (this *DocumentTemplate) SetPaperSize(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldPaperSize, v)
+}
+
+func (this DocumentTemplate) GetLogoObjectKey() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldLogoObjectKey)
+}
+
+func (this *DocumentTemplate) SetLogoObjectKey(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldLogoObjectKey, v)
+}
+
+func (this DocumentTemplate) GetFontObjectKey() *string {
+	return this.GetFieldData().GetString(DocumentTemplateFieldFontObjectKey)
+}
+
+func (this *DocumentTemplate) SetFontObjectKey(v *string) {
+	this.GetFieldData().SetString(DocumentTemplateFieldFontObjectKey, v)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// The JSON model file is parsed at start-up by RegisterModels; a malformed file panics the whole
+// app rather than failing the build. These tests turn that into a test failure instead.
+
+func requireField(t *testing.T, schema *dmodel.ModelSchema, fieldName string) *dmodel.ModelField {
+	t.Helper()
+	field, ok := schema.Fields()[fieldName]
+	require.Truef(t, ok, "schema %q has no field %q", schema.Name(), fieldName)
+	return field
+}
+
+func TestTheTemplateSchemaParsesUnderItsConstantName(t *testing.T) {
+	// Normally done by CoreModule.RegisterModels during app start-up.
+	_ = basemodel.RegisterJsonBaseSchemas()
+
+	schema := DocumentTemplateSchemaBuilder().Build()
+
+	assert.Equal(t, DocumentTemplateSchemaName, schema.Name())
+	assert.Equal(t, "document_templates", schema.TableName())
+	assert.NotEmpty(t, schema.RecordLabelField())
+}
+
+// The object keys are written by upload_asset alone. A client able to set one could point its
+// template at any object in the bucket and have it embedded in a document.
+func TestTheAssetKeysCannotBeWrittenByAClient(t *testing.T) {
+	_ = basemodel.RegisterJsonBaseSchemas()
+	schema := DocumentTemplateSchemaBuilder().Build()
+
+	assert.True(t, requireField(t, schema, DocumentTemplateFieldLogoObjectKey).IsNoUpdate())
+	assert.True(t, requireField(t, schema, DocumentTemplateFieldFontObjectKey).IsNoUpdate())
+	assert.True(t, requireField(t, schema, DocumentTemplateFieldDocumentType).IsNoUpdate())
+}
@@ -0,0 +1,54 @@
+package pdf
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// FormatAmount writes an amount to a currency's decimal places, with its thousands grouped.
+//
+// The separators are fixed — "," between thousands and "." before the fraction — rather than
+// taken from a locale. A printed document is read by someone outside the organization, and one set
+// of separators that cannot be mistaken for the other is safer than a locale the reader may not
+// share: "1.000" is a thousand in Hanoi and one in Boston.
+func FormatAmount(amount decimal.Decimal, decimalPlaces int32) string {
+	if decimalPlaces < 0 {
+		decimalPlaces = 0
+	}
+	return groupThousands(amount.StringFixed(decimalPlaces))
+}
+
+// FormatQuantity writes a quantity with as many fractional digits as it carries and no more: a
+// count of 3 prints as "3", a weight of 2.5 as "2.5".
+func FormatQuantity(quantity decimal.Decimal) string {
+	return groupThousands(quantity.String())
+}
+
+// FormatPercent writes a rate as a percentage.
+func FormatPercent(rate decimal.Decimal) string {
+	return rate.String() + "%"
+}
+
+// groupThousands inserts separators into the integer part of a plain decimal string.
+func groupThousands(plain string) string {
+	sign := ""
+	if strings.HasPrefix(plain, "-") {
+		sign, plain = "-", plain[1:]
+	}
+
+	integer, fraction, hasFraction := strings.Cut(plain, ".")
+
+	var grouped strings.Builder
+	for index, digit := range integer {
+		if index > 0 && (len(integer)-index)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if hasFraction {
+		return sign + grouped.String() + "." + fraction
+	}
+	return sign + grouped.String()
+}
@@ -0,0 +1,31 @@
+package pdf
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// The amount printed is what a customer pays against, so its format is pinned: a dropped
+// separator or a lost zero reads as a different number.
+func TestAnAmountIsGroupedAndFixedToTheCurrencyPlaces(t *testing.T) {
+	for expected, testCase := range map[string]struct {
+		amount string
+		places int32
+	}{
+		"1,234,567.50": {"1234567.5", 2},
+		"1,500,000":    {"1500000", 0},
+		"999.000":      {"999", 3},
+		"-12,000.00":   {"-12000", 2},
+		"0":            {"0", 0},
+	} {
+		assert.Equal(t, expected, FormatAmount(decimal.RequireFromString(testCase.amount), testCase.places))
+	}
+}
+
+func TestAQuantityKeepsOnlyTheDigitsItCarries(t *testing.T) {
+	assert.Equal(t, "3", FormatQuantity(decimal.RequireFromString("3.000")))
+	assert.Equal(t, "2.5", FormatQuantity(decimal.RequireFromString("2.5")))
+	assert.Equal(t, "12,000", FormatQuantity(decimal.RequireFromString("12000")))
+}
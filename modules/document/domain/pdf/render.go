@@ -0,0 +1,569 @@
+// Package pdf lays a document out as a PDF.
+//
+// It is pure: it receives the document, the organization's style and the asset bytes, and returns
+// the file. Reading the template, opening the logo and deciding what a document says all happen
+// before it is called, so every layout decision here can be tested without a database or a bucket.
+//
+// The renderer is go-pdf/fpdf, chosen because it is pure Go. A renderer that drove a headless
+// browser or shelled out to wkhtmltopdf would make every deployment carry a second runtime for the
+// sake of one download button.
+package pdf
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+// Style is what an organization's template contributes to the page.
+type Style struct {
+	PaperSize   string
+	AccentColor string
+
+	CompanyName    string
+	CompanyAddress string
+	CompanyTaxCode string
+	HeaderText     string
+	FooterText     string
+
+	Logo *Image
+
+	// Font is a TrueType font. Without one the document is set in Helvetica, whose encoding covers
+	// Western European text and nothing else: a character outside it prints as a question mark
+	// rather than failing the download.
+	Font []byte
+}
+
+// Image is a logo read from file storage.
+type Image struct {
+	Content     []byte
+	ContentType string
+}
+
+// ImageTypeOf maps a MIME type to the image type fpdf embeds. An empty answer means the format
+// cannot be embedded, which upload_asset refuses before it is ever stored.
+func ImageTypeOf(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return "PNG"
+	case "image/jpeg":
+		return "JPG"
+	}
+	return ""
+}
+
+const (
+	pageMargin   = 15.0
+	footerHeight = 12.0
+	lineHeight   = 5.0
+	rowPadding   = 1.5
+	logoMaxWidth = 50.0
+	logoHeight   = 18.0
+	fontFamily   = "document"
+	coreFamily   = "Helvetica"
+	fontSizeBody = 9.0
+)
+
+// defaultAccent is the neutral grey used when a template names no colour, or one that does not
+// parse. A malformed colour is not worth failing a download over.
+var defaultAccent = rgb{90, 90, 90}
+
+// Render lays out one document.
+func Render(doc rendering.Document, style Style) ([]byte, error) {
+	writer := newWriter(style)
+	writer.writeHeader(doc)
+	writer.writePartnerAndFacts(doc)
+	writer.writeLines(doc)
+	writer.writeTotals(doc)
+	writer.writeClosingText(doc.Notes)
+	writer.writeClosingText(style.FooterText)
+
+	var out bytes.Buffer
+	if err := writer.pdf.Output(&out); err != nil {
+		return nil, errors.Wrap(err, "render document")
+	}
+	return out.Bytes(), nil
+}
+
+type rgb struct{ r, g, b int }
+
+// parseColor reads "#RRGGBB".
+func parseColor(value string) (rgb, bool) {
+	if len(value) != 7 || value[0] != '#' {
+		return rgb{}, false
+	}
+	parsed, err := strconv.ParseUint(value[1:], 16, 32)
+	if err != nil {
+		return rgb{}, false
+	}
+	return rgb{int(parsed >> 16 & 0xFF), int(parsed >> 8 & 0xFF), int(parsed & 0xFF)}, true
+}
+
+// writer carries the page being drawn and how text is encoded onto it.
+type writer struct {
+	pdf    *fpdf.Fpdf
+	style  Style
+	accent rgb
+	family string
+
+	// encode turns a Go string into what the current font expects: itself for a TrueType font,
+	// cp1252 bytes for the core Helvetica.
+	encode    func(string) string
+	multibyte bool
+}
+
+func newWriter(style Style) *writer {
+	size := "A4"
+	if style.PaperSize == "letter" {
+		size = "Letter"
+	}
+
+	pdf := fpdf.New("P", "mm", size, "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	// Page breaks are taken by the writer rather than by fpdf, because a table row is several
+	// cells side by side and fpdf would break in the middle of one.
+	pdf.SetAutoPageBreak(false, pageMargin)
+	pdf.AliasNbPages("")
+
+	this := &writer{pdf: pdf, style: style, accent: defaultAccent}
+	if accent, ok := parseColor(style.AccentColor); ok {
+		this.accent = accent
+	}
+
+	if len(style.Font) > 0 {
+		// The one face is registered under every style the layout asks for: a template supplies
+		// one font file, and a heading set in the regular face is better than one that fails.
+		for _, fontStyle := range []string{"", "B", "I"} {
+			pdf.AddUTF8FontFromBytes(fontFamily, fontStyle, style.Font)
+		}
+		// fpdf logs a font it cannot parse rather than failing on it; selecting it is what fails.
+		pdf.SetFont(fontFamily, "", fontSizeBody)
+		this.family = fontFamily
+		this.encode = func(text string) string { return text }
+		this.multibyte = true
+	}
+	if this.family == "" || pdf.Err() {
+		// A font that does not parse leaves fpdf in an error state that would fail the whole
+		// document, so it is dropped and the document is set in Helvetica instead.
+		pdf.ClearError()
+		this.family = coreFamily
+		this.encode = pdf.UnicodeTranslatorFromDescriptor("")
+		this.multibyte = false
+	}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-footerHeight)
+		this.setFont("I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, lineHeight, this.encode("Page "+strconv.Itoa(pdf.PageNo())+" / {nb}"),
+			"", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	return this
+}
+
+func (this *writer) setFont(fontStyle string, size float64) {
+	this.pdf.SetFont(this.family, fontStyle, size)
+}
+
+func (this *writer) contentWidth() float64 {
+	width, _ := this.pdf.GetPageSize()
+	return width - 2*pageMargin
+}
+
+// ensureRoom starts a new page when the next height would run into the footer, and reports
+// whether it did.
+func (this *writer) ensureRoom(height float64) bool {
+	_, pageHeight := this.pdf.GetPageSize()
+	if this.pdf.GetY()+height <= pageHeight-pageMargin-footerHeight {
+		return false
+	}
+	this.pdf.AddPage()
+	return true
+}
+
+// writeHeader draws the logo on the left and the company block on the right, then the title.
+func (this *writer) writeHeader(doc rendering.Document) {
+	pdf := this.pdf
+	top := pdf.GetY()
+	logoBottom := top
+
+	if logo := this.style.Logo; logo != nil {
+		if imageType := ImageTypeOf(logo.ContentType); imageType != "" {
+			options := fpdf.ImageOptions{ImageType: imageType}
+			info := pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(logo.Content))
+			if pdf.Err() {
+				// An image that does not decode is dropped rather than failing the document: the
+				// invoice still has to go out.
+				pdf.ClearError()
+			} else if info != nil {
+				width, height := info.Extent()
+				drawWidth := logoHeight * width / height
+				drawHeight := logoHeight
+				if drawWidth > logoMaxWidth {
+					drawWidth, drawHeight = logoMaxWidth, logoMaxWidth*height/width
+				}
+				pdf.ImageOptions("logo", pageMargin, top, drawWidth, drawHeight, false, options, 0, "")
+				logoBottom = top + drawHeight
+			}
+		}
+	}
+
+	companyWidth := this.contentWidth() / 2
+	companyLeft := pageMargin + this.contentWidth() - companyWidth
+	pdf.SetXY(companyLeft, top)
+	if this.style.CompanyName != "" {
+		this.setFont("B", 12)
+		pdf.SetTextColor(0, 0, 0)
+		this.writeWrapped(companyLeft, companyWidth, this.style.CompanyName, "R")
+	}
+	this.setFont("", fontSizeBody)
+	pdf.SetTextColor(60, 60, 60)
+	for _, text := range []string{
+		this.style.CompanyAddress,
+		labelled("Tax code", this.style.CompanyTaxCode),
+		this.style.HeaderText,
+	} {
+		if text != "" {
+			this.writeWrapped(companyLeft, companyWidth, text, "R")
+		}
+	}
+
+	pdf.SetY(max(pdf.GetY(), logoBottom) + 6)
+
+	this.setFont("B", 18)
+	pdf.SetTextColor(this.accent.r, this.accent.g, this.accent.b)
+	pdf.CellFormat(this.contentWidth()/2, 9, this.encode(doc.Title), "", 0, "L", false, 0, "")
+	this.setFont("B", 12)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(this.contentWidth()/2, 9, this.encode(doc.Number), "", 1, "R", false, 0, "")
+	pdf.Ln(3)
+}
+
+// writePartnerAndFacts draws who the document is addressed to on the left, and the labelled facts
+// on the right.
+func (this *writer) writePartnerAndFacts(doc rendering.Document) {
+	pdf := this.pdf
+	top := pdf.GetY()
+	half := this.contentWidth() / 2
+
+	pdf.SetXY(pageMargin, top)
+	if doc.Partner.Heading != "" {
+		this.setFont("B", fontSizeBody)
+		pdf.SetTextColor(this.accent.r, this.accent.g, this.accent.b)
+		this.writeWrapped(pageMargin, half-4, doc.Partner.Heading, "L")
+	}
+	pdf.SetTextColor(0, 0, 0)
+	if doc.Partner.Name != "" {
+		this.setFont("B", 10)
+		this.writeWrapped(pageMargin, half-4, doc.Partner.Name, "L")
+	}
+	this.setFont("", fontSizeBody)
+	for _, text := range []string{
+		labelled("Tax code", doc.Partner.TaxCode),
+		doc.Partner.Address,
+	} {
+		if text != "" {
+			this.writeWrapped(pageMargin, half-4, text, "L")
+		}
+	}
+	partnerBottom := pdf.GetY()
+
+	pdf.SetY(top)
+	labelWidth := half * 0.45
+	for _, fact := range doc.Facts {
+		if fact.Value == "" {
+			continue
+		}
+		pdf.SetX(pageMargin + half)
+		this.setFont("B", fontSizeBody)
+		pdf.CellFormat(labelWidth, lineHeight, this.encode(fact.Label), "", 0, "L", false, 0, "")
+		this.setFont("", fontSizeBody)
+		pdf.CellFormat(half-labelWidth, lineHeight, this.encode(fact.Value), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetY(max(pdf.GetY(), partnerBottom) + 6)
+}
+
+// column is one column of the line table.
+type column struct {
+	heading string
+	width   float64
+	align   string
+	value   func(line rendering.Line) string
+}
+
+// lineColumns decides the table's columns. A column no line fills is left out, so an invoice does
+// not print an empty discount column and a purchase order an empty tax-rate one.
+func lineColumns(doc rendering.Document, contentWidth float64) []column {
+	places := doc.Currency.DecimalPlaces
+	optional := func(pick func(rendering.Line) bool) bool {
+		for _, line := range doc.Lines {
+			if line.Kind == rendering.LineKindItem && pick(line) {
+				return true
+			}
+		}
+		return false
+	}
+
+	columns := []column{
+		{heading: "Quantity", width: 20, align: "R", value: func(line rendering.Line) string {
+			return FormatQuantity(line.Quantity)
+		}},
+	}
+	if optional(func(line rendering.Line) bool { return line.Unit != "" }) {
+		columns = append(columns, column{heading: "Unit", width: 15, align: "L",
+			value: func(line rendering.Line) string { return line.Unit }})
+	}
+	columns = append(columns, column{heading: "Unit price", width: 27, align: "R",
+		value: func(line rendering.Line) string { return FormatAmount(line.UnitPrice, places) }})
+	if optional(func(line rendering.Line) bool { return line.DiscountPercent != nil }) {
+		columns = append(columns, column{heading: "Disc.", width: 15, align: "R",
+			value: func(line rendering.Line) string { return percentOrBlank(line.DiscountPercent) }})
+	}
+	if optional(func(line rendering.Line) bool { return line.TaxRatePercent != nil }) {
+		columns = append(columns, column{heading: "Tax", width: 15, align: "R",
+			value: func(line rendering.Line) string { return percentOrBlank(line.TaxRatePercent) }})
+	} else if optional(func(line rendering.Line) bool { return line.TaxAmount != nil }) {
+		columns = append(columns, column{heading: "Tax", width: 25, align: "R",
+			value: func(line rendering.Line) string {
+				if line.TaxAmount == nil {
+					return ""
+				}
+				return FormatAmount(*line.TaxAmount, places)
+			}})
+	}
+	columns = append(columns, column{heading: "Amount", width: 30, align: "R",
+		value: func(line rendering.Line) string { return FormatAmount(line.Amount, places) }})
+
+	fixed := 0.0
+	for _, col := range columns {
+		fixed += col.width
+	}
+	description := column{heading: "Description", width: contentWidth - fixed, align: "L",
+		value: func(line rendering.Line) string { return line.Description }}
+	return append([]column{description}, columns...)
+}
+
+func (this *writer) writeTableHeading(columns []column) {
+	pdf := this.pdf
+	this.setFont("B", fontSizeBody)
+	pdf.SetFillColor(this.accent.r, this.accent.g, this.accent.b)
+	pdf.SetTextColor(255, 255, 255)
+	for _, col := range columns {
+		pdf.CellFormat(col.width, lineHeight+2*rowPadding, this.encode(col.heading),
+			"", 0, col.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// writeLines draws the line table, repeating its heading on every page it runs onto.
+//
+// A section, subsection or note spans the whole table and prints its description alone. It is
+// what those line types exist for: the purchase order uses them to organize the printed document,
+// so giving them empty amount cells would print a table of zeros between the real lines.
+func (this *writer) writeLines(doc rendering.Document) {
+	pdf := this.pdf
+	columns := lineColumns(doc, this.contentWidth())
+	this.ensureRoom(3 * lineHeight)
+	this.writeTableHeading(columns)
+
+	pdf.SetDrawColor(210, 210, 210)
+	for _, line := range doc.Lines {
+		fontStyle, wrapped, width := this.lineLayout(line, columns)
+		height := float64(len(wrapped))*lineHeight + 2*rowPadding
+		if this.ensureRoom(height) {
+			this.writeTableHeading(columns)
+		}
+
+		top := pdf.GetY()
+		switch line.Kind {
+		case rendering.LineKindItem, "":
+			this.setFont("", fontSizeBody)
+			this.writeCell(pageMargin, top, width, height, wrapped, "L", false)
+			left := pageMargin + width
+			for _, col := range columns[1:] {
+				this.writeCell(left, top, col.width, height,
+					[]string{this.encode(col.value(line))}, col.align, false)
+				left += col.width
+			}
+		default:
+			this.setFont(fontStyle, fontSizeBody)
+			fill := line.Kind == rendering.LineKindSection
+			if fill {
+				pdf.SetFillColor(240, 240, 240)
+			}
+			if line.Kind == rendering.LineKindNote {
+				pdf.SetTextColor(90, 90, 90)
+			}
+			this.writeCell(pageMargin, top, width, height, wrapped, "L", fill)
+			pdf.SetTextColor(0, 0, 0)
+		}
+		pdf.Line(pageMargin, top+height, pageMargin+this.contentWidth(), top+height)
+		pdf.SetXY(pageMargin, top+height)
+	}
+	pdf.Ln(4)
+}
+
+// lineLayout decides how one line's description wraps, which depends on the width it is given and
+// on the face it is set in.
+func (this *writer) lineLayout(line rendering.Line, columns []column) (string, []string, float64) {
+	fontStyle, width := "", columns[0].width
+	switch line.Kind {
+	case rendering.LineKindSection:
+		fontStyle, width = "B", this.contentWidth()
+	case rendering.LineKindSubsection:
+		fontStyle, width = "B", this.contentWidth()
+	case rendering.LineKindNote:
+		fontStyle, width = "I", this.contentWidth()
+	}
+	this.setFont(fontStyle, fontSizeBody)
+	wrapped := this.wrap(line.Description, width-2*this.pdf.GetCellMargin())
+	if len(wrapped) == 0 {
+		wrapped = []string{""}
+	}
+	return fontStyle, wrapped, width
+}
+
+// writeCell draws one table cell of a row whose height the tallest cell decided.
+func (this *writer) writeCell(
+	left float64, top float64, width float64, height float64, lines []string, align string, fill bool,
+) {
+	pdf := this.pdf
+	if fill {
+		pdf.Rect(left, top, width, height, "F")
+	}
+	for index, text := range lines {
+		pdf.SetXY(left, top+rowPadding+float64(index)*lineHeight)
+		pdf.CellFormat(width, lineHeight, text, "", 0, align, false, 0, "")
+	}
+}
+
+// writeTotals draws the totals right-aligned under the table, the emphasized ones in bold above a
+// rule.
+func (this *writer) writeTotals(doc rendering.Document) {
+	pdf := this.pdf
+	labelWidth, valueWidth := 45.0, 40.0
+	left := pageMargin + this.contentWidth() - labelWidth - valueWidth
+	suffix := ""
+	if doc.Currency.Code != "" {
+		suffix = " " + doc.Currency.Code
+	}
+
+	for _, total := range doc.Totals {
+		this.ensureRoom(lineHeight + 2)
+		top := pdf.GetY()
+		fontStyle := ""
+		if total.Emphasis {
+			fontStyle = "B"
+			pdf.SetDrawColor(this.accent.r, this.accent.g, this.accent.b)
+			pdf.Line(left, top, left+labelWidth+valueWidth, top)
+		}
+		this.setFont(fontStyle, 10)
+		pdf.SetXY(left, top+0.5)
+		pdf.CellFormat(labelWidth, lineHeight+1, this.encode(total.Label), "", 0, "L", false, 0, "")
+		pdf.CellFormat(valueWidth, lineHeight+1,
+			this.encode(FormatAmount(total.Amount, doc.Currency.DecimalPlaces)+suffix),
+			"", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+// writeClosingText draws a free-text block across the page: the document's notes, then the
+// template's footer.
+func (this *writer) writeClosingText(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	this.setFont("", fontSizeBody)
+	this.pdf.SetTextColor(60, 60, 60)
+	for _, line := range this.wrap(text, this.contentWidth()-2*this.pdf.GetCellMargin()) {
+		this.ensureRoom(lineHeight)
+		this.pdf.SetX(pageMargin)
+		this.pdf.CellFormat(this.contentWidth(), lineHeight, line, "", 1, "L", false, 0, "")
+	}
+	this.pdf.SetTextColor(0, 0, 0)
+	this.pdf.Ln(3)
+}
+
+// writeWrapped writes text wrapped to a width, one line per row, starting at the current Y.
+func (this *writer) writeWrapped(left float64, width float64, text string, align string) {
+	for _, line := range this.wrap(text, width-2*this.pdf.GetCellMargin()) {
+		this.pdf.SetX(left)
+		this.pdf.CellFormat(width, lineHeight, line, "", 1, align, false, 0, "")
+	}
+}
+
+// wrap encodes text for the current font and breaks it into lines no wider than width.
+//
+// It is the writer's own rather than fpdf's SplitText or SplitLines: the first indexes the font's
+// width table by rune and panics on a character past the core fonts' 256, the second counts bytes
+// and cuts a multi-byte character in half. Measuring whole words through GetStringWidth is correct
+// for both faces.
+func (this *writer) wrap(text string, width float64) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		current := ""
+		for _, word := range strings.Fields(this.encode(paragraph)) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if this.pdf.GetStringWidth(candidate) <= width {
+				current = candidate
+				continue
+			}
+			if current != "" {
+				lines = append(lines, current)
+			}
+			current = word
+			for this.pdf.GetStringWidth(current) > width {
+				head, tail := this.splitWord(current, width)
+				lines = append(lines, head)
+				current = tail
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// splitWord cuts a word too long for a line — a URL, an account number — at the last character
+// that fits. At least one character always goes, so a width narrower than one glyph still ends.
+func (this *writer) splitWord(word string, width float64) (string, string) {
+	cut := 0
+	for cut < len(word) {
+		next := cut + 1
+		if this.multibyte {
+			_, size := utf8.DecodeRuneInString(word[cut:])
+			next = cut + size
+		}
+		if cut > 0 && this.pdf.GetStringWidth(word[:next]) > width {
+			break
+		}
+		cut = next
+	}
+	return word[:cut], word[cut:]
+}
+
+func labelled(label string, value string) string {
+	if value == "" {
+		return ""
+	}
+	return label + ": " + value
+}
+
+func percentOrBlank(rate *decimal.Decimal) string {
+	if rate == nil {
+		return ""
+	}
+	return FormatPercent(*rate)
+}
@@ -0,0 +1,136 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+func sampleDocument(lineCount int) rendering.Document {
+	rate := decimal.RequireFromString("10")
+	lines := []rendering.Line{
+		{Kind: rendering.LineKindSection, Description: "Hardware"},
+	}
+	for index := 0; index < lineCount; index++ {
+		lines = append(lines, rendering.Line{
+			Kind:           rendering.LineKindItem,
+			Description:    "Đèn LED chiếu sáng — a description long enough to wrap onto a second line of the table",
+			Quantity:       decimal.RequireFromString("2"),
+			Unit:           "pcs",
+			UnitPrice:      decimal.RequireFromString("150000"),
+			TaxRatePercent: &rate,
+			Amount:         decimal.RequireFromString("300000"),
+		})
+	}
+	lines = append(lines, rendering.Line{Kind: rendering.LineKindNote, Description: "Delivered in two parts."})
+
+	return rendering.Document{
+		Title:  "Invoice",
+		Number: "INV-2026-000001",
+		Facts:  []rendering.Fact{{Label: "Issued", Value: "2026-10-19"}},
+		Partner: rendering.Partner{
+			Heading: "Bill to", Name: "Công ty TNHH Ánh Sáng", Address: "12 Lý Thường Kiệt, Hà Nội",
+		},
+		Currency: rendering.Currency{Code: "VND", DecimalPlaces: 0},
+		Lines:    lines,
+		Totals: []rendering.Total{
+			{Label: "Subtotal", Amount: decimal.RequireFromString("300000")},
+			{Label: "Total", Amount: decimal.RequireFromString("330000"), Emphasis: true},
+		},
+	}
+}
+
+func pageCount(content []byte) int {
+	return bytes.Count(content, []byte("/Type /Page\n"))
+}
+
+func TestADocumentRendersAsAPdf(t *testing.T) {
+	content, err := Render(sampleDocument(3), Style{CompanyName: "Nikki Trading", AccentColor: "#1E6FD9"})
+
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")))
+	assert.Equal(t, 1, pageCount(content))
+}
+
+// A long document runs onto further pages rather than off the bottom of the first.
+func TestALongDocumentBreaksOntoMorePages(t *testing.T) {
+	content, err := Render(sampleDocument(80), Style{})
+
+	require.NoError(t, err)
+	assert.Greater(t, pageCount(content), 1)
+}
+
+// Text outside Helvetica's encoding without a font uploaded is degraded, not fatal: the invoice
+// still has to go out.
+func TestTextTheCoreFontCannotEncodeDoesNotFailTheDocument(t *testing.T) {
+	doc := sampleDocument(1)
+	doc.Partner.Name = "株式会社 日本"
+
+	_, err := Render(doc, Style{})
+
+	assert.NoError(t, err)
+}
+
+// A template's assets come from storage and may be anything that was uploaded before the checks
+// existed. One that does not decode is dropped, and the document renders without it.
+func TestABrokenAssetIsDroppedRatherThanFailingTheDocument(t *testing.T) {
+	style := Style{
+		Logo: &Image{Content: []byte("not a png"), ContentType: "image/png"},
+		Font: []byte("not a font"),
+	}
+
+	content, err := Render(sampleDocument(1), style)
+
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")))
+}
+
+func TestALogoIsEmbedded(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	logo.Set(1, 1, color.RGBA{R: 200, A: 255})
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, logo))
+
+	content, err := Render(sampleDocument(1), Style{Logo: &Image{Content: encoded.Bytes(), ContentType: "image/png"}})
+
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "/Subtype /Image")
+}
+
+// The optional columns follow the lines: an invoice prints no discount column, and a document whose
+// lines carry a tax amount rather than a rate prints the amount.
+func TestOnlyTheColumnsTheLinesFillArePrinted(t *testing.T) {
+	headings := func(doc rendering.Document) string {
+		names := []string{}
+		for _, col := range lineColumns(doc, 180) {
+			names = append(names, col.heading)
+		}
+		return strings.Join(names, ",")
+	}
+
+	assert.Equal(t, "Description,Quantity,Unit,Unit price,Tax,Amount", headings(sampleDocument(1)))
+
+	discount, tax := decimal.RequireFromString("5"), decimal.RequireFromString("9.5")
+	purchase := rendering.Document{Lines: []rendering.Line{
+		{Kind: rendering.LineKindItem, DiscountPercent: &discount, TaxAmount: &tax},
+	}}
+	assert.Equal(t, "Description,Quantity,Unit price,Disc.,Tax,Amount", headings(purchase))
+}
+
+func TestAMalformedAccentFallsBackToGrey(t *testing.T) {
+	_, ok := parseColor("blue")
+	assert.False(t, ok)
+
+	accent, ok := parseColor("#1E6FD9")
+	assert.True(t, ok)
+	assert.Equal(t, rgb{0x1E, 0x6F, 0xD9}, accent)
+}
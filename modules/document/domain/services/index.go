@@ -0,0 +1,10 @@
+package services
+
+import deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+
+func InitDomainServices() error {
+	return deps.Register(
+		NewDocumentRenderDomainServiceImpl,
+		NewTemplateAssetDomainService,
+	)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"go.bryk.io/pkg/errors"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/pdf"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+func NewDocumentRenderDomainServiceImpl(
+	storage filestorage.FileStorageAdapter,
+) itRendering.DocumentRenderDomainService {
+	return &DocumentRenderDomainServiceImpl{storage: storage}
+}
+
+// DocumentRenderDomainServiceImpl reads an organization's template and its assets, and hands both to
+// the renderer with the document to print.
+type DocumentRenderDomainServiceImpl struct {
+	storage filestorage.FileStorageAdapter
+}
+
+// RenderPdf renders one document with its organization's template.
+//
+// An asset that cannot be read — an object removed from the bucket behind the row's back, or one
+// grown past its cap — is dropped and the document rendered without it. The download is how an
+// invoice reaches a customer, and a missing logo is a lesser failure than a missing invoice.
+func (this *DocumentRenderDomainServiceImpl) RenderPdf(
+	ctx corectx.Context, query itRendering.RenderPdfQuery,
+) (*itRendering.RenderPdfResult, error) {
+	if !isDocumentType(query.DocumentType) {
+		vErrs := ft.NewClientErrors()
+		vErrs.Append(*ft.NewBusinessViolation(models.DocumentTemplateFieldDocumentType,
+			"document.unknown_document_type",
+			"'"+query.DocumentType+"' is not a document type a template can be kept for"))
+		return &itRendering.RenderPdfResult{ClientErrors: *vErrs}, nil
+	}
+
+	template, err := findTemplateOf(ctx, query.OrgId, query.DocumentType)
+	if err != nil {
+		return nil, errors.Wrap(err, "render pdf")
+	}
+
+	style := pdf.Style{}
+	if template != nil {
+		style = this.styleOf(ctx, template)
+	}
+
+	content, err := pdf.Render(query.Document, style)
+	if err != nil {
+		return nil, errors.Wrap(err, "render pdf")
+	}
+
+	return &itRendering.RenderPdfResult{
+		HasData: true,
+		Data: itRendering.RenderPdfResultData{
+			FileName: fileNameOf(query.Document),
+			Content:  content,
+		},
+	}, nil
+}
+
+func (this *DocumentRenderDomainServiceImpl) styleOf(
+	ctx corectx.Context, template *models.DocumentTemplate,
+) pdf.Style {
+	style := pdf.Style{
+		PaperSize:      derefString(template.GetPaperSize()),
+		AccentColor:    derefString(template.GetAccentColor()),
+		CompanyName:    derefString(template.GetCompanyName()),
+		CompanyAddress: derefString(template.GetCompanyAddress()),
+		CompanyTaxCode: derefString(template.GetCompanyTaxCode()),
+		HeaderText:     derefString(template.GetHeaderText()),
+		FooterText:     derefString(template.GetFooterText()),
+	}
+
+	if logoKey := derefString(template.GetLogoObjectKey()); logoKey != "" {
+		if content, ok := this.readAsset(ctx, logoKey, maxLogoSize); ok {
+			style.Logo = &pdf.Image{Content: content, ContentType: sniffContentType(content)}
+		}
+	}
+	if fontKey := derefString(template.GetFontObjectKey()); fontKey != "" {
+		if content, ok := this.readAsset(ctx, fontKey, maxFontSize); ok {
+			style.Font = content
+		}
+	}
+	return style
+}
+
+// readAsset reads one stored asset whole, refusing one larger than its cap. The cap is checked
+// again on the way out because the bucket, not this module, is what holds the bytes.
+func (this *DocumentRenderDomainServiceImpl) readAsset(
+	ctx corectx.Context, objectKey string, maxSize int64,
+) ([]byte, bool) {
+	object, err := this.storage.Open(ctx, objectKey, "")
+	if err != nil || object == nil || object.Body == nil {
+		return nil, false
+	}
+	defer object.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(object.Body, maxSize+1))
+	if err != nil || int64(len(content)) > maxSize {
+		return nil, false
+	}
+	return content, true
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileNameOf names the download after what it is, so a saved file reads "Invoice-INV-2026-000001.pdf"
+// rather than "download.pdf". Anything outside a conservative set becomes a hyphen: the name ends up
+// in a header and on whatever file system the reader saves to.
+func fileNameOf(doc itRendering.Document) string {
+	parts := []string{}
+	for _, part := range []string{doc.Title, doc.Number} {
+		if cleaned := strings.Trim(unsafeFileNameChars.ReplaceAllString(part, "-"), "-"); cleaned != "" {
+			parts = append(parts, cleaned)
+		}
+	}
+	if len(parts) == 0 {
+		return "document.pdf"
+	}
+	return strings.Join(parts, "-") + ".pdf"
+}
+
+func isDocumentType(documentType string) bool {
+	switch models.DocumentType(documentType) {
+	case models.DocumentTypeInvoice, models.DocumentTypePurchaseOrder:
+		return true
+	}
+	return false
+}
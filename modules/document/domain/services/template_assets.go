@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+
+	"github.com/gabriel-vasile/mimetype"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/objectkey"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/pdf"
+)
+
+// The assets a template can carry.
+const (
+	AssetKindLogo = "logo"
+	AssetKindFont = "font"
+)
+
+// Caps on what an asset may weigh. Both are read whole on every download, so they are bounded by
+// what a download can afford rather than by what a bucket can hold: a logo larger than a megabyte is
+// a photograph, and a font larger than eight is a CJK family nobody meant to embed whole.
+const (
+	maxLogoSize int64 = 1 << 20
+	maxFontSize int64 = 8 << 20
+)
+
+// objectKeyPrefix is where template assets live in the bucket, under the organization that owns them.
+const objectKeyPrefix = "document/templates"
+
+// TemplateAssetDomainService stores the logo and the font a template is printed with.
+type TemplateAssetDomainService struct {
+	storage filestorage.FileStorageAdapter
+}
+
+func NewTemplateAssetDomainService(storage filestorage.FileStorageAdapter) *TemplateAssetDomainService {
+	return &TemplateAssetDomainService{storage: storage}
+}
+
+// UploadAssetCommand carries one asset for one template.
+type UploadAssetCommand struct {
+	TemplateId string
+	Kind       string
+	FileName   string
+	Content    []byte
+}
+
+// UploadAssetResult is where the asset was stored and what it was recognized as.
+type UploadAssetResult struct {
+	TemplateId  string
+	Kind        string
+	ObjectKey   string
+	ContentType string
+	Size        int64
+}
+
+// UploadAsset stores a logo or a font and points the template at it.
+//
+// The type is sniffed from the bytes rather than taken from the file name or a client's header,
+// because the renderer embeds whatever it is given: a "logo.png" that is really a GIF is refused
+// here, not discovered on a customer's invoice.
+//
+// The object is stored before the row is updated, and the previous object removed only after. A
+// failure between the two leaves an unreferenced object in the bucket, which costs storage; the
+// other order would leave a template pointing at nothing, which costs the logo on every document.
+func (this *TemplateAssetDomainService) UploadAsset(
+	ctx corectx.Context, cmd UploadAssetCommand,
+) (*UploadAssetResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+
+	contentType := sniffContentType(cmd.Content)
+	validateAsset(cmd, contentType, vErrs)
+	if vErrs.Count() > 0 {
+		return nil, vErrs, nil
+	}
+
+	template, err := findTemplateById(ctx, cmd.TemplateId)
+	if err != nil {
+		return nil, vErrs, err
+	}
+	if template == nil {
+		vErrs.Append(*ft.NewBusinessViolation(models.DocumentTemplateFieldId,
+			"document.template_not_found", "no document template with id '"+cmd.TemplateId+"'"))
+		return nil, vErrs, nil
+	}
+
+	orgId := ""
+	if template.GetOrgId() != nil {
+		orgId = string(*template.GetOrgId())
+	}
+	objectKey, err := objectkey.Build(objectKeyPrefix+"/"+orgId+"/"+cmd.Kind, cmd.FileName)
+	if err != nil {
+		return nil, vErrs, errors.Wrap(err, "upload asset")
+	}
+
+	size := int64(len(cmd.Content))
+	err = this.storage.Put(ctx, objectKey, bytes.NewReader(cmd.Content),
+		filestorage.NewPutOptions(contentType, size))
+	if err != nil {
+		return nil, vErrs, errors.Wrap(err, "upload asset")
+	}
+
+	keyField, previousKey := models.DocumentTemplateFieldLogoObjectKey, template.GetLogoObjectKey()
+	if cmd.Kind == AssetKindFont {
+		keyField, previousKey = models.DocumentTemplateFieldFontObjectKey, template.GetFontObjectKey()
+	}
+
+	if err := writeTemplateFields(ctx, cmd.TemplateId, dmodel.DynamicFields{keyField: objectKey}); err != nil {
+		// Best effort: the object was never referenced, so nothing is lost if this fails too.
+		_ = this.storage.Remove(ctx, objectKey)
+		return nil, vErrs, err
+	}
+
+	if previous := derefString(previousKey); previous != "" && previous != objectKey {
+		// Best effort for the same reason: the template no longer points at it.
+		_ = this.storage.Remove(ctx, previous)
+	}
+
+	return &UploadAssetResult{
+		TemplateId:  cmd.TemplateId,
+		Kind:        cmd.Kind,
+		ObjectKey:   objectKey,
+		ContentType: contentType,
+		Size:        size,
+	}, vErrs, nil
+}
+
+// validateAsset checks an asset against the rules of its kind. It is separate from UploadAsset so
+// the rules can be pinned without a bucket.
+func validateAsset(cmd UploadAssetCommand, contentType string, vErrs *ft.ClientErrors) {
+	if cmd.TemplateId == "" {
+		vErrs.Append(*ft.NewBusinessViolation(models.DocumentTemplateFieldId,
+			"document.template_required", "no document template was identified"))
+	}
+
+	var maxSize int64
+	switch cmd.Kind {
+	case AssetKindLogo:
+		maxSize = maxLogoSize
+		if len(cmd.Content) > 0 && pdf.ImageTypeOf(contentType) == "" {
+			vErrs.Append(*ft.NewValidationError("content", ft.ErrorKey("err_file_type_not_allowed"),
+				"file type {{actual}} is not one of {{allowed}}",
+				map[string]any{"allowed": []string{"image/png", "image/jpeg"}, "actual": contentType}))
+		}
+	case AssetKindFont:
+		maxSize = maxFontSize
+		if len(cmd.Content) > 0 && contentType != "font/ttf" {
+			vErrs.Append(*ft.NewValidationError("content", ft.ErrorKey("err_file_type_not_allowed"),
+				"file type {{actual}} is not one of {{allowed}}",
+				map[string]any{"allowed": []string{"font/ttf"}, "actual": contentType}))
+		}
+	default:
+		vErrs.Append(*ft.NewBusinessViolation("kind", "document.unknown_asset_kind",
+			"an asset is either '"+AssetKindLogo+"' or '"+AssetKindFont+"'"))
+		return
+	}
+
+	size := int64(len(cmd.Content))
+	if size == 0 {
+		vErrs.Append(*ft.NewBusinessViolation("content", "document.asset_empty", "the file is empty"))
+	}
+	if size > maxSize {
+		vErrs.Append(*ft.NewValidationError("content", ft.ErrorKey("err_file_too_large"),
+			"file size {{actual_size}} exceeds the maximum of {{max_size}} bytes",
+			map[string]any{"max_size": maxSize, "actual_size": size}))
+	}
+}
+
+func sniffContentType(content []byte) string {
+	return mimetype.Detect(content).String()
+}
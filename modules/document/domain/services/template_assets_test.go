@@ -0,0 +1,84 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+// What may be stored as a template's asset is decided by its bytes, before the bucket is touched.
+// These pin those rules without one.
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	return encoded.Bytes()
+}
+
+func TestAPngLogoIsAccepted(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	content := pngBytes(t)
+
+	validateAsset(UploadAssetCommand{TemplateId: "t", Kind: AssetKindLogo, Content: content},
+		sniffContentType(content), vErrs)
+
+	assert.Zero(t, vErrs.Count())
+}
+
+// The type is read from the bytes: a GIF named logo.png is still a GIF, and the renderer cannot
+// embed it.
+func TestALogoIsRefusedByWhatItIsNotWhatItIsCalled(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	content := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+
+	validateAsset(UploadAssetCommand{TemplateId: "t", Kind: AssetKindLogo, FileName: "logo.png", Content: content},
+		sniffContentType(content), vErrs)
+
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+func TestAFontMustBeTrueType(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	content := pngBytes(t)
+
+	validateAsset(UploadAssetCommand{TemplateId: "t", Kind: AssetKindFont, Content: content},
+		sniffContentType(content), vErrs)
+
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+func TestAnOversizedLogoIsRefused(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	content := append(pngBytes(t), make([]byte, maxLogoSize)...)
+
+	validateAsset(UploadAssetCommand{TemplateId: "t", Kind: AssetKindLogo, Content: content},
+		sniffContentType(content), vErrs)
+
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+func TestAnUnknownKindIsRefused(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+
+	validateAsset(UploadAssetCommand{TemplateId: "t", Kind: "watermark", Content: []byte("x")}, "text/plain", vErrs)
+
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+// The file name ends up in a header and on the reader's disk, so it keeps to a safe set.
+func TestADownloadIsNamedAfterItsDocument(t *testing.T) {
+	assert.Equal(t, "Invoice-INV-2026-000001.pdf",
+		fileNameOf(itRendering.Document{Title: "Invoice", Number: "INV-2026-000001"}))
+	assert.Equal(t, "Purchase-Order-PO-7.pdf",
+		fileNameOf(itRendering.Document{Title: "Purchase Order", Number: "PO/7"}))
+	assert.Equal(t, "Draft-invoice.pdf", fileNameOf(itRendering.Document{Title: "Draft invoice"}))
+	assert.Equal(t, "document.pdf", fileNameOf(itRendering.Document{}))
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// templateEngine resolves the template resource's engine.
+//
+// A missing engine is a Go error rather than a validation failure: it means this module was
+// initialized wrongly, which is a defect in the deployment and not something a caller can correct.
+func templateEngine() (drif.DynamicResourceEngine, error) {
+	engine, ok := dynamicresource.Registry().GetEngine(models.DocumentTemplateSchemaName)
+	if !ok {
+		return nil, errors.Errorf("the '%s' engine is not registered", models.DocumentTemplateSchemaName)
+	}
+	return engine, nil
+}
+
+// findTemplateById fetches one template by primary key, returning nil when it does not exist.
+func findTemplateById(ctx corectx.Context, templateId string) (*models.DocumentTemplate, error) {
+	engine, err := templateEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.DocumentTemplateFieldId: templateId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findTemplateById")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return models.NewDocumentTemplateFrom(found.Data), nil
+}
+
+// findTemplateOf fetches the template an organization keeps for one document type, returning nil
+// when it keeps none. The unique index on the pair is what makes the first row the only one.
+func findTemplateOf(
+	ctx corectx.Context, orgId model.Id, documentType string,
+) (*models.DocumentTemplate, error) {
+	engine, err := templateEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(models.DocumentTemplateFieldOrgId, dmodel.Equals, string(orgId)),
+		*dmodel.NewSearchNode().NewCondition(models.DocumentTemplateFieldDocumentType, dmodel.Equals, documentType),
+	)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  1,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findTemplateOf")
+	}
+	if found == nil || !found.HasData || len(found.Data.Items) == 0 {
+		return nil, nil
+	}
+	return models.NewDocumentTemplateFrom(found.Data.Items[0]), nil
+}
+
+// writeTemplateFields updates a template through the repository.
+//
+// The object keys are declared no_update so a client cannot point a template at an object it did
+// not upload; upload_asset writes them here instead, having stored the object first.
+func writeTemplateFields(ctx corectx.Context, templateId string, fields dmodel.DynamicFields) error {
+	engine, err := templateEngine()
+	if err != nil {
+		return err
+	}
+
+	update := dmodel.DynamicFields{models.DocumentTemplateFieldId: templateId}
+	for key, value := range fields {
+		update[key] = value
+	}
+	_, err = engine.ResourceRepository().Update(ctx, update)
+	return errors.Wrap(err, "writeTemplateFields")
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
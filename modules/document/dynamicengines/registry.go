@@ -0,0 +1,96 @@
+// Package dynamicengines declares the resource engine the Document module serves through the
+// dynamic resource engine, and creates it during the module's Init().
+//
+// Its imports point one way only: the domain, the module's own interfaces, and the dynamicresource
+// module — never app/, infra/ or transport/. That keeps the package importable by both document
+// (which creates the engine) and document/transport/restful (which registers its routes) without a
+// cycle.
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/array"
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// engineSpec declares one resource engine the Document module owns.
+type engineSpec struct {
+	// SchemaName is the dynamic-model schema the engine serves. It must be an XSchemaName
+	// constant, never a string derived from the resource path.
+	SchemaName string
+
+	// DefaultFields is the field set a listing search returns. Primary key fields are always
+	// included by the query builder, so listing them here is redundant.
+	DefaultFields []string
+
+	// DefineActions adds resource-specific actions and validation on top of the built-in CRUD
+	// ones. It is optional: a resource without custom behavior leaves it nil.
+	DefineActions func(drif.DynamicResourceEngine) error
+}
+
+// engineSpecs lists the resources this module serves through the dynamic resource engine, each
+// with the field set its listing UI needs.
+var engineSpecs = []engineSpec{
+	documentTemplateEngineSpec(),
+}
+
+// The Document Template engine.
+//
+// Its text fields are plain CRUD. The logo and the font are not: their keys are no_update, and
+// upload_asset is the one way to set them.
+func documentTemplateEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.DocumentTemplateSchemaName,
+		DefaultFields: []string{
+			models.DocumentTemplateFieldDocumentType,
+			models.DocumentTemplateFieldCompanyName,
+			models.DocumentTemplateFieldPaperSize,
+			models.DocumentTemplateFieldOrgId,
+		},
+		DefineActions: defineTemplateActions,
+	}
+}
+
+// EngineSchemaNames lists the schemas this module creates an engine for, so that route
+// registration and engine creation cannot drift apart.
+func EngineSchemaNames() []string {
+	return array.Map(engineSpecs, func(spec engineSpec) string {
+		return spec.SchemaName
+	})
+}
+
+// InitDynamicEngines creates the resource engines this module owns and publishes them into the
+// dependency container, so that other modules can inject them by name.
+func InitDynamicEngines() error {
+	for _, spec := range engineSpecs {
+		if err := initEngine(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func initEngine(spec engineSpec) error {
+	engine, err := dynamicresource.Registry().NewEngine(spec.SchemaName, drif.NewEngineOptions{
+		DefaultSearchFields: spec.DefaultFields,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the '%s' resource engine", spec.SchemaName)
+	}
+
+	if spec.DefineActions != nil {
+		if err := spec.DefineActions(engine); err != nil {
+			return errors.Wrapf(err, "failed to define actions of the '%s' resource engine", spec.SchemaName)
+		}
+	}
+
+	err = deps.RegisterNamed(
+		dynamicresource.EngineDependencyName(spec.SchemaName),
+		func() drif.DynamicResourceEngine { return engine },
+	)
+	return errors.Wrapf(err, "failed to register the '%s' resource engine", spec.SchemaName)
+}
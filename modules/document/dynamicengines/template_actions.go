@@ -0,0 +1,118 @@
+package dynamicengines
+
+import (
+	"encoding/base64"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/document/constants"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/services"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// Parameter names of upload_asset. The template arrives as the ":id" path segment.
+const (
+	paramTemplateId = "id"
+	paramKind       = "kind"
+	paramFileName   = "file_name"
+	paramContent    = "content"
+)
+
+// defineTemplateActions adds upload_asset.
+//
+// It carries "update" rather than a permission of its own: replacing a template's logo is editing
+// the template, and someone trusted to rewrite its footer is trusted to change its picture.
+func defineTemplateActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionUploadAsset,
+		ActionType:  drif.ActionTypeGeneric,
+		RestPath:    ":id/" + constants.ActionUploadAsset,
+		Permission:  drif.PermissionUpdate,
+		MainProcess: processUploadAsset,
+	})
+}
+
+// processUploadAsset stores a logo or a font for one template.
+func processUploadAsset(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireAssetService()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, vErrs := buildUploadAssetCommand(input.Params)
+	if vErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *vErrs}, nil
+	}
+
+	result, cErrs, err := service.UploadAsset(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+
+	return &drif.ActionResult{
+		HasData: true,
+		Data: map[string]any{
+			"template_id":  result.TemplateId,
+			"kind":         result.Kind,
+			"object_key":   result.ObjectKey,
+			"content_type": result.ContentType,
+			"size":         result.Size,
+		},
+	}, nil
+}
+
+// buildUploadAssetCommand reads the asset, whose bytes arrive base64-encoded: the engine's actions
+// take JSON, and an asset is small enough that the third more it weighs in transit does not matter.
+func buildUploadAssetCommand(params dmodel.DynamicFields) (services.UploadAssetCommand, *ft.ClientErrors) {
+	vErrs := ft.NewClientErrors()
+	cmd := services.UploadAssetCommand{
+		TemplateId: readString(params, paramTemplateId),
+		Kind:       readString(params, paramKind),
+		FileName:   readString(params, paramFileName),
+	}
+
+	content, err := base64.StdEncoding.DecodeString(readString(params, paramContent))
+	if err != nil {
+		vErrs.Append(*ft.NewBusinessViolation(paramContent, "document.asset_malformed",
+			"the file content must be base64-encoded"))
+		return cmd, vErrs
+	}
+	cmd.Content = content
+	return cmd, vErrs
+}
+
+func readString(params dmodel.DynamicFields, field string) string {
+	value, ok := params[field]
+	if !ok || value == nil {
+		return ""
+	}
+	if typed, ok := value.(string); ok {
+		return typed
+	}
+	return ""
+}
+
+// assetService is the domain service upload_asset delegates to. It is a package variable because an
+// action callback is handed only its own engine.
+var assetService *services.TemplateAssetDomainService
+
+// SetAssetService installs the service upload_asset delegates to. Init calls it before any request
+// is served.
+func SetAssetService(service *services.TemplateAssetDomainService) {
+	assetService = service
+}
+
+func requireAssetService() (*services.TemplateAssetDomainService, error) {
+	if assetService == nil {
+		return nil, errors.New(
+			"the template asset service was not installed; DocumentModule.Init must call " +
+				"dynamicengines.SetAssetService")
+	}
+	return assetService, nil
+}
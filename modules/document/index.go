@@ -0,0 +1,86 @@
+// Package document prints what other modules record.
+//
+// It owns two things: the template each organization keeps for each kind of printed document, and
+// the renderer that lays a document out with it. It owns no documents. An invoice or a purchase
+// order is described to it through interfaces/rendering by the module that keeps the record, which
+// is also where the download action lives.
+package document
+
+import (
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/semver"
+	"github.com/sky-as-code/nikki-erp/modules"
+	"github.com/sky-as-code/nikki-erp/modules/document/app"
+	modconstants "github.com/sky-as-code/nikki-erp/modules/document/constants"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/document/domain/services"
+	"github.com/sky-as-code/nikki-erp/modules/document/dynamicengines"
+	"github.com/sky-as-code/nikki-erp/modules/document/transport/restful"
+)
+
+// ModuleSingleton is the exported symbol that will be looked up by the plugin loader.
+//
+// It is typed DynamicModule rather than InCodeModule so that dropping RegisterModels fails the
+// build. Under the wider interface the method is found by a type assertion instead, and a module
+// that has lost it still compiles, still loads, and silently registers no schemas at all.
+var ModuleSingleton modules.DynamicModule = &DocumentModule{}
+
+type DocumentModule struct {
+}
+
+// LabelKey implements NikkiModule.
+func (*DocumentModule) LabelKey() string {
+	return "document.moduleLabel"
+}
+
+// Name implements NikkiModule.
+func (*DocumentModule) Name() string {
+	return modconstants.DocumentModuleName
+}
+
+// Deps implements NikkiModule.
+func (*DocumentModule) Deps() []string {
+	return []string{
+		"dynamicresource",
+	}
+}
+
+// IsInternal implements InCodeModule.
+func (*DocumentModule) IsInternal() bool {
+	return false
+}
+
+// Version implements NikkiModule.
+func (*DocumentModule) Version() semver.SemVer {
+	return *semver.MustParseSemVer("v1.0.0")
+}
+
+// Init implements NikkiModule.
+//
+// The order is load-bearing: the engine must exist before the services that read templates through
+// it, the domain services before the application service that wraps them, and the engine's action
+// must have its service installed before transport registers the route that reaches it.
+func (*DocumentModule) Init() error {
+	if err := dynamicengines.InitDynamicEngines(); err != nil {
+		return err
+	}
+	if err := services.InitDomainServices(); err != nil {
+		return err
+	}
+	if err := app.InitApplicationServices(); err != nil {
+		return err
+	}
+	err := deps.Invoke(func(assets *services.TemplateAssetDomainService) {
+		dynamicengines.SetAssetService(assets)
+	})
+	if err != nil {
+		return err
+	}
+	return restful.InitRestfulHandlers()
+}
+
+// RegisterModels implements DynamicModule.
+func (*DocumentModule) RegisterModels() error {
+	return dmodel.RegisterSchemaB(models.DocumentTemplateSchemaBuilder())
+}
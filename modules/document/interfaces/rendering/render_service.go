@@ -0,0 +1,22 @@
+package rendering
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+)
+
+type RenderPdfResult = dyn.OpResult[RenderPdfResultData]
+
+// DocumentRenderDomainService is the full capability, implemented inside Document.
+type DocumentRenderDomainService interface {
+	// RenderPdf lays a document out with the template its organization keeps for that document
+	// type, or with the defaults when it keeps none. A missing template is not an error: an
+	// organization that has not set one up still gets a readable document, only without its logo.
+	RenderPdf(ctx corectx.Context, query RenderPdfQuery) (*RenderPdfResult, error)
+}
+
+// DocumentRenderAppService is the capability other modules consume. It is the type a consuming
+// module's infra/external/index.go binds to its own local port.
+type DocumentRenderAppService interface {
+	RenderPdf(ctx corectx.Context, query RenderPdfQuery) (*RenderPdfResult, error)
+}
@@ -0,0 +1,135 @@
+// Package rendering declares the document-rendering capability that Document offers to other
+// modules.
+//
+// A module that prints something describes it here — a title, a partner, a currency, lines and
+// totals — and gets back a PDF laid out with the organization's template. It never hands over its
+// own records: the renderer must not learn what an invoice is, or every new printable document
+// would be a change to this module.
+//
+// A consuming module never imports this package from its domain or application layer. It declares a
+// local port in its own interfaces/external/ and binds it once in infra/external/index.go — see
+// docs/wiki/01 "Microservice-ready Monolith".
+package rendering
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// Document types a template can be kept for. They are the values of document_template's
+// document_type field.
+const (
+	DocumentTypeInvoice       = "invoice"
+	DocumentTypePurchaseOrder = "purchase_order"
+)
+
+// RenderPdfQuery asks for one document to be rendered with its organization's template.
+type RenderPdfQuery struct {
+	OrgId        model.Id
+	DocumentType string
+	Document     Document
+}
+
+// RenderPdfResultData is the rendered file.
+type RenderPdfResultData struct {
+	// FileName is derived from the title and the number, so a saved download says what it is.
+	FileName string
+	Content  []byte
+}
+
+// Document is everything printed on one page set, already read and decided by the module that owns
+// the record. The renderer formats; it does not look anything up.
+type Document struct {
+	// Title is printed large at the top: "Invoice", "Request for Quotation", "Purchase Order".
+	// It is the caller's because one record may print under different titles at different points
+	// of its life.
+	Title  string
+	Number string
+
+	// Facts are the labelled values beside the title — dates, references, terms — in the order
+	// they should be printed.
+	Facts []Fact
+
+	Partner  Partner
+	Currency Currency
+	Lines    []Line
+
+	// Totals are printed under the lines in order. The last one marked Emphasis is the amount the
+	// document asks for.
+	Totals []Total
+
+	Notes string
+}
+
+// Fact is one labelled value in the document header.
+type Fact struct {
+	Label string
+	Value string
+}
+
+// Partner is who the document is addressed to: the customer on an invoice, the vendor on a purchase
+// order.
+type Partner struct {
+	// Heading introduces the block: "Bill to", "Vendor".
+	Heading string
+	Name    string
+	TaxCode string
+	Address string
+}
+
+// Currency is what the renderer needs to print an amount.
+type Currency struct {
+	Code   string
+	Symbol string
+
+	// DecimalPlaces is how many fractional digits amounts are printed to. Amounts arrive already
+	// rounded by the owning module; this only decides how many zeros a round number shows.
+	DecimalPlaces int32
+}
+
+// LineKind separates the lines that carry money from those that organize the document.
+type LineKind string
+
+const (
+	LineKindItem       = LineKind("item")
+	LineKindSection    = LineKind("section")
+	LineKindSubsection = LineKind("subsection")
+	LineKindNote       = LineKind("note")
+)
+
+// Line is one row of the document. A section, subsection or note prints its description alone and
+// ignores every amount.
+type Line struct {
+	Kind        LineKind
+	Description string
+
+	Quantity decimal.Decimal
+	// Unit is the unit of measure's symbol, or empty for a count.
+	Unit      string
+	UnitPrice decimal.Decimal
+
+	// DiscountPercent, TaxRatePercent and TaxAmount are optional because the two documents carry
+	// different ones: an invoice line has a tax rate, a purchase line a discount and a computed tax
+	// amount. A column every line leaves empty is not printed.
+	DiscountPercent *decimal.Decimal
+	TaxRatePercent  *decimal.Decimal
+	TaxAmount       *decimal.Decimal
+
+	Amount decimal.Decimal
+}
+
+// Total is one labelled amount under the lines.
+type Total struct {
+	Label    string
+	Amount   decimal.Decimal
+	Emphasis bool
+}
+
+// FormatDate is how a date fact is written on every printed document, so that an invoice and a
+// purchase order of the same organization do not disagree about it.
+func FormatDate(value time.Time) string {
+	return value.Format("2006-01-02")
+}
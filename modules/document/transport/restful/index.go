@@ -0,0 +1,37 @@
+package restful
+
+import (
+	"github.com/labstack/echo/v5"
+
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	m "github.com/sky-as-code/nikki-erp/modules/core/httpserver/middlewares"
+	"github.com/sky-as-code/nikki-erp/modules/document/dynamicengines"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+)
+
+func InitRestfulHandlers() error {
+	return initDocumentV1()
+}
+
+// initDocumentV1 mounts the engine routes under /v1/document.
+//
+// Rendering has no route here. A document is downloaded from the resource it prints — the invoice,
+// the purchase order — so that reading it and printing it are checked against the same permission.
+func initDocumentV1() error {
+	return deps.Invoke(func(route *echo.Group) error {
+		registerEngineRoutes(route.Group("/v1/document"))
+		return nil
+	})
+}
+
+// registerEngineRoutes exposes every Document resource engine over HTTP.
+// A missing engine is skipped, so that a build which drops one still starts.
+func registerEngineRoutes(routeV1 *echo.Group) {
+	for _, schemaName := range dynamicengines.EngineSchemaNames() {
+		engine, exists := dynamicresource.Registry().GetEngine(schemaName)
+		if !exists {
+			continue
+		}
+		engine.RestApi().RegisterRoutes(routeV1, m.SmokeAuthz())
+	}
+}
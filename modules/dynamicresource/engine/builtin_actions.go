@@ -3,7 +3,13 @@ package engine
 import (
 	stdErr "errors"
 
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
 	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 )
 
@@ -15,7 +21,13 @@ import (
 // constraints and enforce the etag on update. Declaring a schema here would validate twice.
 // A module that needs a pipeline-level schema on a built-in can still add one with
 // ModifyAction; the second validation is idempotent on already-sanitized params.
-func DefineBuiltinActions(engine it.DynamicResourceEngine) error {
+//
+// Only the first BuiltinActionOptions is used, the rest are ignored.
+func DefineBuiltinActions(engine it.DynamicResourceEngine, options ...BuiltinActionOptions) error {
+	actionOpts := BuiltinActionOptions{}
+	if len(options) > 0 {
+		actionOpts = options[0]
+	}
 	return stdErr.Join(
 		engine.DefineAction(it.DynamicActionDefinition{
 			ActionName:  it.ActionCreate,
@@ -75,6 +87,40 @@ func DefineBuiltinActions(engine it.DynamicResourceEngine) error {
 			Permission:  it.PermissionRead,
 			MainProcess: processExists,
 		}),
+		// aggregate carries its group keys and measures in the body, as exists does.
+		engine.DefineAction(it.DynamicActionDefinition{
+			ActionName:  it.ActionAggregate,
+			ActionType:  it.ActionTypeGeneric,
+			RestPath:    "aggregate",
+			Permission:  it.PermissionRead,
+			MainProcess: processAggregate,
+		}),
+		// import writes each row through the create or update action, so a row is checked as a
+		// record written by hand is. Its own permission is create; an upsert key asks for update
+		// as well, before a dry run reports what it would update.
+		engine.DefineAction(it.DynamicActionDefinition{
+			ActionName:  it.ActionImport,
+			ActionType:  it.ActionTypeGeneric,
+			RestPath:    "import",
+			Permission:  it.PermissionCreate,
+			MainProcess: newProcessImport(engine),
+		}),
+		// export reads what search reads, so a GET with the search query string downloads it.
+		engine.DefineAction(it.DynamicActionDefinition{
+			ActionName:  it.ActionExport,
+			ActionType:  it.ActionTypeRead,
+			RestPath:    "export",
+			Permission:  it.PermissionRead,
+			MainProcess: newProcessExport(actionOpts),
+		}),
+		// history is defined on every resource; one not tracking history answers with an empty page.
+		engine.DefineAction(it.DynamicActionDefinition{
+			ActionName:  it.ActionHistory,
+			ActionType:  it.ActionTypeRead,
+			RestPath:    ":id/history",
+			Permission:  it.PermissionRead,
+			MainProcess: processHistory,
+		}),
 		engine.DefineAction(it.DynamicActionDefinition{
 			ActionName:  it.ActionGetSchema,
 			ActionType:  it.ActionTypeRead,
@@ -85,6 +131,18 @@ func DefineBuiltinActions(engine it.DynamicResourceEngine) error {
 	)
 }
 
+// BuiltinActionOptions carries the services a built-in action needs beyond the engine's own
+// subengines. Every field is optional; an action whose service is absent reports so when it is
+// asked for the feature, rather than failing to be defined.
+type BuiltinActionOptions struct {
+	// ExportStorage keeps the files of background exports. Without it, only a streamed export
+	// is available.
+	ExportStorage filestorage.FileStorageAdapter
+
+	// Logger reports what fails after a background action has already answered.
+	Logger logging.LoggerService
+}
+
 func processCreate(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
 	result, err := input.ResourceService.Create(ctx, input.Params)
 	return toActionResult(result, err)
@@ -135,6 +193,65 @@ func processExists(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult
 	return toActionResult(result, err)
 }
 
+// processAggregate attaches the data even when no group matched, for the reason processSearch
+// does: an empty report is a result.
+func processAggregate(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+	result, err := input.ResourceService.Aggregate(ctx, input.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &it.ActionResult{
+		ClientErrors: result.ClientErrors,
+		HasData:      result.HasData,
+		Data:         result.Data,
+	}, nil
+}
+
+func processHistory(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+	result, err := input.ResourceService.History(ctx, input.Params)
+	return toActionResult(result, err)
+}
+
+func newProcessImport(engine it.DynamicResourceEngine) it.DynamicActionProcessFn {
+	writeRow := newImportRowWriter(engine)
+	return func(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+		if len(readImportNames(input.Params[it.ImportParamUpsertBy])) > 0 {
+			update, defined := engine.Action(it.ActionUpdate)
+			if !defined {
+				return nil, errors.New("an import with an upsert key needs the update action")
+			}
+			if cErrs := assertActionPermission(ctx, engine, update); cErrs != nil {
+				return &it.ActionResult{ClientErrors: *cErrs}, nil
+			}
+		}
+		result, err := input.ResourceService.Import(ctx, input.Params, writeRow)
+		return toActionResult(result, err)
+	}
+}
+
+// newImportRowWriter stores an imported row by executing the engine's create or update action,
+// whatever a module has made of them with ModifyAction.
+func newImportRowWriter(engine it.DynamicResourceEngine) it.ImportRowWriter {
+	return func(ctx corectx.Context, data dmodel.DynamicFields, isUpdate bool) (ft.ClientErrors, error) {
+		actionName := it.ActionCreate
+		if isUpdate {
+			actionName = it.ActionUpdate
+		}
+		result, err := engine.ExecuteAction(ctx, actionName, data)
+		if err != nil {
+			return nil, err
+		}
+		if isUpdate && (result == nil || (result.ClientErrors.Count() == 0 && !result.HasData)) {
+			// Deleted since the first pass found it.
+			return ft.ClientErrors{*ft.NewAnonymousNotFoundError()}, nil
+		}
+		if result == nil {
+			return nil, nil
+		}
+		return result.ClientErrors, nil
+	}
+}
+
 // processGetSchema serves the resource schema in the simplified shape the clients cache.
 func processGetSchema(_ corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
 	return &it.ActionResult{
@@ -17,6 +17,9 @@ type NewEngineParam struct {
 	Schema     *dmodel.ModelSchema
 	Repository it.DynamicResourceRepository
 	Service    it.DynamicResourceService
+
+	// Events is told of every action that succeeds. It is optional.
+	Events it.ActionEventListener
 }
 
 func NewDynamicResourceEngine(param NewEngineParam) it.DynamicResourceEngine {
@@ -26,6 +29,7 @@ func NewDynamicResourceEngine(param NewEngineParam) it.DynamicResourceEngine {
 		defaultScope: requestguard.ResourceScopeOrg,
 		repository:   param.Repository,
 		service:      param.Service,
+		events:       param.Events,
 		actions:      map[string]it.DynamicActionDefinition{},
 	}
 	engine.restApi = NewDynamicRestApi(engine)
@@ -42,6 +46,7 @@ type DynamicResourceEngineImpl struct {
 	restApi    it.DynamicRestApi
 	service    it.DynamicResourceService
 	repository it.DynamicResourceRepository
+	events     it.ActionEventListener
 
 	mutex   sync.RWMutex
 	actions map[string]it.DynamicActionDefinition
@@ -116,12 +116,16 @@ func TestDefineBuiltinActions(t *testing.T) {
 
 	assert.NoError(t, DefineBuiltinActions(engine))
 	assert.Equal(t, []string{
+		it.ActionAggregate,
 		it.ActionCreate,
 		it.ActionDelete,
 		it.ActionExists,
+		it.ActionExport,
 		it.ActionGetById,
 		it.ActionGetByUnique,
 		it.ActionGetSchema,
+		it.ActionHistory,
+		it.ActionImport,
 		it.ActionSearch,
 		it.ActionSetArchived,
 		it.ActionUpdate,
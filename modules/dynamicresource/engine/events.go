@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// ActionEventHub tells every listener added to it of the actions of the engines it is given to.
+//
+// A listener may be added after the engines are built, as a module listening to the resources of
+// another may well initialize after it.
+type ActionEventHub struct {
+	mutex     sync.RWMutex
+	logger    logging.LoggerService
+	listeners []it.ActionEventListener
+}
+
+var _ it.ActionEventListener = (*ActionEventHub)(nil)
+
+func NewActionEventHub() *ActionEventHub {
+	return &ActionEventHub{}
+}
+
+// SetLogger gives the hub the logger it reports a failing listener to. The hub outlives the
+// dependency container, which is why the logger is not a constructor argument.
+func (this *ActionEventHub) SetLogger(logger logging.LoggerService) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.logger = logger
+}
+
+func (this *ActionEventHub) Add(listener it.ActionEventListener) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.listeners = append(this.listeners, listener)
+}
+
+func (this *ActionEventHub) ListensTo(resourceName string, actionName string) bool {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	for _, listener := range this.listeners {
+		if listener.ListensTo(resourceName, actionName) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnActionSucceeded gives the event an id, then hands it to each listener of the action. A
+// listener that panics is logged and skipped, as the action it is told of has already happened.
+func (this *ActionEventHub) OnActionSucceeded(ctx corectx.Context, event it.ActionEvent) {
+	if event.Id == "" {
+		id, err := model.NewId()
+		if err != nil {
+			this.logError("[ActionEventHub] %s.%s: no event id: %s", event.ResourceName, event.ActionName, err.Error())
+			return
+		}
+		event.Id = string(*id)
+	}
+
+	this.mutex.RLock()
+	listeners := append([]it.ActionEventListener{}, this.listeners...)
+	this.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		if listener.ListensTo(event.ResourceName, event.ActionName) {
+			this.notify(ctx, listener, event)
+		}
+	}
+}
+
+func (this *ActionEventHub) notify(ctx corectx.Context, listener it.ActionEventListener, event it.ActionEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			this.logError("[ActionEventHub] %s.%s: a listener panicked: %v", event.ResourceName, event.ActionName, r)
+		}
+	}()
+	listener.OnActionSucceeded(ctx, event)
+}
+
+func (this *ActionEventHub) logError(format string, args ...any) {
+	this.mutex.RLock()
+	logger := this.logger
+	this.mutex.RUnlock()
+	if logger != nil {
+		logger.Errorf(format, args...)
+	}
+}
+
+// listensTo reports whether anybody listens to the action, which is what decides whether the
+// pipeline prepares an event for it.
+func (this *DynamicResourceEngineImpl) listensTo(actionName string) bool {
+	return this.events != nil && this.events.ListensTo(this.ResourceName(), actionName)
+}
+
+// eventOrgIdBefore resolves the organization of the record an action is about to run on: from the
+// record the pipeline fetched, from the params, and last by reading the record named by "id". It is
+// resolved before the action, as the record a delete is run on is gone after it.
+func (this *DynamicResourceEngineImpl) eventOrgIdBefore(
+	ctx corectx.Context, params dmodel.DynamicFields, foundModel *dmodel.DynamicFields,
+) string {
+	if _, hasOrg := this.schema.Field(basemodel.FieldOrgId); !hasOrg {
+		return ""
+	}
+	if foundModel != nil {
+		if orgId := readString(*foundModel, basemodel.FieldOrgId); orgId != "" {
+			return orgId
+		}
+	}
+	if orgId := readString(params, basemodel.FieldOrgId); orgId != "" {
+		return orgId
+	}
+
+	recordId := readString(params, basemodel.FieldId)
+	if recordId == "" || this.repository == nil {
+		return ""
+	}
+	found, err := this.repository.FindByKeys(ctx, dmodel.DynamicFields{basemodel.FieldId: recordId})
+	if err != nil || found == nil || !found.HasData {
+		return ""
+	}
+	return readString(found.Data, basemodel.FieldOrgId)
+}
+
+// emitActionEvent tells the listeners of an action that it succeeded. A record the action created
+// is the last place its organization and id are looked for.
+func (this *DynamicResourceEngineImpl) emitActionEvent(
+	ctx corectx.Context, actionName string, params dmodel.DynamicFields, orgId string, result *it.ActionResult,
+) {
+	event := it.ActionEvent{
+		ResourceName: this.ResourceName(),
+		ActionName:   actionName,
+		OrgId:        orgId,
+		ActorId:      string(ctx.GetPermissions().UserId),
+		RecordId:     readString(params, basemodel.FieldId),
+		OccurredAt:   time.Now().UTC(),
+		Data:         result.Data,
+	}
+	if record, isRecord := result.Data.(dmodel.DynamicFields); isRecord {
+		if event.RecordId == "" {
+			event.RecordId = readString(record, basemodel.FieldId)
+		}
+		if event.OrgId == "" {
+			event.OrgId = readString(record, basemodel.FieldOrgId)
+		}
+	}
+	this.events.OnActionSucceeded(ctx, event)
+}
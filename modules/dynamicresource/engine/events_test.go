@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// recordingListener listens to the actions it is given and keeps the events it is told of.
+type recordingListener struct {
+	actions map[string]bool
+	events  []it.ActionEvent
+	panics  bool
+}
+
+func (this *recordingListener) ListensTo(_ string, actionName string) bool {
+	return this.actions[actionName]
+}
+
+func (this *recordingListener) OnActionSucceeded(_ corectx.Context, event it.ActionEvent) {
+	if this.panics {
+		panic("listener failure")
+	}
+	this.events = append(this.events, event)
+}
+
+// newEventEngine builds an engine over a schema with an org_id column, whose actions are listened
+// to by the returned listener.
+func newEventEngine(repo it.DynamicResourceRepository, actions ...string) (*DynamicResourceEngineImpl, *recordingListener) {
+	listener := &recordingListener{actions: map[string]bool{}}
+	for _, action := range actions {
+		listener.actions[action] = true
+	}
+	hub := NewActionEventHub()
+	hub.Add(listener)
+
+	schema := dmodel.DefineModel("test_event_resource").
+		Field(dmodel.DefineField().Name("id").DataType(dmodel.FieldDataTypeUlid())).
+		Field(dmodel.DefineField().Name("org_id").DataType(dmodel.FieldDataTypeUlid())).
+		Build()
+	engine := NewDynamicResourceEngine(NewEngineParam{
+		Schema:     schema,
+		Repository: repo,
+		Events:     hub,
+	}).(*DynamicResourceEngineImpl)
+	return engine, listener
+}
+
+func TestExecuteActionTellsListenersOfSuccess(t *testing.T) {
+	repo := &stubRepository{record: dmodel.DynamicFields{"org_id": "01JORG"}, found: true}
+	engine, listener := newEventEngine(repo, "approve")
+	require.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName:  "approve",
+		MainProcess: noopProcess,
+	}))
+
+	_, err := engine.ExecuteAction(ownerContext(), "approve", dmodel.DynamicFields{"id": "01JREC"})
+	require.NoError(t, err)
+
+	require.Len(t, listener.events, 1)
+	event := listener.events[0]
+	assert.NotEmpty(t, event.Id)
+	assert.Equal(t, "test_event_resource", event.ResourceName)
+	assert.Equal(t, "approve", event.ActionName)
+	assert.Equal(t, "01JREC", event.RecordId)
+	assert.Equal(t, "01JORG", event.OrgId, "the organization is read from the record before the action")
+	assert.False(t, event.OccurredAt.IsZero())
+}
+
+func TestExecuteActionTakesEventOrgFromCreatedRecord(t *testing.T) {
+	engine, listener := newEventEngine(&stubRepository{}, "create_thing")
+	require.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName: "create_thing",
+		MainProcess: func(_ corectx.Context, _ it.ProcessInput) (*it.ActionResult, error) {
+			return &it.ActionResult{
+				Data:    dmodel.DynamicFields{"id": "01JNEW", "org_id": "01JORG"},
+				HasData: true,
+			}, nil
+		},
+	}))
+
+	_, err := engine.ExecuteAction(ownerContext(), "create_thing", dmodel.DynamicFields{})
+	require.NoError(t, err)
+
+	require.Len(t, listener.events, 1)
+	assert.Equal(t, "01JNEW", listener.events[0].RecordId)
+	assert.Equal(t, "01JORG", listener.events[0].OrgId)
+}
+
+func TestExecuteActionTellsNobodyOfFailure(t *testing.T) {
+	engine, listener := newEventEngine(&stubRepository{}, "reject", "fail")
+	require.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName: "reject",
+		MainProcess: func(_ corectx.Context, _ it.ProcessInput) (*it.ActionResult, error) {
+			return &it.ActionResult{ClientErrors: ft.ClientErrors{*ft.NewAnonymousNotFoundError()}}, nil
+		},
+	}))
+	require.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName:  "fail",
+		Permission:  it.PermissionUpdate,
+		MainProcess: noopProcess,
+	}))
+
+	_, err := engine.ExecuteAction(ownerContext(), "reject", dmodel.DynamicFields{"org_id": "01JORG"})
+	require.NoError(t, err)
+	_, err = engine.ExecuteAction(deniedContext(), "fail", dmodel.DynamicFields{"org_id": "01JORG"})
+	require.NoError(t, err)
+
+	assert.Empty(t, listener.events, "an action answered with client errors did not happen")
+}
+
+func TestExecuteActionSkipsUnlistenedActions(t *testing.T) {
+	repo := &stubRepository{}
+	engine, listener := newEventEngine(repo)
+	require.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName:  "quiet",
+		MainProcess: noopProcess,
+	}))
+
+	_, err := engine.ExecuteAction(ownerContext(), "quiet", dmodel.DynamicFields{"id": "01JREC"})
+	require.NoError(t, err)
+
+	assert.Empty(t, listener.events)
+	assert.Nil(t, repo.fetchedKeys, "nothing is read for an event nobody listens to")
+}
+
+func TestActionEventHubSurvivesPanickingListener(t *testing.T) {
+	hub := NewActionEventHub()
+	failing := &recordingListener{actions: map[string]bool{"approve": true}, panics: true}
+	working := &recordingListener{actions: map[string]bool{"approve": true}}
+	deaf := &recordingListener{actions: map[string]bool{}}
+	hub.Add(failing)
+	hub.Add(working)
+	hub.Add(deaf)
+
+	assert.True(t, hub.ListensTo("order", "approve"))
+	assert.False(t, hub.ListensTo("order", "delete"))
+
+	hub.OnActionSucceeded(ownerContext(), it.ActionEvent{ResourceName: "order", ActionName: "approve"})
+
+	require.Len(t, working.events, 1)
+	assert.NotEmpty(t, working.events[0].Id, "the hub gives the event its id")
+	assert.Empty(t, deaf.events)
+}
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/common/util"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+const (
+	// exportPageSize is how many records one read of an export fetches. Only the page being
+	// written is held in memory, whatever the number of records.
+	exportPageSize = model.MODEL_RULE_PAGE_MAX_SIZE
+
+	// exportAsyncTimeout bounds a background export, well past what an interactive download
+	// would be given.
+	exportAsyncTimeout = 30 * time.Minute
+
+	// exportUrlLifetime is how long the link of a background export stays valid.
+	exportUrlLifetime = 24 * time.Hour
+
+	exportObjectPrefix = "exports"
+)
+
+// exportSearchFn reads one page of an export. It is the resource service's Search, so that a
+// module's override and the computed fields apply to an export as they do to a search.
+type exportSearchFn func(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error)
+
+// newProcessExport builds the export action, which answers with a stream of the file, or with a
+// link to it when the export runs in the background.
+func newProcessExport(options BuiltinActionOptions) it.DynamicActionProcessFn {
+	return func(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+		run, cErrs, err := startExport(ctx, input.ResourceService.Schema(), input.ResourceService.Search, input.Params)
+		if err != nil {
+			return nil, errors.Wrap(err, "export")
+		}
+		if cErrs.Count() > 0 {
+			return &it.ActionResult{ClientErrors: cErrs}, nil
+		}
+		if !run.async {
+			return &it.ActionResult{Data: run.stream(ctx), HasData: true}, nil
+		}
+		return run.startInBackground(ctx, options)
+	}
+}
+
+// exportRun is one export in progress. The first page is read before the action answers, so that
+// a bad query is reported as one instead of as a broken download.
+type exportRun struct {
+	schema  *dmodel.ModelSchema
+	search  exportSearchFn
+	params  dmodel.DynamicFields
+	format  string
+	async   bool
+	columns []exportColumn
+	page    dyn.PagedResultData[dmodel.DynamicFields]
+}
+
+func startExport(
+	ctx corectx.Context, schema *dmodel.ModelSchema, search exportSearchFn, params dmodel.DynamicFields,
+) (*exportRun, ft.ClientErrors, error) {
+	run := &exportRun{schema: schema, search: search}
+	cErrs := ft.ClientErrors{}
+
+	run.format = strings.ToLower(readString(params, it.ExportParamFormat))
+	switch run.format {
+	case "":
+		run.format = it.ExportFormatCsv
+	case it.ExportFormatCsv, it.ExportFormatXlsx:
+	default:
+		cErrs.Append(*ft.NewValidationError(it.ExportParamFormat, ft.ErrorKey("err_unsupported_export_format"),
+			fmt.Sprintf("format must be '%s' or '%s'", it.ExportFormatCsv, it.ExportFormatXlsx)))
+	}
+	async, err := readExportBool(params, it.ExportParamAsync)
+	if err != nil {
+		cErrs.Append(*dmodel.NewInvalidDataTypeErr(it.ExportParamAsync, "boolean"))
+	}
+	run.async = async
+	if cErrs.Count() > 0 {
+		return nil, cErrs, nil
+	}
+
+	// Every matching record is exported, so the paging the caller may have copied over from a
+	// search is dropped, and the export pages on its own.
+	run.params = maps.Clone(params)
+	for _, name := range []string{it.ExportParamFormat, it.ExportParamAsync, queryParamPage, queryParamCursor} {
+		delete(run.params, name)
+	}
+	run.params[queryParamSize] = exportPageSize
+
+	first, err := search(ctx, run.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if first.ClientErrors.Count() > 0 {
+		return nil, first.ClientErrors, nil
+	}
+	run.page = first.Data
+
+	fields := first.Data.DesiredFields
+	if len(fields) == 0 {
+		fields = columnNames(schema)
+	}
+	run.columns = exportColumns(schema, fields, model.LanguageCode(readString(params, queryParamLanguage)))
+	return run, nil, nil
+}
+
+// readExportBool accepts a flag as a bool or as the text of one, as it arrives from a query string.
+func readExportBool(params dmodel.DynamicFields, name string) (bool, error) {
+	if flag, ok := readBool(params, name); ok {
+		return flag, nil
+	}
+	raw := readString(params, name)
+	if raw == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+func (this *exportRun) fileName() string {
+	return this.schema.Name() + "_" + time.Now().UTC().Format("20060102_150405") + "." + this.format
+}
+
+func (this *exportRun) contentType() string {
+	if this.format == it.ExportFormatXlsx {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func (this *exportRun) stream(ctx corectx.Context) it.StreamResultData {
+	return it.StreamResultData{
+		FileName:    this.fileName(),
+		ContentType: this.contentType(),
+		Write: func(writer io.Writer) error {
+			return this.writeTo(ctx, writer)
+		},
+	}
+}
+
+// writeTo writes the file page by page: a page is written, then dropped for the next one.
+func (this *exportRun) writeTo(ctx corectx.Context, writer io.Writer) error {
+	fileWriter, err := newExportFileWriter(this.format, writer, this.columns)
+	if err != nil {
+		return err
+	}
+	for {
+		for _, row := range this.page.Items {
+			if err := fileWriter.writeRow(exportRowCells(this.columns, row)); err != nil {
+				return err
+			}
+		}
+		hasMore, err := this.nextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if !hasMore {
+			break
+		}
+	}
+	return fileWriter.close()
+}
+
+// nextPage reads the page after the current one. A keyset search hands out the cursor of the next
+// page, and leaves it out on a short, last one. A ranked text search pages by number instead, and
+// has a next page for as long as its pages come full.
+func (this *exportRun) nextPage(ctx corectx.Context) (bool, error) {
+	current := this.page
+	switch {
+	case current.NextCursor != "":
+		this.params[queryParamCursor] = current.NextCursor
+	case len(current.Items) >= exportPageSize:
+		this.params[queryParamPage] = current.Page + 1
+	default:
+		return false, nil
+	}
+
+	next, err := this.search(ctx, this.params)
+	if err != nil {
+		return false, err
+	}
+	if next.ClientErrors.Count() > 0 {
+		return false, errors.Errorf("export page failed: %v", next.ClientErrors.ToError())
+	}
+	this.page = next.Data
+	return len(next.Data.Items) > 0, nil
+}
+
+// startInBackground answers with the link the file will be served at, then writes the file to
+// storage once the request is over. A failure by then has no one to answer to, so it is logged.
+func (this *exportRun) startInBackground(
+	ctx corectx.Context, options BuiltinActionOptions,
+) (*it.ActionResult, error) {
+	if options.ExportStorage == nil {
+		return &it.ActionResult{ClientErrors: ft.ClientErrors{*ft.NewValidationError(
+			it.ExportParamAsync, ft.ErrorKey("err_async_export_unavailable"),
+			"background export needs a file storage, which is not configured")}}, nil
+	}
+
+	id, err := model.NewId()
+	if err != nil {
+		return nil, errors.Wrap(err, "export")
+	}
+	fileName := this.fileName()
+	objectKey := path.Join(exportObjectPrefix, this.schema.Name(), string(*id), fileName)
+	url, err := options.ExportStorage.GeneratePresignedUrl(ctx, objectKey, exportUrlLifetime)
+	if err != nil {
+		return nil, errors.Wrap(err, "export")
+	}
+
+	logger := options.Logger
+	if logger == nil {
+		logger = ctx.GetLogger()
+	}
+	detached, cancel := corectx.DetachRequestContext(ctx, exportAsyncTimeout)
+	go func() {
+		defer cancel()
+		defer func() {
+			if e := ft.RecoverPanicFailedTo(recover(), "export "+this.schema.Name()); e != nil {
+				logExportFailure(logger, objectKey, e)
+			}
+		}()
+		if err := this.upload(detached, options.ExportStorage, objectKey, fileName); err != nil {
+			logExportFailure(logger, objectKey, err)
+		}
+	}()
+
+	return &it.ActionResult{
+		Data: it.ExportResultData{
+			FileName:  fileName,
+			ObjectKey: objectKey,
+			Url:       url,
+			ExpiresAt: time.Now().UTC().Add(exportUrlLifetime),
+		},
+		HasData: true,
+	}, nil
+}
+
+// upload pipes the file into storage as it is written, so a background export holds no more in
+// memory than a streamed one.
+func (this *exportRun) upload(
+	ctx corectx.Context, storage filestorage.FileStorageAdapter, objectKey string, fileName string,
+) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(this.writeTo(ctx, writer))
+	}()
+
+	putOpts := filestorage.NewPutOptions(this.contentType(), 0)
+	putOpts.ContentDisposition = util.ToPtr("attachment; filename=" + strconv.Quote(fileName))
+	err := storage.Put(ctx, objectKey, reader, putOpts)
+	// Unblocks the writer when storage gave up before reading everything.
+	reader.CloseWithError(err)
+	return errors.Wrap(err, "export upload")
+}
+
+func logExportFailure(logger logging.LoggerService, objectKey string, err error) {
+	if logger == nil {
+		return
+	}
+	logger.Error("background export to '"+objectKey+"' failed", err)
+}
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// exportSearchStub hands out its pages in turn, and records the params of every read.
+type exportSearchStub struct {
+	pages []dyn.PagedResultData[dmodel.DynamicFields]
+	cErrs ft.ClientErrors
+	mutex sync.Mutex
+	calls []dmodel.DynamicFields
+}
+
+func (this *exportSearchStub) search(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.calls = append(this.calls, maps.Clone(params))
+	if this.cErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{ClientErrors: this.cErrs}, nil
+	}
+	if len(this.calls) > len(this.pages) {
+		return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{}, nil
+	}
+	page := this.pages[len(this.calls)-1]
+	return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{Data: page, HasData: len(page.Items) > 0}, nil
+}
+
+func exportItems(from int, count int) []dmodel.DynamicFields {
+	items := make([]dmodel.DynamicFields, count)
+	for i := range items {
+		items[i] = dmodel.DynamicFields{"code": fmt.Sprintf("P%04d", from+i), "qty": int64(from + i)}
+	}
+	return items
+}
+
+func readExportCsv(t *testing.T, content []byte) [][]string {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(content, []byte(utf8Bom)), "CSV must start with a BOM for Excel")
+	records, err := csv.NewReader(bytes.NewReader(content[len(utf8Bom):])).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestExport_FollowsCursorsPastTheFirstPage(t *testing.T) {
+	schema := importSchemas(t)
+	stub := &exportSearchStub{pages: []dyn.PagedResultData[dmodel.DynamicFields]{
+		{Items: exportItems(0, exportPageSize), DesiredFields: []string{"code", "qty"}, NextCursor: "next"},
+		{Items: exportItems(exportPageSize, 3), DesiredFields: []string{"code", "qty"}},
+	}}
+
+	run, cErrs, err := startExport(ownerContext(), schema, stub.search, dmodel.DynamicFields{
+		it.ExportParamFormat: "csv", queryParamPage: 4, queryParamCursor: "stale", queryParamSize: 10,
+	})
+	require.NoError(t, err)
+	require.Zero(t, cErrs.Count())
+
+	var buffer bytes.Buffer
+	require.NoError(t, run.writeTo(ownerContext(), &buffer))
+	records := readExportCsv(t, buffer.Bytes())
+
+	assert.Len(t, records, 1+exportPageSize+3)
+	assert.Equal(t, []string{"code", "qty"}, records[0])
+	assert.Equal(t, []string{"P0502", "502"}, records[len(records)-1])
+
+	require.Len(t, stub.calls, 2)
+	assert.Equal(t, exportPageSize, stub.calls[0][queryParamSize])
+	for _, name := range []string{it.ExportParamFormat, queryParamPage, queryParamCursor} {
+		assert.NotContains(t, stub.calls[0], name)
+	}
+	assert.Equal(t, "next", stub.calls[1][queryParamCursor])
+}
+
+func TestExport_PagesByNumberWithoutCursor(t *testing.T) {
+	schema := importSchemas(t)
+	stub := &exportSearchStub{pages: []dyn.PagedResultData[dmodel.DynamicFields]{
+		{Items: exportItems(0, exportPageSize), DesiredFields: []string{"code"}},
+		{Items: exportItems(exportPageSize, 1), DesiredFields: []string{"code"}, Page: 1},
+	}}
+
+	run, _, err := startExport(ownerContext(), schema, stub.search, dmodel.DynamicFields{})
+	require.NoError(t, err)
+	require.NoError(t, run.writeTo(ownerContext(), io.Discard))
+
+	require.Len(t, stub.calls, 2)
+	assert.Equal(t, 1, stub.calls[1][queryParamPage])
+}
+
+func TestExport_HeadersAreLocalizedAndNestedFieldsFlattened(t *testing.T) {
+	schema := importSchemas(t)
+	columns := exportColumns(schema, []string{"name", "qty", "category.code", "missing"}, model.LanguageCodeEnUs)
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.header
+	}
+	assert.Equal(t, []string{"Product name", "qty", "category / code", "missing"}, headers)
+
+	cells := exportRowCells(columns, dmodel.DynamicFields{
+		"name":     model.LangJson{model.LanguageCodeEnUs: "Bolt"},
+		"qty":      int64(7),
+		"category": dmodel.DynamicFields{"code": "HW"},
+	})
+	assert.Equal(t, "Bolt", cells[0].csvText())
+	assert.Equal(t, exportCellNumber, cells[1].kind)
+	assert.Equal(t, "7", cells[1].csvText())
+	assert.Equal(t, "HW", cells[2].csvText())
+	assert.Equal(t, "", cells[3].csvText())
+}
+
+func TestExportCellOf_FormatsByFieldType(t *testing.T) {
+	schema := dmodel.DefineModel("test_export_line").
+		Field(dmodel.DefineField().Name("price").
+			DataType(dmodel.FieldDataTypeDecimal("0", "1000000", 2))).
+		Field(dmodel.DefineField().Name("due").
+			DataType(dmodel.FieldDataTypeDate())).
+		Field(dmodel.DefineField().Name("tags").
+			DataType(dmodel.FieldDataTypeString(0, 20).ArrayType())).
+		Build()
+	columns := exportColumns(schema, []string{"price", "due", "tags"}, "")
+
+	cells := exportRowCells(columns, dmodel.DynamicFields{
+		"price": "12.5",
+		"due":   "2026-03-01",
+		"tags":  []string{"a", "b"},
+	})
+	assert.Equal(t, exportCellNumber, cells[0].kind)
+	assert.Equal(t, "12.50", cells[0].csvText())
+	assert.Equal(t, exportCellDate, cells[1].kind)
+	assert.Equal(t, "2026-03-01", cells[1].csvText())
+	assert.Equal(t, "a, b", cells[2].csvText())
+
+	joined := exportPathValue(dmodel.DynamicFields{"lines": []dmodel.DynamicFields{
+		{"code": "L1"}, {"code": "L2"},
+	}}, []string{"lines", "code"})
+	assert.Equal(t, "L1, L2", exportCellOf(exportColumn{scale: -1}, joined).csvText())
+}
+
+func TestExport_XlsxReadsBack(t *testing.T) {
+	schema := importSchemas(t)
+	stub := &exportSearchStub{pages: []dyn.PagedResultData[dmodel.DynamicFields]{
+		{Items: exportItems(0, 2), DesiredFields: []string{"code", "qty"}},
+	}}
+
+	run, _, err := startExport(ownerContext(), schema, stub.search, dmodel.DynamicFields{
+		it.ExportParamFormat: "XLSX",
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(run.fileName(), ".xlsx"))
+
+	var buffer bytes.Buffer
+	require.NoError(t, run.writeTo(ownerContext(), &buffer))
+	records, err := readXlsxRecords(buffer.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"code", "qty"}, {"P0000", "0"}, {"P0001", "1"}}, records)
+}
+
+func TestExport_ReportsBadQueriesBeforeStreaming(t *testing.T) {
+	schema := importSchemas(t)
+
+	_, cErrs, err := startExport(ownerContext(), schema, (&exportSearchStub{}).search, dmodel.DynamicFields{
+		it.ExportParamFormat: "pdf", it.ExportParamAsync: "maybe",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, cErrs.Count())
+	assert.Equal(t, "common:err_unsupported_export_format", string(cErrs[0].Key))
+
+	stub := &exportSearchStub{cErrs: ft.ClientErrors{*ft.NewAnonymousValidationError(
+		ft.ErrorKey("err_malformed_graph"), "bad graph")}}
+	_, cErrs, err = startExport(ownerContext(), schema, stub.search, dmodel.DynamicFields{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, cErrs.Count())
+}
+
+// exportStorageStub keeps what was put, and signals once the upload is over.
+type exportStorageStub struct {
+	objectKey string
+	content   []byte
+	opts      *filestorage.PutOptions
+	done      chan struct{}
+}
+
+func (this *exportStorageStub) Put(ctx context.Context, objectKey string, r io.Reader, opts *filestorage.PutOptions) error {
+	defer close(this.done)
+	this.objectKey = objectKey
+	this.opts = opts
+	content, err := io.ReadAll(r)
+	this.content = content
+	return err
+}
+
+func (this *exportStorageStub) Open(context.Context, string, string) (*filestorage.StreamObjectResult, error) {
+	return nil, nil
+}
+
+func (this *exportStorageStub) Remove(context.Context, string) error {
+	return nil
+}
+
+func (this *exportStorageStub) GeneratePresignedUrl(_ context.Context, objectKey string, _ time.Duration) (string, error) {
+	return "https://files.example/" + objectKey, nil
+}
+
+func TestExport_AsyncUploadsInTheBackground(t *testing.T) {
+	schema := importSchemas(t)
+	newStub := func() *exportSearchStub {
+		return &exportSearchStub{pages: []dyn.PagedResultData[dmodel.DynamicFields]{
+			{Items: exportItems(0, 2), DesiredFields: []string{"code"}},
+		}}
+	}
+	params := dmodel.DynamicFields{it.ExportParamAsync: "true"}
+
+	run, _, err := startExport(ownerContext(), schema, newStub().search, params)
+	require.NoError(t, err)
+	result, err := run.startInBackground(ownerContext(), BuiltinActionOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, "common:err_async_export_unavailable", string(result.ClientErrors[0].Key))
+
+	storage := &exportStorageStub{done: make(chan struct{})}
+	run, _, err = startExport(ownerContext(), schema, newStub().search, params)
+	require.NoError(t, err)
+	result, err = run.startInBackground(ownerContext(), BuiltinActionOptions{ExportStorage: storage})
+	require.NoError(t, err)
+
+	exported, ok := result.Data.(it.ExportResultData)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(exported.ObjectKey, "exports/"+importProductSchema+"/"))
+	assert.Equal(t, "https://files.example/"+exported.ObjectKey, exported.Url)
+
+	select {
+	case <-storage.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background export did not finish")
+	}
+	assert.Equal(t, exported.ObjectKey, storage.objectKey)
+	assert.Contains(t, *storage.opts.ContentDisposition, exported.FileName)
+	assert.Equal(t, [][]string{{"code"}, {"P0000"}, {"P0001"}}, readExportCsv(t, storage.content))
+}
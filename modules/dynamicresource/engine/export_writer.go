@@ -0,0 +1,490 @@
+package engine
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// exportColumn is one column of an export: a field of the resource, or with a dotted path such as
+// "category.code", a field of a related record, which the search has nested under its edge.
+type exportColumn struct {
+	path   []string
+	header string
+	// field is nil when the path names nothing the schemas know of; its values are then written
+	// as they come.
+	field *dmodel.ModelField
+	// scale is the number of decimal places of a decimal field, and -1 for any other field.
+	scale    int
+	language model.LanguageCode
+}
+
+// exportColumns heads every column with the label of its field in the requested language. A
+// nested column is headed by the labels along its path, as in "Category / Code".
+func exportColumns(schema *dmodel.ModelSchema, fields []string, language model.LanguageCode) []exportColumn {
+	if language == "" {
+		language = model.DefaultLanguageCode
+	}
+	columns := make([]exportColumn, 0, len(fields))
+	for _, name := range fields {
+		column := exportColumn{path: strings.Split(name, "."), scale: -1, language: language}
+		labels := make([]string, 0, len(column.path))
+		current := schema
+		for i, segment := range column.path {
+			if current == nil {
+				labels = append(labels, segment)
+				continue
+			}
+			if i < len(column.path)-1 {
+				relation, found := exportRelation(current, segment)
+				if !found {
+					current = nil
+					labels = append(labels, segment)
+					continue
+				}
+				labels = append(labels, translateLabel(relation.Label(), language, segment))
+				current = dmodel.GetSchema(relation.DestSchemaName)
+				continue
+			}
+			if field, exists := current.Field(segment); exists {
+				column.field = field
+				labels = append(labels, translateLabel(field.Label(), language, segment))
+				continue
+			}
+			labels = append(labels, segment)
+		}
+		if column.field != nil && column.field.DataType().String() == dmodel.FieldDataTypeNameDecimal {
+			column.scale = decimalScale(column.field)
+		}
+		column.header = strings.Join(labels, " / ")
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func exportRelation(schema *dmodel.ModelSchema, edge string) (dmodel.ModelRelation, bool) {
+	for _, relation := range schema.Relations() {
+		if relation.Edge == edge {
+			return relation, true
+		}
+	}
+	return dmodel.ModelRelation{}, false
+}
+
+// translateLabel picks the label in the language asked for, then in the default language, and
+// falls back to the field name for a field nobody labelled.
+func translateLabel(label model.LangJson, language model.LanguageCode, fallback string) string {
+	if text := label[language]; text != "" {
+		return text
+	}
+	if text := label[model.DefaultLanguageCode]; text != "" {
+		return text
+	}
+	return fallback
+}
+
+func decimalScale(field *dmodel.ModelField) int {
+	switch scale := field.DataType().Options()[dmodel.FieldDataTypeOptScale].(type) {
+	case uint:
+		return int(scale)
+	case int:
+		return scale
+	}
+	return -1
+}
+
+type exportCellKind int
+
+const (
+	exportCellText exportCellKind = iota
+	exportCellNumber
+	exportCellDate
+	exportCellDateTime
+)
+
+// exportCell is a value made ready for a file. A number keeps its text, which is exact; a date
+// keeps its time, which an XLSX file writes as a date serial and a CSV file as text.
+type exportCell struct {
+	kind exportCellKind
+	text string
+	time time.Time
+}
+
+func (this exportCell) csvText() string {
+	switch this.kind {
+	case exportCellDate:
+		return this.time.Format(time.DateOnly)
+	case exportCellDateTime:
+		return this.time.UTC().Format(time.DateTime)
+	}
+	return this.text
+}
+
+func exportRowCells(columns []exportColumn, row dmodel.DynamicFields) []exportCell {
+	cells := make([]exportCell, len(columns))
+	for i, column := range columns {
+		cells[i] = exportCellOf(column, exportPathValue(row, column.path))
+	}
+	return cells
+}
+
+// exportPathValue follows a column path into the nested records of a row. A to-many edge nests a
+// list of records, and gives the list of their values.
+func exportPathValue(value any, path []string) any {
+	if len(path) == 0 || value == nil {
+		return value
+	}
+	switch typed := value.(type) {
+	case dmodel.DynamicFields:
+		return exportPathValue(typed[path[0]], path[1:])
+	case map[string]any:
+		return exportPathValue(typed[path[0]], path[1:])
+	case []dmodel.DynamicFields:
+		values := make([]any, 0, len(typed))
+		for _, item := range typed {
+			values = append(values, exportPathValue(item, path))
+		}
+		return values
+	case []any:
+		values := make([]any, 0, len(typed))
+		for _, item := range typed {
+			values = append(values, exportPathValue(item, path))
+		}
+		return values
+	}
+	return nil
+}
+
+// exportCellOf converts a value through its field's data type first, so that a decimal read as
+// text or a date read as a time.Time is written the way its field declares.
+func exportCellOf(column exportColumn, value any) exportCell {
+	if value == nil {
+		return exportCell{}
+	}
+	if column.field != nil {
+		if converted, err := column.field.DataType().TryConvert(value, column.field.DataType().Options()); err == nil {
+			if inner := converted.Get(); inner != nil && *inner != nil {
+				value = *inner
+			}
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		return exportCell{text: typed}
+	case decimal.Decimal:
+		if column.scale >= 0 {
+			return exportCell{kind: exportCellNumber, text: typed.StringFixed(int32(column.scale))}
+		}
+		return exportCell{kind: exportCellNumber, text: typed.String()}
+	case model.ModelDate:
+		return exportCell{kind: exportCellDate, time: typed.GoTime()}
+	case model.ModelDateTime:
+		return exportCell{kind: exportCellDateTime, time: typed.GoTime()}
+	case time.Time:
+		return exportCell{kind: exportCellDateTime, time: typed}
+	case bool:
+		return exportCell{text: strconv.FormatBool(typed)}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return exportCell{kind: exportCellNumber, text: fmt.Sprint(typed)}
+	case float32:
+		return exportCell{kind: exportCellNumber, text: strconv.FormatFloat(float64(typed), 'f', -1, 32)}
+	case float64:
+		return exportCell{kind: exportCellNumber, text: strconv.FormatFloat(typed, 'f', -1, 64)}
+	case model.LangJson:
+		return exportCell{text: translateLabel(typed, column.language, "")}
+	case dmodel.DynamicFields, map[string]any:
+		raw, _ := json.Marshal(typed)
+		return exportCell{text: string(raw)}
+	case fmt.Stringer:
+		return exportCell{text: typed.String()}
+	}
+
+	// A list, from an array field or a to-many edge, goes in one cell, its items comma-separated.
+	if reflected := reflect.ValueOf(value); reflected.Kind() == reflect.Slice {
+		items := make([]string, 0, reflected.Len())
+		for i := 0; i < reflected.Len(); i++ {
+			item := exportCellOf(exportColumn{scale: column.scale, language: column.language}, reflected.Index(i).Interface())
+			if text := item.csvText(); text != "" {
+				items = append(items, text)
+			}
+		}
+		return exportCell{text: strings.Join(items, ", ")}
+	}
+	return exportCell{text: fmt.Sprint(value)}
+}
+
+// exportFileWriter writes the rows of an export as they come, without keeping any of them.
+type exportFileWriter interface {
+	writeRow(cells []exportCell) error
+	close() error
+}
+
+// newExportFileWriter starts the file and writes its header row.
+func newExportFileWriter(format string, writer io.Writer, columns []exportColumn) (exportFileWriter, error) {
+	switch format {
+	case it.ExportFormatCsv:
+		return newCsvExportWriter(writer, columns)
+	case it.ExportFormatXlsx:
+		return newXlsxExportWriter(writer, columns)
+	}
+	return nil, errors.Errorf("newExportFileWriter: unsupported format '%s'", format)
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+// newCsvExportWriter starts the file with a UTF-8 byte order mark, without which spreadsheet
+// software reads the file in a legacy code page and garbles every accented letter.
+func newCsvExportWriter(writer io.Writer, columns []exportColumn) (*csvExportWriter, error) {
+	if _, err := writer.Write(utf8Bom); err != nil {
+		return nil, errors.Wrap(err, "newCsvExportWriter")
+	}
+	this := &csvExportWriter{writer: csv.NewWriter(writer), record: make([]string, len(columns))}
+	for i, column := range columns {
+		this.record[i] = column.header
+	}
+	return this, errors.Wrap(this.writer.Write(this.record), "newCsvExportWriter")
+}
+
+func (this *csvExportWriter) writeRow(cells []exportCell) error {
+	for i, cell := range cells {
+		this.record[i] = cell.csvText()
+	}
+	return errors.Wrap(this.writer.Write(this.record), "csvExportWriter.writeRow")
+}
+
+func (this *csvExportWriter) close() error {
+	this.writer.Flush()
+	return errors.Wrap(this.writer.Error(), "csvExportWriter.close")
+}
+
+// Cell styles of an exported workbook, by their index in styles.xml. The decimal styles follow,
+// one per decimal scale the columns use.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleDate
+	xlsxStyleDateTime
+	xlsxStyleFirstDecimal
+)
+
+// xlsxFirstCustomFormat is the first number format id a workbook may define; lower ones are
+// built into spreadsheet software.
+const xlsxFirstCustomFormat = 164
+
+// xlsxExportWriter writes a workbook of one sheet. The sheet is the last part of the archive, so
+// its rows go straight into the zip stream. Text cells are written inline rather than in a shared
+// string table, which would have to be complete, and so held in memory, before the sheet.
+type xlsxExportWriter struct {
+	archive       *zip.Writer
+	sheet         *bufio.Writer
+	columnStyles  []int
+	columnLetters []string
+	rowNumber     int
+}
+
+func newXlsxExportWriter(writer io.Writer, columns []exportColumn) (*xlsxExportWriter, error) {
+	this := &xlsxExportWriter{
+		archive:       zip.NewWriter(writer),
+		columnStyles:  make([]int, len(columns)),
+		columnLetters: make([]string, len(columns)),
+	}
+	scaleStyles := map[int]int{}
+	scales := []int{}
+	for i, column := range columns {
+		this.columnLetters[i] = xlsxColumnLetters(i)
+		if column.scale < 0 {
+			continue
+		}
+		if _, exists := scaleStyles[column.scale]; !exists {
+			scaleStyles[column.scale] = xlsxStyleFirstDecimal + len(scales)
+			scales = append(scales, column.scale)
+		}
+		this.columnStyles[i] = scaleStyles[column.scale]
+	}
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles(scales)},
+	}
+	for _, part := range parts {
+		partWriter, err := this.archive.Create(part.name)
+		if err != nil {
+			return nil, errors.Wrap(err, "newXlsxExportWriter")
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return nil, errors.Wrap(err, "newXlsxExportWriter")
+		}
+	}
+
+	sheetWriter, err := this.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, errors.Wrap(err, "newXlsxExportWriter")
+	}
+	this.sheet = bufio.NewWriter(sheetWriter)
+	this.sheet.WriteString(xml.Header + `<worksheet xmlns="` + xlsxMainNamespace + `">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+
+	header := make([]exportCell, len(columns))
+	for i, column := range columns {
+		header[i] = exportCell{text: column.header}
+	}
+	return this, this.writeCells(header, xlsxStyleHeader)
+}
+
+func (this *xlsxExportWriter) writeRow(cells []exportCell) error {
+	return this.writeCells(cells, -1)
+}
+
+// writeCells writes one row. A style of -1 styles each cell after its kind and column.
+func (this *xlsxExportWriter) writeCells(cells []exportCell, style int) error {
+	this.rowNumber++
+	row := strconv.Itoa(this.rowNumber)
+	this.sheet.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		if cell.kind == exportCellText && cell.text == "" {
+			continue
+		}
+		cellStyle := style
+		if cellStyle < 0 {
+			cellStyle = this.cellStyle(i, cell)
+		}
+		this.sheet.WriteString(`<c r="` + this.columnLetters[i] + row + `"`)
+		if cellStyle != xlsxStyleDefault {
+			this.sheet.WriteString(` s="` + strconv.Itoa(cellStyle) + `"`)
+		}
+		switch cell.kind {
+		case exportCellNumber:
+			this.sheet.WriteString(`><v>` + cell.text + `</v></c>`)
+		case exportCellDate, exportCellDateTime:
+			this.sheet.WriteString(`><v>` + strconv.FormatFloat(timeToExcelSerial(cell.time), 'f', -1, 64) + `</v></c>`)
+		default:
+			this.sheet.WriteString(` t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(this.sheet, []byte(cell.text)); err != nil {
+				return errors.Wrap(err, "xlsxExportWriter.writeCells")
+			}
+			this.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := this.sheet.WriteString(`</row>`)
+	return errors.Wrap(err, "xlsxExportWriter.writeCells")
+}
+
+func (this *xlsxExportWriter) cellStyle(column int, cell exportCell) int {
+	switch cell.kind {
+	case exportCellDate:
+		return xlsxStyleDate
+	case exportCellDateTime:
+		return xlsxStyleDateTime
+	case exportCellNumber:
+		return this.columnStyles[column]
+	}
+	return xlsxStyleDefault
+}
+
+func (this *xlsxExportWriter) close() error {
+	this.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := this.sheet.Flush(); err != nil {
+		return errors.Wrap(err, "xlsxExportWriter.close")
+	}
+	return errors.Wrap(this.archive.Close(), "xlsxExportWriter.close")
+}
+
+// xlsxColumnLetters is the reverse of xlsxColumnIndex: 0 is "A", 26 is "AA".
+func xlsxColumnLetters(index int) string {
+	letters := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		letters = string(rune('A'+(index-1)%26)) + letters
+	}
+	return letters
+}
+
+// timeToExcelSerial is the reverse of excelSerialToTime. Dates are written as they are stored, in
+// UTC, as the file carries no time zone.
+func timeToExcelSerial(at time.Time) float64 {
+	return at.UTC().Sub(excelEpoch).Hours() / 24
+}
+
+const xlsxMainNamespace = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+const xlsxContentTypes = xml.Header +
+	`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header +
+	`<workbook xmlns="` + xlsxMainNamespace + `" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles declares the cell styles in the order of the xlsxStyle constants: a bold header,
+// ISO dates and date-times, then a grouped number format per decimal scale.
+func xlsxStyles(scales []int) string {
+	formats := []string{"yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"}
+	for _, scale := range scales {
+		format := "#,##0"
+		if scale > 0 {
+			format += "." + strings.Repeat("0", scale)
+		}
+		formats = append(formats, format)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(xml.Header + `<styleSheet xmlns="` + xlsxMainNamespace + `">`)
+	builder.WriteString(`<numFmts count="` + strconv.Itoa(len(formats)) + `">`)
+	for i, format := range formats {
+		builder.WriteString(`<numFmt numFmtId="` + strconv.Itoa(xlsxFirstCustomFormat+i) +
+			`" formatCode="` + format + `"/>`)
+	}
+	builder.WriteString(`</numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+	builder.WriteString(`<cellXfs count="` + strconv.Itoa(xlsxStyleFirstDecimal+len(scales)) + `">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`)
+	for i := range formats {
+		builder.WriteString(`<xf numFmtId="` + strconv.Itoa(xlsxFirstCustomFormat+i) +
+			`" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`)
+	}
+	builder.WriteString(`</cellXfs></styleSheet>`)
+	return builder.String()
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// importColumn is where one column of an import file goes. A plain column fills field; a LangJson
+// column fills one translation of field; a lookup column names a peer record by a unique field of
+// the peer, and fills the foreign key columns of relation.
+type importColumn struct {
+	index    int
+	header   string
+	field    *dmodel.ModelField
+	language model.LanguageCode
+
+	relation   *dmodel.ModelRelation
+	peerSchema *dmodel.ModelSchema
+	peerField  string
+}
+
+func (this importColumn) isLookup() bool {
+	return this.relation != nil
+}
+
+// errorField is the field row errors about this column are reported under.
+func (this importColumn) errorField() string {
+	if this.isLookup() {
+		return this.relation.Edge + "." + this.peerField
+	}
+	return this.field.Name()
+}
+
+// mapImportColumns resolves every header of the file. A header names a field by its name or by
+// any of its label translations, case aside:
+//   - "name" or "Product name" fills a field; for a LangJson field, the default language.
+//   - "name:vi-VN" or "name[vi-VN]" fills one translation of a LangJson field.
+//   - "category.code" links a many-to-one relation to the peer whose unique "code" matches.
+//
+// A header that names nothing is rejected rather than skipped: a typo in a header would
+// otherwise import every row without that column, and report success.
+func mapImportColumns(schema *dmodel.ModelSchema, header []string) ([]importColumn, ft.ClientErrors) {
+	cErrs := ft.ClientErrors{}
+	columns := make([]importColumn, 0, len(header))
+	targets := map[string]string{}
+
+	for index, name := range header {
+		if name == "" {
+			continue
+		}
+		column, found := resolveImportColumn(schema, name)
+		if !found {
+			cErrs.Append(*ft.NewValidationError(importColumnErrorField(name), ft.ErrorKey("err_unknown_import_column"),
+				"column names no field of this resource"))
+			continue
+		}
+		column.index = index
+
+		target := column.errorField() + ":" + string(column.language)
+		if previous, taken := targets[target]; taken {
+			cErrs.Append(*ft.NewValidationError(importColumnErrorField(name), ft.ErrorKey("err_duplicate_import_column"),
+				fmt.Sprintf("column fills the same field as column '%s'", previous)))
+			continue
+		}
+		targets[target] = name
+		columns = append(columns, column)
+	}
+	return columns, cErrs
+}
+
+func importColumnErrorField(header string) string {
+	return "columns." + header
+}
+
+func resolveImportColumn(schema *dmodel.ModelSchema, header string) (importColumn, bool) {
+	if field := findImportField(schema, header); field != nil {
+		column := importColumn{header: header, field: field}
+		if field.DataType().String() == dmodel.FieldDataTypeNameLangJson {
+			column.language = model.DefaultLanguageCode
+		}
+		return column, true
+	}
+	if base, language, ok := splitImportLanguage(header); ok {
+		field := findImportField(schema, base)
+		if field != nil && field.DataType().String() == dmodel.FieldDataTypeNameLangJson {
+			return importColumn{header: header, field: field, language: language}, true
+		}
+	}
+	if edge, peerField, ok := strings.Cut(header, "."); ok {
+		return resolveImportLookup(schema, header, strings.TrimSpace(edge), strings.TrimSpace(peerField))
+	}
+	return importColumn{}, false
+}
+
+// splitImportLanguage reads the language suffix of "name:vi-VN" or "name[vi-VN]".
+func splitImportLanguage(header string) (string, model.LanguageCode, bool) {
+	if strings.HasSuffix(header, "]") {
+		if base, language, ok := strings.Cut(strings.TrimSuffix(header, "]"), "["); ok {
+			return strings.TrimSpace(base), model.LanguageCode(strings.TrimSpace(language)), true
+		}
+	}
+	if at := strings.LastIndex(header, ":"); at > 0 {
+		return strings.TrimSpace(header[:at]), model.LanguageCode(strings.TrimSpace(header[at+1:])), true
+	}
+	return "", "", false
+}
+
+// findImportField returns the field a header names, provided an import may write it. Keys and
+// values the system fills itself, and fields with no column, cannot be imported.
+func findImportField(schema *dmodel.ModelSchema, name string) *dmodel.ModelField {
+	for _, field := range schema.Columns() {
+		if !isImportableField(schema, field) {
+			continue
+		}
+		if strings.EqualFold(field.Name(), name) || matchesLabel(field.Label(), name) {
+			return field
+		}
+	}
+	return nil
+}
+
+func isImportableField(schema *dmodel.ModelSchema, field *dmodel.ModelField) bool {
+	return !field.IsVirtual() &&
+		!field.IsEdgeModel() &&
+		!field.IsAutoGenerated() &&
+		!field.IsServiceInjected() &&
+		!field.IsVersioningKey() &&
+		!schema.IsTenantKey(field.Name())
+}
+
+func matchesLabel(label model.LangJson, name string) bool {
+	for _, text := range label {
+		if text != "" && strings.EqualFold(text, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImportLookup accepts a relation this resource holds the foreign key of, looked up by a
+// field that identifies one peer record on its own: its primary key or a unique key.
+func resolveImportLookup(schema *dmodel.ModelSchema, header string, edge string, peerField string) (importColumn, bool) {
+	for _, relation := range schema.ToRelations() {
+		if relation.IsInverse || len(relation.EffectiveForeignKeys()) == 0 {
+			continue
+		}
+		if relation.RelationType != dmodel.RelationTypeManyToOne && relation.RelationType != dmodel.RelationTypeOneToOne {
+			continue
+		}
+		if !strings.EqualFold(relation.Edge, edge) && !matchesLabel(relation.Label(), edge) {
+			continue
+		}
+		peerSchema := dmodel.GetSchema(relation.DestSchemaName)
+		if peerSchema == nil {
+			return importColumn{}, false
+		}
+		field, exists := peerSchema.Column(peerField)
+		if !exists || !isSingleFieldKey(peerSchema, field.Name()) {
+			return importColumn{}, false
+		}
+		return importColumn{
+			header:     header,
+			relation:   &relation,
+			peerSchema: peerSchema,
+			peerField:  field.Name(),
+		}, true
+	}
+	return importColumn{}, false
+}
+
+// isSingleFieldKey reports whether the field alone identifies a record, within a tenant when the
+// schema has one.
+func isSingleFieldKey(schema *dmodel.ModelSchema, fieldName string) bool {
+	keys := append([][]string{schema.PrimaryKeys()}, schema.AllUniques()...)
+	return slices.ContainsFunc(keys, func(key []string) bool {
+		key = slices.DeleteFunc(slices.Clone(key), schema.IsTenantKey)
+		return len(key) == 1 && key[0] == fieldName
+	})
+}
+
+// isUniqueKey reports whether the field set, in any order, is the primary key or a unique key.
+func isUniqueKey(schema *dmodel.ModelSchema, fieldNames []string) bool {
+	wanted := slices.Sorted(slices.Values(fieldNames))
+	keys := append([][]string{schema.PrimaryKeys()}, schema.AllUniques()...)
+	return slices.ContainsFunc(keys, func(key []string) bool {
+		key = slices.DeleteFunc(slices.Clone(key), schema.IsTenantKey)
+		slices.Sort(key)
+		return slices.Equal(key, wanted)
+	})
+}
+
+// importCellValue turns a cell into what the field's data type converts from. Most types parse
+// text themselves; the exceptions are JSON-written arrays and the serial numbers XLSX stores
+// dates as.
+func importCellValue(field *dmodel.ModelField, cell string) any {
+	if field.IsArray() && strings.HasPrefix(cell, "[") {
+		var items []any
+		if err := json.Unmarshal([]byte(cell), &items); err == nil {
+			return items
+		}
+		return cell
+	}
+	switch field.DataType().String() {
+	case dmodel.FieldDataTypeNameModelDate, dmodel.FieldDataTypeNameModelDateTime:
+		if serial, err := strconv.ParseFloat(cell, 64); err == nil {
+			return excelSerialToTime(serial)
+		}
+	}
+	return cell
+}
+
+// excelEpoch is day zero of the 1900 date system. It is the 30th rather than the 31st because
+// the format counts a 29 February 1900 that never was.
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+func excelSerialToTime(serial float64) time.Time {
+	days := int(serial)
+	seconds := (serial - float64(days)) * 24 * 60 * 60
+	return excelEpoch.AddDate(0, 0, days).Add(time.Duration(seconds+0.5) * time.Second)
+}
@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.bryk.io/pkg/errors"
+
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// Bounds on what an import reads. The file size is checked as it is uploaded; an XLSX part is
+// checked as it is decompressed, since a small archive can inflate to gigabytes.
+const (
+	maxImportFileBytes = 20 << 20
+	maxImportPartBytes = 100 << 20
+	maxImportRows      = 50000
+	// maxXlsxColumns is the widest sheet a spreadsheet application writes (column XFD).
+	maxXlsxColumns = 16384
+)
+
+// importTable is an uploaded file read into text cells: the header row, then one slice per
+// data row. Rows may be shorter than the header when trailing cells are empty. Blank rows are
+// dropped, so rowNumbers keeps each row's position in the file, which is what a user finds it by.
+type importTable struct {
+	header     []string
+	rows       [][]string
+	rowNumbers []int
+}
+
+// detectImportFormat picks the format from the explicit param first, then from the file name.
+// XLSX is a zip archive, so a file starting with the zip signature is taken as XLSX too.
+func detectImportFormat(format string, fileName string, content []byte) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".xlsx":
+		return it.ImportFormatXlsx
+	case ".csv", ".txt":
+		return it.ImportFormatCsv
+	}
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return it.ImportFormatXlsx
+	}
+	return it.ImportFormatCsv
+}
+
+func readImportTable(format string, content []byte) (*importTable, error) {
+	var records [][]string
+	var err error
+	switch format {
+	case it.ImportFormatCsv:
+		records, err = readCsvRecords(content)
+	case it.ImportFormatXlsx:
+		records, err = readXlsxRecords(content)
+	default:
+		return nil, errors.Errorf("readImportTable: unsupported format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	table := &importTable{}
+	for i, record := range records {
+		if isBlankRecord(record) {
+			continue
+		}
+		if table.header == nil {
+			table.header = make([]string, len(record))
+			for j, name := range record {
+				table.header[j] = strings.TrimSpace(name)
+			}
+			continue
+		}
+		if len(table.rows) == maxImportRows {
+			return nil, errors.Errorf("readImportTable: file has more than %d rows", maxImportRows)
+		}
+		table.rows = append(table.rows, record)
+		table.rowNumbers = append(table.rowNumbers, i+1)
+	}
+	return table, nil
+}
+
+var utf8Bom = []byte("\xef\xbb\xbf")
+
+// readCsvRecords reads comma- or semicolon-separated text. Spreadsheet software in locales
+// that write a decimal comma saves CSV with semicolons, so the header line decides which.
+func readCsvRecords(content []byte) ([][]string, error) {
+	content = bytes.TrimPrefix(content, utf8Bom)
+	headerLine, _, _ := bytes.Cut(content, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	if bytes.Count(headerLine, []byte(";")) > bytes.Count(headerLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	return records, errors.Wrap(err, "readCsvRecords")
+}
+
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readXlsxRecords reads the first worksheet of an XLSX workbook. Only cell values are read:
+// a formula cell gives its cached result, and a date cell its serial number, which the column
+// mapping converts once it knows the target field is a date.
+func readXlsxRecords(content []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.Wrap(err, "readXlsxRecords: not an XLSX file")
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := xlsxFirstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	sharedStrings, err := xlsxSharedStrings(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Number int        `xml:"r,attr"`
+			Cells  []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeXlsxPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	records := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		// The header takes one row more than the data rows, and a row number past that is refused
+		// before padding would allocate up to it.
+		if row.Number > maxImportRows+1 {
+			return nil, errors.Errorf("readXlsxRecords: sheet has more than %d rows", maxImportRows)
+		}
+		// An empty row is left out of the sheet; padding keeps every record at its row number.
+		for row.Number > len(records)+1 {
+			records = append(records, nil)
+		}
+		record := []string{}
+		for i, cell := range row.Cells {
+			column := i
+			if cell.Ref != "" {
+				column = xlsxColumnIndex(cell.Ref)
+			}
+			if column < 0 || column >= maxXlsxColumns {
+				return nil, errors.Errorf("readXlsxRecords: cell '%s' is outside the sheet", cell.Ref)
+			}
+			for len(record) <= column {
+				record = append(record, "")
+			}
+			record[column] = cell.text(sharedStrings)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+type xlsxCell struct {
+	Ref        string `xml:"r,attr"`
+	Type       string `xml:"t,attr"`
+	Value      string `xml:"v"`
+	InlineText string `xml:"is>t"`
+}
+
+func (this xlsxCell) text(sharedStrings []string) string {
+	switch this.Type {
+	case "s":
+		index, err := strconv.Atoi(this.Value)
+		if err != nil || index < 0 || index >= len(sharedStrings) {
+			return ""
+		}
+		return sharedStrings[index]
+	case "inlineStr":
+		return this.InlineText
+	case "b":
+		if this.Value == "1" {
+			return "true"
+		}
+		return "false"
+	}
+	return this.Value
+}
+
+// xlsxColumnIndex turns the letters of a cell reference such as "AB12" into a zero-based index.
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	for _, char := range ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		index = index*26 + int(char-'A'+1)
+	}
+	return index - 1
+}
+
+// xlsxFirstSheetPath follows the workbook relationships to the first sheet, which is not
+// necessarily named sheet1.xml.
+func xlsxFirstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RelId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXlsxPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("xlsxFirstSheetPath: workbook has no sheet")
+	}
+
+	var rels struct {
+		Items []struct {
+			Id     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXlsxPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Items {
+		if rel.Id != workbook.Sheets[0].RelId {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("xlsxFirstSheetPath: first sheet has no relationship")
+}
+
+// xlsxSharedStrings reads the shared string table. A rich-text entry is split into runs,
+// whose texts are joined.
+func xlsxSharedStrings(files map[string]*zip.File) ([]string, error) {
+	if _, exists := files["xl/sharedStrings.xml"]; !exists {
+		return nil, nil
+	}
+	var table struct {
+		Items []struct {
+			Text string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+	if err := decodeXlsxPart(files, "xl/sharedStrings.xml", &table); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		out[i] = item.Text + strings.Join(item.Runs, "")
+	}
+	return out, nil
+}
+
+func decodeXlsxPart(files map[string]*zip.File, name string, target any) error {
+	file, exists := files[name]
+	if !exists {
+		return errors.Errorf("decodeXlsxPart: missing part '%s'", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return errors.Wrapf(err, "decodeXlsxPart: open '%s'", name)
+	}
+	defer reader.Close()
+
+	// The size in the archive header is the archive's own claim, so the read is bounded as well.
+	if file.UncompressedSize64 > maxImportPartBytes {
+		return errors.Errorf("decodeXlsxPart: part '%s' is larger than %d bytes", name, maxImportPartBytes)
+	}
+	raw, err := io.ReadAll(io.LimitReader(reader, maxImportPartBytes+1))
+	if err != nil {
+		return errors.Wrapf(err, "decodeXlsxPart: read '%s'", name)
+	}
+	if len(raw) > maxImportPartBytes {
+		return errors.Errorf("decodeXlsxPart: part '%s' is larger than %d bytes", name, maxImportPartBytes)
+	}
+	return errors.Wrapf(xml.Unmarshal(raw, target), "decodeXlsxPart: decode '%s'", name)
+}
@@ -0,0 +1,421 @@
+package engine
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+const (
+	importCategorySchema = "test_import_category"
+	importProductSchema  = "test_import_product"
+)
+
+// importSchemas registers a product holding a many-to-one edge to a category, which a lookup
+// column resolves by the category's unique code.
+func importSchemas(t *testing.T) *dmodel.ModelSchema {
+	t.Helper()
+	registry := dmodel.GetSchemaRegistry()
+	if existing := registry.Get(importProductSchema); existing != nil {
+		return existing
+	}
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(importCategorySchema).
+			TableName("test_import_categories").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).PrimaryKey(true)).
+			Field(dmodel.DefineField().Name("code").
+				DataType(dmodel.FieldDataTypeString(1, 20)).RequiredForCreate().Unique())))
+
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(importProductSchema).
+			TableName("test_import_products").
+			ShouldBuildDb().
+			Field(dmodel.DefineField().Name("id").
+				DataType(dmodel.FieldDataTypeUlid()).PrimaryKey(true)).
+			Field(dmodel.DefineField().Name("code").
+				DataType(dmodel.FieldDataTypeString(1, 20)).RequiredForCreate().Unique()).
+			Field(dmodel.DefineField().Name("name").
+				Label(model.LangJson{model.LanguageCodeEnUs: "Product name"}).
+				DataType(dmodel.FieldDataTypeLangJson(0, 50))).
+			Field(dmodel.DefineField().Name("qty").
+				DataType(dmodel.FieldDataTypeInt64(0, 1000))).
+			Field(dmodel.DefineField().Name("category_id").
+				DataType(dmodel.FieldDataTypeUlid())).
+			EdgeTo(dmodel.Edge("category").ManyToOne(
+				importCategorySchema, dmodel.DynamicFields{"category_id": "id"}))))
+
+	require.NoError(t, registry.FinalizeRelations())
+	return registry.Get(importProductSchema)
+}
+
+// importStubRepository finds the stored products listed in existing, by code.
+type importStubRepository struct {
+	it.DynamicResourceRepository
+
+	existing map[string]dmodel.DynamicFields
+	tx       *importTransaction
+}
+
+func (this *importStubRepository) FindByKeys(
+	_ corectx.Context, keys dmodel.DynamicFields,
+) (*dyn.OpResult[dmodel.DynamicFields], error) {
+	found, exists := this.existing[keys["code"].(string)]
+	return &dyn.OpResult[dmodel.DynamicFields]{Data: found, HasData: exists}, nil
+}
+
+const importCategoryId = "01JZ0000000000000000000001"
+
+func newImportService(t *testing.T, existing map[string]dmodel.DynamicFields) *DynamicResourceServiceImpl {
+	return NewDynamicResourceService(NewServiceParam{
+		Schema:     importSchemas(t),
+		Repository: &importStubRepository{existing: existing},
+		SourceSearch: func(
+			_ corectx.Context, schemaName string, keyColumn string, keys []any, _ []string,
+		) ([]dmodel.DynamicFields, error) {
+			rows := []dmodel.DynamicFields{}
+			for _, key := range keys {
+				if schemaName == importCategorySchema && keyColumn == "code" && key == "TOOLS" {
+					rows = append(rows, dmodel.DynamicFields{"code": "TOOLS", "id": importCategoryId})
+				}
+			}
+			return rows, nil
+		},
+	}).(*DynamicResourceServiceImpl)
+}
+
+func TestReadImportTable_CsvKeepsFileRowNumbers(t *testing.T) {
+	content := "\xef\xbb\xbfcode;qty\nA;1\n;\nB;2\n"
+
+	table, err := readImportTable(it.ImportFormatCsv, []byte(content))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"code", "qty"}, table.header, "the BOM is dropped and the semicolon detected")
+	assert.Equal(t, [][]string{{"A", "1"}, {"B", "2"}}, table.rows)
+	assert.Equal(t, []int{2, 4}, table.rowNumbers, "the blank row still counts")
+}
+
+func TestReadImportTable_Xlsx(t *testing.T) {
+	content := buildXlsx(t, `<sheetData>`+
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>qty</t></is></c></row>`+
+		`<row r="3"><c r="A3" t="s"><v>1</v></c><c r="C3"><v>45352</v></c></row>`+
+		`</sheetData>`)
+
+	table, err := readImportTable(it.ImportFormatXlsx, content)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"code", "qty"}, table.header)
+	assert.Equal(t, [][]string{{"A-1", "", "45352"}}, table.rows, "a skipped cell is kept as empty")
+	assert.Equal(t, []int{3}, table.rowNumbers, "a row left out of the sheet still counts")
+}
+
+func TestReadImportTable_RefusesMoreRowsThanTheLimit(t *testing.T) {
+	content := "code\n" + strings.Repeat("A\n", maxImportRows+1)
+
+	_, err := readImportTable(it.ImportFormatCsv, []byte(content))
+
+	assert.ErrorContains(t, err, "more than")
+}
+
+// A row or cell reference far past the data would otherwise have the reader pad up to it.
+func TestReadImportTable_XlsxRefusesReferencesPastTheSheet(t *testing.T) {
+	content := buildXlsx(t, `<sheetData><row r="99999999"><c r="A99999999"><v>1</v></c></row></sheetData>`)
+	_, err := readImportTable(it.ImportFormatXlsx, content)
+	assert.ErrorContains(t, err, "more than")
+
+	content = buildXlsx(t, `<sheetData><row r="1"><c r="ZZZZZZ1"><v>1</v></c></row></sheetData>`)
+	_, err = readImportTable(it.ImportFormatXlsx, content)
+	assert.ErrorContains(t, err, "outside the sheet")
+}
+
+// A part that inflates past the limit is refused however small the archive is.
+func TestDecodeXlsxPart_RefusesAnOversizedPart(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	part, err := writer.Create("xl/workbook.xml")
+	require.NoError(t, err)
+	chunk := bytes.Repeat([]byte(" "), 1<<20)
+	for written := 0; written <= maxImportPartBytes; written += len(chunk) {
+		_, err = part.Write(chunk)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	assert.Less(t, buffer.Len(), 1<<20)
+
+	_, err = readImportTable(it.ImportFormatXlsx, buffer.Bytes())
+
+	assert.ErrorContains(t, err, "larger than")
+}
+
+func TestDetectImportFormat(t *testing.T) {
+	assert.Equal(t, it.ImportFormatXlsx, detectImportFormat("", "stock.XLSX", nil))
+	assert.Equal(t, it.ImportFormatCsv, detectImportFormat("", "stock.csv", nil))
+	assert.Equal(t, it.ImportFormatXlsx, detectImportFormat("", "", []byte("PK\x03\x04...")))
+	assert.Equal(t, it.ImportFormatCsv, detectImportFormat("CSV", "stock.xlsx", nil), "the explicit format wins")
+}
+
+func TestMapImportColumns(t *testing.T) {
+	schema := importSchemas(t)
+
+	columns, cErrs := mapImportColumns(schema, []string{"CODE", "Product name", "name[vi-VN]", "category.code", ""})
+
+	require.Zero(t, cErrs.Count())
+	require.Len(t, columns, 4)
+	assert.Equal(t, "code", columns[0].field.Name())
+	assert.Equal(t, model.DefaultLanguageCode, columns[1].language, "a plain LangJson column is the default language")
+	assert.Equal(t, model.LanguageCode("vi-VN"), columns[2].language)
+	assert.True(t, columns[3].isLookup())
+	assert.Equal(t, "category.code", columns[3].errorField())
+}
+
+func TestMapImportColumns_RejectsUnknownAndDuplicateColumns(t *testing.T) {
+	schema := importSchemas(t)
+
+	_, cErrs := mapImportColumns(schema, []string{"code", "Code", "colour", "id", "category.name"})
+
+	fields := []string{}
+	for _, item := range cErrs {
+		fields = append(fields, item.Field+" "+item.Key)
+	}
+	assert.ElementsMatch(t, []string{
+		"columns.Code common:err_duplicate_import_column",
+		"columns.colour common:err_unknown_import_column",
+		"columns.id common:err_unknown_import_column",
+		"columns.category.name common:err_unknown_import_column",
+	}, fields, "an auto-generated key and a non-unique lookup field cannot be imported")
+}
+
+func TestImport_DryRunReportsRowErrorsByFileRow(t *testing.T) {
+	service := newImportService(t, nil)
+
+	result, err := service.Import(ownerContext(), dmodel.DynamicFields{
+		it.ImportParamContent:  []byte("code,qty,category.code\nA,1,TOOLS\n,2,TOOLS\nC,abc,NONE\n"),
+		it.ImportParamFileName: "products.csv",
+		it.ImportParamDryRun:   true,
+	}, nil)
+
+	require.NoError(t, err)
+	fields := []string{}
+	for _, item := range result.ClientErrors {
+		fields = append(fields, item.Field)
+	}
+	assert.ElementsMatch(t, []string{"rows[3].code", "rows[4].qty", "rows[4].category.code"}, fields)
+}
+
+func TestImport_DryRunCountsCreatesAndUpserts(t *testing.T) {
+	service := newImportService(t, map[string]dmodel.DynamicFields{
+		"A": {"id": "01JZ0000000000000000000002", "code": "A"},
+	})
+
+	result, err := service.Import(ownerContext(), dmodel.DynamicFields{
+		it.ImportParamContent:  []byte("code,name:vi-VN,Product name,category.code\nA,Búa,Hammer,TOOLS\nB,,Saw,\n"),
+		it.ImportParamDryRun:   true,
+		it.ImportParamUpsertBy: "code",
+	}, nil)
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count())
+	assert.Equal(t, it.ImportResultData{DryRun: true, TotalRows: 2, CreatedCount: 1, UpdatedCount: 1}, result.Data)
+}
+
+func TestImport_RowsAreMappedWithTranslationsAndLookups(t *testing.T) {
+	schema := importSchemas(t)
+	columns, cErrs := mapImportColumns(schema, []string{"code", "name:vi-VN", "name", "category.code"})
+	require.Zero(t, cErrs.Count())
+	table := &importTable{rows: [][]string{{"A", "Búa", "Hammer", "TOOLS"}}, rowNumbers: []int{2}}
+
+	rows, cErrs, err := newImportService(t, nil).prepareImportRows(ownerContext(), importOptions{}, columns, table)
+
+	require.NoError(t, err)
+	require.Zero(t, cErrs.Count())
+	assert.Equal(t, dmodel.DynamicFields{
+		"code":        "A",
+		"name":        model.LangJson{"vi-VN": "Búa", model.DefaultLanguageCode: "Hammer"},
+		"category_id": importCategoryId,
+	}, rows[0].data)
+}
+
+func TestImport_RejectsDuplicateUpsertKeysAndNonUniqueUpsertFields(t *testing.T) {
+	service := newImportService(t, nil)
+
+	result, err := service.Import(ownerContext(), dmodel.DynamicFields{
+		it.ImportParamContent:  []byte("code,qty\nA,1\nA,2\n"),
+		it.ImportParamUpsertBy: []string{"code"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, "rows[3].code", result.ClientErrors[0].Field)
+	assert.Equal(t, "common:err_duplicate_import_key", result.ClientErrors[0].Key)
+
+	result, err = service.Import(ownerContext(), dmodel.DynamicFields{
+		it.ImportParamContent:  []byte("code,qty\nA,1\n"),
+		it.ImportParamUpsertBy: "qty",
+	}, nil)
+	require.NoError(t, err)
+	assert.True(t, result.ClientErrors.Has(it.ImportParamUpsertBy))
+}
+
+// importTransaction records how the write pass ended.
+type importTransaction struct {
+	committed  bool
+	rolledBack bool
+}
+
+func (this *importTransaction) Commit() error {
+	this.committed = true
+	return nil
+}
+
+func (this *importTransaction) Rollback() error {
+	this.rolledBack = true
+	return nil
+}
+
+func (this *importStubRepository) BeginTransaction(_ corectx.Context) (database.DbTransaction, error) {
+	this.tx = &importTransaction{}
+	return this.tx, nil
+}
+
+// newImportEngine serves the import service through an engine with the built-in actions, whose
+// create action is then replaced by a module's.
+func newImportEngine(t *testing.T, create it.DynamicActionDelta) (*DynamicResourceEngineImpl, *importStubRepository) {
+	t.Helper()
+	service := newImportService(t, map[string]dmodel.DynamicFields{
+		"A": {"id": "01JZ0000000000000000000002", "code": "A"},
+	})
+	repository := service.repository.(*importStubRepository)
+	engine := NewDynamicResourceEngine(NewEngineParam{
+		Schema:     service.Schema(),
+		Repository: repository,
+		Service:    service,
+	}).(*DynamicResourceEngineImpl)
+	require.NoError(t, DefineBuiltinActions(engine))
+	create.ActionName = it.ActionCreate
+	require.NoError(t, engine.ModifyAction(create))
+	return engine, repository
+}
+
+// An imported row is written by the create action a module made of the built-in one, inside the
+// import's transaction.
+func TestImport_WritesEachRowThroughTheCreateAction(t *testing.T) {
+	created := []dmodel.DynamicFields{}
+	engine, repository := newImportEngine(t, it.DynamicActionDelta{
+		MainProcess: func(ctx corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+			assert.NotNil(t, ctx.GetDbTranx())
+			assert.Equal(t, it.ActionImport, dyn.ChangeActionOf(ctx))
+			created = append(created, input.Params)
+			return &it.ActionResult{Data: input.Params, HasData: true}, nil
+		},
+	})
+
+	result, err := engine.ExecuteAction(ownerContext(), it.ActionImport, dmodel.DynamicFields{
+		it.ImportParamContent: []byte("code,qty\nB,1\nC,2\n"),
+	})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	require.Len(t, created, 2)
+	assert.Equal(t, "B", created[0]["code"])
+	assert.Equal(t, "C", created[1]["code"])
+	assert.True(t, repository.tx.committed)
+}
+
+// A resource refusing a client-written create refuses an imported one, and nothing is kept.
+func TestImport_RowsRefusedByTheCreateActionAreReportedAndRolledBack(t *testing.T) {
+	engine, repository := newImportEngine(t, it.DynamicActionDelta{
+		ValidateExtra: func(
+			_ corectx.Context, params dmodel.DynamicFields, _ *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+		) error {
+			if params["code"] == "C" {
+				vErrs.Append(*ft.NewBusinessViolation("code", "test.err_refused", "refused"))
+			}
+			return nil
+		},
+		MainProcess: func(_ corectx.Context, input it.ProcessInput) (*it.ActionResult, error) {
+			return &it.ActionResult{Data: input.Params, HasData: true}, nil
+		},
+	})
+
+	result, err := engine.ExecuteAction(ownerContext(), it.ActionImport, dmodel.DynamicFields{
+		it.ImportParamContent: []byte("code,qty\nB,1\nC,2\n"),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, "rows[3].code", result.ClientErrors[0].Field)
+	assert.True(t, repository.tx.rolledBack)
+	assert.False(t, repository.tx.committed)
+}
+
+// An upsert key turns rows into updates, so it needs the update permission, even for a dry run.
+func TestImport_UpsertNeedsTheUpdatePermission(t *testing.T) {
+	engine, _ := newImportEngine(t, it.DynamicActionDelta{})
+	openImport := ""
+	require.NoError(t, engine.ModifyAction(it.DynamicActionDelta{ActionName: it.ActionImport, Permission: &openImport}))
+
+	result, err := engine.ExecuteAction(deniedContext(), it.ActionImport, dmodel.DynamicFields{
+		it.ImportParamContent:  []byte("code,qty\nA,1\n"),
+		it.ImportParamDryRun:   true,
+		it.ImportParamUpsertBy: "code",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, ft.ErrorKey("err_insufficient_permissions"), result.ClientErrors[0].Key)
+
+	result, err = engine.ExecuteAction(deniedContext(), it.ActionImport, dmodel.DynamicFields{
+		it.ImportParamContent: []byte("code,qty\nA,1\n"),
+		it.ImportParamDryRun:  true,
+	})
+	require.NoError(t, err)
+	assert.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+}
+
+func TestImportCellValue_ConvertsExcelSerialDates(t *testing.T) {
+	field := dmodel.DefineField().Name("due").DataType(dmodel.FieldDataTypeDateTime()).Build()
+
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), importCellValue(field, "45352.5"))
+	assert.Equal(t, "2024-03-01", importCellValue(field, "2024-03-01"))
+}
+
+// buildXlsx zips the smallest workbook the reader accepts, with the sheet stored under a name
+// other than sheet1.xml so that the relationship is followed rather than guessed.
+func buildXlsx(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Products" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId7" Target="worksheets/products.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>code</t></si><si><r><t>A</t></r><r><t>-1</t></r></si></sst>`,
+		"xl/worksheets/products.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			sheetData + `</worksheet>`,
+	}
+
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for name, content := range parts {
+		part, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
@@ -0,0 +1,463 @@
+package engine
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// Component names shared by every resource. Those of a resource are prefixed with its name.
+const (
+	openApiClientErrors     = "ClientErrors"
+	openApiCreateResponse   = "RestCreateResponse"
+	openApiMutateResponse   = "RestMutateResponse"
+	openApiSingleMeta       = "SingleMetaData"
+	openApiSecurityScheme   = "bearerAuth"
+	openApiMimeJson         = "application/json"
+	openApiMimeMultipart    = "multipart/form-data"
+	openApiMimeXlsx         = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	openApiMimeCsv          = "text/csv"
+	openApiSuffixCreate     = "_create"
+	openApiSuffixUpdate     = "_update"
+	openApiSuffixGetOne     = "_get_response"
+	openApiSuffixSearch     = "_search_response"
+	openApiSuffixActionBody = "_params"
+)
+
+// BuildOpenApiDocument describes the REST surface of the given engines, as they were mounted:
+// every path comes from the routes an engine recorded when its RestApi was registered, so an
+// engine no module mounted is left out, as it answers no request.
+//
+// Request and response shapes follow the bindings the generic handler applies to the built-in
+// actions. An action of a feature module is described from its ParamSchema, and an action that
+// installed its own RestHandler only by its path, as the shape of its payload is its own.
+func BuildOpenApiDocument(info OpenApiInfo, engines []it.DynamicResourceEngine) *OpenApiDocument {
+	builder := &openApiBuilder{document: &OpenApiDocument{
+		OpenApi: OpenApiVersion,
+		Info:    info,
+		Paths:   map[string]OpenApiPathItem{},
+		Components: OpenApiComponents{
+			Schemas: sharedComponentSchemas(),
+			SecuritySchemes: map[string]OpenApiSecurityScheme{
+				openApiSecurityScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		Security: []map[string][]string{{openApiSecurityScheme: {}}},
+	}}
+	for _, engine := range engines {
+		builder.addEngine(engine)
+	}
+	return builder.document
+}
+
+type openApiBuilder struct {
+	document *OpenApiDocument
+}
+
+func (this *openApiBuilder) addEngine(engine it.DynamicResourceEngine) {
+	routes := engine.RestApi().Routes()
+	schema := engine.Schema()
+	if len(routes) == 0 || schema == nil {
+		return
+	}
+
+	resourceName := engine.ResourceName()
+	this.document.Tags = append(this.document.Tags, OpenApiTag{
+		Name:        resourceName,
+		Description: langJsonText(schema.Description()),
+	})
+	this.addResourceSchemas(resourceName, schema)
+
+	for _, route := range routes {
+		definition, exists := engine.Action(route.ActionName)
+		if !exists {
+			continue
+		}
+		path, pathParams := openApiPath(route.Path)
+		pathItem, exists := this.document.Paths[path]
+		if !exists {
+			pathItem = OpenApiPathItem{}
+			this.document.Paths[path] = pathItem
+		}
+		pathItem[strings.ToLower(route.Method)] = this.operation(resourceName, schema, definition, route.Method, pathParams)
+	}
+}
+
+// addResourceSchemas adds the record of a resource and the bodies its create and update take.
+func (this *openApiBuilder) addResourceSchemas(resourceName string, schema *dmodel.ModelSchema) {
+	schemas := this.document.Components.Schemas
+	schemas[resourceName] = recordJsonSchema(schema)
+	schemas[resourceName+openApiSuffixCreate] = writeJsonSchema(schema, false)
+	schemas[resourceName+openApiSuffixUpdate] = writeJsonSchema(schema, true)
+	schemas[resourceName+openApiSuffixGetOne] = objectSchema(map[string]*JsonSchema{
+		"item": schemaRef(resourceName),
+		"meta": schemaRef(openApiSingleMeta),
+	}, "item", "meta")
+	schemas[resourceName+openApiSuffixSearch] = objectSchema(map[string]*JsonSchema{
+		"items":          {Type: "array", Items: schemaRef(resourceName)},
+		"total":          {Type: "integer"},
+		"page":           {Type: "integer"},
+		"size":           {Type: "integer"},
+		"next_cursor":    {Type: "string"},
+		"desired_fields": stringArraySchema(),
+		"masked_fields":  stringArraySchema(),
+		"schema_etag":    {Type: "string"},
+	}, "items", "total", "page", "size")
+}
+
+// operation describes one action. The switch mirrors DynamicRestApiImpl.bindingFor, which is
+// what turns the request into params and the result into a payload.
+func (this *openApiBuilder) operation(
+	resourceName string, schema *dmodel.ModelSchema, definition it.DynamicActionDefinition,
+	method string, pathParams []string,
+) *OpenApiOperation {
+	operation := &OpenApiOperation{
+		OperationId: resourceName + "_" + definition.ActionName,
+		Tags:        []string{resourceName},
+		Summary:     builtinActionSummaries[definition.ActionName],
+		Parameters:  pathParameters(schema, pathParams),
+		Responses:   errorResponses(),
+	}
+	if definition.RestHandler != nil {
+		operation.Summary = ""
+		operation.Responses["200"] = OpenApiResponse{Description: "Handled by the action's own REST handler"}
+		return operation
+	}
+
+	switch definition.ActionName {
+	case it.ActionCreate:
+		operation.RequestBody = jsonRequestBody(schemaRef(resourceName + openApiSuffixCreate))
+		operation.Responses["201"] = jsonResponse("The created record", schemaRef(openApiCreateResponse))
+	case it.ActionUpdate:
+		operation.RequestBody = jsonRequestBody(schemaRef(resourceName + openApiSuffixUpdate))
+		operation.Responses["200"] = jsonResponse("The update outcome", schemaRef(openApiMutateResponse))
+	case it.ActionDelete:
+		operation.Parameters = append(operation.Parameters, queryParameter(queryParamOrgId, &JsonSchema{Type: "string"},
+			"Organization of the record, for a resource scoped by organization"))
+		operation.Responses["200"] = jsonResponse("The deletion outcome", schemaRef(openApiMutateResponse))
+	case it.ActionSetArchived:
+		operation.RequestBody = jsonRequestBody(objectSchema(map[string]*JsonSchema{
+			"is_archived": {Type: "boolean"},
+			"etag":        {Type: "string"},
+		}, "is_archived", "etag"))
+		operation.Responses["200"] = jsonResponse("The archiving outcome", schemaRef(openApiMutateResponse))
+	case it.ActionGetById:
+		operation.Parameters = append(operation.Parameters, fieldsParameter())
+		operation.Responses["200"] = jsonResponse("The record", schemaRef(resourceName+openApiSuffixGetOne))
+	case it.ActionSearch:
+		operation.Parameters = append(operation.Parameters, searchParameters()...)
+		operation.Responses["200"] = jsonResponse("A page of records", schemaRef(resourceName+openApiSuffixSearch))
+	case it.ActionExport:
+		operation.Parameters = append(operation.Parameters, searchParameters()...)
+		operation.Parameters = append(operation.Parameters,
+			queryParameter(it.ExportParamFormat, enumStringSchema(it.ExportFormatCsv, it.ExportFormatXlsx), "File format, csv by default"),
+			queryParameter(it.ExportParamAsync, &JsonSchema{Type: "boolean"},
+				"Write the file to storage in the background and answer with a link to it"),
+		)
+		binary := &JsonSchema{Type: "string", Format: "binary"}
+		operation.Responses["200"] = OpenApiResponse{
+			Description: "The exported file, or a link to it when async is set",
+			Content: map[string]OpenApiMediaType{
+				openApiMimeCsv:  {Schema: binary},
+				openApiMimeXlsx: {Schema: binary},
+				openApiMimeJson: {Schema: objectSchema(map[string]*JsonSchema{
+					"file_name":  {Type: "string"},
+					"object_key": {Type: "string"},
+					"url":        {Type: "string", Format: "uri"},
+					"expires_at": {Type: "string", Format: "date-time"},
+				})},
+			},
+		}
+	case it.ActionImport:
+		operation.RequestBody = &OpenApiRequestBody{
+			Required: true,
+			Content: map[string]OpenApiMediaType{openApiMimeMultipart: {Schema: objectSchema(map[string]*JsonSchema{
+				importFileField:         {Type: "string", Format: "binary"},
+				it.ImportParamFormat:    enumStringSchema(it.ImportFormatCsv, it.ImportFormatXlsx),
+				it.ImportParamBatchSize: {Type: "integer"},
+				it.ImportParamUpsertBy:  {Type: "string"},
+				it.ImportParamDryRun:    {Type: "boolean"},
+			}, importFileField)}},
+		}
+		operation.Responses["200"] = jsonResponse("The import outcome", objectSchema(map[string]*JsonSchema{
+			"dry_run":       {Type: "boolean"},
+			"total_rows":    {Type: "integer"},
+			"created_count": {Type: "integer"},
+			"updated_count": {Type: "integer"},
+		}))
+	case it.ActionHistory:
+		operation.Parameters = append(operation.Parameters,
+			queryParameter(queryParamPage, &JsonSchema{Type: "integer"}, ""),
+			queryParameter(queryParamSize, &JsonSchema{Type: "integer"}, ""),
+		)
+		operation.Responses["200"] = jsonResponse("A page of the record's field changes", &JsonSchema{Type: "object"})
+	case it.ActionExists, it.ActionAggregate:
+		operation.RequestBody = jsonRequestBody(&JsonSchema{Type: "object"})
+		operation.Responses["200"] = jsonResponse("The query outcome", &JsonSchema{Type: "object"})
+	case it.ActionGetSchema:
+		operation.Responses["200"] = jsonResponse("The resource schema", &JsonSchema{Type: "object"})
+	default:
+		this.describeCustomAction(operation, resourceName, definition, method, pathParams)
+	}
+	return operation
+}
+
+// describeCustomAction describes an action of a feature module, whose params echoBindParams
+// binds: from the query string of a GET or DELETE, from the JSON body otherwise.
+func (this *openApiBuilder) describeCustomAction(
+	operation *OpenApiOperation, resourceName string, definition it.DynamicActionDefinition,
+	method string, pathParams []string,
+) {
+	operation.Responses["200"] = jsonResponse("The action result", &JsonSchema{})
+	hasQuery := method == http.MethodGet || method == http.MethodDelete
+
+	if definition.ParamSchema == nil {
+		if !hasQuery {
+			operation.RequestBody = &OpenApiRequestBody{
+				Content: map[string]OpenApiMediaType{openApiMimeJson: {Schema: &JsonSchema{Type: "object"}}},
+			}
+		}
+		return
+	}
+	paramSchema := definition.ParamSchema()
+	if paramSchema == nil {
+		return
+	}
+
+	body := paramsJsonSchema(paramSchema, definition.ValidateAsEdit, pathParams)
+	if hasQuery {
+		for _, name := range paramSchema.FieldNames() {
+			property, exists := body.Properties[name]
+			if !exists {
+				continue
+			}
+			parameter := queryParameter(name, property, "")
+			parameter.Required = slices.Contains(body.Required, name)
+			operation.Parameters = append(operation.Parameters, parameter)
+		}
+		return
+	}
+	componentName := resourceName + "_" + definition.ActionName + openApiSuffixActionBody
+	this.document.Components.Schemas[componentName] = body
+	operation.RequestBody = jsonRequestBody(schemaRef(componentName))
+	operation.RequestBody.Required = len(body.Required) > 0
+}
+
+var builtinActionSummaries = map[string]string{
+	it.ActionCreate:      "Create a record",
+	it.ActionUpdate:      "Update a record",
+	it.ActionDelete:      "Delete a record",
+	it.ActionSetArchived: "Archive or restore a record",
+	it.ActionGetById:     "Get a record by its id",
+	it.ActionSearch:      "Search records",
+	it.ActionExists:      "Check which records exist",
+	it.ActionAggregate:   "Aggregate records",
+	it.ActionImport:      "Import records from a file",
+	it.ActionExport:      "Export records to a file",
+	it.ActionHistory:     "List the field changes of a record",
+	it.ActionGetSchema:   "Get the resource schema",
+}
+
+// recordJsonSchema describes a record as the read endpoints return it. What the server fills
+// or derives is read-only.
+func recordJsonSchema(schema *dmodel.ModelSchema) *JsonSchema {
+	result := &JsonSchema{
+		Type:        "object",
+		Description: langJsonText(schema.Label()),
+		Properties:  map[string]*JsonSchema{},
+	}
+	for _, name := range schema.FieldNames() {
+		field, exists := schema.Field(name)
+		if !exists {
+			continue
+		}
+		property := fieldJsonSchema(field)
+		property.ReadOnly = field.IsAutoGenerated() || field.IsComputed() || field.IsVirtual()
+		result.Properties[name] = property
+	}
+	return result
+}
+
+// writeJsonSchema describes the body of a create or an update, keeping only the fields
+// ModelSchema.Validate takes from the client. An update leaves the primary key out, as the path
+// names the record.
+func writeJsonSchema(schema *dmodel.ModelSchema, forEdit bool) *JsonSchema {
+	result := &JsonSchema{Type: "object", Properties: map[string]*JsonSchema{}}
+	for _, name := range schema.FieldNames() {
+		field, exists := schema.Field(name)
+		if !exists || !isClientWritable(schema, field, forEdit) {
+			continue
+		}
+		result.Properties[name] = fieldJsonSchema(field)
+		if isRequiredFrom(field, forEdit) {
+			result.Required = append(result.Required, name)
+		}
+	}
+	return result
+}
+
+func isClientWritable(schema *dmodel.ModelSchema, field *dmodel.ModelField, forEdit bool) bool {
+	if field.IsVirtual() || field.IsEdgeModel() || field.IsComputed() || field.IsServiceInjected() {
+		return false
+	}
+	if !forEdit {
+		return !field.IsAutoGenerated()
+	}
+	if field.IsNoUpdate() || schema.IsPrimaryKey(field.Name()) {
+		return false
+	}
+	return !field.IsAutoGenerated() || schema.IsVersioningKey(field.Name())
+}
+
+// isRequiredFrom reports whether the client must send the field, which ModelField.Validate
+// decides: on create, a required field with no default of any kind.
+func isRequiredFrom(field *dmodel.ModelField, forEdit bool) bool {
+	if forEdit {
+		return field.IsRequiredForUpdate()
+	}
+	hasDefault := (field.Default() != nil && !field.Default().IsEmpty()) ||
+		field.DefaultFn() != nil || field.UsesTypeDefault()
+	return field.IsRequiredForCreate() && !hasDefault
+}
+
+// paramsJsonSchema describes the params of a custom action, leaving out those its path carries.
+func paramsJsonSchema(paramSchema *dmodel.ModelSchema, forEdit bool, pathParams []string) *JsonSchema {
+	result := &JsonSchema{Type: "object", Properties: map[string]*JsonSchema{}}
+	for _, name := range paramSchema.FieldNames() {
+		field, exists := paramSchema.Field(name)
+		if !exists || field.IsVirtual() || slices.Contains(pathParams, name) {
+			continue
+		}
+		result.Properties[name] = fieldJsonSchema(field)
+		if isRequiredFrom(field, forEdit) {
+			result.Required = append(result.Required, name)
+		}
+	}
+	return result
+}
+
+// openApiPath turns an Echo path into an OpenAPI one, ":id" becoming "{id}", and returns the
+// names of its path params.
+func openApiPath(echoPath string) (string, []string) {
+	segments := strings.Split(echoPath, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// pathParameters describes the path params, with the schema of the resource field each names
+// when there is one.
+func pathParameters(schema *dmodel.ModelSchema, names []string) []OpenApiParameter {
+	parameters := make([]OpenApiParameter, 0, len(names))
+	for _, name := range names {
+		paramSchema := &JsonSchema{Type: "string"}
+		if field, exists := schema.Field(name); exists && !field.IsVirtual() {
+			paramSchema = dataTypeJsonSchema(field.DataType())
+		}
+		parameters = append(parameters, OpenApiParameter{Name: name, In: "path", Required: true, Schema: paramSchema})
+	}
+	return parameters
+}
+
+func fieldsParameter() OpenApiParameter {
+	return queryParameter(queryParamFields, &JsonSchema{Type: "string"},
+		"Fields to return, comma-separated or repeated")
+}
+
+// searchParameters are the query params searchParams reads.
+func searchParameters() []OpenApiParameter {
+	return []OpenApiParameter{
+		fieldsParameter(),
+		queryParameter(queryParamPage, &JsonSchema{Type: "integer"}, ""),
+		queryParameter(queryParamSize, &JsonSchema{Type: "integer"}, ""),
+		queryParameter(queryParamCursor, &JsonSchema{Type: "string"}, "Cursor of the next page, from next_cursor"),
+		queryParameter(queryParamText, &JsonSchema{Type: "string"}, "Full-text query"),
+		queryParameter(queryParamLanguage, &JsonSchema{Type: "string"}, "Language of the full-text query"),
+		queryParameter(queryParamName, &JsonSchema{Type: "string"}, "Name of a saved search"),
+		queryParameter(queryParamIncludeArchived, &JsonSchema{Type: "boolean"}, "Include archived records"),
+		queryParameter(queryParamGraph, &JsonSchema{Type: "string"}, "Search graph, JSON-encoded"),
+		queryParameter(queryParamContext, &JsonSchema{Type: "string"}, "Search context values, JSON-encoded"),
+	}
+}
+
+func queryParameter(name string, schema *JsonSchema, description string) OpenApiParameter {
+	return OpenApiParameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func jsonRequestBody(schema *JsonSchema) *OpenApiRequestBody {
+	return &OpenApiRequestBody{
+		Required: true,
+		Content:  map[string]OpenApiMediaType{openApiMimeJson: {Schema: schema}},
+	}
+}
+
+func jsonResponse(description string, schema *JsonSchema) OpenApiResponse {
+	return OpenApiResponse{
+		Description: description,
+		Content:     map[string]OpenApiMediaType{openApiMimeJson: {Schema: schema}},
+	}
+}
+
+// errorResponses are the answers every endpoint may give a request it does not carry out:
+// serveAction answers client errors with 400, or 403 when they are authorization errors, and
+// the authentication middleware answers 401.
+func errorResponses() map[string]OpenApiResponse {
+	return map[string]OpenApiResponse{
+		"400": jsonResponse("The request is malformed or invalid, or the record is not found", schemaRef(openApiClientErrors)),
+		"401": jsonResponse("The access token is missing or invalid", schemaRef(openApiClientErrors)),
+		"403": jsonResponse("The caller is not allowed to perform the action", schemaRef(openApiClientErrors)),
+	}
+}
+
+// sharedComponentSchemas are the envelopes of httpserver every resource shares.
+func sharedComponentSchemas() map[string]*JsonSchema {
+	return map[string]*JsonSchema{
+		openApiClientErrors: {Type: "array", Items: objectSchema(map[string]*JsonSchema{
+			"field":   {Type: "string"},
+			"key":     {Type: "string"},
+			"message": {Type: "string"},
+			"type":    {Type: "string"},
+			"vars":    {Type: "object"},
+		})},
+		openApiCreateResponse: objectSchema(map[string]*JsonSchema{
+			"id":         {Type: "string"},
+			"createdAt":  {Type: "integer", Format: "int64"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"etag":       {Type: "string"},
+		}, "id", "etag"),
+		openApiMutateResponse: objectSchema(map[string]*JsonSchema{
+			"affected_count": {Type: "integer"},
+			"affected_at":    {Type: "string", Format: "date-time"},
+			"etag":           {Type: "string"},
+		}, "affected_count", "affected_at"),
+		openApiSingleMeta: objectSchema(map[string]*JsonSchema{
+			"desired_fields": stringArraySchema(),
+			"masked_fields":  stringArraySchema(),
+			"schema_etag":    {Type: "string"},
+		}),
+	}
+}
+
+func objectSchema(properties map[string]*JsonSchema, required ...string) *JsonSchema {
+	return &JsonSchema{Type: "object", Properties: properties, Required: required}
+}
+
+func stringArraySchema() *JsonSchema {
+	return &JsonSchema{Type: "array", Items: &JsonSchema{Type: "string"}}
+}
+
+func enumStringSchema(values ...string) *JsonSchema {
+	result := &JsonSchema{Type: "string"}
+	for _, value := range values {
+		result.Enum = append(result.Enum, value)
+	}
+	return result
+}
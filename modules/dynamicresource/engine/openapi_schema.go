@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"regexp"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// fieldJsonSchema describes the values a field holds, with the limits its data type enforces.
+// A field the server always fills is never null in a record, so only the other optional fields
+// are described as nullable.
+func fieldJsonSchema(field *dmodel.ModelField) *JsonSchema {
+	dataType := field.DataType()
+	result := dataTypeJsonSchema(dataType)
+	if dataType.IsArray() {
+		result = &JsonSchema{Type: "array", Items: result}
+	}
+	if field.IsNullable() && !field.IsPrimaryKey() && !field.IsAutoGenerated() {
+		result.Type = nullableType(result.Type)
+	}
+
+	result.Description = langJsonText(field.Description())
+	if result.Description == "" {
+		result.Description = langJsonText(field.Label())
+	}
+	if defaultValue := field.Default(); defaultValue != nil && !defaultValue.IsEmpty() {
+		result.Default = *defaultValue.Get()
+	}
+	return result
+}
+
+// dataTypeJsonSchema maps one scalar of a data type. Formats JSON Schema does not define, such
+// as "ulid" or "decimal", are still given: a client generator ignores a format it does not know.
+func dataTypeJsonSchema(dataType dmodel.FieldDataType) *JsonSchema {
+	options := dataType.Options()
+	switch dataType.String() {
+	case dmodel.FieldDataTypeNameBoolean:
+		return &JsonSchema{Type: "boolean"}
+	case dmodel.FieldDataTypeNameInt32:
+		result := &JsonSchema{Type: "integer", Format: "int32"}
+		if bounds, ok := options[dmodel.FieldDataTypeOptRange].([]int32); ok && len(bounds) == 2 {
+			result.Minimum, result.Maximum = int64Ptr(int64(bounds[0])), int64Ptr(int64(bounds[1]))
+		}
+		return result
+	case dmodel.FieldDataTypeNameInt64:
+		result := &JsonSchema{Type: "integer", Format: "int64"}
+		if bounds, ok := options[dmodel.FieldDataTypeOptRange].([]int64); ok && len(bounds) == 2 {
+			result.Minimum, result.Maximum = int64Ptr(bounds[0]), int64Ptr(bounds[1])
+		}
+		return result
+	case dmodel.FieldDataTypeNameDecimal:
+		result := &JsonSchema{Type: "string", Format: "decimal"}
+		if bounds, ok := options[dmodel.FieldDataTypeOptRange].([]string); ok && len(bounds) == 2 {
+			result.DecimalMinimum, result.DecimalMaximum = bounds[0], bounds[1]
+		}
+		if scale, ok := options[dmodel.FieldDataTypeOptScale].(uint); ok {
+			result.DecimalScale = &scale
+		}
+		return result
+	case dmodel.FieldDataTypeNameEnumString:
+		result := &JsonSchema{Type: "string"}
+		if values, ok := options[dmodel.FieldDataTypeOptEnumValues].([]string); ok {
+			for _, value := range values {
+				result.Enum = append(result.Enum, value)
+			}
+		}
+		return result
+	case dmodel.FieldDataTypeNameEnumInt32:
+		result := &JsonSchema{Type: "integer", Format: "int32"}
+		if values, ok := options[dmodel.FieldDataTypeOptEnumValues].([]int32); ok {
+			for _, value := range values {
+				result.Enum = append(result.Enum, value)
+			}
+		}
+		return result
+	case dmodel.FieldDataTypeNameEmail:
+		return &JsonSchema{Type: "string", Format: "email"}
+	case dmodel.FieldDataTypeNamePhone:
+		return &JsonSchema{Type: "string", Format: "phone"}
+	case dmodel.FieldDataTypeNameUrl:
+		return withStringLimits(&JsonSchema{Type: "string", Format: "uri"}, options)
+	case dmodel.FieldDataTypeNameUlid:
+		return &JsonSchema{Type: "string", Format: "ulid"}
+	case dmodel.FieldDataTypeNameUuid:
+		return &JsonSchema{Type: "string", Format: "uuid"}
+	case dmodel.FieldDataTypeNameModelDate:
+		return &JsonSchema{Type: "string", Format: "date"}
+	case dmodel.FieldDataTypeNameModelDateTime:
+		return &JsonSchema{Type: "string", Format: "date-time"}
+	case dmodel.FieldDataTypeNameModelTime:
+		return &JsonSchema{Type: "string", Format: "time"}
+	case dmodel.FieldDataTypeNameLangCode:
+		return &JsonSchema{Type: "string", Format: "language-code"}
+	case dmodel.FieldDataTypeNameSecret:
+		return withStringLimits(&JsonSchema{Type: "string", Format: "password", WriteOnly: true}, options)
+	case dmodel.FieldDataTypeNameString, dmodel.FieldDataTypeNameSlug, dmodel.FieldDataTypeNameEtag:
+		return withStringLimits(&JsonSchema{Type: "string"}, options)
+	case dmodel.FieldDataTypeNameLangJson:
+		return langJsonSchema(options)
+	case dmodel.FieldDataTypeNameJsonMap:
+		return &JsonSchema{Type: []string{"object", "array"}}
+	case dmodel.FieldDataTypeNameModel:
+		return &JsonSchema{Type: "object"}
+	}
+	return &JsonSchema{}
+}
+
+// langJsonSchema describes a translated text: an object keyed by language code, each value
+// bounded as the data type bounds one translation. The languages listed are those the server
+// keeps; the object is left open for the translation key reference.
+func langJsonSchema(options dmodel.FieldDataTypeOptions) *JsonSchema {
+	translation := withStringLimits(&JsonSchema{Type: "string"}, options)
+	result := &JsonSchema{Type: "object", AdditionalProperties: translation}
+	if languages, ok := options[dmodel.FieldDataTypeOptLangJsonWhitelist].([]model.LanguageCode); ok {
+		result.Properties = make(map[string]*JsonSchema, len(languages))
+		for _, language := range languages {
+			result.Properties[string(language)] = translation
+		}
+	}
+	return result
+}
+
+func withStringLimits(result *JsonSchema, options dmodel.FieldDataTypeOptions) *JsonSchema {
+	if length, ok := options[dmodel.FieldDataTypeOptLength].([]int); ok && len(length) == 2 {
+		minLength, maxLength := length[0], length[1]
+		if minLength > 0 {
+			result.MinLength = &minLength
+		}
+		if maxLength > 0 {
+			result.MaxLength = &maxLength
+		}
+	}
+	if pattern, ok := options[dmodel.FieldDataTypeOptPattern].(*regexp.Regexp); ok && pattern != nil {
+		result.Pattern = pattern.String()
+	}
+	return result
+}
+
+// nullableType adds "null" to the JSON types a value may have.
+func nullableType(jsonType any) any {
+	switch typed := jsonType.(type) {
+	case string:
+		return []string{typed, "null"}
+	case []string:
+		return append(append([]string{}, typed...), "null")
+	}
+	return jsonType
+}
+
+// langJsonText picks the default-language text of a label or description.
+func langJsonText(text model.LangJson) string {
+	if text == nil {
+		return ""
+	}
+	return text[model.DefaultLanguageCode]
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
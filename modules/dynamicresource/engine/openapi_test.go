@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// newOpenApiEngine builds an engine over a schema with a field of each kind the document tells
+// apart, with the built-in actions defined.
+func newOpenApiEngine(t *testing.T, name string) *DynamicResourceEngineImpl {
+	t.Helper()
+
+	schema := dmodel.DefineModel(name).
+		Field(dmodel.DefineField().Name("id").DataType(dmodel.FieldDataTypeUlid()).PrimaryKey()).
+		Field(dmodel.DefineField().Name("etag").DataType(dmodel.FieldDataTypeEtag()).VersioningKey().AutoGenerated()).
+		Field(dmodel.DefineField().Name("code").DataType(dmodel.FieldDataTypeString(2, 20)).RequiredForCreate().NoUpdate()).
+		Field(dmodel.DefineField().Name("status").DataType(dmodel.FieldDataTypeEnumString([]string{"draft", "done"})).
+			RequiredForCreate().Default("draft")).
+		Field(dmodel.DefineField().Name("quantity").DataType(dmodel.FieldDataTypeInt32(0, 100))).
+		Field(dmodel.DefineField().Name("price").DataType(dmodel.FieldDataTypeDecimal("0", "1000", 2))).
+		Field(dmodel.DefineField().Name("created_at").DataType(dmodel.FieldDataTypeDateTime()).AutoGenerated()).
+		Build()
+	engine := NewDynamicResourceEngine(NewEngineParam{Schema: schema}).(*DynamicResourceEngineImpl)
+	require.NoError(t, DefineBuiltinActions(engine))
+	return engine
+}
+
+func TestRegisterRoutesRecordsFullPaths(t *testing.T) {
+	engine := newOpenApiEngine(t, "test_openapi")
+	assert.Empty(t, engine.RestApi().Routes(), "nothing is recorded before the resource is mounted")
+
+	engine.RestApi().RegisterRoutes(echo.New().Group("/api").Group("/v1/test"))
+
+	routes := engine.RestApi().Routes()
+	assert.Contains(t, routes, it.RestRoute{
+		ActionName: it.ActionGetById, Method: http.MethodGet, Path: "/api/v1/test/test_openapi/:id",
+	})
+	assert.Len(t, routes, 12)
+}
+
+func TestBuildOpenApiDocumentDescribesBuiltins(t *testing.T) {
+	engine := newOpenApiEngine(t, "test_openapi")
+	engine.RestApi().RegisterRoutes(echo.New().Group("/v1"))
+	unmounted := newOpenApiEngine(t, "test_unmounted")
+
+	document := BuildOpenApiDocument(OpenApiInfo{Title: "Test", Version: "v1"},
+		[]it.DynamicResourceEngine{engine, unmounted})
+
+	assert.Equal(t, OpenApiVersion, document.OpenApi)
+	assert.Len(t, document.Tags, 1, "an engine nobody mounted answers no request")
+	assert.NotContains(t, document.Components.Schemas, "test_unmounted")
+
+	byId := document.Paths["/v1/test_openapi/{id}"]
+	require.NotNil(t, byId)
+	assert.Equal(t, "test_openapi_get_by_id", byId["get"].OperationId)
+	assert.Equal(t, "test_openapi_update", byId["patch"].OperationId)
+	assert.Equal(t, "test_openapi_delete", byId["delete"].OperationId)
+	assert.Equal(t, OpenApiParameter{
+		Name: "id", In: "path", Required: true, Schema: &JsonSchema{Type: "string", Format: "ulid"},
+	}, byId["get"].Parameters[0])
+	assert.Equal(t, "#/components/schemas/test_openapi_get_response",
+		byId["get"].Responses["200"].Content[openApiMimeJson].Schema.Ref)
+
+	base := document.Paths["/v1/test_openapi"]
+	require.NotNil(t, base)
+	assert.Equal(t, "#/components/schemas/test_openapi_create",
+		base["post"].RequestBody.Content[openApiMimeJson].Schema.Ref)
+	assert.Contains(t, base["post"].Responses, "201")
+	assert.Equal(t, "#/components/schemas/test_openapi_search_response",
+		base["get"].Responses["200"].Content[openApiMimeJson].Schema.Ref)
+	assert.Contains(t, base["get"].Responses, "403")
+
+	assert.Contains(t, document.Paths["/v1/test_openapi/import"]["post"].RequestBody.Content, openApiMimeMultipart)
+	assert.Contains(t, document.Paths["/v1/test_openapi/export"]["get"].Responses["200"].Content, openApiMimeXlsx)
+}
+
+// The create body keeps what the client writes and requires what has no default; the update body
+// drops the key the path carries and what may not change after creation.
+func TestBuildOpenApiDocumentDescribesWriteBodies(t *testing.T) {
+	engine := newOpenApiEngine(t, "test_openapi")
+	engine.RestApi().RegisterRoutes(echo.New().Group(""))
+	schemas := BuildOpenApiDocument(OpenApiInfo{}, []it.DynamicResourceEngine{engine}).Components.Schemas
+
+	create := schemas["test_openapi_create"]
+	assert.ElementsMatch(t, []string{"code", "status", "quantity", "price"}, keysOf(create.Properties))
+	assert.Equal(t, []string{"code"}, create.Required, "status has a default")
+
+	update := schemas["test_openapi_update"]
+	assert.ElementsMatch(t, []string{"etag", "status", "quantity", "price"}, keysOf(update.Properties))
+
+	record := schemas["test_openapi"]
+	assert.True(t, record.Properties["created_at"].ReadOnly)
+	assert.Equal(t, "date-time", record.Properties["created_at"].Format)
+	assert.Equal(t, []any{"draft", "done"}, record.Properties["status"].Enum)
+	assert.Equal(t, "draft", record.Properties["status"].Default)
+}
+
+func TestBuildOpenApiDocumentDescribesCustomActions(t *testing.T) {
+	engine := newOpenApiEngine(t, "test_openapi")
+	paramSchema := dmodel.DefineModel("test_openapi_params").
+		Field(dmodel.DefineField().Name("id").DataType(dmodel.FieldDataTypeUlid()).RequiredForCreate()).
+		Field(dmodel.DefineField().Name("note").DataType(dmodel.FieldDataTypeString(0, 200)).RequiredForCreate()).
+		Build()
+	for _, definition := range []it.DynamicActionDefinition{
+		{ActionName: "approve", ActionType: it.ActionTypeGeneric, RestPath: ":id/approve"},
+		{ActionName: "preview", ActionType: it.ActionTypeRead, RestPath: ":id/preview"},
+	} {
+		definition.ParamSchema = func() *dmodel.ModelSchema { return paramSchema }
+		definition.MainProcess = noopProcess
+		require.NoError(t, engine.DefineAction(definition))
+	}
+	engine.RestApi().RegisterRoutes(echo.New().Group(""))
+
+	document := BuildOpenApiDocument(OpenApiInfo{}, []it.DynamicResourceEngine{engine})
+
+	approve := document.Paths["/test_openapi/{id}/approve"]["post"]
+	require.NotNil(t, approve)
+	assert.Equal(t, "#/components/schemas/test_openapi_approve_params",
+		approve.RequestBody.Content[openApiMimeJson].Schema.Ref)
+	body := document.Components.Schemas["test_openapi_approve_params"]
+	assert.Equal(t, []string{"note"}, keysOf(body.Properties), "the path carries the id")
+	assert.Equal(t, []string{"note"}, body.Required)
+
+	preview := document.Paths["/test_openapi/{id}/preview"]["get"]
+	require.NotNil(t, preview)
+	assert.Nil(t, preview.RequestBody, "a GET reads its params from the query string")
+	require.Len(t, preview.Parameters, 2)
+	assert.Equal(t, "query", preview.Parameters[1].In)
+	assert.Equal(t, "note", preview.Parameters[1].Name)
+	assert.True(t, preview.Parameters[1].Required)
+}
+
+func TestDataTypeJsonSchemaCarriesLimits(t *testing.T) {
+	quantity := dataTypeJsonSchema(dmodel.FieldDataTypeInt32(1, 9))
+	assert.Equal(t, "integer", quantity.Type)
+	assert.Equal(t, int64(1), *quantity.Minimum)
+	assert.Equal(t, int64(9), *quantity.Maximum)
+
+	price := dataTypeJsonSchema(dmodel.FieldDataTypeDecimal("-5", "5", 3))
+	assert.Equal(t, "string", price.Type)
+	assert.Equal(t, uint(3), *price.DecimalScale)
+	assert.Equal(t, "-5", price.DecimalMinimum)
+
+	name := dataTypeJsonSchema(dmodel.FieldDataTypeString(0, 50))
+	assert.Nil(t, name.MinLength, "a zero minimum is no limit")
+	assert.Equal(t, 50, *name.MaxLength)
+
+	tags := fieldJsonSchema(dmodel.DefineField().Name("tags").DataType(dmodel.FieldDataTypeString(1, 10).ArrayType()).Build())
+	assert.Equal(t, []string{"array", "null"}, tags.Type)
+	assert.Equal(t, "string", tags.Items.Type)
+
+	encoded, err := json.Marshal(dataTypeJsonSchema(dmodel.FieldDataTypeEnumInt32([]int32{1, 2})))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"integer","format":"int32","enum":[1,2]}`, string(encoded))
+}
+
+func TestOpenApiPath(t *testing.T) {
+	path, params := openApiPath("/v1/thing/:id/lines/:line_id")
+	assert.Equal(t, "/v1/thing/{id}/lines/{line_id}", path)
+	assert.Equal(t, []string{"id", "line_id"}, params)
+
+	path, params = openApiPath("/v1/thing")
+	assert.Equal(t, "/v1/thing", path)
+	assert.Empty(t, params)
+}
+
+func keysOf(properties map[string]*JsonSchema) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	return keys
+}
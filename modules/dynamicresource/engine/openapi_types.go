@@ -0,0 +1,106 @@
+package engine
+
+// The types below are the subset of OpenAPI 3.1 the generated document uses. They marshal to
+// the JSON the specification describes; maps are emitted with sorted keys, so two runs over the
+// same engines produce byte-identical documents.
+
+// OpenApiVersion is the version of the OpenAPI specification the document follows.
+const OpenApiVersion = "3.1.0"
+
+type OpenApiDocument struct {
+	OpenApi    string                     `json:"openapi"`
+	Info       OpenApiInfo                `json:"info"`
+	Tags       []OpenApiTag               `json:"tags,omitempty"`
+	Paths      map[string]OpenApiPathItem `json:"paths"`
+	Components OpenApiComponents          `json:"components"`
+	Security   []map[string][]string      `json:"security,omitempty"`
+}
+
+type OpenApiInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenApiTag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenApiPathItem holds the operations of one path, keyed by lower-case HTTP method.
+type OpenApiPathItem map[string]*OpenApiOperation
+
+type OpenApiOperation struct {
+	OperationId string                     `json:"operationId"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Parameters  []OpenApiParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenApiRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenApiResponse `json:"responses"`
+}
+
+type OpenApiParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *JsonSchema `json:"schema"`
+}
+
+type OpenApiRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenApiMediaType `json:"content,omitempty"`
+}
+
+type OpenApiMediaType struct {
+	Schema *JsonSchema `json:"schema"`
+}
+
+type OpenApiComponents struct {
+	Schemas         map[string]*JsonSchema           `json:"schemas"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenApiSecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// JsonSchema is a JSON Schema 2020-12 object, the dialect OpenAPI 3.1 embeds.
+//
+// Type is a string, or a []string when the value may also be null.
+type JsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 any                    `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Minimum              *int64                 `json:"minimum,omitempty"`
+	Maximum              *int64                 `json:"maximum,omitempty"`
+	Items                *JsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*JsonSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+
+	// A decimal travels as a string, which JSON Schema cannot bound numerically, so its limits
+	// are carried as extensions instead.
+	DecimalScale   *uint  `json:"x-scale,omitempty"`
+	DecimalMinimum string `json:"x-minimum,omitempty"`
+	DecimalMaximum string `json:"x-maximum,omitempty"`
+}
+
+func schemaRef(componentName string) *JsonSchema {
+	return &JsonSchema{Ref: "#/components/schemas/" + componentName}
+}
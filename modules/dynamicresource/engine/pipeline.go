@@ -100,32 +100,57 @@ func (this *DynamicResourceEngineImpl) ExecuteAction(
 		return &it.ActionResult{ClientErrors: clientErrs}, nil
 	}
 
+	var eventOrgId string
+	listened := this.listensTo(actionName)
+	if listened {
+		eventOrgId = this.eventOrgIdBefore(ctx, params, foundModel)
+	}
+
+	// A change this action makes to a resource tracking history is recorded under its name. An
+	// action run from within another one is recorded under the outer name.
+	if dyn.ChangeActionOf(ctx) == "" {
+		ctx = dyn.WithChangeAction(ctx, actionName)
+	}
 	result, err = definition.MainProcess(ctx, it.ProcessInput{
 		Params:             params,
 		FoundModel:         foundModel,
 		ResourceService:    this.ResourceService(),
 		ResourceRepository: this.ResourceRepository(),
 	})
-	return result, errors.Wrap(err, "ExecuteAction.MainProcess")
+	if err != nil {
+		return result, errors.Wrap(err, "ExecuteAction.MainProcess")
+	}
+	if listened && result != nil && result.ClientErrors.Count() == 0 {
+		this.emitActionEvent(ctx, actionName, params, eventOrgId, result)
+	}
+	return result, nil
 }
 
 // assertPermission checks the action's permission against the caller's entitlements.
 // An action with an empty Permission is open to anyone the middleware already authenticated.
 func (this *DynamicResourceEngineImpl) assertPermission(
 	ctx corectx.Context, definition it.DynamicActionDefinition,
+) *ft.ClientErrors {
+	return assertActionPermission(ctx, this, definition)
+}
+
+// assertActionPermission is assertPermission for any engine, for an action that has to check
+// another action's permission before it runs.
+func assertActionPermission(
+	ctx corectx.Context, engine it.DynamicResourceEngine, definition it.DynamicActionDefinition,
 ) *ft.ClientErrors {
 	if definition.Permission == "" {
 		return nil
 	}
 
-	scope := this.DefaultPermissionScope()
+	scope := engine.DefaultPermissionScope()
 	if definition.PermissionScope != nil {
 		scope = *definition.PermissionScope
 	}
 
 	return requestguard.AssertPermission(ctx, requestguard.Perm{
 		ActionCode:   definition.Permission,
-		ResourceCode: this.ResourceName(),
+		ResourceCode: engine.ResourceName(),
 		Scope:        scope,
 	})
 }
@@ -77,6 +77,35 @@ func TestExecuteActionRunsMainProcess(t *testing.T) {
 	assert.Equal(t, "abc", seen["name"])
 }
 
+// The change history names the action a change was made by, so the pipeline hands the name down,
+// keeping the outer one when an action runs another.
+func TestExecuteActionNamesTheChangeAction(t *testing.T) {
+	engine := newPipelineEngine(&stubRepository{})
+	var seen []string
+
+	assert.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName: "post_ledger",
+		MainProcess: func(ctx corectx.Context, _ it.ProcessInput) (*it.ActionResult, error) {
+			seen = append(seen, dyn.ChangeActionOf(ctx))
+			return &it.ActionResult{HasData: true}, nil
+		},
+	}))
+	assert.NoError(t, engine.DefineAction(it.DynamicActionDefinition{
+		ActionName: "approve",
+		MainProcess: func(ctx corectx.Context, _ it.ProcessInput) (*it.ActionResult, error) {
+			seen = append(seen, dyn.ChangeActionOf(ctx))
+			return engine.ExecuteAction(ctx, "post_ledger", nil)
+		},
+	}))
+
+	ctx := ownerContext()
+	_, err := engine.ExecuteAction(ctx, "approve", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"approve", "approve"}, seen)
+	assert.Empty(t, dyn.ChangeActionOf(ctx), "the caller's context is left untouched")
+}
+
 // An action declaring KeysToFetch has its record read by the pipeline. Handing that record to
 // MainProcess is what saves it re-reading a row the pipeline already read.
 func TestExecuteActionPassesFoundModelToMainProcess(t *testing.T) {
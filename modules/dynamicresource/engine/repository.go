@@ -107,3 +107,15 @@ func (this *DynamicResourceRepositoryImpl) Exists(
 ) (*dyn.OpResult[dyn.RepoExistsResult], error) {
 	return baserepo.Exists(ctx, this.dynamicRepo, keys)
 }
+
+func (this *DynamicResourceRepositoryImpl) Aggregate(
+	ctx corectx.Context, param dyn.RepoAggregateParam,
+) (*dyn.OpResult[dyn.AggregateResultData], error) {
+	return this.dynamicRepo.Aggregate(ctx, param)
+}
+
+func (this *DynamicResourceRepositoryImpl) ChangeHistory(
+	ctx corectx.Context, param dyn.RepoChangeHistoryParam,
+) (*dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]], error) {
+	return this.dynamicRepo.ChangeHistory(ctx, param)
+}
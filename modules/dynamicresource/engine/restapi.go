@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v5"
@@ -23,6 +25,7 @@ func NewDynamicRestApi(engine it.DynamicResourceEngine) it.DynamicRestApi {
 // check always runs and a ModifyAction override takes effect on the REST surface too.
 type DynamicRestApiImpl struct {
 	engine it.DynamicResourceEngine
+	routes []it.RestRoute
 }
 
 // RegisterRoutes adds every REST-exposed action of the resource to the given group.
@@ -40,15 +43,26 @@ func (this *DynamicRestApiImpl) RegisterRoutes(route *echo.Group, middlewares ..
 		if handler == nil {
 			handler = this.actionHandler(definition.ActionName)
 		}
-		route.Add(
+		mounted := route.Add(
 			definition.ActionType.HttpMethod(),
 			joinRestPath(base, definition.RestPath),
 			handler,
 			middlewares...,
 		)
+		this.routes = append(this.routes, it.RestRoute{
+			ActionName: definition.ActionName,
+			Method:     mounted.Method,
+			Path:       mounted.Path,
+		})
 	}
 }
 
+// Routes returns the endpoints registered so far. Registration happens once at startup, before
+// the server accepts requests, so no lock guards the list.
+func (this *DynamicRestApiImpl) Routes() []it.RestRoute {
+	return append([]it.RestRoute{}, this.routes...)
+}
+
 // routableActions returns the actions that declare a REST surface, ordered by decreasing
 // specificity: paths with fewer path params first, then longer paths, then alphabetically.
 // That places "meta/schema" and "exists" ahead of ":id", and ":id/archived" ahead of ":id".
@@ -115,8 +129,14 @@ func (this *DynamicRestApiImpl) bindingFor(actionName string) restBinding {
 		return restBinding{this.getByIdParams, getOneResponse, httpserver.JsonOk}
 	case it.ActionSearch:
 		return restBinding{this.searchParams, searchResponse, httpserver.JsonOk}
-	case it.ActionExists:
+	case it.ActionExists, it.ActionAggregate:
 		return restBinding{rawBodyParams, identityResponse, httpserver.JsonOk}
+	case it.ActionImport:
+		return restBinding{importParams, identityResponse, httpserver.JsonOk}
+	case it.ActionExport:
+		return restBinding{this.exportParams, identityResponse, httpserver.JsonOk}
+	case it.ActionHistory:
+		return restBinding{this.historyParams, identityResponse, httpserver.JsonOk}
 	case it.ActionGetSchema:
 		return restBinding{noParams, identityResponse, httpserver.JsonOk}
 	}
@@ -194,14 +214,55 @@ func (this *DynamicRestApiImpl) serveAction(
 	}
 	// Search reports HasData=false for an empty page, which is a successful result rather
 	// than a missing record: a filter matching nothing, or a page past the end, still
-	// answers 200 with an empty item list.
-	if !result.HasData && actionName != it.ActionSearch {
+	// answers 200 with an empty item list. An aggregate matching nothing is the same.
+	if !result.HasData && actionName != it.ActionSearch && actionName != it.ActionAggregate {
 		return httpserver.JsonBadRequest(echoCtx, ft.ClientErrors{*ft.NewAnonymousNotFoundError()})
 	}
 
+	// A file is served as itself whatever the binding: wrapping a PDF in JSON would force every
+	// client to decode it before it could be saved or shown.
+	if file, isFile := result.Data.(it.FileResultData); isFile {
+		return fileResponse(echoCtx, file)
+	}
+	if stream, isStream := result.Data.(it.StreamResultData); isStream {
+		return streamResponse(echoCtx, stream)
+	}
+
 	return jsonSuccessFn(echoCtx, buildResponse(result.Data))
 }
 
+// fileResponse writes a file result with the headers a browser needs to save or display it.
+//
+// The file name is quoted with strconv rather than concatenated, because a name taken from a
+// document number may carry a quote or a backslash that would otherwise end the header value.
+func fileResponse(echoCtx *echo.Context, file it.FileResultData) error {
+	disposition := "attachment"
+	if file.Inline {
+		disposition = "inline"
+	}
+	if file.FileName != "" {
+		disposition += "; filename=" + strconv.Quote(file.FileName)
+	}
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	echoCtx.Response().Header().Set(echo.HeaderContentDisposition, disposition)
+	return echoCtx.Blob(http.StatusOK, contentType, file.Content)
+}
+
+// streamResponse sends the headers of a file, then has the action write the file into the body.
+// An error past that point cannot change the status anymore; it is returned for the server to
+// log, and the client is left with a truncated file.
+func streamResponse(echoCtx *echo.Context, stream it.StreamResultData) error {
+	header := echoCtx.Response().Header()
+	header.Set(echo.HeaderContentDisposition, "attachment; filename="+strconv.Quote(stream.FileName))
+	header.Set(echo.HeaderContentType, stream.ContentType)
+	echoCtx.Response().WriteHeader(http.StatusOK)
+	return stream.Write(echoCtx.Response())
+}
+
 // The response builders below type-assert the action result to the shape its built-in
 // action documents. A custom action that returns something else should install its own
 // REST surface rather than reusing these endpoints.
@@ -247,6 +308,7 @@ func searchResponse(data any) any {
 		Total:         paged.Total,
 		Page:          paged.Page,
 		Size:          paged.Size,
+		NextCursor:    paged.NextCursor,
 		DesiredFields: paged.DesiredFields,
 		MaskedFields:  paged.MaskedFields,
 		SchemaEtag:    paged.SchemaEtag,
@@ -2,6 +2,9 @@ package engine
 
 import (
 	"encoding/json"
+	stdErr "errors"
+	"fmt"
+	"io"
 	"maps"
 	"net/http"
 	"sort"
@@ -14,6 +17,7 @@ import (
 	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
 	"github.com/sky-as-code/nikki-erp/modules/core/httpserver"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 )
 
 // Query parameter names accepted by the read endpoints.
@@ -22,6 +26,8 @@ const (
 	queryParamFields   = "fields"
 	queryParamPage     = "page"
 	queryParamSize     = "size"
+	queryParamCursor   = basemodel.FieldCursor
+	queryParamText     = basemodel.FieldTextQuery
 	queryParamGraph    = "graph"
 	queryParamContext  = basemodel.FieldContext
 	queryParamLanguage = "language"
@@ -194,7 +200,8 @@ func (this *DynamicRestApiImpl) getByIdParams(echoCtx *echo.Context) (dmodel.Dyn
 	return params, nil
 }
 
-// searchParams reads paging, field selection and the search graph from the query string.
+// searchParams reads paging, field selection, the full-text query and the search graph from the
+// query string.
 func (this *DynamicRestApiImpl) searchParams(echoCtx *echo.Context) (dmodel.DynamicFields, error) {
 	params := dmodel.DynamicFields{}
 
@@ -207,6 +214,12 @@ func (this *DynamicRestApiImpl) searchParams(echoCtx *echo.Context) (dmodel.Dyna
 	if size, ok := readIntQuery(echoCtx, queryParamSize); ok {
 		params[queryParamSize] = size
 	}
+	if cursor := echoCtx.QueryParam(queryParamCursor); cursor != "" {
+		params[queryParamCursor] = cursor
+	}
+	if text := echoCtx.QueryParam(queryParamText); text != "" {
+		params[queryParamText] = text
+	}
 	if language := echoCtx.QueryParam(queryParamLanguage); language != "" {
 		params[queryParamLanguage] = language
 	}
@@ -265,6 +278,98 @@ func (this *DynamicRestApiImpl) updateParams(echoCtx *echo.Context) (dmodel.Dyna
 	return params, nil
 }
 
+// exportParams reads the search the export runs from the query string, as searchParams does,
+// plus the file format and the background flag.
+func (this *DynamicRestApiImpl) exportParams(echoCtx *echo.Context) (dmodel.DynamicFields, error) {
+	params, err := this.searchParams(echoCtx)
+	if err != nil {
+		return nil, err
+	}
+	if format := echoCtx.QueryParam(it.ExportParamFormat); format != "" {
+		params[it.ExportParamFormat] = format
+	}
+	if raw := echoCtx.QueryParam(it.ExportParamAsync); raw != "" {
+		async, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "malformed 'async' query parameter")
+		}
+		params[it.ExportParamAsync] = async
+	}
+	return params, nil
+}
+
+// historyParams reads the record id from the path and the paging from the query string.
+func (this *DynamicRestApiImpl) historyParams(echoCtx *echo.Context) (dmodel.DynamicFields, error) {
+	params := dmodel.DynamicFields{
+		basemodel.FieldId: echoCtx.Param("id"),
+	}
+	if page, ok := readIntQuery(echoCtx, queryParamPage); ok {
+		params[queryParamPage] = page
+	}
+	if size, ok := readIntQuery(echoCtx, queryParamSize); ok {
+		params[queryParamSize] = size
+	}
+	return params, nil
+}
+
+const (
+	// importFileField is the multipart field an import file is uploaded in.
+	importFileField      = "file"
+	importFormSlackBytes = 1 << 20
+)
+
+// importParams reads the uploaded file and the import options from a multipart form. The options
+// are form values, as a browser sends them alongside the file.
+func importParams(echoCtx *echo.Context) (dmodel.DynamicFields, error) {
+	// The form around the file adds a little to the body, which the slack leaves room for.
+	request := echoCtx.Request()
+	request.Body = http.MaxBytesReader(nil, request.Body, maxImportFileBytes+importFormSlackBytes)
+	fileHeader, err := echoCtx.FormFile(importFileField)
+	if maxErr := (*http.MaxBytesError)(nil); stdErr.As(err, &maxErr) {
+		return nil, importTooLargeError()
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "missing 'file' upload")
+	}
+	if fileHeader.Size > maxImportFileBytes {
+		return nil, importTooLargeError()
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	params := dmodel.DynamicFields{
+		it.ImportParamContent:  content,
+		it.ImportParamFileName: fileHeader.Filename,
+	}
+	for _, name := range []string{it.ImportParamFormat, it.ImportParamBatchSize, it.ImportParamUpsertBy} {
+		if value := echoCtx.FormValue(name); value != "" {
+			params[name] = value
+		}
+	}
+	// Parsed here rather than forwarded, because readBool would take a malformed value as false,
+	// and a dry run asked for with a typo must not turn into a real import.
+	if raw := echoCtx.FormValue(it.ImportParamDryRun); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "malformed 'dry_run' form value")
+		}
+		params[it.ImportParamDryRun] = dryRun
+	}
+	return params, nil
+}
+
+func importTooLargeError() error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("import file is larger than %d bytes", maxImportFileBytes))
+}
+
 // readCsvQuery reads a query parameter that may be repeated or comma-separated.
 func readCsvQuery(echoCtx *echo.Context, name string) []string {
 	values := echoCtx.QueryParams()[name]
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"bytes"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,6 +10,7 @@ import (
 
 	"github.com/labstack/echo/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 )
@@ -38,10 +41,14 @@ func TestRegisterRoutesCoversBuiltins(t *testing.T) {
 	// methods on the same path cannot shadow each other, so that tie is cosmetic.
 	assert.Equal(t, []string{
 		"GET /test_resource/meta/schema",
+		"POST /test_resource/aggregate",
 		"POST /test_resource/exists",
+		"GET /test_resource/export",
+		"POST /test_resource/import",
 		"POST /test_resource",
 		"GET /test_resource",
 		"POST /test_resource/:id/archived",
+		"GET /test_resource/:id/history",
 		"DELETE /test_resource/:id",
 		"GET /test_resource/:id",
 		"PATCH /test_resource/:id",
@@ -56,7 +63,7 @@ func TestRegisterRoutesSkipsUnexposedActions(t *testing.T) {
 	for _, route := range registeredRoutes(t, engine) {
 		assert.NotContains(t, route, "get_by_unique")
 	}
-	assert.Len(t, registeredRoutes(t, engine), 8, "9 built-ins, 1 unexposed")
+	assert.Len(t, registeredRoutes(t, engine), 12, "13 built-ins, 1 unexposed")
 }
 
 // A module-defined action gets a route from its RestPath, which is the whole point of the
@@ -165,6 +172,64 @@ func TestEchoBindParamsToleratesEmptyBody(t *testing.T) {
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
 
+// An upload is read into memory whole, so one past the limit is refused before it is.
+func TestImportParamsRefusesAnOversizedFile(t *testing.T) {
+	echoApp := echo.New()
+	echoApp.POST("/import", func(echoCtx *echo.Context) error {
+		_, err := importParams(echoCtx)
+		return err
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(importFileField, "stock.csv")
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("a"), maxImportFileBytes+importFormSlackBytes))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/import", body)
+	request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+
+	recorder := httptest.NewRecorder()
+	echoApp.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
+
+// A file result is answered with its own bytes and headers, not wrapped in JSON.
+func TestFileResponseServesTheBytes(t *testing.T) {
+	echoApp := echo.New()
+	echoApp.GET("/thing", func(echoCtx *echo.Context) error {
+		return fileResponse(echoCtx, it.FileResultData{
+			FileName:    `INV-2026-"1".pdf`,
+			ContentType: "application/pdf",
+			Content:     []byte("%PDF-1.3"),
+		})
+	})
+
+	recorder := httptest.NewRecorder()
+	echoApp.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/thing", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/pdf", recorder.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="INV-2026-\"1\".pdf"`,
+		recorder.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "%PDF-1.3", recorder.Body.String())
+}
+
+func TestFileResponseCanBeInline(t *testing.T) {
+	echoApp := echo.New()
+	echoApp.GET("/thing", func(echoCtx *echo.Context) error {
+		return fileResponse(echoCtx, it.FileResultData{Content: []byte("x"), Inline: true})
+	})
+
+	recorder := httptest.NewRecorder()
+	echoApp.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/thing", nil))
+
+	assert.Equal(t, "inline", recorder.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, echo.MIMEOctetStream, recorder.Header().Get(echo.HeaderContentType))
+}
+
 func indexOf(values []string, target string) int {
 	for index, value := range values {
 		if value == target {
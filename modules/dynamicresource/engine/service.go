@@ -4,8 +4,11 @@ import (
 	"go.bryk.io/pkg/errors"
 
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
 	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
 	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 )
@@ -22,6 +25,10 @@ type NewServiceParam struct {
 	// DefaultFields is returned by a search that specifies neither fields nor a resolvable
 	// view. When empty, every column of the schema is returned.
 	DefaultFields []string
+
+	// SourceSearch reads another resource's rows, which an import needs to resolve a relation
+	// looked up by the peer's unique key. It is optional: without it, such a lookup fails.
+	SourceSearch SourceSearchFn
 }
 
 func NewDynamicResourceService(param NewServiceParam) it.DynamicResourceService {
@@ -34,6 +41,7 @@ func NewDynamicResourceService(param NewServiceParam) it.DynamicResourceService
 		repository:    param.Repository,
 		fieldResolver: param.FieldResolver,
 		defaultFields: defaultFields,
+		sourceSearch:  param.SourceSearch,
 	}
 }
 
@@ -48,6 +56,7 @@ type DynamicResourceServiceImpl struct {
 	repository    it.DynamicResourceRepository
 	fieldResolver corecrud.FieldsResolver
 	defaultFields []string
+	sourceSearch  SourceSearchFn
 }
 
 func (this *DynamicResourceServiceImpl) Schema() *dmodel.ModelSchema {
@@ -209,6 +218,74 @@ func (this *DynamicResourceServiceImpl) Exists(
 	return result, errors.Wrap(err, "DynamicResourceService.Exists")
 }
 
+func (this *DynamicResourceServiceImpl) Aggregate(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.AggregateResultData], error) {
+	query, err := paramsToAggregateQuery(params)
+	if err != nil {
+		if cErrs, ok := clientErrorsForDecodeFailure(err); ok {
+			return &dyn.OpResult[dyn.AggregateResultData]{ClientErrors: cErrs}, nil
+		}
+		return nil, errors.Wrap(err, "DynamicResourceService.Aggregate")
+	}
+	result, err := corecrud.Aggregate(ctx, corecrud.AggregateParam{
+		Action:       this.actionName("aggregate"),
+		DbRepoGetter: this.repository,
+		Query:        query,
+	})
+	return result, errors.Wrap(err, "DynamicResourceService.Aggregate")
+}
+
+// History reads the change history of one record. The record must exist for the caller first, so
+// that the history of a record of another tenant stays out of reach like the record itself.
+func (this *DynamicResourceServiceImpl) History(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]], error) {
+	cErrs := ft.ClientErrors{}
+	id := readId(params, basemodel.FieldId)
+	if id == "" {
+		cErrs.Append(*dmodel.NewMissingFieldErr(basemodel.FieldId))
+	}
+	page, pageOk := readIntParam(params[queryParamPage])
+	if !pageOk || page < 0 {
+		cErrs.Append(*dmodel.NewInvalidDataTypeErr(queryParamPage, "integer"))
+	}
+	size, sizeOk := readIntParam(params[queryParamSize])
+	if !sizeOk || size < 0 {
+		cErrs.Append(*dmodel.NewInvalidDataTypeErr(queryParamSize, "integer"))
+	}
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{ClientErrors: cErrs}, nil
+	}
+
+	keys := dmodel.DynamicFields{basemodel.FieldId: id}
+	existing, err := this.repository.Exists(ctx, []dmodel.DynamicFields{keys})
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamicResourceService.History")
+	}
+	if existing.ClientErrors.Count() > 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{ClientErrors: existing.ClientErrors}, nil
+	}
+	if len(existing.Data.Existing) == 0 {
+		return &dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]]{HasData: false}, nil
+	}
+
+	result, err := this.repository.ChangeHistory(ctx, dyn.RepoChangeHistoryParam{
+		Keys: keys,
+		Page: page,
+		Size: min(size, model.MODEL_RULE_PAGE_MAX_SIZE),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamicResourceService.History")
+	}
+	// A record nobody changed since it was tracked still has a history, an empty one; only a
+	// missing record answers without data.
+	if result.ClientErrors.Count() == 0 {
+		result.HasData = true
+	}
+	return result, nil
+}
+
 // searchSingle runs a one-item search over the given graph and reshapes it into a get-one result.
 func (this *DynamicResourceServiceImpl) searchSingle(
 	ctx corectx.Context, fields []string, graph *dmodel.SearchGraph,
@@ -55,6 +55,7 @@ func unwrapPagedResult(
 		Total:         paged.Total,
 		Page:          paged.Page,
 		Size:          paged.Size,
+		NextCursor:    paged.NextCursor,
 		DesiredFields: paged.DesiredFields,
 		MaskedFields:  paged.MaskedFields,
 		SchemaEtag:    paged.SchemaEtag,
@@ -0,0 +1,421 @@
+package engine
+
+import (
+	stdErr "errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// Import loads a CSV or XLSX file in two passes. The first reads the whole file, resolves the
+// lookups and the records to upsert, and validates every row with the schema, so that a file with
+// errors is reported in full and nothing is written. A dry run stops there. The second pass hands
+// each row to writeRow, which the import action points at the resource's own create and update
+// actions: a row then passes every check, and causes every side effect, that a resource adds to
+// those actions, and a resource refusing a client-written create refuses an imported one too.
+// Those checks run at write time only, so a dry run does not report what they would refuse.
+//
+// The rows are written in one transaction, or one per batch when a batch size is given: a failed
+// batch is rolled back, and the batches before it stay committed. Called inside a transaction of
+// the caller's, the import joins it and does not batch.
+func (this *DynamicResourceServiceImpl) Import(
+	ctx corectx.Context, params dmodel.DynamicFields, writeRow it.ImportRowWriter,
+) (*dyn.OpResult[it.ImportResultData], error) {
+	opts, cErrs := readImportOptions(this.schema, params)
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[it.ImportResultData]{ClientErrors: cErrs}, nil
+	}
+
+	table, err := readImportTable(opts.format, opts.content)
+	if err != nil {
+		// An unreadable file is the caller's to fix; the parser's message says what is wrong with it.
+		return &dyn.OpResult[it.ImportResultData]{ClientErrors: ft.ClientErrors{
+			*ft.NewValidationError(it.ImportParamContent, ft.ErrorKey("err_unreadable_import_file"), err.Error()),
+		}}, nil
+	}
+	columns, cErrs := mapImportColumns(this.schema, table.header)
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[it.ImportResultData]{ClientErrors: cErrs}, nil
+	}
+
+	rows, cErrs, err := this.prepareImportRows(ctx, opts, columns, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "DynamicResourceService.Import")
+	}
+	if cErrs.Count() > 0 {
+		return &dyn.OpResult[it.ImportResultData]{ClientErrors: cErrs}, nil
+	}
+
+	data := it.ImportResultData{DryRun: opts.dryRun, TotalRows: len(rows)}
+	for _, row := range rows {
+		if row.isUpdate {
+			data.UpdatedCount++
+		} else {
+			data.CreatedCount++
+		}
+	}
+	if !opts.dryRun {
+		cErrs, err = this.writeImportRows(ctx, rows, opts.batchSize, writeRow)
+		if err != nil {
+			return nil, errors.Wrap(err, "DynamicResourceService.Import")
+		}
+		if cErrs.Count() > 0 {
+			return &dyn.OpResult[it.ImportResultData]{ClientErrors: cErrs}, nil
+		}
+	}
+	return &dyn.OpResult[it.ImportResultData]{Data: data, HasData: true}, nil
+}
+
+type importOptions struct {
+	content   []byte
+	format    string
+	dryRun    bool
+	batchSize int
+	upsertBy  []string
+}
+
+func readImportOptions(schema *dmodel.ModelSchema, params dmodel.DynamicFields) (importOptions, ft.ClientErrors) {
+	cErrs := ft.ClientErrors{}
+	opts := importOptions{}
+
+	switch content := params[it.ImportParamContent].(type) {
+	case []byte:
+		opts.content = content
+	case string:
+		opts.content = []byte(content)
+	}
+	if len(opts.content) == 0 {
+		cErrs.Append(*dmodel.NewMissingFieldErr(it.ImportParamContent))
+	}
+
+	opts.format = detectImportFormat(
+		readString(params, it.ImportParamFormat), readString(params, it.ImportParamFileName), opts.content)
+	if opts.format != it.ImportFormatCsv && opts.format != it.ImportFormatXlsx {
+		cErrs.Append(*ft.NewValidationError(it.ImportParamFormat, ft.ErrorKey("err_unsupported_import_format"),
+			"format must be csv or xlsx"))
+	}
+
+	opts.dryRun, _ = readBool(params, it.ImportParamDryRun)
+
+	batchSize, ok := readIntParam(params[it.ImportParamBatchSize])
+	if !ok || batchSize < 0 {
+		cErrs.Append(*dmodel.NewInvalidDataTypeErr(it.ImportParamBatchSize, "non-negative integer"))
+	}
+	opts.batchSize = batchSize
+
+	opts.upsertBy = readImportNames(params[it.ImportParamUpsertBy])
+	if len(opts.upsertBy) > 0 && !isUniqueKey(schema, opts.upsertBy) {
+		cErrs.Append(*ft.NewValidationError(it.ImportParamUpsertBy, ft.ErrorKey("err_invalid_upsert_key"),
+			"upsert fields must be the primary key or a unique key of this resource"))
+	}
+	return opts, cErrs
+}
+
+// readImportNames accepts a list of names, or one comma-separated string as a form field carries it.
+func readImportNames(raw any) []string {
+	var names []string
+	switch typed := raw.(type) {
+	case []string:
+		names = typed
+	case []any:
+		for _, item := range typed {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	case string:
+		names = strings.Split(typed, ",")
+	}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// importRow is one data row, mapped to fields and ready to write. number is the row's position
+// in the file, header included.
+type importRow struct {
+	number   int
+	data     dmodel.DynamicFields
+	isUpdate bool
+}
+
+func importRowErrorField(rowNumber int, field string) string {
+	if field == "" {
+		return fmt.Sprintf("rows[%d]", rowNumber)
+	}
+	return fmt.Sprintf("rows[%d].%s", rowNumber, field)
+}
+
+// appendRowErrors reports the errors of one row under that row's number.
+func appendRowErrors(target *ft.ClientErrors, rowNumber int, cErrs ft.ClientErrors) {
+	for _, item := range cErrs {
+		item.Field = importRowErrorField(rowNumber, item.Field)
+		target.Append(item)
+	}
+}
+
+func (this *DynamicResourceServiceImpl) prepareImportRows(
+	ctx corectx.Context, opts importOptions, columns []importColumn, table *importTable,
+) ([]importRow, ft.ClientErrors, error) {
+	cErrs := ft.ClientErrors{}
+	rows := make([]importRow, len(table.rows))
+	for i, cells := range table.rows {
+		rows[i] = importRow{number: table.rowNumbers[i], data: importRowFields(columns, cells)}
+	}
+
+	if err := this.resolveImportLookups(ctx, columns, table, rows, &cErrs); err != nil {
+		return nil, nil, err
+	}
+	if len(opts.upsertBy) > 0 {
+		if err := this.resolveImportUpserts(ctx, opts.upsertBy, rows, &cErrs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, row := range rows {
+		data := maps.Clone(row.data)
+		this.schema.InjectServiceFields(ctx, data, row.isUpdate)
+		if _, vErrs := this.schema.Validate(data, row.isUpdate); vErrs.Count() > 0 {
+			appendRowErrors(&cErrs, row.number, vErrs)
+		}
+	}
+	return rows, cErrs, nil
+}
+
+// importRowFields maps the cells of one row. An empty cell is left out, so that the field
+// default applies on create and the stored value stays on update.
+func importRowFields(columns []importColumn, cells []string) dmodel.DynamicFields {
+	fields := dmodel.DynamicFields{}
+	for _, column := range columns {
+		if column.isLookup() || column.index >= len(cells) {
+			continue
+		}
+		cell := strings.TrimSpace(cells[column.index])
+		if cell == "" {
+			continue
+		}
+		name := column.field.Name()
+		if column.language == "" {
+			fields[name] = importCellValue(column.field, cell)
+			continue
+		}
+		translations, _ := fields[name].(model.LangJson)
+		if translations == nil {
+			translations = model.LangJson{}
+		}
+		translations[column.language] = cell
+		fields[name] = translations
+	}
+	return fields
+}
+
+// resolveImportLookups fills the foreign keys of every lookup column, with one batched read per
+// column rather than one per row.
+func (this *DynamicResourceServiceImpl) resolveImportLookups(
+	ctx corectx.Context, columns []importColumn, table *importTable, rows []importRow, cErrs *ft.ClientErrors,
+) error {
+	for _, column := range columns {
+		if !column.isLookup() {
+			continue
+		}
+		cellOf := func(rowIndex int) string {
+			cells := table.rows[rowIndex]
+			if column.index >= len(cells) {
+				return ""
+			}
+			return strings.TrimSpace(cells[column.index])
+		}
+
+		values := []any{}
+		seen := map[string]bool{}
+		for i := range rows {
+			if cell := cellOf(i); cell != "" && !seen[cell] {
+				seen[cell] = true
+				values = append(values, cell)
+			}
+		}
+		peers, err := this.searchImportPeers(ctx, column, values)
+		if err != nil {
+			return err
+		}
+
+		for i, row := range rows {
+			cell := cellOf(i)
+			if cell == "" {
+				continue
+			}
+			keys, found := peers[cell]
+			if !found {
+				cErrs.Append(*ft.NewNotFoundError(importRowErrorField(row.number, column.errorField())))
+				continue
+			}
+			maps.Copy(row.data, keys)
+		}
+	}
+	return nil
+}
+
+// searchImportPeers reads the peers a lookup column names, keyed by the looked-up value as text.
+func (this *DynamicResourceServiceImpl) searchImportPeers(
+	ctx corectx.Context, column importColumn, values []any,
+) (map[string]dmodel.DynamicFields, error) {
+	if this.sourceSearch == nil {
+		return nil, errors.Errorf("import lookup '%s' needs a SourceSearch in the service", column.header)
+	}
+	fkPairs := column.relation.EffectiveForeignKeys()
+	fields := []string{column.peerField}
+	for _, pair := range fkPairs {
+		fields = append(fields, pair.ReferencedColumn)
+	}
+
+	found := make(map[string]dmodel.DynamicFields, len(values))
+	for chunk := range slices.Chunk(values, model.MODEL_RULE_PAGE_MAX_SIZE) {
+		peers, err := this.sourceSearch(ctx, column.peerSchema.Name(), column.peerField, chunk, fields)
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range peers {
+			keys := dmodel.DynamicFields{}
+			for _, pair := range fkPairs {
+				keys[pair.FkColumn] = peer[pair.ReferencedColumn]
+			}
+			found[fmt.Sprint(peer[column.peerField])] = keys
+		}
+	}
+	return found, nil
+}
+
+// resolveImportUpserts turns every row whose upsert key matches a stored record into an update of
+// that record. Two rows with the same key are rejected: the second would silently overwrite the
+// first within one import.
+func (this *DynamicResourceServiceImpl) resolveImportUpserts(
+	ctx corectx.Context, upsertBy []string, rows []importRow, cErrs *ft.ClientErrors,
+) error {
+	firstRowOfKey := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		keys, complete := this.importUpsertKeys(upsertBy, row.data)
+		if !complete {
+			continue
+		}
+
+		keyParts := make([]string, len(upsertBy))
+		for j, name := range upsertBy {
+			keyParts[j] = fmt.Sprint(keys[name])
+		}
+		keyText := strings.Join(keyParts, "\x00")
+		if first, duplicated := firstRowOfKey[keyText]; duplicated {
+			cErrs.Append(*ft.NewValidationError(importRowErrorField(row.number, upsertBy[0]),
+				ft.ErrorKey("err_duplicate_import_key"), fmt.Sprintf("same key as row %d", first)))
+			continue
+		}
+		firstRowOfKey[keyText] = row.number
+
+		found, err := this.repository.FindByKeys(ctx, keys)
+		if err != nil {
+			return err
+		}
+		if found.ClientErrors.Count() > 0 {
+			appendRowErrors(cErrs, row.number, found.ClientErrors)
+			continue
+		}
+		if !found.HasData {
+			continue
+		}
+		row.isUpdate = true
+		for _, name := range this.schema.PrimaryKeys() {
+			row.data[name] = found.Data[name]
+		}
+		if _, hasEtag := this.schema.Field(basemodel.FieldEtag); hasEtag {
+			row.data[basemodel.FieldEtag] = found.Data[basemodel.FieldEtag]
+		}
+	}
+	return nil
+}
+
+// importUpsertKeys reads the upsert key of a row, converted to the key fields' types so that the
+// lookup compares like with like. A row missing part of its key can only be a create.
+func (this *DynamicResourceServiceImpl) importUpsertKeys(
+	upsertBy []string, data dmodel.DynamicFields,
+) (dmodel.DynamicFields, bool) {
+	keys := dmodel.DynamicFields{}
+	for _, name := range upsertBy {
+		raw, exists := data[name]
+		if !exists {
+			return nil, false
+		}
+		keys[name] = raw
+		if field, found := this.schema.Field(name); found {
+			if converted, vErr := field.Validate(raw); vErr == nil && !converted.IsEmpty() {
+				keys[name] = *converted.Get()
+			}
+		}
+	}
+	return keys, true
+}
+
+// writeImportRows writes the prepared rows, in the caller's transaction when there is one.
+func (this *DynamicResourceServiceImpl) writeImportRows(
+	ctx corectx.Context, rows []importRow, batchSize int, writeRow it.ImportRowWriter,
+) (ft.ClientErrors, error) {
+	if ctx.GetDbTranx() != nil {
+		return writeImportBatch(ctx, rows, writeRow)
+	}
+	if batchSize <= 0 || batchSize > len(rows) {
+		batchSize = len(rows)
+	}
+	for batch := range slices.Chunk(rows, batchSize) {
+		cErrs, err := this.writeImportBatchInTx(ctx, batch, writeRow)
+		if err != nil || cErrs.Count() > 0 {
+			return cErrs, err
+		}
+	}
+	return nil, nil
+}
+
+func (this *DynamicResourceServiceImpl) writeImportBatchInTx(
+	ctx corectx.Context, rows []importRow, writeRow it.ImportRowWriter,
+) (_ ft.ClientErrors, err error) {
+	tx, err := this.repository.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.SetDbTranx(tx)
+	defer ctx.SetDbTranx(nil)
+
+	cErrs, err := writeImportBatch(ctx, rows, writeRow)
+	if err != nil || cErrs.Count() > 0 {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = stdErr.Join(err, rbErr)
+		}
+		return cErrs, err
+	}
+	return nil, tx.Commit()
+}
+
+func writeImportBatch(ctx corectx.Context, rows []importRow, writeRow it.ImportRowWriter) (ft.ClientErrors, error) {
+	cErrs := ft.ClientErrors{}
+	for _, row := range rows {
+		rowErrs, err := writeRow(ctx, maps.Clone(row.data), row.isUpdate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d", row.number)
+		}
+		appendRowErrors(&cErrs, row.number, rowErrs)
+	}
+	return cErrs, nil
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"encoding/json"
 	stdErr "errors"
+	"strconv"
 
 	"go.bryk.io/pkg/errors"
 
@@ -83,6 +84,13 @@ func paramsToSearchQuery(params dmodel.DynamicFields) (dyn.SearchQuery, error) {
 	return query, err
 }
 
+// paramsToAggregateQuery reads the group keys, measures, having filters and graph out of params.
+func paramsToAggregateQuery(params dmodel.DynamicFields) (dyn.AggregateQuery, error) {
+	query := dyn.AggregateQuery{}
+	err := decodeParams(params, &query)
+	return query, err
+}
+
 func readId(params dmodel.DynamicFields, field string) model.Id {
 	return model.Id(readString(params, field))
 }
@@ -99,6 +107,27 @@ func readString(params dmodel.DynamicFields, field string) string {
 	return ""
 }
 
+// readIntParam accepts a number as Go code, JSON or a form field would carry it. Absent is zero.
+func readIntParam(raw any) (int, bool) {
+	switch typed := raw.(type) {
+	case nil:
+		return 0, true
+	case int:
+		return typed, true
+	case int64:
+		return int(typed), true
+	case float64:
+		return int(typed), typed == float64(int(typed))
+	case string:
+		if typed == "" {
+			return 0, true
+		}
+		parsed, err := strconv.Atoi(typed)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
 func readBool(params dmodel.DynamicFields, field string) (bool, bool) {
 	val, ok := params[field]
 	if !ok || val == nil {
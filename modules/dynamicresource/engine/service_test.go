@@ -4,9 +4,13 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/computed"
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
 	"github.com/sky-as-code/nikki-erp/common/model"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 )
 
 // newFieldsTestSchema builds a schema with three column-backed fields, so that a test
@@ -68,3 +72,30 @@ func TestParamsToSearchQuery_NoContextStaysNil(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, query.Context)
 }
+
+func TestParamsToSearchQuery_TextQuerySurvivesValidation(t *testing.T) {
+	query, err := paramsToSearchQuery(dmodel.DynamicFields{"q": "blue widgt"})
+	require.NoError(t, err)
+
+	sanitized, cErrs := query.GetSchema().ValidateStruct(query)
+	require.Zero(t, cErrs.Count())
+	assert.Equal(t, "blue widgt", *sanitized.(*dyn.SearchQuery).TextQuery)
+}
+
+func TestParamsToAggregateQuery_SurvivesValidation(t *testing.T) {
+	query, err := paramsToAggregateQuery(dmodel.DynamicFields{
+		"group_by": []any{map[string]any{"field": "created_at", "trunc": "month"}},
+		"measures": []any{map[string]any{"name": "total", "function": "sum", "field": "amount"}},
+		"having":   []any{map[string]any{"measure": "total", "operator": ">", "value": 100}},
+	})
+	require.NoError(t, err)
+
+	sanitized, cErrs := query.GetSchema().ValidateStruct(query)
+	require.Zero(t, cErrs.Count())
+	validated := *(sanitized.(*dyn.AggregateQuery))
+
+	assert.Equal(t, []orm.AggregateGroupBy{{Field: "created_at", Trunc: orm.DateTruncMonth}}, validated.GroupBy)
+	assert.Equal(t, []orm.AggregateMeasure{{Name: "total", Function: computed.AggSum, Field: "amount"}}, validated.Measures)
+	assert.Equal(t, []orm.AggregateHaving{{Measure: "total", Operator: dmodel.GreaterThan, Value: float64(100)}}, validated.Having)
+	assert.Equal(t, model.MODEL_RULE_PAGE_MAX_SIZE, validated.Limit, "the limit defaults to the largest page")
+}
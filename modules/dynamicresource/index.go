@@ -45,6 +45,11 @@ func (*DynamicResourceModule) Version() semver.SemVer {
 // It hands the core services that every engine needs to the registry, so that feature
 // modules can create their engines during their own Init(). This module is initialized
 // before them because they declare it in their Deps().
+//
+// It also serves the OpenAPI document of the engines those modules go on to mount.
 func (*DynamicResourceModule) Init() error {
-	return initRegistryDeps()
+	if err := initRegistryDeps(); err != nil {
+		return err
+	}
+	return initOpenApiRoute()
 }
@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"io"
 	"net/http"
 	"regexp"
 
@@ -24,6 +25,10 @@ const (
 	ActionGetByUnique = "get_by_unique"
 	ActionSearch      = "search"
 	ActionExists      = "exists"
+	ActionAggregate   = "aggregate"
+	ActionImport      = "import"
+	ActionExport      = "export"
+	ActionHistory     = "history"
 	ActionGetSchema   = "get_schema"
 )
 
@@ -95,9 +100,41 @@ const (
 //   - dmodel.DynamicFields for single-record actions
 //   - dyn.PagedResultData[dmodel.DynamicFields] for search
 //   - dyn.ExistsResultData for exists
+//   - dyn.AggregateResultData for aggregate
+//   - ImportResultData for import
+//   - StreamResultData for export, or ExportResultData when it runs in the background
+//   - dyn.PagedResultData[dyn.ChangeHistoryEntry] for history
 //   - dyn.MutateResultData for delete/update/set_archived
+//   - FileResultData for an action that produces a document rather than a record
 type ActionResult = dyn.OpResult[any]
 
+// FileResultData is the data of an action whose result is a file, such as a rendered PDF. The
+// REST engine answers it with the bytes themselves instead of a JSON payload, so a download goes
+// through the same pipeline — and the same permission check — as every other action.
+type FileResultData struct {
+	// FileName is offered to the client in Content-Disposition. It is not a storage key.
+	FileName    string
+	ContentType string
+	Content     []byte
+
+	// Inline asks the browser to display the file rather than save it.
+	Inline bool
+}
+
+// StreamResultData is the data of an action whose result is a file too large to hold in memory,
+// such as an export of every record of a resource. The REST engine sends the headers, then calls
+// Write with the response body, so the file is produced while it is downloaded.
+//
+// An error from Write ends the download early: the status is already sent by then, so the client
+// sees a truncated file rather than an error payload. Whatever can fail on the request itself must
+// therefore fail before the action returns.
+type StreamResultData struct {
+	// FileName is offered to the client in Content-Disposition.
+	FileName    string
+	ContentType string
+	Write       func(writer io.Writer) error
+}
+
 // ProcessInput is handed to the main processing function of an action.
 type ProcessInput struct {
 	Params dmodel.DynamicFields
@@ -59,6 +59,19 @@ type DynamicResourceEngine interface {
 type DynamicRestApi interface {
 	// RegisterRoutes adds every endpoint of the resource to the given route group.
 	RegisterRoutes(route *echo.Group, middlewares ...echo.MiddlewareFunc)
+
+	// Routes lists the endpoints RegisterRoutes added, in registration order, each with its
+	// full path including the prefix of the group it was mounted on. It is empty until the
+	// resource is mounted.
+	Routes() []RestRoute
+}
+
+// RestRoute is one endpoint of a resource, as it was mounted on the HTTP server.
+type RestRoute struct {
+	ActionName string
+	Method     string
+	// Path is in Echo syntax, its path params written ":name".
+	Path string
 }
 
 // DynamicResourceService holds the business processing of a resource. It performs
@@ -82,6 +95,24 @@ type DynamicResourceService interface {
 	Search(ctx corectx.Context, params dmodel.DynamicFields) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error)
 	Exists(ctx corectx.Context, params dmodel.DynamicFields) (*dyn.OpResult[dyn.ExistsResultData], error)
 
+	// Aggregate groups the records the params' graph selects and computes measures per group.
+	// Params carry a dyn.AggregateQuery.
+	Aggregate(ctx corectx.Context, params dmodel.DynamicFields) (*dyn.OpResult[dyn.AggregateResultData], error)
+
+	// History returns the change history of one record, the latest change first. Params are the
+	// record "id", and the "page" and "size" of the history. It is empty for a resource whose schema
+	// does not declare TrackHistory.
+	History(ctx corectx.Context, params dmodel.DynamicFields) (
+		*dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]], error)
+
+	// Import creates, or with an upsert key updates, one record per row of a CSV or XLSX file.
+	// Params are the ImportParam* keys. Every row is validated before any is written; row errors
+	// name the spreadsheet row, as in "rows[3].code". Each row is stored by writeRow, which the
+	// import action points at the resource's own create and update actions.
+	Import(
+		ctx corectx.Context, params dmodel.DynamicFields, writeRow ImportRowWriter,
+	) (*dyn.OpResult[ImportResultData], error)
+
 	// Schema is the dynamic-model schema this service operates on.
 	Schema() *dmodel.ModelSchema
 }
@@ -104,4 +135,7 @@ type DynamicResourceRepository interface {
 	GetOne(ctx corectx.Context, param dyn.RepoGetOneParam) (*dyn.OpResult[dmodel.DynamicFields], error)
 	Search(ctx corectx.Context, param dyn.RepoSearchParam) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error)
 	Exists(ctx corectx.Context, keys []dmodel.DynamicFields) (*dyn.OpResult[dyn.RepoExistsResult], error)
+	Aggregate(ctx corectx.Context, param dyn.RepoAggregateParam) (*dyn.OpResult[dyn.AggregateResultData], error)
+	ChangeHistory(ctx corectx.Context, param dyn.RepoChangeHistoryParam) (
+		*dyn.OpResult[dyn.PagedResultData[dyn.ChangeHistoryEntry]], error)
 }
@@ -0,0 +1,48 @@
+package interfaces
+
+import (
+	"time"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+)
+
+// ActionEvent reports one action that succeeded: it passed every check, and its MainProcess
+// answered without an error or a client error.
+type ActionEvent struct {
+	// Id identifies the event. Every listener told of one action is given the same id, so that a
+	// receiver told twice can tell it is the same occurrence.
+	Id string
+
+	ResourceName string
+	ActionName   string
+
+	// OrgId is the organization of the record the action was run on, or empty when the action
+	// cannot be attributed to one, such as an action on a resource without an "org_id" column.
+	OrgId string
+
+	// ActorId is the user who ran the action, or empty for the system.
+	ActorId string
+
+	// RecordId is the "id" of the record the action was run on, or of the record it created.
+	// It is empty for an action on no single record, such as search.
+	RecordId string
+
+	OccurredAt time.Time
+
+	// Data is the action's result data, of the shape the action documents.
+	Data any
+}
+
+// ActionEventListener is told of the actions every engine runs.
+//
+// It is asked first whether it listens to an action, before the action runs, so that an engine
+// does the work of building an event only for an action somebody listens to.
+type ActionEventListener interface {
+	// ListensTo reports whether the listener wants the events of an action. It is called on every
+	// action of every engine and must answer from memory.
+	ListensTo(resourceName string, actionName string) bool
+
+	// OnActionSucceeded is called after the action, on the request's own goroutine. It cannot fail
+	// the action, which has already happened: a listener handles its own errors.
+	OnActionSucceeded(ctx corectx.Context, event ActionEvent)
+}
@@ -0,0 +1,28 @@
+package interfaces
+
+import "time"
+
+// Params of the export action, on top of every search param: the export reads the records a
+// search with the same graph, fields, text query and language would, in the same order.
+const (
+	// ExportParamFormat is ExportFormatCsv, the default, or ExportFormatXlsx.
+	ExportParamFormat = "format"
+	// ExportParamAsync writes the file to storage in the background and answers at once with a
+	// link to it, instead of streaming it in the response.
+	ExportParamAsync = "async"
+)
+
+const (
+	ExportFormatCsv  = "csv"
+	ExportFormatXlsx = "xlsx"
+)
+
+// ExportResultData answers an export that runs in the background. The URL is presigned and
+// serves the file once it is complete; until then it answers not found, as storage only shows
+// an object whose upload has finished.
+type ExportResultData struct {
+	FileName  string    `json:"file_name"`
+	ObjectKey string    `json:"object_key"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
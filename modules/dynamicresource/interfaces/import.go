@@ -0,0 +1,45 @@
+package interfaces
+
+import (
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+)
+
+// Params of the import action. The REST engine fills them from a multipart upload; a module
+// calling ExecuteAction directly passes the file bytes itself.
+const (
+	// ImportParamContent is the file content, as []byte.
+	ImportParamContent = "content"
+	// ImportParamFileName is the uploaded file name, used to guess the format when none is given.
+	ImportParamFileName = "file_name"
+	// ImportParamFormat is ImportFormatCsv or ImportFormatXlsx.
+	ImportParamFormat = "format"
+	// ImportParamDryRun validates every row and reports what would be written, writing nothing.
+	ImportParamDryRun = "dry_run"
+	// ImportParamBatchSize commits every this many rows in a transaction of their own. Zero, the
+	// default, writes the whole file in one transaction.
+	ImportParamBatchSize = "batch_size"
+	// ImportParamUpsertBy lists the unique key fields that identify an existing record. A row
+	// whose key matches one updates it instead of creating a duplicate, so naming a key needs the
+	// update permission as well as the create permission.
+	ImportParamUpsertBy = "upsert_by"
+)
+
+const (
+	ImportFormatCsv  = "csv"
+	ImportFormatXlsx = "xlsx"
+)
+
+// ImportResultData is the outcome of an import. In a dry run the counts are what a real run
+// would write.
+type ImportResultData struct {
+	DryRun       bool `json:"dry_run"`
+	TotalRows    int  `json:"total_rows"`
+	CreatedCount int  `json:"created_count"`
+	UpdatedCount int  `json:"updated_count"`
+}
+
+// ImportRowWriter stores one row of an import: a create, or an update of the record an upsert key
+// matched. It answers the row's client errors, which the import reports under the row's number.
+type ImportRowWriter func(ctx corectx.Context, data dmodel.DynamicFields, isUpdate bool) (ft.ClientErrors, error)
@@ -32,4 +32,8 @@ type DynamicResourceEngineRegistry interface {
 
 	// AllEngines returns every registered engine.
 	AllEngines() []DynamicResourceEngine
+
+	// AddActionEventListener has the listener told of the actions that succeed on every engine,
+	// those built before the call as well as after it.
+	AddActionEventListener(listener ActionEventListener)
 }
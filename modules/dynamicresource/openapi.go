@@ -0,0 +1,37 @@
+package dynamicresource
+
+import (
+	"github.com/labstack/echo/v5"
+
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	c "github.com/sky-as-code/nikki-erp/modules/core/constants"
+	"github.com/sky-as-code/nikki-erp/modules/core/httpserver"
+	m "github.com/sky-as-code/nikki-erp/modules/core/httpserver/middlewares"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
+)
+
+// OpenApiRestPath is where the OpenAPI document is served, under the HTTP base path.
+const OpenApiRestPath = "/v1/openapi.json"
+
+// OpenApiDocument describes the REST surface of every registered engine. Only the routes the
+// engines recorded when they were mounted are described, so the document is complete once every
+// module has initialized.
+func OpenApiDocument(configSvc config.ConfigService) *engine.OpenApiDocument {
+	info := engine.OpenApiInfo{
+		Title: configSvc.GetStr(c.AppName),
+		// The version of the REST API, which every resource path is mounted under.
+		Version: "v1",
+	}
+	return engine.BuildOpenApiDocument(info, registrySingleton.AllEngines())
+}
+
+// initOpenApiRoute serves the OpenAPI document. It is built on each request rather than here,
+// because the modules whose engines it describes initialize after this one.
+func initOpenApiRoute() error {
+	return deps.Invoke(func(route *echo.Group, configSvc config.ConfigService) {
+		route.GET(OpenApiRestPath, func(echoCtx *echo.Context) error {
+			return httpserver.JsonOk(echoCtx, OpenApiDocument(configSvc))
+		}, m.SmokeAuthz())
+	})
+}
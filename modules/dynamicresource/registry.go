@@ -12,6 +12,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/core/config"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/infra/storage/filestorage"
 	"github.com/sky-as-code/nikki-erp/modules/core/logging"
 	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
 	it "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
@@ -28,6 +29,7 @@ func EngineDependencyName(schemaName string) string {
 
 var registrySingleton = &engineRegistry{
 	engines: map[string]it.DynamicResourceEngine{},
+	events:  engine.NewActionEventHub(),
 }
 
 // Registry returns the process-wide engine registry.
@@ -42,6 +44,7 @@ type coreDeps struct {
 	QueryBuilder  orm.QueryBuilder
 	Logger        logging.LoggerService
 	NewBaseRepoFn dyn.NewBaseDynamicRepositoryFn
+	Storage       filestorage.FileStorageAdapter
 }
 
 // initRegistryDeps resolves the core services the registry needs to build engines.
@@ -54,6 +57,7 @@ func initRegistryDeps() error {
 		queryBuilder orm.QueryBuilder,
 		logger logging.LoggerService,
 		newBaseRepoFn dyn.NewBaseDynamicRepositoryFn,
+		storage filestorage.FileStorageAdapter,
 	) {
 		registrySingleton.setCoreDeps(coreDeps{
 			Client:        client,
@@ -61,6 +65,7 @@ func initRegistryDeps() error {
 			QueryBuilder:  queryBuilder,
 			Logger:        logger,
 			NewBaseRepoFn: newBaseRepoFn,
+			Storage:       storage,
 		})
 	})
 }
@@ -76,6 +81,9 @@ type engineRegistry struct {
 	engines  map[string]it.DynamicResourceEngine
 	deps     coreDeps
 	depsDone bool
+
+	// events is shared by every engine, so a listener added late still hears them all.
+	events *engine.ActionEventHub
 }
 
 func (this *engineRegistry) setCoreDeps(deps coreDeps) {
@@ -84,6 +92,7 @@ func (this *engineRegistry) setCoreDeps(deps coreDeps) {
 
 	this.deps = deps
 	this.depsDone = true
+	this.events.SetLogger(deps.Logger)
 }
 
 // NewEngine builds an engine for the given schema, wires its three subengines and its
@@ -117,7 +126,7 @@ func (this *engineRegistry) NewEngine(
 	coreDeps := this.deps
 	this.mutex.Unlock()
 
-	newEngine, err := buildEngine(schema, coreDeps, engineOpts)
+	newEngine, err := buildEngine(schema, coreDeps, this.events, engineOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +141,7 @@ func (this *engineRegistry) NewEngine(
 }
 
 func buildEngine(
-	schema *dmodel.ModelSchema, deps coreDeps, options it.NewEngineOptions,
+	schema *dmodel.ModelSchema, deps coreDeps, events it.ActionEventListener, options it.NewEngineOptions,
 ) (it.DynamicResourceEngine, error) {
 	repository := engine.NewDynamicResourceRepository(engine.NewRepositoryParam{
 		Client:        deps.Client,
@@ -146,32 +155,37 @@ func buildEngine(
 		Schema:        schema,
 		Repository:    repository,
 		DefaultFields: options.DefaultSearchFields,
+		SourceSearch:  searchSourceRows,
 	})
 	// Every resource gets computed-field evaluation; a schema without computed fields passes
 	// through untouched. A module's extended service embeds this wrapped one, so its overrides
 	// keep layering on top.
-	service = engine.WithComputedFields(service, searchSourceRowsForComputed)
+	service = engine.WithComputedFields(service, searchSourceRows)
 
 	newEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{
 		Schema:     schema,
 		Repository: repository,
 		Service:    service,
+		Events:     events,
 	})
-	if err := engine.DefineBuiltinActions(newEngine); err != nil {
+	if err := engine.DefineBuiltinActions(newEngine, engine.BuiltinActionOptions{
+		ExportStorage: deps.Storage,
+		Logger:        deps.Logger,
+	}); err != nil {
 		return nil, errors.Wrapf(err, "failed to define built-in actions of '%s'", schema.Name())
 	}
 	return newEngine, nil
 }
 
-// searchSourceRowsForComputed is the batched read behind related computed fields: the rows of
-// schemaName whose keyColumn is IN keys, projected down to fields. It goes through the source
+// searchSourceRows is the batched read behind related computed fields and import lookups: the rows
+// of schemaName whose keyColumn is IN keys, projected down to fields. It goes through the source
 // resource's own repository, so tenant/archive handling stays what a direct read would get.
-func searchSourceRowsForComputed(
+func searchSourceRows(
 	ctx corectx.Context, schemaName string, keyColumn string, keys []any, fields []string,
 ) ([]dmodel.DynamicFields, error) {
 	sourceEngine, ok := registrySingleton.GetEngine(schemaName)
 	if !ok {
-		return nil, errors.Errorf("no resource engine for computed-field source '%s'", schemaName)
+		return nil, errors.Errorf("no resource engine for source '%s'", schemaName)
 	}
 	graph := dmodel.NewSearchGraph()
 	graph.NewCondition(keyColumn, dmodel.In, keys...)
@@ -187,7 +201,7 @@ func searchSourceRowsForComputed(
 	}
 	if found != nil && found.ClientErrors.Count() > 0 {
 		return nil, errors.Errorf(
-			"computed-field source read of '%s' failed: %v", schemaName, found.ClientErrors.ToError())
+			"source read of '%s' failed: %v", schemaName, found.ClientErrors.ToError())
 	}
 	if found == nil || !found.HasData {
 		return nil, nil
@@ -227,3 +241,7 @@ func (this *engineRegistry) AllEngines() []it.DynamicResourceEngine {
 	}
 	return result
 }
+
+func (this *engineRegistry) AddActionEventListener(listener it.ActionEventListener) {
+	this.events.Add(listener)
+}
@@ -10,6 +10,7 @@ func InitApplicationServices() error {
 		NewLanguageApplicationServiceImpl,
 		NewModelMetadataApplicationServiceImpl,
 		NewModuleApplicationServiceImpl,
+		NewTaxApplicationServiceImpl,
 		NewTagServiceImpl,
 		NewUomConversionApplicationServiceImpl,
 	)
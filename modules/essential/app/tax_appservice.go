@@ -0,0 +1,28 @@
+package app
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+)
+
+func NewTaxApplicationServiceImpl(taxSvc itTax.TaxDomainService) itTax.TaxAppService {
+	return &TaxApplicationServiceImpl{taxSvc: taxSvc}
+}
+
+// TaxApplicationServiceImpl is the capability boundary other modules bind to. It is a thin
+// delegation for the reason the currency one is.
+type TaxApplicationServiceImpl struct {
+	taxSvc itTax.TaxDomainService
+}
+
+func (this *TaxApplicationServiceImpl) AssertUsable(
+	ctx corectx.Context, query itTax.AssertUsableQuery,
+) (*itTax.AssertUsableResult, error) {
+	return this.taxSvc.AssertUsable(ctx, query)
+}
+
+func (this *TaxApplicationServiceImpl) ComputeTaxes(
+	ctx corectx.Context, query itTax.ComputeTaxesQuery,
+) (*itTax.ComputeTaxesResult, error) {
+	return this.taxSvc.ComputeTaxes(ctx, query)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// TaxType says which way a tax moves the total.
+type TaxType string
+
+const (
+	// TaxTypeVat is added to what the buyer pays.
+	TaxTypeVat = TaxType("vat")
+
+	// TaxTypeWithholding is kept back by the buyer and remitted on the seller's behalf, so it is
+	// subtracted from the total.
+	TaxTypeWithholding = TaxType("withholding")
+)
+
+func (this TaxType) String() string {
+	return string(this)
+}
+
+// TaxRounding says where a tax's amounts are rounded to the currency's precision.
+type TaxRounding string
+
+const (
+	TaxRoundingPerLine     = TaxRounding("per_line")
+	TaxRoundingPerDocument = TaxRounding("per_document")
+)
+
+func (this TaxRounding) String() string {
+	return string(this)
+}
+
+const (
+	TaxSchemaName = "essential_tax"
+
+	TaxFieldId            = basemodel.FieldId
+	TaxFieldCode          = "code"
+	TaxFieldName          = "name"
+	TaxFieldTaxType       = "tax_type"
+	TaxFieldRatePercent   = "rate_percent"
+	TaxFieldPriceIncluded = "price_included"
+	TaxFieldCompound      = "compound"
+	TaxFieldRounding      = "rounding"
+	TaxFieldSequence      = "sequence"
+	TaxFieldAccountLabel  = "account_label"
+	TaxFieldIsActive      = "is_active"
+)
+
+//go:embed tax.json
+var taxSchemaJson string
+
+func TaxSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(taxSchemaJson)
+}
+
+// Tax is one tax a document line may be charged.
+//
+// It lives in Essential for the reason Currency does: invoices and purchase orders are taxed by
+// the same authorities at the same rates, and two modules keeping their own lists would drift.
+type Tax struct {
+	basemodel.DynamicModelBase
+}
+
+func NewTax() *Tax {
+	return &Tax{basemodel.NewDynamicModel()}
+}
+
+func NewTaxFrom(src dmodel.DynamicFields) *Tax {
+	return &Tax{basemodel.NewDynamicModel(src)}
+}
+
+func (this Tax) GetCode() *string {
+	return this.GetFieldData().GetString(TaxFieldCode)
+}
+
+func (this *Tax) SetCode(v *string) {
+	this.GetFieldData().SetString(TaxFieldCode, v)
+}
+
+func (this Tax) GetName() *model.LangJson {
+	return this.GetFieldData().GetLangJson(TaxFieldName)
+}
+
+func (this *Tax) SetName(v *model.LangJson) {
+	this.GetFieldData().SetLangJson(TaxFieldName, v)
+}
+
+func (this Tax) GetTaxType() *string {
+	return this.GetFieldData().GetString(TaxFieldTaxType)
+}
+
+func (this *Tax) SetTaxType(v *string) {
+	this.GetFieldData().SetString(TaxFieldTaxType, v)
+}
+
+func (this Tax) GetRatePercent() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(TaxFieldRatePercent)
+}
+
+func (this *Tax) SetRatePercent(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(TaxFieldRatePercent, v)
+}
+
+func (this Tax) GetPriceIncluded() *bool {
+	return this.GetFieldData().GetBool(TaxFieldPriceIncluded)
+}
+
+func (this *Tax) SetPriceIncluded(v *bool) {
+	this.GetFieldData().SetBool(TaxFieldPriceIncluded, v)
+}
+
+func (this Tax) GetCompound() *bool {
+	return this.GetFieldData().GetBool(TaxFieldCompound)
+}
+
+func (this *Tax) SetCompound(v *bool) {
+	this.GetFieldData().SetBool(TaxFieldCompound, v)
+}
+
+func (this Tax) GetRounding() *string {
+	return this.GetFieldData().GetString(TaxFieldRounding)
+}
+
+func (this *Tax) SetRounding(v *string) {
+	this.GetFieldData().SetString(TaxFieldRounding, v)
+}
+
+func (this Tax) GetSequence() *int32 {
+	return this.GetFieldData().GetInt32(TaxFieldSequence)
+}
+
+func (this *Tax) SetSequence(v *int32) {
+	this.GetFieldData().SetInt32(TaxFieldSequence, v)
+}
+
+func (this Tax) GetAccountLabel() *string {
+	return this.GetFieldData().GetString(TaxFieldAccountLabel)
+}
+
+func (this *Tax) SetAccountLabel(v *string) {
+	this.GetFieldData().SetString(TaxFieldAccountLabel, v)
+}
+
+func (this Tax) GetIsActive() *bool {
+	return this.GetFieldData().GetBool(TaxFieldIsActive)
+}
+
+func (this *Tax) SetIsActive(v *bool) {
+	this.GetFieldData().SetBool(TaxFieldIsActive, v)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+)
+
+func TestTaxSchemaParses(t *testing.T) {
+	requireBaseSchemasRegistered(t)
+
+	schema := TaxSchemaBuilder().Build()
+
+	assert.Equal(t, TaxSchemaName, schema.Name())
+	assert.Equal(t, "essential_taxes", schema.TableName())
+	assert.Equal(t, TaxFieldName, schema.RecordLabelField())
+	// Issued documents key their breakdown by code, so a code that could change would orphan them.
+	assert.True(t, requireField(t, schema, TaxFieldCode).IsNoUpdate())
+	assert.Equal(t, dmodel.FieldDataTypeNameDecimal,
+		requireField(t, schema, TaxFieldRatePercent).DataType().String())
+}
+
+// The computation switches on both enums, so a value added to the schema alone would be a tax that
+// computes as nothing in particular.
+func TestTaxEnumValues(t *testing.T) {
+	requireBaseSchemasRegistered(t)
+
+	schema := TaxSchemaBuilder().Build()
+
+	assert.ElementsMatch(t,
+		[]string{TaxTypeVat.String(), TaxTypeWithholding.String()},
+		requireField(t, schema, TaxFieldTaxType).DataType().Options()[dmodel.FieldDataTypeOptEnumValues])
+	assert.ElementsMatch(t,
+		[]string{TaxRoundingPerLine.String(), TaxRoundingPerDocument.String()},
+		requireField(t, schema, TaxFieldRounding).DataType().Options()[dmodel.FieldDataTypeOptEnumValues])
+}
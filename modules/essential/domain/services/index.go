@@ -9,6 +9,7 @@ func InitDomainServices() error {
 		NewLanguageDomainServiceImpl,
 		NewModelMetadataDomainServiceImpl,
 		NewModuleDomainServiceImpl,
+		NewTaxDomainServiceImpl,
 		NewUomConversionDomainServiceImpl,
 	)
 }
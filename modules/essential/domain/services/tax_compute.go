@@ -0,0 +1,249 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sky-as-code/nikki-erp/common/util"
+	"github.com/sky-as-code/nikki-erp/modules/essential/domain/models"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+)
+
+// The tax arithmetic, kept free of any repository so that it can be pinned by tests.
+//
+// A line's taxes are applied in sequence. Each has a multiplier on the line's untaxed amount: its
+// rate, times one plus the taxes before it if it is compound, negated if it is withholding. The
+// price-included ones are divided out of the line amount first to find the untaxed amount, and then
+// every tax is charged on that.
+//
+// Rounding is where per-line and per-document taxes differ. A per-line tax is rounded on each line
+// and its document amount is the sum. A per-document tax is summed exactly and rounded once; the
+// lines are still rounded so that they can be stored, and the difference between their sum and the
+// document amount is put on the line that carries the most of that tax. Without that, the lines
+// would not add up to the document, which is the one discrepancy every reader checks for.
+
+var hundred = decimal.NewFromInt(100)
+
+// taxRule is the part of a tax the computation reads.
+type taxRule struct {
+	Id            string
+	Code          string
+	TaxType       models.TaxType
+	RatePercent   decimal.Decimal
+	PriceIncluded bool
+	Compound      bool
+	PerDocument   bool
+	Sequence      int32
+	AccountLabel  string
+}
+
+func taxRuleOf(tax *models.Tax) taxRule {
+	taxType := models.TaxType(util.ValueOrZeroOf(tax.GetTaxType()))
+	return taxRule{
+		Id:          util.ValueOrZeroOf(tax.GetId()),
+		Code:        util.ValueOrZeroOf(tax.GetCode()),
+		TaxType:     taxType,
+		RatePercent: util.ValueOrZeroOf(tax.GetRatePercent()),
+		// Withholding is never inside a quoted price: it is what the buyer keeps back from it.
+		PriceIncluded: util.ValueOrZeroOf(tax.GetPriceIncluded()) && taxType != models.TaxTypeWithholding,
+		Compound:      util.ValueOrZeroOf(tax.GetCompound()),
+		PerDocument:   models.TaxRounding(util.ValueOrZeroOf(tax.GetRounding())) == models.TaxRoundingPerDocument,
+		Sequence:      util.ValueOrZeroOf(tax.GetSequence()),
+		AccountLabel:  util.ValueOrZeroOf(tax.GetAccountLabel()),
+	}
+}
+
+// lineTaxWork is one tax on one line while the document is being computed.
+type lineTaxWork struct {
+	rule    taxRule
+	exact   decimal.Decimal
+	rounded decimal.Decimal
+	base    decimal.Decimal
+}
+
+// lineWork is one line while the document is being computed.
+type lineWork struct {
+	amount      decimal.Decimal
+	hasIncluded bool
+	untaxed     decimal.Decimal
+	taxes       []*lineTaxWork
+}
+
+// computeTaxes applies rules to lines, rounding to scale. Every id a line names must be in rules.
+func computeTaxes(rules map[string]taxRule, lines []itTax.TaxableLine, scale int32) itTax.ComputeTaxesResultData {
+	works := make([]*lineWork, len(lines))
+	for index, line := range lines {
+		works[index] = computeLine(rulesOf(rules, line.TaxIds), line.Amount, scale)
+	}
+
+	spreadDocumentRounding(works, scale)
+
+	result := itTax.ComputeTaxesResultData{
+		Lines:   make([]itTax.TaxedLine, len(works)),
+		Untaxed: decimal.Zero,
+		Tax:     decimal.Zero,
+	}
+	breakdown := map[string]*itTax.TaxBreakdown{}
+	order := []taxRule{}
+
+	for index, work := range works {
+		untaxed := work.untaxed
+		if work.hasIncluded {
+			// The line still comes to the price that was quoted: whatever the included taxes
+			// rounded to, the untaxed amount is what is left of the price after them.
+			untaxed = work.amount.Round(scale)
+			for _, tax := range work.taxes {
+				if tax.rule.PriceIncluded {
+					untaxed = untaxed.Sub(tax.rounded)
+				}
+			}
+		}
+
+		taxed := itTax.TaxedLine{Untaxed: untaxed, Tax: decimal.Zero, Taxes: []itTax.LineTax{}}
+		for _, tax := range work.taxes {
+			taxed.Tax = taxed.Tax.Add(tax.rounded)
+			taxed.Taxes = append(taxed.Taxes, itTax.LineTax{TaxId: tax.rule.Id, Amount: tax.rounded})
+
+			entry, seen := breakdown[tax.rule.Id]
+			if !seen {
+				entry = &itTax.TaxBreakdown{
+					TaxId:        tax.rule.Id,
+					Code:         tax.rule.Code,
+					TaxType:      tax.rule.TaxType.String(),
+					RatePercent:  tax.rule.RatePercent,
+					AccountLabel: tax.rule.AccountLabel,
+					Base:         decimal.Zero,
+					Amount:       decimal.Zero,
+				}
+				breakdown[tax.rule.Id] = entry
+				order = append(order, tax.rule)
+			}
+			entry.Base = entry.Base.Add(tax.base.Round(scale))
+			entry.Amount = entry.Amount.Add(tax.rounded)
+		}
+		taxed.Total = taxed.Untaxed.Add(taxed.Tax)
+
+		result.Lines[index] = taxed
+		result.Untaxed = result.Untaxed.Add(taxed.Untaxed)
+		result.Tax = result.Tax.Add(taxed.Tax)
+	}
+	result.Total = result.Untaxed.Add(result.Tax)
+
+	sortRules(order)
+	result.Breakdown = make([]itTax.TaxBreakdown, 0, len(order))
+	for _, rule := range order {
+		result.Breakdown = append(result.Breakdown, *breakdown[rule.Id])
+	}
+	return result
+}
+
+// computeLine works out one line's exact taxes and rounds each to scale.
+func computeLine(rules []taxRule, amount decimal.Decimal, scale int32) *lineWork {
+	work := &lineWork{amount: amount}
+
+	// Each tax's multiplier on the untaxed amount, and the multiplier of its base.
+	multipliers := make([]decimal.Decimal, len(rules))
+	bases := make([]decimal.Decimal, len(rules))
+	prior := decimal.Zero
+	included := decimal.NewFromInt(1)
+	for index, rule := range rules {
+		base := decimal.NewFromInt(1)
+		if rule.Compound {
+			base = base.Add(prior)
+		}
+		multiplier := rule.RatePercent.Div(hundred).Mul(base)
+		if rule.TaxType == models.TaxTypeWithholding {
+			multiplier = multiplier.Neg()
+		}
+		multipliers[index] = multiplier
+		bases[index] = base
+		prior = prior.Add(multiplier)
+
+		if rule.PriceIncluded {
+			work.hasIncluded = true
+			included = included.Add(multiplier)
+		}
+	}
+
+	untaxed := amount
+	if work.hasIncluded && !included.IsZero() {
+		untaxed = amount.Div(included)
+	}
+	work.untaxed = untaxed.Round(scale)
+
+	for index, rule := range rules {
+		exact := untaxed.Mul(multipliers[index])
+		work.taxes = append(work.taxes, &lineTaxWork{
+			rule:    rule,
+			exact:   exact,
+			rounded: exact.Round(scale),
+			base:    untaxed.Mul(bases[index]),
+		})
+	}
+	return work
+}
+
+// spreadDocumentRounding rounds each per-document tax once over the document and puts the
+// difference from the sum of its rounded line amounts on the line carrying the most of it.
+func spreadDocumentRounding(works []*lineWork, scale int32) {
+	type documentTax struct {
+		exact   decimal.Decimal
+		rounded decimal.Decimal
+		largest *lineTaxWork
+	}
+	documentTaxes := map[string]*documentTax{}
+
+	for _, work := range works {
+		for _, tax := range work.taxes {
+			if !tax.rule.PerDocument {
+				continue
+			}
+			entry, seen := documentTaxes[tax.rule.Id]
+			if !seen {
+				entry = &documentTax{exact: decimal.Zero, rounded: decimal.Zero}
+				documentTaxes[tax.rule.Id] = entry
+			}
+			entry.exact = entry.exact.Add(tax.exact)
+			entry.rounded = entry.rounded.Add(tax.rounded)
+			if entry.largest == nil || tax.rounded.Abs().GreaterThan(entry.largest.rounded.Abs()) {
+				entry.largest = tax
+			}
+		}
+	}
+
+	for _, entry := range documentTaxes {
+		difference := entry.exact.Round(scale).Sub(entry.rounded)
+		if !difference.IsZero() {
+			entry.largest.rounded = entry.largest.rounded.Add(difference)
+		}
+	}
+}
+
+// rulesOf picks a line's taxes out of rules in the order they are applied. A tax named twice on a
+// line is charged once.
+func rulesOf(rules map[string]taxRule, taxIds []string) []taxRule {
+	seen := map[string]bool{}
+	picked := make([]taxRule, 0, len(taxIds))
+	for _, taxId := range taxIds {
+		rule, ok := rules[taxId]
+		if !ok || seen[taxId] {
+			continue
+		}
+		seen[taxId] = true
+		picked = append(picked, rule)
+	}
+	sortRules(picked)
+	return picked
+}
+
+// sortRules orders taxes by sequence, and by code where two share one so that the order does not
+// depend on how a line happened to list them.
+func sortRules(rules []taxRule) {
+	sort.SliceStable(rules, func(left, right int) bool {
+		if rules[left].Sequence != rules[right].Sequence {
+			return rules[left].Sequence < rules[right].Sequence
+		}
+		return rules[left].Code < rules[right].Code
+	})
+}
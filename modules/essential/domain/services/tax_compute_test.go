@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/essential/domain/models"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+)
+
+func vat(id string, rate string) taxRule {
+	return taxRule{Id: id, Code: id, TaxType: models.TaxTypeVat, RatePercent: decimal.RequireFromString(rate), Sequence: 10}
+}
+
+func taxable(amount string, taxIds ...string) itTax.TaxableLine {
+	return itTax.TaxableLine{Amount: decimal.RequireFromString(amount), TaxIds: taxIds}
+}
+
+func rulesById(rules ...taxRule) map[string]taxRule {
+	byId := map[string]taxRule{}
+	for _, rule := range rules {
+		byId[rule.Id] = rule
+	}
+	return byId
+}
+
+func assertAmount(t *testing.T, want string, got decimal.Decimal, what string) {
+	t.Helper()
+	assert.True(t, got.Equal(decimal.RequireFromString(want)), "%s: want %s, got %s", what, want, got)
+}
+
+func TestATaxIsAddedOnTopOfTheAmount(t *testing.T) {
+	result := computeTaxes(rulesById(vat("VAT10", "10")), []itTax.TaxableLine{taxable("100", "VAT10")}, 2)
+
+	assertAmount(t, "100", result.Lines[0].Untaxed, "untaxed")
+	assertAmount(t, "10", result.Lines[0].Tax, "tax")
+	assertAmount(t, "110", result.Total, "total")
+}
+
+// A price-included tax is taken out of the price, so the line still comes to what was quoted.
+func TestAPriceIncludedTaxIsTakenOutOfTheAmount(t *testing.T) {
+	included := vat("VAT10", "10")
+	included.PriceIncluded = true
+
+	result := computeTaxes(rulesById(included), []itTax.TaxableLine{taxable("110", "VAT10")}, 2)
+
+	assertAmount(t, "100", result.Lines[0].Untaxed, "untaxed")
+	assertAmount(t, "10", result.Lines[0].Tax, "tax")
+	assertAmount(t, "110", result.Lines[0].Total, "total")
+}
+
+// Whatever the included tax rounds to, the line's total is the price: the untaxed amount absorbs
+// the rounding rather than the total.
+func TestAPriceIncludedLineKeepsItsPriceThroughRounding(t *testing.T) {
+	included := vat("VAT8", "8")
+	included.PriceIncluded = true
+
+	result := computeTaxes(rulesById(included), []itTax.TaxableLine{taxable("10", "VAT8")}, 2)
+
+	assertAmount(t, "0.74", result.Lines[0].Tax, "tax")
+	assertAmount(t, "9.26", result.Lines[0].Untaxed, "untaxed")
+	assertAmount(t, "10", result.Lines[0].Total, "total")
+}
+
+func TestWithholdingIsSubtractedFromTheTotal(t *testing.T) {
+	withholding := taxRule{Id: "WHT5", Code: "WHT5", TaxType: models.TaxTypeWithholding,
+		RatePercent: decimal.RequireFromString("5"), Sequence: 20}
+
+	result := computeTaxes(rulesById(vat("VAT10", "10"), withholding),
+		[]itTax.TaxableLine{taxable("100", "WHT5", "VAT10")}, 2)
+
+	assertAmount(t, "5", result.Tax, "tax")
+	assertAmount(t, "105", result.Total, "total")
+	require.Len(t, result.Breakdown, 2)
+	assert.Equal(t, "VAT10", result.Breakdown[0].Code)
+	assertAmount(t, "-5", result.Breakdown[1].Amount, "withholding")
+}
+
+// A compound tax is charged on the amount plus the taxes before it in sequence.
+func TestACompoundTaxIsChargedOnThePriorTaxes(t *testing.T) {
+	first := vat("BASE10", "10")
+	first.Sequence = 1
+	second := vat("ON5", "5")
+	second.Sequence = 2
+	second.Compound = true
+
+	result := computeTaxes(rulesById(first, second), []itTax.TaxableLine{taxable("100", "ON5", "BASE10")}, 2)
+
+	require.Len(t, result.Lines[0].Taxes, 2)
+	assertAmount(t, "10", result.Lines[0].Taxes[0].Amount, "first tax")
+	assertAmount(t, "5.5", result.Lines[0].Taxes[1].Amount, "compound tax")
+	assertAmount(t, "110", result.Breakdown[1].Base, "compound base")
+	assertAmount(t, "115.5", result.Total, "total")
+}
+
+// Three lines each owing 0.105 round to 0.11 apiece per line, but to 0.32 once per document. Either
+// way the lines must add up to the document.
+func TestRoundingPerLineAndPerDocument(t *testing.T) {
+	lines := []itTax.TaxableLine{taxable("1.05", "VAT10"), taxable("1.05", "VAT10"), taxable("1.05", "VAT10")}
+
+	perLine := computeTaxes(rulesById(vat("VAT10", "10")), lines, 2)
+	assertAmount(t, "0.33", perLine.Tax, "per-line tax")
+
+	perDocumentRule := vat("VAT10", "10")
+	perDocumentRule.PerDocument = true
+	perDocument := computeTaxes(rulesById(perDocumentRule), lines, 2)
+	assertAmount(t, "0.32", perDocument.Tax, "per-document tax")
+	assertAmount(t, "0.32", perDocument.Breakdown[0].Amount, "breakdown")
+
+	sum := decimal.Zero
+	for _, line := range perDocument.Lines {
+		sum = sum.Add(line.Tax)
+	}
+	assertAmount(t, "0.32", sum, "sum of the lines")
+}
+
+func TestALineWithoutTaxesOwesNothing(t *testing.T) {
+	result := computeTaxes(rulesById(), []itTax.TaxableLine{taxable("12.345")}, 2)
+
+	assertAmount(t, "12.35", result.Lines[0].Untaxed, "untaxed")
+	assertAmount(t, "0", result.Tax, "tax")
+	assert.Empty(t, result.Breakdown)
+}
+
+func TestATaxNamedTwiceOnALineIsChargedOnce(t *testing.T) {
+	result := computeTaxes(rulesById(vat("VAT10", "10")), []itTax.TaxableLine{taxable("100", "VAT10", "VAT10")}, 2)
+
+	assertAmount(t, "10", result.Tax, "tax")
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/common/util"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	"github.com/sky-as-code/nikki-erp/modules/essential/domain/models"
+	itCurrency "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/currency"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+)
+
+// maxTaxesPerDocument bounds how many distinct taxes one computation may load. A document names a
+// handful; a query naming thousands is a malformed request rather than a document.
+const maxTaxesPerDocument = 100
+
+func NewTaxDomainServiceImpl(currencySvc itCurrency.CurrencyDomainService) itTax.TaxDomainService {
+	return &TaxDomainServiceImpl{currencySvc: currencySvc}
+}
+
+type TaxDomainServiceImpl struct {
+	currencySvc itCurrency.CurrencyDomainService
+}
+
+// AssertUsable reports whether every named tax may be put on a new line.
+//
+// Each bad reference is its own violation, so that a line naming two withdrawn taxes is told about
+// both at once rather than one per attempt.
+func (this *TaxDomainServiceImpl) AssertUsable(
+	ctx corectx.Context, query itTax.AssertUsableQuery,
+) (*itTax.AssertUsableResult, error) {
+	vErrs := &ft.ClientErrors{}
+	field := query.Field
+	if field == "" {
+		field = models.TaxFieldId
+	}
+
+	found, err := this.loadTaxes(ctx, query.Ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "assert taxes usable")
+	}
+
+	for _, taxId := range uniqueIds(query.Ids) {
+		tax, ok := found[taxId]
+		switch {
+		case !ok:
+			vErrs.Append(*ft.NewBusinessViolation(field, "tax.not_found",
+				"the tax '"+taxId+"' does not exist"))
+		case !util.ValueOrZeroOf(tax.GetIsActive()):
+			vErrs.Append(*ft.NewBusinessViolation(field, "tax.not_active",
+				"the tax '"+util.ValueOrZeroOf(tax.GetCode())+"' is not active"))
+		case util.ValueOrZeroOf(tax.IsArchived()):
+			vErrs.Append(*ft.NewBusinessViolation(field, "tax.archived",
+				"the tax '"+util.ValueOrZeroOf(tax.GetCode())+"' is archived"))
+		}
+	}
+
+	if vErrs.Count() > 0 {
+		return &itTax.AssertUsableResult{ClientErrors: *vErrs}, nil
+	}
+	return &itTax.AssertUsableResult{HasData: true}, nil
+}
+
+// ComputeTaxes works out what a document's lines owe.
+//
+// The currency is resolved here rather than passed as a scale, so that every consumer rounds a
+// currency to the same number of places without each holding a currency port for the purpose.
+func (this *TaxDomainServiceImpl) ComputeTaxes(
+	ctx corectx.Context, query itTax.ComputeTaxesQuery,
+) (*itTax.ComputeTaxesResult, error) {
+	vErrs := &ft.ClientErrors{}
+
+	taxIds := []model.Id{}
+	for _, line := range query.Lines {
+		taxIds = append(taxIds, line.TaxIds...)
+	}
+	found, err := this.loadTaxes(ctx, taxIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "compute taxes")
+	}
+
+	rules := make(map[string]taxRule, len(found))
+	for taxId, tax := range found {
+		rules[taxId] = taxRuleOf(tax)
+	}
+	for _, taxId := range uniqueIds(taxIds) {
+		if _, ok := rules[taxId]; !ok {
+			vErrs.Append(*ft.NewBusinessViolation("tax_ids", "tax.not_found",
+				"the tax '"+taxId+"' does not exist"))
+		}
+	}
+	if vErrs.Count() > 0 {
+		return &itTax.ComputeTaxesResult{ClientErrors: *vErrs}, nil
+	}
+
+	scale, err := this.scaleOf(ctx, query.CurrencyId)
+	if err != nil {
+		return nil, errors.Wrap(err, "compute taxes")
+	}
+
+	return &itTax.ComputeTaxesResult{
+		Data:    computeTaxes(rules, query.Lines, scale),
+		HasData: true,
+	}, nil
+}
+
+// scaleOf reads the currency's precision. A document with no currency, or one that no longer
+// resolves, rounds to the commonest precision rather than failing: the taxes are still owed.
+func (this *TaxDomainServiceImpl) scaleOf(ctx corectx.Context, currencyId model.Id) (int32, error) {
+	if currencyId == "" {
+		return defaultDecimalPlaces, nil
+	}
+	found, err := this.currencySvc.GetCurrency(ctx, itCurrency.GetCurrencyQuery{Id: currencyId})
+	if err != nil {
+		return 0, err
+	}
+	if found == nil || !found.HasData {
+		return defaultDecimalPlaces, nil
+	}
+	return found.Data.DecimalPlaces, nil
+}
+
+// loadTaxes fetches the named taxes by id, archived ones included: a document already carrying a
+// tax must keep computing after it is withdrawn.
+func (this *TaxDomainServiceImpl) loadTaxes(
+	ctx corectx.Context, taxIds []model.Id,
+) (map[string]*models.Tax, error) {
+	found := map[string]*models.Tax{}
+	unique := uniqueIds(taxIds)
+	if len(unique) == 0 {
+		return found, nil
+	}
+	if len(unique) > maxTaxesPerDocument {
+		return nil, errors.Errorf("loadTaxes: %d distinct taxes exceed the limit of %d",
+			len(unique), maxTaxesPerDocument)
+	}
+
+	engine, ok := dynamicresource.Registry().GetEngine(models.TaxSchemaName)
+	if !ok {
+		return nil, errors.Errorf("loadTaxes: the '%s' engine is not registered", models.TaxSchemaName)
+	}
+
+	keys := make([]any, len(unique))
+	for index, taxId := range unique {
+		keys[index] = taxId
+	}
+	graph := dmodel.NewSearchGraph()
+	graph.NewCondition(models.TaxFieldId, dmodel.In, keys...)
+
+	result, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  len(unique),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loadTaxes")
+	}
+	if result == nil || !result.HasData {
+		return found, nil
+	}
+	for _, item := range result.Data.Items {
+		tax := models.NewTaxFrom(item)
+		found[util.ValueOrZeroOf(tax.GetId())] = tax
+	}
+	return found, nil
+}
+
+// uniqueIds drops empty and repeated ids, keeping the first occurrence's order.
+func uniqueIds(ids []model.Id) []model.Id {
+	seen := map[model.Id]bool{}
+	unique := make([]model.Id, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
@@ -17,6 +17,7 @@ func TestEngineSchemaNamesCoverEveryResource(t *testing.T) {
 	assert.ElementsMatch(t,
 		[]string{
 			models.CurrencySchemaName,
+			models.TaxSchemaName,
 			models.UomCatSchemaName,
 			models.UomSchemaName,
 		},
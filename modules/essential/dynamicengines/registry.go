@@ -35,6 +35,7 @@ type engineSpec struct {
 // each with the field set its listing UI needs.
 var engineSpecs = []engineSpec{
 	currencyEngineSpec(),
+	taxEngineSpec(),
 	uomCatEngineSpec(),
 	uomEngineSpec(),
 }
@@ -0,0 +1,24 @@
+package dynamicengines
+
+import (
+	"github.com/sky-as-code/nikki-erp/modules/essential/domain/models"
+)
+
+// The Tax engine.
+//
+// Like Currency, a tax is reference data: the built-in CRUD is its whole surface. Computing what a
+// line owes is not an action on the resource but a service other modules call
+// (itTax.TaxAppService), because it works on their lines rather than on a tax.
+func taxEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.TaxSchemaName,
+		DefaultFields: []string{
+			models.TaxFieldCode,
+			models.TaxFieldName,
+			models.TaxFieldTaxType,
+			models.TaxFieldRatePercent,
+			models.TaxFieldPriceIncluded,
+			models.TaxFieldIsActive,
+		},
+	}
+}
@@ -80,6 +80,7 @@ func (*EssentialModule) RegisterModels() error {
 		dmodel.RegisterSchemaB(models.LanguageSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.TagSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.CurrencySchemaBuilder()),
+		dmodel.RegisterSchemaB(models.TaxSchemaBuilder()),
 		// The category must be registered before the UoM: the UoM's edge points at it.
 		dmodel.RegisterSchemaB(models.UomCatSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.UomSchemaBuilder()),
@@ -0,0 +1,29 @@
+package tax
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+)
+
+type AssertUsableResult = dyn.OpResult[struct{}]
+type ComputeTaxesResult = dyn.OpResult[ComputeTaxesResultData]
+
+// TaxDomainService is the capability, implemented inside Essential.
+type TaxDomainService interface {
+	// AssertUsable reports whether the taxes may be put on a new line.
+	//
+	// It is narrower than what ComputeTaxes accepts, for the reason AssertUsable on a currency is: a
+	// tax withdrawn from use must keep computing for the documents that already carry it.
+	AssertUsable(ctx corectx.Context, query AssertUsableQuery) (*AssertUsableResult, error)
+
+	// ComputeTaxes works out what a document's lines owe. A tax that does not exist is a violation;
+	// an inactive or archived one still computes.
+	ComputeTaxes(ctx corectx.Context, query ComputeTaxesQuery) (*ComputeTaxesResult, error)
+}
+
+// TaxAppService is the capability other modules consume. It is the type a consuming module's
+// infra/external/index.go binds to its own local port.
+type TaxAppService interface {
+	AssertUsable(ctx corectx.Context, query AssertUsableQuery) (*AssertUsableResult, error)
+	ComputeTaxes(ctx corectx.Context, query ComputeTaxesQuery) (*ComputeTaxesResult, error)
+}
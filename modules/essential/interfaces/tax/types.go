@@ -0,0 +1,100 @@
+// Package tax declares the tax capability that Essential offers to other modules.
+//
+// CRUD on the tax resource goes through the dynamic resource engine. What lives here is what a
+// module pricing lines must be able to do without reaching into Essential's repositories: check
+// that the taxes a line names may be used, and work out what the line owes under them.
+//
+// The computation is in one place on purpose. Invoices and purchase orders taxed by the same rules
+// must come to the same amounts, and two copies of the arithmetic — price-included extraction,
+// compounding, per-document rounding — would disagree in exactly the cases an auditor checks.
+//
+// A consuming module binds this through a local port in its own interfaces/external/, as it does
+// for the currency capability.
+package tax
+
+import (
+	"github.com/shopspring/decimal"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// AssertUsableQuery asks whether every tax in Ids may be put on a new line: each must exist, be
+// active and not be archived.
+type AssertUsableQuery struct {
+	Ids []model.Id
+
+	// Field is the name the caller knows the references by — "tax_ids" on a line. It is echoed back
+	// on any violation.
+	Field string
+}
+
+// ComputeTaxesQuery asks what a document's lines owe.
+type ComputeTaxesQuery struct {
+	// CurrencyId is the document's currency, whose decimal_places every amount is rounded to. A
+	// document with no currency yet rounds to two places.
+	CurrencyId model.Id
+
+	Lines []TaxableLine
+}
+
+// TaxableLine is one line as the consuming module prices it.
+type TaxableLine struct {
+	// Amount is what the line comes to before tax is added: quantity times unit price, less any
+	// discount. When one of the line's taxes is price-included, that tax is inside this amount.
+	Amount decimal.Decimal
+
+	// TaxIds names the taxes charged on the line. A line with none owes nothing.
+	TaxIds []model.Id
+}
+
+// ComputeTaxesResultData is what the document owes, line by line and tax by tax.
+//
+// Every amount is rounded to the currency, and the parts add up: Untaxed and Tax are the sums of
+// the lines', and the sum of Breakdown is Tax. A consumer stores what it is given rather than
+// adding anything up again itself.
+type ComputeTaxesResultData struct {
+	// Lines are in the order of the query's lines.
+	Lines []TaxedLine
+
+	// Breakdown has one entry per tax charged anywhere on the document, in the order the taxes
+	// are applied.
+	Breakdown []TaxBreakdown
+
+	Untaxed decimal.Decimal
+	Tax     decimal.Decimal
+	Total   decimal.Decimal
+}
+
+// TaxedLine is what one line owes.
+type TaxedLine struct {
+	// Untaxed is the line amount with any price-included tax taken out.
+	Untaxed decimal.Decimal
+
+	// Tax is the net of the line's taxes: withholding is subtracted, so it can be negative.
+	Tax decimal.Decimal
+
+	Total decimal.Decimal
+	Taxes []LineTax
+}
+
+// LineTax is one tax's share of one line.
+type LineTax struct {
+	TaxId  model.Id
+	Amount decimal.Decimal
+}
+
+// TaxBreakdown is one tax's total over the document, as a printed document and a tax return show
+// it.
+type TaxBreakdown struct {
+	TaxId        model.Id
+	Code         string
+	TaxType      string
+	RatePercent  decimal.Decimal
+	AccountLabel string
+
+	// Base is the amount the tax was charged on, summed over the lines that carry it.
+	Base decimal.Decimal
+
+	// Amount is negative for withholding, so that adding up a breakdown gives the document's tax.
+	Amount decimal.Decimal
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/job"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itAr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+)
+
+// A campaign deadline is a date a reviewer was given weeks of notice of, so an hour's
+// lateness in closing it costs nothing.
+const (
+	cronAccessReviewDeadline    = "0 * * * *"
+	jobNameAccessReviewDeadline = "iam-access-review-deadline"
+)
+
+// AccessReviewJobs closes campaigns whose deadline has passed.
+type AccessReviewJobs struct {
+	accessReviewSvc itAr.AccessReviewDomainService
+	logger          logging.LoggerService
+
+	// now is injected so the sweep can be tested against a fixed clock rather than by waiting.
+	now func() time.Time
+}
+
+func NewAccessReviewJobs(
+	accessReviewSvc itAr.AccessReviewDomainService,
+	logger logging.LoggerService,
+) *AccessReviewJobs {
+	return &AccessReviewJobs{
+		accessReviewSvc: accessReviewSvc,
+		logger:          logger,
+		now:             time.Now,
+	}
+}
+
+func (this *AccessReviewJobs) RegisterJobs(registry job.CronjobRegistry) error {
+	return registry.Register(cronAccessReviewDeadline, jobNameAccessReviewDeadline, asJobHandler(this.CloseDue))
+}
+
+// CloseDue closes every campaign past its deadline, first revoking what nobody reviewed
+// when the campaign asks for that.
+//
+// A campaign whose revocations did not all succeed is left open, so the next run meets
+// its remaining items again; closing it would turn an unfinished revocation into a
+// final result. One campaign's trouble does not hold up the others.
+func (this *AccessReviewJobs) CloseDue(ctx corectx.Context) error {
+	due, err := this.accessReviewSvc.FindDueCampaigns(ctx, this.now())
+	if err != nil {
+		return errors.Wrap(err, jobNameAccessReviewDeadline)
+	}
+
+	for _, campaign := range due {
+		autoRevoke := campaign.IsAutoRevokeUnreviewed()
+		if autoRevoke != nil && *autoRevoke && !this.revokeUnreviewed(ctx, campaign) {
+			continue
+		}
+		if err := this.accessReviewSvc.CloseDueCampaign(ctx, campaign); err != nil {
+			this.logger.Errorf("%s: %s", jobNameAccessReviewDeadline, err.Error())
+		}
+	}
+	return nil
+}
+
+// revokeUnreviewed works through the campaign's pending items batch by batch, paging
+// by id so that an item left pending - one that failed, or one a deadline does not
+// revoke - is passed over rather than read back again. It reports whether every item
+// it met went through.
+func (this *AccessReviewJobs) revokeUnreviewed(ctx corectx.Context, campaign models.AccessReviewCampaign) bool {
+	revoked, failed := 0, 0
+	defer func() {
+		if revoked > 0 || failed > 0 {
+			this.logger.Infof("%s: auto-revoked %d and failed %d unreviewed item(s) of campaign '%s'",
+				jobNameAccessReviewDeadline, revoked, failed, *campaign.GetId())
+		}
+	}()
+
+	var afterId *model.Id
+	for {
+		pending, err := this.accessReviewSvc.FindPendingItems(ctx, campaign, afterId)
+		if err != nil {
+			this.logger.Errorf("%s: %s", jobNameAccessReviewDeadline, err.Error())
+			return false
+		}
+		if len(pending) == 0 {
+			return failed == 0
+		}
+
+		for _, item := range pending {
+			ok, err := this.accessReviewSvc.AutoRevokeItem(ctx, item)
+			if err != nil {
+				this.logger.Errorf("%s: %s", jobNameAccessReviewDeadline, err.Error())
+				failed++
+				continue
+			}
+			if ok {
+				revoked++
+			}
+		}
+		afterId = pending[len(pending)-1].GetId()
+		if afterId == nil {
+			this.logger.Errorf("%s: pending item of campaign '%s' has no id", jobNameAccessReviewDeadline, *campaign.GetId())
+			return false
+		}
+	}
+}
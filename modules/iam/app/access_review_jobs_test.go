@@ -0,0 +1,134 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itAr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+)
+
+// The deadline sweep must finish however its items turn out. An item it cannot revoke stays
+// pending, so reading pending items back from the start would meet it again on every pass;
+// the sweep pages by id instead, and a failure keeps the campaign open for the next run.
+
+const testSweepBatchSize = 2
+
+// stubAccessReviewService holds one due campaign's pending items and the outcome each gets.
+type stubAccessReviewService struct {
+	itAr.AccessReviewDomainService
+
+	pendingIds []model.Id
+	notRevoked map[model.Id]bool
+	failing    map[model.Id]bool
+
+	attempts []model.Id
+	closed   bool
+}
+
+func (this *stubAccessReviewService) FindDueCampaigns(
+	_ corectx.Context, _ time.Time,
+) ([]models.AccessReviewCampaign, error) {
+	return []models.AccessReviewCampaign{*models.NewAccessReviewCampaignFrom(dmodel.DynamicFields{
+		models.AccessReviewCampFieldId:                   "01CAMPAIGN000000000000000A",
+		models.AccessReviewCampFieldAutoRevokeUnreviewed: true,
+	})}, nil
+}
+
+func (this *stubAccessReviewService) FindPendingItems(
+	_ corectx.Context, _ models.AccessReviewCampaign, afterId *model.Id,
+) ([]models.AccessReviewItem, error) {
+	var batch []models.AccessReviewItem
+	for _, id := range this.pendingIds {
+		if afterId != nil && id <= *afterId {
+			continue
+		}
+		if len(batch) == testSweepBatchSize {
+			break
+		}
+		batch = append(batch, *models.NewAccessReviewItemFrom(dmodel.DynamicFields{
+			models.AccessReviewItemFieldId: string(id),
+		}))
+	}
+	return batch, nil
+}
+
+// AutoRevokeItem removes the item from the pending list only when it is revoked, as the
+// database does; anything else is still there to be read back.
+func (this *stubAccessReviewService) AutoRevokeItem(_ corectx.Context, item models.AccessReviewItem) (bool, error) {
+	id := *item.GetId()
+	this.attempts = append(this.attempts, id)
+	if len(this.attempts) > 10*len(this.pendingIds)+10 {
+		panic("the sweep is reading the same items back")
+	}
+	if this.failing[id] {
+		return false, errors.New("revoke failed")
+	}
+	if this.notRevoked[id] {
+		return false, nil
+	}
+	for i, pendingId := range this.pendingIds {
+		if pendingId == id {
+			this.pendingIds = append(this.pendingIds[:i], this.pendingIds[i+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (this *stubAccessReviewService) CloseDueCampaign(_ corectx.Context, _ models.AccessReviewCampaign) error {
+	this.closed = true
+	return nil
+}
+
+func newAccessReviewJobs(svc *stubAccessReviewService) *AccessReviewJobs {
+	jobs := NewAccessReviewJobs(svc, logging.NewLogger(logging.LevelError))
+	jobs.now = func() time.Time { return sweepNow }
+	return jobs
+}
+
+func TestCloseDueRevokesEveryUnreviewedItemAcrossBatches(t *testing.T) {
+	svc := &stubAccessReviewService{pendingIds: []model.Id{"item-1", "item-2", "item-3", "item-4", "item-5"}}
+
+	require.NoError(t, newAccessReviewJobs(svc).CloseDue(sweepContext()))
+
+	assert.Equal(t, []model.Id{"item-1", "item-2", "item-3", "item-4", "item-5"}, svc.attempts)
+	assert.Empty(t, svc.pendingIds)
+	assert.True(t, svc.closed)
+}
+
+// An item left pending - decided in between, or of a kind a deadline does not revoke - is
+// passed over once and does not hold the campaign open.
+func TestCloseDuePassesOverItemsLeftPending(t *testing.T) {
+	svc := &stubAccessReviewService{
+		pendingIds: []model.Id{"item-1", "item-2", "item-3", "item-4"},
+		notRevoked: map[model.Id]bool{"item-1": true, "item-2": true, "item-3": true},
+	}
+
+	require.NoError(t, newAccessReviewJobs(svc).CloseDue(sweepContext()))
+
+	assert.Equal(t, []model.Id{"item-1", "item-2", "item-3", "item-4"}, svc.attempts)
+	assert.True(t, svc.closed)
+}
+
+func TestCloseDueKeepsACampaignWithFailedItemsOpen(t *testing.T) {
+	svc := &stubAccessReviewService{
+		pendingIds: []model.Id{"item-1", "item-2", "item-3"},
+		failing:    map[model.Id]bool{"item-1": true},
+	}
+
+	require.NoError(t, newAccessReviewJobs(svc).CloseDue(sweepContext()))
+
+	assert.Equal(t, []model.Id{"item-1", "item-2", "item-3"}, svc.attempts,
+		"one failure does not stop the items after it")
+	assert.Equal(t, []model.Id{"item-1"}, svc.pendingIds)
+	assert.False(t, svc.closed, "the next run meets the failed item again")
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+)
+
+func NewAccessReviewWorkflow(
+	accessReviewAppSvc it.AccessReviewAppService,
+	accessReviewSvc it.AccessReviewDomainService,
+) *AccessReviewWorkflow {
+	return &AccessReviewWorkflow{
+		accessReviewAppSvc: accessReviewAppSvc,
+		accessReviewSvc:    accessReviewSvc,
+	}
+}
+
+// AccessReviewWorkflow serves the access review engines' actions and guards from the
+// access review services, in the way GrantRequestWorkflow does for grant requests.
+type AccessReviewWorkflow struct {
+	accessReviewAppSvc it.AccessReviewAppService
+	accessReviewSvc    it.AccessReviewDomainService
+}
+
+func (this *AccessReviewWorkflow) ValidateCampaignEdit(
+	ctx corectx.Context, input *models.AccessReviewCampaign, found *models.AccessReviewCampaign, vErrs *ft.ClientErrors,
+) error {
+	return this.accessReviewSvc.ValidateCampaignEdit(ctx, input, found, vErrs)
+}
+
+func (this *AccessReviewWorkflow) Launch(
+	ctx corectx.Context, id model.Id, etag model.Etag,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.accessReviewAppSvc.LaunchCampaign(ctx, it.LaunchCampaignCommand{Id: id, Etag: etag})
+}
+
+func (this *AccessReviewWorkflow) Close(
+	ctx corectx.Context, id model.Id, etag model.Etag,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.accessReviewAppSvc.CloseCampaign(ctx, it.CloseCampaignCommand{Id: id, Etag: etag})
+}
+
+// Export widens the export into an untyped result, the shape the engine's actions return.
+func (this *AccessReviewWorkflow) Export(ctx corectx.Context, id model.Id) (*dyn.OpResult[any], error) {
+	result, err := this.accessReviewAppSvc.ExportCampaign(ctx, it.ExportCampaignQuery{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	out := &dyn.OpResult[any]{ClientErrors: result.ClientErrors, HasData: result.HasData}
+	if result.HasData {
+		out.Data = result.Data
+	}
+	return out, nil
+}
+
+func (this *AccessReviewWorkflow) Decide(
+	ctx corectx.Context, id model.Id, etag model.Etag, decision models.AccessReviewDecision, comment *string,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.accessReviewAppSvc.DecideReviewItem(ctx, it.DecideReviewItemCommand{
+		Id: id, Etag: etag, Decision: decision, Comment: comment,
+	})
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+)
+
+func NewAccessReviewApplicationServiceImpl(
+	accessReviewSvc it.AccessReviewDomainService,
+	campaignRepo it.AccessReviewCampaignRepository,
+) it.AccessReviewAppService {
+	return &AccessReviewApplicationServiceImpl{
+		accessReviewSvc: accessReviewSvc,
+		campaignRepo:    campaignRepo,
+	}
+}
+
+type AccessReviewApplicationServiceImpl struct {
+	accessReviewSvc it.AccessReviewDomainService
+	campaignRepo    it.AccessReviewCampaignRepository
+}
+
+func (this *AccessReviewApplicationServiceImpl) LaunchCampaign(ctx corectx.Context, cmd it.LaunchCampaignCommand) (*it.LaunchCampaignResult, error) {
+	if cErr := assertPermission(ctx, c.ActionLaunch, c.ResourceIamAccessReviewCampaign, c.ResourceScopeDomain); cErr != nil {
+		return &it.LaunchCampaignResult{ClientErrors: *cErr}, nil
+	}
+	// The campaign opens and its items are snapshotted together, or neither happens.
+	return corecrud.ExecInTranx(ctx, this.campaignRepo, func(tranxCtx corectx.Context) (*it.LaunchCampaignResult, error) {
+		return this.accessReviewSvc.LaunchCampaign(tranxCtx, cmd)
+	})
+}
+
+func (this *AccessReviewApplicationServiceImpl) CloseCampaign(ctx corectx.Context, cmd it.CloseCampaignCommand) (*it.CloseCampaignResult, error) {
+	if cErr := assertPermission(ctx, c.ActionClose, c.ResourceIamAccessReviewCampaign, c.ResourceScopeDomain); cErr != nil {
+		return &it.CloseCampaignResult{ClientErrors: *cErr}, nil
+	}
+	return this.accessReviewSvc.CloseCampaign(ctx, cmd)
+}
+
+// DecideReviewItem asserts no permission here: who may decide depends on the owner of
+// the role under review, which the domain service checks once it has loaded the item.
+func (this *AccessReviewApplicationServiceImpl) DecideReviewItem(ctx corectx.Context, cmd it.DecideReviewItemCommand) (*it.DecideReviewItemResult, error) {
+	// A revocation writes the item, the assignment and the audit row; all commit or none does.
+	return corecrud.ExecInTranx(ctx, this.campaignRepo, func(tranxCtx corectx.Context) (*it.DecideReviewItemResult, error) {
+		return this.accessReviewSvc.DecideReviewItem(tranxCtx, cmd)
+	})
+}
+
+func (this *AccessReviewApplicationServiceImpl) ExportCampaign(ctx corectx.Context, query it.ExportCampaignQuery) (*it.ExportCampaignResult, error) {
+	if cErr := assertPermission(ctx, "read", c.ResourceIamAccessReviewCampaign, c.ResourceScopeDomain); cErr != nil {
+		return &it.ExportCampaignResult{ClientErrors: *cErr}, nil
+	}
+	return this.accessReviewSvc.ExportCampaign(ctx, query)
+}
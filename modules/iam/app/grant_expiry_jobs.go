@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	stdErr "errors"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/job"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+// The schedules.
+//
+// Revocation runs often because the permission cache has already stopped honouring an
+// expired grant; the sweep only brings the tables and the trail in line, and the gap
+// should be short. The notice runs hourly because its lead time is counted in hours.
+const (
+	cronGrantExpiry       = "*/5 * * * *"
+	cronGrantExpiryNotice = "0 * * * *"
+
+	jobNameGrantExpiry       = "iam-grant-expiry"
+	jobNameGrantExpiryNotice = "iam-grant-expiry-notice"
+)
+
+// GrantExpiryJobs runs the sweeps that end time-bound role grants.
+type GrantExpiryJobs struct {
+	expirySvc itRole.GrantExpiryDomainService
+	logger    logging.LoggerService
+
+	// notifyBefore is how long before expiry the receiver is warned.
+	notifyBefore time.Duration
+
+	// now is injected so the sweeps can be tested against a fixed clock rather than by waiting.
+	now func() time.Time
+}
+
+func NewGrantExpiryJobs(
+	expirySvc itRole.GrantExpiryDomainService,
+	notifyBefore time.Duration,
+	logger logging.LoggerService,
+) *GrantExpiryJobs {
+	return &GrantExpiryJobs{
+		expirySvc:    expirySvc,
+		logger:       logger,
+		notifyBefore: notifyBefore,
+		now:          time.Now,
+	}
+}
+
+// RegisterJobs puts both sweeps on the scheduler.
+func (this *GrantExpiryJobs) RegisterJobs(registry job.CronjobRegistry) error {
+	return stdErr.Join(
+		registry.Register(cronGrantExpiry, jobNameGrantExpiry, asJobHandler(this.RevokeExpired)),
+		registry.Register(cronGrantExpiryNotice, jobNameGrantExpiryNotice, asJobHandler(this.NotifyExpiring)),
+	)
+}
+
+// asJobHandler adapts a sweep to the scheduler's handler signature, which carries job
+// arguments neither sweep takes.
+func asJobHandler(sweep func(corectx.Context) error) job.JobHandleFn {
+	return func(ctx context.Context, _ *string) error {
+		return sweep(corectx.NewRequestContext(ctx))
+	}
+}
+
+// RevokeExpired removes every assignment past its expiry.
+//
+// One assignment's failure does not stop the sweep: it is logged and met again on the
+// next run, while the grants queued behind it are still revoked on time.
+func (this *GrantExpiryJobs) RevokeExpired(ctx corectx.Context) error {
+	asOf := this.now()
+	expired, err := this.expirySvc.FindExpiredGrants(ctx, asOf)
+	if err != nil {
+		return errors.Wrap(err, jobNameGrantExpiry)
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	revoked := 0
+	for _, assignment := range expired {
+		ok, err := this.expirySvc.RevokeExpiredGrant(ctx, assignment, asOf)
+		if err != nil {
+			this.logger.Errorf("%s: %s", jobNameGrantExpiry, err.Error())
+			continue
+		}
+		if ok {
+			revoked++
+		}
+	}
+	this.logger.Infof("%s: revoked %d of %d expired grant(s)", jobNameGrantExpiry, revoked, len(expired))
+	return nil
+}
+
+// NotifyExpiring warns the receivers of grants that end within the lead time. Each
+// grant is warned once; a renewal clears the mark so the new expiry is warned about too.
+func (this *GrantExpiryJobs) NotifyExpiring(ctx corectx.Context) error {
+	asOf := this.now()
+	expiring, err := this.expirySvc.FindGrantsNearingExpiry(ctx, asOf, asOf.Add(this.notifyBefore))
+	if err != nil {
+		return errors.Wrap(err, jobNameGrantExpiryNotice)
+	}
+
+	for _, assignment := range expiring {
+		if err := this.expirySvc.NotifyGrantNearingExpiry(ctx, assignment, asOf); err != nil {
+			this.logger.Warnf("%s: %s", jobNameGrantExpiryNotice, err.Error())
+		}
+	}
+	return nil
+}
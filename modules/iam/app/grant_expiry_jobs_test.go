@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/services"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+// The sweeps decide when access ends and who is told beforehand. They are run here against a
+// fixed clock and an in-memory assignment table that keeps the same rules as the SQL: expired
+// means at or before the clock, and a grant once marked notified is not offered again.
+
+var sweepNow = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+type stubAssignmentRepository struct {
+	itRole.RoleAssignmentRepository
+
+	assignments []itRole.RoleAssignment
+	notifiedAt  map[model.Id]time.Time
+}
+
+func (this *stubAssignmentRepository) BeginTransaction(_ corectx.Context) (database.DbTransaction, error) {
+	return stubTransaction{}, nil
+}
+
+func (this *stubAssignmentRepository) FindExpired(
+	_ corectx.Context, asOf time.Time, _ int,
+) ([]itRole.RoleAssignment, error) {
+	var found []itRole.RoleAssignment
+	for _, assignment := range this.assignments {
+		if !assignment.ExpiresAt.After(asOf) {
+			found = append(found, assignment)
+		}
+	}
+	return found, nil
+}
+
+func (this *stubAssignmentRepository) FindExpiringUnnotified(
+	_ corectx.Context, asOf time.Time, until time.Time, _ int,
+) ([]itRole.RoleAssignment, error) {
+	var found []itRole.RoleAssignment
+	for _, assignment := range this.assignments {
+		_, notified := this.notifiedAt[assignment.Id]
+		if assignment.ExpiresAt.After(asOf) && !assignment.ExpiresAt.After(until) && !notified {
+			found = append(found, assignment)
+		}
+	}
+	return found, nil
+}
+
+func (this *stubAssignmentRepository) DeleteExpired(
+	_ corectx.Context, target itRole.RoleAssignment, asOf time.Time,
+) (bool, error) {
+	for i, assignment := range this.assignments {
+		if assignment.Id == target.Id && !assignment.ExpiresAt.After(asOf) {
+			this.assignments = append(this.assignments[:i], this.assignments[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (this *stubAssignmentRepository) MarkExpiryNotified(
+	_ corectx.Context, assignment itRole.RoleAssignment, at time.Time,
+) error {
+	this.notifiedAt[assignment.Id] = at
+	return nil
+}
+
+type stubTransaction struct{}
+
+func (stubTransaction) Commit() error   { return nil }
+func (stubTransaction) Rollback() error { return nil }
+
+// stubPermissionRepository records whose cached permissions were rebuilt.
+type stubPermissionRepository struct {
+	itPerm.PermissionRepository
+
+	rebuiltUsers  []model.Id
+	rebuiltGroups []model.Id
+}
+
+func (this *stubPermissionRepository) RebuildUserPermission(_ corectx.Context, userId model.Id) error {
+	this.rebuiltUsers = append(this.rebuiltUsers, userId)
+	return nil
+}
+
+func (this *stubPermissionRepository) RebuildUserPermissionsForGroup(_ corectx.Context, groupId model.Id) error {
+	this.rebuiltGroups = append(this.rebuiltGroups, groupId)
+	return nil
+}
+
+type recordingNotifier struct {
+	notified []model.Id
+}
+
+func (this *recordingNotifier) NotifyGrantExpiring(_ corectx.Context, assignment itRole.RoleAssignment) error {
+	this.notified = append(this.notified, assignment.Id)
+	return nil
+}
+
+func grantExpiringAt(id string, kind itRole.AssignmentReceiverKind, expiresAt time.Time) itRole.RoleAssignment {
+	return itRole.RoleAssignment{
+		Id:           model.Id(id),
+		RoleId:       "01ROLE0000000000000000000A",
+		ReceiverKind: kind,
+		ReceiverId:   model.Id("receiver-" + id),
+		ExpiresAt:    &expiresAt,
+	}
+}
+
+type grantExpiryFixture struct {
+	jobs        *GrantExpiryJobs
+	assignments *stubAssignmentRepository
+	permissions *stubPermissionRepository
+	notifier    *recordingNotifier
+}
+
+func newGrantExpiryFixture(assignments ...itRole.RoleAssignment) grantExpiryFixture {
+	fixture := grantExpiryFixture{
+		assignments: &stubAssignmentRepository{assignments: assignments, notifiedAt: map[model.Id]time.Time{}},
+		permissions: &stubPermissionRepository{},
+		notifier:    &recordingNotifier{},
+	}
+	expirySvc := services.NewGrantExpiryDomainServiceImpl(
+		fixture.assignments, fixture.permissions, nil, fixture.notifier,
+	)
+	fixture.jobs = NewGrantExpiryJobs(expirySvc, 24*time.Hour, logging.NewLogger(logging.LevelError))
+	fixture.jobs.now = func() time.Time { return sweepNow }
+	return fixture
+}
+
+func sweepContext() corectx.Context {
+	return corectx.NewRequestContext(context.Background())
+}
+
+func TestRevokeExpiredRemovesOnlyGrantsPastTheClock(t *testing.T) {
+	fixture := newGrantExpiryFixture(
+		grantExpiringAt("expired-user", itRole.AssignmentReceiverUser, sweepNow.Add(-time.Hour)),
+		grantExpiringAt("expired-group", itRole.AssignmentReceiverGroup, sweepNow),
+		grantExpiringAt("still-valid", itRole.AssignmentReceiverUser, sweepNow.Add(time.Minute)),
+	)
+
+	require.NoError(t, fixture.jobs.RevokeExpired(sweepContext()))
+
+	require.Len(t, fixture.assignments.assignments, 1)
+	assert.Equal(t, model.Id("still-valid"), fixture.assignments.assignments[0].Id)
+	assert.Equal(t, []model.Id{"receiver-expired-user"}, fixture.permissions.rebuiltUsers)
+	assert.Equal(t, []model.Id{"receiver-expired-group"}, fixture.permissions.rebuiltGroups,
+		"a group grant rebuilds every member through the group")
+}
+
+func TestNotifyExpiringWarnsOnlyWithinTheLeadTime(t *testing.T) {
+	fixture := newGrantExpiryFixture(
+		grantExpiringAt("already-expired", itRole.AssignmentReceiverUser, sweepNow.Add(-time.Minute)),
+		grantExpiringAt("within-window", itRole.AssignmentReceiverUser, sweepNow.Add(23*time.Hour)),
+		grantExpiringAt("window-edge", itRole.AssignmentReceiverGroup, sweepNow.Add(24*time.Hour)),
+		grantExpiringAt("beyond-window", itRole.AssignmentReceiverUser, sweepNow.Add(25*time.Hour)),
+	)
+
+	require.NoError(t, fixture.jobs.NotifyExpiring(sweepContext()))
+
+	assert.Equal(t, []model.Id{"within-window", "window-edge"}, fixture.notifier.notified)
+	assert.Equal(t, sweepNow, fixture.assignments.notifiedAt["within-window"],
+		"the mark is stamped with the sweep's clock")
+}
+
+func TestNotifyExpiringDoesNotWarnTwice(t *testing.T) {
+	fixture := newGrantExpiryFixture(
+		grantExpiringAt("within-window", itRole.AssignmentReceiverUser, sweepNow.Add(time.Hour)),
+	)
+
+	require.NoError(t, fixture.jobs.NotifyExpiring(sweepContext()))
+	fixture.jobs.now = func() time.Time { return sweepNow.Add(30 * time.Minute) }
+	require.NoError(t, fixture.jobs.NotifyExpiring(sweepContext()))
+
+	assert.Equal(t, []model.Id{"within-window"}, fixture.notifier.notified)
+}
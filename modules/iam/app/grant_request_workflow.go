@@ -0,0 +1,59 @@
+package app
+
+import (
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/rolerequest"
+)
+
+func NewGrantRequestWorkflow(
+	roleRequestAppSvc it.RoleRequestAppService,
+	roleRequestSvc it.RoleRequestDomainService,
+) *GrantRequestWorkflow {
+	return &GrantRequestWorkflow{
+		roleRequestAppSvc: roleRequestAppSvc,
+		roleRequestSvc:    roleRequestSvc,
+	}
+}
+
+// GrantRequestWorkflow serves the grant request engine's workflow actions and guards
+// from the role request services, so the engine and the hand-written API decide a
+// request the same way. The decisions go through the application service, which is
+// what opens the transaction an approval needs.
+type GrantRequestWorkflow struct {
+	roleRequestAppSvc it.RoleRequestAppService
+	roleRequestSvc    it.RoleRequestDomainService
+}
+
+func (this *GrantRequestWorkflow) ValidateNewRequest(
+	ctx corectx.Context, request *models.RoleRequest, vErrs *ft.ClientErrors,
+) error {
+	return this.roleRequestSvc.ValidateNewRoleRequest(ctx, request, vErrs)
+}
+
+func (this *GrantRequestWorkflow) ValidateRequestEdit(
+	ctx corectx.Context, input *models.RoleRequest, found *models.RoleRequest, vErrs *ft.ClientErrors,
+) error {
+	return this.roleRequestSvc.ValidateRoleRequestEdit(ctx, input, found, vErrs)
+}
+
+func (this *GrantRequestWorkflow) Approve(
+	ctx corectx.Context, id model.Id, etag model.Etag,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.roleRequestAppSvc.ApproveRoleRequest(ctx, it.ApproveRoleRequestCommand{Id: id, Etag: etag})
+}
+
+func (this *GrantRequestWorkflow) Reject(
+	ctx corectx.Context, id model.Id, etag model.Etag, reason *string,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.roleRequestAppSvc.RejectRoleRequest(ctx, it.RejectRoleRequestCommand{Id: id, Etag: etag, Reason: reason})
+}
+
+func (this *GrantRequestWorkflow) Cancel(
+	ctx corectx.Context, id model.Id, etag model.Etag,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.roleRequestAppSvc.CancelRoleRequest(ctx, it.CancelRoleRequestCommand{Id: id, Etag: etag})
+}
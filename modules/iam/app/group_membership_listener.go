@@ -0,0 +1,21 @@
+package app
+
+import (
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+)
+
+func NewGroupMembershipListener(permRepo itPerm.PermissionRepository) *GroupMembershipListener {
+	return &GroupMembershipListener{permRepo: permRepo}
+}
+
+// GroupMembershipListener keeps permissions in step with the membership rows the group
+// membership engine writes, as GroupDomainService.ManageGroupUsers does for its own.
+type GroupMembershipListener struct {
+	permRepo itPerm.PermissionRepository
+}
+
+func (this *GroupMembershipListener) MembershipChanged(ctx corectx.Context, userId model.Id) error {
+	return this.permRepo.RebuildUserPermission(ctx, userId)
+}
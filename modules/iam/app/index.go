@@ -8,11 +8,13 @@ import (
 
 func InitApplicationServices() error {
 	err := errors.Join(
+		deps.Register(NewAccessReviewApplicationServiceImpl),
 		deps.Register(NewAttemptApplicationServiceImpl),
 		deps.Register(NewLoginApplicationServiceImpl),
 		deps.Register(NewPasswordApplicationServiceImpl),
 		deps.Register(NewEntitlementApplicationServiceImpl),
 		deps.Register(NewGroupApplicationServiceImpl),
+		deps.Register(NewInvitationApplicationServiceImpl),
 		deps.Register(NewOrganizationApplicationServiceImpl),
 		deps.Register(NewOrgUnitApplicationServiceImpl),
 		deps.Register(NewResourceApplicationServiceImpl),
@@ -20,6 +22,7 @@ func InitApplicationServices() error {
 		deps.Register(NewRoleApplicationServiceImpl),
 		deps.Register(NewRoleRequestApplicationServiceImpl),
 		deps.Register(NewPermissionApplicationServiceImpl),
+		deps.Register(NewScimApplicationServiceImpl),
 		deps.Register(NewUserApplicationServiceImpl),
 	)
 	return err
@@ -0,0 +1,25 @@
+package app
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+)
+
+func NewInvitationApplicationServiceImpl(invitationSvc it.InvitationDomainService) it.InvitationAppService {
+	return &InvitationApplicationServiceImpl{invitationSvc: invitationSvc}
+}
+
+// InvitationApplicationServiceImpl asserts no permission of its own. Sending is reached
+// through the user engine's send_invitation action, which already requires update on
+// iam_user; accepting is public, the token being the invitee's only credential.
+type InvitationApplicationServiceImpl struct {
+	invitationSvc it.InvitationDomainService
+}
+
+func (this *InvitationApplicationServiceImpl) SendInvitation(ctx corectx.Context, cmd it.SendInvitationCommand) (*it.SendInvitationResult, error) {
+	return this.invitationSvc.SendInvitation(ctx, cmd)
+}
+
+func (this *InvitationApplicationServiceImpl) AcceptInvitation(ctx corectx.Context, cmd it.AcceptInvitationCommand) (*it.AcceptInvitationResult, error) {
+	return this.invitationSvc.AcceptInvitation(ctx, cmd)
+}
@@ -94,3 +94,22 @@ func (this *RoleRequestApplicationServiceImpl) UpdateRoleRequest(ctx corectx.Con
 	}
 	return this.roleRequestSvc.UpdateRoleRequest(ctx, cmd)
 }
+
+// ApproveRoleRequest, RejectRoleRequest and CancelRoleRequest assert no permission
+// here: who may answer depends on the role's owner and who may cancel on the
+// requestor, and the domain service decides both once it has loaded the request.
+
+func (this *RoleRequestApplicationServiceImpl) ApproveRoleRequest(ctx corectx.Context, cmd it.ApproveRoleRequestCommand) (*it.ApproveRoleRequestResult, error) {
+	// An approval writes the request, the role assignment and the audit row; all commit or none does.
+	return corecrud.ExecInTranx(ctx, this.roleRequestRepo, func(tranxCtx corectx.Context) (*it.ApproveRoleRequestResult, error) {
+		return this.roleRequestSvc.ApproveRoleRequest(tranxCtx, cmd)
+	})
+}
+
+func (this *RoleRequestApplicationServiceImpl) RejectRoleRequest(ctx corectx.Context, cmd it.RejectRoleRequestCommand) (*it.RejectRoleRequestResult, error) {
+	return this.roleRequestSvc.RejectRoleRequest(ctx, cmd)
+}
+
+func (this *RoleRequestApplicationServiceImpl) CancelRoleRequest(ctx corectx.Context, cmd it.CancelRoleRequestCommand) (*it.CancelRoleRequestResult, error) {
+	return this.roleRequestSvc.CancelRoleRequest(ctx, cmd)
+}
@@ -0,0 +1,1096 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	ds "github.com/sky-as-code/nikki-erp/common/datastructure"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itOrg "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/organization"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+func NewScimApplicationServiceImpl(
+	orgSvc itOrg.OrganizationDomainService, permSvc itPerm.PermissionAppService,
+) itScim.ScimAppService {
+	return &ScimApplicationServiceImpl{orgSvc: orgSvc, permSvc: permSvc}
+}
+
+// ScimApplicationServiceImpl provisions an organization's users and groups through the
+// user, group and group membership engines, whose pipelines check the client's
+// permissions. What the engines cannot check is the organization: every read here is
+// scoped to the client's, so a user or a group outside it is answered as not found.
+type ScimApplicationServiceImpl struct {
+	orgSvc  itOrg.OrganizationDomainService
+	permSvc itPerm.PermissionAppService
+}
+
+// scimEngineFor resolves a resource's engine from the registry, which is populated
+// during the module's Init.
+var scimEngineFor = func(schemaName string) (drif.DynamicResourceEngine, error) {
+	engine, ok := dynamicresource.Registry().GetEngine(schemaName)
+	if !ok {
+		return nil, errors.Errorf("no resource engine for '%s'", schemaName)
+	}
+	return engine, nil
+}
+
+var scimUserFields = []string{
+	models.UserFieldId,
+	models.UserFieldEmail,
+	models.UserFieldDisplayName,
+	models.UserFieldStatus,
+	basemodel.FieldCreatedAt,
+	basemodel.FieldUpdatedAt,
+	basemodel.FieldEtag,
+}
+
+var scimGroupFields = []string{
+	models.GroupFieldId,
+	models.GroupFieldName,
+	basemodel.FieldCreatedAt,
+	basemodel.FieldUpdatedAt,
+	basemodel.FieldEtag,
+}
+
+// scimGroupLanguage is the language a group's displayName is read and written in. SCIM
+// has a single display name, while an IAM group's name is translatable.
+const scimGroupLanguage = model.LanguageCodeEnUs
+
+// scimParamLanguage names the search option that compares translatable fields in one
+// language.
+const scimParamLanguage = "language"
+
+func (this *ScimApplicationServiceImpl) Authenticate(ctx corectx.Context, bearerToken string) (*itScim.Client, error) {
+	if bearerToken == "" {
+		return nil, nil
+	}
+	engine, err := scimEngineFor(models.ScimTokenSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	result, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.ScimTokenFieldTokenHash: models.HashScimToken(bearerToken),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "authenticate SCIM token")
+	}
+	if result.ClientErrors.Count() > 0 || !result.HasData {
+		return nil, nil
+	}
+
+	token := models.NewScimTokenFrom(result.Data)
+	if expiresAt := token.GetExpiresAt(); expiresAt != nil && !expiresAt.AfterT(time.Now()) {
+		return nil, nil
+	}
+
+	// The owner's grants are read on every request, so a token stops working when its owner
+	// is disabled, and loses what its owner loses.
+	owner, err := this.permSvc.GetUserEntitlements(ctx, itPerm.GetUserEntitlementsQuery{
+		UserId: token.GetOwnerId(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "authenticate SCIM token")
+	}
+	if owner.ClientErrors.Count() > 0 || !owner.HasData {
+		return nil, nil
+	}
+	return &itScim.Client{
+		TokenId: *token.GetId(),
+		OrgId:   *token.GetOrgId(),
+		OwnerId: *token.GetOwnerId(),
+		Owner: corectx.ContextPermissions{
+			IsOwner:      owner.Data.IsOwner,
+			Entitlements: ds.NewSetFrom(owner.Data.Entitlements...),
+			UserId:       owner.Data.UserId,
+			UserOrgIds:   ds.NewSetFrom(owner.Data.UserOrgIds...),
+			OrgUnitId:    owner.Data.OrgUnitId,
+			OrgUnitOrgId: owner.Data.OrgUnitOrgId,
+		},
+	}, nil
+}
+
+// Users
+
+func (this *ScimApplicationServiceImpl) ListUsers(
+	ctx corectx.Context, client itScim.Client, query itScim.ListQuery,
+) (*itScim.Result[itScim.ListResponse[itScim.User]], error) {
+	filter, sErr := scimFilterNode(query.Filter, resolveScimUserAttr)
+	if sErr != nil {
+		return &itScim.Result[itScim.ListResponse[itScim.User]]{Error: sErr}, nil
+	}
+	list, sErr, err := this.list(ctx, scimListParam{
+		schemaName: models.UserSchemaName,
+		fields:     scimUserFields,
+		scope:      scimUserScope(client),
+		filter:     filter,
+		query:      query,
+	})
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.ListResponse[itScim.User]]{Error: sErr}, err
+	}
+	users := make([]itScim.User, 0, len(list.items))
+	for _, item := range list.items {
+		users = append(users, toScimUser(item))
+	}
+	return &itScim.Result[itScim.ListResponse[itScim.User]]{
+		Data: newScimListResponse(users, list.total, list.startIndex),
+	}, nil
+}
+
+func (this *ScimApplicationServiceImpl) GetUser(
+	ctx corectx.Context, client itScim.Client, id string,
+) (*itScim.Result[itScim.User], error) {
+	found, sErr, err := this.findUser(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.User]{Error: sErr}, err
+	}
+	user := toScimUser(found)
+	return &itScim.Result[itScim.User]{Data: &user}, nil
+}
+
+// CreateUser creates the user and makes it a member of the client's organization.
+func (this *ScimApplicationServiceImpl) CreateUser(
+	ctx corectx.Context, client itScim.Client, user itScim.User,
+) (*itScim.Result[itScim.User], error) {
+	if sErr := validateScimUser(user); sErr != nil {
+		return &itScim.Result[itScim.User]{Error: sErr}, nil
+	}
+	active := user.Active == nil || *user.Active
+	params := dmodel.DynamicFields{
+		models.UserFieldEmail:       user.UserName,
+		models.UserFieldDisplayName: scimUserDisplayName(user),
+		models.UserFieldStatus:      string(scimUserStatus(active)),
+	}
+	created, err := executeScimAction(ctx, models.UserSchemaName, drif.ActionCreate, params)
+	if err != nil {
+		return nil, err
+	}
+	if created.ClientErrors.Count() > 0 {
+		return &itScim.Result[itScim.User]{Error: scimErrorFrom(created.ClientErrors)}, nil
+	}
+	userId := created.Data.(dmodel.DynamicFields).GetModelId(models.UserFieldId)
+
+	membership, err := this.orgSvc.ManageOrgUsers(ctx, itOrg.ManageOrgUsersCommand{
+		OrgId: client.OrgId,
+		Add:   ds.NewSetFrom(*userId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if membership.ClientErrors.Count() > 0 {
+		return &itScim.Result[itScim.User]{Error: scimErrorFrom(membership.ClientErrors)}, nil
+	}
+	return this.GetUser(ctx, client, string(*userId))
+}
+
+func (this *ScimApplicationServiceImpl) ReplaceUser(
+	ctx corectx.Context, client itScim.Client, id string, user itScim.User,
+) (*itScim.Result[itScim.User], error) {
+	found, sErr, err := this.findUser(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.User]{Error: sErr}, err
+	}
+	return this.replaceUser(ctx, client, found, user)
+}
+
+// PatchUser applies the operations to the user as SCIM sees it, then stores the outcome
+// as a replacement would.
+func (this *ScimApplicationServiceImpl) PatchUser(
+	ctx corectx.Context, client itScim.Client, id string, patch itScim.PatchRequest,
+) (*itScim.Result[itScim.User], error) {
+	found, sErr, err := this.findUser(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.User]{Error: sErr}, err
+	}
+	user := toScimUser(found)
+	// The name is answered as a copy of the display name. Dropping it here lets a patch of
+	// displayName alone take effect.
+	user.Name = nil
+	for _, operation := range patch.Operations {
+		if sErr := applyScimUserPatch(&user, operation); sErr != nil {
+			return &itScim.Result[itScim.User]{Error: sErr}, nil
+		}
+	}
+	return this.replaceUser(ctx, client, found, user)
+}
+
+// DeleteUser deletes the user, unless it is also a member of another organization. It is
+// then only removed from the client's, which is all the client can see of it.
+func (this *ScimApplicationServiceImpl) DeleteUser(
+	ctx corectx.Context, client itScim.Client, id string,
+) (*itScim.Result[struct{}], error) {
+	found, sErr, err := this.findUser(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[struct{}]{Error: sErr}, err
+	}
+	userId := *found.GetModelId(models.UserFieldId)
+	shared, sErr, err := this.isInOtherOrg(ctx, client, userId)
+	if err != nil || sErr != nil {
+		return &itScim.Result[struct{}]{Error: sErr}, err
+	}
+
+	var clientErrs ft.ClientErrors
+	if shared {
+		result, err := this.orgSvc.ManageOrgUsers(ctx, itOrg.ManageOrgUsersCommand{
+			OrgId:  client.OrgId,
+			Remove: ds.NewSetFrom(userId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		clientErrs = result.ClientErrors
+	} else {
+		result, err := executeScimAction(ctx, models.UserSchemaName, drif.ActionDelete, dmodel.DynamicFields{
+			models.UserFieldId: string(userId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		clientErrs = result.ClientErrors
+	}
+	if clientErrs.Count() > 0 {
+		return &itScim.Result[struct{}]{Error: scimErrorFrom(clientErrs)}, nil
+	}
+	return &itScim.Result[struct{}]{Data: &struct{}{}}, nil
+}
+
+// isInOtherOrg tells whether the user is a member of an organization besides the client's.
+// Such a user is not the client's alone to rename, suspend or delete.
+func (this *ScimApplicationServiceImpl) isInOtherOrg(
+	ctx corectx.Context, client itScim.Client, userId model.Id,
+) (bool, *itScim.Error, error) {
+	otherOrgs, err := this.orgSvc.SearchOrgs(ctx, itOrg.SearchOrgsQuery{
+		Fields: []string{models.OrgFieldId},
+		Size:   1,
+		Graph: dmodel.NewSearchGraph().And(
+			*dmodel.NewSearchNode().NewCondition(models.OrgEdgeUsers, dmodel.Linked, string(userId)),
+			*dmodel.NewSearchNode().NewCondition(models.OrgFieldId, dmodel.NotEquals, string(client.OrgId)),
+		),
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	if otherOrgs.ClientErrors.Count() > 0 {
+		return false, scimErrorFrom(otherOrgs.ClientErrors), nil
+	}
+	return otherOrgs.Data.Total > 0, nil, nil
+}
+
+func (this *ScimApplicationServiceImpl) findUser(
+	ctx corectx.Context, client itScim.Client, id string,
+) (dmodel.DynamicFields, *itScim.Error, error) {
+	return this.findOne(ctx, models.UserSchemaName, scimUserFields, scimUserScope(client), id, itScim.ResourceTypeUser)
+}
+
+// replaceUser writes what SCIM can change of a user. The status only changes when the
+// client flips active: an invited user that is patched for its name stays invited.
+func (this *ScimApplicationServiceImpl) replaceUser(
+	ctx corectx.Context, client itScim.Client, found dmodel.DynamicFields, user itScim.User,
+) (*itScim.Result[itScim.User], error) {
+	if sErr := validateScimUser(user); sErr != nil {
+		return &itScim.Result[itScim.User]{Error: sErr}, nil
+	}
+	id := *found.GetModelId(models.UserFieldId)
+	params := dmodel.DynamicFields{
+		models.UserFieldId:          string(id),
+		basemodel.FieldEtag:         string(*found.GetEtag(basemodel.FieldEtag)),
+		models.UserFieldEmail:       user.UserName,
+		models.UserFieldDisplayName: scimUserDisplayName(user),
+	}
+	wasActive := scimUserIsActive(found)
+	if user.Active != nil && *user.Active != wasActive {
+		params[models.UserFieldStatus] = string(scimUserStatus(*user.Active))
+	}
+
+	if scimUserProfileChanged(found, params) || params[models.UserFieldStatus] != nil {
+		shared, sErr, err := this.isInOtherOrg(ctx, client, id)
+		if err != nil || sErr != nil {
+			return &itScim.Result[itScim.User]{Error: sErr}, err
+		}
+		if shared {
+			return this.replaceSharedUser(ctx, client, found, params)
+		}
+	}
+
+	updated, err := executeScimAction(ctx, models.UserSchemaName, drif.ActionUpdate, params)
+	if err != nil {
+		return nil, err
+	}
+	if updated.ClientErrors.Count() > 0 {
+		return &itScim.Result[itScim.User]{Error: scimErrorFrom(updated.ClientErrors)}, nil
+	}
+	return this.GetUser(ctx, client, string(id))
+}
+
+// replaceSharedUser handles a change to a user who is also a member of another organization.
+// Its profile and status belong to every organization it is in, so the only change the client
+// can make is a deactivation, which takes the user out of the client's organization alone.
+func (this *ScimApplicationServiceImpl) replaceSharedUser(
+	ctx corectx.Context, client itScim.Client, found dmodel.DynamicFields, params dmodel.DynamicFields,
+) (*itScim.Result[itScim.User], error) {
+	deactivates := params[models.UserFieldStatus] == string(scimUserStatus(false))
+	if !deactivates || scimUserProfileChanged(found, params) {
+		return &itScim.Result[itScim.User]{Error: itScim.NewError(http.StatusForbidden, itScim.ErrTypeMutability,
+			"the user is also a member of another organization; only deactivating it is allowed")}, nil
+	}
+
+	id := *found.GetModelId(models.UserFieldId)
+	result, err := this.orgSvc.ManageOrgUsers(ctx, itOrg.ManageOrgUsersCommand{
+		OrgId:  client.OrgId,
+		Remove: ds.NewSetFrom(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ClientErrors.Count() > 0 {
+		return &itScim.Result[itScim.User]{Error: scimErrorFrom(result.ClientErrors)}, nil
+	}
+	// The user is now out of the client's sight, so it is answered as it was with active off.
+	user := toScimUser(found)
+	inactive := false
+	user.Active = &inactive
+	return &itScim.Result[itScim.User]{Data: &user}, nil
+}
+
+func scimUserProfileChanged(found dmodel.DynamicFields, params dmodel.DynamicFields) bool {
+	for _, field := range []string{models.UserFieldEmail, models.UserFieldDisplayName} {
+		stored := found.GetString(field)
+		if stored == nil || *stored != params[field] {
+			return true
+		}
+	}
+	return false
+}
+
+func scimUserScope(client itScim.Client) dmodel.SearchNode {
+	return *dmodel.NewSearchNode().NewCondition(models.UserEdgeOrgs, dmodel.Linked, string(client.OrgId))
+}
+
+func validateScimUser(user itScim.User) *itScim.Error {
+	if strings.TrimSpace(user.UserName) == "" {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, "userName is required")
+	}
+	return nil
+}
+
+// scimUserDisplayName picks the display name from the most explicit attribute the client
+// sent, falling back on the userName, since an IAM user must have one.
+func scimUserDisplayName(user itScim.User) string {
+	if user.DisplayName != nil && *user.DisplayName != "" {
+		return *user.DisplayName
+	}
+	if user.Name != nil {
+		if user.Name.Formatted != nil && *user.Name.Formatted != "" {
+			return *user.Name.Formatted
+		}
+		parts := []string{}
+		if user.Name.GivenName != nil && *user.Name.GivenName != "" {
+			parts = append(parts, *user.Name.GivenName)
+		}
+		if user.Name.FamilyName != nil && *user.Name.FamilyName != "" {
+			parts = append(parts, *user.Name.FamilyName)
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, " ")
+		}
+	}
+	return user.UserName
+}
+
+// scimUserStatus maps SCIM's active flag to a user status. A deactivated user is
+// suspended, which keeps it from signing in while preserving its record.
+func scimUserStatus(active bool) models.UserStatus {
+	if active {
+		return models.UserStatusActive
+	}
+	return models.UserStatusSuspended
+}
+
+func scimUserIsActive(fields dmodel.DynamicFields) bool {
+	status := fields.GetString(models.UserFieldStatus)
+	return status != nil && *status == string(models.UserStatusActive)
+}
+
+func toScimUser(fields dmodel.DynamicFields) itScim.User {
+	email := fields.GetString(models.UserFieldEmail)
+	displayName := fields.GetString(models.UserFieldDisplayName)
+	active := scimUserIsActive(fields)
+	primary := true
+	emailType := "work"
+
+	user := itScim.User{
+		Schemas:     []string{itScim.SchemaUser},
+		Id:          string(*fields.GetModelId(models.UserFieldId)),
+		DisplayName: displayName,
+		Active:      &active,
+		Meta:        toScimMeta(fields, itScim.ResourceTypeUser),
+	}
+	if displayName != nil {
+		user.Name = &itScim.Name{Formatted: displayName}
+	}
+	if email != nil {
+		user.UserName = *email
+		user.Emails = []itScim.Email{{Value: *email, Type: &emailType, Primary: &primary}}
+	}
+	return user
+}
+
+func resolveScimUserAttr(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	switch cmp.attr {
+	case "id":
+		return scimStringCondition(models.UserFieldId, cmp, negate)
+	case "username", "emails", "emails.value":
+		return scimStringCondition(models.UserFieldEmail, cmp, negate)
+	case "displayname", "name.formatted":
+		return scimStringCondition(models.UserFieldDisplayName, cmp, negate)
+	case "meta.created":
+		return scimStringCondition(basemodel.FieldCreatedAt, cmp, negate)
+	case "meta.lastmodified":
+		return scimStringCondition(basemodel.FieldUpdatedAt, cmp, negate)
+	case "active":
+		return resolveScimActiveAttr(cmp, negate)
+	}
+	return nil, scimUnfilterableAttr(cmp.attr)
+}
+
+// resolveScimActiveAttr filters on the status, of which active is a reading.
+func resolveScimActiveAttr(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	op := cmp.searchOperator(negate)
+	if op == dmodel.IsSet || op == dmodel.IsNotSet {
+		return dmodel.NewSearchNode().NewCondition(models.UserFieldStatus, op), nil
+	}
+	wanted, isBool := cmp.value.(bool)
+	if !isBool || (op != dmodel.Equals && op != dmodel.NotEquals) {
+		return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+			"active can only be compared for equality to true or false")
+	}
+	if (op == dmodel.Equals) != wanted {
+		return dmodel.NewSearchNode().NewCondition(
+			models.UserFieldStatus, dmodel.NotEquals, string(models.UserStatusActive)), nil
+	}
+	return dmodel.NewSearchNode().NewCondition(
+		models.UserFieldStatus, dmodel.Equals, string(models.UserStatusActive)), nil
+}
+
+// Groups
+
+func (this *ScimApplicationServiceImpl) ListGroups(
+	ctx corectx.Context, client itScim.Client, query itScim.ListQuery,
+) (*itScim.Result[itScim.ListResponse[itScim.Group]], error) {
+	filter, sErr := scimFilterNode(query.Filter, resolveScimGroupAttr)
+	if sErr != nil {
+		return &itScim.Result[itScim.ListResponse[itScim.Group]]{Error: sErr}, nil
+	}
+	list, sErr, err := this.list(ctx, scimListParam{
+		schemaName: models.GroupSchemaName,
+		fields:     scimGroupFields,
+		scope:      scimGroupScope(client),
+		filter:     filter,
+		query:      query,
+		language:   scimGroupLanguage,
+	})
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.ListResponse[itScim.Group]]{Error: sErr}, err
+	}
+	groups, err := this.toScimGroups(ctx, list.items)
+	if err != nil {
+		return nil, err
+	}
+	return &itScim.Result[itScim.ListResponse[itScim.Group]]{
+		Data: newScimListResponse(groups, list.total, list.startIndex),
+	}, nil
+}
+
+func (this *ScimApplicationServiceImpl) GetGroup(
+	ctx corectx.Context, client itScim.Client, id string,
+) (*itScim.Result[itScim.Group], error) {
+	found, sErr, err := this.findGroup(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+	groups, err := this.toScimGroups(ctx, []dmodel.DynamicFields{found})
+	if err != nil {
+		return nil, err
+	}
+	return &itScim.Result[itScim.Group]{Data: &groups[0]}, nil
+}
+
+// CreateGroup creates a group of the client's organization, owned by the user who
+// issued the token.
+func (this *ScimApplicationServiceImpl) CreateGroup(
+	ctx corectx.Context, client itScim.Client, group itScim.Group,
+) (*itScim.Result[itScim.Group], error) {
+	if sErr := validateScimGroup(group); sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, nil
+	}
+	memberIds, sErr, err := this.resolveMembers(ctx, client, group.Members)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+
+	created, err := executeScimAction(ctx, models.GroupSchemaName, drif.ActionCreate, dmodel.DynamicFields{
+		models.GroupFieldName:    model.LangJson{scimGroupLanguage: group.DisplayName},
+		models.GroupFieldOrgId:   string(client.OrgId),
+		models.GroupFieldOwnerId: string(client.OwnerId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created.ClientErrors.Count() > 0 {
+		return &itScim.Result[itScim.Group]{Error: scimErrorFrom(created.ClientErrors)}, nil
+	}
+	groupId := *created.Data.(dmodel.DynamicFields).GetModelId(models.GroupFieldId)
+
+	sErr, err = this.syncMembers(ctx, groupId, ds.NewSet[model.Id](), memberIds)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+	return this.GetGroup(ctx, client, string(groupId))
+}
+
+func (this *ScimApplicationServiceImpl) ReplaceGroup(
+	ctx corectx.Context, client itScim.Client, id string, group itScim.Group,
+) (*itScim.Result[itScim.Group], error) {
+	found, sErr, err := this.findGroup(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+	return this.replaceGroup(ctx, client, found, group)
+}
+
+func (this *ScimApplicationServiceImpl) PatchGroup(
+	ctx corectx.Context, client itScim.Client, id string, patch itScim.PatchRequest,
+) (*itScim.Result[itScim.Group], error) {
+	found, sErr, err := this.findGroup(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+	groups, err := this.toScimGroups(ctx, []dmodel.DynamicFields{found})
+	if err != nil {
+		return nil, err
+	}
+	group := groups[0]
+	for _, operation := range patch.Operations {
+		if sErr := applyScimGroupPatch(&group, operation); sErr != nil {
+			return &itScim.Result[itScim.Group]{Error: sErr}, nil
+		}
+	}
+	return this.replaceGroup(ctx, client, found, group)
+}
+
+func (this *ScimApplicationServiceImpl) DeleteGroup(
+	ctx corectx.Context, client itScim.Client, id string,
+) (*itScim.Result[struct{}], error) {
+	found, sErr, err := this.findGroup(ctx, client, id)
+	if err != nil || sErr != nil {
+		return &itScim.Result[struct{}]{Error: sErr}, err
+	}
+	result, err := executeScimAction(ctx, models.GroupSchemaName, drif.ActionDelete, dmodel.DynamicFields{
+		models.GroupFieldId: string(*found.GetModelId(models.GroupFieldId)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ClientErrors.Count() > 0 {
+		return &itScim.Result[struct{}]{Error: scimErrorFrom(result.ClientErrors)}, nil
+	}
+	return &itScim.Result[struct{}]{Data: &struct{}{}}, nil
+}
+
+func (this *ScimApplicationServiceImpl) findGroup(
+	ctx corectx.Context, client itScim.Client, id string,
+) (dmodel.DynamicFields, *itScim.Error, error) {
+	return this.findOne(ctx, models.GroupSchemaName, scimGroupFields, scimGroupScope(client), id, itScim.ResourceTypeGroup)
+}
+
+// replaceGroup renames the group when its display name changed, then brings its
+// membership to the one the client sent.
+func (this *ScimApplicationServiceImpl) replaceGroup(
+	ctx corectx.Context, client itScim.Client, found dmodel.DynamicFields, group itScim.Group,
+) (*itScim.Result[itScim.Group], error) {
+	if sErr := validateScimGroup(group); sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, nil
+	}
+	groupId := *found.GetModelId(models.GroupFieldId)
+	wanted, sErr, err := this.resolveMembers(ctx, client, group.Members)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+
+	if group.DisplayName != scimGroupDisplayName(found) {
+		updated, err := executeScimAction(ctx, models.GroupSchemaName, drif.ActionUpdate, dmodel.DynamicFields{
+			models.GroupFieldId:   string(groupId),
+			basemodel.FieldEtag:   string(*found.GetEtag(basemodel.FieldEtag)),
+			models.GroupFieldName: model.LangJson{scimGroupLanguage: group.DisplayName},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if updated.ClientErrors.Count() > 0 {
+			return &itScim.Result[itScim.Group]{Error: scimErrorFrom(updated.ClientErrors)}, nil
+		}
+	}
+
+	members, err := this.searchMembers(ctx, []model.Id{groupId})
+	if err != nil {
+		return nil, err
+	}
+	current := ds.NewSet[model.Id]()
+	for _, userId := range members[groupId] {
+		current.Add(userId)
+	}
+	sErr, err = this.syncMembers(ctx, groupId, current, wanted)
+	if err != nil || sErr != nil {
+		return &itScim.Result[itScim.Group]{Error: sErr}, err
+	}
+	return this.GetGroup(ctx, client, string(groupId))
+}
+
+// resolveMembers checks that every member is a user of the client's organization, which
+// also keeps the client from reaching users it cannot see.
+func (this *ScimApplicationServiceImpl) resolveMembers(
+	ctx corectx.Context, client itScim.Client, members []itScim.Member,
+) (ds.Set[model.Id], *itScim.Error, error) {
+	wanted := ds.NewSet[model.Id]()
+	values := []any{}
+	for _, member := range members {
+		if member.Value == "" {
+			return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, "a member must have a value"), nil
+		}
+		if !wanted.Contains(model.Id(member.Value)) {
+			wanted.Add(model.Id(member.Value))
+			values = append(values, member.Value)
+		}
+	}
+	if len(values) == 0 {
+		return wanted, nil, nil
+	}
+
+	found, err := searchScimRecords(ctx, models.UserSchemaName, dmodel.DynamicFields{
+		basemodel.FieldFields: []string{models.UserFieldId},
+		basemodel.FieldSize:   len(values),
+		basemodel.FieldGraph: dmodel.NewSearchGraph().And(
+			scimUserScope(client),
+			*dmodel.NewSearchNode().NewCondition(models.UserFieldId, dmodel.In, values...),
+		),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if found.ClientErrors.Count() > 0 {
+		return nil, scimErrorFrom(found.ClientErrors), nil
+	}
+	if found.Data.Total != len(values) {
+		return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidValue,
+			"some members are not users of this organization"), nil
+	}
+	return wanted, nil, nil
+}
+
+// syncMembers adds and removes membership rows one at a time, through the membership
+// engine, so that each goes through its permission check.
+func (this *ScimApplicationServiceImpl) syncMembers(
+	ctx corectx.Context, groupId model.Id, current ds.Set[model.Id], wanted ds.Set[model.Id],
+) (*itScim.Error, error) {
+	for _, userId := range wanted.ToSlice() {
+		if current.Contains(userId) {
+			continue
+		}
+		result, err := executeScimAction(ctx, models.GrpUsrRelSchemaName, drif.ActionCreate, dmodel.DynamicFields{
+			models.GrpUsrRelFieldGroupId: string(groupId),
+			models.GrpUsrRelFieldUserId:  string(userId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.ClientErrors.Count() > 0 {
+			return scimErrorFrom(result.ClientErrors), nil
+		}
+	}
+
+	removed := []any{}
+	for _, userId := range current.ToSlice() {
+		if !wanted.Contains(userId) {
+			removed = append(removed, string(userId))
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	rels, err := searchScimRecords(ctx, models.GrpUsrRelSchemaName, dmodel.DynamicFields{
+		basemodel.FieldFields: []string{models.GrpUsrRelFieldId},
+		basemodel.FieldSize:   len(removed),
+		basemodel.FieldGraph: dmodel.NewSearchGraph().And(
+			*dmodel.NewSearchNode().NewCondition(models.GrpUsrRelFieldGroupId, dmodel.Equals, string(groupId)),
+			*dmodel.NewSearchNode().NewCondition(models.GrpUsrRelFieldUserId, dmodel.In, removed...),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rels.ClientErrors.Count() > 0 {
+		return scimErrorFrom(rels.ClientErrors), nil
+	}
+	for _, rel := range rels.Data.Items {
+		result, err := executeScimAction(ctx, models.GrpUsrRelSchemaName, drif.ActionDelete, dmodel.DynamicFields{
+			models.GrpUsrRelFieldId: string(*rel.GetModelId(models.GrpUsrRelFieldId)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.ClientErrors.Count() > 0 {
+			return scimErrorFrom(result.ClientErrors), nil
+		}
+	}
+	return nil, nil
+}
+
+// searchMembers answers the member user ids of each group, reading the membership rows
+// page by page since a group may be larger than one page.
+func (this *ScimApplicationServiceImpl) searchMembers(
+	ctx corectx.Context, groupIds []model.Id,
+) (map[model.Id][]model.Id, error) {
+	members := map[model.Id][]model.Id{}
+	if len(groupIds) == 0 {
+		return members, nil
+	}
+	values := make([]any, 0, len(groupIds))
+	for _, groupId := range groupIds {
+		values = append(values, string(groupId))
+	}
+	for page := 0; ; page++ {
+		rels, err := searchScimRecords(ctx, models.GrpUsrRelSchemaName, dmodel.DynamicFields{
+			basemodel.FieldFields: []string{models.GrpUsrRelFieldGroupId, models.GrpUsrRelFieldUserId},
+			basemodel.FieldPage:   page,
+			basemodel.FieldSize:   itScim.MaxResults,
+			basemodel.FieldGraph: dmodel.NewSearchGraph().
+				NewCondition(models.GrpUsrRelFieldGroupId, dmodel.In, values...).
+				OrderBy(models.GrpUsrRelFieldId),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if rels.ClientErrors.Count() > 0 {
+			return nil, errors.Wrap(rels.ClientErrors.ToError(), "search group members")
+		}
+		for _, rel := range rels.Data.Items {
+			groupId := *rel.GetModelId(models.GrpUsrRelFieldGroupId)
+			members[groupId] = append(members[groupId], *rel.GetModelId(models.GrpUsrRelFieldUserId))
+		}
+		if (page+1)*itScim.MaxResults >= rels.Data.Total {
+			return members, nil
+		}
+	}
+}
+
+func (this *ScimApplicationServiceImpl) toScimGroups(
+	ctx corectx.Context, items []dmodel.DynamicFields,
+) ([]itScim.Group, error) {
+	groupIds := make([]model.Id, 0, len(items))
+	for _, item := range items {
+		groupIds = append(groupIds, *item.GetModelId(models.GroupFieldId))
+	}
+	members, err := this.searchMembers(ctx, groupIds)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]itScim.Group, 0, len(items))
+	for i, item := range items {
+		group := itScim.Group{
+			Schemas:     []string{itScim.SchemaGroup},
+			Id:          string(groupIds[i]),
+			DisplayName: scimGroupDisplayName(item),
+			Members:     []itScim.Member{},
+			Meta:        toScimMeta(item, itScim.ResourceTypeGroup),
+		}
+		for _, userId := range members[groupIds[i]] {
+			group.Members = append(group.Members, itScim.Member{Value: string(userId)})
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func scimGroupScope(client itScim.Client) dmodel.SearchNode {
+	return *dmodel.NewSearchNode().NewCondition(models.GroupFieldOrgId, dmodel.Equals, string(client.OrgId))
+}
+
+func validateScimGroup(group itScim.Group) *itScim.Error {
+	if strings.TrimSpace(group.DisplayName) == "" {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, "displayName is required")
+	}
+	return nil
+}
+
+// scimGroupDisplayName reads the group's name in the SCIM language, or in any language
+// for a group that was named from elsewhere without one.
+func scimGroupDisplayName(fields dmodel.DynamicFields) string {
+	name := fields.GetLangJson(models.GroupFieldName)
+	if name == nil {
+		return ""
+	}
+	if value, ok := (*name)[scimGroupLanguage]; ok {
+		return value
+	}
+	for _, value := range *name {
+		return value
+	}
+	return ""
+}
+
+func resolveScimGroupAttr(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	switch cmp.attr {
+	case "id":
+		return scimStringCondition(models.GroupFieldId, cmp, negate)
+	case "displayname":
+		return resolveScimGroupNameAttr(cmp, negate)
+	case "members", "members.value":
+		return resolveScimMembersAttr(cmp, negate)
+	case "meta.created":
+		return scimStringCondition(basemodel.FieldCreatedAt, cmp, negate)
+	case "meta.lastmodified":
+		return scimStringCondition(basemodel.FieldUpdatedAt, cmp, negate)
+	}
+	return nil, scimUnfilterableAttr(cmp.attr)
+}
+
+// resolveScimGroupNameAttr compares the name's SCIM language. Equality compares the
+// whole translatable value, which is what a group created through SCIM holds.
+func resolveScimGroupNameAttr(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	name, isString := cmp.value.(string)
+	op := cmp.searchOperator(negate)
+	if !isString || op == dmodel.IsSet || op == dmodel.IsNotSet {
+		return scimStringCondition(models.GroupFieldName, cmp, negate)
+	}
+	switch op {
+	case dmodel.Equals, dmodel.NotEquals:
+		return dmodel.NewSearchNode().NewCondition(
+			models.GroupFieldName, op, model.LangJson{scimGroupLanguage: name}), nil
+	case dmodel.Contains, dmodel.NotContains, dmodel.StartsWith,
+		dmodel.NotStartsWith, dmodel.EndsWith, dmodel.NotEndsWith:
+		return dmodel.NewSearchNode().NewCondition(models.GroupFieldName, op, name), nil
+	}
+	return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter, "displayName cannot be compared by order")
+}
+
+func resolveScimMembersAttr(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	userId, isString := cmp.value.(string)
+	switch op := cmp.searchOperator(negate); {
+	case isString && op == dmodel.Equals:
+		return dmodel.NewSearchNode().NewCondition(models.GroupEdgeUsers, dmodel.Linked, userId), nil
+	case isString && op == dmodel.NotEquals:
+		return dmodel.NewSearchNode().NewCondition(models.GroupEdgeUsers, dmodel.NotLinked, userId), nil
+	}
+	return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+		"members can only be compared for equality to a user id")
+}
+
+// Common
+
+type scimListParam struct {
+	schemaName string
+	fields     []string
+	scope      dmodel.SearchNode
+	filter     *dmodel.SearchNode
+	query      itScim.ListQuery
+	language   model.LanguageCode
+}
+
+type scimList struct {
+	items      []dmodel.DynamicFields
+	total      int
+	startIndex int
+}
+
+// list answers one SCIM page. SCIM pages by a 1-based start index, which needs not fall
+// on the boundary of an engine page: the window is then read from the two engine pages
+// it straddles.
+func (this *ScimApplicationServiceImpl) list(
+	ctx corectx.Context, param scimListParam,
+) (*scimList, *itScim.Error, error) {
+	startIndex := max(param.query.StartIndex, 1)
+	count := itScim.MaxResults
+	if param.query.Count != nil {
+		count = min(max(*param.query.Count, 0), itScim.MaxResults)
+	}
+
+	nodes := []dmodel.SearchNode{param.scope}
+	if param.filter != nil {
+		nodes = append(nodes, *param.filter)
+	}
+	// A count of zero asks for the total alone, which a one-record page answers.
+	size := max(count, 1)
+	offset := (startIndex - 1) % size
+	page := (startIndex - 1) / size
+
+	out := &scimList{items: []dmodel.DynamicFields{}, startIndex: startIndex}
+	for {
+		params := dmodel.DynamicFields{
+			basemodel.FieldFields: param.fields,
+			basemodel.FieldPage:   page,
+			basemodel.FieldSize:   size,
+			basemodel.FieldGraph:  dmodel.NewSearchGraph().And(nodes...).OrderBy(basemodel.FieldId),
+		}
+		if param.language != "" {
+			params[scimParamLanguage] = param.language
+		}
+		result, err := searchScimRecords(ctx, param.schemaName, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result.ClientErrors.Count() > 0 {
+			return nil, scimErrorFrom(result.ClientErrors), nil
+		}
+		out.total = result.Data.Total
+		out.items = append(out.items, result.Data.Items...)
+		if len(out.items) >= count+offset || len(result.Data.Items) < size {
+			break
+		}
+		page++
+	}
+
+	if offset >= len(out.items) {
+		out.items = []dmodel.DynamicFields{}
+	} else {
+		out.items = out.items[offset:min(offset+count, len(out.items))]
+	}
+	return out, nil, nil
+}
+
+// findOne reads one record by id, within the client's scope.
+func (this *ScimApplicationServiceImpl) findOne(
+	ctx corectx.Context, schemaName string, fields []string, scope dmodel.SearchNode, id string, resourceType string,
+) (dmodel.DynamicFields, *itScim.Error, error) {
+	result, err := searchScimRecords(ctx, schemaName, dmodel.DynamicFields{
+		basemodel.FieldFields: fields,
+		basemodel.FieldSize:   1,
+		basemodel.FieldGraph: dmodel.NewSearchGraph().And(
+			scope,
+			*dmodel.NewSearchNode().NewCondition(basemodel.FieldId, dmodel.Equals, id),
+		),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.ClientErrors.Count() > 0 {
+		return nil, scimErrorFrom(result.ClientErrors), nil
+	}
+	if len(result.Data.Items) == 0 {
+		return nil, itScim.NewNotFoundError(resourceType, id), nil
+	}
+	return result.Data.Items[0], nil, nil
+}
+
+func executeScimAction(
+	ctx corectx.Context, schemaName string, actionName string, params dmodel.DynamicFields,
+) (*drif.ActionResult, error) {
+	engine, err := scimEngineFor(schemaName)
+	if err != nil {
+		return nil, err
+	}
+	return engine.ExecuteAction(ctx, actionName, params)
+}
+
+func searchScimRecords(
+	ctx corectx.Context, schemaName string, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	result, err := executeScimAction(ctx, schemaName, drif.ActionSearch, params)
+	if err != nil {
+		return nil, err
+	}
+	out := &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+		ClientErrors: result.ClientErrors,
+		HasData:      result.HasData,
+	}
+	if result.HasData {
+		out.Data = result.Data.(dyn.PagedResultData[dmodel.DynamicFields])
+	}
+	return out, nil
+}
+
+func scimFilterNode(filter *string, resolve scimAttrResolver) (*dmodel.SearchNode, *itScim.Error) {
+	if filter == nil || strings.TrimSpace(*filter) == "" {
+		return nil, nil
+	}
+	parsed, sErr := parseScimFilter(*filter)
+	if sErr != nil {
+		return nil, sErr
+	}
+	return parsed.toSearchNode(resolve, false)
+}
+
+// scimStringCondition compares a field to a string, or tests its presence.
+func scimStringCondition(field string, cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	if _, isString := cmp.value.(string); !isString && cmp.value != nil {
+		return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+			"attribute '"+cmp.attr+"' can only be compared to a string")
+	}
+	return scimFieldCondition(field, cmp, negate)
+}
+
+func newScimListResponse[T any](resources []T, total int, startIndex int) *itScim.ListResponse[T] {
+	return &itScim.ListResponse[T]{
+		Schemas:      []string{itScim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// toScimMeta fills all of meta but its location, which only the transport knows.
+func toScimMeta(fields dmodel.DynamicFields, resourceType string) *itScim.Meta {
+	meta := &itScim.Meta{ResourceType: resourceType}
+	if created := fields.GetModelDateTime(basemodel.FieldCreatedAt); created != nil {
+		value := created.GoTime().UTC().Format(time.RFC3339)
+		meta.Created = &value
+	}
+	if updated := fields.GetModelDateTime(basemodel.FieldUpdatedAt); updated != nil {
+		value := updated.GoTime().UTC().Format(time.RFC3339)
+		meta.LastModified = &value
+	} else {
+		meta.LastModified = meta.Created
+	}
+	if etag := fields.GetEtag(basemodel.FieldEtag); etag != nil {
+		meta.Version = `W/"` + string(*etag) + `"`
+	}
+	return meta
+}
+
+// scimErrorFrom answers the engines' client errors in SCIM's terms. Only a few of them
+// have a status of their own; the rest are the client sending an invalid value.
+func scimErrorFrom(cErrs ft.ClientErrors) *itScim.Error {
+	details := make([]string, 0, len(cErrs))
+	for _, item := range cErrs {
+		switch {
+		case ft.IsAuthorizationError(item):
+			return itScim.NewError(http.StatusForbidden, "", item.String())
+		case item.Key == "common.err_unique_constraint_violated":
+			return itScim.NewError(http.StatusConflict, itScim.ErrTypeUniqueness, item.String())
+		case item.Key == ft.ErrorKey("err_not_found"):
+			return itScim.NewError(http.StatusNotFound, "", item.String())
+		case item.Key == ft.ErrorKey("err_etag_mismatched"):
+			return itScim.NewError(http.StatusPreconditionFailed, "", item.String())
+		}
+		if item.Field != "" {
+			details = append(details, item.Field+": "+item.String())
+		} else {
+			details = append(details, item.String())
+		}
+	}
+	return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, strings.Join(details, "; "))
+}
@@ -0,0 +1,402 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ds "github.com/sky-as-code/nikki-erp/common/datastructure"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itOrg "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/organization"
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+// A SCIM user or group is stored as an IAM user or group whose fields are named and shaped
+// differently: userName is the email, active is the status, a group's displayName is a
+// translatable name and its members are membership rows. Each is written through SCIM here
+// and read back, over in-memory engines, to check nothing is lost on the way.
+
+var testScimClient = itScim.Client{
+	TokenId: "01SCIMTOKEN000000000000000",
+	OrgId:   "01SCIMORG00000000000000000",
+	OwnerId: "01SCIMOWNER000000000000000",
+}
+
+// stubScimEngine keeps one schema's records in the order they were created. Its search
+// answers the equality and membership conditions on the records' own fields; a condition
+// on anything else, such as an edge to the organization, is taken as met.
+type stubScimEngine struct {
+	drif.DynamicResourceEngine
+
+	prefix  string
+	records []dmodel.DynamicFields
+}
+
+func (this *stubScimEngine) ExecuteAction(
+	_ corectx.Context, actionName string, params dmodel.DynamicFields,
+) (*drif.ActionResult, error) {
+	switch actionName {
+	case drif.ActionCreate:
+		record := this.insert(params)
+		return &drif.ActionResult{Data: copyScimRecord(record), HasData: true}, nil
+	case drif.ActionUpdate:
+		record := this.find(params[basemodel.FieldId])
+		for key, value := range params {
+			if key != basemodel.FieldEtag {
+				record[key] = value
+			}
+		}
+		record[basemodel.FieldEtag] = model.Etag(fmt.Sprintf("etag-%06d", len(this.records)+1))
+		return &drif.ActionResult{HasData: true}, nil
+	case drif.ActionDelete:
+		for i, record := range this.records {
+			if record[basemodel.FieldId] == params[basemodel.FieldId] {
+				this.records = append(this.records[:i], this.records[i+1:]...)
+				break
+			}
+		}
+		return &drif.ActionResult{HasData: true}, nil
+	case drif.ActionSearch:
+		return &drif.ActionResult{Data: this.search(params), HasData: true}, nil
+	}
+	return nil, fmt.Errorf("unexpected action '%s'", actionName)
+}
+
+func (this *stubScimEngine) insert(fields dmodel.DynamicFields) dmodel.DynamicFields {
+	record := copyScimRecord(fields)
+	record[basemodel.FieldId] = fmt.Sprintf("%s-%d", this.prefix, len(this.records)+1)
+	record[basemodel.FieldEtag] = model.Etag("etag-000001")
+	this.records = append(this.records, record)
+	return record
+}
+
+func (this *stubScimEngine) find(id any) dmodel.DynamicFields {
+	for _, record := range this.records {
+		if record[basemodel.FieldId] == id {
+			return record
+		}
+	}
+	return dmodel.DynamicFields{}
+}
+
+func (this *stubScimEngine) search(params dmodel.DynamicFields) dyn.PagedResultData[dmodel.DynamicFields] {
+	graph := params[basemodel.FieldGraph].(*dmodel.SearchGraph)
+	page, _ := params[basemodel.FieldPage].(int)
+	size := params[basemodel.FieldSize].(int)
+
+	matched := []dmodel.DynamicFields{}
+	for _, record := range this.records {
+		if matchesScimNode(record, graph.ToSearchNode()) {
+			matched = append(matched, copyScimRecord(record))
+		}
+	}
+	items := matched[min(page*size, len(matched)):min((page+1)*size, len(matched))]
+	return dyn.PagedResultData[dmodel.DynamicFields]{Items: items, Total: len(matched), Page: page, Size: size}
+}
+
+func matchesScimNode(record dmodel.DynamicFields, node *dmodel.SearchNode) bool {
+	for _, operand := range node.GetAnd() {
+		if !matchesScimNode(record, &operand) {
+			return false
+		}
+	}
+	if or := node.GetOr(); len(or) > 0 {
+		anyMatched := false
+		for _, operand := range or {
+			anyMatched = anyMatched || matchesScimNode(record, &operand)
+		}
+		if !anyMatched {
+			return false
+		}
+	}
+	condition := node.GetCondition()
+	value, isField := record[condition.Field()]
+	if len(condition) == 0 || !isField {
+		return true
+	}
+	switch condition.Operator() {
+	case dmodel.Equals:
+		return fmt.Sprint(value) == fmt.Sprint(condition.Value())
+	case dmodel.In:
+		for _, candidate := range condition.Values() {
+			if fmt.Sprint(value) == fmt.Sprint(candidate) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func copyScimRecord(fields dmodel.DynamicFields) dmodel.DynamicFields {
+	record := dmodel.DynamicFields{}
+	for key, value := range fields {
+		record[key] = value
+	}
+	return record
+}
+
+type stubScimOrgService struct {
+	itOrg.OrganizationDomainService
+
+	added   []model.Id
+	removed []model.Id
+	// sharedUsers are members of another organization as well as the client's.
+	sharedUsers []string
+}
+
+func (this *stubScimOrgService) ManageOrgUsers(
+	_ corectx.Context, cmd itOrg.ManageOrgUsersCommand,
+) (*itOrg.ManageOrgUsersResult, error) {
+	if cmd.Add != nil {
+		this.added = append(this.added, cmd.Add.ToSlice()...)
+	}
+	if cmd.Remove != nil {
+		this.removed = append(this.removed, cmd.Remove.ToSlice()...)
+	}
+	return &itOrg.ManageOrgUsersResult{HasData: true}, nil
+}
+
+// SearchOrgs answers the search for another organization of a user, the only one SCIM makes.
+func (this *stubScimOrgService) SearchOrgs(
+	_ corectx.Context, query itOrg.SearchOrgsQuery, _ ...corecrud.ServiceSearchOptions,
+) (*itOrg.SearchOrgsResult, error) {
+	userId := query.Graph.ToSearchNode().GetAnd()[0].GetCondition().Value()
+	total := 0
+	if slices.Contains(this.sharedUsers, fmt.Sprint(userId)) {
+		total = 1
+	}
+	return &itOrg.SearchOrgsResult{Data: itOrg.SearchOrgsResultData{Total: total}, HasData: true}, nil
+}
+
+type scimFixture struct {
+	svc    itScim.ScimAppService
+	orgSvc *stubScimOrgService
+	users  *stubScimEngine
+	groups *stubScimEngine
+	rels   *stubScimEngine
+}
+
+func newScimFixture(t *testing.T) scimFixture {
+	t.Helper()
+	fixture := scimFixture{
+		orgSvc: &stubScimOrgService{},
+		users:  &stubScimEngine{prefix: "user"},
+		groups: &stubScimEngine{prefix: "group"},
+		rels:   &stubScimEngine{prefix: "rel"},
+	}
+	fixture.svc = NewScimApplicationServiceImpl(fixture.orgSvc, nil)
+
+	engines := map[string]*stubScimEngine{
+		models.UserSchemaName:      fixture.users,
+		models.GroupSchemaName:     fixture.groups,
+		models.GrpUsrRelSchemaName: fixture.rels,
+	}
+	original := scimEngineFor
+	scimEngineFor = func(schemaName string) (drif.DynamicResourceEngine, error) {
+		return engines[schemaName], nil
+	}
+	t.Cleanup(func() { scimEngineFor = original })
+	return fixture
+}
+
+func scimContext() corectx.Context {
+	return corectx.NewRequestContext(context.Background())
+}
+
+func TestScimUserRoundTrip(t *testing.T) {
+	fixture := newScimFixture(t)
+	inactive := false
+	sent := itScim.User{
+		UserName: "bjensen@example.com",
+		Name:     &itScim.Name{GivenName: scimText("Barbara"), FamilyName: scimText("Jensen")},
+		Emails:   []itScim.Email{{Value: "bjensen@example.com", Type: scimText("work")}},
+		Active:   &inactive,
+	}
+
+	created, err := fixture.svc.CreateUser(scimContext(), testScimClient, sent)
+	require.NoError(t, err)
+	require.Nil(t, created.Error)
+
+	stored := fixture.users.records[0]
+	assert.Equal(t, "bjensen@example.com", stored[models.UserFieldEmail])
+	assert.Equal(t, "Barbara Jensen", stored[models.UserFieldDisplayName])
+	assert.Equal(t, string(models.UserStatusSuspended), stored[models.UserFieldStatus])
+	assert.Equal(t, []model.Id{"user-1"}, fixture.orgSvc.added, "the user joins the client's organization")
+
+	read, err := fixture.svc.GetUser(scimContext(), testScimClient, created.Data.Id)
+	require.NoError(t, err)
+	require.Nil(t, read.Error)
+	user := *read.Data
+	assert.Equal(t, "user-1", user.Id)
+	assert.Equal(t, "bjensen@example.com", user.UserName)
+	assert.Equal(t, "Barbara Jensen", *user.DisplayName)
+	assert.Equal(t, "Barbara Jensen", *user.Name.Formatted)
+	assert.False(t, *user.Active)
+	require.Len(t, user.Emails, 1)
+	assert.Equal(t, "bjensen@example.com", user.Emails[0].Value)
+	assert.True(t, *user.Emails[0].Primary)
+
+	// What was read back, sent back, changes nothing.
+	replaced, err := fixture.svc.ReplaceUser(scimContext(), testScimClient, user.Id, user)
+	require.NoError(t, err)
+	require.Nil(t, replaced.Error)
+	assert.Equal(t, user.UserName, replaced.Data.UserName)
+	assert.Equal(t, *user.DisplayName, *replaced.Data.DisplayName)
+	assert.Equal(t, *user.Active, *replaced.Data.Active)
+
+	patched, err := fixture.svc.PatchUser(scimContext(), testScimClient, user.Id, itScim.PatchRequest{
+		Operations: []itScim.PatchOperation{
+			{Op: "replace", Path: scimPath("displayName"), Value: "Babs Jensen"},
+			{Op: "replace", Path: scimPath("active"), Value: true},
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, patched.Error)
+	assert.Equal(t, "Babs Jensen", *patched.Data.DisplayName)
+	assert.True(t, *patched.Data.Active)
+	assert.Equal(t, string(models.UserStatusActive), fixture.users.records[0][models.UserFieldStatus])
+}
+
+// A user who is also in another organization is not the client's to rename or suspend there.
+// Deactivating it takes it out of the client's organization, as deleting it does.
+func TestScimReplaceUserLeavesAUserSharedWithAnotherOrganization(t *testing.T) {
+	fixture := newScimFixture(t)
+	fixture.seedUsers("shared@example.com")
+	fixture.users.records[0][models.UserFieldDisplayName] = "Shared User"
+	fixture.orgSvc.sharedUsers = []string{"user-1"}
+
+	renamed, err := fixture.svc.PatchUser(scimContext(), testScimClient, "user-1", itScim.PatchRequest{
+		Operations: []itScim.PatchOperation{{Op: "replace", Path: scimPath("displayName"), Value: "Renamed"}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, renamed.Error)
+	assert.Equal(t, "403", renamed.Error.Status)
+	assert.Equal(t, "Shared User", fixture.users.records[0][models.UserFieldDisplayName])
+
+	deactivated, err := fixture.svc.PatchUser(scimContext(), testScimClient, "user-1", itScim.PatchRequest{
+		Operations: []itScim.PatchOperation{{Op: "replace", Path: scimPath("active"), Value: false}},
+	})
+	require.NoError(t, err)
+	require.Nil(t, deactivated.Error)
+	assert.False(t, *deactivated.Data.Active)
+	assert.Equal(t, []model.Id{"user-1"}, fixture.orgSvc.removed, "only the membership is removed")
+	assert.Equal(t, string(models.UserStatusActive), fixture.users.records[0][models.UserFieldStatus])
+}
+
+// A token is granted what its owner holds in the token's organization, widened to the domain
+// only because the engines cannot tell one organization's records from another's.
+func TestScimClientIsGrantedNoMoreThanItsOwner(t *testing.T) {
+	otherOrg := model.Id("01SCIMOTHERORG000000000000")
+	client := testScimClient
+	client.Owner = corectx.ContextPermissions{
+		Entitlements: ds.NewSetFrom(
+			reguard.BuildExpression(drif.PermissionRead, models.UserSchemaName, reguard.ResourceScopeOrg, nil),
+			reguard.BuildExpression(reguard.Wildcard, models.GroupSchemaName, reguard.ResourceScopeOrg, &otherOrg),
+		),
+		UserOrgIds: ds.NewSetFrom(client.OrgId),
+	}
+
+	granted := client.Permissions().Entitlements
+
+	assert.ElementsMatch(t, []string{
+		reguard.BuildExpression(drif.PermissionRead, models.UserSchemaName, reguard.ResourceScopeDomain, nil),
+	}, granted.ToSlice(), "nothing the owner lacks in the organization, and nothing from another")
+
+	client.Owner = corectx.ContextPermissions{IsOwner: true}
+	assert.Len(t, client.Permissions().Entitlements.ToSlice(), 12, "every provisioning permission")
+}
+
+func TestScimGetUserAnswersAnUnknownIdAsNotFound(t *testing.T) {
+	fixture := newScimFixture(t)
+
+	result, err := fixture.svc.GetUser(scimContext(), testScimClient, "user-9")
+
+	require.NoError(t, err)
+	require.NotNil(t, result.Error)
+	assert.Equal(t, "404", result.Error.Status)
+}
+
+func (this scimFixture) seedUsers(emails ...string) {
+	for _, email := range emails {
+		this.users.insert(dmodel.DynamicFields{
+			models.UserFieldEmail:  email,
+			models.UserFieldStatus: string(models.UserStatusActive),
+		})
+	}
+}
+
+func (this scimFixture) storedMemberIds() []string {
+	memberIds := []string{}
+	for _, rel := range this.rels.records {
+		memberIds = append(memberIds, rel[models.GrpUsrRelFieldUserId].(string))
+	}
+	return memberIds
+}
+
+func TestScimGroupRoundTrip(t *testing.T) {
+	fixture := newScimFixture(t)
+	fixture.seedUsers("a@example.com", "b@example.com")
+
+	created, err := fixture.svc.CreateGroup(scimContext(), testScimClient, itScim.Group{
+		DisplayName: "Ops",
+		Members:     []itScim.Member{{Value: "user-1"}, {Value: "user-2"}, {Value: "user-1"}},
+	})
+	require.NoError(t, err)
+	require.Nil(t, created.Error)
+
+	stored := fixture.groups.records[0]
+	assert.Equal(t, model.LangJson{scimGroupLanguage: "Ops"}, stored[models.GroupFieldName])
+	assert.Equal(t, string(testScimClient.OrgId), stored[models.GroupFieldOrgId])
+	assert.Equal(t, string(testScimClient.OwnerId), stored[models.GroupFieldOwnerId])
+	assert.ElementsMatch(t, []string{"user-1", "user-2"}, fixture.storedMemberIds())
+
+	read, err := fixture.svc.GetGroup(scimContext(), testScimClient, created.Data.Id)
+	require.NoError(t, err)
+	require.Nil(t, read.Error)
+	group := *read.Data
+	assert.Equal(t, "group-1", group.Id)
+	assert.Equal(t, "Ops", group.DisplayName)
+	assert.ElementsMatch(t, []string{"user-1", "user-2"}, scimMemberIds(group))
+
+	patched, err := fixture.svc.PatchGroup(scimContext(), testScimClient, group.Id, itScim.PatchRequest{
+		Operations: []itScim.PatchOperation{
+			{Op: "replace", Path: scimPath("displayName"), Value: "Operations"},
+			{Op: "remove", Path: scimPath(`members[value eq "user-1"]`)},
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, patched.Error)
+	assert.Equal(t, "Operations", patched.Data.DisplayName)
+	assert.Equal(t, []string{"user-2"}, scimMemberIds(*patched.Data))
+	assert.Equal(t, []string{"user-2"}, fixture.storedMemberIds())
+	assert.Equal(t, model.LangJson{scimGroupLanguage: "Operations"}, fixture.groups.records[0][models.GroupFieldName])
+}
+
+func TestScimCreateGroupRefusesAMemberOutsideTheOrganization(t *testing.T) {
+	fixture := newScimFixture(t)
+	fixture.seedUsers("a@example.com")
+
+	result, err := fixture.svc.CreateGroup(scimContext(), testScimClient, itScim.Group{
+		DisplayName: "Ops",
+		Members:     []itScim.Member{{Value: "user-1"}, {Value: "user-9"}},
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, result.Error)
+	assert.Equal(t, itScim.ErrTypeInvalidValue, result.Error.ScimType)
+	assert.Empty(t, fixture.groups.records, "nothing is created")
+	assert.Empty(t, fixture.rels.records)
+}
@@ -0,0 +1,393 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+// scimFilter is a parsed SCIM filter, RFC 7644 section 3.4.2.2.
+//
+// A node is either a logical one (and, or, not) over its operands, or a comparison of
+// one attribute. Attribute names are case-insensitive in SCIM, so they are kept
+// lower-cased; the sub-attributes of a value path, as in emails[value eq "x"], are kept
+// joined to their parent, as "emails.value".
+type scimFilter struct {
+	logic    string
+	operands []*scimFilter
+
+	attr  string
+	op    string
+	value any
+}
+
+const (
+	scimLogicAnd = "and"
+	scimLogicOr  = "or"
+	scimLogicNot = "not"
+
+	scimOpPresent = "pr"
+)
+
+// scimCompareOps maps each SCIM comparison to the search operator it becomes.
+var scimCompareOps = map[string]dmodel.Operator{
+	"eq":          dmodel.Equals,
+	"ne":          dmodel.NotEquals,
+	"co":          dmodel.Contains,
+	"sw":          dmodel.StartsWith,
+	"ew":          dmodel.EndsWith,
+	"gt":          dmodel.GreaterThan,
+	"ge":          dmodel.GreaterEqual,
+	"lt":          dmodel.LessThan,
+	"le":          dmodel.LessEqual,
+	scimOpPresent: dmodel.IsSet,
+}
+
+// scimNegatedOps is how "not" is pushed down to the comparisons: the search graph has
+// no negation node of its own.
+var scimNegatedOps = map[dmodel.Operator]dmodel.Operator{
+	dmodel.Equals:       dmodel.NotEquals,
+	dmodel.NotEquals:    dmodel.Equals,
+	dmodel.Contains:     dmodel.NotContains,
+	dmodel.StartsWith:   dmodel.NotStartsWith,
+	dmodel.EndsWith:     dmodel.NotEndsWith,
+	dmodel.GreaterThan:  dmodel.LessEqual,
+	dmodel.GreaterEqual: dmodel.LessThan,
+	dmodel.LessThan:     dmodel.GreaterEqual,
+	dmodel.LessEqual:    dmodel.GreaterThan,
+	dmodel.IsSet:        dmodel.IsNotSet,
+	dmodel.Linked:       dmodel.NotLinked,
+}
+
+// scimAttrResolver turns one comparison into a search node over a resource's fields,
+// negated when asked. It decides which attributes can be filtered on.
+type scimAttrResolver func(cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error)
+
+// toSearchNode translates the filter. Negation is carried down by De Morgan's laws, so
+// that only the comparisons themselves are ever negated.
+func (this *scimFilter) toSearchNode(resolve scimAttrResolver, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	switch this.logic {
+	case scimLogicNot:
+		return this.operands[0].toSearchNode(resolve, !negate)
+	case scimLogicAnd, scimLogicOr:
+		nodes := make([]dmodel.SearchNode, 0, len(this.operands))
+		for _, operand := range this.operands {
+			node, sErr := operand.toSearchNode(resolve, negate)
+			if sErr != nil {
+				return nil, sErr
+			}
+			nodes = append(nodes, *node)
+		}
+		if (this.logic == scimLogicAnd) != negate {
+			return dmodel.NewSearchNode().And(nodes...), nil
+		}
+		return dmodel.NewSearchNode().Or(nodes...), nil
+	}
+	return resolve(this, negate)
+}
+
+// searchOperator is the operator the comparison becomes. Comparing to null is read as
+// the attribute's absence, which is what "eq null" means to a client.
+func (this *scimFilter) searchOperator(negate bool) dmodel.Operator {
+	op := scimCompareOps[this.op]
+	if this.value == nil && this.op != scimOpPresent {
+		if op == dmodel.Equals {
+			op = dmodel.IsNotSet
+		} else {
+			op = dmodel.IsSet
+		}
+	}
+	if !negate {
+		return op
+	}
+	if negated, ok := scimNegatedOps[op]; ok {
+		return negated
+	}
+	if op == dmodel.IsNotSet {
+		return dmodel.IsSet
+	}
+	return op
+}
+
+// scimFieldCondition compares a model field as the filter compares the attribute.
+func scimFieldCondition(field string, cmp *scimFilter, negate bool) (*dmodel.SearchNode, *itScim.Error) {
+	op := cmp.searchOperator(negate)
+	if op == dmodel.IsSet || op == dmodel.IsNotSet {
+		return dmodel.NewSearchNode().NewCondition(field, op), nil
+	}
+	return dmodel.NewSearchNode().NewCondition(field, op, cmp.value), nil
+}
+
+func scimUnfilterableAttr(attr string) *itScim.Error {
+	return itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+		fmt.Sprintf("attribute '%s' is not supported in a filter", attr))
+}
+
+// parseScimFilter parses the filter query parameter.
+func parseScimFilter(input string) (*scimFilter, *itScim.Error) {
+	tokens, sErr := lexScimFilter(input)
+	if sErr != nil {
+		return nil, sErr
+	}
+	parser := &scimFilterParser{tokens: tokens}
+	filter, sErr := parser.parseOr()
+	if sErr != nil {
+		return nil, sErr
+	}
+	if parser.peek().kind != scimTokenEnd {
+		return nil, parser.unexpected()
+	}
+	return filter, nil
+}
+
+type scimTokenKind int
+
+const (
+	scimTokenEnd scimTokenKind = iota
+	scimTokenWord
+	scimTokenString
+	scimTokenOpenParen
+	scimTokenCloseParen
+	scimTokenOpenBracket
+	scimTokenCloseBracket
+)
+
+type scimToken struct {
+	kind scimTokenKind
+	text string
+}
+
+func lexScimFilter(input string) ([]scimToken, *itScim.Error) {
+	tokens := []scimToken{}
+	for pos := 0; pos < len(input); {
+		char := input[pos]
+		switch {
+		case char == ' ' || char == '\t':
+			pos++
+		case char == '(':
+			tokens = append(tokens, scimToken{kind: scimTokenOpenParen, text: "("})
+			pos++
+		case char == ')':
+			tokens = append(tokens, scimToken{kind: scimTokenCloseParen, text: ")"})
+			pos++
+		case char == '[':
+			tokens = append(tokens, scimToken{kind: scimTokenOpenBracket, text: "["})
+			pos++
+		case char == ']':
+			tokens = append(tokens, scimToken{kind: scimTokenCloseBracket, text: "]"})
+			pos++
+		case char == '"':
+			end := pos + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter, "unterminated string in filter")
+			}
+			// Filter strings are JSON strings, escapes included.
+			var text string
+			if err := json.Unmarshal([]byte(input[pos:end+1]), &text); err != nil {
+				return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter, "malformed string in filter")
+			}
+			tokens = append(tokens, scimToken{kind: scimTokenString, text: text})
+			pos = end + 1
+		default:
+			end := pos
+			for end < len(input) && !strings.ContainsRune(" \t()[]\"", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, scimToken{kind: scimTokenWord, text: input[pos:end]})
+			pos = end
+		}
+	}
+	return append(tokens, scimToken{kind: scimTokenEnd}), nil
+}
+
+type scimFilterParser struct {
+	tokens []scimToken
+	pos    int
+}
+
+func (this *scimFilterParser) peek() scimToken {
+	return this.tokens[this.pos]
+}
+
+func (this *scimFilterParser) next() scimToken {
+	token := this.tokens[this.pos]
+	if token.kind != scimTokenEnd {
+		this.pos++
+	}
+	return token
+}
+
+func (this *scimFilterParser) peekKeyword(keyword string) bool {
+	token := this.peek()
+	return token.kind == scimTokenWord && strings.EqualFold(token.text, keyword)
+}
+
+func (this *scimFilterParser) expect(kind scimTokenKind) *itScim.Error {
+	if this.peek().kind != kind {
+		return this.unexpected()
+	}
+	this.next()
+	return nil
+}
+
+func (this *scimFilterParser) unexpected() *itScim.Error {
+	token := this.peek()
+	if token.kind == scimTokenEnd {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter, "filter ends unexpectedly")
+	}
+	return itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+		fmt.Sprintf("unexpected '%s' in filter", token.text))
+}
+
+func (this *scimFilterParser) parseOr() (*scimFilter, *itScim.Error) {
+	return this.parseLogic(scimLogicOr, this.parseAnd)
+}
+
+func (this *scimFilterParser) parseAnd() (*scimFilter, *itScim.Error) {
+	return this.parseLogic(scimLogicAnd, this.parseUnary)
+}
+
+// parseLogic parses a left-associative chain of one logical operator, "and" binding
+// tighter than "or" by the order parseOr and parseAnd call each other in.
+func (this *scimFilterParser) parseLogic(
+	logic string, parseOperand func() (*scimFilter, *itScim.Error),
+) (*scimFilter, *itScim.Error) {
+	left, sErr := parseOperand()
+	if sErr != nil {
+		return nil, sErr
+	}
+	for this.peekKeyword(logic) {
+		this.next()
+		right, sErr := parseOperand()
+		if sErr != nil {
+			return nil, sErr
+		}
+		left = &scimFilter{logic: logic, operands: []*scimFilter{left, right}}
+	}
+	return left, nil
+}
+
+func (this *scimFilterParser) parseUnary() (*scimFilter, *itScim.Error) {
+	if this.peekKeyword(scimLogicNot) {
+		this.next()
+		inner, sErr := this.parseGroup()
+		if sErr != nil {
+			return nil, sErr
+		}
+		return &scimFilter{logic: scimLogicNot, operands: []*scimFilter{inner}}, nil
+	}
+	if this.peek().kind == scimTokenOpenParen {
+		return this.parseGroup()
+	}
+	return this.parseAttrExpr()
+}
+
+func (this *scimFilterParser) parseGroup() (*scimFilter, *itScim.Error) {
+	if sErr := this.expect(scimTokenOpenParen); sErr != nil {
+		return nil, sErr
+	}
+	inner, sErr := this.parseOr()
+	if sErr != nil {
+		return nil, sErr
+	}
+	if sErr := this.expect(scimTokenCloseParen); sErr != nil {
+		return nil, sErr
+	}
+	return inner, nil
+}
+
+func (this *scimFilterParser) parseAttrExpr() (*scimFilter, *itScim.Error) {
+	token := this.peek()
+	if token.kind != scimTokenWord {
+		return nil, this.unexpected()
+	}
+	this.next()
+	attr := normalizeScimAttr(token.text)
+
+	if this.peek().kind == scimTokenOpenBracket {
+		this.next()
+		inner, sErr := this.parseOr()
+		if sErr != nil {
+			return nil, sErr
+		}
+		if sErr := this.expect(scimTokenCloseBracket); sErr != nil {
+			return nil, sErr
+		}
+		inner.prefixAttr(attr)
+		return inner, nil
+	}
+
+	opToken := this.peek()
+	op := strings.ToLower(opToken.text)
+	if _, ok := scimCompareOps[op]; opToken.kind != scimTokenWord || !ok {
+		return nil, this.unexpected()
+	}
+	this.next()
+	if op == scimOpPresent {
+		return &scimFilter{attr: attr, op: op}, nil
+	}
+
+	value, sErr := this.parseValue()
+	if sErr != nil {
+		return nil, sErr
+	}
+	return &scimFilter{attr: attr, op: op, value: value}, nil
+}
+
+func (this *scimFilterParser) parseValue() (any, *itScim.Error) {
+	token := this.peek()
+	switch token.kind {
+	case scimTokenString:
+		this.next()
+		return token.text, nil
+	case scimTokenWord:
+		this.next()
+		switch strings.ToLower(token.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if number, err := strconv.ParseFloat(token.text, 64); err == nil {
+			return number, nil
+		}
+		return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidFilter,
+			fmt.Sprintf("'%s' is not a valid value in a filter", token.text))
+	}
+	return nil, this.unexpected()
+}
+
+// prefixAttr makes the attributes of a value path's filter relative to its parent.
+func (this *scimFilter) prefixAttr(parent string) {
+	if this.logic != "" {
+		for _, operand := range this.operands {
+			operand.prefixAttr(parent)
+		}
+		return
+	}
+	this.attr = parent + "." + this.attr
+}
+
+// normalizeScimAttr lower-cases an attribute path and drops its schema URN prefix, as in
+// "urn:ietf:params:scim:schemas:core:2.0:User:userName".
+func normalizeScimAttr(path string) string {
+	lower := strings.ToLower(path)
+	if strings.HasPrefix(lower, "urn:") {
+		if pos := strings.LastIndex(lower, ":"); pos >= 0 {
+			lower = lower[pos+1:]
+		}
+	}
+	return lower
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+// A SCIM filter is written by an identity provider we do not control and becomes a search
+// over the organization's users and groups. The grammar is checked against RFC 7644's own
+// examples, and every filter that cannot be read must be refused as invalidFilter rather
+// than searched in part.
+
+func scimCompare(attr string, op string, value any) *scimFilter {
+	return &scimFilter{attr: attr, op: op, value: value}
+}
+
+func scimLogic(logic string, operands ...*scimFilter) *scimFilter {
+	return &scimFilter{logic: logic, operands: operands}
+}
+
+func TestParseScimFilter(t *testing.T) {
+	for _, testCase := range []struct {
+		input    string
+		expected *scimFilter
+	}{
+		{`userName eq "bjensen"`, scimCompare("username", "eq", "bjensen")},
+		{`UserName EQ "bjensen"`, scimCompare("username", "eq", "bjensen")},
+		{`name.familyName co "O'Malley"`, scimCompare("name.familyname", "co", "O'Malley")},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`, scimCompare("username", "sw", "J")},
+		{`title pr`, &scimFilter{attr: "title", op: "pr"}},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`,
+			scimCompare("meta.lastmodified", "gt", "2011-05-13T04:42:34Z")},
+		{`displayName eq "say \"hi\""`, scimCompare("displayname", "eq", `say "hi"`)},
+		{`active eq false`, scimCompare("active", "eq", false)},
+		{`displayName eq null`, scimCompare("displayname", "eq", nil)},
+		{`quota le 1.5`, scimCompare("quota", "le", 1.5)},
+		{`title pr and userType eq "Employee"`, scimLogic(scimLogicAnd,
+			&scimFilter{attr: "title", op: "pr"}, scimCompare("usertype", "eq", "Employee"))},
+		// "and" binds tighter than "or".
+		{`a eq 1 or b eq 2 and c eq 3`, scimLogic(scimLogicOr,
+			scimCompare("a", "eq", 1.0),
+			scimLogic(scimLogicAnd, scimCompare("b", "eq", 2.0), scimCompare("c", "eq", 3.0)))},
+		{`(a eq 1 or b eq 2) and c eq 3`, scimLogic(scimLogicAnd,
+			scimLogic(scimLogicOr, scimCompare("a", "eq", 1.0), scimCompare("b", "eq", 2.0)),
+			scimCompare("c", "eq", 3.0))},
+		{`a eq 1 or b eq 2 or c eq 3`, scimLogic(scimLogicOr,
+			scimLogic(scimLogicOr, scimCompare("a", "eq", 1.0), scimCompare("b", "eq", 2.0)),
+			scimCompare("c", "eq", 3.0))},
+		{`not (userName eq "a")`, scimLogic(scimLogicNot, scimCompare("username", "eq", "a"))},
+		{`emails[type eq "work" and value co "@example.com"]`, scimLogic(scimLogicAnd,
+			scimCompare("emails.type", "eq", "work"), scimCompare("emails.value", "co", "@example.com"))},
+		{`members[value eq "u1"] or displayName eq "Ops"`, scimLogic(scimLogicOr,
+			scimCompare("members.value", "eq", "u1"), scimCompare("displayname", "eq", "Ops"))},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			filter, sErr := parseScimFilter(testCase.input)
+
+			require.Nil(t, sErr)
+			assert.Equal(t, testCase.expected, filter)
+		})
+	}
+}
+
+func TestParseScimFilterRefusesMalformedFilters(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName is "a"`,
+		`userName eq bjensen`,
+		`userName eq "unterminated`,
+		`userName eq "bad \escape"`,
+		`(userName eq "a"`,
+		`userName eq "a")`,
+		`not userName eq "a"`,
+		`emails[type eq "work"`,
+		`emails[type eq "work"]]`,
+		`and userName eq "a"`,
+		`userName eq "a" or`,
+		`userName eq "a" userName eq "b"`,
+		`"bjensen" eq userName`,
+	} {
+		t.Run(input, func(t *testing.T) {
+			filter, sErr := parseScimFilter(input)
+
+			assert.Nil(t, filter)
+			require.NotNil(t, sErr)
+			assert.Equal(t, itScim.ErrTypeInvalidFilter, sErr.ScimType)
+			assert.Equal(t, "400", sErr.Status)
+		})
+	}
+}
+
+func TestScimFilterToUserSearchNode(t *testing.T) {
+	for _, testCase := range []struct {
+		input    string
+		expected *dmodel.SearchNode
+	}{
+		{`userName eq "a@example.com"`,
+			dmodel.NewSearchNode().NewCondition(models.UserFieldEmail, dmodel.Equals, "a@example.com")},
+		{`emails[value sw "a@"]`,
+			dmodel.NewSearchNode().NewCondition(models.UserFieldEmail, dmodel.StartsWith, "a@")},
+		{`displayName eq null`,
+			dmodel.NewSearchNode().NewCondition(models.UserFieldDisplayName, dmodel.IsNotSet)},
+		{`active eq true`, dmodel.NewSearchNode().NewCondition(
+			models.UserFieldStatus, dmodel.Equals, string(models.UserStatusActive))},
+		{`active eq false`, dmodel.NewSearchNode().NewCondition(
+			models.UserFieldStatus, dmodel.NotEquals, string(models.UserStatusActive))},
+		// "not" is pushed down to the comparisons by De Morgan's laws.
+		{`not (userName eq "a" or displayName sw "B")`, dmodel.NewSearchNode().And(
+			*dmodel.NewSearchNode().NewCondition(models.UserFieldEmail, dmodel.NotEquals, "a"),
+			*dmodel.NewSearchNode().NewCondition(models.UserFieldDisplayName, dmodel.NotStartsWith, "B"),
+		)},
+		{`not (not (displayName pr))`,
+			dmodel.NewSearchNode().NewCondition(models.UserFieldDisplayName, dmodel.IsSet)},
+		{`not (active eq true) and meta.created ge "2026-01-01T00:00:00Z"`, dmodel.NewSearchNode().And(
+			*dmodel.NewSearchNode().NewCondition(
+				models.UserFieldStatus, dmodel.NotEquals, string(models.UserStatusActive)),
+			*dmodel.NewSearchNode().NewCondition(basemodel.FieldCreatedAt, dmodel.GreaterEqual, "2026-01-01T00:00:00Z"),
+		)},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			node, sErr := scimFilterNode(&testCase.input, resolveScimUserAttr)
+
+			require.Nil(t, sErr)
+			assertSameSearchNode(t, testCase.expected, node)
+		})
+	}
+}
+
+func TestScimFilterToGroupSearchNode(t *testing.T) {
+	for _, testCase := range []struct {
+		input    string
+		expected *dmodel.SearchNode
+	}{
+		{`displayName eq "Ops"`, dmodel.NewSearchNode().NewCondition(
+			models.GroupFieldName, dmodel.Equals, model.LangJson{scimGroupLanguage: "Ops"})},
+		{`displayName co "Ops"`,
+			dmodel.NewSearchNode().NewCondition(models.GroupFieldName, dmodel.Contains, "Ops")},
+		{`members[value eq "u1"]`,
+			dmodel.NewSearchNode().NewCondition(models.GroupEdgeUsers, dmodel.Linked, "u1")},
+		{`not (members eq "u1")`,
+			dmodel.NewSearchNode().NewCondition(models.GroupEdgeUsers, dmodel.NotLinked, "u1")},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			node, sErr := scimFilterNode(&testCase.input, resolveScimGroupAttr)
+
+			require.Nil(t, sErr)
+			assertSameSearchNode(t, testCase.expected, node)
+		})
+	}
+}
+
+// A filter that parses but names what cannot be searched is refused as a whole.
+func TestScimFilterRefusesWhatCannotBeSearched(t *testing.T) {
+	for _, testCase := range []struct {
+		input   string
+		resolve scimAttrResolver
+	}{
+		{`title eq "Boss"`, resolveScimUserAttr},
+		{`userName eq "a" or title eq "Boss"`, resolveScimUserAttr},
+		{`userName eq 1`, resolveScimUserAttr},
+		{`active gt true`, resolveScimUserAttr},
+		{`active eq "yes"`, resolveScimUserAttr},
+		{`displayName gt "A"`, resolveScimGroupAttr},
+		{`members co "u1"`, resolveScimGroupAttr},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			node, sErr := scimFilterNode(&testCase.input, testCase.resolve)
+
+			assert.Nil(t, node)
+			require.NotNil(t, sErr)
+			assert.Equal(t, itScim.ErrTypeInvalidFilter, sErr.ScimType)
+		})
+	}
+}
+
+func TestScimFilterNodeIgnoresAnEmptyFilter(t *testing.T) {
+	blank := "  "
+	for _, filter := range []*string{nil, &blank} {
+		node, sErr := scimFilterNode(filter, resolveScimUserAttr)
+
+		assert.Nil(t, node)
+		assert.Nil(t, sErr)
+	}
+}
+
+func assertSameSearchNode(t *testing.T, expected *dmodel.SearchNode, actual *dmodel.SearchNode) {
+	t.Helper()
+	require.NotNil(t, actual)
+	expectedJson, err := json.Marshal(expected)
+	require.NoError(t, err)
+	actualJson, err := json.Marshal(actual)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJson), string(actualJson))
+}
@@ -0,0 +1,353 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+// The PATCH operations of RFC 7644 section 3.5.2, matched case-insensitively as some
+// identity providers capitalize them.
+const (
+	scimPatchAdd     = "add"
+	scimPatchReplace = "replace"
+	scimPatchRemove  = "remove"
+)
+
+// applyScimUserPatch applies one operation to the user.
+//
+// An operation without a path carries an object of attributes, each applied as if it
+// had been named by the path. emails is the userName under another name, so that
+// setting either sets both.
+func applyScimUserPatch(user *itScim.User, operation itScim.PatchOperation) *itScim.Error {
+	op := strings.ToLower(operation.Op)
+	if op != scimPatchAdd && op != scimPatchReplace && op != scimPatchRemove {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidSyntax,
+			fmt.Sprintf("unsupported patch operation '%s'", operation.Op))
+	}
+	if operation.Path == nil || *operation.Path == "" {
+		if op == scimPatchRemove {
+			return itScim.NewBadRequestError(itScim.ErrTypeNoTarget, "remove requires a path")
+		}
+		attrs, ok := operation.Value.(map[string]any)
+		if !ok {
+			return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue,
+				"an operation without a path must carry an object")
+		}
+		for attr, value := range attrs {
+			if sErr := applyScimUserAttr(user, op, normalizeScimPatchPath(attr), value); sErr != nil {
+				return sErr
+			}
+		}
+		return nil
+	}
+	return applyScimUserAttr(user, op, normalizeScimPatchPath(*operation.Path), operation.Value)
+}
+
+func applyScimUserAttr(user *itScim.User, op string, path string, value any) *itScim.Error {
+	remove := op == scimPatchRemove
+	switch path {
+	case "active":
+		if remove {
+			return nil
+		}
+		active, sErr := readScimPatchBool(path, value)
+		if sErr != nil {
+			return sErr
+		}
+		user.Active = &active
+	case "displayname":
+		text, sErr := readScimPatchString(path, value, remove)
+		if sErr != nil {
+			return sErr
+		}
+		user.DisplayName = text
+	case "username", "emails", "emails.value":
+		if remove {
+			return itScim.NewBadRequestError(itScim.ErrTypeMutability, "userName cannot be removed")
+		}
+		email, sErr := readScimPatchEmail(path, value)
+		if sErr != nil {
+			return sErr
+		}
+		user.UserName = email
+	case "name":
+		if remove {
+			user.Name = nil
+			return nil
+		}
+		attrs, ok := value.(map[string]any)
+		if !ok {
+			return scimPatchInvalidValue(path)
+		}
+		for attr, attrValue := range attrs {
+			if sErr := applyScimUserAttr(user, op, "name."+strings.ToLower(attr), attrValue); sErr != nil {
+				return sErr
+			}
+		}
+	case "name.formatted", "name.givenname", "name.familyname":
+		text, sErr := readScimPatchString(path, value, remove)
+		if sErr != nil {
+			return sErr
+		}
+		if user.Name == nil {
+			user.Name = &itScim.Name{}
+		}
+		switch path {
+		case "name.formatted":
+			user.Name.Formatted = text
+		case "name.givenname":
+			user.Name.GivenName = text
+		default:
+			user.Name.FamilyName = text
+		}
+	default:
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidPath,
+			fmt.Sprintf("attribute '%s' cannot be patched", path))
+	}
+	return nil
+}
+
+// applyScimGroupPatch applies one operation to the group. Members are added, removed or
+// replaced as a whole list; a removal may also select its members with a filter, as in
+// members[value eq "2819c223"].
+func applyScimGroupPatch(group *itScim.Group, operation itScim.PatchOperation) *itScim.Error {
+	op := strings.ToLower(operation.Op)
+	if op != scimPatchAdd && op != scimPatchReplace && op != scimPatchRemove {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidSyntax,
+			fmt.Sprintf("unsupported patch operation '%s'", operation.Op))
+	}
+	if operation.Path == nil || *operation.Path == "" {
+		if op == scimPatchRemove {
+			return itScim.NewBadRequestError(itScim.ErrTypeNoTarget, "remove requires a path")
+		}
+		attrs, ok := operation.Value.(map[string]any)
+		if !ok {
+			return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue,
+				"an operation without a path must carry an object")
+		}
+		for attr, value := range attrs {
+			if sErr := applyScimGroupAttr(group, op, normalizeScimPatchPath(attr), value); sErr != nil {
+				return sErr
+			}
+		}
+		return nil
+	}
+
+	path := *operation.Path
+	if strings.Contains(path, "[") {
+		if op != scimPatchRemove {
+			return itScim.NewBadRequestError(itScim.ErrTypeInvalidPath,
+				"a filtered path is only supported to remove members")
+		}
+		memberIds, sErr := readScimMemberFilter(path)
+		if sErr != nil {
+			return sErr
+		}
+		removeScimMembers(group, memberIds)
+		return nil
+	}
+	return applyScimGroupAttr(group, op, normalizeScimPatchPath(path), operation.Value)
+}
+
+func applyScimGroupAttr(group *itScim.Group, op string, path string, value any) *itScim.Error {
+	switch path {
+	case "displayname":
+		if op == scimPatchRemove {
+			return itScim.NewBadRequestError(itScim.ErrTypeMutability, "displayName cannot be removed")
+		}
+		text, sErr := readScimPatchString(path, value, false)
+		if sErr != nil {
+			return sErr
+		}
+		if text == nil {
+			return scimPatchInvalidValue(path)
+		}
+		group.DisplayName = *text
+	case "members":
+		if op == scimPatchRemove && value == nil {
+			group.Members = []itScim.Member{}
+			return nil
+		}
+		memberIds, sErr := readScimPatchMembers(value)
+		if sErr != nil {
+			return sErr
+		}
+		switch op {
+		case scimPatchAdd:
+			for _, memberId := range memberIds {
+				if !hasScimMember(group, memberId) {
+					group.Members = append(group.Members, itScim.Member{Value: memberId})
+				}
+			}
+		case scimPatchReplace:
+			group.Members = []itScim.Member{}
+			for _, memberId := range memberIds {
+				group.Members = append(group.Members, itScim.Member{Value: memberId})
+			}
+		default:
+			removeScimMembers(group, memberIds)
+		}
+	case "externalid":
+		// Not kept: accepted so that a client sending it along is not refused.
+	default:
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidPath,
+			fmt.Sprintf("attribute '%s' cannot be patched", path))
+	}
+	return nil
+}
+
+// readScimMemberFilter reads the member ids a filtered path selects. Only equality on the
+// member value is supported, alone or or-ed.
+func readScimMemberFilter(path string) ([]string, *itScim.Error) {
+	filter, sErr := parseScimFilter(path)
+	if sErr != nil {
+		sErr.ScimType = itScim.ErrTypeInvalidPath
+		return nil, sErr
+	}
+	memberIds := []string{}
+	var collect func(node *scimFilter) bool
+	collect = func(node *scimFilter) bool {
+		if node.logic == scimLogicOr {
+			for _, operand := range node.operands {
+				if !collect(operand) {
+					return false
+				}
+			}
+			return true
+		}
+		memberId, isString := node.value.(string)
+		if node.logic != "" || node.attr != "members.value" || node.op != "eq" || !isString {
+			return false
+		}
+		memberIds = append(memberIds, memberId)
+		return true
+	}
+	if !collect(filter) {
+		return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidPath,
+			"members can only be selected by value equality")
+	}
+	return memberIds, nil
+}
+
+func readScimPatchMembers(value any) ([]string, *itScim.Error) {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	memberIds := make([]string, 0, len(items))
+	for _, item := range items {
+		member, ok := item.(map[string]any)
+		if !ok {
+			return nil, scimPatchInvalidValue("members")
+		}
+		memberId, ok := member["value"].(string)
+		if !ok || memberId == "" {
+			return nil, scimPatchInvalidValue("members")
+		}
+		memberIds = append(memberIds, memberId)
+	}
+	return memberIds, nil
+}
+
+func hasScimMember(group *itScim.Group, memberId string) bool {
+	for _, member := range group.Members {
+		if member.Value == memberId {
+			return true
+		}
+	}
+	return false
+}
+
+func removeScimMembers(group *itScim.Group, memberIds []string) {
+	kept := []itScim.Member{}
+	for _, member := range group.Members {
+		removed := false
+		for _, memberId := range memberIds {
+			if member.Value == memberId {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, member)
+		}
+	}
+	group.Members = kept
+}
+
+// normalizeScimPatchPath lower-cases the path and drops a value filter on a multi-valued
+// attribute, so that emails[type eq "work"].value reads as emails.value: a user has a
+// single email.
+func normalizeScimPatchPath(path string) string {
+	normalized := normalizeScimAttr(path)
+	if start := strings.Index(normalized, "["); start >= 0 {
+		if end := strings.Index(normalized[start:], "]"); end >= 0 {
+			normalized = normalized[:start] + normalized[start+end+1:]
+		}
+	}
+	return normalized
+}
+
+// readScimPatchBool also accepts "True" and "False", which some identity providers send.
+func readScimPatchBool(path string, value any) (bool, *itScim.Error) {
+	switch typed := value.(type) {
+	case bool:
+		return typed, nil
+	case string:
+		switch strings.ToLower(typed) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, scimPatchInvalidValue(path)
+}
+
+func readScimPatchString(path string, value any, remove bool) (*string, *itScim.Error) {
+	if remove || value == nil {
+		return nil, nil
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, scimPatchInvalidValue(path)
+	}
+	return &text, nil
+}
+
+// readScimPatchEmail reads an address given either plainly, or as the emails list, from
+// which the primary address, or else the first, is taken.
+func readScimPatchEmail(path string, value any) (string, *itScim.Error) {
+	if text, ok := value.(string); ok && text != "" {
+		return text, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	email := ""
+	for _, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return "", scimPatchInvalidValue(path)
+		}
+		address, _ := entry["value"].(string)
+		if primary, _ := entry["primary"].(bool); primary && address != "" {
+			return address, nil
+		}
+		if email == "" {
+			email = address
+		}
+	}
+	if email == "" {
+		return "", scimPatchInvalidValue(path)
+	}
+	return email, nil
+}
+
+func scimPatchInvalidValue(path string) *itScim.Error {
+	return itScim.NewBadRequestError(itScim.ErrTypeInvalidValue,
+		fmt.Sprintf("invalid value for '%s'", path))
+}
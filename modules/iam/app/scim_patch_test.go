@@ -0,0 +1,244 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+// Identity providers each word their PATCH requests their own way: with or without a path,
+// capitalized ops, booleans as strings, filtered paths into multi-valued attributes. The
+// operations are applied here to the resource as SCIM sees it, before anything is stored.
+
+func scimPath(path string) *string {
+	return &path
+}
+
+func scimText(text string) *string {
+	return &text
+}
+
+func newPatchedScimUser() itScim.User {
+	active := true
+	return itScim.User{UserName: "a@example.com", DisplayName: scimText("Ann"), Active: &active}
+}
+
+func TestApplyScimUserPatch(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		operation itScim.PatchOperation
+		check     func(t *testing.T, user itScim.User)
+	}{
+		"replace a simple attribute": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("displayName"), Value: "Ann Lee"},
+			func(t *testing.T, user itScim.User) { assert.Equal(t, "Ann Lee", *user.DisplayName) },
+		},
+		"capitalized op and a boolean as a string": {
+			itScim.PatchOperation{Op: "Replace", Path: scimPath("active"), Value: "False"},
+			func(t *testing.T, user itScim.User) { assert.False(t, *user.Active) },
+		},
+		"add without a path": {
+			itScim.PatchOperation{Op: "add", Value: map[string]any{"displayName": "Ann Lee", "active": false}},
+			func(t *testing.T, user itScim.User) {
+				assert.Equal(t, "Ann Lee", *user.DisplayName)
+				assert.False(t, *user.Active)
+			},
+		},
+		"replace a sub-attribute": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("name.givenName"), Value: "Ann"},
+			func(t *testing.T, user itScim.User) { assert.Equal(t, "Ann", *user.Name.GivenName) },
+		},
+		"replace a complex attribute": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("name"),
+				Value: map[string]any{"givenName": "Ann", "familyName": "Lee"}},
+			func(t *testing.T, user itScim.User) {
+				assert.Equal(t, "Ann", *user.Name.GivenName)
+				assert.Equal(t, "Lee", *user.Name.FamilyName)
+			},
+		},
+		"replace on a filtered multi-valued path": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath(`emails[type eq "work"].value`), Value: "b@example.com"},
+			func(t *testing.T, user itScim.User) { assert.Equal(t, "b@example.com", user.UserName) },
+		},
+		"replace a multi-valued attribute takes the primary": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("emails"), Value: []any{
+				map[string]any{"value": "home@example.com", "type": "home"},
+				map[string]any{"value": "work@example.com", "type": "work", "primary": true},
+			}},
+			func(t *testing.T, user itScim.User) { assert.Equal(t, "work@example.com", user.UserName) },
+		},
+		"replace a multi-valued attribute without a primary takes the first": {
+			itScim.PatchOperation{Op: "add", Path: scimPath("emails"), Value: []any{
+				map[string]any{"value": "first@example.com"}, map[string]any{"value": "second@example.com"},
+			}},
+			func(t *testing.T, user itScim.User) { assert.Equal(t, "first@example.com", user.UserName) },
+		},
+		"remove a simple attribute": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("displayName")},
+			func(t *testing.T, user itScim.User) { assert.Nil(t, user.DisplayName) },
+		},
+		"remove a complex attribute": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("name")},
+			func(t *testing.T, user itScim.User) { assert.Nil(t, user.Name) },
+		},
+		"remove active leaves it as it was": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("active")},
+			func(t *testing.T, user itScim.User) { assert.True(t, *user.Active) },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			user := newPatchedScimUser()
+
+			require.Nil(t, applyScimUserPatch(&user, testCase.operation))
+			testCase.check(t, user)
+		})
+	}
+}
+
+func TestApplyScimUserPatchRefusesWhatItCannotApply(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		operation itScim.PatchOperation
+		scimType  string
+	}{
+		"unknown op":              {itScim.PatchOperation{Op: "move", Path: scimPath("displayName")}, itScim.ErrTypeInvalidSyntax},
+		"remove without a path":   {itScim.PatchOperation{Op: "remove"}, itScim.ErrTypeNoTarget},
+		"no path and no object":   {itScim.PatchOperation{Op: "add", Value: "Ann"}, itScim.ErrTypeInvalidValue},
+		"remove the userName":     {itScim.PatchOperation{Op: "remove", Path: scimPath("userName")}, itScim.ErrTypeMutability},
+		"unknown attribute":       {itScim.PatchOperation{Op: "add", Path: scimPath("title"), Value: "Boss"}, itScim.ErrTypeInvalidPath},
+		"active not a boolean":    {itScim.PatchOperation{Op: "replace", Path: scimPath("active"), Value: "yes"}, itScim.ErrTypeInvalidValue},
+		"displayName not a text":  {itScim.PatchOperation{Op: "replace", Path: scimPath("displayName"), Value: 7.0}, itScim.ErrTypeInvalidValue},
+		"emails without an email": {itScim.PatchOperation{Op: "replace", Path: scimPath("emails"), Value: []any{"x"}}, itScim.ErrTypeInvalidValue},
+	} {
+		t.Run(name, func(t *testing.T) {
+			user := newPatchedScimUser()
+
+			sErr := applyScimUserPatch(&user, testCase.operation)
+
+			require.NotNil(t, sErr)
+			assert.Equal(t, testCase.scimType, sErr.ScimType)
+		})
+	}
+}
+
+func newPatchedScimGroup(memberIds ...string) itScim.Group {
+	group := itScim.Group{DisplayName: "Ops", Members: []itScim.Member{}}
+	for _, memberId := range memberIds {
+		group.Members = append(group.Members, itScim.Member{Value: memberId})
+	}
+	return group
+}
+
+func scimMemberIds(group itScim.Group) []string {
+	memberIds := []string{}
+	for _, member := range group.Members {
+		memberIds = append(memberIds, member.Value)
+	}
+	return memberIds
+}
+
+func scimMembersValue(memberIds ...string) []any {
+	values := []any{}
+	for _, memberId := range memberIds {
+		values = append(values, map[string]any{"value": memberId})
+	}
+	return values
+}
+
+func TestApplyScimGroupPatch(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		operation   itScim.PatchOperation
+		displayName string
+		memberIds   []string
+	}{
+		"replace the display name": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("displayName"), Value: "Finance"},
+			"Finance", []string{"u1", "u2"},
+		},
+		"add members keeps each once": {
+			itScim.PatchOperation{Op: "add", Path: scimPath("members"), Value: scimMembersValue("u2", "u3")},
+			"Ops", []string{"u1", "u2", "u3"},
+		},
+		"add a single member object": {
+			itScim.PatchOperation{Op: "add", Path: scimPath("members"), Value: map[string]any{"value": "u3"}},
+			"Ops", []string{"u1", "u2", "u3"},
+		},
+		"add without a path": {
+			itScim.PatchOperation{Op: "add", Value: map[string]any{"members": scimMembersValue("u3")}},
+			"Ops", []string{"u1", "u2", "u3"},
+		},
+		"replace members": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("members"), Value: scimMembersValue("u3")},
+			"Ops", []string{"u3"},
+		},
+		"remove members by value": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("members"), Value: scimMembersValue("u1")},
+			"Ops", []string{"u2"},
+		},
+		"remove every member": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("members")},
+			"Ops", []string{},
+		},
+		"remove on a filtered path": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath(`members[value eq "u1"]`)},
+			"Ops", []string{"u2"},
+		},
+		"remove on a filtered path of or-ed values": {
+			itScim.PatchOperation{Op: "Remove", Path: scimPath(`members[value eq "u1" or value eq "u2"]`)},
+			"Ops", []string{},
+		},
+		"external id is accepted and not kept": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("externalId"), Value: "ext-1"},
+			"Ops", []string{"u1", "u2"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			group := newPatchedScimGroup("u1", "u2")
+
+			require.Nil(t, applyScimGroupPatch(&group, testCase.operation))
+			assert.Equal(t, testCase.displayName, group.DisplayName)
+			assert.Equal(t, testCase.memberIds, scimMemberIds(group))
+		})
+	}
+}
+
+func TestApplyScimGroupPatchRefusesWhatItCannotApply(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		operation itScim.PatchOperation
+		scimType  string
+	}{
+		"add on a filtered path": {
+			itScim.PatchOperation{Op: "add", Path: scimPath(`members[value eq "u1"]`)}, itScim.ErrTypeInvalidPath,
+		},
+		"filter on another attribute": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath(`members[display eq "Ann"]`)}, itScim.ErrTypeInvalidPath,
+		},
+		"filter not by equality": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath(`members[value sw "u"]`)}, itScim.ErrTypeInvalidPath,
+		},
+		"malformed filter": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath(`members[value eq "u1"`)}, itScim.ErrTypeInvalidPath,
+		},
+		"remove the display name": {
+			itScim.PatchOperation{Op: "remove", Path: scimPath("displayName")}, itScim.ErrTypeMutability,
+		},
+		"member without a value": {
+			itScim.PatchOperation{Op: "add", Path: scimPath("members"), Value: []any{map[string]any{"display": "Ann"}}},
+			itScim.ErrTypeInvalidValue,
+		},
+		"unknown attribute": {
+			itScim.PatchOperation{Op: "replace", Path: scimPath("owner"), Value: "u1"}, itScim.ErrTypeInvalidPath,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			group := newPatchedScimGroup("u1", "u2")
+
+			sErr := applyScimGroupPatch(&group, testCase.operation)
+
+			require.NotNil(t, sErr)
+			assert.Equal(t, testCase.scimType, sErr.ScimType)
+			assert.Equal(t, []string{"u1", "u2"}, scimMemberIds(group))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+)
+
+func NewUserInvitationSender(invitationAppSvc it.InvitationAppService) *UserInvitationSender {
+	return &UserInvitationSender{invitationAppSvc: invitationAppSvc}
+}
+
+// UserInvitationSender serves the user engine's send_invitation action from the
+// invitation service, in the way GrantRequestWorkflow does for grant requests.
+type UserInvitationSender struct {
+	invitationAppSvc it.InvitationAppService
+}
+
+// SendInvitation widens the result into the untyped one the engine's actions return.
+func (this *UserInvitationSender) SendInvitation(
+	ctx corectx.Context, userId model.Id, lang *string,
+) (*drif.ActionResult, error) {
+	result, err := this.invitationAppSvc.SendInvitation(ctx, it.SendInvitationCommand{UserId: userId, Lang: lang})
+	if err != nil {
+		return nil, err
+	}
+	out := &drif.ActionResult{ClientErrors: result.ClientErrors, HasData: result.HasData}
+	if result.HasData {
+		out.Data = result.Data
+	}
+	return out, nil
+}
@@ -24,4 +24,7 @@ const (
 	ResourceIamGroup        = "iam_group"
 	ResourceIamOrganization = "iam_org"
 	ResourceIamOrgUnit      = "iam_orgunit"
+
+	ResourceIamAccessReviewCampaign = "iam_access_review_campaign"
+	ResourceIamAccessReviewItem     = "iam_access_review_item"
 )
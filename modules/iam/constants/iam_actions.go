@@ -9,4 +9,17 @@ const (
 	// ActionManageCredentials covers issuing a temporary password and reading it
 	// back. Separate from Update because it is an account-takeover capability.
 	ActionManageCredentials = "manage_credentials"
+
+	// ActionRespond lets its holder approve or reject grant requests for roles they
+	// do not own. A role's owners answer requests for it without this.
+	ActionRespond = "respond"
+
+	// ActionLaunch and ActionClose move an access review campaign between its states.
+	// Launching snapshots the scope's holdings, so it is kept apart from Update.
+	ActionLaunch = "launch"
+	ActionClose  = "close"
+
+	// ActionReviewAny lets its holder keep or revoke access review items for roles they
+	// do not own. A role's owners decide the items for it without this.
+	ActionReviewAny = "review_any"
 )
@@ -5,5 +5,15 @@ import (
 )
 
 const (
-	LoginAttemptDurationSecs core.ConfigName = "IAM.LOGIN_ATTEMPT_DURATION_SECS"
+	LoginAttemptDurationSecs     core.ConfigName = "IAM.LOGIN_ATTEMPT_DURATION_SECS"
+	GrantExpiryNotifyBeforeHours core.ConfigName = "IAM.GRANT_EXPIRY_NOTIFY_BEFORE_HOURS"
+
+	InvitationExpiryHours core.ConfigName = "IAM.INVITATION.EXPIRY_HOURS"
+	InvitationAcceptUrl   core.ConfigName = "IAM.INVITATION.ACCEPT_URL"
+
+	MailSmtpHost     core.ConfigName = "IAM.MAIL.SMTP_HOST"
+	MailSmtpPort     core.ConfigName = "IAM.MAIL.SMTP_PORT"
+	MailSmtpUsername core.ConfigName = "IAM.MAIL.SMTP_USERNAME"
+	MailSmtpPassword core.ConfigName = "IAM.MAIL.SMTP_PASSWORD"
+	MailFrom         core.ConfigName = "IAM.MAIL.FROM"
 )
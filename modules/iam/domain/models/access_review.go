@@ -0,0 +1,405 @@
+package models
+
+import (
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+type AccessReviewScopeType string
+type AccessReviewStatus string
+type AccessReviewItemType string
+type AccessReviewDecision string
+
+const (
+	AccessReviewScopeOrg     = AccessReviewScopeType("org")
+	AccessReviewScopeOrgUnit = AccessReviewScopeType("org_unit")
+	AccessReviewScopeRole    = AccessReviewScopeType("role")
+
+	// A campaign is drafted, then launched, which is when its items are snapshotted.
+	// It is closed by hand or by its deadline; a closed campaign accepts no decisions.
+	AccessReviewStatusDraft  = AccessReviewStatus("draft")
+	AccessReviewStatusOpen   = AccessReviewStatus("open")
+	AccessReviewStatusClosed = AccessReviewStatus("closed")
+
+	// A campaign reviews who holds each role, a user or a group.
+	AccessReviewItemRoleUser  = AccessReviewItemType("role_user")
+	AccessReviewItemRoleGroup = AccessReviewItemType("role_group")
+
+	AccessReviewDecisionPending     = AccessReviewDecision("pending")
+	AccessReviewDecisionKept        = AccessReviewDecision("kept")
+	AccessReviewDecisionRevoked     = AccessReviewDecision("revoked")
+	AccessReviewDecisionAutoRevoked = AccessReviewDecision("auto_revoked")
+)
+
+const (
+	AccessReviewCampaignSchemaName = "iam_access_review_campaign"
+
+	AccessReviewCampFieldId                   = basemodel.FieldId
+	AccessReviewCampFieldName                 = "name"
+	AccessReviewCampFieldDescription          = "description"
+	AccessReviewCampFieldScopeType            = "scope_type"
+	AccessReviewCampFieldScopeId              = "scope_id"
+	AccessReviewCampFieldStatus               = "status"
+	AccessReviewCampFieldDeadlineAt           = "deadline_at"
+	AccessReviewCampFieldAutoRevokeUnreviewed = "auto_revoke_unreviewed"
+	AccessReviewCampFieldLaunchedAt           = "launched_at"
+	AccessReviewCampFieldClosedAt             = "closed_at"
+	AccessReviewCampFieldOwnerId              = "owner_id"
+
+	AccessReviewCampEdgeOwner = "owner"
+)
+
+func AccessReviewCampaignSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.DefineModel(AccessReviewCampaignSchemaName).
+		Label(model.NewLangJsonRefSf("%s.label", AccessReviewCampaignSchemaName)).
+		TableName("iam_access_review_campaigns").
+		RecordLabelField(AccessReviewCampFieldName).
+		ShouldBuildDb().
+		Extend(basemodel.BaseModelSchemaBuilder()).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldName).
+				DataType(dmodel.FieldDataTypeString(1, model.MODEL_RULE_LONG_NAME_LENGTH)).
+				RequiredForCreate(),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldDescription).
+				DataType(dmodel.FieldDataTypeString(0, model.MODEL_RULE_DESC_LENGTH)),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldScopeType).
+				DataType(dmodel.FieldDataTypeEnumString([]string{
+					string(AccessReviewScopeOrg), string(AccessReviewScopeOrgUnit), string(AccessReviewScopeRole),
+				})).
+				RequiredForCreate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewCampFieldScopeId).
+				Description(model.LangJson{"en-US": "The organization, organizational unit or role the campaign reviews, according to scope_type."}).
+				RequiredForCreate(),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldStatus).
+				DataType(dmodel.FieldDataTypeEnumString([]string{
+					string(AccessReviewStatusDraft), string(AccessReviewStatusOpen), string(AccessReviewStatusClosed),
+				})).
+				RequiredForCreate().
+				Default(string(AccessReviewStatusDraft)),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldDeadlineAt).
+				DataType(dmodel.FieldDataTypeDateTime()).
+				RequiredForCreate(),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldAutoRevokeUnreviewed).
+				DataType(dmodel.FieldDataTypeBoolean()).
+				Description(model.LangJson{"en-US": "Revoke every item still unreviewed when the deadline passes."}).
+				RequiredForCreate().
+				Default(false),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldLaunchedAt).
+				DataType(dmodel.FieldDataTypeDateTime()),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewCampFieldClosedAt).
+				DataType(dmodel.FieldDataTypeDateTime()),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewCampFieldOwnerId),
+		).
+		Extend(basemodel.AuditableModelSchemaBuilder()).
+		Extend(basemodel.VersionedModelSchemaBuilder()).
+		EdgeTo(
+			dmodel.Edge(AccessReviewCampEdgeOwner).
+				Label(model.LangJson{"en-US": "Owner"}).
+				ManyToOne(UserSchemaName, dmodel.DynamicFields{
+					AccessReviewCampFieldOwnerId: UserFieldId,
+				}),
+		)
+}
+
+// AccessReviewCampaign is one round of re-certifying who holds what within a scope.
+type AccessReviewCampaign struct {
+	basemodel.DynamicModelBase
+}
+
+func NewAccessReviewCampaign() *AccessReviewCampaign {
+	return &AccessReviewCampaign{basemodel.NewDynamicModel()}
+}
+
+func NewAccessReviewCampaignFrom(src dmodel.DynamicFields) *AccessReviewCampaign {
+	return &AccessReviewCampaign{basemodel.NewDynamicModel(src)}
+}
+
+func (this AccessReviewCampaign) GetName() *string {
+	return this.GetFieldData().GetString(AccessReviewCampFieldName)
+}
+
+func (this AccessReviewCampaign) GetScopeType() *AccessReviewScopeType {
+	scopeType := this.GetFieldData().GetString(AccessReviewCampFieldScopeType)
+	if scopeType == nil {
+		return nil
+	}
+	typed := AccessReviewScopeType(*scopeType)
+	return &typed
+}
+
+func (this AccessReviewCampaign) GetScopeId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewCampFieldScopeId)
+}
+
+func (this AccessReviewCampaign) GetStatus() *AccessReviewStatus {
+	status := this.GetFieldData().GetString(AccessReviewCampFieldStatus)
+	if status == nil {
+		return nil
+	}
+	typed := AccessReviewStatus(*status)
+	return &typed
+}
+
+func (this *AccessReviewCampaign) SetStatus(v AccessReviewStatus) {
+	s := string(v)
+	this.GetFieldData().SetString(AccessReviewCampFieldStatus, &s)
+}
+
+func (this AccessReviewCampaign) GetDeadlineAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(AccessReviewCampFieldDeadlineAt)
+}
+
+func (this AccessReviewCampaign) IsAutoRevokeUnreviewed() *bool {
+	return this.GetFieldData().GetBool(AccessReviewCampFieldAutoRevokeUnreviewed)
+}
+
+func (this AccessReviewCampaign) GetLaunchedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(AccessReviewCampFieldLaunchedAt)
+}
+
+func (this *AccessReviewCampaign) SetLaunchedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(AccessReviewCampFieldLaunchedAt, v)
+}
+
+func (this AccessReviewCampaign) GetClosedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(AccessReviewCampFieldClosedAt)
+}
+
+func (this *AccessReviewCampaign) SetClosedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(AccessReviewCampFieldClosedAt, v)
+}
+
+func (this *AccessReviewCampaign) SetOwnerId(v *model.Id) {
+	this.GetFieldData().SetModelId(AccessReviewCampFieldOwnerId, v)
+}
+
+const (
+	AccessReviewItemSchemaName = "iam_access_review_item"
+
+	AccessReviewItemFieldId              = basemodel.FieldId
+	AccessReviewItemFieldCampaignId      = "campaign_id"
+	AccessReviewItemFieldItemType        = "item_type"
+	AccessReviewItemFieldRoleId          = "role_id"
+	AccessReviewItemFieldReceiverUserId  = "receiver_user_id"
+	AccessReviewItemFieldReceiverGroupId = "receiver_group_id"
+	AccessReviewItemFieldReviewerUserId  = "reviewer_user_id"
+	AccessReviewItemFieldReviewerGroupId = "reviewer_group_id"
+	AccessReviewItemFieldDecision        = "decision"
+	AccessReviewItemFieldDecisionComment = "decision_comment"
+	AccessReviewItemFieldDecidedById     = "decided_by_id"
+	AccessReviewItemFieldDecidedAt       = "decided_at"
+
+	AccessReviewItemEdgeCampaign      = "campaign"
+	AccessReviewItemEdgeRole          = "role"
+	AccessReviewItemEdgeReceiverUser  = "receiver_user"
+	AccessReviewItemEdgeReceiverGroup = "receiver_group"
+	AccessReviewItemEdgeReviewerUser  = "reviewer_user"
+	AccessReviewItemEdgeReviewerGroup = "reviewer_group"
+)
+
+// AccessReviewItemSchemaBuilder describes one snapshotted holding under review.
+func AccessReviewItemSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.DefineModel(AccessReviewItemSchemaName).
+		Label(model.NewLangJsonRefSf("%s.label", AccessReviewItemSchemaName)).
+		TableName("iam_access_review_items").
+		ShouldBuildDb().
+		Extend(basemodel.BaseModelSchemaBuilder()).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldCampaignId).
+				RequiredForCreate().
+				NoUpdate(),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewItemFieldItemType).
+				DataType(dmodel.FieldDataTypeEnumString([]string{
+					string(AccessReviewItemRoleUser), string(AccessReviewItemRoleGroup),
+				})).
+				RequiredForCreate().
+				NoUpdate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldRoleId).
+				RequiredForCreate().
+				NoUpdate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldReceiverUserId).
+				NoUpdate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldReceiverGroupId).
+				NoUpdate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldReviewerUserId).
+				Description(model.LangJson{"en-US": "The role's owner user when the campaign was launched"}).
+				NoUpdate(),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldReviewerGroupId).
+				Description(model.LangJson{"en-US": "The role's owner group when the campaign was launched"}).
+				NoUpdate(),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewItemFieldDecision).
+				DataType(dmodel.FieldDataTypeEnumString([]string{
+					string(AccessReviewDecisionPending), string(AccessReviewDecisionKept),
+					string(AccessReviewDecisionRevoked), string(AccessReviewDecisionAutoRevoked),
+				})).
+				RequiredForCreate().
+				Default(string(AccessReviewDecisionPending)),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewItemFieldDecisionComment).
+				DataType(dmodel.FieldDataTypeString(0, model.MODEL_RULE_COMMENT_LENGTH)),
+		).
+		Field(
+			basemodel.DefineFieldId(AccessReviewItemFieldDecidedById),
+		).
+		Field(
+			dmodel.DefineField().Name(AccessReviewItemFieldDecidedAt).
+				DataType(dmodel.FieldDataTypeDateTime()),
+		).
+		Extend(basemodel.AuditableModelSchemaBuilder()).
+		Extend(basemodel.VersionedModelSchemaBuilder()).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeCampaign).
+				Label(model.LangJson{"en-US": "Campaign"}).
+				ManyToOne(AccessReviewCampaignSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldCampaignId: AccessReviewCampFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeCascade),
+		).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeRole).
+				Label(model.LangJson{"en-US": "Role"}).
+				ManyToOne(RoleSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldRoleId: RoleFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeCascade),
+		).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeReceiverUser).
+				Label(model.LangJson{"en-US": "Receiver User"}).
+				ManyToOne(UserSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldReceiverUserId: UserFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeCascade),
+		).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeReceiverGroup).
+				Label(model.LangJson{"en-US": "Receiver Group"}).
+				ManyToOne(GroupSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldReceiverGroupId: GroupFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeCascade),
+		).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeReviewerUser).
+				Label(model.LangJson{"en-US": "Reviewer User"}).
+				ManyToOne(UserSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldReviewerUserId: UserFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeSetNull),
+		).
+		EdgeTo(
+			dmodel.Edge(AccessReviewItemEdgeReviewerGroup).
+				Label(model.LangJson{"en-US": "Reviewer Group"}).
+				ManyToOne(GroupSchemaName, dmodel.DynamicFields{
+					AccessReviewItemFieldReviewerGroupId: GroupFieldId,
+				}).
+				OnDelete(dmodel.RelationCascadeSetNull),
+		)
+}
+
+// AccessReviewItem is one holding a reviewer keeps or revokes: a role held by a user or
+// a group.
+type AccessReviewItem struct {
+	basemodel.DynamicModelBase
+}
+
+func NewAccessReviewItem() *AccessReviewItem {
+	return &AccessReviewItem{basemodel.NewDynamicModel()}
+}
+
+func NewAccessReviewItemFrom(src dmodel.DynamicFields) *AccessReviewItem {
+	return &AccessReviewItem{basemodel.NewDynamicModel(src)}
+}
+
+func (this AccessReviewItem) GetCampaignId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldCampaignId)
+}
+
+func (this AccessReviewItem) GetItemType() *AccessReviewItemType {
+	itemType := this.GetFieldData().GetString(AccessReviewItemFieldItemType)
+	if itemType == nil {
+		return nil
+	}
+	typed := AccessReviewItemType(*itemType)
+	return &typed
+}
+
+func (this AccessReviewItem) GetRoleId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldRoleId)
+}
+
+func (this AccessReviewItem) GetReceiverUserId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldReceiverUserId)
+}
+
+func (this AccessReviewItem) GetReceiverGroupId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldReceiverGroupId)
+}
+
+func (this AccessReviewItem) GetReviewerUserId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldReviewerUserId)
+}
+
+func (this AccessReviewItem) GetReviewerGroupId() *model.Id {
+	return this.GetFieldData().GetModelId(AccessReviewItemFieldReviewerGroupId)
+}
+
+func (this AccessReviewItem) GetDecision() *AccessReviewDecision {
+	decision := this.GetFieldData().GetString(AccessReviewItemFieldDecision)
+	if decision == nil {
+		return nil
+	}
+	typed := AccessReviewDecision(*decision)
+	return &typed
+}
+
+func (this *AccessReviewItem) SetDecision(v AccessReviewDecision) {
+	s := string(v)
+	this.GetFieldData().SetString(AccessReviewItemFieldDecision, &s)
+}
+
+func (this *AccessReviewItem) SetDecisionComment(v *string) {
+	this.GetFieldData().SetString(AccessReviewItemFieldDecisionComment, v)
+}
+
+func (this *AccessReviewItem) SetDecidedById(v *model.Id) {
+	this.GetFieldData().SetModelId(AccessReviewItemFieldDecidedById, v)
+}
+
+func (this *AccessReviewItem) SetDecidedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(AccessReviewItemFieldDecidedAt, v)
+}
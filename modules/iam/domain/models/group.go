@@ -15,6 +15,7 @@ const (
 	GroupFieldName        = "name"
 	GroupFieldDescription = "description"
 	GroupFieldOwnerId     = "owner_id"
+	GroupFieldOrgId       = "org_id"
 
 	GroupEdgeOwner                = "owner"
 	GroupEdgeRoles                = "roles"
@@ -83,6 +84,14 @@ func (this *Group) SetName(v *string) {
 	this.GetFieldData().SetString(GroupFieldName, v)
 }
 
+func (this Group) GetOwnerId() *model.Id {
+	return this.GetFieldData().GetModelId(GroupFieldOwnerId)
+}
+
+func (this Group) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(GroupFieldOrgId)
+}
+
 func (this Group) GetEtag() *model.Etag {
 	return this.GetFieldData().GetEtag(basemodel.FieldEtag)
 }
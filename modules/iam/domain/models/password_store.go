@@ -21,6 +21,9 @@ const (
 	PasswordStoreTypePasswordTemp = PasswordStoreType("passwordtmp")
 	PasswordStoreTypeOtpSecret    = PasswordStoreType("otp_secret")
 	PasswordStoreTypeOtpRecovery  = PasswordStoreType("otp_recovery")
+	// PasswordStoreTypeInvitation holds the hash of the pending invitation token's nonce.
+	// It is cleared when the invitation is accepted, which is what makes the token single-use.
+	PasswordStoreTypeInvitation = PasswordStoreType("invitation")
 )
 
 const (
@@ -56,6 +59,7 @@ func PasswordStoreSchemaBuilder() *dmodel.ModelSchemaBuilder {
 			dmodel.DefineField().Name(PasswordStoreFieldType).
 				DataType(dmodel.FieldDataTypeEnumString([]string{
 					string(PasswordStoreTypePassword), string(PasswordStoreTypePasswordTemp), string(PasswordStoreTypeOtpSecret), string(PasswordStoreTypeOtpRecovery),
+					string(PasswordStoreTypeInvitation),
 				})).
 				RequiredForCreate(),
 		).
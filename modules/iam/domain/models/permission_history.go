@@ -32,6 +32,8 @@ var permissionHistoryReasonValues = []string{
 	"ent_added_role_group", "ent_removed_role_group", "ent_deleted_role_group",
 	"role_added", "role_removed", "role_deleted",
 	"role_added_group", "role_removed_group", "role_deleted_group",
+	"role_expired", "role_expired_group",
+	"role_review_revoked", "role_review_revoked_group",
 }
 
 func PermissionHistorySchemaBuilder() *dmodel.ModelSchemaBuilder {
@@ -208,6 +210,14 @@ func (this *PermissionHistory) SetAssignmentId(v *model.Id) {
 	this.GetFieldData().SetModelId(PermHistoryFieldAssignmentId, v)
 }
 
+func (this *PermissionHistory) SetRoleRequestId(v *model.Id) {
+	this.GetFieldData().SetModelId(PermHistoryFieldRoleRequestId, v)
+}
+
+func (this *PermissionHistory) SetRevokeRequestId(v *model.Id) {
+	this.GetFieldData().SetModelId(PermHistoryFieldRevokeRequestId, v)
+}
+
 func (this *PermissionHistory) SetApproverId(v *model.Id) {
 	this.GetFieldData().SetModelId(PermHistoryFieldApproverId, v)
 }
@@ -247,4 +257,13 @@ const (
 	PermissionHistoryReasonRoleAddedGroup   = PermissionHistoryReason("role_added_group")
 	PermissionHistoryReasonRoleRemovedGroup = PermissionHistoryReason("role_removed_group")
 	PermissionHistoryReasonRoleDeletedGroup = PermissionHistoryReason("role_deleted_group")
+
+	// A time-bound grant reaching its end. Kept apart from role_removed so the trail
+	// tells "someone took it away" from "it ran out", which an access review reads differently.
+	PermissionHistoryReasonRoleExpired      = PermissionHistoryReason("role_expired")
+	PermissionHistoryReasonRoleExpiredGroup = PermissionHistoryReason("role_expired_group")
+
+	// A holding an access review campaign revoked, by its reviewer or by its deadline.
+	PermissionHistoryReasonRoleReviewRevoked      = PermissionHistoryReason("role_review_revoked")
+	PermissionHistoryReasonRoleReviewRevokedGroup = PermissionHistoryReason("role_review_revoked_group")
 )
@@ -73,3 +73,23 @@ func (this Role) IsPrivate() *bool {
 func (this *Role) SetIsPrivate(v *bool) {
 	this.GetFieldData().SetBool(RoleFieldIsPrivate, v)
 }
+
+func (this Role) GetOwnerUserId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleFieldOwnerUserId)
+}
+
+func (this Role) GetOwnerGroupId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleFieldOwnerGroupId)
+}
+
+func (this Role) IsRequestable() *bool {
+	return this.GetFieldData().GetBool(RoleFieldIsRequestable)
+}
+
+func (this Role) IsRequiredAttachment() *bool {
+	return this.GetFieldData().GetBool(RoleFieldIsRequiredAttach)
+}
+
+func (this Role) IsRequiredComment() *bool {
+	return this.GetFieldData().GetBool(RoleFieldIsRequiredComment)
+}
@@ -15,6 +15,7 @@ const (
 	RoleGroupAssignFieldRoleRequestId   = "role_request_id"
 	RoleGroupAssignFieldApproverId      = "approver_id"
 	RoleGroupAssignFieldExpiresAt       = "expires_at"
+	RoleGroupAssignFieldExpiryNotified  = "expiry_notified_at"
 
 	RoleGroupAssignEdgeRoleRequest = "role_request"
 	RoleGroupAssignEdgeApprover    = "approver"
@@ -52,6 +53,11 @@ func RoleGroupAssignmentSchemaBuilder() *dmodel.ModelSchemaBuilder {
 				DataType(dmodel.FieldDataTypeDateTime()).
 				Description(model.LangJson{"en-US": "The date and time when the entitlement grant expires."}),
 		).
+		Field(
+			dmodel.DefineField().Name(RoleGroupAssignFieldExpiryNotified).
+				DataType(dmodel.FieldDataTypeDateTime()).
+				Description(model.LangJson{"en-US": "When the receiver was told the grant is about to expire."}),
+		).
 		Extend(basemodel.AuditableReadonlyModelSchemaBuilder()).
 		EdgeTo(
 			dmodel.Edge(RoleGroupAssignEdgeRoleRequest).
@@ -154,3 +154,66 @@ func NewRoleRequest() *RoleRequest {
 func NewRoleRequestFrom(src dmodel.DynamicFields) *RoleRequest {
 	return &RoleRequest{basemodel.NewDynamicModel(src)}
 }
+
+func (this RoleRequest) GetRoleId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleReqFieldRoleId)
+}
+
+func (this RoleRequest) GetStatus() *RoleRequestStatus {
+	status := this.GetFieldData().GetString(RoleReqFieldStatus)
+	if status == nil {
+		return nil
+	}
+	typed := RoleRequestStatus(*status)
+	return &typed
+}
+
+func (this *RoleRequest) SetStatus(v RoleRequestStatus) {
+	s := string(v)
+	this.GetFieldData().SetString(RoleReqFieldStatus, &s)
+}
+
+func (this RoleRequest) GetType() *RoleRequestType {
+	reqType := this.GetFieldData().GetString(RoleReqFieldType)
+	if reqType == nil {
+		return nil
+	}
+	typed := RoleRequestType(*reqType)
+	return &typed
+}
+
+func (this RoleRequest) GetReceiverUserId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleReqFieldReceiverUserId)
+}
+
+func (this RoleRequest) GetReceiverGroupId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleReqFieldReceiverGroupId)
+}
+
+func (this RoleRequest) GetRequestorId() *model.Id {
+	return this.GetFieldData().GetModelId(RoleReqFieldRequestorId)
+}
+
+func (this RoleRequest) GetRequestComment() *string {
+	return this.GetFieldData().GetString(RoleReqFieldRequestComment)
+}
+
+func (this RoleRequest) GetAttachmentUrl() *string {
+	return this.GetFieldData().GetString(RoleReqFieldAttachmentUrl)
+}
+
+func (this RoleRequest) GetGrantExpiresAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(RoleReqFieldGrantExpiresAt)
+}
+
+func (this *RoleRequest) SetRejectionReason(v *string) {
+	this.GetFieldData().SetString(RoleReqFieldRejectionReason, v)
+}
+
+func (this *RoleRequest) SetRespondedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(RoleReqFieldRespondedAt, v)
+}
+
+func (this *RoleRequest) SetResponderId(v *model.Id) {
+	this.GetFieldData().SetModelId(RoleReqFieldResponderId, v)
+}
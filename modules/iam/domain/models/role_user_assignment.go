@@ -15,6 +15,7 @@ const (
 	RoleUserAssignFieldRoleRequestId  = "role_request_id"
 	RoleUserAssignFieldApproverId     = "approver_id"
 	RoleUserAssignFieldExpiresAt      = "expires_at"
+	RoleUserAssignFieldExpiryNotified = "expiry_notified_at"
 
 	RoleUserAssignEdgeRoleRequest = "role_request"
 	RoleUserAssignEdgeApprover    = "approver"
@@ -53,6 +54,11 @@ func RoleUserAssignmentSchemaBuilder() *dmodel.ModelSchemaBuilder {
 				DataType(dmodel.FieldDataTypeDateTime()).
 				Description(model.LangJson{"en-US": "The date and time when the entitlement grant expires."}),
 		).
+		Field(
+			dmodel.DefineField().Name(RoleUserAssignFieldExpiryNotified).
+				DataType(dmodel.FieldDataTypeDateTime()).
+				Description(model.LangJson{"en-US": "When the receiver was told the grant is about to expire."}),
+		).
 		EdgeTo(
 			dmodel.Edge(RoleUserAssignEdgeRoleRequest).
 				Label(model.LangJson{"en-US": "Role Request"}).
@@ -177,6 +177,13 @@ func groupSchemaBuilderLegacy() *dmodel.ModelSchemaBuilder {
 					"is responsible for reviewing the membership periodically.",
 				}),
 		).
+		// Added after the conversion, to both builders alike.
+		Field(
+			basemodel.DefineFieldId(GroupFieldOrgId).
+				Description(model.LangJson{model.LanguageCodeEnUs: "If specified, the group belongs to this organization, " +
+					"as the groups an organization's identity provider provisions do. Otherwise, the group is deployment-wide.",
+				}),
+		).
 		Extend(basemodel.ArchivableModelSchemaBuilder()).
 		Extend(basemodel.VersionedModelSchemaBuilder()).
 		Extend(basemodel.AuditableModelSchemaBuilder()).
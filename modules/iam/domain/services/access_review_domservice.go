@@ -0,0 +1,504 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itAr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+	itGrp "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/group"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+// accessReviewBatchSize bounds one read of due campaigns, pending items or an export
+// page, for the same reason as the grant expiry sweep.
+const accessReviewBatchSize = 500
+
+func NewAccessReviewDomainServiceImpl(
+	campaignRepo itAr.AccessReviewCampaignRepository,
+	itemRepo itAr.AccessReviewItemRepository,
+	groupRepo itGrp.GroupRepository,
+	assignmentRepo itRole.RoleAssignmentRepository,
+	permRepo itPerm.PermissionRepository,
+	historyRepo itPerm.PermissionHistoryRepository,
+) itAr.AccessReviewDomainService {
+	return &AccessReviewDomainServiceImpl{
+		campaignRepo:   campaignRepo,
+		itemRepo:       itemRepo,
+		groupRepo:      groupRepo,
+		assignmentRepo: assignmentRepo,
+		permRepo:       permRepo,
+		auditor:        permissionAuditor{historyRepo: historyRepo},
+	}
+}
+
+// AccessReviewDomainServiceImpl runs access review campaigns.
+//
+// A campaign is drafted against a scope, and launching it snapshots every holding in
+// that scope as an item addressed to the owner of the role concerned. The snapshot is
+// what gets certified: a holding granted after launch waits for the next campaign, and
+// one removed meanwhile is simply gone when its item is revoked.
+//
+// A revocation goes through the same steps as any other - the reviewed holder's
+// assignment is removed, the cache rebuilt and the permission history written - so a
+// campaign's outcome can be read from the history like every other change.
+type AccessReviewDomainServiceImpl struct {
+	campaignRepo   itAr.AccessReviewCampaignRepository
+	itemRepo       itAr.AccessReviewItemRepository
+	groupRepo      itGrp.GroupRepository
+	assignmentRepo itRole.RoleAssignmentRepository
+	permRepo       itPerm.PermissionRepository
+	auditor        permissionAuditor
+}
+
+// LaunchCampaign opens a draft campaign and snapshots its items. The caller runs it in
+// one transaction, so a campaign is never open without its items.
+func (this *AccessReviewDomainServiceImpl) LaunchCampaign(
+	ctx corectx.Context, cmd itAr.LaunchCampaignCommand,
+) (*itAr.LaunchCampaignResult, error) {
+	campaign := domain.NewAccessReviewCampaign()
+	campaign.SetId(&cmd.Id)
+	campaign.SetEtag(&cmd.Etag)
+	campaign.SetStatus(domain.AccessReviewStatusOpen)
+	now := model.NewModelDateTime()
+	campaign.SetLaunchedAt(&now)
+
+	var launched *domain.AccessReviewCampaign
+	result, err := corecrud.Update(ctx, corecrud.UpdateParam[domain.AccessReviewCampaign, *domain.AccessReviewCampaign]{
+		Action:       "launch access review campaign",
+		DbRepoGetter: this.campaignRepo,
+		Data:         campaign,
+		ValidateExtra: func(
+			_ corectx.Context, _ *domain.AccessReviewCampaign, found *domain.AccessReviewCampaign, vErrs *ft.ClientErrors,
+		) error {
+			validateCampaignStatus(found, domain.AccessReviewStatusDraft, vErrs)
+			deadline := found.GetDeadlineAt()
+			if deadline != nil && !deadline.GoTime().After(time.Now()) {
+				vErrs.Append(*ft.NewValidationError(
+					domain.AccessReviewCampFieldDeadlineAt, ft.ErrorKey("err_access_review_deadline_in_past", "iam"),
+					"deadline_at must be in the future when the campaign is launched",
+				))
+			}
+			launched = found
+			return nil
+		},
+	})
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData || launched == nil {
+		return result, err
+	}
+
+	if _, err := this.itemRepo.SnapshotItems(ctx, *launched); err != nil {
+		return nil, errors.Wrap(err, "snapshot access review items")
+	}
+	return result, nil
+}
+
+// CloseCampaign closes an open campaign by hand. Items still pending stay pending: only
+// the deadline revokes what nobody reviewed, and only when the campaign says so.
+func (this *AccessReviewDomainServiceImpl) CloseCampaign(
+	ctx corectx.Context, cmd itAr.CloseCampaignCommand,
+) (*itAr.CloseCampaignResult, error) {
+	return this.closeCampaign(ctx, "close access review campaign", cmd.Id, cmd.Etag)
+}
+
+func (this *AccessReviewDomainServiceImpl) CloseDueCampaign(
+	ctx corectx.Context, campaign domain.AccessReviewCampaign,
+) error {
+	id, etag := campaign.GetId(), campaign.GetEtag()
+	if id == nil || etag == nil {
+		return errors.New("access review campaign is missing its id or etag")
+	}
+	result, err := this.closeCampaign(ctx, "close due access review campaign", *id, *etag)
+	if err != nil {
+		return err
+	}
+	if result.ClientErrors.Count() > 0 {
+		return errors.Wrapf(clientErrorsToError(result.ClientErrors, "campaign could not be closed"),
+			"close access review campaign '%s'", *id)
+	}
+	return nil
+}
+
+func (this *AccessReviewDomainServiceImpl) closeCampaign(
+	ctx corectx.Context, action string, id model.Id, etag model.Etag,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	campaign := domain.NewAccessReviewCampaign()
+	campaign.SetId(&id)
+	campaign.SetEtag(&etag)
+	campaign.SetStatus(domain.AccessReviewStatusClosed)
+	now := model.NewModelDateTime()
+	campaign.SetClosedAt(&now)
+
+	return corecrud.Update(ctx, corecrud.UpdateParam[domain.AccessReviewCampaign, *domain.AccessReviewCampaign]{
+		Action:       action,
+		DbRepoGetter: this.campaignRepo,
+		Data:         campaign,
+		ValidateExtra: func(
+			_ corectx.Context, _ *domain.AccessReviewCampaign, found *domain.AccessReviewCampaign, vErrs *ft.ClientErrors,
+		) error {
+			validateCampaignStatus(found, domain.AccessReviewStatusOpen, vErrs)
+			return nil
+		},
+	})
+}
+
+// accessReviewCampaignStateFields are the fields only launch and close write. A generic
+// edit that set one would open a campaign without its items, or close it early.
+var accessReviewCampaignStateFields = []string{
+	domain.AccessReviewCampFieldStatus,
+	domain.AccessReviewCampFieldLaunchedAt,
+	domain.AccessReviewCampFieldClosedAt,
+}
+
+// ValidateCampaignEdit lets a campaign be edited only as a draft, and never into another
+// state. As with grant requests, repeating a state field's current value is let through.
+func (this *AccessReviewDomainServiceImpl) ValidateCampaignEdit(
+	_ corectx.Context, input *domain.AccessReviewCampaign, found *domain.AccessReviewCampaign, vErrs *ft.ClientErrors,
+) error {
+	inputFields := input.GetFieldData()
+	foundFields := found.GetFieldData()
+	for _, field := range accessReviewCampaignStateFields {
+		value, present := inputFields[field]
+		if !present || fmt.Sprint(value) == fmt.Sprint(foundFields[field]) {
+			continue
+		}
+		vErrs.Append(*ft.NewBusinessViolation(
+			field, ft.ErrorKey("err_access_review_state_not_editable", "iam"),
+			"a campaign is launched and closed through its actions, not by editing it",
+		))
+	}
+	if vErrs.Count() > 0 {
+		return nil
+	}
+	status := found.GetStatus()
+	if status != nil && *status != domain.AccessReviewStatusDraft {
+		vErrs.Append(*ft.NewBusinessViolation(
+			domain.AccessReviewCampFieldStatus, ft.ErrorKey("err_access_review_not_editable", "iam"),
+			"a campaign can only be edited while it is a draft",
+		))
+	}
+	return nil
+}
+
+// DecideReviewItem records a reviewer's decision and, for a revocation, carries it out
+// at once rather than at the close: access the reviewer has judged unwarranted should
+// not outlive the judgement by weeks. The caller runs it in one transaction.
+func (this *AccessReviewDomainServiceImpl) DecideReviewItem(
+	ctx corectx.Context, cmd itAr.DecideReviewItemCommand,
+) (*itAr.DecideReviewItemResult, error) {
+	item := domain.NewAccessReviewItem()
+	item.SetId(&cmd.Id)
+	item.SetEtag(&cmd.Etag)
+	item.SetDecision(cmd.Decision)
+	item.SetDecisionComment(cmd.Comment)
+	item.SetDecidedById(actorOf(ctx))
+	now := model.NewModelDateTime()
+	item.SetDecidedAt(&now)
+
+	var decided *domain.AccessReviewItem
+	result, err := corecrud.Update(ctx, corecrud.UpdateParam[domain.AccessReviewItem, *domain.AccessReviewItem]{
+		Action:       "decide access review item",
+		DbRepoGetter: this.itemRepo,
+		Data:         item,
+		ValidateExtra: func(
+			ctx corectx.Context, _ *domain.AccessReviewItem, found *domain.AccessReviewItem, vErrs *ft.ClientErrors,
+		) error {
+			if cmd.Decision != domain.AccessReviewDecisionKept && cmd.Decision != domain.AccessReviewDecisionRevoked {
+				vErrs.Append(*ft.NewValidationError(
+					domain.AccessReviewItemFieldDecision, ft.ErrorKey("err_access_review_invalid_decision", "iam"),
+					"decision must be either kept or revoked",
+				))
+				return nil
+			}
+			validateItemPending(found, vErrs)
+			if vErrs.Count() > 0 {
+				return nil
+			}
+			if err := this.validateCampaignOpen(ctx, found, vErrs); err != nil || vErrs.Count() > 0 {
+				return err
+			}
+			if err := this.validateReviewer(ctx, found, vErrs); err != nil {
+				return err
+			}
+			decided = found
+			return nil
+		},
+	})
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData || decided == nil {
+		return result, err
+	}
+	if cmd.Decision == domain.AccessReviewDecisionRevoked {
+		if err := this.revokeItem(ctx, *decided); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (this *AccessReviewDomainServiceImpl) FindDueCampaigns(
+	ctx corectx.Context, asOf time.Time,
+) ([]domain.AccessReviewCampaign, error) {
+	campaigns, err := this.campaignRepo.FindDue(ctx, asOf, accessReviewBatchSize)
+	return campaigns, errors.Wrap(err, "find due access review campaigns")
+}
+
+func (this *AccessReviewDomainServiceImpl) FindPendingItems(
+	ctx corectx.Context, campaign domain.AccessReviewCampaign, afterId *model.Id,
+) ([]domain.AccessReviewItem, error) {
+	campaignId := campaign.GetId()
+	if campaignId == nil {
+		return nil, errors.New("access review campaign is missing its id")
+	}
+	nodes := []dmodel.SearchNode{
+		*dmodel.NewSearchNode().NewCondition(domain.AccessReviewItemFieldCampaignId, dmodel.Equals, string(*campaignId)),
+		*dmodel.NewSearchNode().NewCondition(
+			domain.AccessReviewItemFieldDecision, dmodel.Equals, string(domain.AccessReviewDecisionPending)),
+	}
+	if afterId != nil {
+		nodes = append(nodes, *dmodel.NewSearchNode().NewCondition(
+			domain.AccessReviewItemFieldId, dmodel.GreaterThan, string(*afterId)))
+	}
+	graph := dmodel.NewSearchGraph().And(nodes...).OrderBy(domain.AccessReviewItemFieldId)
+	found, err := this.itemRepo.Search(ctx, dyn.RepoSearchParam{
+		Graph:  graph,
+		Fields: accessReviewItemFields,
+		Page:   0,
+		Size:   accessReviewBatchSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "find pending access review items")
+	}
+	if found.ClientErrors.Count() > 0 {
+		return nil, clientErrorsToError(found.ClientErrors, "failed to find pending access review items")
+	}
+	return found.Data.Items, nil
+}
+
+// AutoRevokeItem revokes an item nobody reviewed before the deadline. The update is
+// etag-checked against the item as it was read, so a decision that landed in between
+// wins and the item is left alone.
+func (this *AccessReviewDomainServiceImpl) AutoRevokeItem(
+	ctx corectx.Context, item domain.AccessReviewItem,
+) (bool, error) {
+	revoked, err := corecrud.ExecInTranx(ctx, this.itemRepo, func(tranxCtx corectx.Context) (*bool, error) {
+		update := domain.NewAccessReviewItem()
+		update.SetId(item.GetId())
+		update.SetEtag(item.GetEtag())
+		update.SetDecision(domain.AccessReviewDecisionAutoRevoked)
+		now := model.NewModelDateTime()
+		update.SetDecidedAt(&now)
+
+		result, err := corecrud.Update(tranxCtx, corecrud.UpdateParam[domain.AccessReviewItem, *domain.AccessReviewItem]{
+			Action:       "auto-revoke access review item",
+			DbRepoGetter: this.itemRepo,
+			Data:         update,
+			ValidateExtra: func(
+				_ corectx.Context, _ *domain.AccessReviewItem, found *domain.AccessReviewItem, vErrs *ft.ClientErrors,
+			) error {
+				validateItemPending(found, vErrs)
+				return nil
+			},
+		})
+		done := err == nil && result.ClientErrors.Count() == 0 && result.HasData
+		if !done {
+			return &done, err
+		}
+		return &done, this.revokeItem(tranxCtx, item)
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "auto-revoke access review item '%s'", *item.GetId())
+	}
+	return *revoked, nil
+}
+
+// ExportCampaign returns the campaign, a count of its items per decision and every
+// item, with the names a reader needs to follow it without looking ids up.
+func (this *AccessReviewDomainServiceImpl) ExportCampaign(
+	ctx corectx.Context, query itAr.ExportCampaignQuery,
+) (*itAr.ExportCampaignResult, error) {
+	foundCampaign, err := this.campaignRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{domain.AccessReviewCampFieldId: query.Id},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "export access review campaign")
+	}
+	if foundCampaign.ClientErrors.Count() > 0 || !foundCampaign.HasData {
+		return &itAr.ExportCampaignResult{ClientErrors: foundCampaign.ClientErrors}, nil
+	}
+
+	graph := dmodel.NewSearchGraph().
+		NewCondition(domain.AccessReviewItemFieldCampaignId, dmodel.Equals, string(query.Id))
+	export := itAr.CampaignExport{
+		Campaign: foundCampaign.Data,
+		Summary:  map[string]int{},
+	}
+	for page := 0; ; page++ {
+		found, err := this.itemRepo.Search(ctx, dyn.RepoSearchParam{
+			Graph:  graph,
+			Fields: accessReviewExportFields,
+			Page:   page,
+			Size:   accessReviewBatchSize,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "export access review items")
+		}
+		if found.ClientErrors.Count() > 0 {
+			return &itAr.ExportCampaignResult{ClientErrors: found.ClientErrors}, nil
+		}
+		for _, item := range found.Data.Items {
+			if decision := item.GetDecision(); decision != nil {
+				export.Summary[string(*decision)]++
+			}
+		}
+		export.Items = append(export.Items, found.Data.Items...)
+		if len(found.Data.Items) < accessReviewBatchSize {
+			break
+		}
+	}
+	return &itAr.ExportCampaignResult{Data: export, HasData: true}, nil
+}
+
+// accessReviewItemFields is what deciding or revoking an item needs to read back.
+var accessReviewItemFields = []string{
+	domain.AccessReviewItemFieldId, basemodel.FieldEtag,
+	domain.AccessReviewItemFieldCampaignId, domain.AccessReviewItemFieldItemType,
+	domain.AccessReviewItemFieldRoleId, domain.AccessReviewItemFieldReceiverUserId,
+	domain.AccessReviewItemFieldReceiverGroupId, domain.AccessReviewItemFieldReviewerUserId,
+	domain.AccessReviewItemFieldReviewerGroupId, domain.AccessReviewItemFieldDecision,
+}
+
+var accessReviewExportFields = append(append([]string{}, accessReviewItemFields...),
+	domain.AccessReviewItemFieldDecisionComment,
+	domain.AccessReviewItemFieldDecidedById,
+	domain.AccessReviewItemFieldDecidedAt,
+	fmt.Sprintf("%s.%s", domain.AccessReviewItemEdgeRole, domain.RoleFieldName),
+	fmt.Sprintf("%s.%s", domain.AccessReviewItemEdgeReceiverUser, domain.UserFieldDisplayName),
+	fmt.Sprintf("%s.%s", domain.AccessReviewItemEdgeReceiverGroup, domain.GroupFieldName),
+)
+
+func validateCampaignStatus(
+	found *domain.AccessReviewCampaign, expected domain.AccessReviewStatus, vErrs *ft.ClientErrors,
+) {
+	status := found.GetStatus()
+	if status == nil || *status != expected {
+		vErrs.Append(*ft.NewBusinessViolation(
+			domain.AccessReviewCampFieldStatus, ft.ErrorKey("err_access_review_wrong_status", "iam"),
+			fmt.Sprintf("this can only be done to a campaign whose status is %s", expected),
+		))
+	}
+}
+
+func validateItemPending(found *domain.AccessReviewItem, vErrs *ft.ClientErrors) {
+	decision := found.GetDecision()
+	if decision == nil || *decision != domain.AccessReviewDecisionPending {
+		vErrs.Append(*ft.NewBusinessViolation(
+			domain.AccessReviewItemFieldDecision, ft.ErrorKey("err_access_review_item_decided", "iam"),
+			"this item has already been decided",
+		))
+	}
+}
+
+// validateCampaignOpen refuses a decision on an item whose campaign is not running:
+// before launch there is nothing to decide, and after the close the result is final.
+func (this *AccessReviewDomainServiceImpl) validateCampaignOpen(
+	ctx corectx.Context, item *domain.AccessReviewItem, vErrs *ft.ClientErrors,
+) error {
+	campaignId := item.GetCampaignId()
+	if campaignId == nil {
+		return errors.New("access review item has no campaign")
+	}
+	found, err := this.campaignRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{domain.AccessReviewCampFieldId: *campaignId},
+		Fields: []string{domain.AccessReviewCampFieldId, domain.AccessReviewCampFieldStatus},
+	})
+	if err != nil {
+		return errors.Wrap(err, "load access review campaign")
+	}
+	if !found.HasData {
+		vErrs.Append(*ft.NewNotFoundError(domain.AccessReviewItemFieldCampaignId))
+		return nil
+	}
+	validateCampaignStatus(&found.Data, domain.AccessReviewStatusOpen, vErrs)
+	return nil
+}
+
+// validateReviewer routes the decision to the owner of the item's role as it was at
+// launch. Anyone else needs the "review_any" permission, which is also what decides the
+// items of a role that had no owner.
+func (this *AccessReviewDomainServiceImpl) validateReviewer(
+	ctx corectx.Context, item *domain.AccessReviewItem, vErrs *ft.ClientErrors,
+) error {
+	isReviewer, err := isActorOwner(ctx, this.groupRepo, item.GetReviewerUserId(), item.GetReviewerGroupId())
+	if err != nil || isReviewer {
+		return err
+	}
+	canReviewAny := reguard.AssertPermission(ctx, reguard.PermFor(
+		c.ActionReviewAny, domain.AccessReviewItemSchemaName, reguard.ResourceScopeDomain,
+	)) == nil
+	if !canReviewAny {
+		vErrs.Append(*ft.NewAuthorizationError(
+			ft.ErrorKey("err_not_access_reviewer", "iam"),
+			"only the owner of the role under review can decide this item",
+		))
+	}
+	return nil
+}
+
+// revokeItem removes the reviewed holder's assignment of the role, and nobody else's. A
+// holding already gone by then, revoked or expired since the snapshot, leaves nothing to
+// remove and nothing to record.
+func (this *AccessReviewDomainServiceImpl) revokeItem(ctx corectx.Context, item domain.AccessReviewItem) error {
+	itemType := item.GetItemType()
+	roleId := item.GetRoleId()
+	if itemType == nil || roleId == nil {
+		return errors.New("access review item has no type or role")
+	}
+
+	target := itRole.RoleAssignment{RoleId: *roleId}
+	reason := domain.PermissionHistoryReasonRoleReviewRevoked
+	switch *itemType {
+	case domain.AccessReviewItemRoleGroup:
+		target.ReceiverKind = itRole.AssignmentReceiverGroup
+		target.ReceiverId = safeId(item.GetReceiverGroupId())
+		reason = domain.PermissionHistoryReasonRoleReviewRevokedGroup
+	case domain.AccessReviewItemRoleUser:
+		target.ReceiverKind = itRole.AssignmentReceiverUser
+		target.ReceiverId = safeId(item.GetReceiverUserId())
+	default:
+		return errors.Errorf("access review item of type '%s' cannot be revoked", *itemType)
+	}
+	if target.ReceiverId == "" {
+		return errors.New("access review item has no receiver")
+	}
+
+	removed, err := this.assignmentRepo.RevokeAssignment(ctx, target)
+	if err != nil {
+		return errors.Wrap(err, "revoke reviewed assignment")
+	}
+	if removed == nil {
+		return nil
+	}
+	if err := rebuildAssignmentHolders(ctx, this.permRepo, *removed); err != nil {
+		return err
+	}
+	return this.auditor.recordAssignmentTransition(ctx, domain.PermissionHistoryEffectRevoke, reason, *removed, nil)
+}
+
+func safeId(id *model.Id) model.Id {
+	if id == nil {
+		return ""
+	}
+	return *id
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itAr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+// A review decision can take access away, so it must reach exactly the holder under review
+// and no one else, and only while the campaign is running. Decisions and closes run here over
+// in-memory rows; the doubles they share with the grant request tests are defined there.
+
+const (
+	testCampaignId = "01CAMPAIGN000000000000000A"
+	testItemId     = "01ITEM0000000000000000000A"
+	testGroupId    = "01GROUP000000000000000000A"
+)
+
+type stubCampaignRepository struct {
+	itAr.AccessReviewCampaignRepository
+
+	base *stubBaseRepository
+}
+
+func (this *stubCampaignRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return this.base
+}
+
+func (this *stubCampaignRepository) GetOne(
+	ctx corectx.Context, param dyn.RepoGetOneParam,
+) (*dyn.OpResult[domain.AccessReviewCampaign], error) {
+	found, err := this.base.GetOne(ctx, param)
+	if err != nil || !found.HasData {
+		return &dyn.OpResult[domain.AccessReviewCampaign]{}, err
+	}
+	return &dyn.OpResult[domain.AccessReviewCampaign]{
+		Data: *domain.NewAccessReviewCampaignFrom(found.Data), HasData: true,
+	}, nil
+}
+
+type stubReviewItemRepository struct {
+	itAr.AccessReviewItemRepository
+
+	base *stubBaseRepository
+}
+
+func (this *stubReviewItemRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return this.base
+}
+
+func (this *stubReviewItemRepository) BeginTransaction(ctx corectx.Context) (database.DbTransaction, error) {
+	return this.base.BeginTransaction(ctx)
+}
+
+type accessReviewFixture struct {
+	svc         *AccessReviewDomainServiceImpl
+	campaigns   *stubBaseRepository
+	items       *stubBaseRepository
+	grants      *stubGrantRepository
+	permissions *stubPermissionRepository
+}
+
+func newAccessReviewFixture(
+	t *testing.T, campaignStatus domain.AccessReviewStatus, item dmodel.DynamicFields,
+) accessReviewFixture {
+	t.Helper()
+	// Normally done by CoreModule.RegisterModels during app start-up; a second call only
+	// reports the builders as already registered.
+	_ = basemodel.RegisterJsonBaseSchemas()
+	fixture := accessReviewFixture{
+		campaigns: newStubBaseRepository(domain.AccessReviewCampaignSchemaBuilder().Build(), dmodel.DynamicFields{
+			domain.AccessReviewCampFieldId:     testCampaignId,
+			basemodel.FieldEtag:                testEtag,
+			domain.AccessReviewCampFieldStatus: string(campaignStatus),
+		}),
+		items:       newStubBaseRepository(domain.AccessReviewItemSchemaBuilder().Build(), item),
+		grants:      &stubGrantRepository{},
+		permissions: &stubPermissionRepository{},
+	}
+	fixture.svc = NewAccessReviewDomainServiceImpl(
+		&stubCampaignRepository{base: fixture.campaigns}, &stubReviewItemRepository{base: fixture.items},
+		nil, fixture.grants, fixture.permissions, nil,
+	).(*AccessReviewDomainServiceImpl)
+	return fixture
+}
+
+func pendingReviewItem(itemType domain.AccessReviewItemType) dmodel.DynamicFields {
+	item := dmodel.DynamicFields{
+		domain.AccessReviewItemFieldId:             testItemId,
+		basemodel.FieldEtag:                        testEtag,
+		domain.AccessReviewItemFieldCampaignId:     testCampaignId,
+		domain.AccessReviewItemFieldItemType:       string(itemType),
+		domain.AccessReviewItemFieldRoleId:         testRoleId,
+		domain.AccessReviewItemFieldReviewerUserId: testOwnerId,
+		domain.AccessReviewItemFieldDecision:       string(domain.AccessReviewDecisionPending),
+	}
+	switch itemType {
+	case domain.AccessReviewItemRoleUser:
+		item[domain.AccessReviewItemFieldReceiverUserId] = testReceiverId
+	case domain.AccessReviewItemRoleGroup:
+		item[domain.AccessReviewItemFieldReceiverGroupId] = testGroupId
+	}
+	return item
+}
+
+func (this accessReviewFixture) decision() domain.AccessReviewDecision {
+	return *domain.NewAccessReviewItemFrom(this.items.rows[testItemId]).GetDecision()
+}
+
+func (this accessReviewFixture) campaignStatus() domain.AccessReviewStatus {
+	return *domain.NewAccessReviewCampaignFrom(this.campaigns.rows[testCampaignId]).GetStatus()
+}
+
+func decide(decision domain.AccessReviewDecision) itAr.DecideReviewItemCommand {
+	return itAr.DecideReviewItemCommand{Id: testItemId, Etag: testEtag, Decision: decision}
+}
+
+func TestDecideKeptLeavesTheHoldingInPlace(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+
+	result, err := fixture.svc.DecideReviewItem(actorContext(testOwnerId), decide(domain.AccessReviewDecisionKept))
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, domain.AccessReviewDecisionKept, fixture.decision())
+	assert.Empty(t, fixture.grants.revoked)
+}
+
+// Revoking takes the role from the holder under review and rebuilds only that holder; the
+// role itself, and everyone else who holds it, is untouched.
+func TestDecideRevokedRemovesOnlyTheReviewedAssignment(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		itemType domain.AccessReviewItemType
+		expected itRole.RoleAssignment
+	}{
+		"user": {domain.AccessReviewItemRoleUser, itRole.RoleAssignment{
+			RoleId: testRoleId, ReceiverKind: itRole.AssignmentReceiverUser, ReceiverId: testReceiverId,
+		}},
+		"group": {domain.AccessReviewItemRoleGroup, itRole.RoleAssignment{
+			RoleId: testRoleId, ReceiverKind: itRole.AssignmentReceiverGroup, ReceiverId: testGroupId,
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(testCase.itemType))
+
+			result, err := fixture.svc.DecideReviewItem(actorContext(testOwnerId), decide(domain.AccessReviewDecisionRevoked))
+
+			require.NoError(t, err)
+			require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+			assert.Equal(t, domain.AccessReviewDecisionRevoked, fixture.decision())
+			assert.Equal(t, []itRole.RoleAssignment{testCase.expected}, fixture.grants.revoked)
+			rebuilt := append(fixture.permissions.rebuiltUsers, fixture.permissions.rebuiltGroups...)
+			assert.Equal(t, []model.Id{testCase.expected.ReceiverId}, rebuilt)
+		})
+	}
+}
+
+func TestDecideByAStrangerIsRefused(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+
+	result, err := fixture.svc.DecideReviewItem(actorContext(testStrangerId), decide(domain.AccessReviewDecisionRevoked))
+
+	assertRefused(t, result, err, ft.ErrorKey("err_not_access_reviewer", "iam"))
+	assert.Empty(t, fixture.grants.revoked)
+}
+
+func TestDecideByAReviewAnyPermissionHolderIsAccepted(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+	reviewAny := reguard.BuildExpression(
+		c.ActionReviewAny, domain.AccessReviewItemSchemaName, reguard.ResourceScopeDomain, nil,
+	)
+
+	result, err := fixture.svc.DecideReviewItem(
+		actorContext(testStrangerId, reviewAny), decide(domain.AccessReviewDecisionKept),
+	)
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, domain.AccessReviewDecisionKept, fixture.decision())
+}
+
+func TestDecideRefusedOutsideAnOpenCampaign(t *testing.T) {
+	for _, status := range []domain.AccessReviewStatus{domain.AccessReviewStatusDraft, domain.AccessReviewStatusClosed} {
+		t.Run(string(status), func(t *testing.T) {
+			fixture := newAccessReviewFixture(t, status, pendingReviewItem(domain.AccessReviewItemRoleUser))
+
+			result, err := fixture.svc.DecideReviewItem(actorContext(testOwnerId), decide(domain.AccessReviewDecisionRevoked))
+
+			assertRefused(t, result, err, ft.ErrorKey("err_access_review_wrong_status", "iam"))
+			assert.Empty(t, fixture.grants.revoked)
+		})
+	}
+}
+
+func TestDecideOnADecidedItemIsRefused(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+	fixture.items.rows[testItemId][domain.AccessReviewItemFieldDecision] = string(domain.AccessReviewDecisionKept)
+
+	result, err := fixture.svc.DecideReviewItem(actorContext(testOwnerId), decide(domain.AccessReviewDecisionRevoked))
+
+	assertRefused(t, result, err, ft.ErrorKey("err_access_review_item_decided", "iam"))
+	assert.Equal(t, domain.AccessReviewDecisionKept, fixture.decision())
+	assert.Empty(t, fixture.grants.revoked)
+}
+
+func TestCloseCampaignClosesOnlyAnOpenCampaign(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+
+	result, err := fixture.svc.CloseCampaign(actorContext(testOwnerId), itAr.CloseCampaignCommand{
+		Id: testCampaignId, Etag: testEtag,
+	})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, domain.AccessReviewStatusClosed, fixture.campaignStatus())
+	assert.Equal(t, domain.AccessReviewDecisionPending, fixture.decision(),
+		"a close by hand leaves what nobody reviewed pending")
+
+	draft := newAccessReviewFixture(t, domain.AccessReviewStatusDraft, pendingReviewItem(domain.AccessReviewItemRoleUser))
+	result, err = draft.svc.CloseCampaign(actorContext(testOwnerId), itAr.CloseCampaignCommand{
+		Id: testCampaignId, Etag: testEtag,
+	})
+	assertRefused(t, result, err, ft.ErrorKey("err_access_review_wrong_status", "iam"))
+}
+
+func TestAutoRevokeItemRevokesAPendingItem(t *testing.T) {
+	fixture := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+
+	revoked, err := fixture.svc.AutoRevokeItem(actorContext(""), *domain.NewAccessReviewItemFrom(
+		pendingReviewItem(domain.AccessReviewItemRoleUser)))
+
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, domain.AccessReviewDecisionAutoRevoked, fixture.decision())
+	assert.Len(t, fixture.grants.revoked, 1)
+}
+
+// The sweep works from a batch read before the item is revoked. An item a reviewer kept in
+// between is reported as not revoked and left as it is.
+func TestAutoRevokeItemLeavesAnItemKeptInBetween(t *testing.T) {
+	kept := newAccessReviewFixture(t, domain.AccessReviewStatusOpen, pendingReviewItem(domain.AccessReviewItemRoleUser))
+	kept.items.rows[testItemId][domain.AccessReviewItemFieldDecision] = string(domain.AccessReviewDecisionKept)
+
+	revoked, err := kept.svc.AutoRevokeItem(actorContext(""), *domain.NewAccessReviewItemFrom(
+		pendingReviewItem(domain.AccessReviewItemRoleUser)))
+
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Equal(t, domain.AccessReviewDecisionKept, kept.decision())
+	assert.Empty(t, kept.grants.revoked)
+}
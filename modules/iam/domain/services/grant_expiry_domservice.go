@@ -0,0 +1,113 @@
+package services
+
+import (
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+// grantExpiryBatchSize bounds one sweep. A backlog larger than this is worked off over
+// the following runs rather than held in memory at once.
+const grantExpiryBatchSize = 500
+
+func NewGrantExpiryDomainServiceImpl(
+	assignmentRepo itRole.RoleAssignmentRepository,
+	permRepo itPerm.PermissionRepository,
+	historyRepo itPerm.PermissionHistoryRepository,
+	notifier itRole.GrantExpiryNotifier,
+) itRole.GrantExpiryDomainService {
+	return &GrantExpiryDomainServiceImpl{
+		assignmentRepo: assignmentRepo,
+		permRepo:       permRepo,
+		notifier:       notifier,
+		auditor:        permissionAuditor{historyRepo: historyRepo},
+	}
+}
+
+// GrantExpiryDomainServiceImpl ends the assignments an approved request made time-bound.
+//
+// The permission cache already ignores rows past their expiry, so access stops at the
+// deadline whether or not the sweep has run. What the sweep adds is the record: the
+// assignment leaves the table, the cache is rebuilt to match, and the trail says the
+// grant ran out rather than leaving it to be inferred from a timestamp.
+type GrantExpiryDomainServiceImpl struct {
+	assignmentRepo itRole.RoleAssignmentRepository
+	permRepo       itPerm.PermissionRepository
+	notifier       itRole.GrantExpiryNotifier
+	auditor        permissionAuditor
+}
+
+func (this *GrantExpiryDomainServiceImpl) FindExpiredGrants(
+	ctx corectx.Context, asOf time.Time,
+) ([]itRole.RoleAssignment, error) {
+	assignments, err := this.assignmentRepo.FindExpired(ctx, asOf, grantExpiryBatchSize)
+	return assignments, errors.Wrap(err, "find expired grants")
+}
+
+// RevokeExpiredGrant deletes one expired assignment, rebuilds its holders' permissions
+// and writes the audit row, in one transaction. Returns false when the assignment was
+// no longer expired by the time it was reached, typically because it was renewed.
+func (this *GrantExpiryDomainServiceImpl) RevokeExpiredGrant(
+	ctx corectx.Context, assignment itRole.RoleAssignment, asOf time.Time,
+) (bool, error) {
+	revoked, err := corecrud.ExecInTranx(ctx, this.assignmentRepo, func(tranxCtx corectx.Context) (*bool, error) {
+		deleted, err := this.assignmentRepo.DeleteExpired(tranxCtx, assignment, asOf)
+		if err != nil || !deleted {
+			return &deleted, err
+		}
+		if err := rebuildAssignmentHolders(tranxCtx, this.permRepo, assignment); err != nil {
+			return nil, err
+		}
+		reason := domain.PermissionHistoryReasonRoleExpired
+		if assignment.ReceiverKind == itRole.AssignmentReceiverGroup {
+			reason = domain.PermissionHistoryReasonRoleExpiredGroup
+		}
+		err = this.auditor.recordAssignmentTransition(
+			tranxCtx, domain.PermissionHistoryEffectRevoke, reason, assignment, nil,
+		)
+		return &deleted, err
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "revoke expired grant '%s'", assignment.Id)
+	}
+	return *revoked, nil
+}
+
+func (this *GrantExpiryDomainServiceImpl) FindGrantsNearingExpiry(
+	ctx corectx.Context, asOf time.Time, until time.Time,
+) ([]itRole.RoleAssignment, error) {
+	assignments, err := this.assignmentRepo.FindExpiringUnnotified(ctx, asOf, until, grantExpiryBatchSize)
+	return assignments, errors.Wrap(err, "find grants nearing expiry")
+}
+
+// NotifyGrantNearingExpiry sends the notice and then marks it sent.
+//
+// In that order on purpose: if the mark fails, the next run sends the notice again,
+// and a receiver warned twice is better off than one never warned at all. The mark is
+// stamped with the sweep's own clock, not the wall clock, so both agree on "now".
+func (this *GrantExpiryDomainServiceImpl) NotifyGrantNearingExpiry(
+	ctx corectx.Context, assignment itRole.RoleAssignment, notifiedAt time.Time,
+) error {
+	if err := this.notifier.NotifyGrantExpiring(ctx, assignment); err != nil {
+		return errors.Wrapf(err, "notify expiry of grant '%s'", assignment.Id)
+	}
+	err := this.assignmentRepo.MarkExpiryNotified(ctx, assignment, notifiedAt)
+	return errors.Wrapf(err, "mark grant '%s' as notified", assignment.Id)
+}
+
+// rebuildAssignmentHolders refreshes the cached permissions of whoever holds the
+// assignment: the user, or every member of the group.
+func rebuildAssignmentHolders(
+	ctx corectx.Context, permRepo itPerm.PermissionRepository, assignment itRole.RoleAssignment,
+) error {
+	if assignment.ReceiverKind == itRole.AssignmentReceiverGroup {
+		return permRepo.RebuildUserPermissionsForGroup(ctx, assignment.ReceiverId)
+	}
+	return permRepo.RebuildUserPermission(ctx, assignment.ReceiverId)
+}
@@ -4,10 +4,13 @@ import deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
 
 func InitDomainServices() error {
 	return deps.Register(
+		NewAccessReviewDomainServiceImpl,
 		NewActionDomainService,
 		NewAttemptDomainServiceImpl,
 		NewEntitlementDomainServiceImpl,
+		NewGrantExpiryDomainServiceImpl,
 		NewGroupDomainServiceImpl,
+		NewInvitationDomainServiceImpl,
 		NewLoginDomainServiceImpl,
 		NewOrganizationDomainServiceImpl,
 		NewOrgUnitDomainServiceImpl,
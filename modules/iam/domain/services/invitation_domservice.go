@@ -0,0 +1,372 @@
+package services
+
+import (
+	stdErr "errors"
+	"net/url"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+	"go.uber.org/dig"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	coreConst "github.com/sky-as-code/nikki-erp/modules/core/constants"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+	itInv "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+	itPwd "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/password"
+	itUser "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/user"
+)
+
+// defaultInvitationExpiryHours matches config.default.yaml.
+const defaultInvitationExpiryHours = 72
+
+// errInvitationRaced rolls back an acceptance that lost to another one of the same
+// token: the user's etag had moved by the time it went to activate them.
+var errInvitationRaced = stdErr.New("invitation was accepted concurrently")
+
+type InvitationServiceParams struct {
+	dig.In
+
+	ConfigSvc         config.ConfigService
+	Logger            logging.LoggerService
+	UserRepo          itUser.UserRepository
+	PasswordStoreRepo itPwd.PasswordStoreRepository
+	PasswordSvc       itPwd.PasswordDomainService
+	Mailer            itExt.Mailer
+}
+
+func NewInvitationDomainServiceImpl(params InvitationServiceParams) (itInv.InvitationDomainService, error) {
+	templates, err := loadInvitationTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &InvitationDomainServiceImpl{
+		configSvc:         params.ConfigSvc,
+		logger:            params.Logger,
+		userRepo:          params.UserRepo,
+		passwordStoreRepo: params.PasswordStoreRepo,
+		passwordSvc:       params.PasswordSvc,
+		mailer:            params.Mailer,
+		templates:         templates,
+	}, nil
+}
+
+// InvitationDomainServiceImpl invites users by email and lets them in once they choose a
+// password.
+//
+// A user is invitable while they are a draft or already invited: an active user has
+// a password, and a suspended one was shut out on purpose. The first invitation moves a
+// draft to invited; accepting moves invited to active.
+type InvitationDomainServiceImpl struct {
+	configSvc         config.ConfigService
+	logger            logging.LoggerService
+	userRepo          itUser.UserRepository
+	passwordStoreRepo itPwd.PasswordStoreRepository
+	passwordSvc       itPwd.PasswordDomainService
+	mailer            itExt.Mailer
+	templates         map[string]invitationTemplates
+}
+
+// SendInvitation issues a new token, which replaces any earlier one, and emails its link.
+// The email is sent inside the transaction, so an invitation that could not be delivered
+// leaves the earlier link working and the user's status unchanged.
+func (this *InvitationDomainServiceImpl) SendInvitation(
+	ctx corectx.Context, cmd itInv.SendInvitationCommand,
+) (_ *itInv.SendInvitationResult, err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "send invitation"); e != nil {
+			err = e
+		}
+	}()
+
+	sanitized, cErrs := cmd.GetSchema().ValidateStruct(cmd)
+	if cErrs.Count() > 0 {
+		return &itInv.SendInvitationResult{ClientErrors: cErrs}, nil
+	}
+	cmd = *sanitized.(*itInv.SendInvitationCommand)
+
+	user, err := this.findUser(ctx, cmd.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		cErrs.Append(*ft.NewNotFoundError("user_id"))
+		return &itInv.SendInvitationResult{ClientErrors: cErrs}, nil
+	}
+	this.assertInvitable(*user, &cErrs)
+	if cErrs.Count() > 0 {
+		return &itInv.SendInvitationResult{ClientErrors: cErrs}, nil
+	}
+
+	secret, err := this.tokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(
+		this.configSvc.GetInt(c.InvitationExpiryHours, defaultInvitationExpiryHours)) * time.Hour)
+	token, err := newInvitationToken(cmd.UserId, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	expiresAtModel := model.ModelDateTime(expiresAt)
+
+	message, err := renderInvitationMail(this.templates, cmd.Lang, user.MustGetEmail(), invitationMailData{
+		AppName:     this.configSvc.GetStr(coreConst.AppName, ""),
+		DisplayName: user.MustGetDisplayName(),
+		AcceptUrl:   this.acceptUrl(token.sign(secret)),
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := corecrud.ExecInTranx(ctx, this.userRepo, func(tranxCtx corectx.Context) (*itInv.SendInvitationResult, error) {
+		nonceHash := token.nonceHash()
+		err := upsertPasswordStore(
+			tranxCtx, this.passwordStoreRepo, models.PrincipalTypeNikkiUser, cmd.UserId,
+			models.PasswordStoreTypeInvitation, &nonceHash, &expiresAtModel, nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if user.MustGetStatus() == models.UserStatusDraft {
+			if err := this.setUserStatus(tranxCtx, *user, models.UserStatusInvited); err != nil {
+				return nil, err
+			}
+		}
+		return &itInv.SendInvitationResult{
+			Data: itInv.SendInvitationResultData{
+				Email:     user.MustGetEmail(),
+				ExpiresAt: expiresAtModel,
+			},
+			HasData: true,
+		}, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "send invitation to user '%s'", cmd.UserId)
+	}
+
+	// The email goes out once the token it carries is committed. Sent inside the transaction, a
+	// slow mail server would hold the user's row locked, and a rollback after sending would leave
+	// the invitee with a link to a token that was never stored. A failed send leaves a stored
+	// token nobody received, which the next invitation replaces.
+	if err := this.mailer.Send(ctx, *message); err != nil {
+		return nil, errors.Wrapf(err, "deliver invitation email to user '%s'", cmd.UserId)
+	}
+	return result, nil
+}
+
+// AcceptInvitation checks the token, then sets the password and activates the user in one
+// transaction. Every way a token can be unusable is reported alike, so the answer does not
+// tell a guesser which users exist or were invited.
+func (this *InvitationDomainServiceImpl) AcceptInvitation(
+	ctx corectx.Context, cmd itInv.AcceptInvitationCommand,
+) (_ *itInv.AcceptInvitationResult, err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "accept invitation"); e != nil {
+			err = e
+		}
+	}()
+
+	sanitized, cErrs := cmd.GetSchema().ValidateStruct(cmd)
+	if cErrs.Count() > 0 {
+		return &itInv.AcceptInvitationResult{ClientErrors: cErrs}, nil
+	}
+	cmd = *sanitized.(*itInv.AcceptInvitationCommand)
+
+	secret, err := this.tokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	token := parseInvitationToken(secret, cmd.Token)
+	if token == nil {
+		return invalidInvitationResult(), nil
+	}
+	if !token.expiresAt.After(time.Now()) {
+		cErrs.Append(*ft.NewBusinessViolation(
+			"token", ft.ErrorKey("err_invitation_expired", "iam"),
+			"This invitation has expired. Ask for a new one.",
+		))
+		return &itInv.AcceptInvitationResult{ClientErrors: cErrs}, nil
+	}
+
+	user, err := this.findUser(ctx, token.userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.MustIsArchived() || user.MustGetStatus() != models.UserStatusInvited {
+		return invalidInvitationResult(), nil
+	}
+	isLive, err := this.isLiveToken(ctx, *token)
+	if err != nil {
+		return nil, err
+	}
+	if !isLive {
+		return invalidInvitationResult(), nil
+	}
+
+	result, err := corecrud.ExecInTranx(ctx, this.userRepo, func(tranxCtx corectx.Context) (*itInv.AcceptInvitationResult, error) {
+		setResult, err := this.passwordSvc.SetPassword(
+			withInvitationAccepted(tranxCtx, token.userId),
+			itPwd.SetPasswordCommand{
+				PrincipalType: models.PrincipalTypeNikkiUser,
+				PrincipalId:   token.userId,
+				NewPassword:   cmd.NewPassword,
+			},
+		)
+		if err != nil || setResult.ClientErrors.Count() > 0 {
+			// A refused password wrote nothing, so the token stays usable for another try.
+			return setResult, err
+		}
+
+		now := model.NewModelDateTime()
+		err = upsertPasswordStore(
+			tranxCtx, this.passwordStoreRepo, models.PrincipalTypeNikkiUser, token.userId,
+			models.PasswordStoreTypeInvitation, nil, nil, &now,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := this.setUserStatus(tranxCtx, *user, models.UserStatusActive); err != nil {
+			return nil, err
+		}
+		return setResult, nil
+	})
+	if stdErr.Is(err, errInvitationRaced) {
+		return invalidInvitationResult(), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "accept invitation of user '%s'", token.userId)
+	}
+	return result, nil
+}
+
+func (this *InvitationDomainServiceImpl) assertInvitable(user models.User, cErrs *ft.ClientErrors) {
+	status := user.MustGetStatus()
+	if user.MustIsArchived() || (status != models.UserStatusDraft && status != models.UserStatusInvited) {
+		cErrs.Append(*ft.NewBusinessViolation(
+			"user_id", ft.ErrorKey("err_user_not_invitable", "iam"),
+			"Only a draft or invited user who is not archived can be sent an invitation.",
+		))
+		return
+	}
+	if email := user.GetEmail(); email == nil || *email == "" {
+		cErrs.Append(*ft.NewBusinessViolation(
+			"user_id", ft.ErrorKey("err_user_no_email", "iam"),
+			"The user has no email to send the invitation to.",
+		))
+	}
+}
+
+// isLiveToken reports whether the token is the user's latest invitation and has not been
+// used. The stored expiry is checked too, though the signed one already was, so that
+// shortening it in the database is enough to withdraw a link.
+func (this *InvitationDomainServiceImpl) isLiveToken(ctx corectx.Context, token invitationToken) (bool, error) {
+	found, err := this.passwordStoreRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{
+			models.PasswordStoreFieldPrincipalType: string(models.PrincipalTypeNikkiUser),
+			models.PasswordStoreFieldPrincipalId:   string(token.userId),
+			models.PasswordStoreFieldType:          string(models.PasswordStoreTypeInvitation),
+		},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "find invitation")
+	}
+	if found.ClientErrors.Count() > 0 || !found.HasData {
+		return false, nil
+	}
+	storedHash := found.Data.GetHash()
+	if storedHash == nil || !token.matchesNonceHash(*storedHash) {
+		return false, nil
+	}
+	storedExpiry := found.Data.GetExpiresAt()
+	return storedExpiry == nil || !storedExpiry.BeforeT(time.Now()), nil
+}
+
+func (this *InvitationDomainServiceImpl) setUserStatus(
+	ctx corectx.Context, user models.User, status models.UserStatus,
+) error {
+	update := models.NewUser()
+	update.SetId(user.GetId())
+	update.SetEtag(user.GetEtag())
+	update.SetStatus(&status)
+
+	result, err := corecrud.Update(ctx, corecrud.UpdateParam[models.User, *models.User]{
+		Action:       "set invited user status",
+		DbRepoGetter: this.userRepo,
+		Data:         update,
+	})
+	if err != nil {
+		return err
+	}
+	if result.ClientErrors.Count() > 0 {
+		return errInvitationRaced
+	}
+	return nil
+}
+
+func (this *InvitationDomainServiceImpl) findUser(ctx corectx.Context, userId model.Id) (*models.User, error) {
+	found, err := this.userRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{models.UserFieldId: string(userId)},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "find invited user")
+	}
+	if found.ClientErrors.Count() > 0 || !found.HasData {
+		return nil, nil
+	}
+	return &found.Data, nil
+}
+
+// tokenSecret signs invitations with the same key as the other tokens this system issues.
+func (this *InvitationDomainServiceImpl) tokenSecret() ([]byte, error) {
+	secret := this.configSvc.GetStr(coreConst.TokenSecretKey, "")
+	if secret == "" {
+		return nil, errors.Errorf("%s must be set to sign invitations", coreConst.TokenSecretKey)
+	}
+	return []byte(secret), nil
+}
+
+func (this *InvitationDomainServiceImpl) acceptUrl(signedToken string) string {
+	base := this.configSvc.GetStr(c.InvitationAcceptUrl, "")
+	query := url.Values{"token": []string{signedToken}}.Encode()
+	parsed, err := url.Parse(base)
+	if err != nil || parsed.RawQuery == "" {
+		return base + "?" + query
+	}
+	return base + "&" + query
+}
+
+func invalidInvitationResult() *itInv.AcceptInvitationResult {
+	cErrs := ft.NewClientErrors()
+	cErrs.Append(*ft.NewBusinessViolation(
+		"token", ft.ErrorKey("err_invitation_invalid", "iam"),
+		"This invitation link is not valid. It may have been used already or replaced by a newer one.",
+	))
+	return &itInv.AcceptInvitationResult{ClientErrors: *cErrs}
+}
+
+type invitationAcceptedCtxKey struct{}
+
+// withInvitationAccepted marks a copy of the context as carrying a verified invitation for
+// the user, which SetPassword takes in place of a current password.
+//
+// A context value rather than a command field, so that no request body can claim it.
+func withInvitationAccepted(ctx corectx.Context, userId model.Id) corectx.Context {
+	marked := corectx.CloneRequestContext(ctx)
+	marked.WithValue(invitationAcceptedCtxKey{}, userId)
+	return marked
+}
+
+func isAcceptingInvitation(ctx corectx.Context, principalId model.Id) bool {
+	userId, ok := ctx.Value(invitationAcceptedCtxKey{}).(model.Id)
+	return ok && userId == principalId
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	coreConst "github.com/sky-as-code/nikki-erp/modules/core/constants"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itInv "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+	itPwd "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/password"
+	itUser "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/user"
+)
+
+// Accepting an invitation sets a password for someone who is not signed in, so a link must
+// work once and only once. The acceptance runs here over an in-memory user and password
+// store.
+
+const testInviteeId = "01INVITEE0000000000000000A"
+
+type stubConfigService struct {
+	config.ConfigService
+}
+
+func (this stubConfigService) GetStr(name coreConst.ConfigName, defaultVal ...any) string {
+	if name == coreConst.TokenSecretKey {
+		return string(testInvitationSecret)
+	}
+	if len(defaultVal) > 0 {
+		if value, ok := defaultVal[0].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+type stubUserRepository struct {
+	itUser.UserRepository
+
+	base *stubBaseRepository
+}
+
+func (this *stubUserRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return this.base
+}
+
+func (this *stubUserRepository) BeginTransaction(ctx corectx.Context) (database.DbTransaction, error) {
+	return this.base.BeginTransaction(ctx)
+}
+
+func (this *stubUserRepository) GetOne(
+	ctx corectx.Context, param dyn.RepoGetOneParam,
+) (*dyn.OpResult[models.User], error) {
+	found, err := this.base.GetOne(ctx, param)
+	if err != nil || !found.HasData {
+		return &dyn.OpResult[models.User]{}, err
+	}
+	return &dyn.OpResult[models.User]{Data: *models.NewUserFrom(found.Data), HasData: true}, nil
+}
+
+// stubPasswordStoreRepository holds the invitee's one invitation record.
+type stubPasswordStoreRepository struct {
+	itPwd.PasswordStoreRepository
+
+	record *models.PasswordStore
+}
+
+func (this *stubPasswordStoreRepository) GetOne(
+	_ corectx.Context, _ dyn.RepoGetOneParam,
+) (*dyn.OpResult[models.PasswordStore], error) {
+	if this.record == nil {
+		return &dyn.OpResult[models.PasswordStore]{}, nil
+	}
+	return &dyn.OpResult[models.PasswordStore]{Data: *this.record, HasData: true}, nil
+}
+
+func (this *stubPasswordStoreRepository) Update(
+	_ corectx.Context, record models.PasswordStore,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	this.record = &record
+	return &dyn.OpResult[dyn.MutateResultData]{HasData: true}, nil
+}
+
+type stubPasswordService struct {
+	itPwd.PasswordDomainService
+
+	passwordsSet int
+}
+
+func (this *stubPasswordService) SetPassword(
+	ctx corectx.Context, cmd itPwd.SetPasswordCommand,
+) (*itPwd.SetPasswordResult, error) {
+	if !isAcceptingInvitation(ctx, cmd.PrincipalId) {
+		return nil, errors.New("password set without a verified invitation")
+	}
+	this.passwordsSet++
+	return &itPwd.SetPasswordResult{HasData: true}, nil
+}
+
+type invitationFixture struct {
+	svc       *InvitationDomainServiceImpl
+	users     *stubBaseRepository
+	store     *stubPasswordStoreRepository
+	passwords *stubPasswordService
+}
+
+// newInvitationFixture stores an invited user whose live invitation is the given token.
+func newInvitationFixture(t *testing.T, live *invitationToken) invitationFixture {
+	t.Helper()
+	// Normally done by CoreModule.RegisterModels during app start-up; a second call only
+	// reports the builders as already registered.
+	_ = basemodel.RegisterJsonBaseSchemas()
+
+	hash := live.nonceHash()
+	expiresAt := model.ModelDateTime(live.expiresAt)
+	record := models.NewPasswordStore()
+	record.SetHash(&hash)
+	record.SetExpiresAt(&expiresAt)
+
+	fixture := invitationFixture{
+		users: newStubBaseRepository(models.UserSchemaBuilder().Build(), dmodel.DynamicFields{
+			models.UserFieldId:        testInviteeId,
+			basemodel.FieldEtag:       testEtag,
+			basemodel.FieldIsArchived: false,
+			models.UserFieldStatus:    string(models.UserStatusInvited),
+		}),
+		store:     &stubPasswordStoreRepository{record: record},
+		passwords: &stubPasswordService{},
+	}
+	fixture.svc = &InvitationDomainServiceImpl{
+		configSvc:         stubConfigService{},
+		userRepo:          &stubUserRepository{base: fixture.users},
+		passwordStoreRepo: fixture.store,
+		passwordSvc:       fixture.passwords,
+	}
+	return fixture
+}
+
+func newLiveInvitationToken(t *testing.T) *invitationToken {
+	t.Helper()
+	token, err := newInvitationToken(testInviteeId, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	return token
+}
+
+func (this invitationFixture) accept(token *invitationToken) (*itInv.AcceptInvitationResult, error) {
+	return this.svc.AcceptInvitation(corectx.NewRequestContext(context.Background()), itInv.AcceptInvitationCommand{
+		Token:       token.sign(testInvitationSecret),
+		NewPassword: "Correct-Horse-42",
+	})
+}
+
+func (this invitationFixture) userStatus() models.UserStatus {
+	return models.NewUserFrom(this.users.rows[testInviteeId]).MustGetStatus()
+}
+
+func TestAcceptInvitationActivatesTheUser(t *testing.T) {
+	token := newLiveInvitationToken(t)
+	fixture := newInvitationFixture(t, token)
+
+	result, err := fixture.accept(token)
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, 1, fixture.passwords.passwordsSet)
+	assert.Equal(t, models.UserStatusActive, fixture.userStatus())
+	assert.Nil(t, fixture.store.record.GetHash(), "the stored nonce is spent")
+}
+
+// Even were the user put back to invited, the spent nonce no longer matches, so the link
+// stays dead.
+func TestAcceptedInvitationCannotBeReused(t *testing.T) {
+	token := newLiveInvitationToken(t)
+	fixture := newInvitationFixture(t, token)
+	_, err := fixture.accept(token)
+	require.NoError(t, err)
+
+	result, err := fixture.accept(token)
+	assertInvitationInvalid(t, result, err)
+
+	fixture.users.rows[testInviteeId][models.UserFieldStatus] = string(models.UserStatusInvited)
+	result, err = fixture.accept(token)
+	assertInvitationInvalid(t, result, err)
+	assert.Equal(t, 1, fixture.passwords.passwordsSet)
+}
+
+func TestReplacedInvitationCannotBeAccepted(t *testing.T) {
+	older, newer := newLiveInvitationToken(t), newLiveInvitationToken(t)
+	fixture := newInvitationFixture(t, newer)
+
+	result, err := fixture.accept(older)
+
+	assertInvitationInvalid(t, result, err)
+	assert.Zero(t, fixture.passwords.passwordsSet)
+	assert.Equal(t, models.UserStatusInvited, fixture.userStatus())
+}
+
+func assertInvitationInvalid(t *testing.T, result *itInv.AcceptInvitationResult, err error) {
+	t.Helper()
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Equal(t, 1, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, ft.ErrorKey("err_invitation_invalid", "iam"), result.ClientErrors[0].Key)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	htmlTmpl "html/template"
+	"strings"
+	textTmpl "text/template"
+
+	"go.bryk.io/pkg/errors"
+
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+)
+
+//go:embed templates/invitation.*.tmpl
+var invitationTemplateFiles embed.FS
+
+// invitationDefaultLang is used when the sender asks for none, or for one there is no
+// template for. Every supported language has both a text and an HTML template.
+const invitationDefaultLang = "en-US"
+
+var invitationLangs = []string{"en-US", "vi-VN"}
+
+type invitationMailData struct {
+	AppName     string
+	DisplayName string
+	AcceptUrl   string
+	ExpiresAt   string
+}
+
+type invitationTemplates struct {
+	text *textTmpl.Template
+	html *htmlTmpl.Template
+}
+
+// loadInvitationTemplates parses every language up front, so a broken template stops
+// the service from being built rather than failing the first invitation sent.
+func loadInvitationTemplates() (map[string]invitationTemplates, error) {
+	out := make(map[string]invitationTemplates, len(invitationLangs))
+	for _, lang := range invitationLangs {
+		text, err := textTmpl.ParseFS(invitationTemplateFiles, fmt.Sprintf("templates/invitation.%s.txt.tmpl", lang))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse invitation text template '%s'", lang)
+		}
+		html, err := htmlTmpl.ParseFS(invitationTemplateFiles, fmt.Sprintf("templates/invitation.%s.html.tmpl", lang))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse invitation HTML template '%s'", lang)
+		}
+		out[lang] = invitationTemplates{text: text, html: html}
+	}
+	return out, nil
+}
+
+func renderInvitationMail(
+	templates map[string]invitationTemplates, lang *string, to string, data invitationMailData,
+) (*itExt.MailMessage, error) {
+	chosen, ok := invitationTemplates{}, false
+	if lang != nil {
+		chosen, ok = templates[*lang]
+	}
+	if !ok {
+		chosen = templates[invitationDefaultLang]
+	}
+
+	var subject, text, html strings.Builder
+	if err := chosen.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, errors.Wrap(err, "render invitation subject")
+	}
+	if err := chosen.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, errors.Wrap(err, "render invitation text")
+	}
+	if err := chosen.html.Execute(&html, data); err != nil {
+		return nil, errors.Wrap(err, "render invitation HTML")
+	}
+	htmlBody := html.String()
+	return &itExt.MailMessage{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: text.String(),
+		HtmlBody: &htmlBody,
+	}, nil
+}
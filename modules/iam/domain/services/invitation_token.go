@@ -0,0 +1,100 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+)
+
+// invitationNonceBytes is the entropy of a token. The nonce is what the database
+// remembers, so it alone decides whether a well-signed token is still the live one.
+const invitationNonceBytes = 32
+
+// invitationToken is what an invitation link carries.
+//
+// The signature lets a forged or altered token be refused without a database read; the
+// nonce, whose hash is stored against the user, is what makes the token single-use and
+// lets a newer invitation replace an older one.
+type invitationToken struct {
+	userId    model.Id
+	expiresAt time.Time
+	nonce     string
+}
+
+func newInvitationToken(userId model.Id, expiresAt time.Time) (*invitationToken, error) {
+	raw := make([]byte, invitationNonceBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.Wrap(err, "generate invitation nonce")
+	}
+	return &invitationToken{
+		userId:    userId,
+		expiresAt: expiresAt,
+		nonce:     base64.RawURLEncoding.EncodeToString(raw),
+	}, nil
+}
+
+// sign encodes the token as "<payload>.<signature>", both base64url, so it can go into a
+// URL as it is.
+func (this invitationToken) sign(secret []byte) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strings.Join([]string{
+		string(this.userId), strconv.FormatInt(this.expiresAt.Unix(), 10), this.nonce,
+	}, ".")))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(invitationSignature(secret, payload))
+}
+
+// nonceHash is what is stored. A leaked table row is not a usable link.
+func (this invitationToken) nonceHash() string {
+	sum := sha256.Sum256([]byte(this.nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+func (this invitationToken) matchesNonceHash(storedHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(this.nonceHash()), []byte(storedHash)) == 1
+}
+
+// parseInvitationToken returns nil for a token that is malformed or not signed with
+// the secret. Expiry is left to the caller, which reports it differently.
+func parseInvitationToken(secret []byte, signed string) *invitationToken {
+	payload, signature, found := strings.Cut(signed, ".")
+	if !found {
+		return nil
+	}
+	givenSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(givenSignature, invitationSignature(secret, payload)) {
+		return nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil
+	}
+	parts := strings.Split(string(decoded), ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	expiresUnix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &invitationToken{
+		userId:    model.Id(parts[0]),
+		expiresAt: time.Unix(expiresUnix, 0),
+		nonce:     parts[2],
+	}
+}
+
+func invitationSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// An invitation link is a credential handed to someone who is not signed in. Everything a
+// holder of the link could change about it must make it unreadable, and the nonce alone
+// decides whether a well-signed link is still the live one.
+
+var testInvitationSecret = []byte("invitation-test-secret")
+
+func newTestInvitationToken(t *testing.T) *invitationToken {
+	t.Helper()
+	token, err := newInvitationToken("01USER0000000000000000000A", time.Unix(1767225600, 0))
+	require.NoError(t, err)
+	return token
+}
+
+func TestInvitationTokenRoundTrip(t *testing.T) {
+	token := newTestInvitationToken(t)
+
+	parsed := parseInvitationToken(testInvitationSecret, token.sign(testInvitationSecret))
+
+	require.NotNil(t, parsed)
+	assert.Equal(t, token.userId, parsed.userId)
+	assert.True(t, token.expiresAt.Equal(parsed.expiresAt))
+	assert.Equal(t, token.nonce, parsed.nonce)
+	assert.True(t, parsed.matchesNonceHash(token.nonceHash()))
+}
+
+func TestInvitationTokensDoNotRepeat(t *testing.T) {
+	first, second := newTestInvitationToken(t), newTestInvitationToken(t)
+
+	assert.NotEqual(t, first.nonce, second.nonce)
+	assert.NotEqual(t, first.sign(testInvitationSecret), second.sign(testInvitationSecret))
+}
+
+func TestParseInvitationTokenRefusesTamperedPayload(t *testing.T) {
+	token := newTestInvitationToken(t)
+	_, signature, _ := strings.Cut(token.sign(testInvitationSecret), ".")
+
+	for name, tampered := range map[string]invitationToken{
+		"user":   {userId: "01OTHERUSER00000000000000A", expiresAt: token.expiresAt, nonce: token.nonce},
+		"expiry": {userId: token.userId, expiresAt: token.expiresAt.Add(24 * time.Hour), nonce: token.nonce},
+		"nonce":  {userId: token.userId, expiresAt: token.expiresAt, nonce: token.nonce + "x"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload, _, _ := strings.Cut(tampered.sign(testInvitationSecret), ".")
+
+			assert.Nil(t, parseInvitationToken(testInvitationSecret, payload+"."+signature))
+		})
+	}
+}
+
+func TestParseInvitationTokenRefusesTamperedSignature(t *testing.T) {
+	payload, signature, _ := strings.Cut(newTestInvitationToken(t).sign(testInvitationSecret), ".")
+	raw, err := base64.RawURLEncoding.DecodeString(signature)
+	require.NoError(t, err)
+	raw[0] ^= 0x01
+
+	assert.Nil(t, parseInvitationToken(testInvitationSecret, payload+"."+base64.RawURLEncoding.EncodeToString(raw)))
+	assert.Nil(t, parseInvitationToken(testInvitationSecret, payload+"."+signature[:len(signature)-2]),
+		"a truncated signature")
+}
+
+func TestParseInvitationTokenRefusesAnotherSecret(t *testing.T) {
+	signed := newTestInvitationToken(t).sign([]byte("some-other-secret"))
+
+	assert.Nil(t, parseInvitationToken(testInvitationSecret, signed))
+}
+
+// Each of these is signed with the right secret, so only the shape of the payload is wrong.
+func TestParseInvitationTokenRefusesMalformedSegments(t *testing.T) {
+	signedPayload := func(payload string) string {
+		return payload + "." + base64.RawURLEncoding.EncodeToString(invitationSignature(testInvitationSecret, payload))
+	}
+	encode := func(decoded string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(decoded))
+	}
+
+	for name, signed := range map[string]string{
+		"empty":                 "",
+		"no separator":          encode("01USER0000000000000000000A.1767225600.nonce"),
+		"signature not base64":  encode("a.1.b") + ".!!!",
+		"payload not base64":    signedPayload("!!!"),
+		"too few parts":         signedPayload(encode("01USER0000000000000000000A.1767225600")),
+		"too many parts":        signedPayload(encode("01USER0000000000000000000A.1767225600.nonce.extra")),
+		"expiry not a number":   signedPayload(encode("01USER0000000000000000000A.tomorrow.nonce")),
+		"extra token separator": newTestInvitationToken(t).sign(testInvitationSecret) + ".extra",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, parseInvitationToken(testInvitationSecret, signed))
+		})
+	}
+}
+
+// A newer invitation stores a new hash, so the older link, though well signed, no longer
+// matches.
+func TestInvitationTokenNonceHashMismatch(t *testing.T) {
+	older, newer := newTestInvitationToken(t), newTestInvitationToken(t)
+
+	assert.True(t, newer.matchesNonceHash(newer.nonceHash()))
+	assert.False(t, older.matchesNonceHash(newer.nonceHash()))
+	assert.False(t, older.matchesNonceHash(""))
+	assert.NotContains(t, older.nonceHash(), older.nonce, "the stored hash does not reveal the nonce")
+}
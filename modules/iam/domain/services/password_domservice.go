@@ -314,6 +314,11 @@ func (this *PasswordDomainServiceImpl) SetPassword(ctx corectx.Context, cmd it.S
 				curPassHash = []byte(*stores.getPasswordHash())
 			}
 			if cmd.CurrentPassword == nil {
+				// An invitee choosing their first password has none to give; the
+				// invitation token they presented stands in for it.
+				if curPassHash == nil && isAcceptingInvitation(ctx, cmd.PrincipalId) {
+					return nil
+				}
 				cErrs.Append(*ft.NewValidationError(
 					"current_password",
 					ft.ErrorKey("err_current_password_required", "iam"),
@@ -382,7 +382,23 @@ func (this *PasswordDomainServiceImpl) upsertPasswordStoreHash(
 	expiresAt *model.ModelDateTime,
 	lastUsedAt *model.ModelDateTime,
 ) error {
-	existingResult, err := this.passwordStoreRepo.GetOne(ctx, dyn.RepoGetOneParam{
+	return upsertPasswordStore(
+		ctx, this.passwordStoreRepo, principalType, principalId, passwordType, hash, expiresAt, lastUsedAt)
+}
+
+// upsertPasswordStore writes the principal's store of the given type, creating it on first use.
+// It is shared with the invitation service, which keeps its token nonce in the same table.
+func upsertPasswordStore(
+	ctx corectx.Context,
+	passwordStoreRepo it.PasswordStoreRepository,
+	principalType models.PrincipalType,
+	principalId model.Id,
+	passwordType models.PasswordStoreType,
+	hash *string,
+	expiresAt *model.ModelDateTime,
+	lastUsedAt *model.ModelDateTime,
+) error {
+	existingResult, err := passwordStoreRepo.GetOne(ctx, dyn.RepoGetOneParam{
 		Filter: dmodel.DynamicFields{
 			models.PasswordStoreFieldPrincipalType: string(principalType),
 			models.PasswordStoreFieldPrincipalId:   string(principalId),
@@ -418,7 +434,7 @@ func (this *PasswordDomainServiceImpl) upsertPasswordStoreHash(
 	record.SetLastUsedAt(lastUsedAt)
 
 	if existingResult.HasData {
-		updateResult, err := this.passwordStoreRepo.Update(ctx, record)
+		updateResult, err := passwordStoreRepo.Update(ctx, record)
 		if err != nil {
 			return err
 		}
@@ -428,7 +444,7 @@ func (this *PasswordDomainServiceImpl) upsertPasswordStoreHash(
 		return nil
 	}
 
-	insertResult, err := this.passwordStoreRepo.Insert(ctx, record)
+	insertResult, err := passwordStoreRepo.Insert(ctx, record)
 	if err != nil {
 		return err
 	}
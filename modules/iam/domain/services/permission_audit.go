@@ -5,6 +5,7 @@ import (
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
 	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
 )
 
 // permissionAuditor writes the append-only trail of grants and revocations.
@@ -57,6 +58,41 @@ func (this permissionAuditor) recordRoleTransition(
 	return nil
 }
 
+// recordAssignmentTransition records a grant or revocation of one assignment that
+// came from a request, so the row carries the granting request - and the revoking one,
+// when there is one - as well as the role and the receiver. The expiry sweep runs
+// without a signed-in user, so its rows name no approver: the request that set the
+// expiry is the authority behind them.
+func (this permissionAuditor) recordAssignmentTransition(
+	ctx corectx.Context,
+	effect domain.PermissionHistoryEffect,
+	reason domain.PermissionHistoryReason,
+	assignment itRole.RoleAssignment,
+	revokeRequestId *model.Id,
+) error {
+	if this.historyRepo == nil {
+		return nil
+	}
+	entry := domain.NewPermissionHistory()
+	newId, err := model.NewId()
+	if err != nil {
+		return err
+	}
+	entry.SetId(newId)
+	entry.SetEffect(effect)
+	entry.SetReason(reason)
+	roleId := assignment.RoleId
+	entry.SetRoleId(&roleId)
+	receiverId := assignment.ReceiverId
+	entry.SetReceiverId(&receiverId)
+	entry.SetRoleRequestId(assignment.RoleRequestId)
+	entry.SetRevokeRequestId(revokeRequestId)
+	entry.SetApproverId(actorOf(ctx))
+
+	_, err = this.historyRepo.Insert(ctx, *entry)
+	return err
+}
+
 // recordEntitlementTransition records a change to what a role grants, rather than
 // to who holds it. The receiver is left empty on purpose: the change applies to
 // every current and future holder of the role, and naming a snapshot of them here
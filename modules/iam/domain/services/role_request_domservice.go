@@ -1,25 +1,47 @@
 package services
 
 import (
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	"github.com/sky-as-code/nikki-erp/common/safe"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	"github.com/sky-as-code/nikki-erp/modules/core/cqrs"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
 	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itGrp "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/group"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
 	itRr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/rolerequest"
 )
 
 func NewRoleRequestDomainServiceImpl(
 	roleRequestRepo itRr.RoleRequestRepository,
+	roleRepo itRole.RoleRepository,
+	groupRepo itGrp.GroupRepository,
+	assignmentRepo itRole.RoleAssignmentRepository,
+	permRepo itPerm.PermissionRepository,
+	historyRepo itPerm.PermissionHistoryRepository,
 	cqrsBus cqrs.CqrsBus,
 ) itRr.RoleRequestDomainService {
-	return &RoleRequestDomainServiceImpl{cqrsBus: cqrsBus, roleRequestRepo: roleRequestRepo}
+	return &RoleRequestDomainServiceImpl{
+		cqrsBus:         cqrsBus,
+		roleRequestRepo: roleRequestRepo,
+		roleRepo:        roleRepo,
+		groupRepo:       groupRepo,
+		assignmentRepo:  assignmentRepo,
+		permRepo:        permRepo,
+		auditor:         permissionAuditor{historyRepo: historyRepo},
+	}
 }
 
 type RoleRequestDomainServiceImpl struct {
 	cqrsBus         cqrs.CqrsBus
 	roleRequestRepo itRr.RoleRequestRepository
+	roleRepo        itRole.RoleRepository
+	groupRepo       itGrp.GroupRepository
+	assignmentRepo  itRole.RoleAssignmentRepository
+	permRepo        itPerm.PermissionRepository
+	auditor         permissionAuditor
 }
 
 func (this *RoleRequestDomainServiceImpl) CreateRoleRequest(
@@ -27,9 +49,19 @@ func (this *RoleRequestDomainServiceImpl) CreateRoleRequest(
 ) (*itRr.CreateRoleRequestResult, error) {
 	opts := safe.GetOptional(options, corecrud.ServiceCreateOptions[*domain.RoleRequest]{})
 	return corecrud.Create(ctx, corecrud.CreateParam[domain.RoleRequest, *domain.RoleRequest]{
-		Action:                 "create grant request",
-		BaseRepoGetter:         this.roleRequestRepo,
-		Data:                   cmd,
+		Action:         "create grant request",
+		BaseRepoGetter: this.roleRequestRepo,
+		Data:           cmd,
+		BeforeValidation: func(ctx corectx.Context, request *domain.RoleRequest, _ *ft.ClientErrors) (*domain.RoleRequest, error) {
+			// A request is always born pending, and filed by whoever is asking unless
+			// an administrator files it on someone's behalf.
+			request.SetStatus(domain.RoleReqStatusPending)
+			if request.GetRequestorId() == nil {
+				request.GetFieldData().SetModelId(domain.RoleReqFieldRequestorId, actorOf(ctx))
+			}
+			return request, nil
+		},
+		ValidateExtra:          this.ValidateNewRoleRequest,
 		AfterValidationSuccess: opts.AfterValidationSuccess,
 	})
 }
@@ -78,6 +110,10 @@ func (this *RoleRequestDomainServiceImpl) SearchRoleRequests(
 	})
 }
 
+// UpdateRoleRequest edits what a pending request asks for. Its outcome is not
+// editable here: status and the responder fields move only through
+// ApproveRoleRequest, RejectRoleRequest and CancelRoleRequest, which is what
+// guarantees that an approval always comes with its assignment and its audit row.
 func (this *RoleRequestDomainServiceImpl) UpdateRoleRequest(
 	ctx corectx.Context, cmd itRr.UpdateRoleRequestCommand, options ...corecrud.ServiceUpdateOptions[*domain.RoleRequest],
 ) (*itRr.UpdateRoleRequestResult, error) {
@@ -86,6 +122,7 @@ func (this *RoleRequestDomainServiceImpl) UpdateRoleRequest(
 		Action:                 "update grant request",
 		DbRepoGetter:           this.roleRequestRepo,
 		Data:                   cmd,
+		ValidateExtra:          this.ValidateRoleRequestEdit,
 		AfterValidationSuccess: opts.AfterValidationSuccess,
 	})
 }
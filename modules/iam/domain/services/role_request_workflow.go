@@ -0,0 +1,464 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itGrp "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/group"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+	itRr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/rolerequest"
+)
+
+// The grant request workflow.
+//
+// A request is filed pending and leaves that state exactly once: approved or rejected by
+// whoever owns the role, or cancelled by whoever filed it. Approval is the only path
+// that changes what anyone may do, so it writes the request, the assignment and the
+// audit row together; the caller runs it inside one transaction.
+
+// roleRequestOutcomeFields are the fields only the workflow writes. A generic edit that
+// changes one of them would be an approval without an assignment, or a rejection
+// nobody can be held to.
+var roleRequestOutcomeFields = []string{
+	domain.RoleReqFieldStatus,
+	domain.RoleReqFieldResponderId,
+	domain.RoleReqFieldRespondedAt,
+	domain.RoleReqFieldRejectionReason,
+}
+
+// ValidateNewRoleRequest applies the requested role's own rules to a new request.
+func (this *RoleRequestDomainServiceImpl) ValidateNewRoleRequest(
+	ctx corectx.Context, request *domain.RoleRequest, vErrs *ft.ClientErrors,
+) error {
+	role, err := this.loadRequestedRole(ctx, request, vErrs)
+	if err != nil || role == nil {
+		return err
+	}
+	validateRoleRules(role, request, vErrs)
+	return nil
+}
+
+// validateRoleRules checks a request against its role: that the role can be requested, that
+// the comment and the attachment it demands are there, and that the grant is not already over.
+func validateRoleRules(role *domain.Role, request *domain.RoleRequest, vErrs *ft.ClientErrors) {
+	validateRoleRequestable(role, vErrs)
+	if isTrue(role.IsRequiredComment()) && isBlank(request.GetRequestComment()) {
+		vErrs.Append(*ft.NewValidationError(
+			domain.RoleReqFieldRequestComment, ft.ErrorKey("err_request_comment_required", "iam"),
+			"this role requires a comment explaining why it is requested",
+		))
+	}
+	if isTrue(role.IsRequiredAttachment()) && isBlank(request.GetAttachmentUrl()) {
+		vErrs.Append(*ft.NewValidationError(
+			domain.RoleReqFieldAttachmentUrl, ft.ErrorKey("err_request_attachment_required", "iam"),
+			"this role requires an attachment supporting the request",
+		))
+	}
+	validateGrantExpiry(request, vErrs)
+}
+
+// ApproveRoleRequest approves a pending request and carries it out: a grant request
+// creates the assignment, a revoke request removes it.
+//
+// The role's rules are checked again at this point rather than trusted from filing
+// time. A role may have stopped being requestable, or started demanding a comment, while
+// the request sat in a queue, and approving it anyway would hand out something on terms
+// its owner has since withdrawn.
+// Whoever filed the request may not approve it, even as the role's owner: a grant
+// needs a second person to agree to it.
+func (this *RoleRequestDomainServiceImpl) ApproveRoleRequest(
+	ctx corectx.Context, cmd itRr.ApproveRoleRequestCommand,
+) (*itRr.ApproveRoleRequestResult, error) {
+	var approved *domain.RoleRequest
+	result, err := this.respond(ctx, respondParam{
+		action:          "approve grant request",
+		id:              cmd.Id,
+		etag:            cmd.Etag,
+		status:          domain.RoleReqStatusApproved,
+		recordResponder: true,
+		validate: func(ctx corectx.Context, found *domain.RoleRequest, vErrs *ft.ClientErrors) error {
+			validateNotRequestor(ctx, found, vErrs)
+			if vErrs.Count() > 0 {
+				return nil
+			}
+			role, err := this.loadRequestedRole(ctx, found, vErrs)
+			if err != nil || role == nil {
+				return err
+			}
+			if err := this.validateResponder(ctx, role, vErrs); err != nil {
+				return err
+			}
+			validateRoleRules(role, found, vErrs)
+			approved = found
+			return nil
+		},
+	})
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData || approved == nil {
+		return result, err
+	}
+
+	if reqType := approved.GetType(); reqType != nil && *reqType == domain.RoleReqTypeRevoke {
+		err = this.revokeApprovedRequest(ctx, approved)
+	} else {
+		err = this.grantApprovedRequest(ctx, approved)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RejectRoleRequest turns a pending request down. Only the role's owner may, for the
+// same reason only the owner may approve: a refusal is a decision about the role.
+func (this *RoleRequestDomainServiceImpl) RejectRoleRequest(
+	ctx corectx.Context, cmd itRr.RejectRoleRequestCommand,
+) (*itRr.RejectRoleRequestResult, error) {
+	return this.respond(ctx, respondParam{
+		action:          "reject grant request",
+		id:              cmd.Id,
+		etag:            cmd.Etag,
+		status:          domain.RoleReqStatusRejected,
+		rejectionReason: cmd.Reason,
+		recordResponder: true,
+		validate: func(ctx corectx.Context, found *domain.RoleRequest, vErrs *ft.ClientErrors) error {
+			role, err := this.loadRequestedRole(ctx, found, vErrs)
+			if err != nil || role == nil {
+				return err
+			}
+			return this.validateResponder(ctx, role, vErrs)
+		},
+	})
+}
+
+// CancelRoleRequest withdraws a pending request. It is the requestor's own act, so it
+// records no responder: nobody answered the request, it was taken back. A request with
+// no requestor on record, such as one whose filer was deleted, has nobody to take it
+// back, so only those allowed to respond to any request may withdraw it.
+func (this *RoleRequestDomainServiceImpl) CancelRoleRequest(
+	ctx corectx.Context, cmd itRr.CancelRoleRequestCommand,
+) (*itRr.CancelRoleRequestResult, error) {
+	return this.respond(ctx, respondParam{
+		action: "cancel grant request",
+		id:     cmd.Id,
+		etag:   cmd.Etag,
+		status: domain.RoleReqStatusCancelled,
+		validate: func(ctx corectx.Context, found *domain.RoleRequest, vErrs *ft.ClientErrors) error {
+			requestorId := found.GetRequestorId()
+			if requestorId == nil {
+				if !this.canRespondForAnyRole(ctx) {
+					vErrs.Append(*ft.NewAuthorizationError(
+						ft.ErrorKey("err_only_responder_can_cancel_orphan", "iam"),
+						"a request without a requestor can only be cancelled by someone who may respond to it",
+					))
+				}
+				return nil
+			}
+			actorId := actorOf(ctx)
+			if actorId == nil || *actorId != *requestorId {
+				vErrs.Append(*ft.NewAuthorizationError(
+					ft.ErrorKey("err_only_requestor_can_cancel", "iam"),
+					"only the user who filed the request can cancel it",
+				))
+			}
+			return nil
+		},
+	})
+}
+
+type respondParam struct {
+	action          string
+	id              model.Id
+	etag            model.Etag
+	status          domain.RoleRequestStatus
+	rejectionReason *string
+	recordResponder bool
+	validate        func(ctx corectx.Context, found *domain.RoleRequest, vErrs *ft.ClientErrors) error
+}
+
+// respond moves a pending request to its outcome through the ordinary update path, so
+// the etag check, the schema validation and the versioning all still apply.
+func (this *RoleRequestDomainServiceImpl) respond(
+	ctx corectx.Context, param respondParam,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	request := domain.NewRoleRequest()
+	request.SetId(&param.id)
+	request.SetEtag(&param.etag)
+	request.SetStatus(param.status)
+	if param.rejectionReason != nil {
+		request.SetRejectionReason(param.rejectionReason)
+	}
+	if param.recordResponder {
+		now := model.NewModelDateTime()
+		request.SetRespondedAt(&now)
+		request.SetResponderId(actorOf(ctx))
+	}
+
+	return corecrud.Update(ctx, corecrud.UpdateParam[domain.RoleRequest, *domain.RoleRequest]{
+		Action:       param.action,
+		DbRepoGetter: this.roleRequestRepo,
+		Data:         request,
+		ValidateExtra: func(
+			ctx corectx.Context, _ *domain.RoleRequest, found *domain.RoleRequest, vErrs *ft.ClientErrors,
+		) error {
+			validatePending(found, vErrs)
+			if vErrs.Count() > 0 {
+				return nil
+			}
+			return param.validate(ctx, found, vErrs)
+		},
+	})
+}
+
+// validateResponder routes the decision to the role's owner: its owner user, or any
+// member of its owner group. Anyone else needs the "respond" permission on grant
+// requests, which is also what answers requests for a role that has no owner.
+func (this *RoleRequestDomainServiceImpl) validateResponder(
+	ctx corectx.Context, role *domain.Role, vErrs *ft.ClientErrors,
+) error {
+	isOwner, err := this.isRoleOwner(ctx, role)
+	if err != nil || isOwner || this.canRespondForAnyRole(ctx) {
+		return err
+	}
+	vErrs.Append(*ft.NewAuthorizationError(
+		ft.ErrorKey("err_not_role_owner", "iam"),
+		"only the owner of the requested role can respond to this request",
+	))
+	return nil
+}
+
+// validateNotRequestor refuses a decision by the user who filed the request.
+func validateNotRequestor(ctx corectx.Context, found *domain.RoleRequest, vErrs *ft.ClientErrors) {
+	requestorId := found.GetRequestorId()
+	actorId := actorOf(ctx)
+	if requestorId != nil && actorId != nil && *requestorId == *actorId {
+		vErrs.Append(*ft.NewAuthorizationError(
+			ft.ErrorKey("err_requestor_cannot_approve", "iam"),
+			"the user who filed the request cannot approve it",
+		))
+	}
+}
+
+func (this *RoleRequestDomainServiceImpl) isRoleOwner(ctx corectx.Context, role *domain.Role) (bool, error) {
+	return isActorOwner(ctx, this.groupRepo, role.GetOwnerUserId(), role.GetOwnerGroupId())
+}
+
+// isActorOwner tells whether the signed-in user is the given owner user, or a member of
+// the given owner group. Both grant requests and access review items route a decision
+// about a role to its owner this way.
+func isActorOwner(
+	ctx corectx.Context, groupRepo itGrp.GroupRepository, ownerUserId *model.Id, ownerGroupId *model.Id,
+) (bool, error) {
+	actorId := actorOf(ctx)
+	if actorId == nil {
+		return false, nil
+	}
+	if ownerUserId != nil && *ownerUserId == *actorId {
+		return true, nil
+	}
+	if ownerGroupId == nil {
+		return false, nil
+	}
+	isMember, err := groupRepo.GetBaseRepo().ExistsM2m(ctx, dyn.RepoExistsM2mParam{
+		M2mEdge: domain.GroupEdgeUsers,
+		SrcId:   *ownerGroupId,
+		DestId:  actorId,
+	})
+	return isMember, errors.Wrap(err, "check owner group membership")
+}
+
+func (this *RoleRequestDomainServiceImpl) canRespondForAnyRole(ctx corectx.Context) bool {
+	return reguard.AssertPermission(ctx, reguard.PermFor(
+		c.ActionRespond, domain.RoleRequestSchemaName, reguard.ResourceScopeDomain,
+	)) == nil
+}
+
+func (this *RoleRequestDomainServiceImpl) loadRequestedRole(
+	ctx corectx.Context, request *domain.RoleRequest, vErrs *ft.ClientErrors,
+) (*domain.Role, error) {
+	roleId := request.GetRoleId()
+	if roleId == nil {
+		return nil, nil
+	}
+	resRole, err := this.roleRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{domain.RoleFieldId: *roleId},
+		Fields: []string{
+			domain.RoleFieldId, domain.RoleFieldOwnerUserId, domain.RoleFieldOwnerGroupId,
+			domain.RoleFieldIsRequestable, domain.RoleFieldIsRequiredAttach, domain.RoleFieldIsRequiredComment,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resRole.HasData {
+		vErrs.Append(*ft.NewNotFoundError(domain.RoleReqFieldRoleId))
+		return nil, nil
+	}
+	return &resRole.Data, nil
+}
+
+func validateRoleRequestable(role *domain.Role, vErrs *ft.ClientErrors) {
+	if !isTrue(role.IsRequestable()) {
+		vErrs.Append(*ft.NewBusinessViolation(
+			domain.RoleReqFieldRoleId, ft.ErrorKey("err_role_not_requestable", "iam"),
+			"this role cannot be requested",
+		))
+	}
+}
+
+func validatePending(found *domain.RoleRequest, vErrs *ft.ClientErrors) {
+	status := found.GetStatus()
+	if status == nil || *status != domain.RoleReqStatusPending {
+		vErrs.Append(*ft.NewBusinessViolation(
+			domain.RoleReqFieldStatus, ft.ErrorKey("err_grant_request_not_pending", "iam"),
+			"only a pending request can be changed",
+		))
+	}
+}
+
+// ValidateRoleRequestEdit refuses a generic edit that changes the outcome fields, then
+// one of a request already decided. An edit that repeats the outcome fields' current
+// values is let through, so a client that sends the whole record back is not punished
+// for it.
+//
+// What is left is checked as a new request would be, on the stored request with the edit
+// applied: an edit may otherwise move a request to a role that cannot be requested, or
+// drop the comment its role demands.
+func (this *RoleRequestDomainServiceImpl) ValidateRoleRequestEdit(
+	ctx corectx.Context, input *domain.RoleRequest, found *domain.RoleRequest, vErrs *ft.ClientErrors,
+) error {
+	inputFields := input.GetFieldData()
+	foundFields := found.GetFieldData()
+	for _, field := range roleRequestOutcomeFields {
+		value, present := inputFields[field]
+		if !present || fmt.Sprint(value) == fmt.Sprint(foundFields[field]) {
+			continue
+		}
+		vErrs.Append(*ft.NewBusinessViolation(
+			field, ft.ErrorKey("err_grant_request_outcome_not_editable", "iam"),
+			"a grant request is answered through the approve, reject or cancel action, not by editing it",
+		))
+	}
+	if vErrs.Count() == 0 {
+		validatePending(found, vErrs)
+	}
+	if vErrs.Count() > 0 {
+		return nil
+	}
+
+	merged := maps.Clone(foundFields)
+	maps.Copy(merged, inputFields)
+	return this.ValidateNewRoleRequest(ctx, domain.NewRoleRequestFrom(merged), vErrs)
+}
+
+// validateGrantExpiry refuses a grant whose expiry has already passed. The assignment
+// would be dead on arrival, and the approver would see it succeed.
+func validateGrantExpiry(request *domain.RoleRequest, vErrs *ft.ClientErrors) {
+	expiresAt := request.GetGrantExpiresAt()
+	if expiresAt != nil && !expiresAt.GoTime().After(time.Now()) {
+		vErrs.Append(*ft.NewValidationError(
+			domain.RoleReqFieldGrantExpiresAt, ft.ErrorKey("err_grant_expires_in_past", "iam"),
+			"grant_expires_at must be in the future",
+		))
+	}
+}
+
+// assignmentOf names the assignment a request is about.
+func assignmentOf(request *domain.RoleRequest) (itRole.RoleAssignment, error) {
+	assignment := itRole.RoleAssignment{RoleRequestId: request.GetId()}
+	if roleId := request.GetRoleId(); roleId != nil {
+		assignment.RoleId = *roleId
+	}
+	if groupId := request.GetReceiverGroupId(); groupId != nil {
+		assignment.ReceiverKind = itRole.AssignmentReceiverGroup
+		assignment.ReceiverId = *groupId
+	} else if userId := request.GetReceiverUserId(); userId != nil {
+		assignment.ReceiverKind = itRole.AssignmentReceiverUser
+		assignment.ReceiverId = *userId
+	} else {
+		return assignment, errors.New("grant request has no receiver")
+	}
+	return assignment, nil
+}
+
+// grantApprovedRequest creates the assignment an approved grant asks for. It carries
+// the request's grant_expires_at, which is what makes the grant time-bound: the cache
+// stops honouring it at that instant and the expiry sweep removes it afterwards.
+func (this *RoleRequestDomainServiceImpl) grantApprovedRequest(
+	ctx corectx.Context, request *domain.RoleRequest,
+) error {
+	grant, err := assignmentOf(request)
+	if err != nil {
+		return err
+	}
+	grant.ApproverId = actorOf(ctx)
+	if expiresAt := request.GetGrantExpiresAt(); expiresAt != nil {
+		goTime := expiresAt.GoTime()
+		grant.ExpiresAt = &goTime
+	}
+
+	assignment, err := this.assignmentRepo.GrantFromRequest(ctx, grant)
+	if err != nil {
+		return errors.Wrap(err, "grant approved request")
+	}
+	if assignment == nil {
+		// The receiver already holds the role permanently; nothing changed to record.
+		return nil
+	}
+	if err := rebuildAssignmentHolders(ctx, this.permRepo, *assignment); err != nil {
+		return err
+	}
+	reason := domain.PermissionHistoryReasonRoleAdded
+	if assignment.ReceiverKind == itRole.AssignmentReceiverGroup {
+		reason = domain.PermissionHistoryReasonRoleAddedGroup
+	}
+	return this.auditor.recordAssignmentTransition(
+		ctx, domain.PermissionHistoryEffectGrant, reason, *assignment, nil)
+}
+
+// revokeApprovedRequest removes the assignment an approved revoke request names. A
+// receiver who no longer holds the role leaves nothing to remove and nothing to record.
+func (this *RoleRequestDomainServiceImpl) revokeApprovedRequest(
+	ctx corectx.Context, request *domain.RoleRequest,
+) error {
+	target, err := assignmentOf(request)
+	if err != nil {
+		return err
+	}
+
+	removed, err := this.assignmentRepo.RevokeAssignment(ctx, target)
+	if err != nil {
+		return errors.Wrap(err, "revoke approved request")
+	}
+	if removed == nil {
+		return nil
+	}
+	if err := rebuildAssignmentHolders(ctx, this.permRepo, *removed); err != nil {
+		return err
+	}
+	reason := domain.PermissionHistoryReasonRoleRemoved
+	if removed.ReceiverKind == itRole.AssignmentReceiverGroup {
+		reason = domain.PermissionHistoryReasonRoleRemovedGroup
+	}
+	return this.auditor.recordAssignmentTransition(
+		ctx, domain.PermissionHistoryEffectRevoke, reason, *removed, request.GetId())
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+func isBlank(value *string) bool {
+	return value == nil || *value == ""
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ds "github.com/sky-as-code/nikki-erp/common/datastructure"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+	itRr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/rolerequest"
+)
+
+// A grant request leaves pending exactly once, and who may move it is the whole of its
+// security. The workflow runs here over in-memory rows, through the same update path the
+// database-backed service takes.
+
+const (
+	testRequestId   = "01REQUEST0000000000000000A"
+	testRoleId      = "01ROLE0000000000000000000A"
+	testOwnerId     = "01OWNER000000000000000000A"
+	testRequestorId = "01REQUESTOR00000000000000A"
+	testReceiverId  = "01RECEIVER000000000000000A"
+	testStrangerId  = "01STRANGER000000000000000A"
+	testEtag        = "etag-000001"
+)
+
+// stubBaseRepository keeps one row per id and answers the reads and the write the generic
+// update flow makes.
+type stubBaseRepository struct {
+	dyn.BaseDynamicRepository
+
+	schema  *dmodel.ModelSchema
+	rows    map[string]dmodel.DynamicFields
+	updates []dmodel.DynamicFields
+}
+
+func newStubBaseRepository(schema *dmodel.ModelSchema, rows ...dmodel.DynamicFields) *stubBaseRepository {
+	repo := &stubBaseRepository{schema: schema, rows: map[string]dmodel.DynamicFields{}}
+	for _, row := range rows {
+		repo.rows[row[basemodel.FieldId].(string)] = row
+	}
+	return repo
+}
+
+func (this *stubBaseRepository) Schema() *dmodel.ModelSchema {
+	return this.schema
+}
+
+func (this *stubBaseRepository) GetOne(
+	_ corectx.Context, param dyn.RepoGetOneParam,
+) (*dyn.OpResult[dmodel.DynamicFields], error) {
+	row, found := this.rows[stringOf(param.Filter[basemodel.FieldId])]
+	if !found {
+		return &dyn.OpResult[dmodel.DynamicFields]{}, nil
+	}
+	copied := dmodel.DynamicFields{}
+	for key, value := range row {
+		copied[key] = value
+	}
+	return &dyn.OpResult[dmodel.DynamicFields]{Data: copied, HasData: true}, nil
+}
+
+func (this *stubBaseRepository) CheckUniqueCollisions(
+	_ corectx.Context, _ dmodel.DynamicFields,
+) (*dyn.OpResult[[][]string], error) {
+	return &dyn.OpResult[[][]string]{}, nil
+}
+
+func (this *stubBaseRepository) Update(
+	_ corectx.Context, data dmodel.DynamicFields,
+) (*dyn.OpResult[dmodel.DynamicFields], error) {
+	this.updates = append(this.updates, data)
+	row := this.rows[stringOf(data[basemodel.FieldId])]
+	for key, value := range data {
+		row[key] = value
+	}
+	return &dyn.OpResult[dmodel.DynamicFields]{Data: data, HasData: true}, nil
+}
+
+func (this *stubBaseRepository) BeginTransaction(_ corectx.Context) (database.DbTransaction, error) {
+	return stubTransaction{}, nil
+}
+
+type stubTransaction struct{}
+
+func (stubTransaction) Commit() error   { return nil }
+func (stubTransaction) Rollback() error { return nil }
+
+func stringOf(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case *model.Id:
+		return string(*typed)
+	}
+	return ""
+}
+
+type stubRoleRequestRepository struct {
+	itRr.RoleRequestRepository
+
+	base *stubBaseRepository
+}
+
+func (this *stubRoleRequestRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return this.base
+}
+
+type stubRoleRepository struct {
+	itRole.RoleRepository
+
+	role dmodel.DynamicFields
+}
+
+func (this *stubRoleRepository) GetOne(_ corectx.Context, _ dyn.RepoGetOneParam) (*dyn.OpResult[domain.Role], error) {
+	return &dyn.OpResult[domain.Role]{Data: *domain.NewRoleFrom(this.role), HasData: true}, nil
+}
+
+// stubGrantRepository records the assignments the workflow creates and removes.
+type stubGrantRepository struct {
+	itRole.RoleAssignmentRepository
+
+	granted []itRole.RoleAssignment
+	revoked []itRole.RoleAssignment
+}
+
+func (this *stubGrantRepository) GrantFromRequest(
+	_ corectx.Context, grant itRole.RoleAssignment,
+) (*itRole.RoleAssignment, error) {
+	this.granted = append(this.granted, grant)
+	return &grant, nil
+}
+
+func (this *stubGrantRepository) RevokeAssignment(
+	_ corectx.Context, target itRole.RoleAssignment,
+) (*itRole.RoleAssignment, error) {
+	this.revoked = append(this.revoked, target)
+	return &target, nil
+}
+
+// stubPermissionRepository records whose cached permissions were rebuilt.
+type stubPermissionRepository struct {
+	itPerm.PermissionRepository
+
+	rebuiltUsers  []model.Id
+	rebuiltGroups []model.Id
+}
+
+func (this *stubPermissionRepository) RebuildUserPermission(_ corectx.Context, userId model.Id) error {
+	this.rebuiltUsers = append(this.rebuiltUsers, userId)
+	return nil
+}
+
+func (this *stubPermissionRepository) RebuildUserPermissionsForGroup(_ corectx.Context, groupId model.Id) error {
+	this.rebuiltGroups = append(this.rebuiltGroups, groupId)
+	return nil
+}
+
+// actorContext signs a user in, holding the given permission expressions.
+func actorContext(userId string, entitlements ...string) corectx.Context {
+	ctx := corectx.NewRequestContext(context.Background())
+	grants := ds.NewSet[string]()
+	grants.AddMany(entitlements...)
+	ctx.SetPermissions(corectx.ContextPermissions{UserId: model.Id(userId), Entitlements: grants})
+	return ctx
+}
+
+var respondToAnyRequest = reguard.BuildExpression(
+	c.ActionRespond, domain.RoleRequestSchemaName, reguard.ResourceScopeDomain, nil,
+)
+
+type roleRequestFixture struct {
+	svc         *RoleRequestDomainServiceImpl
+	role        *stubRoleRepository
+	requests    *stubBaseRepository
+	grants      *stubGrantRepository
+	permissions *stubPermissionRepository
+}
+
+func newRoleRequestFixture(t *testing.T, request dmodel.DynamicFields) roleRequestFixture {
+	t.Helper()
+	// Normally done by CoreModule.RegisterModels during app start-up; a second call only
+	// reports the builders as already registered.
+	_ = basemodel.RegisterJsonBaseSchemas()
+	fixture := roleRequestFixture{
+		requests:    newStubBaseRepository(domain.RoleRequestSchemaBuilder().Build(), request),
+		grants:      &stubGrantRepository{},
+		permissions: &stubPermissionRepository{},
+	}
+	fixture.role = &stubRoleRepository{role: dmodel.DynamicFields{
+		domain.RoleFieldId:            testRoleId,
+		domain.RoleFieldOwnerUserId:   testOwnerId,
+		domain.RoleFieldIsRequestable: true,
+	}}
+	fixture.svc = NewRoleRequestDomainServiceImpl(
+		&stubRoleRequestRepository{base: fixture.requests}, fixture.role, nil,
+		fixture.grants, fixture.permissions, nil, nil,
+	).(*RoleRequestDomainServiceImpl)
+	return fixture
+}
+
+func pendingRequest(requestorId *string) dmodel.DynamicFields {
+	request := dmodel.DynamicFields{
+		domain.RoleReqFieldId:             testRequestId,
+		basemodel.FieldEtag:               testEtag,
+		domain.RoleReqFieldStatus:         string(domain.RoleReqStatusPending),
+		domain.RoleReqFieldType:           string(domain.RoleReqTypeGrant),
+		domain.RoleReqFieldRoleId:         testRoleId,
+		domain.RoleReqFieldReceiverUserId: testReceiverId,
+	}
+	if requestorId != nil {
+		request[domain.RoleReqFieldRequestorId] = *requestorId
+	}
+	return request
+}
+
+func requestor() *string {
+	id := testRequestorId
+	return &id
+}
+
+func (this roleRequestFixture) status() string {
+	return stringOfStatus(this.requests.rows[testRequestId][domain.RoleReqFieldStatus])
+}
+
+func stringOfStatus(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case domain.RoleRequestStatus:
+		return string(typed)
+	case *domain.RoleRequestStatus:
+		return string(*typed)
+	}
+	return ""
+}
+
+func assertRefused(t *testing.T, result *dyn.OpResult[dyn.MutateResultData], err error, errorKey string) {
+	t.Helper()
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Equal(t, 1, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, errorKey, result.ClientErrors[0].Key)
+}
+
+func TestApproveByTheRoleOwnerGrantsTheRole(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+	result, err := fixture.svc.ApproveRoleRequest(actorContext(testOwnerId), itRr.ApproveRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, string(domain.RoleReqStatusApproved), fixture.status())
+	require.Len(t, fixture.grants.granted, 1)
+	assert.Equal(t, model.Id(testReceiverId), fixture.grants.granted[0].ReceiverId)
+	assert.Equal(t, model.Id(testOwnerId), *fixture.grants.granted[0].ApproverId)
+	assert.Equal(t, []model.Id{testReceiverId}, fixture.permissions.rebuiltUsers)
+}
+
+func TestApproveByARespondPermissionHolderGrantsTheRole(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+	result, err := fixture.svc.ApproveRoleRequest(
+		actorContext(testStrangerId, respondToAnyRequest),
+		itRr.ApproveRoleRequestCommand{Id: testRequestId, Etag: testEtag},
+	)
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Len(t, fixture.grants.granted, 1)
+}
+
+func TestApproveByAStrangerIsRefused(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+	result, err := fixture.svc.ApproveRoleRequest(actorContext(testStrangerId), itRr.ApproveRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+
+	assertRefused(t, result, err, ft.ErrorKey("err_not_role_owner", "iam"))
+	assert.Equal(t, string(domain.RoleReqStatusPending), fixture.status())
+	assert.Empty(t, fixture.grants.granted)
+}
+
+// Owning the role, or holding the respond permission, does not let anyone approve their own
+// request: the grant would have been agreed to by nobody else.
+func TestApproveByTheRequestorIsRefused(t *testing.T) {
+	for name, entitlements := range map[string][]string{
+		"without respond permission": nil,
+		"with respond permission":    {respondToAnyRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+			result, err := fixture.svc.ApproveRoleRequest(
+				actorContext(testRequestorId, entitlements...),
+				itRr.ApproveRoleRequestCommand{Id: testRequestId, Etag: testEtag},
+			)
+
+			assertRefused(t, result, err, ft.ErrorKey("err_requestor_cannot_approve", "iam"))
+			assert.Empty(t, fixture.grants.granted)
+		})
+	}
+}
+
+func TestRejectByTheRoleOwnerRecordsTheReason(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+	reason := "not needed for this project"
+
+	result, err := fixture.svc.RejectRoleRequest(actorContext(testOwnerId), itRr.RejectRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag, Reason: &reason,
+	})
+
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, string(domain.RoleReqStatusRejected), fixture.status())
+	assert.Equal(t, reason, *fixture.requests.rows[testRequestId].GetString(domain.RoleReqFieldRejectionReason))
+	assert.Empty(t, fixture.grants.granted)
+}
+
+func TestRejectByAStrangerIsRefused(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+	result, err := fixture.svc.RejectRoleRequest(actorContext(testStrangerId), itRr.RejectRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+
+	assertRefused(t, result, err, ft.ErrorKey("err_not_role_owner", "iam"))
+	assert.Equal(t, string(domain.RoleReqStatusPending), fixture.status())
+}
+
+func TestCancelIsTheRequestorsOwnAct(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+
+	result, err := fixture.svc.CancelRoleRequest(actorContext(testOwnerId), itRr.CancelRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+	assertRefused(t, result, err, ft.ErrorKey("err_only_requestor_can_cancel", "iam"))
+
+	result, err = fixture.svc.CancelRoleRequest(actorContext(testRequestorId), itRr.CancelRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, string(domain.RoleReqStatusCancelled), fixture.status())
+	assert.Nil(t, fixture.requests.rows[testRequestId][domain.RoleReqFieldResponderId],
+		"a cancellation answers nobody's request")
+}
+
+// A request whose requestor is gone has nobody to take it back, so it must not become
+// something anyone at all may withdraw.
+func TestCancelWithoutARequestorNeedsTheRespondPermission(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(nil))
+
+	result, err := fixture.svc.CancelRoleRequest(actorContext(testStrangerId), itRr.CancelRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+	assertRefused(t, result, err, ft.ErrorKey("err_only_responder_can_cancel_orphan", "iam"))
+	assert.Equal(t, string(domain.RoleReqStatusPending), fixture.status())
+
+	result, err = fixture.svc.CancelRoleRequest(
+		actorContext(testStrangerId, respondToAnyRequest),
+		itRr.CancelRoleRequestCommand{Id: testRequestId, Etag: testEtag},
+	)
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count(), "%v", result.ClientErrors)
+	assert.Equal(t, string(domain.RoleReqStatusCancelled), fixture.status())
+}
+
+// Every outcome is final: a decided request cannot be decided again, whoever asks.
+func TestADecidedRequestCannotBeDecidedAgain(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+	_, err := fixture.svc.RejectRoleRequest(actorContext(testOwnerId), itRr.RejectRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+	require.NoError(t, err)
+	etag := model.Etag(stringOf(fixture.requests.rows[testRequestId][basemodel.FieldEtag]))
+
+	approved, err := fixture.svc.ApproveRoleRequest(actorContext(testOwnerId), itRr.ApproveRoleRequestCommand{
+		Id: testRequestId, Etag: etag,
+	})
+	assertRefused(t, approved, err, ft.ErrorKey("err_grant_request_not_pending", "iam"))
+
+	cancelled, err := fixture.svc.CancelRoleRequest(actorContext(testRequestorId), itRr.CancelRoleRequestCommand{
+		Id: testRequestId, Etag: etag,
+	})
+	assertRefused(t, cancelled, err, ft.ErrorKey("err_grant_request_not_pending", "iam"))
+	assert.Empty(t, fixture.grants.granted)
+}
+
+// A role that started demanding an attachment while the request waited is not granted
+// without one.
+func TestApproveChecksTheRoleRulesAgain(t *testing.T) {
+	fixture := newRoleRequestFixture(t, pendingRequest(requestor()))
+	fixture.role.role[domain.RoleFieldIsRequiredAttach] = true
+
+	result, err := fixture.svc.ApproveRoleRequest(actorContext(testOwnerId), itRr.ApproveRoleRequestCommand{
+		Id: testRequestId, Etag: testEtag,
+	})
+
+	assertRefused(t, result, err, ft.ErrorKey("err_request_attachment_required", "iam"))
+	assert.Empty(t, fixture.grants.granted)
+}
+
+// An edit is checked on the request it leaves behind, so clearing the comment the role
+// demands is refused while an edit of another field is not.
+func TestAnEditIsCheckedAgainstTheRoleRules(t *testing.T) {
+	stored := pendingRequest(requestor())
+	stored[domain.RoleReqFieldRequestComment] = "Needed for the audit"
+	fixture := newRoleRequestFixture(t, stored)
+	fixture.role.role[domain.RoleFieldIsRequiredComment] = true
+	found := domain.NewRoleRequestFrom(stored)
+
+	var vErrs ft.ClientErrors
+	cleared := domain.NewRoleRequestFrom(dmodel.DynamicFields{domain.RoleReqFieldRequestComment: nil})
+	require.NoError(t, fixture.svc.ValidateRoleRequestEdit(actorContext(testRequestorId), cleared, found, &vErrs))
+	require.Equal(t, 1, vErrs.Count(), "%v", vErrs)
+	assert.Equal(t, ft.ErrorKey("err_request_comment_required", "iam"), vErrs[0].Key)
+
+	vErrs = nil
+	attached := domain.NewRoleRequestFrom(dmodel.DynamicFields{domain.RoleReqFieldAttachmentUrl: "https://files/1"})
+	require.NoError(t, fixture.svc.ValidateRoleRequestEdit(actorContext(testRequestorId), attached, found, &vErrs))
+	assert.Zero(t, vErrs.Count(), "%v", vErrs)
+}
@@ -0,0 +1,259 @@
+package dynamicengines
+
+import (
+	stdErr "errors"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+func accessReviewCampaignEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.AccessReviewCampaignSchemaName,
+		DefaultFields: []string{
+			models.AccessReviewCampFieldName,
+			models.AccessReviewCampFieldScopeType,
+			models.AccessReviewCampFieldStatus,
+			models.AccessReviewCampFieldDeadlineAt,
+		},
+		DefineActions: defineAccessReviewCampaignActions,
+	}
+}
+
+func accessReviewItemEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.AccessReviewItemSchemaName,
+		DefaultFields: []string{
+			models.AccessReviewItemFieldCampaignId,
+			models.AccessReviewItemFieldItemType,
+			models.AccessReviewItemFieldRoleId,
+			models.AccessReviewItemFieldDecision,
+		},
+		DefineActions: defineAccessReviewItemActions,
+	}
+}
+
+// The access review actions.
+//
+// A campaign moves from draft to open by launch and from open to closed by close, never
+// by an edit: launching is what snapshots the items. Its items are written only by that
+// snapshot and decided only by keep and revoke, so the built-in writes on items are
+// refused outright.
+//
+// Launch, close and export declare no permission here because the application service
+// they delegate to asserts it. Keep and revoke declare none at all: who may decide
+// depends on the owner of the role under review, which only the workflow can see.
+
+const (
+	ActionLaunchAccessReview = "launch"
+	ActionCloseAccessReview  = "close"
+	ActionExportAccessReview = "export"
+
+	ActionKeepAccessReviewItem   = "keep"
+	ActionRevokeAccessReviewItem = "revoke"
+)
+
+// paramDecisionComment is the same name the field has on the item.
+const paramDecisionComment = models.AccessReviewItemFieldDecisionComment
+
+// AccessReviewWorkflow is what the access review actions and guards delegate to,
+// declared here for the same reason as GrantRequestWorkflow.
+type AccessReviewWorkflow interface {
+	ValidateCampaignEdit(ctx corectx.Context, input *models.AccessReviewCampaign, found *models.AccessReviewCampaign, vErrs *ft.ClientErrors) error
+
+	Launch(ctx corectx.Context, id model.Id, etag model.Etag) (*dyn.OpResult[dyn.MutateResultData], error)
+	Close(ctx corectx.Context, id model.Id, etag model.Etag) (*dyn.OpResult[dyn.MutateResultData], error)
+	Export(ctx corectx.Context, id model.Id) (*dyn.OpResult[any], error)
+	Decide(ctx corectx.Context, id model.Id, etag model.Etag, decision models.AccessReviewDecision, comment *string) (*dyn.OpResult[dyn.MutateResultData], error)
+}
+
+var accessReviewWorkflow AccessReviewWorkflow
+
+// SetAccessReviewWorkflow installs the workflow the access review actions delegate to.
+// IamModule.Init calls it before any request is served.
+func SetAccessReviewWorkflow(workflow AccessReviewWorkflow) {
+	accessReviewWorkflow = workflow
+}
+
+func requireAccessReviewWorkflow() (AccessReviewWorkflow, error) {
+	if accessReviewWorkflow == nil {
+		return nil, errors.New(
+			"the access review workflow was not installed; IamModule.Init must call " +
+				"dynamicengines.SetAccessReviewWorkflow")
+	}
+	return accessReviewWorkflow, nil
+}
+
+func defineAccessReviewCampaignActions(engine drif.DynamicResourceEngine) error {
+	err := stdErr.Join(
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:       drif.ActionCreate,
+			BeforeValidation: prepareNewAccessReviewCampaign,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionUpdate,
+			KeysToFetch:   accessReviewKeysToFetch,
+			ValidateExtra: validateAccessReviewCampaignEdit,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to attach access review campaign guards")
+	}
+
+	return stdErr.Join(
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionLaunchAccessReview,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/launch",
+			MainProcess: processAccessReviewLaunch,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionCloseAccessReview,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/close",
+			MainProcess: processAccessReviewClose,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionExportAccessReview,
+			ActionType:  drif.ActionTypeRead,
+			RestPath:    ":id/export",
+			MainProcess: processAccessReviewExport,
+		}),
+	)
+}
+
+func defineAccessReviewItemActions(engine drif.DynamicResourceEngine) error {
+	err := stdErr.Join(
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionCreate,
+			ValidateExtra: refuseAccessReviewItemWrite,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionUpdate,
+			ValidateExtra: refuseAccessReviewItemWrite,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionDelete,
+			ValidateExtra: refuseAccessReviewItemWrite,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to attach access review item guards")
+	}
+
+	return stdErr.Join(
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionKeepAccessReviewItem,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/keep",
+			MainProcess: processAccessReviewItemKeep,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionRevokeAccessReviewItem,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/revoke",
+			MainProcess: processAccessReviewItemRevoke,
+		}),
+	)
+}
+
+func accessReviewKeysToFetch(params dmodel.DynamicFields) dmodel.DynamicFields {
+	return dmodel.DynamicFields{models.AccessReviewCampFieldId: params[models.AccessReviewCampFieldId]}
+}
+
+// prepareNewAccessReviewCampaign makes a campaign born a draft, owned by whoever drafts it
+// unless the body names another owner.
+func prepareNewAccessReviewCampaign(
+	ctx corectx.Context, params dmodel.DynamicFields, _ *ft.ClientErrors,
+) (dmodel.DynamicFields, error) {
+	params[models.AccessReviewCampFieldStatus] = string(models.AccessReviewStatusDraft)
+	delete(params, models.AccessReviewCampFieldLaunchedAt)
+	delete(params, models.AccessReviewCampFieldClosedAt)
+	if params[models.AccessReviewCampFieldOwnerId] == nil {
+		if userId := ctx.GetPermissions().UserId; userId != "" {
+			params[models.AccessReviewCampFieldOwnerId] = string(userId)
+		}
+	}
+	return params, nil
+}
+
+func validateAccessReviewCampaignEdit(
+	ctx corectx.Context, params dmodel.DynamicFields, foundModel *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	if foundModel == nil {
+		return nil
+	}
+	workflow, err := requireAccessReviewWorkflow()
+	if err != nil {
+		return err
+	}
+	return workflow.ValidateCampaignEdit(ctx,
+		models.NewAccessReviewCampaignFrom(params), models.NewAccessReviewCampaignFrom(*foundModel), vErrs)
+}
+
+func refuseAccessReviewItemWrite(
+	_ corectx.Context, _ dmodel.DynamicFields, _ *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	vErrs.Append(*ft.NewBusinessViolation(
+		models.AccessReviewItemFieldId, ft.ErrorKey("err_access_review_item_read_only", "iam"),
+		"review items are created by launching their campaign and decided through keep or revoke",
+	))
+	return nil
+}
+
+func processAccessReviewLaunch(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireAccessReviewWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.AccessReviewCampFieldId)
+	return toMutateActionResult(workflow.Launch(ctx, id, etag))
+}
+
+func processAccessReviewClose(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireAccessReviewWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.AccessReviewCampFieldId)
+	return toMutateActionResult(workflow.Close(ctx, id, etag))
+}
+
+func processAccessReviewExport(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireAccessReviewWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, _ := readRecordKey(input.Params, models.AccessReviewCampFieldId)
+	return workflow.Export(ctx, id)
+}
+
+func processAccessReviewItemKeep(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	return decideAccessReviewItem(ctx, input, models.AccessReviewDecisionKept)
+}
+
+func processAccessReviewItemRevoke(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	return decideAccessReviewItem(ctx, input, models.AccessReviewDecisionRevoked)
+}
+
+func decideAccessReviewItem(
+	ctx corectx.Context, input drif.ProcessInput, decision models.AccessReviewDecision,
+) (*drif.ActionResult, error) {
+	workflow, err := requireAccessReviewWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.AccessReviewItemFieldId)
+	var comment *string
+	if value := readStringParam(input.Params, paramDecisionComment); value != "" {
+		comment = &value
+	}
+	return toMutateActionResult(workflow.Decide(ctx, id, etag, decision, comment))
+}
@@ -1,6 +1,17 @@
 package dynamicengines
 
 import (
+	stdErr "errors"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
 )
 
@@ -14,5 +25,204 @@ func grantRequestEngineSpec() engineSpec {
 			models.RoleReqFieldType,
 			models.RoleReqFieldRequestComment,
 		},
+		DefineActions: defineGrantRequestActions,
+	}
+}
+
+// The grant request workflow actions.
+//
+// A request is decided by approve, reject or cancel, never by editing its status: an
+// approval has to create the assignment in the same transaction, and only the workflow
+// does that. The built-in update is therefore guarded so it cannot reach the outcome
+// fields, and the built-in create so it applies the role's own rules.
+//
+// None of the three declares a permission. Who may answer depends on the role's owner
+// and who may cancel on the requestor, neither of which the engine's check can see; the
+// workflow decides once it has loaded the request.
+
+// Action names of the workflow, in the same style as the built-ins.
+const (
+	ActionApproveGrantRequest = "approve"
+	ActionRejectGrantRequest  = "reject"
+	ActionCancelGrantRequest  = "cancel"
+)
+
+// paramRejectionReason is the same name the field has on the request.
+const paramRejectionReason = models.RoleReqFieldRejectionReason
+
+// GrantRequestWorkflow is what the workflow actions and guards delegate to. It is
+// declared here rather than imported so that this package stays a leaf; the IAM module
+// adapts its role request services to it.
+type GrantRequestWorkflow interface {
+	ValidateNewRequest(ctx corectx.Context, request *models.RoleRequest, vErrs *ft.ClientErrors) error
+	ValidateRequestEdit(ctx corectx.Context, input *models.RoleRequest, found *models.RoleRequest, vErrs *ft.ClientErrors) error
+
+	Approve(ctx corectx.Context, id model.Id, etag model.Etag) (*dyn.OpResult[dyn.MutateResultData], error)
+	Reject(ctx corectx.Context, id model.Id, etag model.Etag, reason *string) (*dyn.OpResult[dyn.MutateResultData], error)
+	Cancel(ctx corectx.Context, id model.Id, etag model.Etag) (*dyn.OpResult[dyn.MutateResultData], error)
+}
+
+var grantRequestWorkflow GrantRequestWorkflow
+
+// SetGrantRequestWorkflow installs the workflow the grant request actions delegate to.
+// IamModule.Init calls it before any request is served.
+func SetGrantRequestWorkflow(workflow GrantRequestWorkflow) {
+	grantRequestWorkflow = workflow
+}
+
+func requireGrantRequestWorkflow() (GrantRequestWorkflow, error) {
+	if grantRequestWorkflow == nil {
+		return nil, errors.New(
+			"the grant request workflow was not installed; IamModule.Init must call " +
+				"dynamicengines.SetGrantRequestWorkflow")
+	}
+	return grantRequestWorkflow, nil
+}
+
+func defineGrantRequestActions(engine drif.DynamicResourceEngine) error {
+	err := stdErr.Join(
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:       drif.ActionCreate,
+			BeforeValidation: prepareNewGrantRequest,
+			ValidateExtra:    validateNewGrantRequest,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionUpdate,
+			KeysToFetch:   grantRequestKeysToFetch,
+			ValidateExtra: validateGrantRequestEdit,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to attach grant request guards")
+	}
+
+	return stdErr.Join(
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionApproveGrantRequest,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/approve",
+			MainProcess: processGrantRequestApprove,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionRejectGrantRequest,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/reject",
+			MainProcess: processGrantRequestReject,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionCancelGrantRequest,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/cancel",
+			MainProcess: processGrantRequestCancel,
+		}),
+	)
+}
+
+func grantRequestKeysToFetch(params dmodel.DynamicFields) dmodel.DynamicFields {
+	return dmodel.DynamicFields{models.RoleReqFieldId: params[models.RoleReqFieldId]}
+}
+
+// prepareNewGrantRequest makes a request born pending whatever the body says, and filed
+// by the signed-in user unless it names another requestor.
+func prepareNewGrantRequest(
+	ctx corectx.Context, params dmodel.DynamicFields, _ *ft.ClientErrors,
+) (dmodel.DynamicFields, error) {
+	params[models.RoleReqFieldStatus] = string(models.RoleReqStatusPending)
+	if params[models.RoleReqFieldRequestorId] == nil {
+		if userId := ctx.GetPermissions().UserId; userId != "" {
+			params[models.RoleReqFieldRequestorId] = string(userId)
+		}
+	}
+	return params, nil
+}
+
+func validateNewGrantRequest(
+	ctx corectx.Context, params dmodel.DynamicFields, _ *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	workflow, err := requireGrantRequestWorkflow()
+	if err != nil {
+		return err
+	}
+	return workflow.ValidateNewRequest(ctx, models.NewRoleRequestFrom(params), vErrs)
+}
+
+func validateGrantRequestEdit(
+	ctx corectx.Context, params dmodel.DynamicFields, foundModel *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	if foundModel == nil {
+		return nil
+	}
+	workflow, err := requireGrantRequestWorkflow()
+	if err != nil {
+		return err
+	}
+	return workflow.ValidateRequestEdit(
+		ctx, models.NewRoleRequestFrom(params), models.NewRoleRequestFrom(*foundModel), vErrs)
+}
+
+func processGrantRequestApprove(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireGrantRequestWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.RoleReqFieldId)
+	return toMutateActionResult(workflow.Approve(ctx, id, etag))
+}
+
+func processGrantRequestReject(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireGrantRequestWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.RoleReqFieldId)
+	var reason *string
+	if value := readStringParam(input.Params, paramRejectionReason); value != "" {
+		reason = &value
+	}
+	return toMutateActionResult(workflow.Reject(ctx, id, etag, reason))
+}
+
+func processGrantRequestCancel(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	workflow, err := requireGrantRequestWorkflow()
+	if err != nil {
+		return nil, err
+	}
+	id, etag := readRecordKey(input.Params, models.RoleReqFieldId)
+	return toMutateActionResult(workflow.Cancel(ctx, id, etag))
+}
+
+// readRecordKey reads the record id from the path and the etag from the body.
+// The etag is what stops two deciders acting on the same record at once.
+func readRecordKey(params dmodel.DynamicFields, idField string) (model.Id, model.Etag) {
+	return model.Id(readStringParam(params, idField)),
+		model.Etag(readStringParam(params, basemodel.FieldEtag))
+}
+
+func readStringParam(params dmodel.DynamicFields, field string) string {
+	value, ok := params[field]
+	if !ok || value == nil {
+		return ""
+	}
+	if typed, ok := value.(string); ok {
+		return typed
+	}
+	return ""
+}
+
+// toMutateActionResult widens a mutation result into the engine's generic action result,
+// keeping the ClientErrors a refused decision reports its reason through.
+func toMutateActionResult(
+	result *dyn.OpResult[dyn.MutateResultData], err error,
+) (*drif.ActionResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := &drif.ActionResult{
+		ClientErrors: result.ClientErrors,
+		HasData:      result.HasData,
+	}
+	if result.HasData {
+		out.Data = result.Data
 	}
+	return out, nil
 }
@@ -0,0 +1,110 @@
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+// groupUserRelEngineSpec serves group memberships one row at a time, which is how SCIM
+// provisioning adds and removes group members.
+func groupUserRelEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.GrpUsrRelSchemaName,
+		DefaultFields: []string{
+			models.GrpUsrRelFieldGroupId,
+			models.GrpUsrRelFieldUserId,
+		},
+		DefineActions: defineGroupUserRelActions,
+	}
+}
+
+// GroupMembershipListener is told whose membership changed, declared here for the same
+// reason as GrantRequestWorkflow. A membership decides which of the group's roles apply
+// to the user, so the user's permissions are rebuilt from it.
+type GroupMembershipListener interface {
+	MembershipChanged(ctx corectx.Context, userId model.Id) error
+}
+
+var groupMembershipListener GroupMembershipListener
+
+// SetGroupMembershipListener installs what membership changes are reported to.
+// IamModule.Init calls it before any request is served.
+func SetGroupMembershipListener(listener GroupMembershipListener) {
+	groupMembershipListener = listener
+}
+
+func defineGroupUserRelActions(engine drif.DynamicResourceEngine) error {
+	err := engine.ModifyAction(drif.DynamicActionDelta{
+		ActionName:  drif.ActionCreate,
+		MainProcess: processCreateGroupMembership,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to modify group membership create")
+	}
+	// The row is fetched before it is deleted, for the member it names.
+	err = engine.ModifyAction(drif.DynamicActionDelta{
+		ActionName:  drif.ActionDelete,
+		KeysToFetch: groupUserRelKeysToFetch,
+		MainProcess: processDeleteGroupMembership,
+	})
+	return errors.Wrap(err, "failed to modify group membership delete")
+}
+
+func groupUserRelKeysToFetch(params dmodel.DynamicFields) dmodel.DynamicFields {
+	return dmodel.DynamicFields{models.GrpUsrRelFieldId: params[models.GrpUsrRelFieldId]}
+}
+
+func processCreateGroupMembership(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	result, err := input.ResourceService.Create(ctx, input.Params)
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData {
+		return toGroupMembershipActionResult(result, err)
+	}
+	userId := model.Id(readStringParam(result.Data, models.GrpUsrRelFieldUserId))
+	if err := notifyGroupMembershipChanged(ctx, userId); err != nil {
+		return nil, err
+	}
+	return toGroupMembershipActionResult(result, nil)
+}
+
+func processDeleteGroupMembership(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	if input.FoundModel == nil {
+		return &drif.ActionResult{HasData: false}, nil
+	}
+	result, err := input.ResourceService.Delete(ctx, input.Params)
+	if err != nil || result.ClientErrors.Count() > 0 || !result.HasData {
+		return toMutateActionResult(result, err)
+	}
+	userId := model.Id(readStringParam(*input.FoundModel, models.GrpUsrRelFieldUserId))
+	if err := notifyGroupMembershipChanged(ctx, userId); err != nil {
+		return nil, err
+	}
+	return toMutateActionResult(result, nil)
+}
+
+func notifyGroupMembershipChanged(ctx corectx.Context, userId model.Id) error {
+	if groupMembershipListener == nil {
+		return errors.New(
+			"the group membership listener was not installed; IamModule.Init must call " +
+				"dynamicengines.SetGroupMembershipListener")
+	}
+	return groupMembershipListener.MembershipChanged(ctx, userId)
+}
+
+func toGroupMembershipActionResult(
+	result *dyn.OpResult[dmodel.DynamicFields], err error,
+) (*drif.ActionResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := &drif.ActionResult{ClientErrors: result.ClientErrors, HasData: result.HasData}
+	if result.HasData {
+		out.Data = result.Data
+	}
+	return out, nil
+}
@@ -37,11 +37,15 @@ var engineSpecs = []engineSpec{
 	orgEngineSpec(),
 	orgUnitEngineSpec(),
 	groupEngineSpec(),
+	groupUserRelEngineSpec(),
 	roleEngineSpec(),
 	entitlementEngineSpec(),
 	resourceEngineSpec(),
 	actionEngineSpec(),
 	grantRequestEngineSpec(),
+	accessReviewCampaignEngineSpec(),
+	accessReviewItemEngineSpec(),
+	scimTokenEngineSpec(),
 }
 
 // EngineSchemaNames lists the schemas IAM creates an engine for, so that route
@@ -0,0 +1,167 @@
+package dynamicengines
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	stdErr "errors"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	reguard "github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+func scimTokenEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.ScimTokenSchemaName,
+		DefaultFields: []string{
+			models.ScimTokenFieldName,
+			models.ScimTokenFieldOrgId,
+			models.ScimTokenFieldOwnerId,
+			models.ScimTokenFieldExpiresAt,
+		},
+		DefineActions: defineScimTokenActions,
+	}
+}
+
+// ActionIssueScimToken replaces the built-in create: a token must be generated by the
+// server, and its plain value is answered exactly once, by this action. Revoking a token
+// is the built-in delete.
+const ActionIssueScimToken = "issue"
+
+// scimTokenBytes is the entropy of an issued token.
+const scimTokenBytes = 32
+
+// paramScimToken names the plain token in the issue action's result.
+const paramScimToken = "token"
+
+func defineScimTokenActions(engine drif.DynamicResourceEngine) error {
+	err := stdErr.Join(
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionCreate,
+			ValidateExtra: refuseScimTokenCreate,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionUpdate,
+			KeysToFetch:   scimTokenKeysToFetch,
+			ValidateExtra: validateScimTokenEdit,
+		}),
+		engine.ModifyAction(drif.DynamicActionDelta{
+			ActionName:    drif.ActionDelete,
+			KeysToFetch:   scimTokenKeysToFetch,
+			ValidateExtra: validateScimTokenEdit,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to attach SCIM token guards")
+	}
+
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:    ActionIssueScimToken,
+		ActionType:    drif.ActionTypeCreate,
+		RestPath:      "issue",
+		Permission:    drif.PermissionCreate,
+		ValidateExtra: validateScimTokenIssue,
+		MainProcess:   processIssueScimToken,
+	})
+}
+
+func scimTokenKeysToFetch(params dmodel.DynamicFields) dmodel.DynamicFields {
+	return dmodel.DynamicFields{models.ScimTokenFieldId: params[models.ScimTokenFieldId]}
+}
+
+func refuseScimTokenCreate(
+	_ corectx.Context, _ dmodel.DynamicFields, _ *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	vErrs.Append(*ft.NewBusinessViolation(
+		models.ScimTokenFieldTokenHash, ft.ErrorKey("err_scim_token_must_be_issued", "iam"),
+		"SCIM tokens are generated by the server through the issue action",
+	))
+	return nil
+}
+
+// validateScimTokenIssue refuses a token for an organization the caller neither belongs to nor
+// manages. The token's requests run with its owner's grants in that organization, so a token
+// for any other would grant nothing, or whatever the owner's domain-wide grants reach.
+func validateScimTokenIssue(
+	ctx corectx.Context, params dmodel.DynamicFields, _ *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	if orgId := params.GetModelId(models.ScimTokenFieldOrgId); orgId != nil {
+		assertManagesScimTokenOrg(ctx, *orgId, vErrs)
+	}
+	return nil
+}
+
+// validateScimTokenEdit holds a token's renaming, its expiry and its revocation to the same
+// callers who could have issued it. The hash, the organization and the owner are fixed at issue
+// time, which the schema enforces by dropping them from an update.
+func validateScimTokenEdit(
+	ctx corectx.Context, _ dmodel.DynamicFields, foundModel *dmodel.DynamicFields, vErrs *ft.ClientErrors,
+) error {
+	if foundModel == nil {
+		return nil
+	}
+	if orgId := foundModel.GetModelId(models.ScimTokenFieldOrgId); orgId != nil {
+		assertManagesScimTokenOrg(ctx, *orgId, vErrs)
+	}
+	return nil
+}
+
+// assertManagesScimTokenOrg passes a member of the organization, and a caller who may update it
+// without being one.
+func assertManagesScimTokenOrg(ctx corectx.Context, orgId model.Id, vErrs *ft.ClientErrors) {
+	perms := ctx.GetPermissions()
+	if perms.UserOrgIds.Contains(orgId) {
+		return
+	}
+	managed := reguard.PermFor(drif.PermissionUpdate, models.OrganizationSchemaName, reguard.ResourceScopeOrg).
+		InOrg(&orgId)
+	if reguard.AssertPermission(ctx, managed) == nil {
+		return
+	}
+	vErrs.Append(*ft.NewBusinessViolation(
+		models.ScimTokenFieldOrgId, ft.ErrorKey("err_scim_token_org_not_managed", "iam"),
+		"a SCIM token can only be managed by a member of its organization or someone who manages it",
+	))
+}
+
+// processIssueScimToken stores the hash of a fresh token on behalf of the caller, and
+// answers the token alongside the created record. It cannot be read back afterwards.
+func processIssueScimToken(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	raw := make([]byte, scimTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.Wrap(err, "generate SCIM token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	params := dmodel.DynamicFields{}
+	for key, value := range input.Params {
+		params[key] = value
+	}
+	params[models.ScimTokenFieldTokenHash] = models.HashScimToken(token)
+	delete(params, models.ScimTokenFieldOwnerId)
+	if userId := ctx.GetPermissions().UserId; userId != "" {
+		params[models.ScimTokenFieldOwnerId] = string(userId)
+	}
+
+	result, err := input.ResourceService.Create(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := &drif.ActionResult{ClientErrors: result.ClientErrors, HasData: result.HasData}
+	if result.HasData {
+		issued := dmodel.DynamicFields{}
+		for key, value := range result.Data {
+			issued[key] = value
+		}
+		delete(issued, models.ScimTokenFieldTokenHash)
+		issued[paramScimToken] = token
+		out.Data = issued
+	}
+	return out, nil
+}
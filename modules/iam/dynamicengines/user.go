@@ -4,6 +4,7 @@ import (
 	"go.bryk.io/pkg/errors"
 
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
@@ -39,18 +40,42 @@ func userKeysToFetch(params dmodel.DynamicFields) dmodel.DynamicFields {
 	return dmodel.DynamicFields{models.UserFieldId: params[models.UserFieldId]}
 }
 
-// processSendUserInvitation shows how a custom action reaches the record the engine already
-// fetched for it: KeysToFetch identified the user, and the pipeline hands it over as FoundModel,
-// so there is no second read. Sending the actual email is left to the invitation feature.
+// paramInvitationLang names the optional body field choosing the invitation email's language.
+const paramInvitationLang = "lang"
+
+// UserInvitationSender is what send_invitation delegates to, declared here for the same
+// reason as GrantRequestWorkflow.
+type UserInvitationSender interface {
+	SendInvitation(ctx corectx.Context, userId model.Id, lang *string) (*drif.ActionResult, error)
+}
+
+var userInvitationSender UserInvitationSender
+
+// SetUserInvitationSender installs what send_invitation delegates to.
+// IamModule.Init calls it before any request is served.
+func SetUserInvitationSender(sender UserInvitationSender) {
+	userInvitationSender = sender
+}
+
+// processSendUserInvitation emails the user fetched through KeysToFetch a link to set their
+// first password. The pipeline hands the record over as FoundModel, so there is no second
+// read here; a missing user is reported by the invitation service like any other.
 func processSendUserInvitation(
-	_ corectx.Context, input drif.ProcessInput,
+	ctx corectx.Context, input drif.ProcessInput,
 ) (*drif.ActionResult, error) {
+	if userInvitationSender == nil {
+		return nil, errors.New(
+			"the user invitation sender was not installed; IamModule.Init must call " +
+				"dynamicengines.SetUserInvitationSender")
+	}
 	if input.FoundModel == nil {
 		return &drif.ActionResult{HasData: false}, nil
 	}
 
-	return &drif.ActionResult{
-		Data:    dmodel.DynamicFields{models.UserFieldEmail: (*input.FoundModel)[models.UserFieldEmail]},
-		HasData: true,
-	}, nil
+	userId := model.Id(readStringParam(*input.FoundModel, models.UserFieldId))
+	var lang *string
+	if value := readStringParam(input.Params, paramInvitationLang); value != "" {
+		lang = &value
+	}
+	return userInvitationSender.SendInvitation(ctx, userId, lang)
 }
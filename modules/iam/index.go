@@ -2,10 +2,15 @@ package iam
 
 import (
 	"errors"
+	"time"
 
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
 	"github.com/sky-as-code/nikki-erp/common/semver"
 	"github.com/sky-as-code/nikki-erp/modules"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	"github.com/sky-as-code/nikki-erp/modules/core/job"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
 	"github.com/sky-as-code/nikki-erp/modules/iam/app"
 	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
 	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
@@ -13,6 +18,11 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/iam/dynamicengines"
 	"github.com/sky-as-code/nikki-erp/modules/iam/infra/external"
 	repo "github.com/sky-as-code/nikki-erp/modules/iam/infra/repository"
+	itAr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/accessreview"
+	itInv "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+	itPerm "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/permission"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+	itRr "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/rolerequest"
 	"github.com/sky-as-code/nikki-erp/modules/iam/transport"
 )
 
@@ -65,10 +75,53 @@ func (*IamModule) Init() error {
 		app.InitApplicationServices(),
 		transport.InitTransport(),
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// The grant request, access review, user and group membership engines' actions reach
+	// their services through package variables, because an action callback is handed only its own engine.
+	return deps.Invoke(func(
+		roleRequestAppSvc itRr.RoleRequestAppService,
+		roleRequestSvc itRr.RoleRequestDomainService,
+		accessReviewAppSvc itAr.AccessReviewAppService,
+		accessReviewSvc itAr.AccessReviewDomainService,
+		invitationAppSvc itInv.InvitationAppService,
+		permRepo itPerm.PermissionRepository,
+	) {
+		dynamicengines.SetGrantRequestWorkflow(app.NewGrantRequestWorkflow(roleRequestAppSvc, roleRequestSvc))
+		dynamicengines.SetAccessReviewWorkflow(app.NewAccessReviewWorkflow(accessReviewAppSvc, accessReviewSvc))
+		dynamicengines.SetUserInvitationSender(app.NewUserInvitationSender(invitationAppSvc))
+		dynamicengines.SetGroupMembershipListener(app.NewGroupMembershipListener(permRepo))
+	})
 }
 
+// OnAppStarted implements InCodeModuleAppStarted.
+//
+// The grant expiry and access review deadline sweeps are registered here rather than in
+// Init because they write assignments and permissions, and must not tick against a
+// half-built container.
+func (*IamModule) OnAppStarted() error {
+	return deps.Invoke(func(
+		cfg config.ConfigService,
+		expirySvc itRole.GrantExpiryDomainService,
+		accessReviewSvc itAr.AccessReviewDomainService,
+		cronRegistry job.CronjobRegistry,
+		logger logging.LoggerService,
+	) error {
+		notifyBefore := time.Duration(
+			cfg.GetInt(c.GrantExpiryNotifyBeforeHours, defaultGrantExpiryNotifyBeforeHours)) * time.Hour
+		return errors.Join(
+			app.NewGrantExpiryJobs(expirySvc, notifyBefore, logger).RegisterJobs(cronRegistry),
+			app.NewAccessReviewJobs(accessReviewSvc, logger).RegisterJobs(cronRegistry),
+		)
+	})
+}
+
+// defaultGrantExpiryNotifyBeforeHours matches config.default.yaml. A zero would warn
+// nobody: every grant would expire before it entered the notice window.
+const defaultGrantExpiryNotifyBeforeHours = 72
+
 // Init implements InCodeModule.
 func (this *IamModule) RegisterModels() error {
 	return errors.Join(
@@ -88,6 +141,9 @@ func (this *IamModule) RegisterModels() error {
 		dmodel.RegisterSchemaB(models.RoleUserAssignmentSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.UserPermissionSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.PermissionHistorySchemaBuilder()),
+		dmodel.RegisterSchemaB(models.AccessReviewCampaignSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.AccessReviewItemSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.ScimTokenSchemaBuilder()),
 
 		dmodel.RegisterSchemaB(models.LoginAttemptSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.MethodSettingSchemaBuilder()),
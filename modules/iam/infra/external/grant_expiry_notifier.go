@@ -0,0 +1,146 @@
+package external
+
+import (
+	stdErr "errors"
+	"fmt"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+	itUser "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/user"
+)
+
+func NewMailGrantExpiryNotifier(
+	mailer itExt.Mailer, userRepo itUser.UserRepository, roleRepo itRole.RoleRepository,
+	logger logging.LoggerService,
+) itRole.GrantExpiryNotifier {
+	return &MailGrantExpiryNotifier{mailer: mailer, userRepo: userRepo, roleRepo: roleRepo, logger: logger}
+}
+
+// MailGrantExpiryNotifier emails the warning to whoever holds the grant: the user, or every
+// active member of the group.
+//
+// It goes through the configured mailer, so a deployment without SMTP still sees the
+// notice in the log.
+type MailGrantExpiryNotifier struct {
+	mailer   itExt.Mailer
+	userRepo itUser.UserRepository
+	roleRepo itRole.RoleRepository
+	logger   logging.LoggerService
+}
+
+// NotifyGrantExpiring sends one email per recipient. A failed send does not stop the others.
+//
+// The grant is marked warned once anyone has received the warning, and the recipients a send
+// failed for are only logged: failing the grant would warn every recipient again on the next run,
+// every hour until the one bad address is fixed. Only when every send failed, as when the mail
+// server is down, are the failures returned so the whole warning is retried.
+func (this *MailGrantExpiryNotifier) NotifyGrantExpiring(
+	ctx corectx.Context, assignment itRole.RoleAssignment,
+) error {
+	recipients, err := this.findRecipients(ctx, assignment)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	roleName, err := this.findRoleName(ctx, assignment.RoleId)
+	if err != nil {
+		return err
+	}
+
+	var sendErrs []error
+	sent := 0
+	for _, recipient := range recipients {
+		if recipient.GetEmail() == nil {
+			continue
+		}
+		message := renderGrantExpiryMail(recipient, roleName, assignment.ExpiresAt)
+		if err := this.mailer.Send(ctx, message); err != nil {
+			sendErrs = append(sendErrs, errors.Wrapf(err, "send expiry notice to '%s'", message.To))
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return stdErr.Join(sendErrs...)
+	}
+	for _, err := range sendErrs {
+		this.logger.Warnf("grant '%s': %s", assignment.Id, err.Error())
+	}
+	return nil
+}
+
+func (this *MailGrantExpiryNotifier) findRecipients(
+	ctx corectx.Context, assignment itRole.RoleAssignment,
+) ([]models.User, error) {
+	receiverNode := dmodel.NewSearchNode().NewCondition(models.UserFieldId, dmodel.Equals, string(assignment.ReceiverId))
+	if assignment.ReceiverKind == itRole.AssignmentReceiverGroup {
+		receiverNode = dmodel.NewSearchNode().NewCondition(
+			models.UserEdgeGroups+"."+models.GroupFieldId, dmodel.Equals, string(assignment.ReceiverId),
+		)
+	}
+	graph := dmodel.NewSearchGraph().And(
+		*receiverNode,
+		*dmodel.NewSearchNode().NewCondition(models.UserFieldStatus, dmodel.Equals, string(models.UserStatusActive)),
+	)
+
+	found, err := this.userRepo.Search(ctx, dyn.RepoSearchParam{
+		Graph:  graph,
+		Fields: []string{models.UserFieldId, models.UserFieldEmail, models.UserFieldDisplayName},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "find holders of grant '%s'", assignment.Id)
+	}
+	if !found.HasData {
+		return nil, nil
+	}
+	return found.Data.Items, nil
+}
+
+// findRoleName falls back to the id when the role is not found, so the warning still goes out.
+func (this *MailGrantExpiryNotifier) findRoleName(ctx corectx.Context, roleId model.Id) (string, error) {
+	found, err := this.roleRepo.GetOne(ctx, dyn.RepoGetOneParam{
+		Filter: dmodel.DynamicFields{models.RoleFieldId: string(roleId)},
+		Fields: []string{models.RoleFieldId, models.RoleFieldName},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "find role '%s'", roleId)
+	}
+	if !found.HasData {
+		return string(roleId), nil
+	}
+	if name := found.Data.GetFieldData().GetString(models.RoleFieldName); name != nil {
+		return *name, nil
+	}
+	return string(roleId), nil
+}
+
+func renderGrantExpiryMail(recipient models.User, roleName string, expiresAt *time.Time) itExt.MailMessage {
+	name := recipient.MustGetEmail()
+	if displayName := recipient.GetDisplayName(); displayName != nil && *displayName != "" {
+		name = *displayName
+	}
+	expiry := "soon"
+	if expiresAt != nil {
+		expiry = "at " + expiresAt.UTC().Format(time.RFC1123)
+	}
+	return itExt.MailMessage{
+		To:      recipient.MustGetEmail(),
+		Subject: fmt.Sprintf("Your access to the role '%s' is about to expire", roleName),
+		TextBody: fmt.Sprintf(
+			"Hello %s,\n\nYour access to the role '%s' expires %s.\n"+
+				"If you still need it, submit a new request before then.\n",
+			name, roleName, expiry,
+		),
+	}
+}
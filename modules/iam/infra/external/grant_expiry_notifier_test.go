@@ -0,0 +1,123 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+	itRole "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+	itUser "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/user"
+)
+
+type stubUserRepository struct {
+	itUser.UserRepository
+
+	users []models.User
+}
+
+func (this *stubUserRepository) Search(
+	_ corectx.Context, _ dyn.RepoSearchParam,
+) (*dyn.OpResult[dyn.PagedResultData[models.User]], error) {
+	return &dyn.OpResult[dyn.PagedResultData[models.User]]{
+		Data:    dyn.PagedResultData[models.User]{Items: this.users},
+		HasData: len(this.users) > 0,
+	}, nil
+}
+
+type stubRoleRepository struct {
+	itRole.RoleRepository
+}
+
+func (this *stubRoleRepository) GetOne(_ corectx.Context, _ dyn.RepoGetOneParam) (*dyn.OpResult[models.Role], error) {
+	return &dyn.OpResult[models.Role]{
+		Data:    *models.NewRoleFrom(dmodel.DynamicFields{models.RoleFieldName: "Accountant"}),
+		HasData: true,
+	}, nil
+}
+
+type recordingMailer struct {
+	sent []itExt.MailMessage
+
+	// refused are the addresses a send to fails.
+	refused map[string]bool
+}
+
+func (this *recordingMailer) Send(_ corectx.Context, message itExt.MailMessage) error {
+	if this.refused[message.To] {
+		return errors.New("mailbox unavailable")
+	}
+	this.sent = append(this.sent, message)
+	return nil
+}
+
+func userWithEmail(email string, displayName string) models.User {
+	fields := dmodel.DynamicFields{models.UserFieldDisplayName: displayName}
+	if email != "" {
+		fields[models.UserFieldEmail] = email
+	}
+	return *models.NewUserFrom(fields)
+}
+
+func TestMailGrantExpiryNotifierWarnsEveryGroupMemberWithAnEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	notifier := NewMailGrantExpiryNotifier(mailer, &stubUserRepository{users: []models.User{
+		userWithEmail("an@example.com", "An"),
+		userWithEmail("", "No Email"),
+		userWithEmail("binh@example.com", "Binh"),
+	}}, &stubRoleRepository{}, logging.NewLogger(logging.LevelError))
+	expiresAt := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	err := notifier.NotifyGrantExpiring(corectx.NewRequestContext(context.Background()), itRole.RoleAssignment{
+		Id: "01ASSIGNMENT00000000000000", RoleId: "01ROLE0000000000000000000A",
+		ReceiverKind: itRole.AssignmentReceiverGroup, ReceiverId: "01GROUP000000000000000000A",
+		ExpiresAt: &expiresAt,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, mailer.sent, 2)
+	assert.Equal(t, "an@example.com", mailer.sent[0].To)
+	assert.Equal(t, "binh@example.com", mailer.sent[1].To)
+	assert.Contains(t, mailer.sent[0].Subject, "Accountant")
+	assert.Contains(t, mailer.sent[0].TextBody, "Hello An")
+	assert.Contains(t, mailer.sent[0].TextBody, "Mon, 02 Mar 2026 09:00:00 UTC")
+}
+
+func notifyGroupGrant(notifier itRole.GrantExpiryNotifier) error {
+	return notifier.NotifyGrantExpiring(corectx.NewRequestContext(context.Background()), itRole.RoleAssignment{
+		Id: "01ASSIGNMENT00000000000000", RoleId: "01ROLE0000000000000000000A",
+		ReceiverKind: itRole.AssignmentReceiverGroup, ReceiverId: "01GROUP000000000000000000A",
+	})
+}
+
+// The grant is marked warned when the notifier answers no error. One member's bad address must
+// not leave it unmarked, or every other member is emailed again on each hourly run.
+func TestMailGrantExpiryNotifierSucceedsWhenSomeMemberIsWarned(t *testing.T) {
+	mailer := &recordingMailer{refused: map[string]bool{"gone@example.com": true}}
+	notifier := NewMailGrantExpiryNotifier(mailer, &stubUserRepository{users: []models.User{
+		userWithEmail("gone@example.com", "Gone"),
+		userWithEmail("an@example.com", "An"),
+	}}, &stubRoleRepository{}, logging.NewLogger(logging.LevelError))
+
+	require.NoError(t, notifyGroupGrant(notifier))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "an@example.com", mailer.sent[0].To)
+}
+
+func TestMailGrantExpiryNotifierFailsWhenNobodyIsWarned(t *testing.T) {
+	mailer := &recordingMailer{refused: map[string]bool{"gone@example.com": true}}
+	notifier := NewMailGrantExpiryNotifier(mailer, &stubUserRepository{users: []models.User{
+		userWithEmail("gone@example.com", "Gone"),
+	}}, &stubRoleRepository{}, logging.NewLogger(logging.LevelError))
+
+	assert.Error(t, notifyGroupGrant(notifier), "the warning is retried on the next run")
+}
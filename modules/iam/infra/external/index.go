@@ -18,6 +18,8 @@ func InitExternalServices() error {
 		deps.Register(func(userPrefSvc itSet.UserPreferenceUiDomainService) itExt.UserPreferenceUiDomainService {
 			return userPrefSvc
 		}),
+		deps.Register(NewMailGrantExpiryNotifier),
+		deps.Register(NewMailer),
 		// deps.Register(func(orgSvc itOrg.OrganizationDomainService) itExt.OrganizationExtService {
 		// 	return orgSvc
 		// }),
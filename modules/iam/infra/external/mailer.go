@@ -0,0 +1,196 @@
+package external
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	stdErr "errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	c "github.com/sky-as-code/nikki-erp/modules/iam/constants"
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+)
+
+// NewMailer picks the delivery channel from configuration: SMTP when a host is set,
+// the log otherwise.
+func NewMailer(cfg config.ConfigService, logger logging.LoggerService) (itExt.Mailer, error) {
+	host := cfg.GetStr(c.MailSmtpHost, "")
+	if host == "" {
+		logger.Warn("iam: IAM.MAIL.SMTP_HOST is not set, emails will be logged instead of sent", nil)
+		return &LogMailer{logger: logger}, nil
+	}
+
+	from, err := mail.ParseAddress(cfg.GetStr(c.MailFrom, ""))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not a valid address", c.MailFrom)
+	}
+	return &SmtpMailer{
+		host:     host,
+		port:     cfg.GetInt(c.MailSmtpPort, 587),
+		username: cfg.GetStr(c.MailSmtpUsername, ""),
+		password: cfg.GetStr(c.MailSmtpPassword, ""),
+		from:     *from,
+	}, nil
+}
+
+// LogMailer is the mailer used until SMTP is configured.
+//
+// It writes only who the email is for and what it is about. The body is never logged:
+// an email may carry what only its recipient should read.
+type LogMailer struct {
+	logger logging.LoggerService
+}
+
+func (this *LogMailer) Send(_ corectx.Context, message itExt.MailMessage) error {
+	this.logger.Infof("iam: email '%s' to '%s' not sent, no SMTP host is configured", message.Subject, message.To)
+	return nil
+}
+
+// smtpSendTimeout bounds one delivery, from the dial to the server accepting the message, when
+// the caller's context allows longer. A server that accepts the connection and then stalls would
+// otherwise hold the caller for as long as the operating system keeps the connection open.
+const smtpSendTimeout = 30 * time.Second
+
+// SmtpMailer sends through an SMTP server, with STARTTLS when the server offers it and
+// PLAIN authentication when a username is configured.
+type SmtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     mail.Address
+}
+
+func (this *SmtpMailer) Send(ctx corectx.Context, message itExt.MailMessage) error {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return errors.Wrapf(err, "invalid recipient '%s'", message.To)
+	}
+
+	var auth smtp.Auth
+	if this.username != "" {
+		auth = smtp.PlainAuth("", this.username, this.password, this.host)
+	}
+	addr := net.JoinHostPort(this.host, strconv.Itoa(this.port))
+	body, err := this.compose(*to, message)
+	if err != nil {
+		return err
+	}
+	sendCtx, cancel := context.WithTimeout(ctx.InnerContext(), smtpSendTimeout)
+	defer cancel()
+	err = this.deliver(sendCtx, addr, auth, to.Address, body)
+	return errors.Wrapf(err, "send email to '%s' through '%s'", to.Address, addr)
+}
+
+// deliver is smtp.SendMail bounded by ctx. The connection's deadline is ctx's, so no read or
+// write outlives it, and cancelling ctx closes the connection under whatever step is waiting.
+func (this *SmtpMailer) deliver(ctx context.Context, addr string, auth smtp.Auth, to string, body []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, this.host)
+	if err != nil {
+		conn.Close()
+		return stdErr.Join(err, ctx.Err())
+	}
+	defer client.Close()
+
+	err = this.converse(client, auth, to, body)
+	return stdErr.Join(err, ctx.Err())
+}
+
+func (this *SmtpMailer) converse(client *smtp.Client, auth smtp.Auth, to string, body []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: this.host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("the server does not support authentication")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(this.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// compose builds a MIME message, multipart/alternative when there is an HTML body so
+// that clients without HTML fall back to the text.
+func (this *SmtpMailer) compose(to mail.Address, message itExt.MailMessage) ([]byte, error) {
+	var out strings.Builder
+	writeHeader := func(name, value string) {
+		fmt.Fprintf(&out, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", this.from.String())
+	writeHeader("To", to.String())
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	writeHeader("MIME-Version", "1.0")
+
+	if message.HtmlBody == nil {
+		writeHeader("Content-Type", `text/plain; charset="utf-8"`)
+		out.WriteString("\r\n")
+		out.WriteString(message.TextBody)
+		return []byte(out.String()), nil
+	}
+
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.Wrap(err, "generate MIME boundary")
+	}
+	boundary := "nikki-" + hex.EncodeToString(raw)
+	writeHeader("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	out.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", message.TextBody},
+		{"text/html", *message.HtmlBody},
+	} {
+		fmt.Fprintf(&out, "--%s\r\n", boundary)
+		fmt.Fprintf(&out, "Content-Type: %s; charset=\"utf-8\"\r\n\r\n", part.contentType)
+		out.WriteString(part.body)
+		out.WriteString("\r\n")
+	}
+	fmt.Fprintf(&out, "--%s--\r\n", boundary)
+	return []byte(out.String()), nil
+}
@@ -0,0 +1,47 @@
+package external
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itExt "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/external"
+)
+
+// An invitation is sent while the administrator waits on the request, and expiry warnings from a
+// sweep with a timeout of its own. A server that takes the connection and never answers must not
+// hold either past their context.
+
+func TestSmtpMailerGivesUpWhenTheContextEnds(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// Accept and stay silent, never sending the greeting.
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer := &SmtpMailer{host: "127.0.0.1", port: addr.Port, from: mail.Address{Address: "noreply@example.com"}}
+	inner, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err = mailer.Send(corectx.NewRequestContext(inner), itExt.MailMessage{
+		To: "invitee@example.com", Subject: "Invitation", TextBody: "Welcome",
+	})
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
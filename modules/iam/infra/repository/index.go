@@ -19,6 +19,8 @@ func InitRepositories() error {
 
 	err = stdErr.Join(
 		// deps.Invoke(registerIdentitySearchPredicates),
+		deps.Register(NewAccessReviewCampaignDynamicRepository),
+		deps.Register(NewAccessReviewItemDynamicRepository),
 		deps.Register(NewActionDynamicRepository),
 		deps.Register(NewEntitlementDynamicRepository),
 		deps.Register(NewGroupDynamicRepository),
@@ -28,6 +30,7 @@ func InitRepositories() error {
 		deps.Register(NewPermissionHistoryDynamicRepository),
 		deps.Register(NewResourceDynamicRepository),
 		deps.Register(NewRoleDynamicRepository),
+		deps.Register(NewRoleAssignmentDynamicRepository),
 		deps.Register(NewRoleRequestDynamicRepository),
 		deps.Register(NewUserDynamicRepository),
 		deps.Register(NewAttemptDynamicRepository),
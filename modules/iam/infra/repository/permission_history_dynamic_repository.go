@@ -72,7 +72,8 @@ func (this *PermissionHistoryDynamicRepository) Insert(
 		models.PermHistoryFieldRoleId, models.PermHistoryFieldRoleName,
 		models.PermHistoryFieldReceiverId, models.PermHistoryFieldApproverId,
 		models.PermHistoryFieldEntitlementId, models.PermHistoryFieldEntitlementExpr,
-		models.PermHistoryFieldAssignmentId,
+		models.PermHistoryFieldAssignmentId, models.PermHistoryFieldRoleRequestId,
+		models.PermHistoryFieldRevokeRequestId,
 	}
 	placeholders := make([]string, 0, len(columns)+2)
 	values := make([]any, 0, len(columns)+2)
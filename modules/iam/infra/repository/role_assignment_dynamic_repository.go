@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"database/sql"
+	stdErr "errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/dig"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	dyorm "github.com/sky-as-code/nikki-erp/common/dynamicmodel/orm"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/database"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/role"
+)
+
+type RoleAssignmentDynamicRepositoryParam struct {
+	dig.In
+
+	Client        dyorm.DbClient
+	ConfigSvc     config.ConfigService
+	QueryBuilder  dyorm.QueryBuilder
+	Logger        logging.LoggerService
+	NewBaseRepoFn dyn.NewBaseDynamicRepositoryFn
+}
+
+func NewRoleAssignmentDynamicRepository(param RoleAssignmentDynamicRepositoryParam) it.RoleAssignmentRepository {
+	dynamicRepo := param.NewBaseRepoFn(
+		dyn.NewBaseRepoParam{
+			Client:       param.Client,
+			ConfigSvc:    param.ConfigSvc,
+			QueryBuilder: param.QueryBuilder,
+			Logger:       param.Logger,
+			Schema:       dmodel.MustGetSchema(domain.RoleUserAssignmentSchemaName),
+		},
+	)
+	return &RoleAssignmentDynamicRepository{dynamicRepo: dynamicRepo}
+}
+
+// RoleAssignmentDynamicRepository works across both assignment tables.
+//
+// Written as raw SQL: the expiry sweep wants one queue ordered by expiry across users
+// and groups, and the generic search path answers one schema at a time. The base
+// repository is kept for the transaction plumbing.
+type RoleAssignmentDynamicRepository struct {
+	dynamicRepo dyn.BaseDynamicRepository
+}
+
+func (this *RoleAssignmentDynamicRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return this.dynamicRepo
+}
+
+func (this *RoleAssignmentDynamicRepository) BeginTransaction(ctx corectx.Context) (database.DbTransaction, error) {
+	return this.dynamicRepo.BeginTransaction(ctx)
+}
+
+// assignmentTable names the table and receiver column behind a receiver kind.
+func assignmentTable(kind it.AssignmentReceiverKind) (table string, receiverColumn string) {
+	if kind == it.AssignmentReceiverGroup {
+		return dmodel.MustGetSchema(domain.RoleGroupAssignmentSchemaName).TableName(),
+			domain.RoleGroupAssignFieldReceiverGroupId
+	}
+	return dmodel.MustGetSchema(domain.RoleUserAssignmentSchemaName).TableName(),
+		domain.RoleUserAssignFieldReceiverUserId
+}
+
+// GrantFromRequest upserts on the (role, receiver) unique key.
+//
+// The conflict branch only fires for a row that already carries an expiry. A row
+// without one is a permanent grant, usually made directly by an administrator, and an
+// approved request for the same role must not quietly turn it into a temporary one.
+// expiry_notified_at is cleared so a renewed grant is warned about again.
+func (this *RoleAssignmentDynamicRepository) GrantFromRequest(
+	ctx corectx.Context, grant it.RoleAssignment,
+) (*it.RoleAssignment, error) {
+	table, receiverColumn := assignmentTable(grant.ReceiverKind)
+	newId, err := model.NewId()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s AS t (id, role_id, %[2]s, role_request_id, approver_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (role_id, %[2]s) DO UPDATE SET
+			role_request_id = EXCLUDED.role_request_id,
+			approver_id = EXCLUDED.approver_id,
+			expires_at = EXCLUDED.expires_at,
+			expiry_notified_at = NULL
+		WHERE t.expires_at IS NOT NULL
+		RETURNING t.id`,
+		table, receiverColumn,
+	)
+	var assignmentId string
+	err = this.dynamicRepo.ExtractClient(ctx).QueryRow(ctx.InnerContext(), query,
+		string(*newId), string(grant.RoleId), string(grant.ReceiverId),
+		nullableId(grant.RoleRequestId), nullableId(grant.ApproverId), nullableTime(grant.ExpiresAt),
+	).Scan(&assignmentId)
+	if stdErr.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := grant
+	result.Id = model.Id(assignmentId)
+	return &result, nil
+}
+
+// RevokeAssignment deletes by the (role, receiver) key rather than by id: a revoke
+// request or a review item names a role and a receiver, not the row that happens to
+// hold them.
+func (this *RoleAssignmentDynamicRepository) RevokeAssignment(
+	ctx corectx.Context, target it.RoleAssignment,
+) (*it.RoleAssignment, error) {
+	table, receiverColumn := assignmentTable(target.ReceiverKind)
+	query := fmt.Sprintf(
+		`DELETE FROM %[1]s WHERE role_id = $1 AND %[2]s = $2
+		RETURNING id, role_request_id, approver_id, expires_at`,
+		table, receiverColumn,
+	)
+
+	var (
+		assignmentId          string
+		requestId, approverId sql.NullString
+		expiresAt             sql.NullTime
+	)
+	err := this.dynamicRepo.ExtractClient(ctx).QueryRow(ctx.InnerContext(), query,
+		string(target.RoleId), string(target.ReceiverId),
+	).Scan(&assignmentId, &requestId, &approverId, &expiresAt)
+	if stdErr.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	removed := target
+	removed.Id = model.Id(assignmentId)
+	removed.RoleRequestId = idFromNull(requestId)
+	removed.ApproverId = idFromNull(approverId)
+	if expiresAt.Valid {
+		removed.ExpiresAt = &expiresAt.Time
+	}
+	return &removed, nil
+}
+
+func (this *RoleAssignmentDynamicRepository) FindExpired(
+	ctx corectx.Context, asOf time.Time, limit int,
+) ([]it.RoleAssignment, error) {
+	return this.findByExpiry(ctx, `expires_at <= $1`, limit, asOf)
+}
+
+func (this *RoleAssignmentDynamicRepository) FindExpiringUnnotified(
+	ctx corectx.Context, asOf time.Time, until time.Time, limit int,
+) ([]it.RoleAssignment, error) {
+	return this.findByExpiry(ctx,
+		`expires_at > $1 AND expires_at <= $2 AND expiry_notified_at IS NULL`, limit, asOf, until)
+}
+
+// findByExpiry reads both tables as one queue, soonest expiry first, so a backlog
+// is worked off in the order it fell due.
+func (this *RoleAssignmentDynamicRepository) findByExpiry(
+	ctx corectx.Context, condition string, limit int, args ...any,
+) ([]it.RoleAssignment, error) {
+	userTable, userColumn := assignmentTable(it.AssignmentReceiverUser)
+	groupTable, groupColumn := assignmentTable(it.AssignmentReceiverGroup)
+	query := fmt.Sprintf(
+		`SELECT id, role_id, '%[1]s', %[2]s, role_request_id, approver_id, expires_at FROM %[3]s WHERE %[7]s
+		UNION ALL
+		SELECT id, role_id, '%[4]s', %[5]s, role_request_id, approver_id, expires_at FROM %[6]s WHERE %[7]s
+		ORDER BY expires_at LIMIT %[8]d`,
+		it.AssignmentReceiverUser, userColumn, userTable,
+		it.AssignmentReceiverGroup, groupColumn, groupTable,
+		condition, limit,
+	)
+
+	rows, err := this.dynamicRepo.ExtractClient(ctx).Query(ctx.InnerContext(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []it.RoleAssignment
+	for rows.Next() {
+		var (
+			id, roleId, kind, receiverId string
+			requestId, approverId        sql.NullString
+			expiresAt                    sql.NullTime
+		)
+		if err := rows.Scan(&id, &roleId, &kind, &receiverId, &requestId, &approverId, &expiresAt); err != nil {
+			return nil, err
+		}
+		assignment := it.RoleAssignment{
+			Id:            model.Id(id),
+			RoleId:        model.Id(roleId),
+			ReceiverKind:  it.AssignmentReceiverKind(kind),
+			ReceiverId:    model.Id(receiverId),
+			RoleRequestId: idFromNull(requestId),
+			ApproverId:    idFromNull(approverId),
+		}
+		if expiresAt.Valid {
+			assignment.ExpiresAt = &expiresAt.Time
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// DeleteExpired re-checks the expiry in the DELETE itself. Between the sweep reading
+// the row and deleting it, a new approval may have extended the grant, and that
+// extension must win.
+func (this *RoleAssignmentDynamicRepository) DeleteExpired(
+	ctx corectx.Context, assignment it.RoleAssignment, asOf time.Time,
+) (bool, error) {
+	table, _ := assignmentTable(assignment.ReceiverKind)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND expires_at <= $2`, table)
+	result, err := this.dynamicRepo.ExtractClient(ctx).Exec(ctx.InnerContext(), query, string(assignment.Id), asOf)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (this *RoleAssignmentDynamicRepository) MarkExpiryNotified(
+	ctx corectx.Context, assignment it.RoleAssignment, at time.Time,
+) error {
+	table, _ := assignmentTable(assignment.ReceiverKind)
+	query := fmt.Sprintf(`UPDATE %s SET expiry_notified_at = $2 WHERE id = $1`, table)
+	_, err := this.dynamicRepo.ExtractClient(ctx).Exec(ctx.InnerContext(), query, string(assignment.Id), at)
+	return err
+}
+
+func nullableId(id *model.Id) any {
+	if id == nil {
+		return nil
+	}
+	return string(*id)
+}
+
+func nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func idFromNull(value sql.NullString) *model.Id {
+	if !value.Valid {
+		return nil
+	}
+	id := model.Id(value.String)
+	return &id
+}
@@ -0,0 +1,28 @@
+package access_review
+
+import (
+	"time"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+type AccessReviewCampaignRepository interface {
+	dyn.DynamicModelRepository
+	GetOne(ctx corectx.Context, param dyn.RepoGetOneParam) (*dyn.OpResult[models.AccessReviewCampaign], error)
+	Search(ctx corectx.Context, param dyn.RepoSearchParam) (*dyn.OpResult[dyn.PagedResultData[models.AccessReviewCampaign]], error)
+	Update(ctx corectx.Context, row models.AccessReviewCampaign) (*dyn.OpResult[dyn.MutateResultData], error)
+	// FindDue returns open campaigns whose deadline is at or before asOf, earliest first.
+	FindDue(ctx corectx.Context, asOf time.Time, limit int) ([]models.AccessReviewCampaign, error)
+}
+
+type AccessReviewItemRepository interface {
+	dyn.DynamicModelRepository
+	GetOne(ctx corectx.Context, param dyn.RepoGetOneParam) (*dyn.OpResult[models.AccessReviewItem], error)
+	Search(ctx corectx.Context, param dyn.RepoSearchParam) (*dyn.OpResult[dyn.PagedResultData[models.AccessReviewItem]], error)
+	Update(ctx corectx.Context, row models.AccessReviewItem) (*dyn.OpResult[dyn.MutateResultData], error)
+	// SnapshotItems writes one pending item for every holding within the campaign's
+	// scope as it stands now, and returns how many it wrote.
+	SnapshotItems(ctx corectx.Context, campaign models.AccessReviewCampaign) (int, error)
+}
@@ -0,0 +1,42 @@
+package access_review
+
+import (
+	"time"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+type AccessReviewDomainService interface {
+	LaunchCampaign(ctx corectx.Context, cmd LaunchCampaignCommand) (*LaunchCampaignResult, error)
+	CloseCampaign(ctx corectx.Context, cmd CloseCampaignCommand) (*CloseCampaignResult, error)
+	DecideReviewItem(ctx corectx.Context, cmd DecideReviewItemCommand) (*DecideReviewItemResult, error)
+	ExportCampaign(ctx corectx.Context, query ExportCampaignQuery) (*ExportCampaignResult, error)
+	// ValidateCampaignEdit refuses a generic edit that moves a campaign between states,
+	// then any edit of one that has been launched: its scope and deadline are what its
+	// items were snapshotted and decided against.
+	ValidateCampaignEdit(ctx corectx.Context, input *domain.AccessReviewCampaign, found *domain.AccessReviewCampaign, vErrs *ft.ClientErrors) error
+
+	// FindDueCampaigns returns open campaigns whose deadline has passed.
+	FindDueCampaigns(ctx corectx.Context, asOf time.Time) ([]domain.AccessReviewCampaign, error)
+	// AutoRevokeItem revokes one item left undecided at its campaign's deadline, in its
+	// own transaction. Returns false when the item was decided in the meantime, or is
+	// not one a deadline revokes.
+	AutoRevokeItem(ctx corectx.Context, item domain.AccessReviewItem) (bool, error)
+	// FindPendingItems returns a batch of the campaign's items still awaiting a decision,
+	// in id order and after afterId when it is given, so a caller pages through them by id.
+	FindPendingItems(
+		ctx corectx.Context, campaign domain.AccessReviewCampaign, afterId *model.Id,
+	) ([]domain.AccessReviewItem, error)
+	// CloseDueCampaign closes a campaign at its deadline, with no signed-in user behind it.
+	CloseDueCampaign(ctx corectx.Context, campaign domain.AccessReviewCampaign) error
+}
+
+type AccessReviewAppService interface {
+	LaunchCampaign(ctx corectx.Context, cmd LaunchCampaignCommand) (*LaunchCampaignResult, error)
+	CloseCampaign(ctx corectx.Context, cmd CloseCampaignCommand) (*CloseCampaignResult, error)
+	DecideReviewItem(ctx corectx.Context, cmd DecideReviewItemCommand) (*DecideReviewItemResult, error)
+	ExportCampaign(ctx corectx.Context, query ExportCampaignQuery) (*ExportCampaignResult, error)
+}
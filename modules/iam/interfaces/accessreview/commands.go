@@ -0,0 +1,92 @@
+package access_review
+
+import (
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/common/util"
+	"github.com/sky-as-code/nikki-erp/modules/core/cqrs"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	domain "github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+func init() {
+	var req cqrs.Request
+	req = (*LaunchCampaignCommand)(nil)
+	req = (*CloseCampaignCommand)(nil)
+	req = (*DecideReviewItemCommand)(nil)
+	req = (*ExportCampaignQuery)(nil)
+	util.Unused(req)
+}
+
+// The campaign commands. Like the grant request workflow, each names the record and the
+// etag the caller last saw, so a decision taken on a stale screen is refused.
+
+var launchCampaignCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "access_review", Action: "launchCampaign",
+}
+
+type LaunchCampaignCommand struct {
+	Id   model.Id   `json:"id" param:"id"`
+	Etag model.Etag `json:"etag"`
+}
+
+func (LaunchCampaignCommand) CqrsRequestType() cqrs.RequestType {
+	return launchCampaignCommandType
+}
+
+type LaunchCampaignResult = dyn.OpResult[dyn.MutateResultData]
+
+var closeCampaignCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "access_review", Action: "closeCampaign",
+}
+
+type CloseCampaignCommand struct {
+	Id   model.Id   `json:"id" param:"id"`
+	Etag model.Etag `json:"etag"`
+}
+
+func (CloseCampaignCommand) CqrsRequestType() cqrs.RequestType {
+	return closeCampaignCommandType
+}
+
+type CloseCampaignResult = dyn.OpResult[dyn.MutateResultData]
+
+var decideReviewItemCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "access_review", Action: "decideReviewItem",
+}
+
+// DecideReviewItemCommand keeps or revokes one item. Decision is either "kept" or
+// "revoked"; "auto_revoked" is reserved for the deadline sweep.
+type DecideReviewItemCommand struct {
+	Id       model.Id                    `json:"id" param:"id"`
+	Etag     model.Etag                  `json:"etag"`
+	Decision domain.AccessReviewDecision `json:"decision"`
+	Comment  *string                     `json:"comment"`
+}
+
+func (DecideReviewItemCommand) CqrsRequestType() cqrs.RequestType {
+	return decideReviewItemCommandType
+}
+
+type DecideReviewItemResult = dyn.OpResult[dyn.MutateResultData]
+
+var exportCampaignQueryType = cqrs.RequestType{
+	Module: "iam", Submodule: "access_review", Action: "exportCampaign",
+}
+
+type ExportCampaignQuery struct {
+	Id model.Id `json:"id" param:"id"`
+}
+
+func (ExportCampaignQuery) CqrsRequestType() cqrs.RequestType {
+	return exportCampaignQueryType
+}
+
+// CampaignExport is the result of a campaign as evidence: the campaign itself, how many
+// items ended in each decision, and every item with its decision.
+type CampaignExport struct {
+	Campaign domain.AccessReviewCampaign `json:"campaign"`
+	Summary  map[string]int              `json:"summary"`
+	Items    []domain.AccessReviewItem   `json:"items"`
+}
+
+type ExportCampaignResult = dyn.OpResult[CampaignExport]
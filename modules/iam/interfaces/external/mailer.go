@@ -0,0 +1,22 @@
+package external
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+)
+
+// MailMessage is one rendered email. HtmlBody is optional; TextBody is always sent so
+// that a client which shows no HTML still shows the whole message.
+type MailMessage struct {
+	To       string
+	Subject  string
+	TextBody string
+	HtmlBody *string
+}
+
+// Mailer delivers an email.
+//
+// It is a port rather than a concrete client so the delivery channel is chosen by the
+// deployment: SMTP when one is configured, the log otherwise.
+type Mailer interface {
+	Send(ctx corectx.Context, message MailMessage) error
+}
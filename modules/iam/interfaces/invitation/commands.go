@@ -0,0 +1,85 @@
+package invitation
+
+import (
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/common/util"
+	"github.com/sky-as-code/nikki-erp/modules/core/cqrs"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+func init() {
+	var req cqrs.Request
+	req = (*SendInvitationCommand)(nil)
+	req = (*AcceptInvitationCommand)(nil)
+	util.Unused(req)
+}
+
+var sendInvitationCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "invitation", Action: "sendInvitation",
+}
+
+// SendInvitationCommand emails a draft or invited user a link to set their first password.
+// Sending again replaces the previous link, which stops working.
+type SendInvitationCommand struct {
+	UserId model.Id `json:"user_id"`
+	// Lang picks the email's language, such as "en-US" or "vi-VN". An unsupported or
+	// missing one falls back to English.
+	Lang *string `json:"lang"`
+}
+
+func (SendInvitationCommand) CqrsRequestType() cqrs.RequestType {
+	return sendInvitationCommandType
+}
+
+func (SendInvitationCommand) GetSchema() *dmodel.ModelSchema {
+	return dmodel.GetOrRegisterSchema(
+		"iam.send_invitation_command",
+		func() *dmodel.ModelSchemaBuilder {
+			return dmodel.DefineModel("_").
+				Field(basemodel.DefineFieldId("user_id").RequiredAlways()).
+				Field(dmodel.DefineField().Name("lang").
+					DataType(dmodel.FieldDataTypeString(0, model.MODEL_RULE_TINY_NAME_LENGTH)))
+		},
+	)
+}
+
+// SendInvitationResultData tells the sender where the link went and until when it works.
+// The link itself is never returned: it is a credential meant for the invitee alone.
+type SendInvitationResultData struct {
+	Email     string              `json:"email"`
+	ExpiresAt model.ModelDateTime `json:"expires_at"`
+}
+type SendInvitationResult = dyn.OpResult[SendInvitationResultData]
+
+var acceptInvitationCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "invitation", Action: "acceptInvitation",
+}
+
+// AcceptInvitationCommand is sent by the invitee, who is not signed in: the token from
+// the emailed link is their only credential.
+type AcceptInvitationCommand struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (AcceptInvitationCommand) CqrsRequestType() cqrs.RequestType {
+	return acceptInvitationCommandType
+}
+
+func (AcceptInvitationCommand) GetSchema() *dmodel.ModelSchema {
+	return dmodel.GetOrRegisterSchema(
+		"iam.accept_invitation_command",
+		func() *dmodel.ModelSchemaBuilder {
+			return dmodel.DefineModel("_").
+				Field(dmodel.DefineField().Name("token").
+					DataType(dmodel.FieldDataTypeSecret(1, model.MODEL_RULE_DESC_LENGTH)).
+					RequiredAlways()).
+				Field(models.DefinePasswordTextField("new_password").RequiredAlways())
+		},
+	)
+}
+
+type AcceptInvitationResult = dyn.OpResult[dyn.MutateResultData]
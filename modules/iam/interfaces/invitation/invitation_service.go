@@ -0,0 +1,17 @@
+package invitation
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+)
+
+type InvitationDomainService interface {
+	SendInvitation(ctx corectx.Context, cmd SendInvitationCommand) (*SendInvitationResult, error)
+	// AcceptInvitation sets the invitee's first password and activates them. The token
+	// stops working once it succeeds.
+	AcceptInvitation(ctx corectx.Context, cmd AcceptInvitationCommand) (*AcceptInvitationResult, error)
+}
+
+type InvitationAppService interface {
+	SendInvitation(ctx corectx.Context, cmd SendInvitationCommand) (*SendInvitationResult, error)
+	AcceptInvitation(ctx corectx.Context, cmd AcceptInvitationCommand) (*AcceptInvitationResult, error)
+}
@@ -0,0 +1,57 @@
+package role
+
+import (
+	"time"
+
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+)
+
+// AssignmentReceiverKind tells which of the two assignment tables a row lives in.
+type AssignmentReceiverKind string
+
+const (
+	AssignmentReceiverUser  = AssignmentReceiverKind("user")
+	AssignmentReceiverGroup = AssignmentReceiverKind("group")
+)
+
+// RoleAssignment is one holding of a role, by a user or by a group, flattened so the
+// expiry sweep can treat both tables as one queue.
+type RoleAssignment struct {
+	Id            model.Id
+	RoleId        model.Id
+	ReceiverKind  AssignmentReceiverKind
+	ReceiverId    model.Id
+	RoleRequestId *model.Id
+	ApproverId    *model.Id
+	// ExpiresAt is nil for a grant that never ends.
+	ExpiresAt *time.Time
+}
+
+// RoleAssignmentRepository reads and writes role assignments by their lifecycle rather
+// than by the M2M edge that ManageUserRoleAssignments and ManageGroupRoleAssignments use.
+//
+// Every method joins the ambient transaction when the context carries one, so an
+// assignment change, its permission rebuild and its audit row commit together.
+type RoleAssignmentRepository interface {
+	dyn.DynamicModelRepository
+
+	// GrantFromRequest creates the assignment an approved request asks for, or refreshes
+	// the request, approver and expiry of one that already exists. Returns nil when the
+	// receiver already holds the role without an expiry: a request does not shorten a
+	// permanent grant.
+	GrantFromRequest(ctx corectx.Context, grant RoleAssignment) (*RoleAssignment, error)
+	// RevokeAssignment removes the receiver's holding of the role and returns what was
+	// removed, or nil when the receiver did not hold it.
+	RevokeAssignment(ctx corectx.Context, target RoleAssignment) (*RoleAssignment, error)
+	// FindExpired returns assignments whose expiry is at or before asOf, oldest first.
+	FindExpired(ctx corectx.Context, asOf time.Time, limit int) ([]RoleAssignment, error)
+	// FindExpiringUnnotified returns assignments expiring within (asOf, until] whose
+	// receiver has not been told yet, soonest first.
+	FindExpiringUnnotified(ctx corectx.Context, asOf time.Time, until time.Time, limit int) ([]RoleAssignment, error)
+	// DeleteExpired removes the assignment only if it is still expired at asOf, and
+	// reports whether it did.
+	DeleteExpired(ctx corectx.Context, assignment RoleAssignment, asOf time.Time) (bool, error)
+	MarkExpiryNotified(ctx corectx.Context, assignment RoleAssignment, at time.Time) error
+}
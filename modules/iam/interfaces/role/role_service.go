@@ -1,6 +1,8 @@
 package role
 
 import (
+	"time"
+
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
@@ -38,3 +40,22 @@ type RoleAppService interface {
 	SetRoleIsArchived(ctx corectx.Context, cmd SetRoleIsArchivedCommand) (*SetRoleIsArchivedResult, error)
 	UpdateRole(ctx corectx.Context, cmd UpdateRoleCommand) (*UpdateRoleResult, error)
 }
+
+// GrantExpiryDomainService ends time-bound grants and warns their receivers first.
+//
+// Each step is exposed per assignment so the scheduled job can keep going past one
+// that fails; a single bad row must not strand every grant queued behind it.
+type GrantExpiryDomainService interface {
+	FindExpiredGrants(ctx corectx.Context, asOf time.Time) ([]RoleAssignment, error)
+	RevokeExpiredGrant(ctx corectx.Context, assignment RoleAssignment, asOf time.Time) (bool, error)
+	FindGrantsNearingExpiry(ctx corectx.Context, asOf time.Time, until time.Time) ([]RoleAssignment, error)
+	NotifyGrantNearingExpiry(ctx corectx.Context, assignment RoleAssignment, notifiedAt time.Time) error
+}
+
+// GrantExpiryNotifier tells a receiver that a grant is about to run out.
+//
+// It is a port rather than a concrete mailer so the delivery channel can change
+// without touching the sweep. For a group assignment the receiver is the group.
+type GrantExpiryNotifier interface {
+	NotifyGrantExpiring(ctx corectx.Context, assignment RoleAssignment) error
+}
@@ -1,6 +1,7 @@
 package role_request
 
 import (
+	"github.com/sky-as-code/nikki-erp/common/model"
 	"github.com/sky-as-code/nikki-erp/common/util"
 	"github.com/sky-as-code/nikki-erp/modules/core/cqrs"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
@@ -17,6 +18,9 @@ func init() {
 	req = (*RoleRequestExistsQuery)(nil)
 	req = (*SearchRoleRequestsQuery)(nil)
 	req = (*UpdateRoleRequestCommand)(nil)
+	req = (*ApproveRoleRequestCommand)(nil)
+	req = (*RejectRoleRequestCommand)(nil)
+	req = (*CancelRoleRequestCommand)(nil)
 	util.Unused(req)
 }
 
@@ -98,3 +102,53 @@ func (UpdateRoleRequestCommand) GetSchema() *dmodel.ModelSchema {
 }
 
 type UpdateRoleRequestResult = dyn.OpResult[dyn.MutateResultData]
+
+// The workflow commands. Each names the request and the etag the caller last saw, so a
+// decision taken on a stale screen is refused rather than applied to a request that
+// has since changed.
+
+var approveRoleRequestCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "role_request", Action: "approveRoleRequest",
+}
+
+type ApproveRoleRequestCommand struct {
+	Id   model.Id   `json:"id" param:"id"`
+	Etag model.Etag `json:"etag"`
+}
+
+func (ApproveRoleRequestCommand) CqrsRequestType() cqrs.RequestType {
+	return approveRoleRequestCommandType
+}
+
+type ApproveRoleRequestResult = dyn.OpResult[dyn.MutateResultData]
+
+var rejectRoleRequestCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "role_request", Action: "rejectRoleRequest",
+}
+
+type RejectRoleRequestCommand struct {
+	Id     model.Id   `json:"id" param:"id"`
+	Etag   model.Etag `json:"etag"`
+	Reason *string    `json:"reason"`
+}
+
+func (RejectRoleRequestCommand) CqrsRequestType() cqrs.RequestType {
+	return rejectRoleRequestCommandType
+}
+
+type RejectRoleRequestResult = dyn.OpResult[dyn.MutateResultData]
+
+var cancelRoleRequestCommandType = cqrs.RequestType{
+	Module: "iam", Submodule: "role_request", Action: "cancelRoleRequest",
+}
+
+type CancelRoleRequestCommand struct {
+	Id   model.Id   `json:"id" param:"id"`
+	Etag model.Etag `json:"etag"`
+}
+
+func (CancelRoleRequestCommand) CqrsRequestType() cqrs.RequestType {
+	return cancelRoleRequestCommandType
+}
+
+type CancelRoleRequestResult = dyn.OpResult[dyn.MutateResultData]
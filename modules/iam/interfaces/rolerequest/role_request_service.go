@@ -1,6 +1,7 @@
 package role_request
 
 import (
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	corecrud "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/crud"
@@ -14,6 +15,16 @@ type RoleRequestDomainService interface {
 	RoleRequestExists(ctx corectx.Context, query RoleRequestExistsQuery) (*RoleRequestExistsResult, error)
 	SearchRoleRequests(ctx corectx.Context, query SearchRoleRequestsQuery, opts ...corecrud.ServiceSearchOptions) (*SearchRoleRequestsResult, error)
 	UpdateRoleRequest(ctx corectx.Context, cmd UpdateRoleRequestCommand, opts ...corecrud.ServiceUpdateOptions[*domain.RoleRequest]) (*UpdateRoleRequestResult, error)
+
+	ApproveRoleRequest(ctx corectx.Context, cmd ApproveRoleRequestCommand) (*ApproveRoleRequestResult, error)
+	RejectRoleRequest(ctx corectx.Context, cmd RejectRoleRequestCommand) (*RejectRoleRequestResult, error)
+	CancelRoleRequest(ctx corectx.Context, cmd CancelRoleRequestCommand) (*CancelRoleRequestResult, error)
+	// ValidateNewRoleRequest applies the requested role's own rules to a request about
+	// to be created: whether it may be requested at all, and what must come with it.
+	ValidateNewRoleRequest(ctx corectx.Context, request *domain.RoleRequest, vErrs *ft.ClientErrors) error
+	// ValidateRoleRequestEdit refuses an edit of a request that is no longer pending, or
+	// one that tries to decide the request instead of going through the actions above.
+	ValidateRoleRequestEdit(ctx corectx.Context, input *domain.RoleRequest, found *domain.RoleRequest, vErrs *ft.ClientErrors) error
 }
 
 type RoleRequestAppService interface {
@@ -23,4 +34,8 @@ type RoleRequestAppService interface {
 	RoleRequestExists(ctx corectx.Context, query RoleRequestExistsQuery) (*RoleRequestExistsResult, error)
 	SearchRoleRequests(ctx corectx.Context, query SearchRoleRequestsQuery) (*SearchRoleRequestsResult, error)
 	UpdateRoleRequest(ctx corectx.Context, cmd UpdateRoleRequestCommand) (*UpdateRoleRequestResult, error)
+
+	ApproveRoleRequest(ctx corectx.Context, cmd ApproveRoleRequestCommand) (*ApproveRoleRequestResult, error)
+	RejectRoleRequest(ctx corectx.Context, cmd RejectRoleRequestCommand) (*RejectRoleRequestResult, error)
+	CancelRoleRequest(ctx corectx.Context, cmd CancelRoleRequestCommand) (*CancelRoleRequestResult, error)
 }
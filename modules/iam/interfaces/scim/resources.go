@@ -0,0 +1,148 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// The schema URNs of RFC 7643 and 7644 this server speaks.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+// MaxResults is the largest page a list answers, advertised in the service provider
+// config. It matches the largest page the dynamic model search accepts.
+const MaxResults = 500
+
+// User is the SCIM core User resource, restricted to the attributes an IAM user has.
+//
+// userName is the user's email, which is how IAM identifies a user. The same address is
+// answered as the only, primary, email.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName *string  `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+type Name struct {
+	Formatted  *string `json:"formatted,omitempty"`
+	GivenName  *string `json:"givenName,omitempty"`
+	FamilyName *string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string  `json:"value"`
+	Type    *string `json:"type,omitempty"`
+	Primary *bool   `json:"primary,omitempty"`
+}
+
+// Group is the SCIM core Group resource. Its members are users only: IAM groups do not nest.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+type Member struct {
+	Value   string  `json:"value"`
+	Display *string `json:"display,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string  `json:"resourceType"`
+	Created      *string `json:"created,omitempty"`
+	LastModified *string `json:"lastModified,omitempty"`
+	Version      string  `json:"version,omitempty"`
+	Location     string  `json:"location,omitempty"`
+}
+
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation keeps Value as decoded JSON: its shape depends on the op and the path,
+// and some clients send booleans as strings.
+type PatchOperation struct {
+	Op    string  `json:"op"`
+	Path  *string `json:"path,omitempty"`
+	Value any     `json:"value,omitempty"`
+}
+
+// The scimType values of RFC 7644 section 3.12 this server answers.
+const (
+	ErrTypeInvalidFilter = "invalidFilter"
+	ErrTypeInvalidSyntax = "invalidSyntax"
+	ErrTypeInvalidPath   = "invalidPath"
+	ErrTypeInvalidValue  = "invalidValue"
+	ErrTypeNoTarget      = "noTarget"
+	ErrTypeMutability    = "mutability"
+	ErrTypeUniqueness    = "uniqueness"
+)
+
+// Error is the SCIM error response. A provisioning client expects it instead of this
+// module's ClientErrors, so the SCIM service answers its own failures in this shape.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func NewError(status int, scimType string, detail string) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+func NewBadRequestError(scimType string, detail string) *Error {
+	return NewError(http.StatusBadRequest, scimType, detail)
+}
+
+func NewNotFoundError(resourceType string, id string) *Error {
+	return NewError(http.StatusNotFound, "", resourceType+" "+id+" not found")
+}
+
+// HttpStatus is the status the error is answered with.
+func (this Error) HttpStatus() int {
+	status, err := strconv.Atoi(this.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// Result is what the SCIM service answers: the resource, or the SCIM error the client is
+// to receive. A Go error alongside it still means the request could not be processed.
+type Result[T any] struct {
+	Data  *T
+	Error *Error
+}
@@ -0,0 +1,102 @@
+package scim
+
+import (
+	ds "github.com/sky-as-code/nikki-erp/common/datastructure"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/requestguard"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/iam/domain/models"
+)
+
+// Client is the provisioning client a SCIM token authenticated.
+type Client struct {
+	TokenId model.Id
+	OrgId   model.Id
+	OwnerId model.Id
+	// Owner is what the token's owner is granted as of the request.
+	Owner corectx.ContextPermissions
+}
+
+// provisionedResources are the engines the SCIM service drives on a client's behalf.
+var provisionedResources = []string{
+	models.UserSchemaName,
+	models.GroupSchemaName,
+	models.GrpUsrRelSchemaName,
+}
+
+// provisioningPermissions are the permissions the SCIM service asks those engines for.
+var provisioningPermissions = []string{
+	drif.PermissionCreate,
+	drif.PermissionRead,
+	drif.PermissionUpdate,
+	drif.PermissionDelete,
+}
+
+// Permissions is what a SCIM request runs with.
+//
+// The grants are domain-wide because the engines check permissions without knowing the
+// record's org. Keeping the client within its own organization is the SCIM service's job:
+// it reads and writes only the users that are members of Client.OrgId, and the groups
+// that belong to it.
+//
+// A permission is granted only when the token's owner holds it in Client.OrgId, so a token
+// never does more than its owner could there, and stops doing what its owner loses.
+//
+// The request acts on behalf of the token's owner.
+func (this Client) Permissions() corectx.ContextPermissions {
+	evalCtx := requestguard.EvalContextFrom(this.Owner)
+	entitlements := ds.NewSet[string]()
+	for _, resource := range provisionedResources {
+		for _, permission := range provisioningPermissions {
+			required := requestguard.PermFor(permission, resource, requestguard.ResourceScopeOrg).InOrg(&this.OrgId)
+			if this.Owner.IsOwner || this.ownerHoldsAny(requestguard.CandidateExpressions(required, evalCtx)) {
+				entitlements.Add(requestguard.BuildExpression(
+					permission, resource, requestguard.ResourceScopeDomain, nil))
+			}
+		}
+	}
+	orgIds := ds.NewSet[model.Id]()
+	orgIds.Add(this.OrgId)
+	return corectx.ContextPermissions{
+		UserId:       this.OwnerId,
+		Entitlements: entitlements,
+		UserOrgIds:   orgIds,
+	}
+}
+
+func (this Client) ownerHoldsAny(expressions []string) bool {
+	for _, expression := range expressions {
+		if this.Owner.Entitlements.Contains(expression) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListQuery carries the list parameters of RFC 7644 section 3.4.2. StartIndex is 1-based.
+type ListQuery struct {
+	Filter     *string
+	StartIndex int
+	Count      *int
+}
+
+type ScimAppService interface {
+	// Authenticate returns the client the bearer token belongs to, or nil when the token is
+	// unknown or expired.
+	Authenticate(ctx corectx.Context, bearerToken string) (*Client, error)
+
+	ListUsers(ctx corectx.Context, client Client, query ListQuery) (*Result[ListResponse[User]], error)
+	GetUser(ctx corectx.Context, client Client, id string) (*Result[User], error)
+	CreateUser(ctx corectx.Context, client Client, user User) (*Result[User], error)
+	ReplaceUser(ctx corectx.Context, client Client, id string, user User) (*Result[User], error)
+	PatchUser(ctx corectx.Context, client Client, id string, patch PatchRequest) (*Result[User], error)
+	DeleteUser(ctx corectx.Context, client Client, id string) (*Result[struct{}], error)
+
+	ListGroups(ctx corectx.Context, client Client, query ListQuery) (*Result[ListResponse[Group]], error)
+	GetGroup(ctx corectx.Context, client Client, id string) (*Result[Group], error)
+	CreateGroup(ctx corectx.Context, client Client, group Group) (*Result[Group], error)
+	ReplaceGroup(ctx corectx.Context, client Client, id string, group Group) (*Result[Group], error)
+	PatchGroup(ctx corectx.Context, client Client, id string, patch PatchRequest) (*Result[Group], error)
+	DeleteGroup(ctx corectx.Context, client Client, id string) (*Result[struct{}], error)
+}
@@ -25,7 +25,9 @@ func InitRestfulHandlers() error {
 		v1.NewRoleRest,
 		v1.NewLoginRest,
 		v1.NewPasswordRest,
+		v1.NewInvitationRest,
 		v1.NewPermissionRest,
+		v1.NewScimRest,
 		// v1.NewRoleRequestRest,
 	)
 	err = stdErr.Join(
@@ -33,6 +35,7 @@ func InitRestfulHandlers() error {
 		initIamDirectoryV1(),
 		initIamAuthorizationV1(),
 		initIamSignInV1(),
+		initIamScimV2(),
 	)
 	return err
 }
@@ -195,6 +198,7 @@ func initIamSignInV1() error {
 		route *echo.Group,
 		loginRest *v1.LoginRest,
 		passwordRest *v1.PasswordRest,
+		invitationRest *v1.InvitationRest,
 	) {
 		routeV1 := route.Group("/v1/iam")
 
@@ -206,5 +210,37 @@ func initIamSignInV1() error {
 		routeV1.POST("/passwords/passwordtmp", passwordRest.CreatePasswordTemp, m.SmokeAuthz())
 		routeV1.POST("/passwords/passwordotp", passwordRest.CreatePasswordOtp, m.SmokeAuthz())
 		routeV1.POST("/passwords/passwordotp/confirm", passwordRest.ConfirmPasswordOtp, m.SmokeAuthz())
+
+		// Public: the invitee has no password to sign in with yet. The token from the
+		// emailed link is what authorizes the call.
+		routeV1.POST("/invitations/accept", invitationRest.AcceptInvitation, m.PublicUnauthorized)
+	})
+}
+
+// initIamScimV2 serves SCIM provisioning at the paths identity providers expect. It is
+// authenticated by an organization's SCIM token instead of a user's JWT.
+func initIamScimV2() error {
+	return deps.Invoke(func(
+		route *echo.Group,
+		scimRest *v1.ScimRest,
+	) {
+		routeScim := route.Group("/scim/v2")
+
+		// Public, as RFC 7644 allows: it describes the server, not any organization's data.
+		routeScim.GET("/ServiceProviderConfig", scimRest.GetServiceProviderConfig, m.PublicUnauthorized)
+
+		routeScim.DELETE("/Users/:id", scimRest.DeleteUser, scimRest.Authenticate)
+		routeScim.GET("/Users/:id", scimRest.GetUser, scimRest.Authenticate)
+		routeScim.GET("/Users", scimRest.ListUsers, scimRest.Authenticate)
+		routeScim.PATCH("/Users/:id", scimRest.PatchUser, scimRest.Authenticate)
+		routeScim.POST("/Users", scimRest.CreateUser, scimRest.Authenticate)
+		routeScim.PUT("/Users/:id", scimRest.ReplaceUser, scimRest.Authenticate)
+
+		routeScim.DELETE("/Groups/:id", scimRest.DeleteGroup, scimRest.Authenticate)
+		routeScim.GET("/Groups/:id", scimRest.GetGroup, scimRest.Authenticate)
+		routeScim.GET("/Groups", scimRest.ListGroups, scimRest.Authenticate)
+		routeScim.PATCH("/Groups/:id", scimRest.PatchGroup, scimRest.Authenticate)
+		routeScim.POST("/Groups", scimRest.CreateGroup, scimRest.Authenticate)
+		routeScim.PUT("/Groups/:id", scimRest.ReplaceGroup, scimRest.Authenticate)
 	})
 }
@@ -0,0 +1,18 @@
+package v1
+
+import (
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+)
+
+type AcceptInvitationRequest = it.AcceptInvitationCommand
+
+type AcceptInvitationResponse struct {
+	ActivatedAt string `json:"activated_at"`
+}
+
+func NewAcceptInvitationResponse(data dyn.MutateResultData) AcceptInvitationResponse {
+	return AcceptInvitationResponse{
+		ActivatedAt: data.AffectedAt.String(),
+	}
+}
@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"github.com/labstack/echo/v5"
+	"go.uber.org/dig"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/modules/core/httpserver"
+	it "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/invitation"
+)
+
+type invitationRestParam struct {
+	dig.In
+
+	InvitationSvc it.InvitationAppService
+}
+
+func NewInvitationRest(params invitationRestParam) *InvitationRest {
+	return &InvitationRest{
+		invitationSvc: params.InvitationSvc,
+	}
+}
+
+// InvitationRest serves only the invitee's side. Sending is the send_invitation action
+// of the user engine.
+type InvitationRest struct {
+	httpserver.RestBase
+	invitationSvc it.InvitationAppService
+}
+
+func (this InvitationRest) AcceptInvitation(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle REST accept invitation"); e != nil {
+			err = e
+		}
+	}()
+	return httpserver.ServeRequest2(
+		echoCtx,
+		this.invitationSvc.AcceptInvitation,
+		func(request AcceptInvitationRequest) it.AcceptInvitationCommand {
+			return it.AcceptInvitationCommand(request)
+		},
+		NewAcceptInvitationResponse,
+		httpserver.JsonOk,
+	)
+}
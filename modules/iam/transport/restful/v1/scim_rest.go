@@ -0,0 +1,374 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"go.uber.org/dig"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	c "github.com/sky-as-code/nikki-erp/modules/core/httpserver/constants"
+	itScim "github.com/sky-as-code/nikki-erp/modules/iam/interfaces/scim"
+)
+
+type scimRestParam struct {
+	dig.In
+
+	ScimSvc itScim.ScimAppService
+}
+
+func NewScimRest(params scimRestParam) *ScimRest {
+	return &ScimRest{
+		scimSvc: params.ScimSvc,
+	}
+}
+
+// ScimRest serves SCIM 2.0 (RFC 7644) to an organization's identity provider. It speaks
+// SCIM's own payloads and errors rather than this API's, since the clients are
+// off-the-shelf provisioning connectors.
+type ScimRest struct {
+	scimSvc itScim.ScimAppService
+}
+
+const scimContentType = "application/scim+json"
+
+// scimClientKey holds the authenticated provisioning client in the request context.
+type scimClientKey struct{}
+
+// Authenticate is the middleware of every SCIM route. It replaces the JWT check of the
+// other routes with the organization's bearer token, and runs the request with the
+// permissions the token grants.
+func (this ScimRest) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(echoCtx *echo.Context) error {
+		reqCtx, err := corectx.AsRequestContext(echoCtx)
+		if err != nil {
+			return err
+		}
+		bearer, found := strings.CutPrefix(echoCtx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !found {
+			return scimUnauthorized(echoCtx)
+		}
+		client, err := this.scimSvc.Authenticate(reqCtx, strings.TrimSpace(bearer))
+		if err != nil {
+			return err
+		}
+		if client == nil {
+			return scimUnauthorized(echoCtx)
+		}
+
+		reqCtx.SetPermissions(client.Permissions())
+		reqCtx.WithValue(scimClientKey{}, *client)
+		reqCtx.WithValue(c.CtxKeyIsAuthorized, true)
+		return next(echoCtx)
+	}
+}
+
+func (this ScimRest) ListUsers(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM list users"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.ListResponse[itScim.User]], error) {
+		query, sErr := readScimListQuery(echoCtx)
+		if sErr != nil {
+			return &itScim.Result[itScim.ListResponse[itScim.User]]{Error: sErr}, nil
+		}
+		result, err := this.scimSvc.ListUsers(ctx, client, *query)
+		if err == nil && result.Data != nil {
+			for i := range result.Data.Resources {
+				setScimUserLocation(echoCtx, &result.Data.Resources[i])
+			}
+		}
+		return result, err
+	})
+}
+
+func (this ScimRest) GetUser(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM get user"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.User], error) {
+		return withScimUserLocation(echoCtx)(this.scimSvc.GetUser(ctx, client, echoCtx.Param("id")))
+	})
+}
+
+func (this ScimRest) CreateUser(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM create user"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusCreated, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.User], error) {
+		user := itScim.User{}
+		if sErr := readScimBody(echoCtx, &user); sErr != nil {
+			return &itScim.Result[itScim.User]{Error: sErr}, nil
+		}
+		return withScimUserLocation(echoCtx)(this.scimSvc.CreateUser(ctx, client, user))
+	})
+}
+
+func (this ScimRest) ReplaceUser(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM replace user"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.User], error) {
+		user := itScim.User{}
+		if sErr := readScimBody(echoCtx, &user); sErr != nil {
+			return &itScim.Result[itScim.User]{Error: sErr}, nil
+		}
+		return withScimUserLocation(echoCtx)(this.scimSvc.ReplaceUser(ctx, client, echoCtx.Param("id"), user))
+	})
+}
+
+func (this ScimRest) PatchUser(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM patch user"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.User], error) {
+		patch := itScim.PatchRequest{}
+		if sErr := readScimBody(echoCtx, &patch); sErr != nil {
+			return &itScim.Result[itScim.User]{Error: sErr}, nil
+		}
+		return withScimUserLocation(echoCtx)(this.scimSvc.PatchUser(ctx, client, echoCtx.Param("id"), patch))
+	})
+}
+
+func (this ScimRest) DeleteUser(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM delete user"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusNoContent, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[struct{}], error) {
+		return this.scimSvc.DeleteUser(ctx, client, echoCtx.Param("id"))
+	})
+}
+
+func (this ScimRest) ListGroups(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM list groups"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.ListResponse[itScim.Group]], error) {
+		query, sErr := readScimListQuery(echoCtx)
+		if sErr != nil {
+			return &itScim.Result[itScim.ListResponse[itScim.Group]]{Error: sErr}, nil
+		}
+		result, err := this.scimSvc.ListGroups(ctx, client, *query)
+		if err == nil && result.Data != nil {
+			for i := range result.Data.Resources {
+				setScimGroupLocation(echoCtx, &result.Data.Resources[i])
+			}
+		}
+		return result, err
+	})
+}
+
+func (this ScimRest) GetGroup(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM get group"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.Group], error) {
+		return withScimGroupLocation(echoCtx)(this.scimSvc.GetGroup(ctx, client, echoCtx.Param("id")))
+	})
+}
+
+func (this ScimRest) CreateGroup(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM create group"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusCreated, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.Group], error) {
+		group := itScim.Group{}
+		if sErr := readScimBody(echoCtx, &group); sErr != nil {
+			return &itScim.Result[itScim.Group]{Error: sErr}, nil
+		}
+		return withScimGroupLocation(echoCtx)(this.scimSvc.CreateGroup(ctx, client, group))
+	})
+}
+
+func (this ScimRest) ReplaceGroup(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM replace group"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.Group], error) {
+		group := itScim.Group{}
+		if sErr := readScimBody(echoCtx, &group); sErr != nil {
+			return &itScim.Result[itScim.Group]{Error: sErr}, nil
+		}
+		return withScimGroupLocation(echoCtx)(this.scimSvc.ReplaceGroup(ctx, client, echoCtx.Param("id"), group))
+	})
+}
+
+func (this ScimRest) PatchGroup(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM patch group"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusOK, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[itScim.Group], error) {
+		patch := itScim.PatchRequest{}
+		if sErr := readScimBody(echoCtx, &patch); sErr != nil {
+			return &itScim.Result[itScim.Group]{Error: sErr}, nil
+		}
+		return withScimGroupLocation(echoCtx)(this.scimSvc.PatchGroup(ctx, client, echoCtx.Param("id"), patch))
+	})
+}
+
+func (this ScimRest) DeleteGroup(echoCtx *echo.Context) (err error) {
+	defer func() {
+		if e := ft.RecoverPanicFailedTo(recover(), "handle SCIM delete group"); e != nil {
+			err = e
+		}
+	}()
+	return serveScim(echoCtx, http.StatusNoContent, func(ctx corectx.Context, client itScim.Client) (*itScim.Result[struct{}], error) {
+		return this.scimSvc.DeleteGroup(ctx, client, echoCtx.Param("id"))
+	})
+}
+
+// GetServiceProviderConfig tells the client which optional parts of SCIM are served.
+func (this ScimRest) GetServiceProviderConfig(echoCtx *echo.Context) error {
+	unsupported := map[string]any{"supported": false}
+	return scimJson(echoCtx, http.StatusOK, map[string]any{
+		"schemas":        []string{itScim.SchemaServiceProviderConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": itScim.MaxResults},
+		"changePassword": unsupported,
+		"sort":           unsupported,
+		"etag":           unsupported,
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "A SCIM token issued to the organization",
+			"primary":     true,
+		}},
+	})
+}
+
+// serveScim runs one SCIM operation for the authenticated client and answers its resource
+// with the given status, or its SCIM error.
+func serveScim[T any](
+	echoCtx *echo.Context, status int,
+	handle func(ctx corectx.Context, client itScim.Client) (*itScim.Result[T], error),
+) error {
+	reqCtx, err := corectx.AsRequestContext(echoCtx)
+	if err != nil {
+		return err
+	}
+	client, ok := reqCtx.Value(scimClientKey{}).(itScim.Client)
+	if !ok {
+		return scimUnauthorized(echoCtx)
+	}
+	result, err := handle(reqCtx, client)
+	if err != nil {
+		return err
+	}
+	if result.Error != nil {
+		return scimJson(echoCtx, result.Error.HttpStatus(), result.Error)
+	}
+	if status == http.StatusNoContent {
+		return echoCtx.NoContent(status)
+	}
+	return scimJson(echoCtx, status, result.Data)
+}
+
+func scimJson(echoCtx *echo.Context, status int, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return echoCtx.Blob(status, scimContentType, body)
+}
+
+func scimUnauthorized(echoCtx *echo.Context) error {
+	echoCtx.Response().Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+	sErr := itScim.NewError(http.StatusUnauthorized, "", "a valid SCIM bearer token is required")
+	return scimJson(echoCtx, http.StatusUnauthorized, sErr)
+}
+
+// readScimBody decodes the body whatever its declared content type, since SCIM clients
+// send application/scim+json, which the default binder does not know.
+func readScimBody(echoCtx *echo.Context, target any) *itScim.Error {
+	if err := json.NewDecoder(echoCtx.Request().Body).Decode(target); err != nil {
+		return itScim.NewBadRequestError(itScim.ErrTypeInvalidSyntax, "the request body is not valid JSON")
+	}
+	return nil
+}
+
+func readScimListQuery(echoCtx *echo.Context) (*itScim.ListQuery, *itScim.Error) {
+	query := &itScim.ListQuery{StartIndex: 1}
+	if filter := echoCtx.QueryParam("filter"); filter != "" {
+		query.Filter = &filter
+	}
+	if raw := echoCtx.QueryParam("startIndex"); raw != "" {
+		startIndex, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, "startIndex must be an integer")
+		}
+		query.StartIndex = startIndex
+	}
+	if raw := echoCtx.QueryParam("count"); raw != "" {
+		count, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, itScim.NewBadRequestError(itScim.ErrTypeInvalidValue, "count must be an integer")
+		}
+		query.Count = &count
+	}
+	return query, nil
+}
+
+func withScimUserLocation(echoCtx *echo.Context) func(*itScim.Result[itScim.User], error) (*itScim.Result[itScim.User], error) {
+	return func(result *itScim.Result[itScim.User], err error) (*itScim.Result[itScim.User], error) {
+		if err == nil && result.Data != nil {
+			setScimUserLocation(echoCtx, result.Data)
+		}
+		return result, err
+	}
+}
+
+func withScimGroupLocation(echoCtx *echo.Context) func(*itScim.Result[itScim.Group], error) (*itScim.Result[itScim.Group], error) {
+	return func(result *itScim.Result[itScim.Group], err error) (*itScim.Result[itScim.Group], error) {
+		if err == nil && result.Data != nil {
+			setScimGroupLocation(echoCtx, result.Data)
+		}
+		return result, err
+	}
+}
+
+func setScimUserLocation(echoCtx *echo.Context, user *itScim.User) {
+	if user.Meta != nil {
+		user.Meta.Location = scimLocation(echoCtx, "Users", user.Id)
+	}
+}
+
+func setScimGroupLocation(echoCtx *echo.Context, group *itScim.Group) {
+	if group.Meta != nil {
+		group.Meta.Location = scimLocation(echoCtx, "Groups", group.Id)
+	}
+}
+
+// scimLocation builds the resource's URL from the request's own, so that it holds behind
+// whatever host and prefix the client reached the API through.
+func scimLocation(echoCtx *echo.Context, collection string, id string) string {
+	path := echoCtx.Request().URL.Path
+	base := path[:strings.LastIndex(path, "/"+collection)+1]
+	return echoCtx.Scheme() + "://" + echoCtx.Request().Host + base + collection + "/" + id
+}
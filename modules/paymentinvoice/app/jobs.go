@@ -17,19 +17,23 @@ import (
 //
 // The watchdog runs often because the window between a lost callback and a customer complaining is
 // short. The cleaner runs once a day because it only deletes what nobody is going to ask about.
+// Recurring invoices are raised hourly: a period is due on a date, not at a time, so an hour's
+// delay costs nothing, and a run that failed is retried within the same day.
 //
-// Single instance is assumed, inherited from the service this replaces (its process manager pinned
-// one instance). Two instances would run every sweep twice; that is not corrupting — each write
-// re-checks the order's state inside its own transaction, so the second run finds nothing to do —
-// but it is wasted work, and distributed locking is out of scope until the deployment needs it.
+// Every sweep runs once per tick across the cluster: the scheduler takes a lock on the tick, and
+// only the instance that gets it runs the sweep. Were two runs to overlap anyway — a tick lock that
+// expired under a run slower than its timeout — that is not corrupting: each write re-checks the
+// order's state inside its own transaction, so the second run finds nothing to do.
 const (
 	cronWatchdog  = "* * * * *"
 	cronCleaner   = "0 0 * * *"
 	cronSyncRetry = "*/5 * * * *"
+	cronRecurring = "15 * * * *"
 
 	jobNameWatchdog  = "paymentinvoice-watchdog"
 	jobNameCleaner   = "paymentinvoice-cleaner"
 	jobNameSyncRetry = "paymentinvoice-sync-retry"
+	jobNameRecurring = "paymentinvoice-recurring-invoices"
 )
 
 // JobsConfig is the tuning the sweeps read from configuration.
@@ -43,10 +47,11 @@ type JobsConfig struct {
 
 // JobsManager runs the module's background sweeps.
 type JobsManager struct {
-	orders *services.OrderDomainService
-	sync   *ResultSyncClient
-	config JobsConfig
-	logger logging.LoggerService
+	orders     *services.OrderDomainService
+	invoices   *services.InvoiceDomainService
+	deliveries *services.SyncDeliveryDomainService
+	config     JobsConfig
+	logger     logging.LoggerService
 
 	// now is injected so the sweeps can be tested against a fixed clock rather than by waiting.
 	now func() time.Time
@@ -54,25 +59,28 @@ type JobsManager struct {
 
 func NewJobsManager(
 	orders *services.OrderDomainService,
-	syncClient *ResultSyncClient,
+	invoices *services.InvoiceDomainService,
+	deliveries *services.SyncDeliveryDomainService,
 	config JobsConfig,
 	logger logging.LoggerService,
 ) *JobsManager {
 	return &JobsManager{
-		orders: orders,
-		sync:   syncClient,
-		config: config,
-		logger: logger,
-		now:    time.Now,
+		orders:     orders,
+		invoices:   invoices,
+		deliveries: deliveries,
+		config:     config,
+		logger:     logger,
+		now:        time.Now,
 	}
 }
 
-// RegisterJobs puts the three sweeps on the scheduler.
+// RegisterJobs puts the sweeps on the scheduler.
 func (this *JobsManager) RegisterJobs(registry job.CronjobRegistry) error {
 	return stdErr.Join(
 		registry.Register(cronWatchdog, jobNameWatchdog, wrap(this.Watchdog)),
 		registry.Register(cronCleaner, jobNameCleaner, wrap(this.Cleaner)),
 		registry.Register(cronSyncRetry, jobNameSyncRetry, wrap(this.SyncRetry)),
+		registry.Register(cronRecurring, jobNameRecurring, wrap(this.RecurringInvoices)),
 	)
 }
 
@@ -171,36 +179,75 @@ func (this *JobsManager) Cleaner(ctx corectx.Context) error {
 	return nil
 }
 
-// SyncRetry re-notifies the ordering system about payments it was never told of.
+// SyncRetry re-sends the notifications the ordering system has not accepted yet.
 //
 // The service this module replaces declared this job and never implemented it, so a tenant that
-// was down when a payment settled never learned of it at all. The retry is bounded by the client's
-// own attempt count; an order whose notification keeps failing stops being retried and stays
-// visibly failed rather than being retried forever.
+// was down when a payment settled never learned of it at all. Each delivery is retried on its own
+// schedule, the wait doubling with every failed attempt, and one that runs out of attempts is
+// dead-lettered: it stays visibly undelivered and is sent again only by a replay.
 func (this *JobsManager) SyncRetry(ctx corectx.Context) error {
-	pending, err := services.FindOrdersNeedingSync(ctx)
+	attempts, err := this.deliveries.RetryDue(ctx)
+	for _, attempt := range attempts {
+		this.logAttempt(attempt)
+	}
 	if err != nil {
 		return errors.Wrap(err, jobNameSyncRetry)
 	}
-	if len(pending) == 0 {
+	return nil
+}
+
+// RecurringInvoices raises the invoices recurring schedules have fallen due for.
+//
+// Every invoice raised is logged with the schedule and period it was raised for, so what the job
+// did on a given day can be read back without querying the runs. One schedule's failure does not
+// stop the sweep, for the same reason as the watchdog's: a schedule with a bad row would otherwise
+// hold back every schedule after it, and meet the next run first again.
+func (this *JobsManager) RecurringInvoices(ctx corectx.Context) error {
+	today := this.now().UTC()
+
+	due, err := services.FindDueRecurringInvoices(ctx, today)
+	if err != nil {
+		return errors.Wrap(err, jobNameRecurring)
+	}
+	if len(due) == 0 {
 		return nil
 	}
-	this.logger.Infof("%s: %d order(s) to re-notify", jobNameSyncRetry, len(pending))
+	this.logger.Infof("%s: %d schedule(s) due", jobNameRecurring, len(due))
 
-	for _, order := range pending {
-		this.notify(ctx, services.StaleOrder{
-			Pk:        order.Pk,
-			OrderId:   order.OrderId,
-			ReturnUrl: order.ReturnUrl,
-		}, order.Status)
+	for _, recurring := range due {
+		generations, err := this.invoices.GenerateRecurring(ctx, recurring, today)
+		for _, generation := range generations {
+			this.logGeneration(generation)
+		}
+		if err != nil {
+			this.logger.Errorf("%s: %s", jobNameRecurring, err.Error())
+		}
 	}
 	return nil
 }
 
-// notify tells the ordering system what became of an order and records the outcome on it.
+// logGeneration records what became of one period.
+func (this *JobsManager) logGeneration(generation services.RecurringGeneration) {
+	period := generation.PeriodDate.Format(time.DateOnly)
+	switch {
+	case generation.InvoiceId == "":
+		this.logger.Infof("%s: schedule '%s' period %s was already invoiced",
+			jobNameRecurring, generation.RecurringInvoiceId, period)
+	case generation.Detail != "":
+		this.logger.Warnf("%s: schedule '%s' period %s raised invoice '%s' (%s): %s",
+			jobNameRecurring, generation.RecurringInvoiceId, period, generation.InvoiceId,
+			generation.Status, generation.Detail)
+	default:
+		this.logger.Infof("%s: schedule '%s' period %s raised invoice '%s' (%s)",
+			jobNameRecurring, generation.RecurringInvoiceId, period, generation.InvoiceId,
+			generation.Status)
+	}
+}
+
+// notify tells the ordering system what became of an order.
 //
-// The outcome is recorded whether or not it succeeded: a failure is what the retry job looks for,
-// so losing it would mean the notification is never re-attempted.
+// The notification is recorded as a delivery before it is sent, so one that does not get through
+// is what the retry job picks up.
 func (this *JobsManager) notify(ctx corectx.Context, order services.StaleOrder, status string) {
 	amount, method, err := this.orders.SyncFactsFor(ctx, order.OrderId)
 	if err != nil {
@@ -209,27 +256,39 @@ func (this *JobsManager) notify(ctx corectx.Context, order services.StaleOrder,
 		return
 	}
 
-	outcome := this.sync.Sync(ctx, ResultSyncRequest{
+	payload, err := EncodeResultPayload(ResultSyncRequest{
 		ReturnUrl:     order.ReturnUrl,
 		OrderId:       order.OrderId,
 		Status:        status,
 		Amount:        amount,
 		PaymentMethod: method,
 	})
+	if err != nil {
+		this.logger.Errorf("paymentinvoice: order '%s' notification could not be encoded: %s",
+			order.OrderId, err.Error())
+		return
+	}
 
-	if err := this.orders.RecordSyncOutcome(ctx, order.Pk, services.SyncOutcome{
-		Status:   outcome.Status,
-		Attempts: outcome.Attempts,
-		Detail:   outcome.Detail,
-		At:       this.now(),
-	}); err != nil {
-		this.logger.Errorf("paymentinvoice: order '%s' sync outcome could not be recorded: %s",
+	attempt, err := this.deliveries.Notify(ctx, services.SyncNotice{
+		OrderPk:           order.Pk,
+		PaymentMethodCode: method,
+		Payload:           payload,
+	})
+	if err != nil {
+		this.logger.Errorf("paymentinvoice: order '%s' notification could not be recorded: %s",
 			order.OrderId, err.Error())
+		return
 	}
-	if !outcome.Succeeded() {
-		this.logger.Warnf("paymentinvoice: order '%s' could not be reported to its caller: %s",
-			order.OrderId, outcome.Detail)
+	this.logAttempt(*attempt)
+}
+
+// logAttempt reports a round of a delivery that did not get through.
+func (this *JobsManager) logAttempt(attempt services.SyncAttempt) {
+	if attempt.Outcome.Succeeded() {
+		return
 	}
+	this.logger.Warnf("paymentinvoice: order '%s' could not be reported to its caller (%s): %s",
+		attempt.OrderRef, attempt.Status, attempt.Outcome.Detail)
 }
 
 // verdictStatus turns a paid/not-paid verdict into the status the ordering system is told.
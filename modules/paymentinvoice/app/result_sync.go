@@ -5,12 +5,17 @@ package app
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
 )
 
 // The wire contract with the ordering system.
@@ -26,6 +31,23 @@ const (
 	syncStatusFailure = "failure"
 )
 
+// The signature a receiver verifies a notification by.
+//
+// The signature is HMAC-SHA256, keyed by the secret of the payment method the order was paid
+// through, over the timestamp header, a full stop and the raw body, hex-encoded behind "sha256=".
+// The timestamp is signed so a receiver can refuse a captured request replayed later; how late is
+// too late is the receiver's to decide. Both are headers rather than body keys because the body is
+// the legacy contract and a machine that does not verify must go on reading it unchanged.
+const (
+	HeaderSignature          = "X-Nikki-Signature"
+	HeaderSignatureTimestamp = "X-Nikki-Timestamp"
+
+	signatureScheme = "sha256="
+)
+
+// SecretSource returns the signing secret for a payment method's code, or "" when it has none.
+type SecretSource func(paymentMethodCode string) string
+
 // ResultSyncPayload is the body posted to an order's return_url.
 type ResultSyncPayload struct {
 	Type          string `json:"type"`
@@ -70,6 +92,7 @@ func (this ResultSyncOutcome) Succeeded() bool {
 type ResultSyncClient struct {
 	httpClient *http.Client
 	maxRetries int
+	secrets    SecretSource
 }
 
 // NewResultSyncClient builds the client with the deployment's timeout and retry bounds.
@@ -87,10 +110,35 @@ func NewResultSyncClient(timeout time.Duration, maxRetries int) *ResultSyncClien
 	return &ResultSyncClient{
 		httpClient: &http.Client{Timeout: timeout},
 		maxRetries: maxRetries,
+		secrets:    func(string) string { return "" },
+	}
+}
+
+// WithSigningSecrets has the client sign what it sends with the secret of each order's payment
+// method.
+//
+// A method with no secret configured sends unsigned, as every notification was before signing
+// existed, so a deployment can roll secrets out one ordering system at a time.
+func (this *ResultSyncClient) WithSigningSecrets(secrets SecretSource) *ResultSyncClient {
+	if secrets != nil {
+		this.secrets = secrets
 	}
+	return this
+}
+
+// EncodeResultPayload renders the body posted to an order's return_url.
+func EncodeResultPayload(req ResultSyncRequest) ([]byte, error) {
+	return json.Marshal(ResultSyncPayload{
+		Type:          syncTypePaymentResult,
+		Status:        StatusToLegacyEnum(req.Status),
+		OrderId:       req.OrderId,
+		Amount:        req.Amount,
+		PaymentMethod: req.PaymentMethod,
+		ResponseTime:  time.Now().UnixMilli(),
+	})
 }
 
-// Sync posts the payment result, retrying a failed attempt with linear backoff.
+// Sync posts the payment result, retrying a failed attempt with exponential backoff.
 //
 // A retry is safe because the notification is a statement of fact rather than a command: the same
 // result arriving twice tells the ordering system the same thing. That is also why a non-2xx is
@@ -102,21 +150,38 @@ func (this *ResultSyncClient) Sync(ctx context.Context, req ResultSyncRequest) R
 		return ResultSyncOutcome{Status: syncStatusSuccess}
 	}
 
-	body, err := json.Marshal(ResultSyncPayload{
-		Type:          syncTypePaymentResult,
-		Status:        StatusToLegacyEnum(req.Status),
-		OrderId:       req.OrderId,
-		Amount:        req.Amount,
-		PaymentMethod: req.PaymentMethod,
-		ResponseTime:  time.Now().UnixMilli(),
-	})
+	body, err := EncodeResultPayload(req)
 	if err != nil {
 		return ResultSyncOutcome{Status: syncStatusFailure, Attempts: 0, Detail: "payload could not be encoded"}
 	}
+	return this.send(ctx, req.ReturnUrl, req.PaymentMethod, body)
+}
+
+// Deliver posts a recorded notification. It implements services.SyncSender.
+func (this *ResultSyncClient) Deliver(ctx context.Context, dispatch services.SyncDispatch) services.SyncOutcome {
+	if dispatch.ReturnUrl == "" {
+		return services.SyncOutcome{Status: services.SyncStatusSuccess}
+	}
+	outcome := this.send(ctx, dispatch.ReturnUrl, dispatch.PaymentMethodCode, dispatch.Payload)
+	return services.SyncOutcome{
+		Status:   outcome.Status,
+		Attempts: outcome.Attempts,
+		Detail:   outcome.Detail,
+	}
+}
+
+// send makes one round of attempts at posting body, each signed afresh.
+//
+// The wait between attempts doubles from a second. The round is short because the sweeps wait on
+// it; a tenant down for longer than a round is the delivery schedule's to retry, not this loop's.
+func (this *ResultSyncClient) send(
+	ctx context.Context, url string, paymentMethodCode string, body []byte,
+) ResultSyncOutcome {
+	secret := this.secrets(paymentMethodCode)
 
 	var lastDetail string
 	for attempt := 1; attempt <= this.maxRetries; attempt++ {
-		detail, ok := this.postOnce(ctx, req.ReturnUrl, body)
+		detail, ok := this.postOnce(ctx, url, secret, body)
 		if ok {
 			return ResultSyncOutcome{Status: syncStatusSuccess, Attempts: attempt}
 		}
@@ -130,7 +195,7 @@ func (this *ResultSyncClient) Sync(ctx context.Context, req ResultSyncRequest) R
 		}
 		if attempt < this.maxRetries {
 			select {
-			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-time.After(time.Duration(1<<(attempt-1)) * time.Second):
 			case <-ctx.Done():
 			}
 		}
@@ -144,7 +209,9 @@ func (this *ResultSyncClient) Sync(ctx context.Context, req ResultSyncRequest) R
 }
 
 // postOnce makes one attempt and reports whether the tenant accepted it.
-func (this *ResultSyncClient) postOnce(ctx context.Context, url string, body []byte) (string, bool) {
+func (this *ResultSyncClient) postOnce(
+	ctx context.Context, url string, secret string, body []byte,
+) (string, bool) {
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		// A URL that cannot be turned into a request will not become one on a retry, but it is
@@ -152,6 +219,13 @@ func (this *ResultSyncClient) postOnce(ctx context.Context, url string, body []b
 		return "the return_url is not a usable address", false
 	}
 	request.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		// Signed per attempt rather than once per round, so every request carries the time it was
+		// actually sent.
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		request.Header.Set(HeaderSignatureTimestamp, timestamp)
+		request.Header.Set(HeaderSignature, SignPayload(secret, timestamp, body))
+	}
 
 	response, err := this.httpClient.Do(request)
 	if err != nil {
@@ -169,6 +243,16 @@ func (this *ResultSyncClient) postOnce(ctx context.Context, url string, body []b
 	return "the ordering system answered " + response.Status, false
 }
 
+// SignPayload computes the signature header for a body sent at timestamp. It is exported as the
+// reference a receiver's verification can be checked against.
+func SignPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureScheme + hex.EncodeToString(mac.Sum(nil))
+}
+
 // StatusToLegacyEnum renders an order status the way the ordering system spells it.
 //
 // This module stores snake_case, the service it replaced sent camelCase, and the machines reading
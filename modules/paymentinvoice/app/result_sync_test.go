@@ -2,10 +2,14 @@ package app
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -14,6 +18,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
 )
 
 // The notification this client sends is how a vending machine learns it may release the goods. A
@@ -184,3 +189,85 @@ func TestDegenerateConfigurationFallsBackToSafeValues(t *testing.T) {
 	assert.Positive(t, client.httpClient.Timeout, "a zero timeout would wait forever")
 	assert.GreaterOrEqual(t, client.maxRetries, 1, "zero retries would send nothing at all")
 }
+
+// A receiver verifies a notification by recomputing the signature over the timestamp it was sent
+// with and the body it received. Anything else in the signed string would make a correct receiver
+// reject every genuine notification.
+func TestANotificationIsSignedWithItsMethodsSecret(t *testing.T) {
+	var signature, timestamp string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(HeaderSignature)
+		timestamp = r.Header.Get(HeaderSignatureTimestamp)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewResultSyncClient(2*time.Second, 1).WithSigningSecrets(func(code string) string {
+		if code == "momo" {
+			return "s3cret"
+		}
+		return ""
+	})
+	outcome := client.Sync(context.Background(), ResultSyncRequest{
+		ReturnUrl:     server.URL,
+		OrderId:       "ORDER1",
+		Status:        models.OrderStatusPaymentSuccess,
+		PaymentMethod: "momo",
+	})
+	require.True(t, outcome.Succeeded())
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	require.NoError(t, err, "the timestamp is Unix seconds")
+	assert.InDelta(t, time.Now().Unix(), sentAt, 5)
+}
+
+// Signing is rolled out one ordering system at a time. A method with no secret must go on sending
+// exactly what it sent before, rather than a signature made with an empty key that proves nothing.
+func TestAMethodWithoutASecretSendsUnsigned(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewResultSyncClient(2*time.Second, 1).WithSigningSecrets(func(string) string { return "" })
+	outcome := client.Sync(context.Background(), ResultSyncRequest{
+		ReturnUrl:     server.URL,
+		OrderId:       "ORDER1",
+		Status:        models.OrderStatusPaymentSuccess,
+		PaymentMethod: "vietqr",
+	})
+
+	require.True(t, outcome.Succeeded())
+	assert.Empty(t, header.Get(HeaderSignature))
+	assert.Empty(t, header.Get(HeaderSignatureTimestamp))
+}
+
+// A recorded delivery is sent byte for byte. Re-encoding it would change responseTime, and a
+// replay would then say something the original notification did not.
+func TestADeliveryIsSentAsRecorded(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	recorded := []byte(`{"type":"paymentResult","status":"paymentSuccess","orderId":"ORDER1","amount":1,"paymentMethod":"momo","responseTime":1}`)
+	outcome := NewResultSyncClient(2*time.Second, 1).Deliver(context.Background(), services.SyncDispatch{
+		ReturnUrl: server.URL,
+		Payload:   recorded,
+	})
+
+	assert.True(t, outcome.Succeeded())
+	assert.Equal(t, 1, outcome.Attempts)
+	assert.Equal(t, recorded, body)
+}
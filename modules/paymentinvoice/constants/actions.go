@@ -20,4 +20,28 @@ const (
 	// ActionIssue closes an invoice draft: it recomputes the totals, assigns the number and
 	// stamps the issue date.
 	ActionIssue = "issue"
+
+	// ActionAllocatePayment counts a completed payment, or a receipt recorded by hand, against an
+	// issued invoice.
+	ActionAllocatePayment = "allocate_payment"
+
+	// ActionCredit issues a credit note against an invoice, optionally refunding it through the
+	// gateway the invoice's order was paid by.
+	ActionCredit = "credit"
+
+	// ActionRelease stops what is left of a payment allocation from counting against its invoice.
+	ActionRelease = "release"
+
+	// ActionDownloadPdf renders an invoice as a PDF. It is the one action here that is not its own
+	// permission code: it checks "read", because printing an invoice grants nothing the caller
+	// could not already read.
+	ActionDownloadPdf = "download_pdf"
+
+	// ActionReplay sends a notification to the ordering system again, whatever became of it
+	// before. It is how a dead-lettered delivery is sent once its tenant is back.
+	ActionReplay = "replay"
+
+	// ActionImportSettlement reconciles a gateway's settlement report for one day against the
+	// transactions recorded for it, replacing any earlier reconciliation of that day.
+	ActionImportSettlement = "import_settlement"
 )
@@ -1,6 +1,8 @@
 package constants
 
 import (
+	"strings"
+
 	core "github.com/sky-as-code/nikki-erp/modules/core/constants"
 )
 
@@ -57,6 +59,22 @@ const (
 	VietQrBankCode   core.ConfigName = "PAYMENTINVOICE.VIETQR.BANK_CODE"
 )
 
+// The sandbox gateway, which collects no money. It is for development and tests only: enabling it
+// where real customers pay lets anyone settle an order for free.
+const (
+	SandboxEnabled core.ConfigName = "PAYMENTINVOICE.SANDBOX.ENABLED"
+
+	// SandboxCallbackUrl is this deployment's own sandbox webhook, which the sandbox posts its
+	// verdicts to.
+	SandboxCallbackUrl core.ConfigName = "PAYMENTINVOICE.SANDBOX.CALLBACK_URL"
+
+	// SandboxPayPageUrl is the base of the page a payer pays or declines a pending order on.
+	SandboxPayPageUrl core.ConfigName = "PAYMENTINVOICE.SANDBOX.PAY_PAGE_URL"
+
+	SandboxSecretKey         core.ConfigName = "PAYMENTINVOICE.SANDBOX.SECRET_KEY"
+	SandboxCallbackDelaySecs core.ConfigName = "PAYMENTINVOICE.SANDBOX.CALLBACK_DELAY_SECS"
+)
+
 const (
 	// OrderExpireAfterMins is how long an order may sit unpaid before the watchdog asks the
 	// gateway for a verdict and, failing one, expires it.
@@ -68,6 +86,30 @@ const (
 	// SyncTimeoutSecs bounds one attempt to notify the ordering system of a payment result.
 	SyncTimeoutSecs core.ConfigName = "PAYMENTINVOICE.SYNC.TIMEOUT_SECS"
 
-	// SyncMaxRetries bounds how many times that notification is re-attempted.
+	// SyncMaxRetries bounds how many times that notification is re-attempted within one round.
 	SyncMaxRetries core.ConfigName = "PAYMENTINVOICE.SYNC.MAX_RETRIES"
+
+	// SyncMaxAttempts bounds the attempts across every round, after which a delivery is
+	// dead-lettered and only a replay sends it again.
+	SyncMaxAttempts core.ConfigName = "PAYMENTINVOICE.SYNC.MAX_ATTEMPTS"
+
+	// SyncBackoffBaseSecs is the wait before the round after a first failed attempt. It doubles
+	// with every attempt after, up to SyncBackoffCapSecs.
+	SyncBackoffBaseSecs core.ConfigName = "PAYMENTINVOICE.SYNC.BACKOFF_BASE_SECS"
+	SyncBackoffCapSecs  core.ConfigName = "PAYMENTINVOICE.SYNC.BACKOFF_CAP_SECS"
 )
+
+// syncSecretPrefix is the key under which each payment method's signing secret is configured.
+//
+// The secrets are credentials, so they are configured like the gateways' — through the {KEY}_FILE
+// secret-file convention in a deployed environment — rather than stored on the payment method,
+// whose table is readable through the API.
+const syncSecretPrefix = "PAYMENTINVOICE.SYNC.SECRET."
+
+// SyncSecretFor is the configuration key of one payment method's signing secret: the method's
+// code upper-cased with every hyphen made an underscore, so it also reads as an environment
+// variable name. Method "momo-qr" is signed with PAYMENTINVOICE.SYNC.SECRET.MOMO_QR.
+func SyncSecretFor(paymentMethodCode string) core.ConfigName {
+	return core.ConfigName(syncSecretPrefix +
+		strings.ToUpper(strings.ReplaceAll(paymentMethodCode, "-", "_")))
+}
@@ -10,4 +10,8 @@ const (
 	ResourceTransaction = "paymentinvoice_transaction"
 	ResourceInvoice     = "paymentinvoice_invoice"
 	ResourceInvoiceLine = "paymentinvoice_invoice_line"
+
+	ResourcePaymentAllocation = "paymentinvoice_payment_allocation"
+	ResourceCreditNote        = "paymentinvoice_credit_note"
+	ResourceCreditNoteLine    = "paymentinvoice_credit_note_line"
 )
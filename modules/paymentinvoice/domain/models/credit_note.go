@@ -0,0 +1,151 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	CreditNoteSchemaName = "paymentinvoice_credit_note"
+
+	CreditNoteFieldId             = basemodel.FieldId
+	CreditNoteFieldNumber         = "number"
+	CreditNoteFieldInvoiceId      = "invoice_id"
+	CreditNoteFieldCurrencyId     = "currency_id"
+	CreditNoteFieldSubtotalAmount = "subtotal_amount"
+	CreditNoteFieldTaxAmount      = "tax_amount"
+	CreditNoteFieldTotalAmount    = "total_amount"
+	CreditNoteFieldIssuedAt       = "issued_at"
+	CreditNoteFieldReason         = "reason"
+	CreditNoteFieldRefundStatus   = "refund_status"
+	CreditNoteFieldRefundAmount   = "refund_amount"
+	CreditNoteFieldOrgId          = "org_id"
+)
+
+const (
+	CreditNoteRefundNone     = "none"
+	CreditNoteRefundRefunded = "refunded"
+	CreditNoteRefundFailed   = "refund_failed"
+)
+
+//go:embed credit_note.json
+var creditNoteSchemaJson string
+
+func CreditNoteSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(creditNoteSchemaJson)
+}
+
+// CreditNote is an accounting document that takes part of an issued invoice back.
+// Table: paymentinvoice_credit_notes.
+//
+// A correction to an issued invoice is a document of its own rather than an edit to the invoice:
+// the invoice stays as it was issued, and the note says what was taken off, when and why. The
+// invoice's amount_credited is the sum of its notes, and reduces what it still owes.
+//
+// A note has no draft. It is numbered, totalled and applied to its invoice in one transaction, so
+// its number is only ever taken by a note that exists.
+type CreditNote struct {
+	basemodel.DynamicModelBase
+}
+
+func NewCreditNote() *CreditNote {
+	return &CreditNote{basemodel.NewDynamicModel()}
+}
+
+func NewCreditNoteFrom(src dmodel.DynamicFields) *CreditNote {
+	return &CreditNote{basemodel.NewDynamicModel(src)}
+}
+
+func (this CreditNote) GetNumber() *string {
+	return this.GetFieldData().GetString(CreditNoteFieldNumber)
+}
+
+func (this *CreditNote) SetNumber(v *string) {
+	this.GetFieldData().SetString(CreditNoteFieldNumber, v)
+}
+
+func (this CreditNote) GetInvoiceId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteFieldInvoiceId)
+}
+
+func (this *CreditNote) SetInvoiceId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteFieldInvoiceId, v)
+}
+
+func (this CreditNote) GetCurrencyId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteFieldCurrencyId)
+}
+
+func (this *CreditNote) SetCurrencyId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteFieldCurrencyId, v)
+}
+
+func (this CreditNote) GetSubtotalAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteFieldSubtotalAmount)
+}
+
+func (this *CreditNote) SetSubtotalAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteFieldSubtotalAmount, v)
+}
+
+func (this CreditNote) GetTaxAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteFieldTaxAmount)
+}
+
+func (this *CreditNote) SetTaxAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteFieldTaxAmount, v)
+}
+
+func (this CreditNote) GetTotalAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteFieldTotalAmount)
+}
+
+func (this *CreditNote) SetTotalAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteFieldTotalAmount, v)
+}
+
+func (this CreditNote) GetIssuedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(CreditNoteFieldIssuedAt)
+}
+
+func (this *CreditNote) SetIssuedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(CreditNoteFieldIssuedAt, v)
+}
+
+func (this CreditNote) GetReason() *string {
+	return this.GetFieldData().GetString(CreditNoteFieldReason)
+}
+
+func (this *CreditNote) SetReason(v *string) {
+	this.GetFieldData().SetString(CreditNoteFieldReason, v)
+}
+
+func (this CreditNote) GetRefundStatus() *string {
+	return this.GetFieldData().GetString(CreditNoteFieldRefundStatus)
+}
+
+func (this *CreditNote) SetRefundStatus(v *string) {
+	this.GetFieldData().SetString(CreditNoteFieldRefundStatus, v)
+}
+
+func (this CreditNote) GetRefundAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteFieldRefundAmount)
+}
+
+func (this *CreditNote) SetRefundAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteFieldRefundAmount, v)
+}
+
+func (this CreditNote) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteFieldOrgId)
+}
+
+func (this *CreditNote) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteFieldOrgId, v)
+}
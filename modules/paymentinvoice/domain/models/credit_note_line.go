@@ -0,0 +1,124 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	CreditNoteLineSchemaName = "paymentinvoice_credit_note_line"
+
+	CreditNoteLineFieldId             = basemodel.FieldId
+	CreditNoteLineFieldCreditNoteId   = "credit_note_id"
+	CreditNoteLineFieldInvoiceId      = "invoice_id"
+	CreditNoteLineFieldInvoiceLineId  = "invoice_line_id"
+	CreditNoteLineFieldDescription    = "description"
+	CreditNoteLineFieldQuantity       = "quantity"
+	CreditNoteLineFieldUnitPrice      = "unit_price"
+	CreditNoteLineFieldTaxRatePercent = "tax_rate_percent"
+	CreditNoteLineFieldAmount         = "amount"
+	CreditNoteLineFieldOrgId          = "org_id"
+)
+
+//go:embed credit_note_line.json
+var creditNoteLineSchemaJson string
+
+func CreditNoteLineSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(creditNoteLineSchemaJson)
+}
+
+// CreditNoteLine is one invoice line credited, in whole or in part.
+// Table: paymentinvoice_credit_note_lines.
+//
+// The description, price and tax rate are copied from the invoice line rather than referenced, for
+// the reason an invoice copies its partner: the note must keep saying what it said if the invoice
+// line is ever corrected.
+type CreditNoteLine struct {
+	basemodel.DynamicModelBase
+}
+
+func NewCreditNoteLine() *CreditNoteLine {
+	return &CreditNoteLine{basemodel.NewDynamicModel()}
+}
+
+func NewCreditNoteLineFrom(src dmodel.DynamicFields) *CreditNoteLine {
+	return &CreditNoteLine{basemodel.NewDynamicModel(src)}
+}
+
+func (this CreditNoteLine) GetCreditNoteId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteLineFieldCreditNoteId)
+}
+
+func (this *CreditNoteLine) SetCreditNoteId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteLineFieldCreditNoteId, v)
+}
+
+func (this CreditNoteLine) GetInvoiceId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteLineFieldInvoiceId)
+}
+
+func (this *CreditNoteLine) SetInvoiceId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteLineFieldInvoiceId, v)
+}
+
+func (this CreditNoteLine) GetInvoiceLineId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteLineFieldInvoiceLineId)
+}
+
+func (this *CreditNoteLine) SetInvoiceLineId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteLineFieldInvoiceLineId, v)
+}
+
+func (this CreditNoteLine) GetDescription() *string {
+	return this.GetFieldData().GetString(CreditNoteLineFieldDescription)
+}
+
+func (this *CreditNoteLine) SetDescription(v *string) {
+	this.GetFieldData().SetString(CreditNoteLineFieldDescription, v)
+}
+
+func (this CreditNoteLine) GetQuantity() *int32 {
+	return this.GetFieldData().GetInt32(CreditNoteLineFieldQuantity)
+}
+
+func (this *CreditNoteLine) SetQuantity(v *int32) {
+	this.GetFieldData().SetInt32(CreditNoteLineFieldQuantity, v)
+}
+
+func (this CreditNoteLine) GetUnitPrice() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteLineFieldUnitPrice)
+}
+
+func (this *CreditNoteLine) SetUnitPrice(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteLineFieldUnitPrice, v)
+}
+
+func (this CreditNoteLine) GetTaxRatePercent() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteLineFieldTaxRatePercent)
+}
+
+func (this *CreditNoteLine) SetTaxRatePercent(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteLineFieldTaxRatePercent, v)
+}
+
+func (this CreditNoteLine) GetAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(CreditNoteLineFieldAmount)
+}
+
+func (this *CreditNoteLine) SetAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(CreditNoteLineFieldAmount, v)
+}
+
+func (this CreditNoteLine) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(CreditNoteLineFieldOrgId)
+}
+
+func (this *CreditNoteLine) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(CreditNoteLineFieldOrgId, v)
+}
@@ -24,7 +24,11 @@ const (
 	InvoiceFieldCurrencyId     = "currency_id"
 	InvoiceFieldSubtotalAmount = "subtotal_amount"
 	InvoiceFieldTaxAmount      = "tax_amount"
+	InvoiceFieldTaxBreakdown   = "tax_breakdown"
 	InvoiceFieldTotalAmount    = "total_amount"
+	InvoiceFieldAmountPaid     = "amount_paid"
+	InvoiceFieldAmountCredited = "amount_credited"
+	InvoiceFieldAmountDue      = "amount_due"
 	InvoiceFieldIssuedAt       = "issued_at"
 	InvoiceFieldNote           = "note"
 	InvoiceFieldOrgId          = "org_id"
@@ -52,6 +56,12 @@ func InvoiceSchemaBuilder() *dmodel.ModelSchemaBuilder {
 // stay unique. The number, the totals and issued_at are all assigned together by the issue
 // action, which is why each is no_update.
 //
+// amount_paid and amount_due are kept by the payment allocations rather than computed on read, so
+// a listing of what is outstanding is a filter on one column. Issue sets amount_due to the total;
+// each allocation, release, refund and credit note afterwards moves them together, and an invoice
+// whose amount_due reaches zero becomes paid. amount_credited is what the credit notes against
+// the invoice have taken off its total.
+//
 // The partner's name, tax code and address are copied onto the invoice rather than referenced, so
 // that a later change to a customer record cannot alter a document already issued.
 type Invoice struct {
@@ -146,6 +156,30 @@ func (this *Invoice) SetTotalAmount(v *decimal.Decimal) {
 	this.GetFieldData().SetDecimal(InvoiceFieldTotalAmount, v)
 }
 
+func (this Invoice) GetAmountPaid() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(InvoiceFieldAmountPaid)
+}
+
+func (this *Invoice) SetAmountPaid(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(InvoiceFieldAmountPaid, v)
+}
+
+func (this Invoice) GetAmountCredited() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(InvoiceFieldAmountCredited)
+}
+
+func (this *Invoice) SetAmountCredited(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(InvoiceFieldAmountCredited, v)
+}
+
+func (this Invoice) GetAmountDue() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(InvoiceFieldAmountDue)
+}
+
+func (this *Invoice) SetAmountDue(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(InvoiceFieldAmountDue, v)
+}
+
 func (this Invoice) GetIssuedAt() *model.ModelDateTime {
 	return this.GetFieldData().GetModelDateTime(InvoiceFieldIssuedAt)
 }
@@ -154,6 +188,20 @@ func (this *Invoice) SetIssuedAt(v *model.ModelDateTime) {
 	this.GetFieldData().SetModelDateTime(InvoiceFieldIssuedAt, v)
 }
 
+// GetTaxBreakdown returns what each named tax came to at issue. Like an order's sync logs, the
+// entries live under a key inside the jsonmap.
+func (this Invoice) GetTaxBreakdown() map[string]any {
+	breakdown, ok := this.GetFieldData().GetAny(InvoiceFieldTaxBreakdown).(map[string]any)
+	if !ok {
+		return nil
+	}
+	return breakdown
+}
+
+func (this *Invoice) SetTaxBreakdown(v map[string]any) {
+	this.GetFieldData().SetAny(InvoiceFieldTaxBreakdown, v)
+}
+
 func (this Invoice) GetNote() *string {
 	return this.GetFieldData().GetString(InvoiceFieldNote)
 }
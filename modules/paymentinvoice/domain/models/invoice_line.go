@@ -20,7 +20,9 @@ const (
 	InvoiceLineFieldQuantity       = "quantity"
 	InvoiceLineFieldUnitPrice      = "unit_price"
 	InvoiceLineFieldTaxRatePercent = "tax_rate_percent"
+	InvoiceLineFieldTaxIds         = "tax_ids"
 	InvoiceLineFieldAmount         = "amount"
+	InvoiceLineFieldTaxAmount      = "tax_amount"
 	InvoiceLineFieldOrgId          = "org_id"
 )
 
@@ -102,6 +104,36 @@ func (this *InvoiceLine) SetAmount(v *decimal.Decimal) {
 	this.GetFieldData().SetDecimal(InvoiceLineFieldAmount, v)
 }
 
+// GetTaxIds returns the taxes named on the line. A request body decodes the list as []any and a
+// row read back as []string, so both are accepted; an entry that is not a string is skipped.
+func (this InvoiceLine) GetTaxIds() []model.Id {
+	switch typed := this.GetFieldData().GetAny(InvoiceLineFieldTaxIds).(type) {
+	case []string:
+		return typed
+	case []any:
+		ids := make([]model.Id, 0, len(typed))
+		for _, item := range typed {
+			if id, ok := item.(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	return nil
+}
+
+func (this *InvoiceLine) SetTaxIds(v []model.Id) {
+	this.GetFieldData().SetStrings(InvoiceLineFieldTaxIds, v)
+}
+
+func (this InvoiceLine) GetTaxAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(InvoiceLineFieldTaxAmount)
+}
+
+func (this *InvoiceLine) SetTaxAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(InvoiceLineFieldTaxAmount, v)
+}
+
 func (this InvoiceLine) GetOrgId() *model.Id {
 	return this.GetFieldData().GetModelId(InvoiceLineFieldOrgId)
 }
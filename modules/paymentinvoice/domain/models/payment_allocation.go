@@ -0,0 +1,144 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	PaymentAllocationSchemaName = "paymentinvoice_payment_allocation"
+
+	PaymentAllocationFieldId             = basemodel.FieldId
+	PaymentAllocationFieldInvoiceId      = "invoice_id"
+	PaymentAllocationFieldTransactionId  = "transaction_id"
+	PaymentAllocationFieldSource         = "source"
+	PaymentAllocationFieldReference      = "reference"
+	PaymentAllocationFieldAmount         = "amount"
+	PaymentAllocationFieldReleasedAmount = "released_amount"
+	PaymentAllocationFieldReceivedAt     = "received_at"
+	PaymentAllocationFieldReleasedAt     = "released_at"
+	PaymentAllocationFieldNote           = "note"
+	PaymentAllocationFieldOrgId          = "org_id"
+)
+
+const (
+	PaymentAllocationSourceGateway      = "gateway"
+	PaymentAllocationSourceBankTransfer = "bank_transfer"
+	PaymentAllocationSourceCash         = "cash"
+)
+
+//go:embed payment_allocation.json
+var paymentAllocationSchemaJson string
+
+func PaymentAllocationSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(paymentAllocationSchemaJson)
+}
+
+// PaymentAllocation records that some money settled some part of an invoice.
+// Table: paymentinvoice_payment_allocations.
+//
+// The money is either a completed gateway payment, named by transaction_id, or a receipt a person
+// recorded by hand — a bank transfer or cash — which no transaction evidences. One payment may be
+// split across several invoices and one invoice settled by several payments, which is why this is
+// a row of its own rather than a column on either side.
+//
+// An allocation is never deleted or reduced. Money that stops counting against the invoice, because
+// the payment was refunded or the allocation was made in error, is recorded in released_amount, so
+// the history of what settled an invoice survives the correction.
+type PaymentAllocation struct {
+	basemodel.DynamicModelBase
+}
+
+func NewPaymentAllocation() *PaymentAllocation {
+	return &PaymentAllocation{basemodel.NewDynamicModel()}
+}
+
+func NewPaymentAllocationFrom(src dmodel.DynamicFields) *PaymentAllocation {
+	return &PaymentAllocation{basemodel.NewDynamicModel(src)}
+}
+
+func (this PaymentAllocation) GetInvoiceId() *model.Id {
+	return this.GetFieldData().GetModelId(PaymentAllocationFieldInvoiceId)
+}
+
+func (this *PaymentAllocation) SetInvoiceId(v *model.Id) {
+	this.GetFieldData().SetModelId(PaymentAllocationFieldInvoiceId, v)
+}
+
+func (this PaymentAllocation) GetTransactionId() *model.Id {
+	return this.GetFieldData().GetModelId(PaymentAllocationFieldTransactionId)
+}
+
+func (this *PaymentAllocation) SetTransactionId(v *model.Id) {
+	this.GetFieldData().SetModelId(PaymentAllocationFieldTransactionId, v)
+}
+
+func (this PaymentAllocation) GetSource() *string {
+	return this.GetFieldData().GetString(PaymentAllocationFieldSource)
+}
+
+func (this *PaymentAllocation) SetSource(v *string) {
+	this.GetFieldData().SetString(PaymentAllocationFieldSource, v)
+}
+
+func (this PaymentAllocation) GetReference() *string {
+	return this.GetFieldData().GetString(PaymentAllocationFieldReference)
+}
+
+func (this *PaymentAllocation) SetReference(v *string) {
+	this.GetFieldData().SetString(PaymentAllocationFieldReference, v)
+}
+
+func (this PaymentAllocation) GetAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(PaymentAllocationFieldAmount)
+}
+
+func (this *PaymentAllocation) SetAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(PaymentAllocationFieldAmount, v)
+}
+
+func (this PaymentAllocation) GetReleasedAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(PaymentAllocationFieldReleasedAmount)
+}
+
+func (this *PaymentAllocation) SetReleasedAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(PaymentAllocationFieldReleasedAmount, v)
+}
+
+func (this PaymentAllocation) GetReceivedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(PaymentAllocationFieldReceivedAt)
+}
+
+func (this *PaymentAllocation) SetReceivedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(PaymentAllocationFieldReceivedAt, v)
+}
+
+func (this PaymentAllocation) GetReleasedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(PaymentAllocationFieldReleasedAt)
+}
+
+func (this *PaymentAllocation) SetReleasedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(PaymentAllocationFieldReleasedAt, v)
+}
+
+func (this PaymentAllocation) GetNote() *string {
+	return this.GetFieldData().GetString(PaymentAllocationFieldNote)
+}
+
+func (this *PaymentAllocation) SetNote(v *string) {
+	this.GetFieldData().SetString(PaymentAllocationFieldNote, v)
+}
+
+func (this PaymentAllocation) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(PaymentAllocationFieldOrgId)
+}
+
+func (this *PaymentAllocation) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(PaymentAllocationFieldOrgId, v)
+}
@@ -36,6 +36,10 @@ const (
 	AdapterCodeMomo   = "momo"
 	AdapterCodeVietQr = "vietqr"
 	AdapterCodeMpos   = "mpos"
+
+	// AdapterCodeSandbox collects nothing; it exists so the payment flow can be run without a
+	// merchant account. A row naming it on a production deployment is a free checkout.
+	AdapterCodeSandbox = "sandbox"
 )
 
 //go:embed payment_method.json
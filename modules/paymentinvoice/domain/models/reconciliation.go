@@ -0,0 +1,155 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	ReconciliationSchemaName = "paymentinvoice_reconciliation"
+
+	ReconciliationFieldId              = basemodel.FieldId
+	ReconciliationFieldPaymentMethodId = "payment_method_id"
+	ReconciliationFieldSettlementDate  = "settlement_date"
+	ReconciliationFieldFileName        = "file_name"
+	ReconciliationFieldStatus          = "status"
+	ReconciliationFieldLineCount       = "line_count"
+	ReconciliationFieldMatchedCount    = "matched_count"
+	ReconciliationFieldMissingCount    = "missing_count"
+	ReconciliationFieldExtraCount      = "extra_count"
+	ReconciliationFieldMismatchCount   = "mismatch_count"
+	ReconciliationFieldSettledTotal    = "settled_total"
+	ReconciliationFieldExpectedTotal   = "expected_total"
+	ReconciliationFieldOrgId           = "org_id"
+)
+
+const (
+	ReconciliationStatusBalanced      = "balanced"
+	ReconciliationStatusDiscrepancies = "discrepancies"
+)
+
+//go:embed reconciliation.json
+var reconciliationSchemaJson string
+
+func ReconciliationSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(reconciliationSchemaJson)
+}
+
+// Reconciliation compares one gateway settlement report with this module's transactions for the
+// same payment method and day. Table: paymentinvoice_reconciliations.
+//
+// It is written whole by the import and never edited: a corrected report is imported again and
+// replaces it, so the counts always describe the items filed under it.
+type Reconciliation struct {
+	basemodel.DynamicModelBase
+}
+
+func NewReconciliation() *Reconciliation {
+	return &Reconciliation{basemodel.NewDynamicModel()}
+}
+
+func NewReconciliationFrom(src dmodel.DynamicFields) *Reconciliation {
+	return &Reconciliation{basemodel.NewDynamicModel(src)}
+}
+
+func (this Reconciliation) GetPaymentMethodId() *model.Id {
+	return this.GetFieldData().GetModelId(ReconciliationFieldPaymentMethodId)
+}
+
+func (this *Reconciliation) SetPaymentMethodId(v *model.Id) {
+	this.GetFieldData().SetModelId(ReconciliationFieldPaymentMethodId, v)
+}
+
+func (this Reconciliation) GetSettlementDate() *model.ModelDate {
+	return this.GetFieldData().GetModelDate(ReconciliationFieldSettlementDate)
+}
+
+func (this *Reconciliation) SetSettlementDate(v *model.ModelDate) {
+	this.GetFieldData().SetModelDate(ReconciliationFieldSettlementDate, v)
+}
+
+func (this Reconciliation) GetFileName() *string {
+	return this.GetFieldData().GetString(ReconciliationFieldFileName)
+}
+
+func (this *Reconciliation) SetFileName(v *string) {
+	this.GetFieldData().SetString(ReconciliationFieldFileName, v)
+}
+
+func (this Reconciliation) GetStatus() *string {
+	return this.GetFieldData().GetString(ReconciliationFieldStatus)
+}
+
+func (this *Reconciliation) SetStatus(v *string) {
+	this.GetFieldData().SetString(ReconciliationFieldStatus, v)
+}
+
+func (this Reconciliation) GetLineCount() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationFieldLineCount)
+}
+
+func (this *Reconciliation) SetLineCount(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationFieldLineCount, v)
+}
+
+func (this Reconciliation) GetMatchedCount() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationFieldMatchedCount)
+}
+
+func (this *Reconciliation) SetMatchedCount(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationFieldMatchedCount, v)
+}
+
+func (this Reconciliation) GetMissingCount() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationFieldMissingCount)
+}
+
+func (this *Reconciliation) SetMissingCount(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationFieldMissingCount, v)
+}
+
+func (this Reconciliation) GetExtraCount() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationFieldExtraCount)
+}
+
+func (this *Reconciliation) SetExtraCount(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationFieldExtraCount, v)
+}
+
+func (this Reconciliation) GetMismatchCount() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationFieldMismatchCount)
+}
+
+func (this *Reconciliation) SetMismatchCount(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationFieldMismatchCount, v)
+}
+
+func (this Reconciliation) GetSettledTotal() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(ReconciliationFieldSettledTotal)
+}
+
+func (this *Reconciliation) SetSettledTotal(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(ReconciliationFieldSettledTotal, v)
+}
+
+func (this Reconciliation) GetExpectedTotal() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(ReconciliationFieldExpectedTotal)
+}
+
+func (this *Reconciliation) SetExpectedTotal(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(ReconciliationFieldExpectedTotal, v)
+}
+
+func (this Reconciliation) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(ReconciliationFieldOrgId)
+}
+
+func (this *Reconciliation) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(ReconciliationFieldOrgId, v)
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	ReconciliationItemSchemaName = "paymentinvoice_reconciliation_item"
+
+	ReconciliationItemFieldId               = basemodel.FieldId
+	ReconciliationItemFieldReconciliationId = "reconciliation_id"
+	ReconciliationItemFieldOutcome          = "outcome"
+	ReconciliationItemFieldTransactionType  = "transaction_type"
+	ReconciliationItemFieldTransactionId    = "transaction_id"
+	ReconciliationItemFieldOrderCode        = "order_code"
+	ReconciliationItemFieldRefTransactionId = "ref_transaction_id"
+	ReconciliationItemFieldExpectedAmount   = "expected_amount"
+	ReconciliationItemFieldSettledAmount    = "settled_amount"
+	ReconciliationItemFieldLineNo           = "line_no"
+	ReconciliationItemFieldOrgId            = "org_id"
+)
+
+const (
+	ReconciliationOutcomeMatched        = "matched"
+	ReconciliationOutcomeMissing        = "missing"
+	ReconciliationOutcomeExtra          = "extra"
+	ReconciliationOutcomeAmountMismatch = "amount_mismatch"
+)
+
+//go:embed reconciliation_item.json
+var reconciliationItemSchemaJson string
+
+func ReconciliationItemSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(reconciliationItemSchemaJson)
+}
+
+// ReconciliationItem is one report line, one transaction, or one of each, with what comparing them
+// found. Table: paymentinvoice_reconciliation_items.
+type ReconciliationItem struct {
+	basemodel.DynamicModelBase
+}
+
+func NewReconciliationItem() *ReconciliationItem {
+	return &ReconciliationItem{basemodel.NewDynamicModel()}
+}
+
+func NewReconciliationItemFrom(src dmodel.DynamicFields) *ReconciliationItem {
+	return &ReconciliationItem{basemodel.NewDynamicModel(src)}
+}
+
+func (this ReconciliationItem) GetReconciliationId() *model.Id {
+	return this.GetFieldData().GetModelId(ReconciliationItemFieldReconciliationId)
+}
+
+func (this *ReconciliationItem) SetReconciliationId(v *model.Id) {
+	this.GetFieldData().SetModelId(ReconciliationItemFieldReconciliationId, v)
+}
+
+func (this ReconciliationItem) GetOutcome() *string {
+	return this.GetFieldData().GetString(ReconciliationItemFieldOutcome)
+}
+
+func (this *ReconciliationItem) SetOutcome(v *string) {
+	this.GetFieldData().SetString(ReconciliationItemFieldOutcome, v)
+}
+
+func (this ReconciliationItem) GetTransactionType() *string {
+	return this.GetFieldData().GetString(ReconciliationItemFieldTransactionType)
+}
+
+func (this *ReconciliationItem) SetTransactionType(v *string) {
+	this.GetFieldData().SetString(ReconciliationItemFieldTransactionType, v)
+}
+
+func (this ReconciliationItem) GetTransactionId() *model.Id {
+	return this.GetFieldData().GetModelId(ReconciliationItemFieldTransactionId)
+}
+
+func (this *ReconciliationItem) SetTransactionId(v *model.Id) {
+	this.GetFieldData().SetModelId(ReconciliationItemFieldTransactionId, v)
+}
+
+func (this ReconciliationItem) GetOrderCode() *string {
+	return this.GetFieldData().GetString(ReconciliationItemFieldOrderCode)
+}
+
+func (this *ReconciliationItem) SetOrderCode(v *string) {
+	this.GetFieldData().SetString(ReconciliationItemFieldOrderCode, v)
+}
+
+func (this ReconciliationItem) GetRefTransactionId() *string {
+	return this.GetFieldData().GetString(ReconciliationItemFieldRefTransactionId)
+}
+
+func (this *ReconciliationItem) SetRefTransactionId(v *string) {
+	this.GetFieldData().SetString(ReconciliationItemFieldRefTransactionId, v)
+}
+
+func (this ReconciliationItem) GetExpectedAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(ReconciliationItemFieldExpectedAmount)
+}
+
+func (this *ReconciliationItem) SetExpectedAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(ReconciliationItemFieldExpectedAmount, v)
+}
+
+func (this ReconciliationItem) GetSettledAmount() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(ReconciliationItemFieldSettledAmount)
+}
+
+func (this *ReconciliationItem) SetSettledAmount(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(ReconciliationItemFieldSettledAmount, v)
+}
+
+func (this ReconciliationItem) GetLineNo() *int32 {
+	return this.GetFieldData().GetInt32(ReconciliationItemFieldLineNo)
+}
+
+func (this *ReconciliationItem) SetLineNo(v *int32) {
+	this.GetFieldData().SetInt32(ReconciliationItemFieldLineNo, v)
+}
+
+func (this ReconciliationItem) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(ReconciliationItemFieldOrgId)
+}
+
+func (this *ReconciliationItem) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(ReconciliationItemFieldOrgId, v)
+}
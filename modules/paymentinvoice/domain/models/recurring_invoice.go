@@ -0,0 +1,185 @@
+package models
+
+import (
+	_ "embed"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	RecurringInvoiceSchemaName = "paymentinvoice_recurring_invoice"
+
+	RecurringInvoiceFieldId              = basemodel.FieldId
+	RecurringInvoiceFieldEtag            = basemodel.FieldEtag
+	RecurringInvoiceFieldName            = "name"
+	RecurringInvoiceFieldPartnerName     = "partner_name"
+	RecurringInvoiceFieldPartnerTaxCode  = "partner_tax_code"
+	RecurringInvoiceFieldPartnerAddress  = "partner_address"
+	RecurringInvoiceFieldCurrencyId      = "currency_id"
+	RecurringInvoiceFieldNote            = "note"
+	RecurringInvoiceFieldIntervalUnit    = "interval_unit"
+	RecurringInvoiceFieldIntervalCount   = "interval_count"
+	RecurringInvoiceFieldStartDate       = "start_date"
+	RecurringInvoiceFieldEndDate         = "end_date"
+	RecurringInvoiceFieldNextRunDate     = "next_run_date"
+	RecurringInvoiceFieldAutoIssue       = "auto_issue"
+	RecurringInvoiceFieldIsActive        = "is_active"
+	RecurringInvoiceFieldLastGeneratedAt = "last_generated_at"
+	RecurringInvoiceFieldOrgId           = "org_id"
+)
+
+const (
+	RecurringIntervalDay   = "day"
+	RecurringIntervalWeek  = "week"
+	RecurringIntervalMonth = "month"
+	RecurringIntervalYear  = "year"
+)
+
+//go:embed recurring_invoice.json
+var recurringInvoiceSchemaJson string
+
+func RecurringInvoiceSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(recurringInvoiceSchemaJson)
+}
+
+// RecurringInvoice is a schedule that raises the same invoice every period.
+// Table: paymentinvoice_recurring_invoices.
+//
+// It is a template, not an invoice: its partner, note and lines are copied onto each invoice as it
+// is generated, so editing the schedule changes the periods still to come and never an invoice
+// already raised. next_run_date is the only thing generation writes back, which is why it is
+// no_update.
+type RecurringInvoice struct {
+	basemodel.DynamicModelBase
+}
+
+func NewRecurringInvoice() *RecurringInvoice {
+	return &RecurringInvoice{basemodel.NewDynamicModel()}
+}
+
+func NewRecurringInvoiceFrom(src dmodel.DynamicFields) *RecurringInvoice {
+	return &RecurringInvoice{basemodel.NewDynamicModel(src)}
+}
+
+func (this RecurringInvoice) GetName() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldName)
+}
+
+func (this *RecurringInvoice) SetName(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldName, v)
+}
+
+func (this RecurringInvoice) GetPartnerName() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldPartnerName)
+}
+
+func (this *RecurringInvoice) SetPartnerName(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldPartnerName, v)
+}
+
+func (this RecurringInvoice) GetPartnerTaxCode() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldPartnerTaxCode)
+}
+
+func (this *RecurringInvoice) SetPartnerTaxCode(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldPartnerTaxCode, v)
+}
+
+func (this RecurringInvoice) GetPartnerAddress() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldPartnerAddress)
+}
+
+func (this *RecurringInvoice) SetPartnerAddress(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldPartnerAddress, v)
+}
+
+func (this RecurringInvoice) GetCurrencyId() *model.Id {
+	return this.GetFieldData().GetModelId(RecurringInvoiceFieldCurrencyId)
+}
+
+func (this *RecurringInvoice) SetCurrencyId(v *model.Id) {
+	this.GetFieldData().SetModelId(RecurringInvoiceFieldCurrencyId, v)
+}
+
+func (this RecurringInvoice) GetNote() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldNote)
+}
+
+func (this *RecurringInvoice) SetNote(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldNote, v)
+}
+
+func (this RecurringInvoice) GetIntervalUnit() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceFieldIntervalUnit)
+}
+
+func (this *RecurringInvoice) SetIntervalUnit(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceFieldIntervalUnit, v)
+}
+
+func (this RecurringInvoice) GetIntervalCount() *int32 {
+	return this.GetFieldData().GetInt32(RecurringInvoiceFieldIntervalCount)
+}
+
+func (this *RecurringInvoice) SetIntervalCount(v *int32) {
+	this.GetFieldData().SetInt32(RecurringInvoiceFieldIntervalCount, v)
+}
+
+func (this RecurringInvoice) GetStartDate() *model.ModelDate {
+	return this.GetFieldData().GetModelDate(RecurringInvoiceFieldStartDate)
+}
+
+func (this *RecurringInvoice) SetStartDate(v *model.ModelDate) {
+	this.GetFieldData().SetModelDate(RecurringInvoiceFieldStartDate, v)
+}
+
+func (this RecurringInvoice) GetEndDate() *model.ModelDate {
+	return this.GetFieldData().GetModelDate(RecurringInvoiceFieldEndDate)
+}
+
+func (this *RecurringInvoice) SetEndDate(v *model.ModelDate) {
+	this.GetFieldData().SetModelDate(RecurringInvoiceFieldEndDate, v)
+}
+
+func (this RecurringInvoice) GetNextRunDate() *model.ModelDate {
+	return this.GetFieldData().GetModelDate(RecurringInvoiceFieldNextRunDate)
+}
+
+func (this *RecurringInvoice) SetNextRunDate(v *model.ModelDate) {
+	this.GetFieldData().SetModelDate(RecurringInvoiceFieldNextRunDate, v)
+}
+
+func (this RecurringInvoice) GetAutoIssue() *bool {
+	return this.GetFieldData().GetBool(RecurringInvoiceFieldAutoIssue)
+}
+
+func (this *RecurringInvoice) SetAutoIssue(v *bool) {
+	this.GetFieldData().SetBool(RecurringInvoiceFieldAutoIssue, v)
+}
+
+func (this RecurringInvoice) GetIsActive() *bool {
+	return this.GetFieldData().GetBool(RecurringInvoiceFieldIsActive)
+}
+
+func (this *RecurringInvoice) SetIsActive(v *bool) {
+	this.GetFieldData().SetBool(RecurringInvoiceFieldIsActive, v)
+}
+
+func (this RecurringInvoice) GetLastGeneratedAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(RecurringInvoiceFieldLastGeneratedAt)
+}
+
+func (this *RecurringInvoice) SetLastGeneratedAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(RecurringInvoiceFieldLastGeneratedAt, v)
+}
+
+func (this RecurringInvoice) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(RecurringInvoiceFieldOrgId)
+}
+
+func (this *RecurringInvoice) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(RecurringInvoiceFieldOrgId, v)
+}
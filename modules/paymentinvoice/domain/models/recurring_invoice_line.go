@@ -0,0 +1,118 @@
+package models
+
+import (
+	_ "embed"
+
+	"github.com/shopspring/decimal"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	RecurringInvoiceLineSchemaName = "paymentinvoice_recurring_invoice_line"
+
+	RecurringInvoiceLineFieldId                 = basemodel.FieldId
+	RecurringInvoiceLineFieldRecurringInvoiceId = "recurring_invoice_id"
+	RecurringInvoiceLineFieldDescription        = "description"
+	RecurringInvoiceLineFieldQuantity           = "quantity"
+	RecurringInvoiceLineFieldUnitPrice          = "unit_price"
+	RecurringInvoiceLineFieldTaxRatePercent     = "tax_rate_percent"
+	RecurringInvoiceLineFieldTaxIds             = "tax_ids"
+	RecurringInvoiceLineFieldOrgId              = "org_id"
+)
+
+//go:embed recurring_invoice_line.json
+var recurringInvoiceLineSchemaJson string
+
+func RecurringInvoiceLineSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(recurringInvoiceLineSchemaJson)
+}
+
+// RecurringInvoiceLine is one line of a recurring invoice, copied onto every invoice it generates.
+// Table: paymentinvoice_recurring_invoice_lines.
+//
+// It carries what an invoice line is written with and nothing it is computed to: amounts are
+// worked out on the generated invoice, by the same rules as any other.
+type RecurringInvoiceLine struct {
+	basemodel.DynamicModelBase
+}
+
+func NewRecurringInvoiceLine() *RecurringInvoiceLine {
+	return &RecurringInvoiceLine{basemodel.NewDynamicModel()}
+}
+
+func NewRecurringInvoiceLineFrom(src dmodel.DynamicFields) *RecurringInvoiceLine {
+	return &RecurringInvoiceLine{basemodel.NewDynamicModel(src)}
+}
+
+func (this RecurringInvoiceLine) GetRecurringInvoiceId() *model.Id {
+	return this.GetFieldData().GetModelId(RecurringInvoiceLineFieldRecurringInvoiceId)
+}
+
+func (this *RecurringInvoiceLine) SetRecurringInvoiceId(v *model.Id) {
+	this.GetFieldData().SetModelId(RecurringInvoiceLineFieldRecurringInvoiceId, v)
+}
+
+func (this RecurringInvoiceLine) GetDescription() *string {
+	return this.GetFieldData().GetString(RecurringInvoiceLineFieldDescription)
+}
+
+func (this *RecurringInvoiceLine) SetDescription(v *string) {
+	this.GetFieldData().SetString(RecurringInvoiceLineFieldDescription, v)
+}
+
+func (this RecurringInvoiceLine) GetQuantity() *int32 {
+	return this.GetFieldData().GetInt32(RecurringInvoiceLineFieldQuantity)
+}
+
+func (this *RecurringInvoiceLine) SetQuantity(v *int32) {
+	this.GetFieldData().SetInt32(RecurringInvoiceLineFieldQuantity, v)
+}
+
+func (this RecurringInvoiceLine) GetUnitPrice() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(RecurringInvoiceLineFieldUnitPrice)
+}
+
+func (this *RecurringInvoiceLine) SetUnitPrice(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(RecurringInvoiceLineFieldUnitPrice, v)
+}
+
+func (this RecurringInvoiceLine) GetTaxRatePercent() *decimal.Decimal {
+	return this.GetFieldData().GetDecimal(RecurringInvoiceLineFieldTaxRatePercent)
+}
+
+func (this *RecurringInvoiceLine) SetTaxRatePercent(v *decimal.Decimal) {
+	this.GetFieldData().SetDecimal(RecurringInvoiceLineFieldTaxRatePercent, v)
+}
+
+// GetTaxIds reads the taxes the line names, accepting both shapes GetTaxIds on an invoice line does.
+func (this RecurringInvoiceLine) GetTaxIds() []model.Id {
+	switch typed := this.GetFieldData().GetAny(RecurringInvoiceLineFieldTaxIds).(type) {
+	case []string:
+		return typed
+	case []any:
+		ids := make([]model.Id, 0, len(typed))
+		for _, item := range typed {
+			if id, ok := item.(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	return nil
+}
+
+func (this *RecurringInvoiceLine) SetTaxIds(v []model.Id) {
+	this.GetFieldData().SetStrings(RecurringInvoiceLineFieldTaxIds, v)
+}
+
+func (this RecurringInvoiceLine) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(RecurringInvoiceLineFieldOrgId)
+}
+
+func (this *RecurringInvoiceLine) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(RecurringInvoiceLineFieldOrgId, v)
+}
@@ -44,6 +44,15 @@ func TestEverySchemaParsesUnderItsConstantName(t *testing.T) {
 		{TransactionSchemaName, "paymentinvoice_transactions", TransactionSchemaBuilder},
 		{InvoiceSchemaName, "paymentinvoice_invoices", InvoiceSchemaBuilder},
 		{InvoiceLineSchemaName, "paymentinvoice_invoice_lines", InvoiceLineSchemaBuilder},
+		{PaymentAllocationSchemaName, "paymentinvoice_payment_allocations", PaymentAllocationSchemaBuilder},
+		{CreditNoteSchemaName, "paymentinvoice_credit_notes", CreditNoteSchemaBuilder},
+		{CreditNoteLineSchemaName, "paymentinvoice_credit_note_lines", CreditNoteLineSchemaBuilder},
+		{RecurringInvoiceSchemaName, "paymentinvoice_recurring_invoices", RecurringInvoiceSchemaBuilder},
+		{RecurringInvoiceLineSchemaName, "paymentinvoice_recurring_invoice_lines", RecurringInvoiceLineSchemaBuilder},
+		{RecurringInvoiceRunSchemaName, "paymentinvoice_recurring_invoice_runs", RecurringInvoiceRunSchemaBuilder},
+		{SyncDeliverySchemaName, "paymentinvoice_sync_deliveries", SyncDeliverySchemaBuilder},
+		{ReconciliationSchemaName, "paymentinvoice_reconciliations", ReconciliationSchemaBuilder},
+		{ReconciliationItemSchemaName, "paymentinvoice_reconciliation_items", ReconciliationItemSchemaBuilder},
 	}
 
 	for _, testCase := range cases {
@@ -72,11 +81,30 @@ func TestMoneyAndPercentageFieldsAreDecimal(t *testing.T) {
 			InvoiceFieldSubtotalAmount,
 			InvoiceFieldTaxAmount,
 			InvoiceFieldTotalAmount,
+			InvoiceFieldAmountPaid,
+			InvoiceFieldAmountDue,
+			InvoiceFieldAmountCredited,
+		},
+		CreditNoteSchemaBuilder().Build(): {
+			CreditNoteFieldSubtotalAmount,
+			CreditNoteFieldTaxAmount,
+			CreditNoteFieldTotalAmount,
+			CreditNoteFieldRefundAmount,
+		},
+		CreditNoteLineSchemaBuilder().Build(): {
+			CreditNoteLineFieldUnitPrice,
+			CreditNoteLineFieldAmount,
+			CreditNoteLineFieldTaxRatePercent,
+		},
+		PaymentAllocationSchemaBuilder().Build(): {
+			PaymentAllocationFieldAmount,
+			PaymentAllocationFieldReleasedAmount,
 		},
 		InvoiceLineSchemaBuilder().Build(): {
 			InvoiceLineFieldUnitPrice,
 			InvoiceLineFieldAmount,
 			InvoiceLineFieldTaxRatePercent,
+			InvoiceLineFieldTaxAmount,
 		},
 		PaymentMethodSchemaBuilder().Build(): {
 			PaymentMethodFieldMinAmount,
@@ -101,6 +129,9 @@ func TestBoundedCountersAreInt32(t *testing.T) {
 
 	quantity := requireField(t, InvoiceLineSchemaBuilder().Build(), InvoiceLineFieldQuantity)
 	assert.Equal(t, dmodel.FieldDataTypeNameInt32, quantity.DataType().String())
+
+	credited := requireField(t, CreditNoteLineSchemaBuilder().Build(), CreditNoteLineFieldQuantity)
+	assert.Equal(t, dmodel.FieldDataTypeNameInt32, credited.DataType().String())
 }
 
 // The order carries no column per gateway. What one gateway needs at create time — a terminal id
@@ -147,6 +178,60 @@ func TestLifecycleFieldsRejectClientUpdates(t *testing.T) {
 	// The number and the totals are assigned by issue, together and from the lines.
 	assert.True(t, requireField(t, invoice, InvoiceFieldNumber).IsNoUpdate())
 	assert.True(t, requireField(t, invoice, InvoiceFieldTotalAmount).IsNoUpdate())
+	// What has been paid is kept by the allocations; a client writing it could mark an invoice
+	// settled that no money settled.
+	assert.True(t, requireField(t, invoice, InvoiceFieldAmountPaid).IsNoUpdate())
+	assert.True(t, requireField(t, invoice, InvoiceFieldAmountDue).IsNoUpdate())
+	assert.True(t, requireField(t, invoice, InvoiceFieldAmountCredited).IsNoUpdate())
+	// The breakdown is frozen with the totals it adds up to.
+	assert.True(t, requireField(t, invoice, InvoiceFieldTaxBreakdown).IsNoUpdate())
+	assert.True(t, requireField(t, InvoiceLineSchemaBuilder().Build(), InvoiceLineFieldTaxAmount).IsNoUpdate())
+
+	allocation := PaymentAllocationSchemaBuilder().Build()
+	for _, fieldName := range []string{
+		PaymentAllocationFieldInvoiceId,
+		PaymentAllocationFieldTransactionId,
+		PaymentAllocationFieldAmount,
+		PaymentAllocationFieldReleasedAmount,
+	} {
+		assert.Truef(t, requireField(t, allocation, fieldName).IsNoUpdate(), "%s must be immutable", fieldName)
+	}
+
+	// A credit note is a document issued once, like the invoice it corrects: changing its lines or
+	// totals afterwards would make amount_credited disagree with the notes that add up to it.
+	creditNote := CreditNoteSchemaBuilder().Build()
+	for _, fieldName := range []string{
+		CreditNoteFieldNumber,
+		CreditNoteFieldInvoiceId,
+		CreditNoteFieldTotalAmount,
+		CreditNoteFieldRefundStatus,
+	} {
+		assert.Truef(t, requireField(t, creditNote, fieldName).IsNoUpdate(), "%s must be immutable", fieldName)
+	}
+}
+
+// A recurring invoice must not invoice one period twice, whether a catch-up after downtime overlaps
+// a regular run or two instances run at once. The run log's unique key is what refuses the second.
+func TestARecurringPeriodIsInvoicedOnce(t *testing.T) {
+	requireBaseSchemasRegistered(t)
+
+	run := RecurringInvoiceRunSchemaBuilder().Build()
+	assert.Contains(t, run.AllUniques(),
+		[]string{RecurringInvoiceRunFieldRecurringInvoiceId, RecurringInvoiceRunFieldPeriodDate})
+
+	// Generation alone advances the schedule; a client moving it would skip or repeat periods.
+	schedule := RecurringInvoiceSchemaBuilder().Build()
+	assert.True(t, requireField(t, schedule, RecurringInvoiceFieldNextRunDate).IsNoUpdate())
+}
+
+// A payment method has one reconciliation per day. Importing a corrected report replaces it, and
+// the unique key is what keeps two imports racing from leaving two that disagree.
+func TestADayIsReconciledOncePerMethod(t *testing.T) {
+	requireBaseSchemasRegistered(t)
+
+	reconciliation := ReconciliationSchemaBuilder().Build()
+	assert.Contains(t, reconciliation.AllUniques(),
+		[]string{ReconciliationFieldPaymentMethodId, ReconciliationFieldSettlementDate})
 }
 
 // An order is found by order_code on every gateway callback and by order_id whenever support or
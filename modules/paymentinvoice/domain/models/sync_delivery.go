@@ -0,0 +1,155 @@
+package models
+
+import (
+	_ "embed"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	SyncDeliverySchemaName = "paymentinvoice_sync_delivery"
+
+	SyncDeliveryFieldId                = basemodel.FieldId
+	SyncDeliveryFieldOrderId           = "order_id"
+	SyncDeliveryFieldOrderRef          = "order_ref"
+	SyncDeliveryFieldReturnUrl         = "return_url"
+	SyncDeliveryFieldPaymentMethodCode = "payment_method_code"
+	SyncDeliveryFieldPayload           = "payload"
+	SyncDeliveryFieldStatus            = "status"
+	SyncDeliveryFieldAttempts          = "attempts"
+	SyncDeliveryFieldNextAttemptAt     = "next_attempt_at"
+	SyncDeliveryFieldLastAttemptAt     = "last_attempt_at"
+	SyncDeliveryFieldDeliveredAt       = "delivered_at"
+	SyncDeliveryFieldLastDetail        = "last_detail"
+	SyncDeliveryFieldOrgId             = "org_id"
+)
+
+const (
+	SyncDeliveryStatusPending      = "pending"
+	SyncDeliveryStatusDelivered    = "delivered"
+	SyncDeliveryStatusFailed       = "failed"
+	SyncDeliveryStatusDeadLettered = "dead_lettered"
+)
+
+//go:embed sync_delivery.json
+var syncDeliverySchemaJson string
+
+func SyncDeliverySchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(syncDeliverySchemaJson)
+}
+
+// SyncDelivery is one notification of a payment result to the ordering system, with every attempt
+// to deliver it. Table: paymentinvoice_sync_deliveries.
+//
+// The order's own sync_logs keep a short summary; this is the record a tenant's complaint is
+// checked against, and the thing a replay re-sends.
+type SyncDelivery struct {
+	basemodel.DynamicModelBase
+}
+
+func NewSyncDelivery() *SyncDelivery {
+	return &SyncDelivery{basemodel.NewDynamicModel()}
+}
+
+func NewSyncDeliveryFrom(src dmodel.DynamicFields) *SyncDelivery {
+	return &SyncDelivery{basemodel.NewDynamicModel(src)}
+}
+
+func (this SyncDelivery) GetOrderId() *model.Id {
+	return this.GetFieldData().GetModelId(SyncDeliveryFieldOrderId)
+}
+
+func (this *SyncDelivery) SetOrderId(v *model.Id) {
+	this.GetFieldData().SetModelId(SyncDeliveryFieldOrderId, v)
+}
+
+func (this SyncDelivery) GetOrderRef() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldOrderRef)
+}
+
+func (this *SyncDelivery) SetOrderRef(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldOrderRef, v)
+}
+
+func (this SyncDelivery) GetReturnUrl() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldReturnUrl)
+}
+
+func (this *SyncDelivery) SetReturnUrl(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldReturnUrl, v)
+}
+
+func (this SyncDelivery) GetPaymentMethodCode() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldPaymentMethodCode)
+}
+
+func (this *SyncDelivery) SetPaymentMethodCode(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldPaymentMethodCode, v)
+}
+
+func (this SyncDelivery) GetPayload() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldPayload)
+}
+
+func (this *SyncDelivery) SetPayload(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldPayload, v)
+}
+
+func (this SyncDelivery) GetStatus() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldStatus)
+}
+
+func (this *SyncDelivery) SetStatus(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldStatus, v)
+}
+
+func (this SyncDelivery) GetAttempts() *int32 {
+	return this.GetFieldData().GetInt32(SyncDeliveryFieldAttempts)
+}
+
+func (this *SyncDelivery) SetAttempts(v *int32) {
+	this.GetFieldData().SetInt32(SyncDeliveryFieldAttempts, v)
+}
+
+func (this SyncDelivery) GetNextAttemptAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(SyncDeliveryFieldNextAttemptAt)
+}
+
+func (this *SyncDelivery) SetNextAttemptAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(SyncDeliveryFieldNextAttemptAt, v)
+}
+
+func (this SyncDelivery) GetLastAttemptAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(SyncDeliveryFieldLastAttemptAt)
+}
+
+func (this *SyncDelivery) SetLastAttemptAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(SyncDeliveryFieldLastAttemptAt, v)
+}
+
+func (this SyncDelivery) GetDeliveredAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(SyncDeliveryFieldDeliveredAt)
+}
+
+func (this *SyncDelivery) SetDeliveredAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(SyncDeliveryFieldDeliveredAt, v)
+}
+
+func (this SyncDelivery) GetLastDetail() *string {
+	return this.GetFieldData().GetString(SyncDeliveryFieldLastDetail)
+}
+
+func (this *SyncDelivery) SetLastDetail(v *string) {
+	this.GetFieldData().SetString(SyncDeliveryFieldLastDetail, v)
+}
+
+func (this SyncDelivery) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(SyncDeliveryFieldOrgId)
+}
+
+func (this *SyncDelivery) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(SyncDeliveryFieldOrgId, v)
+}
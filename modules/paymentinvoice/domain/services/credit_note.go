@@ -0,0 +1,471 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// CreditCommand asks for part of an issued invoice to be taken back.
+//
+// Lines names which invoice lines are credited and how many of their units; when it is empty,
+// everything not yet credited is, which is how a whole invoice is reversed. Refund asks for the
+// credited amount to be given back through the gateway the invoice's order was paid by.
+type CreditCommand struct {
+	InvoiceId string
+	Lines     []CreditLineCommand
+	Reason    *string
+	Refund    bool
+}
+
+// CreditLineCommand names one invoice line to credit.
+type CreditLineCommand struct {
+	InvoiceLineId string
+	Quantity      int32
+}
+
+// CreditResult is the issued note, where its invoice stands afterwards, and what became of the
+// refund if one was asked for.
+type CreditResult struct {
+	CreditNoteId string
+	Number       string
+	IssuedAt     time.Time
+
+	SubtotalAmount decimal.Decimal
+	TaxAmount      decimal.Decimal
+	TotalAmount    decimal.Decimal
+
+	InvoiceStatus string
+	AmountDue     decimal.Decimal
+
+	RefundStatus string
+	RefundAmount decimal.Decimal
+
+	// RefundError says why the gateway refused the refund. The note is issued regardless: the
+	// correction to the invoice stands, and the refund can be made again through the order.
+	RefundError string
+}
+
+// creditLinePlan is one invoice line about to be credited, priced as it was charged.
+type creditLinePlan struct {
+	Line     models.InvoiceLine
+	Quantity int32
+	Amount   decimal.Decimal
+	Tax      decimal.Decimal
+}
+
+// Credit issues a credit note against an invoice.
+//
+// The note, its lines, its number and its effect on the invoice are one transaction, so the number
+// sequence has no gap and the invoice never shows a credit whose note does not exist.
+//
+// No invoice line is credited for more units than it was charged for, counting every earlier note
+// against it. Prices and tax rates are copied from the invoice lines, never taken from the caller:
+// a credit gives back what was charged, not what someone now says it should have been.
+//
+// A refund is checked before anything is written, with the same guard rails a refund of the order
+// has, so a note asking for money the order cannot give back is refused outright. The gateway is
+// only called once the note has committed; if it then refuses, the note stands and records the
+// failure.
+func (this *InvoiceDomainService) Credit(
+	ctx corectx.Context, cmd CreditCommand,
+) (*CreditResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+
+	if cmd.InvoiceId == "" {
+		appendFieldViolation(vErrs, models.CreditNoteFieldInvoiceId,
+			"paymentinvoice.invoice_required", "no invoice was identified")
+		return nil, vErrs, nil
+	}
+
+	var result *CreditResult
+	var order *models.Order
+	err := withInvoiceTransaction(ctx, func(tranxCtx corectx.Context) error {
+		// The note adds to amount_credited, which an allocation settling the invoice also reads.
+		if err := lockInvoiceForUpdate(tranxCtx, cmd.InvoiceId); err != nil {
+			return err
+		}
+		invoice, err := findInvoiceById(tranxCtx, cmd.InvoiceId)
+		if err != nil {
+			return err
+		}
+		if invoice == nil {
+			appendFieldViolation(vErrs, models.CreditNoteFieldInvoiceId,
+				"paymentinvoice.invoice_not_found", "no invoice with id '"+cmd.InvoiceId+"'")
+			return nil
+		}
+		status := derefString(invoice.GetStatus())
+		if status != models.InvoiceStatusIssued && status != models.InvoiceStatusPaid {
+			appendFieldViolation(vErrs, models.CreditNoteFieldInvoiceId,
+				"paymentinvoice.invoice_not_creditable",
+				"only an issued invoice can be credited; this invoice is '"+status+"'")
+			return nil
+		}
+
+		lines, err := findInvoiceLines(tranxCtx, cmd.InvoiceId)
+		if err != nil {
+			return err
+		}
+		earlier, err := findCreditNoteLines(tranxCtx, cmd.InvoiceId)
+		if err != nil {
+			return err
+		}
+		plans := planCreditLines(lines, creditedQuantities(earlier), cmd.Lines, vErrs)
+		if vErrs.Count() > 0 {
+			return nil
+		}
+		totals := creditTotals(plans)
+
+		if cmd.Refund {
+			order, err = this.loadRefundableOrder(tranxCtx, *invoice, totals.Total, vErrs)
+			if err != nil || vErrs.Count() > 0 {
+				return err
+			}
+		}
+
+		issuedAt := time.Now().UTC()
+		result, err = writeCreditNote(tranxCtx, *invoice, cmd, plans, totals, issuedAt)
+		return err
+	})
+	if err != nil || vErrs.Count() > 0 {
+		return nil, vErrs, err
+	}
+
+	if order != nil {
+		if err := this.refundCreditNote(ctx, *order, result); err != nil {
+			return nil, vErrs, err
+		}
+	}
+	return result, vErrs, nil
+}
+
+// writeCreditNote numbers and writes the note and its lines, and takes its total off the invoice.
+func writeCreditNote(
+	ctx corectx.Context,
+	invoice models.Invoice,
+	cmd CreditCommand,
+	plans []creditLinePlan,
+	totals invoiceTotals,
+	issuedAt time.Time,
+) (*CreditResult, error) {
+	number, err := allocateDocumentNumber(ctx, models.CreditNoteSchemaName, models.CreditNoteFieldNumber,
+		fmt.Sprintf("CN-%d-", issuedAt.Year()))
+	if err != nil {
+		return nil, err
+	}
+
+	orgId := derefString(invoice.GetOrgId())
+	fields := dmodel.DynamicFields{
+		models.CreditNoteFieldNumber:         number,
+		models.CreditNoteFieldInvoiceId:      derefString(invoice.GetId()),
+		models.CreditNoteFieldCurrencyId:     derefString(invoice.GetCurrencyId()),
+		models.CreditNoteFieldSubtotalAmount: totals.Subtotal,
+		models.CreditNoteFieldTaxAmount:      totals.Tax,
+		models.CreditNoteFieldTotalAmount:    totals.Total,
+		models.CreditNoteFieldIssuedAt:       issuedAt,
+		models.CreditNoteFieldRefundStatus:   models.CreditNoteRefundNone,
+		models.CreditNoteFieldRefundAmount:   decimal.Zero,
+		models.CreditNoteFieldOrgId:          orgId,
+	}
+	if cmd.Reason != nil && *cmd.Reason != "" {
+		fields[models.CreditNoteFieldReason] = *cmd.Reason
+	}
+	created, err := createRecord(ctx, models.CreditNoteSchemaName, fields)
+	if err != nil {
+		return nil, err
+	}
+	creditNoteId := derefString(models.NewCreditNoteFrom(created).GetId())
+
+	for _, plan := range plans {
+		if _, err := createRecord(ctx, models.CreditNoteLineSchemaName, dmodel.DynamicFields{
+			models.CreditNoteLineFieldCreditNoteId:   creditNoteId,
+			models.CreditNoteLineFieldInvoiceId:      derefString(invoice.GetId()),
+			models.CreditNoteLineFieldInvoiceLineId:  derefString(plan.Line.GetId()),
+			models.CreditNoteLineFieldDescription:    derefString(plan.Line.GetDescription()),
+			models.CreditNoteLineFieldQuantity:       plan.Quantity,
+			models.CreditNoteLineFieldUnitPrice:      derefDecimal(plan.Line.GetUnitPrice()),
+			models.CreditNoteLineFieldTaxRatePercent: derefDecimal(plan.Line.GetTaxRatePercent()),
+			models.CreditNoteLineFieldAmount:         plan.Amount,
+			models.CreditNoteLineFieldOrgId:          orgId,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	credited := derefDecimal(invoice.GetAmountCredited()).Add(totals.Total)
+	invoice.SetAmountCredited(&credited)
+	allocations, err := findAllocations(ctx, models.PaymentAllocationFieldInvoiceId, derefString(invoice.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	settled, err := settleInvoice(ctx, invoice, allocations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreditResult{
+		CreditNoteId:   creditNoteId,
+		Number:         number,
+		IssuedAt:       issuedAt,
+		SubtotalAmount: totals.Subtotal,
+		TaxAmount:      totals.Tax,
+		TotalAmount:    totals.Total,
+		InvoiceStatus:  settled.InvoiceStatus,
+		AmountDue:      settled.AmountDue,
+		RefundStatus:   models.CreditNoteRefundNone,
+		RefundAmount:   decimal.Zero,
+	}, nil
+}
+
+// loadRefundableOrder fetches the order the invoice was paid through and checks that it can give
+// back amount.
+func (this *InvoiceDomainService) loadRefundableOrder(
+	ctx corectx.Context, invoice models.Invoice, amount decimal.Decimal, vErrs *ft.ClientErrors,
+) (*models.Order, error) {
+	orderPk := derefString(invoice.GetOrderId())
+	if orderPk == "" {
+		appendFieldViolation(vErrs, models.CreditNoteFieldRefundStatus,
+			"paymentinvoice.invoice_not_paid_online",
+			"this invoice was not paid through a payment gateway, so there is nothing to refund through one")
+		return nil, nil
+	}
+
+	order, err := findOrderById(ctx, orderPk)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		appendFieldViolation(vErrs, models.CreditNoteFieldRefundStatus,
+			"paymentinvoice.order_not_found", "the order this invoice was paid through no longer exists")
+		return nil, nil
+	}
+	if !assertRefundable(*order, amount, vErrs) {
+		return nil, nil
+	}
+	return order, nil
+}
+
+// refundCreditNote gives the note's total back through the order's gateway and records the outcome
+// on the note.
+//
+// The note has already committed by now. A gateway refusal is recorded on it rather than reported
+// as a failure of the request, because the correction it makes to the invoice stands either way.
+// An error here is different: the outcome is unknown, so it is returned, naming the note, for
+// whoever investigates to check against the gateway.
+func (this *InvoiceDomainService) refundCreditNote(
+	ctx corectx.Context, order models.Order, result *CreditResult,
+) error {
+	if this.orders == nil {
+		return errors.New("the invoice domain service was built without the order service")
+	}
+
+	content := "Credit note " + result.Number
+	_, cErrs, err := this.orders.Refund(ctx, RefundCommand{
+		OrderId: derefString(order.GetOrderId()),
+		Amount:  result.TotalAmount,
+		Content: &content,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "credit note %s was issued but its refund did not complete", result.Number)
+	}
+
+	fields := dmodel.DynamicFields{}
+	if cErrs.Count() > 0 {
+		result.RefundStatus = models.CreditNoteRefundFailed
+		result.RefundError = (*cErrs)[0].String()
+		fields[models.CreditNoteFieldRefundStatus] = models.CreditNoteRefundFailed
+	} else {
+		result.RefundStatus = models.CreditNoteRefundRefunded
+		result.RefundAmount = result.TotalAmount
+		fields[models.CreditNoteFieldRefundStatus] = models.CreditNoteRefundRefunded
+		fields[models.CreditNoteFieldRefundAmount] = result.TotalAmount
+	}
+	return writeCreditNoteFields(ctx, result.CreditNoteId, fields)
+}
+
+// planCreditLines decides what each requested line credits, and refuses any that would credit more
+// units than the invoice line has left.
+//
+// With no lines requested, every invoice line is credited for whatever is left of it. A request
+// naming one line twice is summed, so it is held to the same bound as one naming it once.
+func planCreditLines(
+	lines []*models.InvoiceLine,
+	credited map[string]int32,
+	requested []CreditLineCommand,
+	vErrs *ft.ClientErrors,
+) []creditLinePlan {
+	byId := map[string]*models.InvoiceLine{}
+	for _, line := range lines {
+		byId[derefString(line.GetId())] = line
+	}
+
+	quantities := map[string]int32{}
+	lineIds := []string{}
+	if len(requested) == 0 {
+		for _, line := range lines {
+			lineId := derefString(line.GetId())
+			if left := lineQuantity(*line) - credited[lineId]; left > 0 {
+				quantities[lineId] = left
+				lineIds = append(lineIds, lineId)
+			}
+		}
+	}
+	for _, request := range requested {
+		if _, exists := byId[request.InvoiceLineId]; !exists {
+			appendFieldViolation(vErrs, models.CreditNoteLineFieldInvoiceLineId,
+				"paymentinvoice.invoice_line_not_found",
+				"the invoice has no line with id '"+request.InvoiceLineId+"'")
+			return nil
+		}
+		if request.Quantity <= 0 {
+			appendFieldViolation(vErrs, models.CreditNoteLineFieldQuantity,
+				"paymentinvoice.quantity_not_positive", "a credited quantity must be greater than zero")
+			return nil
+		}
+		if _, seen := quantities[request.InvoiceLineId]; !seen {
+			lineIds = append(lineIds, request.InvoiceLineId)
+		}
+		quantities[request.InvoiceLineId] += request.Quantity
+	}
+
+	if len(lineIds) == 0 {
+		appendFieldViolation(vErrs, models.CreditNoteFieldInvoiceId,
+			"paymentinvoice.invoice_fully_credited", "every line of this invoice has already been credited")
+		return nil
+	}
+
+	plans := make([]creditLinePlan, 0, len(lineIds))
+	for _, lineId := range lineIds {
+		line := byId[lineId]
+		left := lineQuantity(*line) - credited[lineId]
+		if quantities[lineId] > left {
+			appendFieldViolation(vErrs, models.CreditNoteLineFieldQuantity,
+				"paymentinvoice.credit_exceeds_line",
+				fmt.Sprintf("line '%s' has %d units left to credit; %d were asked for",
+					derefString(line.GetDescription()), left, quantities[lineId]))
+			return nil
+		}
+
+		plans = append(plans, planCreditLine(*line, quantities[lineId]))
+	}
+	return plans
+}
+
+// planCreditLine works out what crediting quantity units of one line takes back.
+//
+// A line that named taxes gives back its share of what was stored for it at issue, rather than
+// being taxed again: the taxes may have changed since, and a credit must take back what was charged.
+// A line taxed at a bare rate is credited at that rate, as it always was.
+func planCreditLine(line models.InvoiceLine, quantity int32) creditLinePlan {
+	plan := creditLinePlan{Line: line, Quantity: quantity}
+
+	if len(line.GetTaxIds()) > 0 && lineQuantity(line) > 0 {
+		credited := decimal.NewFromInt(int64(quantity))
+		invoiced := decimal.NewFromInt(int64(lineQuantity(line)))
+		plan.Amount = derefDecimal(line.GetAmount()).Mul(credited).Div(invoiced)
+		plan.Tax = derefDecimal(line.GetTaxAmount()).Mul(credited).Div(invoiced)
+		return plan
+	}
+
+	plan.Amount = derefDecimal(line.GetUnitPrice()).Mul(decimal.NewFromInt(int64(quantity)))
+	plan.Tax = plan.Amount.Mul(derefDecimal(line.GetTaxRatePercent())).Div(decimal.NewFromInt(100))
+	return plan
+}
+
+// creditTotals is what the planned lines come to, taxed per line as the invoice was.
+func creditTotals(plans []creditLinePlan) invoiceTotals {
+	totals := invoiceTotals{Subtotal: decimal.Zero, Tax: decimal.Zero}
+	for _, plan := range plans {
+		totals.Subtotal = totals.Subtotal.Add(plan.Amount)
+		totals.Tax = totals.Tax.Add(plan.Tax)
+	}
+	totals.Total = totals.Subtotal.Add(totals.Tax)
+	return totals
+}
+
+// creditedQuantities sums the units already credited against each invoice line.
+func creditedQuantities(lines []*models.CreditNoteLine) map[string]int32 {
+	credited := map[string]int32{}
+	for _, line := range lines {
+		quantity := int32(0)
+		if q := line.GetQuantity(); q != nil {
+			quantity = *q
+		}
+		credited[derefString(line.GetInvoiceLineId())] += quantity
+	}
+	return credited
+}
+
+func lineQuantity(line models.InvoiceLine) int32 {
+	if quantity := line.GetQuantity(); quantity != nil {
+		return *quantity
+	}
+	return 0
+}
+
+// findCreditNoteLines returns every credit note line filed against one invoice.
+//
+// A full page is an error rather than a partial answer, because an undercount would let a line be
+// credited twice.
+func findCreditNoteLines(ctx corectx.Context, invoiceId string) ([]*models.CreditNoteLine, error) {
+	engine, err := engineFor(models.CreditNoteLineSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.CreditNoteLineFieldInvoiceId, dmodel.Equals, invoiceId),
+	)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  creditNoteLinePageSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findCreditNoteLines")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	if len(found.Data.Items) >= creditNoteLinePageSize {
+		return nil, errors.Errorf(
+			"findCreditNoteLines: invoice '%s' has more credit note lines than can be counted", invoiceId)
+	}
+
+	lines := make([]*models.CreditNoteLine, 0, len(found.Data.Items))
+	for _, item := range found.Data.Items {
+		lines = append(lines, models.NewCreditNoteLineFrom(item))
+	}
+	return lines, nil
+}
+
+// creditNoteLinePageSize bounds the credit note lines read for one invoice. It allows each invoice
+// line to be credited in several notes before the bound is reached.
+const creditNoteLinePageSize = invoiceLinePageSize * 10
+
+// writeCreditNoteFields updates a credit note through the repository. Its refund columns are
+// no_update, so this is the only writer of them.
+func writeCreditNoteFields(ctx corectx.Context, creditNotePk string, fields dmodel.DynamicFields) error {
+	engine, err := engineFor(models.CreditNoteSchemaName)
+	if err != nil {
+		return err
+	}
+
+	update := dmodel.DynamicFields{models.CreditNoteFieldId: creditNotePk}
+	for key, value := range fields {
+		update[key] = value
+	}
+	_, err = engine.ResourceRepository().Update(ctx, update)
+	return errors.Wrap(err, "writeCreditNoteFields")
+}
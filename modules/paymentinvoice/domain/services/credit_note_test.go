@@ -0,0 +1,155 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// A credit note may never take back more than the invoice charged. These pin the rules that bound
+// it, line by line, without a database.
+
+func invoiceLineOf(id string, quantity int32, unitPrice string, taxRate string) *models.InvoiceLine {
+	return models.NewInvoiceLineFrom(dmodel.DynamicFields{
+		models.InvoiceLineFieldId:             id,
+		models.InvoiceLineFieldDescription:    "line " + id,
+		models.InvoiceLineFieldQuantity:       quantity,
+		models.InvoiceLineFieldUnitPrice:      decimal.RequireFromString(unitPrice),
+		models.InvoiceLineFieldTaxRatePercent: decimal.RequireFromString(taxRate),
+	})
+}
+
+// With no lines named, the note credits everything still left on the invoice.
+func TestCreditingWithNoLinesCreditsWhatIsLeft(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{
+		invoiceLineOf("a", 3, "100", "10"),
+		invoiceLineOf("b", 1, "50", "0"),
+	}
+
+	plans := planCreditLines(lines, map[string]int32{"a": 1}, nil, vErrs)
+
+	require.Zero(t, vErrs.Count())
+	require.Len(t, plans, 2)
+	assert.Equal(t, int32(2), plans[0].Quantity)
+	assert.Equal(t, "200", plans[0].Amount.String())
+	assert.Equal(t, int32(1), plans[1].Quantity)
+}
+
+func TestAPartialCreditTakesOnlyTheNamedUnits(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{invoiceLineOf("a", 5, "100", "10")}
+
+	plans := planCreditLines(lines, nil, []CreditLineCommand{{InvoiceLineId: "a", Quantity: 2}}, vErrs)
+
+	require.Zero(t, vErrs.Count())
+	require.Len(t, plans, 1)
+	assert.Equal(t, "200", plans[0].Amount.String())
+	assert.Equal(t, "20", plans[0].Tax.String())
+}
+
+// Units credited by an earlier note are no longer available to a later one.
+func TestACreditBeyondWhatIsLeftOnALineIsRefused(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{invoiceLineOf("a", 5, "100", "0")}
+
+	plans := planCreditLines(lines, map[string]int32{"a": 4}, []CreditLineCommand{{InvoiceLineId: "a", Quantity: 2}}, vErrs)
+
+	assert.Nil(t, plans)
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+// Naming one line twice must not get round the bound that naming it once is held to.
+func TestALineNamedTwiceIsHeldToOneBound(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{invoiceLineOf("a", 3, "100", "0")}
+
+	plans := planCreditLines(lines, nil, []CreditLineCommand{
+		{InvoiceLineId: "a", Quantity: 2},
+		{InvoiceLineId: "a", Quantity: 2},
+	}, vErrs)
+
+	assert.Nil(t, plans)
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+func TestALineFromAnotherInvoiceIsRefused(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{invoiceLineOf("a", 3, "100", "0")}
+
+	plans := planCreditLines(lines, nil, []CreditLineCommand{{InvoiceLineId: "z", Quantity: 1}}, vErrs)
+
+	assert.Nil(t, plans)
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+func TestAFullyCreditedInvoiceTakesNoFurtherNote(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{invoiceLineOf("a", 3, "100", "0")}
+
+	plans := planCreditLines(lines, map[string]int32{"a": 3}, nil, vErrs)
+
+	assert.Nil(t, plans)
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+// Tax is credited per line at the rate the line was invoiced at, as issue charged it.
+func TestCreditTotalsAreTaxedPerLine(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	lines := []*models.InvoiceLine{
+		invoiceLineOf("a", 1, "100", "10"),
+		invoiceLineOf("b", 1, "100", "5"),
+	}
+
+	totals := creditTotals(planCreditLines(lines, nil, nil, vErrs))
+
+	require.Zero(t, vErrs.Count())
+	assert.Equal(t, "200", totals.Subtotal.String())
+	assert.Equal(t, "15", totals.Tax.String())
+	assert.Equal(t, "215", totals.Total.String())
+}
+
+// A line that named taxes is credited its share of the tax stored at issue, not taxed afresh: with
+// one of four units already credited, the rest take back three quarters of it.
+func TestALineWithNamedTaxesCreditsItsStoredTax(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	line := invoiceLineOf("a", 4, "110", "0")
+	line.GetFieldData()[models.InvoiceLineFieldTaxIds] = []string{"01JTAXVAT10000000000000000"}
+	line.GetFieldData()[models.InvoiceLineFieldAmount] = decimal.RequireFromString("400")
+	line.GetFieldData()[models.InvoiceLineFieldTaxAmount] = decimal.RequireFromString("40")
+
+	plans := planCreditLines([]*models.InvoiceLine{line}, map[string]int32{"a": 1}, nil, vErrs)
+	totals := creditTotals(plans)
+
+	require.Zero(t, vErrs.Count())
+	assert.Equal(t, "300", totals.Subtotal.String())
+	assert.Equal(t, "30", totals.Tax.String())
+	assert.Equal(t, "330", totals.Total.String())
+}
+
+// A credit takes its amount off what is due, and a paid invoice credited in part owes nothing.
+func TestACreditReducesWhatIsDue(t *testing.T) {
+	_, due := invoicePaymentTotals(decimal.RequireFromString("1000"), decimal.RequireFromString("300"),
+		[]*models.PaymentAllocation{allocationOf("a", "500", "0")})
+	assert.Equal(t, "200", due.String())
+
+	_, due = invoicePaymentTotals(decimal.RequireFromString("1000"), decimal.RequireFromString("300"),
+		[]*models.PaymentAllocation{allocationOf("a", "1000", "0")})
+	assert.True(t, due.IsZero())
+}
+
+// Credit notes are numbered in a series of their own, read back the same way invoice numbers are.
+func TestACreditNoteNumberIsReadWithItsOwnPrefix(t *testing.T) {
+	sequence, ok := sequenceOfInvoiceNumber("CN-2026-000007", "CN-2026-")
+	assert.True(t, ok)
+	assert.Equal(t, 7, sequence)
+
+	_, ok = sequenceOfInvoiceNumber("INV-2026-000007", "CN-2026-")
+	assert.False(t, ok)
+}
@@ -0,0 +1,668 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// AllocateCommand asks for money to be counted against an invoice.
+//
+// The money is either a completed gateway payment, named by TransactionId, or a receipt recorded by
+// hand, for which TransactionId is empty and Source says how it arrived.
+type AllocateCommand struct {
+	InvoiceId     string
+	TransactionId string
+	Source        string
+	Reference     *string
+	Amount        decimal.Decimal
+	Note          *string
+}
+
+// ReleaseAllocationCommand asks for what is left of one allocation to stop counting against its
+// invoice.
+type ReleaseAllocationCommand struct {
+	AllocationId string
+}
+
+// AllocationResult is the allocation written or released, and where its invoice stands afterwards.
+type AllocationResult struct {
+	AllocationId string
+	InvoiceId    string
+
+	InvoiceStatus string
+	AmountPaid    decimal.Decimal
+	AmountDue     decimal.Decimal
+}
+
+// allocationPageSize bounds how many allocations one invoice or one payment may carry, for the same
+// reason invoiceLinePageSize bounds the lines: totalling only the first page would understate what
+// has been paid.
+const allocationPageSize = 500
+
+// Allocate counts money against an issued invoice, and marks it paid once nothing is left due.
+//
+// Everything is checked inside one transaction, after the payment's row and then the invoice's
+// are locked FOR UPDATE. A second allocation against either waits for the first to commit and then
+// reads the amount_due and the unallocated remainder it left, so two allocations racing for the
+// same money cannot both pass.
+//
+// A payment can only be allocated once it has completed, and never for more than is left of it
+// after refunds and other allocations. Money the business has not received, or has given back,
+// must not settle anything.
+func (this *InvoiceDomainService) Allocate(
+	ctx corectx.Context, cmd AllocateCommand,
+) (*AllocationResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+	if !assertAllocationCommand(cmd, vErrs) {
+		return nil, vErrs, nil
+	}
+
+	var result *AllocationResult
+	err := withInvoiceTransaction(ctx, func(tranxCtx corectx.Context) error {
+		if cmd.TransactionId != "" {
+			if err := lockTransactionForUpdate(tranxCtx, cmd.TransactionId); err != nil {
+				return err
+			}
+		}
+		if err := lockInvoiceForUpdate(tranxCtx, cmd.InvoiceId); err != nil {
+			return err
+		}
+
+		invoice, err := findInvoiceById(tranxCtx, cmd.InvoiceId)
+		if err != nil {
+			return err
+		}
+		if invoice == nil {
+			appendFieldViolation(vErrs, models.PaymentAllocationFieldInvoiceId,
+				"paymentinvoice.invoice_not_found", "no invoice with id '"+cmd.InvoiceId+"'")
+			return nil
+		}
+		if !assertInvoiceAllocatable(*invoice, cmd.Amount, vErrs) {
+			return nil
+		}
+
+		allocations, err := findAllocations(tranxCtx,
+			models.PaymentAllocationFieldInvoiceId, cmd.InvoiceId)
+		if err != nil {
+			return err
+		}
+		if len(allocations) >= allocationPageSize-1 {
+			appendFieldViolation(vErrs, models.PaymentAllocationFieldInvoiceId,
+				"paymentinvoice.invoice_too_many_allocations",
+				fmt.Sprintf("an invoice may be settled by at most %d allocations", allocationPageSize-1))
+			return nil
+		}
+
+		source := cmd.Source
+		if cmd.TransactionId != "" {
+			source = models.PaymentAllocationSourceGateway
+			if err := assertPaymentAvailable(tranxCtx, *invoice, cmd, vErrs); err != nil || vErrs.Count() > 0 {
+				return err
+			}
+		}
+
+		fields := dmodel.DynamicFields{
+			models.PaymentAllocationFieldInvoiceId:      cmd.InvoiceId,
+			models.PaymentAllocationFieldSource:         source,
+			models.PaymentAllocationFieldAmount:         cmd.Amount,
+			models.PaymentAllocationFieldReleasedAmount: decimal.Zero,
+			models.PaymentAllocationFieldReceivedAt:     time.Now().UTC(),
+			models.PaymentAllocationFieldOrgId:          derefString(invoice.GetOrgId()),
+		}
+		if cmd.TransactionId != "" {
+			fields[models.PaymentAllocationFieldTransactionId] = cmd.TransactionId
+		}
+		if cmd.Reference != nil && *cmd.Reference != "" {
+			fields[models.PaymentAllocationFieldReference] = *cmd.Reference
+		}
+		if cmd.Note != nil && *cmd.Note != "" {
+			fields[models.PaymentAllocationFieldNote] = *cmd.Note
+		}
+
+		created, err := createRecord(tranxCtx, models.PaymentAllocationSchemaName, fields)
+		if err != nil {
+			return err
+		}
+		allocation := models.NewPaymentAllocationFrom(created)
+
+		result, err = settleInvoice(tranxCtx, *invoice, append(allocations, allocation))
+		if err != nil {
+			return err
+		}
+		result.AllocationId = derefString(allocation.GetId())
+		return nil
+	})
+
+	if err != nil || vErrs.Count() > 0 {
+		return nil, vErrs, err
+	}
+	return result, vErrs, nil
+}
+
+// ReleaseAllocation stops what is left of an allocation from counting against its invoice.
+//
+// It is how an allocation made in error is undone. The row stays, with its released_amount raised
+// to its amount, so the invoice's history still shows that it was once counted. An invoice already
+// paid goes back to issued, because it is owed again.
+func (this *InvoiceDomainService) ReleaseAllocation(
+	ctx corectx.Context, cmd ReleaseAllocationCommand,
+) (*AllocationResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+
+	if cmd.AllocationId == "" {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldId,
+			"paymentinvoice.allocation_required", "no allocation was identified")
+		return nil, vErrs, nil
+	}
+
+	var result *AllocationResult
+	err := withInvoiceTransaction(ctx, func(tranxCtx corectx.Context) error {
+		allocation, err := findAllocationById(tranxCtx, cmd.AllocationId)
+		if err != nil {
+			return err
+		}
+		if allocation == nil {
+			appendFieldViolation(vErrs, models.PaymentAllocationFieldId,
+				"paymentinvoice.allocation_not_found", "no allocation with id '"+cmd.AllocationId+"'")
+			return nil
+		}
+		remaining := effectiveAllocationAmount(*allocation)
+		if !remaining.IsPositive() {
+			appendFieldViolation(vErrs, models.PaymentAllocationFieldId,
+				"paymentinvoice.allocation_already_released",
+				"this allocation no longer counts against its invoice")
+			return nil
+		}
+
+		if err := releaseFromAllocation(tranxCtx, *allocation, remaining); err != nil {
+			return err
+		}
+
+		invoiceId := derefString(allocation.GetInvoiceId())
+		result, err = resettleInvoice(tranxCtx, invoiceId)
+		if err != nil {
+			return err
+		}
+		result.AllocationId = cmd.AllocationId
+		return nil
+	})
+
+	if err != nil || vErrs.Count() > 0 {
+		return nil, vErrs, err
+	}
+	return result, vErrs, nil
+}
+
+// releaseAllocationsForRefund gives back to the invoices what a completed refund took from their
+// payment.
+//
+// It runs inside the refund's own transaction, so the refund and its effect on the invoices commit
+// together. refundedTotal is the order's running refund total after this refund: a payment may only
+// go on settling invoices for what is left of it, and whatever its allocations hold beyond that is
+// released, the most recent allocations first.
+func releaseAllocationsForRefund(
+	ctx corectx.Context, order models.Order, refundedTotal decimal.Decimal,
+) error {
+	payment, err := findCompletedPayment(ctx, derefString(order.GetId()))
+	if err != nil || payment == nil {
+		return err
+	}
+	// Held so that no allocation of this payment lands between reading them and releasing.
+	if err := lockTransactionForUpdate(ctx, derefString(payment.GetId())); err != nil {
+		return err
+	}
+
+	allocations, err := findAllocations(ctx,
+		models.PaymentAllocationFieldTransactionId, derefString(payment.GetId()))
+	if err != nil {
+		return err
+	}
+
+	retained := derefDecimal(payment.GetAmount()).Sub(refundedTotal)
+	releases := planRefundRelease(allocations, retained)
+
+	invoiceIds := []string{}
+	seen := map[string]bool{}
+	for _, release := range releases {
+		if err := releaseFromAllocation(ctx, release.Allocation, release.Amount); err != nil {
+			return err
+		}
+		invoiceId := derefString(release.Allocation.GetInvoiceId())
+		if !seen[invoiceId] {
+			seen[invoiceId] = true
+			invoiceIds = append(invoiceIds, invoiceId)
+		}
+	}
+
+	for _, invoiceId := range invoiceIds {
+		if _, err := resettleInvoice(ctx, invoiceId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocationRelease is how much to release from one allocation.
+type allocationRelease struct {
+	Allocation models.PaymentAllocation
+	Amount     decimal.Decimal
+}
+
+// planRefundRelease decides what to release so that a payment's allocations come to no more than
+// retained, the part of it the business has kept.
+//
+// The allocations are taken in the order given, which is newest first: the invoices settled last
+// are the ones re-opened, leaving the older settlements, which are more likely to have been acted
+// on, as they were.
+func planRefundRelease(
+	allocations []*models.PaymentAllocation, retained decimal.Decimal,
+) []allocationRelease {
+	if retained.IsNegative() {
+		retained = decimal.Zero
+	}
+
+	allocated := decimal.Zero
+	for _, allocation := range allocations {
+		allocated = allocated.Add(effectiveAllocationAmount(*allocation))
+	}
+
+	excess := allocated.Sub(retained)
+	releases := []allocationRelease{}
+	for _, allocation := range allocations {
+		if !excess.IsPositive() {
+			break
+		}
+		amount := decimal.Min(effectiveAllocationAmount(*allocation), excess)
+		if !amount.IsPositive() {
+			continue
+		}
+		releases = append(releases, allocationRelease{Allocation: *allocation, Amount: amount})
+		excess = excess.Sub(amount)
+	}
+	return releases
+}
+
+// assertAllocationCommand checks what can be checked without reading anything.
+func assertAllocationCommand(cmd AllocateCommand, vErrs *ft.ClientErrors) bool {
+	if cmd.InvoiceId == "" {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldInvoiceId,
+			"paymentinvoice.invoice_required", "no invoice was identified")
+		return false
+	}
+	if !cmd.Amount.IsPositive() {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldAmount,
+			"paymentinvoice.amount_not_positive", "the allocated amount must be greater than zero")
+		return false
+	}
+
+	if cmd.TransactionId != "" {
+		// A payment is a gateway payment by definition; a caller naming another source alongside
+		// it has described the money two ways, and neither can be chosen for them.
+		if cmd.Source != "" && cmd.Source != models.PaymentAllocationSourceGateway {
+			appendFieldViolation(vErrs, models.PaymentAllocationFieldSource,
+				"paymentinvoice.allocation_source_conflict",
+				"a gateway payment cannot also be a '"+cmd.Source+"' receipt")
+			return false
+		}
+		return true
+	}
+
+	if cmd.Source != models.PaymentAllocationSourceBankTransfer &&
+		cmd.Source != models.PaymentAllocationSourceCash {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldSource,
+			"paymentinvoice.allocation_source_required",
+			"a receipt recorded by hand must say whether it was a bank transfer or cash")
+		return false
+	}
+	return true
+}
+
+// assertInvoiceAllocatable refuses an invoice that is not owed money, and an amount larger than what
+// it is owed.
+//
+// Over-allocating is refused rather than capped: the surplus is money that belongs somewhere, and
+// quietly dropping it would leave the payment looking partly unaccounted for.
+func assertInvoiceAllocatable(invoice models.Invoice, amount decimal.Decimal, vErrs *ft.ClientErrors) bool {
+	switch status := derefString(invoice.GetStatus()); status {
+	case models.InvoiceStatusIssued:
+	case models.InvoiceStatusPaid:
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldInvoiceId,
+			"paymentinvoice.invoice_already_paid", "this invoice has already been paid in full")
+		return false
+	default:
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldInvoiceId,
+			"paymentinvoice.invoice_not_issued",
+			"only an issued invoice can be paid; this invoice is '"+status+"'")
+		return false
+	}
+
+	due := derefDecimal(invoice.GetAmountDue())
+	if amount.GreaterThan(due) {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldAmount,
+			"paymentinvoice.allocation_exceeds_due",
+			"allocating "+amount.String()+" would pay more than the "+due.String()+" still due")
+		return false
+	}
+	return true
+}
+
+// assertTransactionAllocatable refuses a transaction that is not money received in the invoice's
+// currency.
+//
+// The currency is compared rather than converted: an allocation says how much of the invoice a
+// payment settled, and a conversion rate chosen here would be an accounting decision this module
+// has no business making.
+func assertTransactionAllocatable(
+	transaction models.Transaction, invoice models.Invoice, vErrs *ft.ClientErrors,
+) bool {
+	if derefString(transaction.GetTransactionType()) != models.TransactionTypePayment {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldTransactionId,
+			"paymentinvoice.transaction_not_payment", "only a payment can settle an invoice")
+		return false
+	}
+	if status := derefString(transaction.GetStatus()); status != models.TransactionStatusCompleted {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldTransactionId,
+			"paymentinvoice.transaction_not_completed",
+			"only a completed payment can settle an invoice; this one is '"+status+"'")
+		return false
+	}
+	if derefString(transaction.GetCurrencyId()) != derefString(invoice.GetCurrencyId()) {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldTransactionId,
+			"paymentinvoice.transaction_currency_mismatch",
+			"the payment is not in the invoice's currency")
+		return false
+	}
+	return true
+}
+
+// assertPaymentAvailable checks that the payment has enough left, after its refunds and its other
+// allocations, to cover the amount.
+func assertPaymentAvailable(
+	ctx corectx.Context, invoice models.Invoice, cmd AllocateCommand, vErrs *ft.ClientErrors,
+) error {
+	transaction, err := findTransactionById(ctx, cmd.TransactionId)
+	if err != nil {
+		return err
+	}
+	if transaction == nil {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldTransactionId,
+			"paymentinvoice.transaction_not_found", "no transaction with id '"+cmd.TransactionId+"'")
+		return nil
+	}
+	if !assertTransactionAllocatable(*transaction, invoice, vErrs) {
+		return nil
+	}
+
+	order, err := findOrderById(ctx, derefString(transaction.GetOrderId()))
+	if err != nil {
+		return err
+	}
+	refunded := decimal.Zero
+	if order != nil {
+		refunded = derefDecimal(order.GetRefundAmount())
+	}
+
+	allocations, err := findAllocations(ctx, models.PaymentAllocationFieldTransactionId, cmd.TransactionId)
+	if err != nil {
+		return err
+	}
+	available := unallocatedPaymentAmount(derefDecimal(transaction.GetAmount()), refunded, allocations)
+
+	if cmd.Amount.GreaterThan(available) {
+		appendFieldViolation(vErrs, models.PaymentAllocationFieldAmount,
+			"paymentinvoice.allocation_exceeds_payment",
+			"allocating "+cmd.Amount.String()+" would use more of the payment than the "+
+				decimal.Max(available, decimal.Zero).String()+" left of it")
+	}
+	return nil
+}
+
+// unallocatedPaymentAmount is what is left of a payment once its refunds and the allocations still
+// counting against it are taken off. It goes negative when a refund has outrun the allocations not
+// yet released, and then covers nothing.
+func unallocatedPaymentAmount(
+	amount decimal.Decimal, refunded decimal.Decimal, allocations []*models.PaymentAllocation,
+) decimal.Decimal {
+	available := amount.Sub(refunded)
+	for _, allocation := range allocations {
+		available = available.Sub(effectiveAllocationAmount(*allocation))
+	}
+	return available
+}
+
+// settleInvoice recomputes the invoice's amount_paid and amount_due from its allocations and its
+// amount_credited, and moves it between issued and paid to match.
+func settleInvoice(
+	ctx corectx.Context, invoice models.Invoice, allocations []*models.PaymentAllocation,
+) (*AllocationResult, error) {
+	paid, due := invoicePaymentTotals(
+		derefDecimal(invoice.GetTotalAmount()), derefDecimal(invoice.GetAmountCredited()), allocations)
+	status := nextInvoiceStatus(derefString(invoice.GetStatus()), due)
+
+	invoiceId := derefString(invoice.GetId())
+	if err := writeInvoiceFields(ctx, invoiceId, dmodel.DynamicFields{
+		models.InvoiceFieldAmountPaid:     paid,
+		models.InvoiceFieldAmountCredited: derefDecimal(invoice.GetAmountCredited()),
+		models.InvoiceFieldAmountDue:      due,
+		models.InvoiceFieldStatus:         status,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &AllocationResult{
+		InvoiceId:     invoiceId,
+		InvoiceStatus: status,
+		AmountPaid:    paid,
+		AmountDue:     due,
+	}, nil
+}
+
+// resettleInvoice locks an invoice, re-reads it and its allocations, then settles it.
+func resettleInvoice(ctx corectx.Context, invoiceId string) (*AllocationResult, error) {
+	if err := lockInvoiceForUpdate(ctx, invoiceId); err != nil {
+		return nil, err
+	}
+	invoice, err := findInvoiceById(ctx, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, errors.Errorf("resettleInvoice: allocated invoice '%s' does not exist", invoiceId)
+	}
+
+	allocations, err := findAllocations(ctx, models.PaymentAllocationFieldInvoiceId, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	return settleInvoice(ctx, *invoice, allocations)
+}
+
+// invoicePaymentTotals is what the allocations come to, and what remains of the total once the
+// credit notes and the allocations are taken off it.
+//
+// amount_due does not go below zero. Allocate refuses to over-pay, so a negative would only come
+// from data written around it, and showing the invoice as owing a negative amount would invite a
+// refund nobody has checked.
+func invoicePaymentTotals(
+	total decimal.Decimal, credited decimal.Decimal, allocations []*models.PaymentAllocation,
+) (paid decimal.Decimal, due decimal.Decimal) {
+	paid = decimal.Zero
+	for _, allocation := range allocations {
+		paid = paid.Add(effectiveAllocationAmount(*allocation))
+	}
+	due = decimal.Max(total.Sub(credited).Sub(paid), decimal.Zero)
+	return paid, due
+}
+
+// nextInvoiceStatus moves an issued invoice to paid once nothing is due, and a paid one back to
+// issued once something is again. A draft or a void invoice keeps its status: neither is owed
+// anything.
+func nextInvoiceStatus(status string, due decimal.Decimal) string {
+	switch {
+	case status == models.InvoiceStatusIssued && !due.IsPositive():
+		return models.InvoiceStatusPaid
+	case status == models.InvoiceStatusPaid && due.IsPositive():
+		return models.InvoiceStatusIssued
+	}
+	return status
+}
+
+// effectiveAllocationAmount is the part of an allocation that still counts against its invoice.
+func effectiveAllocationAmount(allocation models.PaymentAllocation) decimal.Decimal {
+	return derefDecimal(allocation.GetAmount()).Sub(derefDecimal(allocation.GetReleasedAmount()))
+}
+
+// releaseFromAllocation raises an allocation's released_amount by amount.
+func releaseFromAllocation(
+	ctx corectx.Context, allocation models.PaymentAllocation, amount decimal.Decimal,
+) error {
+	engine, err := engineFor(models.PaymentAllocationSchemaName)
+	if err != nil {
+		return err
+	}
+
+	_, err = engine.ResourceRepository().Update(ctx, dmodel.DynamicFields{
+		models.PaymentAllocationFieldId: derefString(allocation.GetId()),
+		models.PaymentAllocationFieldReleasedAmount: derefDecimal(
+			allocation.GetReleasedAmount()).Add(amount),
+		models.PaymentAllocationFieldReleasedAt: time.Now().UTC(),
+	})
+	return errors.Wrap(err, "releaseFromAllocation")
+}
+
+// findAllocationById fetches one allocation by primary key.
+func findAllocationById(ctx corectx.Context, allocationId string) (*models.PaymentAllocation, error) {
+	engine, err := engineFor(models.PaymentAllocationSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.PaymentAllocationFieldId: allocationId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findAllocationById")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return models.NewPaymentAllocationFrom(found.Data), nil
+}
+
+// findAllocations returns the allocations whose field equals value, newest first.
+//
+// A full page is an error rather than a partial answer: Allocate keeps an invoice below the bound,
+// and totalling only part of a payment's allocations would release or settle the wrong amount.
+func findAllocations(
+	ctx corectx.Context, field string, value string,
+) ([]*models.PaymentAllocation, error) {
+	engine, err := engineFor(models.PaymentAllocationSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(*dmodel.NewSearchNode().NewCondition(field, dmodel.Equals, value))
+	graph.OrderBy(basemodel.FieldCreatedAt, dmodel.Desc)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  allocationPageSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findAllocations")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	if len(found.Data.Items) >= allocationPageSize {
+		return nil, errors.Errorf(
+			"findAllocations: more than %d allocations have %s '%s'", allocationPageSize-1, field, value)
+	}
+
+	allocations := make([]*models.PaymentAllocation, 0, len(found.Data.Items))
+	for _, item := range found.Data.Items {
+		allocations = append(allocations, models.NewPaymentAllocationFrom(item))
+	}
+	return allocations, nil
+}
+
+// findTransactionById fetches one transaction by primary key.
+func findTransactionById(ctx corectx.Context, transactionId string) (*models.Transaction, error) {
+	engine, err := engineFor(models.TransactionSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.TransactionFieldId: transactionId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findTransactionById")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return models.NewTransactionFrom(found.Data), nil
+}
+
+// findOrderById fetches one order by primary key.
+func findOrderById(ctx corectx.Context, orderPk string) (*models.Order, error) {
+	engine, err := engineFor(models.OrderSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.OrderFieldId: orderPk,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findOrderById")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return models.NewOrderFrom(found.Data), nil
+}
+
+// findCompletedPayment returns the order's settled payment transaction, if it has one.
+func findCompletedPayment(ctx corectx.Context, orderPk string) (*models.Transaction, error) {
+	engine, err := engineFor(models.TransactionSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.TransactionFieldOrderId, dmodel.Equals, orderPk),
+		*dmodel.NewSearchNode().NewCondition(
+			models.TransactionFieldTransactionType, dmodel.Equals, models.TransactionTypePayment),
+		*dmodel.NewSearchNode().NewCondition(
+			models.TransactionFieldStatus, dmodel.Equals, models.TransactionStatusCompleted),
+	)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  1,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findCompletedPayment")
+	}
+	if found == nil || !found.HasData || len(found.Data.Items) == 0 {
+		return nil, nil
+	}
+	return models.NewTransactionFrom(found.Data.Items[0]), nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// What an invoice is owed, and whether it is paid, is decided by these rules alone. A mistake in
+// them marks an invoice settled that nobody paid, or chases a customer for money already received,
+// so each is pinned here without a database.
+
+func allocationOf(id string, amount string, released string) *models.PaymentAllocation {
+	return models.NewPaymentAllocationFrom(dmodel.DynamicFields{
+		models.PaymentAllocationFieldId:             id,
+		models.PaymentAllocationFieldInvoiceId:      "01INVOICE000000000000000000",
+		models.PaymentAllocationFieldAmount:         decimal.RequireFromString(amount),
+		models.PaymentAllocationFieldReleasedAmount: decimal.RequireFromString(released),
+	})
+}
+
+func invoiceWith(status string, due string) models.Invoice {
+	return *models.NewInvoiceFrom(dmodel.DynamicFields{
+		models.InvoiceFieldId:         "01INVOICE000000000000000000",
+		models.InvoiceFieldStatus:     status,
+		models.InvoiceFieldCurrencyId: "01CURRENCY00000000000000000",
+		models.InvoiceFieldAmountDue:  decimal.RequireFromString(due),
+	})
+}
+
+func TestPartialAllocationsLeaveTheRemainderDue(t *testing.T) {
+	paid, due := invoicePaymentTotals(decimal.RequireFromString("1000"), decimal.Zero, []*models.PaymentAllocation{
+		allocationOf("a", "300", "0"),
+		allocationOf("b", "200", "0"),
+	})
+
+	assert.Equal(t, "500", paid.String())
+	assert.Equal(t, "500", due.String())
+}
+
+// A released part no longer counts: the invoice is owed it again.
+func TestReleasedAmountsDoNotCountAsPaid(t *testing.T) {
+	paid, due := invoicePaymentTotals(decimal.RequireFromString("1000"), decimal.Zero, []*models.PaymentAllocation{
+		allocationOf("a", "1000", "400"),
+	})
+
+	assert.Equal(t, "600", paid.String())
+	assert.Equal(t, "400", due.String())
+}
+
+func TestAnInvoiceBecomesPaidOnlyWhenNothingIsDue(t *testing.T) {
+	assert.Equal(t, models.InvoiceStatusPaid,
+		nextInvoiceStatus(models.InvoiceStatusIssued, decimal.Zero))
+	assert.Equal(t, models.InvoiceStatusIssued,
+		nextInvoiceStatus(models.InvoiceStatusIssued, decimal.RequireFromString("0.01")))
+}
+
+// A refund after the invoice was settled re-opens it: it is owed again.
+func TestAPaidInvoiceOwedAgainIsReopened(t *testing.T) {
+	assert.Equal(t, models.InvoiceStatusIssued,
+		nextInvoiceStatus(models.InvoiceStatusPaid, decimal.RequireFromString("50")))
+}
+
+// Neither a draft nor a void invoice is owed anything, so no allocation arithmetic may move them.
+func TestDraftAndVoidInvoicesKeepTheirStatus(t *testing.T) {
+	for _, status := range []string{models.InvoiceStatusDraft, models.InvoiceStatusVoid} {
+		assert.Equal(t, status, nextInvoiceStatus(status, decimal.Zero))
+		assert.Equal(t, status, nextInvoiceStatus(status, decimal.RequireFromString("10")))
+	}
+}
+
+func TestOnlyAnIssuedInvoiceTakesAnAllocation(t *testing.T) {
+	for status, allowed := range map[string]bool{
+		models.InvoiceStatusDraft:  false,
+		models.InvoiceStatusIssued: true,
+		models.InvoiceStatusPaid:   false,
+		models.InvoiceStatusVoid:   false,
+	} {
+		vErrs := ft.NewClientErrors()
+		ok := assertInvoiceAllocatable(invoiceWith(status, "100"), decimal.RequireFromString("10"), vErrs)
+		assert.Equal(t, allowed, ok, status)
+	}
+}
+
+// Over-allocating is refused rather than capped, so the surplus is not silently dropped.
+func TestAnAllocationLargerThanWhatIsDueIsRefused(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+
+	ok := assertInvoiceAllocatable(invoiceWith(models.InvoiceStatusIssued, "100"),
+		decimal.RequireFromString("100.01"), vErrs)
+
+	assert.False(t, ok)
+	assert.Equal(t, 1, vErrs.Count())
+}
+
+// The payment side of the same guard: what is left of a payment after its refunds and its other
+// allocations is all it can still settle. Allocate reads this under the payment's row lock, so the
+// second of two allocations racing for the same money sees what the first took.
+func TestAnAllocationLargerThanWhatIsLeftOfThePaymentIsRefused(t *testing.T) {
+	amount, refunded := decimal.RequireFromString("100"), decimal.RequireFromString("20")
+	earlier := []*models.PaymentAllocation{allocationOf("a", "60", "10")}
+
+	available := unallocatedPaymentAmount(amount, refunded, earlier)
+	assert.Equal(t, "30", available.String())
+
+	afterFirst := unallocatedPaymentAmount(amount, refunded, append(earlier, allocationOf("b", "30", "0")))
+	assert.True(t, afterFirst.IsZero(), "a second allocation, read after the first, finds nothing left")
+}
+
+// A refund that outruns the allocations leaves the payment covering nothing, rather than the
+// negative remainder being read as room.
+func TestARefundedPaymentHasNothingLeftToAllocate(t *testing.T) {
+	available := unallocatedPaymentAmount(decimal.RequireFromString("100"), decimal.RequireFromString("100"),
+		[]*models.PaymentAllocation{allocationOf("a", "40", "0")})
+
+	assert.True(t, available.IsNegative())
+}
+
+func TestAManualReceiptMustSayHowItArrived(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+
+	ok := assertAllocationCommand(AllocateCommand{
+		InvoiceId: "01INVOICE000000000000000000",
+		Amount:    decimal.RequireFromString("10"),
+	}, vErrs)
+
+	assert.False(t, ok)
+
+	for _, source := range []string{
+		models.PaymentAllocationSourceBankTransfer, models.PaymentAllocationSourceCash,
+	} {
+		assert.True(t, assertAllocationCommand(AllocateCommand{
+			InvoiceId: "01INVOICE000000000000000000",
+			Source:    source,
+			Amount:    decimal.RequireFromString("10"),
+		}, ft.NewClientErrors()), source)
+	}
+}
+
+func TestAGatewayPaymentCannotAlsoBeAManualReceipt(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+
+	ok := assertAllocationCommand(AllocateCommand{
+		InvoiceId:     "01INVOICE000000000000000000",
+		TransactionId: "01TRANSACTION00000000000000",
+		Source:        models.PaymentAllocationSourceCash,
+		Amount:        decimal.RequireFromString("10"),
+	}, vErrs)
+
+	assert.False(t, ok)
+}
+
+func TestANonPositiveAllocationIsRefused(t *testing.T) {
+	for _, amount := range []string{"0", "-5"} {
+		ok := assertAllocationCommand(AllocateCommand{
+			InvoiceId: "01INVOICE000000000000000000",
+			Source:    models.PaymentAllocationSourceCash,
+			Amount:    decimal.RequireFromString(amount),
+		}, ft.NewClientErrors())
+		assert.False(t, ok, amount)
+	}
+}
+
+// Only money actually received in the invoice's currency can settle it.
+func TestOnlyACompletedPaymentInTheInvoiceCurrencyIsAllocatable(t *testing.T) {
+	invoice := invoiceWith(models.InvoiceStatusIssued, "100")
+	transactionWith := func(kind string, status string, currencyId string) models.Transaction {
+		return *models.NewTransactionFrom(dmodel.DynamicFields{
+			models.TransactionFieldTransactionType: kind,
+			models.TransactionFieldStatus:          status,
+			models.TransactionFieldCurrencyId:      currencyId,
+		})
+	}
+	currencyId := "01CURRENCY00000000000000000"
+
+	assert.True(t, assertTransactionAllocatable(transactionWith(
+		models.TransactionTypePayment, models.TransactionStatusCompleted, currencyId),
+		invoice, ft.NewClientErrors()))
+	assert.False(t, assertTransactionAllocatable(transactionWith(
+		models.TransactionTypeRefund, models.TransactionStatusCompleted, currencyId),
+		invoice, ft.NewClientErrors()))
+	assert.False(t, assertTransactionAllocatable(transactionWith(
+		models.TransactionTypePayment, models.TransactionStatusPending, currencyId),
+		invoice, ft.NewClientErrors()))
+	assert.False(t, assertTransactionAllocatable(transactionWith(
+		models.TransactionTypePayment, models.TransactionStatusCompleted, "01OTHERCURRENCY000000000000"),
+		invoice, ft.NewClientErrors()))
+}
+
+// A partial refund releases only what the payment no longer covers, newest allocation first.
+func TestARefundReleasesOnlyTheExcessNewestFirst(t *testing.T) {
+	allocations := []*models.PaymentAllocation{
+		allocationOf("newest", "300", "0"),
+		allocationOf("older", "500", "0"),
+	}
+
+	// 800 allocated from a payment of which 600 is retained: 200 must go back.
+	releases := planRefundRelease(allocations, decimal.RequireFromString("600"))
+
+	require.Len(t, releases, 1)
+	assert.Equal(t, "newest", derefString(releases[0].Allocation.GetId()))
+	assert.Equal(t, "200", releases[0].Amount.String())
+}
+
+func TestARefundLargerThanTheNewestAllocationReachesTheNext(t *testing.T) {
+	allocations := []*models.PaymentAllocation{
+		allocationOf("newest", "300", "100"),
+		allocationOf("older", "500", "0"),
+	}
+
+	// 700 still counts; 250 is retained, so 450 goes back: 200 from the newest, 250 from the next.
+	releases := planRefundRelease(allocations, decimal.RequireFromString("250"))
+
+	require.Len(t, releases, 2)
+	assert.Equal(t, "200", releases[0].Amount.String())
+	assert.Equal(t, "250", releases[1].Amount.String())
+}
+
+// A refund of money that was never allocated leaves the invoices alone.
+func TestARefundOfUnallocatedMoneyReleasesNothing(t *testing.T) {
+	allocations := []*models.PaymentAllocation{allocationOf("only", "300", "0")}
+
+	assert.Empty(t, planRefundRelease(allocations, decimal.RequireFromString("300")))
+	assert.Empty(t, planRefundRelease(allocations, decimal.RequireFromString("1000")))
+}
+
+func TestAFullRefundReleasesEverything(t *testing.T) {
+	allocations := []*models.PaymentAllocation{
+		allocationOf("newest", "300", "0"),
+		allocationOf("older", "500", "0"),
+	}
+
+	releases := planRefundRelease(allocations, decimal.RequireFromString("-10"))
+
+	require.Len(t, releases, 2)
+	assert.Equal(t, "300", releases[0].Amount.String())
+	assert.Equal(t, "500", releases[1].Amount.String())
+}
@@ -12,13 +12,21 @@ import (
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
 )
 
-// InvoiceDomainService closes invoice drafts.
-type InvoiceDomainService struct{}
+// InvoiceDomainService closes invoice drafts and keeps track of what has been paid against them.
+//
+// It holds the order service because a credit note against an invoice paid online gives the money
+// back through the gateway that took it, which is the order service's to do. It holds the tax port
+// because issuing prices the lines that name taxes.
+type InvoiceDomainService struct {
+	orders *OrderDomainService
+	taxes  itExt.TaxExtService
+}
 
-func NewInvoiceDomainService() *InvoiceDomainService {
-	return &InvoiceDomainService{}
+func NewInvoiceDomainService(orders *OrderDomainService, taxes itExt.TaxExtService) *InvoiceDomainService {
+	return &InvoiceDomainService{orders: orders, taxes: taxes}
 }
 
 // IssueCommand asks for a draft to be closed. It carries only the invoice, because everything the
@@ -36,6 +44,9 @@ type IssueResult struct {
 	SubtotalAmount decimal.Decimal
 	TaxAmount      decimal.Decimal
 	TotalAmount    decimal.Decimal
+
+	// TaxBreakdown is what each named tax came to. It is empty when no line named one.
+	TaxBreakdown []itExt.TaxBreakdown
 }
 
 // invoiceLinePageSize bounds how many lines one invoice may carry.
@@ -69,6 +80,9 @@ func (this *InvoiceDomainService) Issue(
 
 	var result *IssueResult
 	err := withInvoiceTransaction(ctx, func(tranxCtx corectx.Context) error {
+		if err := lockInvoiceForUpdate(tranxCtx, cmd.InvoiceId); err != nil {
+			return err
+		}
 		invoice, err := findInvoiceById(tranxCtx, cmd.InvoiceId)
 		if err != nil {
 			return err
@@ -79,8 +93,8 @@ func (this *InvoiceDomainService) Issue(
 			return nil
 		}
 
-		// The status is re-read inside the transaction rather than trusted from a prior read, so
-		// two callers issuing the same draft at once cannot both pass this check.
+		// The status is read under the invoice's lock rather than trusted from a prior read, so
+		// of two callers issuing the same draft at once the second finds it already issued.
 		if status := derefString(invoice.GetStatus()); status != models.InvoiceStatusDraft {
 			appendFieldViolation(vErrs, models.InvoiceFieldStatus,
 				"paymentinvoice.invoice_not_draft",
@@ -88,10 +102,11 @@ func (this *InvoiceDomainService) Issue(
 			return nil
 		}
 
-		totals, err := this.recomputeLines(tranxCtx, cmd.InvoiceId, vErrs)
+		computed, err := this.recomputeLines(tranxCtx, invoice, vErrs)
 		if err != nil || vErrs.Count() > 0 {
 			return err
 		}
+		totals := computed.Totals
 
 		issuedAt := time.Now().UTC()
 		number, err := allocateInvoiceNumber(tranxCtx, issuedAt.Year())
@@ -105,7 +120,11 @@ func (this *InvoiceDomainService) Issue(
 			models.InvoiceFieldIssuedAt:       issuedAt,
 			models.InvoiceFieldSubtotalAmount: totals.Subtotal,
 			models.InvoiceFieldTaxAmount:      totals.Tax,
+			models.InvoiceFieldTaxBreakdown:   encodeTaxBreakdown(computed.Breakdown),
 			models.InvoiceFieldTotalAmount:    totals.Total,
+			models.InvoiceFieldAmountPaid:     decimal.Zero,
+			models.InvoiceFieldAmountCredited: decimal.Zero,
+			models.InvoiceFieldAmountDue:      totals.Total,
 		}); err != nil {
 			return err
 		}
@@ -117,6 +136,7 @@ func (this *InvoiceDomainService) Issue(
 			SubtotalAmount: totals.Subtotal,
 			TaxAmount:      totals.Tax,
 			TotalAmount:    totals.Total,
+			TaxBreakdown:   computed.Breakdown,
 		}
 		return nil
 	})
@@ -134,20 +154,21 @@ type invoiceTotals struct {
 	Total    decimal.Decimal
 }
 
-// recomputeLines rewrites each line's amount and returns what the invoice comes to.
+// recomputeLines rewrites each line's amount and tax and returns what the invoice comes to.
 //
-// Each line's amount is recomputed as quantity × unit_price rather than trusted, for the same
+// Each line's amount is recomputed from its quantity and price rather than trusted, for the same
 // reason the invoice totals are: the quantity and the price are what a reader can check, and a
 // stored amount that disagrees with them is the field that is wrong.
 //
 // Tax is accumulated per line, not applied to the subtotal, because lines may carry different
-// rates — a single rate over the subtotal would silently be wrong for any invoice that mixes them.
+// taxes — a single rate over the subtotal would silently be wrong for any invoice that mixes them.
 func (this *InvoiceDomainService) recomputeLines(
-	ctx corectx.Context, invoiceId string, vErrs *ft.ClientErrors,
-) (invoiceTotals, error) {
+	ctx corectx.Context, invoice *models.Invoice, vErrs *ft.ClientErrors,
+) (*invoiceComputation, error) {
+	invoiceId := derefString(invoice.GetId())
 	lines, err := findInvoiceLines(ctx, invoiceId)
 	if err != nil {
-		return invoiceTotals{}, err
+		return nil, err
 	}
 
 	if len(lines) == 0 {
@@ -155,71 +176,73 @@ func (this *InvoiceDomainService) recomputeLines(
 		appendFieldViolation(vErrs, models.InvoiceFieldId,
 			"paymentinvoice.invoice_has_no_lines",
 			"an invoice must have at least one line before it can be issued")
-		return invoiceTotals{}, nil
+		return nil, nil
 	}
 	if len(lines) >= invoiceLinePageSize {
 		appendFieldViolation(vErrs, models.InvoiceFieldId,
 			"paymentinvoice.invoice_too_many_lines",
 			fmt.Sprintf("an invoice may carry at most %d lines", invoiceLinePageSize-1))
-		return invoiceTotals{}, nil
+		return nil, nil
 	}
 
-	totals := invoiceTotals{
-		Subtotal: decimal.Zero,
-		Tax:      decimal.Zero,
+	computed, err := computeInvoiceLines(ctx, this.taxes, derefString(invoice.GetCurrencyId()), lines, vErrs)
+	if err != nil || computed == nil {
+		return nil, err
 	}
 
-	for _, line := range lines {
-		quantity := int64(0)
-		if q := line.GetQuantity(); q != nil {
-			quantity = int64(*q)
-		}
-		unitPrice := derefDecimal(line.GetUnitPrice())
-
-		amount := unitPrice.Mul(decimal.NewFromInt(quantity))
-		lineTax := amount.Mul(derefDecimal(line.GetTaxRatePercent())).Div(decimal.NewFromInt(100))
+	for index, line := range lines {
+		amount := computed.Lines[index].Amount
+		tax := computed.Lines[index].Tax
 
-		totals.Subtotal = totals.Subtotal.Add(amount)
-		totals.Tax = totals.Tax.Add(lineTax)
-
-		// The line is written back only when the stored amount disagrees, so issuing an invoice
+		// The line is written back only when what is stored disagrees, so issuing an invoice
 		// whose lines are already correct does not touch every row.
-		if !amount.Equal(derefDecimal(line.GetAmount())) {
-			if err := writeInvoiceLineFields(ctx, derefString(line.GetId()), dmodel.DynamicFields{
-				models.InvoiceLineFieldAmount: amount,
-			}); err != nil {
-				return invoiceTotals{}, err
-			}
+		if amount.Equal(derefDecimal(line.GetAmount())) && tax.Equal(derefDecimal(line.GetTaxAmount())) {
+			continue
+		}
+		if err := writeInvoiceLineFields(ctx, derefString(line.GetId()), dmodel.DynamicFields{
+			models.InvoiceLineFieldAmount:    amount,
+			models.InvoiceLineFieldTaxAmount: tax,
+		}); err != nil {
+			return nil, err
 		}
 	}
-
-	totals.Total = totals.Subtotal.Add(totals.Tax)
-	return totals, nil
+	return computed, nil
 }
 
 // allocateInvoiceNumber mints the next number for the given year.
-//
-// The sequence is per-year and derived by counting the invoices already issued in it. That is
-// sound only because this runs inside the issue transaction: the count and the write that follows
-// it are one atomic step, so two invoices issued at the same instant cannot read the same count.
-// The unique index on number is the backstop if that assumption ever breaks — a collision fails
-// the transaction rather than producing two documents with one number.
 func allocateInvoiceNumber(ctx corectx.Context, year int) (string, error) {
-	engine, err := engineFor(models.InvoiceSchemaName)
+	return allocateDocumentNumber(ctx, models.InvoiceSchemaName, models.InvoiceFieldNumber,
+		fmt.Sprintf("INV-%d-", year))
+}
+
+// allocateDocumentNumber mints the next number under prefix for one kind of document, following
+// the highest already taken. The prefix carries the year, so each kind of document has one sequence
+// per year.
+//
+// Reading the highest number inside the issuing transaction does not by itself keep two issues
+// apart: both can read it before either has written. The sequence is therefore locked first, and
+// held until the caller's transaction ends, so the second issue reads the number the first
+// committed. The unique index on number stays as the backstop; a collision fails the transaction
+// rather than putting two documents under one number.
+func allocateDocumentNumber(
+	ctx corectx.Context, schemaName string, numberField string, prefix string,
+) (string, error) {
+	if err := lockDocumentSequence(ctx, schemaName, prefix); err != nil {
+		return "", err
+	}
+	engine, err := engineFor(schemaName)
 	if err != nil {
 		return "", err
 	}
 
-	prefix := fmt.Sprintf("INV-%d-", year)
-
 	graph := &dmodel.SearchGraph{}
 	graph.And(
-		*dmodel.NewSearchNode().NewCondition(models.InvoiceFieldNumber, dmodel.StartsWith, prefix),
+		*dmodel.NewSearchNode().NewCondition(numberField, dmodel.StartsWith, prefix),
 	)
 	// Highest number first: the next one follows the largest already taken, so a gap left by a
-	// deleted invoice is not re-used. Re-using a number would put two documents in the record
+	// deleted document is not re-used. Re-using a number would put two documents in the record
 	// under one identity.
-	graph.OrderBy(models.InvoiceFieldNumber, dmodel.Desc)
+	graph.OrderBy(numberField, dmodel.Desc)
 
 	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
 		Graph: graph,
@@ -227,12 +250,12 @@ func allocateInvoiceNumber(ctx corectx.Context, year int) (string, error) {
 		Size:  1,
 	})
 	if err != nil {
-		return "", errors.Wrap(err, "allocateInvoiceNumber")
+		return "", errors.Wrapf(err, "allocateDocumentNumber(%s)", schemaName)
 	}
 
 	next := 1
 	if found != nil && found.HasData && len(found.Data.Items) > 0 {
-		highest := derefString(models.NewInvoiceFrom(found.Data.Items[0]).GetNumber())
+		highest, _ := found.Data.Items[0][numberField].(string)
 		if sequence, ok := sequenceOfInvoiceNumber(highest, prefix); ok {
 			next = sequence + 1
 		}
@@ -0,0 +1,101 @@
+// This file holds the locks money is counted and documents are numbered under. Like inventory's stock_quant_lock.go, it
+// is raw SQL by necessity rather than drift: the query builder has no lock clause, so no engine
+// call can produce SELECT ... FOR UPDATE.
+//
+// Re-reading inside a transaction is not enough on its own. Under read committed, two allocations
+// against the same invoice both read the amount still due before either has written, both find it
+// sufficient, and both commit. Only a lock taken before the read makes the second wait for the
+// first and then read what the first left.
+package services
+
+import (
+	"hash/fnv"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// lockInvoiceForUpdate holds the invoice row until the enclosing transaction ends. Anything that
+// reads an invoice's totals to write new ones takes it first.
+func lockInvoiceForUpdate(ctx corectx.Context, invoiceId string) error {
+	return lockRowForUpdate(ctx, models.InvoiceSchemaName, invoiceId)
+}
+
+// lockTransactionForUpdate holds a payment's row until the enclosing transaction ends, which
+// serializes everything that counts its unallocated remainder.
+//
+// Where both are needed the payment is locked before the invoice, in every caller, so two of them
+// wait on each other rather than deadlock.
+func lockTransactionForUpdate(ctx corectx.Context, transactionId string) error {
+	return lockRowForUpdate(ctx, models.TransactionSchemaName, transactionId)
+}
+
+// lockRowForUpdate takes a row lock on one record by primary key. A record that does not exist
+// locks nothing; the read that follows finds it missing and says so.
+//
+// It refuses to run outside a transaction: on a pooled connection the lock would be released as
+// soon as the statement returned, and the caller would go on as if it held it.
+func lockRowForUpdate(ctx corectx.Context, schemaName string, id string) error {
+	if ctx == nil || ctx.GetDbTranx() == nil {
+		return errors.Errorf("lockRowForUpdate(%s) requires an ambient transaction", schemaName)
+	}
+	engine, err := engineFor(schemaName)
+	if err != nil {
+		return err
+	}
+
+	repo := engine.ResourceRepository().GetBaseRepo()
+	query, args := buildRowLockQuery(repo.Schema(), id)
+	rows, err := repo.ExtractClient(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return errors.Wrapf(err, "lockRowForUpdate(%s)", schemaName)
+	}
+	defer rows.Close()
+	// The rows are drained rather than read: the lock is taken as each row is returned, and the
+	// statement only needs to have reached it.
+	for rows.Next() {
+	}
+	return errors.Wrapf(rows.Err(), "lockRowForUpdate(%s)", schemaName)
+}
+
+// buildRowLockQuery selects one row by primary key under a lock. The table and column come from the
+// schema; the id only ever travels as a bound argument.
+func buildRowLockQuery(schema *dmodel.ModelSchema, id string) (string, []any) {
+	return "SELECT " + basemodel.FieldId + " FROM " + schema.TableName() +
+		" WHERE " + basemodel.FieldId + " = $1 FOR UPDATE", []any{id}
+}
+
+// documentSequenceLockClass is the first key of the advisory locks numbering takes, which keeps
+// them apart from advisory locks taken elsewhere in the database.
+const documentSequenceLockClass int32 = 0x50494e56
+
+// lockDocumentSequence holds one document number sequence until the enclosing transaction ends.
+//
+// A sequence has no row of its own to lock until its first document is written, so it is locked
+// by name with a transaction-scoped advisory lock, which the commit or rollback releases.
+func lockDocumentSequence(ctx corectx.Context, schemaName string, prefix string) error {
+	if ctx == nil || ctx.GetDbTranx() == nil {
+		return errors.Errorf("lockDocumentSequence(%s) requires an ambient transaction", schemaName)
+	}
+	engine, err := engineFor(schemaName)
+	if err != nil {
+		return err
+	}
+
+	err = engine.ResourceRepository().GetBaseRepo().ExecFunc(ctx, "pg_advisory_xact_lock",
+		documentSequenceLockClass, documentSequenceLockKey(schemaName, prefix))
+	return errors.Wrapf(err, "lockDocumentSequence(%s)", schemaName)
+}
+
+// documentSequenceLockKey names one sequence: a kind of document and the prefix, year included,
+// its numbers share. Two sequences that hash alike only wait on each other; neither can take the
+// other's number.
+func documentSequenceLockKey(schemaName string, prefix string) int32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(schemaName + "/" + prefix))
+	return int32(hash.Sum32())
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// Allocations and credit notes count money, and documents are numbered, under these locks. A lock that is not taken, or is
+// released as soon as it is taken, fails silently until two requests collide, so what can be
+// checked without a database is pinned here.
+
+func invoiceSchema(t *testing.T) *dmodel.ModelSchema {
+	t.Helper()
+	// Normally done by CoreModule.RegisterModels during app start-up; a second call only reports
+	// the builders as already registered.
+	_ = basemodel.RegisterJsonBaseSchemas()
+	return models.InvoiceSchemaBuilder().Build()
+}
+
+func TestBuildRowLockQueryLocksOneRowByPrimaryKey(t *testing.T) {
+	schema := invoiceSchema(t)
+
+	query, args := buildRowLockQuery(schema, "01INVOICE000000000000000000")
+
+	assert.Equal(t, "SELECT id FROM "+schema.TableName()+" WHERE id = $1 FOR UPDATE", query)
+	assert.Equal(t, []any{"01INVOICE000000000000000000"}, args, "the id is bound, never inlined")
+}
+
+// Outside a transaction the lock would be gone before the read it is meant to protect.
+func TestLockRowForUpdateRefusesToRunOutsideATransaction(t *testing.T) {
+	ctx := corectx.NewRequestContext(context.Background())
+
+	err := lockInvoiceForUpdate(ctx, "01INVOICE000000000000000000")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires an ambient transaction")
+}
+
+type stubTransaction struct{}
+
+func (stubTransaction) Commit() error   { return nil }
+func (stubTransaction) Rollback() error { return nil }
+
+// stubNumberedEngine holds the numbers already taken by one kind of document, and records in
+// what order the sequence was locked and read.
+type stubNumberedEngine struct {
+	drif.DynamicResourceEngine
+
+	numbers []string
+	calls   []string
+	locks   [][]any
+}
+
+func (this *stubNumberedEngine) ResourceRepository() drif.DynamicResourceRepository {
+	return stubNumberedRepository{engine: this}
+}
+
+type stubNumberedRepository struct {
+	drif.DynamicResourceRepository
+
+	engine *stubNumberedEngine
+}
+
+func (this stubNumberedRepository) GetBaseRepo() dyn.BaseDynamicRepository {
+	return stubNumberedBaseRepository{engine: this.engine}
+}
+
+func (this stubNumberedRepository) Search(
+	_ corectx.Context, _ dyn.RepoSearchParam,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	this.engine.calls = append(this.engine.calls, "search")
+	items := []dmodel.DynamicFields{}
+	if numbers := this.engine.numbers; len(numbers) > 0 {
+		items = append(items, dmodel.DynamicFields{models.CreditNoteFieldNumber: numbers[len(numbers)-1]})
+	}
+	return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+		Data: dyn.PagedResultData[dmodel.DynamicFields]{Items: items, Total: len(items)}, HasData: true,
+	}, nil
+}
+
+type stubNumberedBaseRepository struct {
+	dyn.BaseDynamicRepository
+
+	engine *stubNumberedEngine
+}
+
+func (this stubNumberedBaseRepository) ExecFunc(_ corectx.Context, sqlFuncName string, sqlFuncArgs ...any) error {
+	this.engine.calls = append(this.engine.calls, sqlFuncName)
+	this.engine.locks = append(this.engine.locks, sqlFuncArgs)
+	return nil
+}
+
+func useNumberedEngine(t *testing.T, engine *stubNumberedEngine) {
+	t.Helper()
+	original := engineFor
+	engineFor = func(string) (drif.DynamicResourceEngine, error) { return engine, nil }
+	t.Cleanup(func() { engineFor = original })
+}
+
+func TestAllocateDocumentNumberLocksTheSequenceBeforeReadingIt(t *testing.T) {
+	engine := &stubNumberedEngine{numbers: []string{"CN-2026-000006", "CN-2026-000007"}}
+	useNumberedEngine(t, engine)
+	ctx := corectx.NewRequestContext(context.Background())
+	ctx.SetDbTranx(stubTransaction{})
+
+	number, err := allocateDocumentNumber(ctx, models.CreditNoteSchemaName, models.CreditNoteFieldNumber, "CN-2026-")
+
+	require.NoError(t, err)
+	assert.Equal(t, "CN-2026-000008", number)
+	assert.Equal(t, []string{"pg_advisory_xact_lock", "search"}, engine.calls,
+		"read before the lock, two issues could both follow the same highest number")
+	assert.Equal(t, []any{documentSequenceLockClass,
+		documentSequenceLockKey(models.CreditNoteSchemaName, "CN-2026-")}, engine.locks[0])
+}
+
+func TestAllocateDocumentNumberRefusesToRunOutsideATransaction(t *testing.T) {
+	engine := &stubNumberedEngine{}
+	useNumberedEngine(t, engine)
+
+	_, err := allocateDocumentNumber(corectx.NewRequestContext(context.Background()),
+		models.CreditNoteSchemaName, models.CreditNoteFieldNumber, "CN-2026-")
+
+	require.Error(t, err)
+	assert.Empty(t, engine.calls, "the lock would be released before the number was written")
+}
+
+// Each kind of document and each year is its own sequence, so issuing one does not wait on another.
+func TestDocumentSequenceLockKeysAreOnePerKindAndYear(t *testing.T) {
+	keys := map[int32]string{}
+	for _, sequence := range []struct{ schemaName, prefix string }{
+		{models.InvoiceSchemaName, "INV-2026-"},
+		{models.InvoiceSchemaName, "INV-2027-"},
+		{models.CreditNoteSchemaName, "CN-2026-"},
+		{models.CreditNoteSchemaName, "CN-2027-"},
+	} {
+		key := documentSequenceLockKey(sequence.schemaName, sequence.prefix)
+		assert.NotContains(t, keys, key, sequence.prefix)
+		keys[key] = sequence.prefix
+		assert.Equal(t, key, documentSequenceLockKey(sequence.schemaName, sequence.prefix), "the key is stable")
+	}
+}
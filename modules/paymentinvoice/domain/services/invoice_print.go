@@ -0,0 +1,241 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
+)
+
+// InvoicePrintDomainService describes an invoice to Document and returns the PDF it renders.
+//
+// It is separate from InvoiceDomainService because it is the one invoice service that reaches
+// across a module boundary, and keeping the ports out of that service keeps issuing and crediting
+// testable without them.
+type InvoicePrintDomainService struct {
+	documents  itExt.DocumentExtService
+	currencies itExt.CurrencyExtService
+	taxes      itExt.TaxExtService
+}
+
+func NewInvoicePrintDomainService(
+	documents itExt.DocumentExtService, currencies itExt.CurrencyExtService, taxes itExt.TaxExtService,
+) *InvoicePrintDomainService {
+	return &InvoicePrintDomainService{documents: documents, currencies: currencies, taxes: taxes}
+}
+
+// PrintResult is the rendered invoice.
+type PrintResult struct {
+	FileName string
+	Content  []byte
+}
+
+// RenderPdf renders one invoice.
+//
+// Any invoice the caller can read can be printed, a draft included: a draft is what gets sent for a
+// customer to check before it is issued. It prints under its own title and with its totals worked
+// out from the lines, because the stored ones are frozen only at issue.
+func (this *InvoicePrintDomainService) RenderPdf(
+	ctx corectx.Context, invoiceId string,
+) (*PrintResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+
+	invoice, err := findInvoiceById(ctx, invoiceId)
+	if err != nil {
+		return nil, vErrs, err
+	}
+	if invoice == nil {
+		appendFieldViolation(vErrs, models.InvoiceFieldId,
+			"paymentinvoice.invoice_not_found", "no invoice with id '"+invoiceId+"'")
+		return nil, vErrs, nil
+	}
+
+	lines, err := findInvoiceLines(ctx, invoiceId)
+	if err != nil {
+		return nil, vErrs, err
+	}
+
+	currency, err := this.currencyOf(ctx, invoice)
+	if err != nil {
+		return nil, vErrs, err
+	}
+
+	computed := storedComputation(invoice, lines)
+	if derefString(invoice.GetStatus()) == models.InvoiceStatusDraft {
+		computed, err = computeInvoiceLines(ctx, this.taxes, derefString(invoice.GetCurrencyId()), lines, vErrs)
+		if err != nil || vErrs.Count() > 0 {
+			return nil, vErrs, err
+		}
+	}
+
+	rendered, err := this.documents.RenderPdf(ctx, itExt.RenderPdfQuery{
+		OrgId:        derefString(invoice.GetOrgId()),
+		DocumentType: itExt.DocumentTypeInvoice,
+		Document:     buildInvoiceDocument(invoice, lines, computed, currency),
+	})
+	if err != nil {
+		return nil, vErrs, errors.Wrap(err, "render invoice")
+	}
+	if rendered.ClientErrors.Count() > 0 {
+		return nil, &rendered.ClientErrors, nil
+	}
+
+	return &PrintResult{FileName: rendered.Data.FileName, Content: rendered.Data.Content}, vErrs, nil
+}
+
+// currencyOf reads the invoice's currency. One that no longer resolves prints the amounts at two
+// places with no code rather than failing the download: the amounts are what the reader needs.
+func (this *InvoicePrintDomainService) currencyOf(
+	ctx corectx.Context, invoice *models.Invoice,
+) (itExt.Currency, error) {
+	currency := itExt.Currency{DecimalPlaces: 2}
+
+	currencyId := derefString(invoice.GetCurrencyId())
+	if currencyId == "" {
+		return currency, nil
+	}
+
+	found, err := this.currencies.GetCurrency(ctx, itExt.GetCurrencyQuery{Id: currencyId})
+	if err != nil {
+		return currency, errors.Wrap(err, "render invoice")
+	}
+	if found != nil && found.HasData {
+		currency = itExt.Currency{
+			Code:          found.Data.Code,
+			Symbol:        found.Data.Symbol,
+			DecimalPlaces: found.Data.DecimalPlaces,
+		}
+	}
+	return currency, nil
+}
+
+// storedComputation reads an issued invoice's lines and taxes as they were frozen at issue, so a
+// reprint says what was charged even after a tax it named has changed.
+func storedComputation(invoice *models.Invoice, lines []*models.InvoiceLine) *invoiceComputation {
+	computed := &invoiceComputation{
+		Lines:     make([]computedLine, len(lines)),
+		Breakdown: decodeTaxBreakdown(invoice.GetTaxBreakdown()),
+	}
+	for index, line := range lines {
+		computed.Lines[index] = computedLine{
+			Amount: derefDecimal(line.GetAmount()),
+			Tax:    derefDecimal(line.GetTaxAmount()),
+		}
+	}
+	return computed
+}
+
+// buildInvoiceDocument says what a printed invoice contains, given what its lines come to. It is
+// pure so the content of the document can be pinned without a renderer.
+func buildInvoiceDocument(
+	invoice *models.Invoice, lines []*models.InvoiceLine, computed *invoiceComputation,
+	currency itExt.Currency,
+) itExt.Document {
+	status := derefString(invoice.GetStatus())
+
+	doc := itExt.Document{
+		Title:  invoiceTitleOf(status),
+		Number: derefString(invoice.GetNumber()),
+		Partner: itExt.Partner{
+			Heading: "Bill to",
+			Name:    derefString(invoice.GetPartnerName()),
+			TaxCode: derefString(invoice.GetPartnerTaxCode()),
+			Address: derefString(invoice.GetPartnerAddress()),
+		},
+		Currency: currency,
+		Notes:    derefString(invoice.GetNote()),
+	}
+
+	if issuedAt := invoice.GetIssuedAt(); issuedAt != nil {
+		doc.Facts = append(doc.Facts, itExt.Fact{Label: "Issued", Value: itExt.FormatDate(issuedAt.GoTime())})
+	}
+	doc.Facts = append(doc.Facts, itExt.Fact{Label: "Status", Value: titleCase(status)})
+
+	// One line naming taxes turns the rate column into a tax amount column for every line: a
+	// compound or price-included tax has no single rate to print.
+	namesTaxes := false
+	for _, line := range lines {
+		namesTaxes = namesTaxes || len(line.GetTaxIds()) > 0
+	}
+
+	for index, line := range lines {
+		printed := itExt.Line{
+			Kind:        itExt.LineKindItem,
+			Description: derefString(line.GetDescription()),
+			Quantity:    decimal.NewFromInt(int64(lineQuantity(*line))),
+			UnitPrice:   derefDecimal(line.GetUnitPrice()),
+			Amount:      computed.Lines[index].Amount,
+		}
+		if namesTaxes {
+			tax := computed.Lines[index].Tax
+			printed.TaxAmount = &tax
+		} else {
+			rate := derefDecimal(line.GetTaxRatePercent())
+			printed.TaxRatePercent = &rate
+		}
+		doc.Lines = append(doc.Lines, printed)
+	}
+
+	if status == models.InvoiceStatusDraft {
+		doc.Totals = []itExt.Total{{Label: "Subtotal", Amount: computed.Totals.Subtotal}}
+		doc.Totals = append(doc.Totals, taxTotalsOf(computed.Totals.Tax, computed.Breakdown)...)
+		doc.Totals = append(doc.Totals,
+			itExt.Total{Label: "Total", Amount: computed.Totals.Total, Emphasis: true})
+		return doc
+	}
+
+	doc.Totals = []itExt.Total{{Label: "Subtotal", Amount: derefDecimal(invoice.GetSubtotalAmount())}}
+	doc.Totals = append(doc.Totals, taxTotalsOf(derefDecimal(invoice.GetTaxAmount()), computed.Breakdown)...)
+	doc.Totals = append(doc.Totals,
+		itExt.Total{Label: "Total", Amount: derefDecimal(invoice.GetTotalAmount()), Emphasis: true},
+		itExt.Total{Label: "Paid", Amount: derefDecimal(invoice.GetAmountPaid())},
+	)
+	// A credited line is printed only on an invoice that has one; on every other it is a zero
+	// that invites the question of what a credit is.
+	if credited := derefDecimal(invoice.GetAmountCredited()); !credited.IsZero() {
+		doc.Totals = append(doc.Totals, itExt.Total{Label: "Credited", Amount: credited})
+	}
+	doc.Totals = append(doc.Totals,
+		itExt.Total{Label: "Amount due", Amount: derefDecimal(invoice.GetAmountDue()), Emphasis: true})
+	return doc
+}
+
+// taxTotalsOf prints one total per named tax, and a plain "Tax" for whatever the lines taxed at a
+// bare rate add on top. An invoice that names no taxes prints the single "Tax" it always did.
+func taxTotalsOf(tax decimal.Decimal, breakdown []itExt.TaxBreakdown) []itExt.Total {
+	if len(breakdown) == 0 {
+		return []itExt.Total{{Label: "Tax", Amount: tax}}
+	}
+	totals := make([]itExt.Total, 0, len(breakdown)+1)
+	rest := tax
+	for _, entry := range breakdown {
+		totals = append(totals, itExt.Total{Label: entry.Code, Amount: entry.Amount})
+		rest = rest.Sub(entry.Amount)
+	}
+	if !rest.IsZero() {
+		totals = append(totals, itExt.Total{Label: "Tax", Amount: rest})
+	}
+	return totals
+}
+
+func invoiceTitleOf(status string) string {
+	switch status {
+	case models.InvoiceStatusDraft:
+		return "Draft invoice"
+	case models.InvoiceStatusVoid:
+		return "Void invoice"
+	}
+	return "Invoice"
+}
+
+func titleCase(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
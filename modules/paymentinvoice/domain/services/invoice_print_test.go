@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
+)
+
+// What a printed invoice says is decided here, not by the renderer. These pin it without one.
+
+func invoiceOf(fields dmodel.DynamicFields) *models.Invoice {
+	base := dmodel.DynamicFields{
+		models.InvoiceFieldPartnerName:    "Công ty Ánh Sáng",
+		models.InvoiceFieldPartnerTaxCode: "0101234567",
+	}
+	for key, value := range fields {
+		base[key] = value
+	}
+	return models.NewInvoiceFrom(base)
+}
+
+// computedOf prices lines that name no taxes, which needs neither a context nor Essential.
+func computedOf(t *testing.T, lines []*models.InvoiceLine) *invoiceComputation {
+	computed, err := computeInvoiceLines(nil, nil, "", lines, ft.NewClientErrors())
+	require.NoError(t, err)
+	return computed
+}
+
+func totalLabels(doc itExt.Document) []string {
+	labels := []string{}
+	for _, total := range doc.Totals {
+		labels = append(labels, total.Label)
+	}
+	return labels
+}
+
+// A draft's stored totals are not yet frozen, so it prints what its lines come to.
+func TestADraftPrintsTheTotalsOfItsLines(t *testing.T) {
+	invoice := invoiceOf(dmodel.DynamicFields{models.InvoiceFieldStatus: models.InvoiceStatusDraft})
+	lines := []*models.InvoiceLine{
+		invoiceLineOf("a", 2, "100", "10"),
+		invoiceLineOf("b", 1, "50", "0"),
+	}
+
+	doc := buildInvoiceDocument(invoice, lines, computedOf(t, lines), itExt.Currency{Code: "VND"})
+
+	assert.Equal(t, "Draft invoice", doc.Title)
+	assert.Equal(t, []string{"Subtotal", "Tax", "Total"}, totalLabels(doc))
+	assert.Equal(t, "250", doc.Totals[0].Amount.String())
+	assert.Equal(t, "20", doc.Totals[1].Amount.String())
+	assert.Equal(t, "270", doc.Totals[2].Amount.String())
+	require.Len(t, doc.Lines, 2)
+	assert.Equal(t, "200", doc.Lines[0].Amount.String())
+	assert.Equal(t, "Bill to", doc.Partner.Heading)
+}
+
+// An issued invoice prints what it was issued for and what is still owed on it.
+func TestAnIssuedInvoicePrintsWhatIsDue(t *testing.T) {
+	invoice := invoiceOf(dmodel.DynamicFields{
+		models.InvoiceFieldStatus:         models.InvoiceStatusIssued,
+		models.InvoiceFieldNumber:         "INV-2026-000001",
+		models.InvoiceFieldIssuedAt:       model.WrapModelDateTime(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)),
+		models.InvoiceFieldSubtotalAmount: decimal.RequireFromString("1000"),
+		models.InvoiceFieldTaxAmount:      decimal.RequireFromString("100"),
+		models.InvoiceFieldTotalAmount:    decimal.RequireFromString("1100"),
+		models.InvoiceFieldAmountPaid:     decimal.RequireFromString("600"),
+		models.InvoiceFieldAmountCredited: decimal.Zero,
+		models.InvoiceFieldAmountDue:      decimal.RequireFromString("500"),
+	})
+
+	doc := buildInvoiceDocument(invoice, nil, storedComputation(invoice, nil), itExt.Currency{Code: "VND"})
+
+	assert.Equal(t, "Invoice", doc.Title)
+	assert.Equal(t, "INV-2026-000001", doc.Number)
+	assert.Equal(t, itExt.Fact{Label: "Issued", Value: "2026-10-01"}, doc.Facts[0])
+	// No credit, no credit line.
+	assert.Equal(t, []string{"Subtotal", "Tax", "Total", "Paid", "Amount due"}, totalLabels(doc))
+	assert.Equal(t, "500", doc.Totals[4].Amount.String())
+	assert.True(t, doc.Totals[4].Emphasis)
+}
+
+func TestACreditedInvoicePrintsTheCredit(t *testing.T) {
+	invoice := invoiceOf(dmodel.DynamicFields{
+		models.InvoiceFieldStatus:         models.InvoiceStatusIssued,
+		models.InvoiceFieldAmountCredited: decimal.RequireFromString("300"),
+	})
+
+	doc := buildInvoiceDocument(invoice, nil, storedComputation(invoice, nil), itExt.Currency{})
+
+	assert.Contains(t, totalLabels(doc), "Credited")
+}
+
+// An issued invoice that named taxes prints each as it was frozen at issue, and a line that named
+// them prints the tax it was charged rather than a rate.
+func TestAnIssuedInvoicePrintsItsFrozenTaxes(t *testing.T) {
+	invoice := invoiceOf(dmodel.DynamicFields{
+		models.InvoiceFieldStatus:         models.InvoiceStatusIssued,
+		models.InvoiceFieldSubtotalAmount: decimal.RequireFromString("1000"),
+		models.InvoiceFieldTaxAmount:      decimal.RequireFromString("80"),
+		models.InvoiceFieldTotalAmount:    decimal.RequireFromString("1080"),
+		models.InvoiceFieldTaxBreakdown: encodeTaxBreakdown([]itExt.TaxBreakdown{
+			{TaxId: "vat", Code: "VAT10", Base: decimal.RequireFromString("1000"), Amount: decimal.RequireFromString("100")},
+			{TaxId: "wht", Code: "WHT2", Base: decimal.RequireFromString("1000"), Amount: decimal.RequireFromString("-20")},
+		}),
+	})
+	line := invoiceLineOf("a", 1, "1000", "0")
+	line.GetFieldData()[models.InvoiceLineFieldTaxIds] = []string{"vat", "wht"}
+	line.GetFieldData()[models.InvoiceLineFieldAmount] = decimal.RequireFromString("1000")
+	line.GetFieldData()[models.InvoiceLineFieldTaxAmount] = decimal.RequireFromString("80")
+	lines := []*models.InvoiceLine{line}
+
+	doc := buildInvoiceDocument(invoice, lines, storedComputation(invoice, lines), itExt.Currency{})
+
+	assert.Equal(t, []string{"Subtotal", "VAT10", "WHT2", "Total", "Paid", "Amount due"}, totalLabels(doc))
+	assert.Equal(t, "-20", doc.Totals[2].Amount.String())
+	require.Len(t, doc.Lines, 1)
+	assert.Nil(t, doc.Lines[0].TaxRatePercent)
+	require.NotNil(t, doc.Lines[0].TaxAmount)
+	assert.Equal(t, "80", doc.Lines[0].TaxAmount.String())
+}
+
+// Tax from lines at a bare rate is printed beside the named taxes, so the tax totals still add up.
+func TestBareRateTaxIsPrintedBesideNamedTaxes(t *testing.T) {
+	totals := taxTotalsOf(decimal.RequireFromString("115"), []itExt.TaxBreakdown{
+		{Code: "VAT10", Amount: decimal.RequireFromString("100")},
+	})
+
+	require.Len(t, totals, 2)
+	assert.Equal(t, "VAT10", totals[0].Label)
+	assert.Equal(t, itExt.Total{Label: "Tax", Amount: decimal.RequireFromString("15")}, totals[1])
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
+)
+
+// How an invoice's lines are taxed.
+//
+// A line that names taxes is taxed by Essential, with everything that implies: price-included
+// extraction, compounding, withholding, rounding to the currency. A line that names none is taxed
+// at its own tax_rate_percent, exactly as every line was before taxes could be named, so that
+// drafts written then issue to the totals they always showed.
+
+// computedLine is what one invoice line comes to.
+type computedLine struct {
+	Amount decimal.Decimal
+	Tax    decimal.Decimal
+}
+
+// invoiceComputation is what an invoice's lines come to, line by line, in total, and by tax.
+type invoiceComputation struct {
+	Lines     []computedLine
+	Totals    invoiceTotals
+	Breakdown []itExt.TaxBreakdown
+}
+
+// computeInvoiceLines prices every line of one invoice.
+//
+// Essential is asked only when at least one line names a tax, so an invoice whose lines all carry a
+// bare rate is computed here alone, and a nil port is a wiring fault only for an invoice that needs
+// it.
+func computeInvoiceLines(
+	ctx corectx.Context, taxes itExt.TaxExtService, currencyId string, lines []*models.InvoiceLine,
+	vErrs *ft.ClientErrors,
+) (*invoiceComputation, error) {
+	computed := &invoiceComputation{
+		Lines:  make([]computedLine, len(lines)),
+		Totals: invoiceTotals{Subtotal: decimal.Zero, Tax: decimal.Zero},
+	}
+
+	taxedIndexes := []int{}
+	taxable := []itExt.TaxableLine{}
+	for index, line := range lines {
+		amount := derefDecimal(line.GetUnitPrice()).Mul(decimal.NewFromInt(int64(lineQuantity(*line))))
+		if taxIds := line.GetTaxIds(); len(taxIds) > 0 {
+			taxedIndexes = append(taxedIndexes, index)
+			taxable = append(taxable, itExt.TaxableLine{Amount: amount, TaxIds: taxIds})
+			continue
+		}
+		computed.Lines[index] = computedLine{
+			Amount: amount,
+			Tax:    amount.Mul(derefDecimal(line.GetTaxRatePercent())).Div(decimal.NewFromInt(100)),
+		}
+	}
+
+	if len(taxable) > 0 {
+		if taxes == nil {
+			return nil, errors.New("an invoice line names taxes but the tax port is not bound; " +
+				"paymentinvoice/infra/external must bind it")
+		}
+		result, err := taxes.ComputeTaxes(ctx, itExt.ComputeTaxesQuery{CurrencyId: currencyId, Lines: taxable})
+		if err != nil {
+			return nil, errors.Wrap(err, "compute invoice taxes")
+		}
+		if result.ClientErrors.Count() > 0 {
+			vErrs.Append(result.ClientErrors...)
+			return nil, nil
+		}
+		for position, index := range taxedIndexes {
+			taxed := result.Data.Lines[position]
+			computed.Lines[index] = computedLine{Amount: taxed.Untaxed, Tax: taxed.Tax}
+		}
+		computed.Breakdown = result.Data.Breakdown
+	}
+
+	for _, line := range computed.Lines {
+		computed.Totals.Subtotal = computed.Totals.Subtotal.Add(line.Amount)
+		computed.Totals.Tax = computed.Totals.Tax.Add(line.Tax)
+	}
+	computed.Totals.Total = computed.Totals.Subtotal.Add(computed.Totals.Tax)
+	return computed, nil
+}
+
+// encodeTaxBreakdown shapes a breakdown for the invoice's tax_breakdown jsonmap. Amounts are
+// written as decimal strings, because a JSON number read back as float64 would lose the cents it
+// exists to record.
+func encodeTaxBreakdown(breakdown []itExt.TaxBreakdown) map[string]any {
+	if len(breakdown) == 0 {
+		return nil
+	}
+	entries := make([]any, 0, len(breakdown))
+	for _, entry := range breakdown {
+		entries = append(entries, map[string]any{
+			"tax_id":        entry.TaxId,
+			"code":          entry.Code,
+			"tax_type":      entry.TaxType,
+			"rate_percent":  entry.RatePercent.String(),
+			"account_label": entry.AccountLabel,
+			"base":          entry.Base.String(),
+			"amount":        entry.Amount.String(),
+		})
+	}
+	return map[string]any{"entries": entries}
+}
+
+// decodeTaxBreakdown reads tax_breakdown back. An entry it cannot read is skipped rather than
+// failing the read: the breakdown is an account of the totals, which are stored on their own.
+func decodeTaxBreakdown(stored map[string]any) []itExt.TaxBreakdown {
+	entries, _ := stored["entries"].([]any)
+	breakdown := make([]itExt.TaxBreakdown, 0, len(entries))
+	for _, raw := range entries {
+		entry, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		amount, err := decimal.NewFromString(stringField(entry, "amount"))
+		if err != nil {
+			continue
+		}
+		breakdown = append(breakdown, itExt.TaxBreakdown{
+			TaxId:        stringField(entry, "tax_id"),
+			Code:         stringField(entry, "code"),
+			TaxType:      stringField(entry, "tax_type"),
+			RatePercent:  decimalField(entry, "rate_percent"),
+			AccountLabel: stringField(entry, "account_label"),
+			Base:         decimalField(entry, "base"),
+			Amount:       amount,
+		})
+	}
+	return breakdown
+}
+
+func stringField(entry map[string]any, key string) string {
+	value, _ := entry[key].(string)
+	return value
+}
+
+func decimalField(entry map[string]any, key string) decimal.Decimal {
+	value, err := decimal.NewFromString(stringField(entry, key))
+	if err != nil {
+		return decimal.Zero
+	}
+	return value
+}
@@ -129,7 +129,9 @@ func (this *OrderDomainService) reverse(
 // markRefundSucceeded closes the order and appends the refund transaction.
 //
 // The running total is written rather than the amount of this refund, so a partially refunded
-// order still says how much of it has been given back.
+// order still says how much of it has been given back. The invoices the payment settled are
+// re-opened by as much as the refund took, in the same transaction, so no invoice stays paid by
+// money that has gone back to the customer.
 func (this *OrderDomainService) markRefundSucceeded(
 	ctx corectx.Context,
 	order models.Order,
@@ -150,8 +152,11 @@ func (this *OrderDomainService) markRefundSucceeded(
 		}); err != nil {
 			return err
 		}
-		return appendRefundTransaction(tranxCtx, order, method, cmd,
-			models.TransactionStatusCompleted, refunded.RefTransactionId, refunded.RawResponse)
+		if err := appendRefundTransaction(tranxCtx, order, method, cmd,
+			models.TransactionStatusCompleted, refunded.RefTransactionId, refunded.RawResponse); err != nil {
+			return err
+		}
+		return releaseAllocationsForRefund(tranxCtx, order, total)
 	})
 }
 
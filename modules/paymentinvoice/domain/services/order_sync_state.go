@@ -7,7 +7,6 @@ import (
 
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
-	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
 )
 
@@ -49,72 +48,8 @@ type SyncOutcome struct {
 	At       time.Time
 }
 
-// PendingSyncOrder is an order the ordering system was never successfully told about.
-type PendingSyncOrder struct {
-	Pk        string
-	OrderId   string
-	Status    string
-	ReturnUrl string
-}
-
-// FindOrdersNeedingSync returns settled orders whose notification has not got through.
-//
-// Only orders that reached a verdict are in scope — there is nothing to report about one still in
-// flight — and only those whose last attempt failed. An order that has exhausted its attempts is
-// excluded by the same count the client bounds itself by, so a permanently unreachable tenant
-// stops being chased rather than being retried forever.
-func FindOrdersNeedingSync(ctx corectx.Context) ([]PendingSyncOrder, error) {
-	engine, err := engineFor(models.OrderSchemaName)
-	if err != nil {
-		return nil, err
-	}
-
-	graph := &dmodel.SearchGraph{}
-	graph.And(
-		*dmodel.NewSearchNode().NewCondition(models.OrderFieldStatus, dmodel.In,
-			models.OrderStatusPaymentSuccess,
-			models.OrderStatusPaymentFailed,
-			models.OrderStatusExpired,
-		),
-		*dmodel.NewSearchNode().NewCondition(
-			models.OrderFieldLastSyncStatus, dmodel.Equals, SyncStatusFailure),
-	)
-
-	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
-		Graph: graph,
-		Page:  0,
-		Size:  sweepPageSize,
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, "FindOrdersNeedingSync")
-	}
-	if found == nil || !found.HasData {
-		return nil, nil
-	}
-
-	pending := make([]PendingSyncOrder, 0, len(found.Data.Items))
-	for _, item := range found.Data.Items {
-		order := models.NewOrderFrom(item)
-
-		// An order with no callback URL cannot be notified and must not be re-queued every five
-		// minutes. It should not be in this set at all — the client records such an order as a
-		// success — so this guards against one that predates that behaviour.
-		returnUrl := derefString(order.GetReturnUrl())
-		if returnUrl == "" {
-			continue
-		}
-		if syncAttemptsOf(order.GetSyncLogs()) >= syncLogLimit {
-			continue
-		}
-
-		pending = append(pending, PendingSyncOrder{
-			Pk:        derefString(order.GetId()),
-			OrderId:   derefString(order.GetOrderId()),
-			Status:    derefString(order.GetStatus()),
-			ReturnUrl: returnUrl,
-		})
-	}
-	return pending, nil
+func (this SyncOutcome) Succeeded() bool {
+	return this.Status == SyncStatusSuccess
 }
 
 // SyncFactsFor reads the two order facts the notification carries beyond its status.
@@ -159,14 +94,13 @@ func (this *OrderDomainService) SyncFactsFor(
 	return amount, derefString(models.NewPaymentMethodFrom(found.Data).GetCode()), nil
 }
 
-// RecordSyncOutcome writes the result of a notification onto the order.
+// recordSyncOutcome writes the result of a notification onto the order.
 //
 // Both the summary status and the appended log entry are written, because the two answer different
-// questions: last_sync_status is what the retry sweep filters on, and the log is what a human
-// reads when asking why a tenant says it was never told.
-func (this *OrderDomainService) RecordSyncOutcome(
-	ctx corectx.Context, orderPk string, outcome SyncOutcome,
-) error {
+// questions: last_sync_status is what the order's listing shows, and the log is what a human reads
+// on the order itself when asking why a tenant says it was never told. Every attempt in full is on
+// the order's sync deliveries.
+func recordSyncOutcome(ctx corectx.Context, orderPk string, outcome SyncOutcome) error {
 	engine, err := engineFor(models.OrderSchemaName)
 	if err != nil {
 		return err
@@ -176,7 +110,7 @@ func (this *OrderDomainService) RecordSyncOutcome(
 		models.OrderFieldId: orderPk,
 	})
 	if err != nil {
-		return errors.Wrap(err, "RecordSyncOutcome")
+		return errors.Wrap(err, "recordSyncOutcome")
 	}
 	if found == nil || !found.HasData {
 		return nil
@@ -222,8 +156,3 @@ func syncLogEntriesOf(logs map[string]any) []any {
 	entries, _ := logs[syncLogEntriesKey].([]any)
 	return entries
 }
-
-// syncAttemptsOf counts how many times an order has been reported on.
-func syncAttemptsOf(logs map[string]any) int {
-	return len(syncLogEntriesOf(logs))
-}
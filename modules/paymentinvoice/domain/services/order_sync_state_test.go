@@ -8,9 +8,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// The sync log is what bounds the retry sweep and what a human reads when a tenant says it was
-// never told a payment settled. These tests cover the two things that has to get right: not
-// growing without bound, and surviving whatever shape the JSON column comes back in.
+// The sync log is what a human reads on the order when a tenant says it was never told a payment
+// settled. These tests cover the two things that has to get right: not growing without bound, and
+// surviving whatever shape the JSON column comes back in.
 
 func TestAnOutcomeIsAppendedToAnEmptyLog(t *testing.T) {
 	logs := appendSyncLog(nil, SyncOutcome{
@@ -62,19 +62,6 @@ func TestTheLogIsCappedAndKeepsTheMostRecentEntries(t *testing.T) {
 	assert.Equal(t, 10, entries[0].(map[string]any)["attempts"])
 }
 
-// The column round-trips through JSON, so the entry list comes back as []any rather than the
-// []map[string]any it was written as. Counting must survive that.
-func TestAttemptsAreCountedFromADecodedColumn(t *testing.T) {
-	decoded := map[string]any{
-		syncLogEntriesKey: []any{
-			map[string]any{"status": SyncStatusFailure},
-			map[string]any{"status": SyncStatusFailure},
-		},
-	}
-
-	assert.Equal(t, 2, syncAttemptsOf(decoded))
-}
-
 // A malformed or absent log must read as empty rather than panicking: a bad log must not stop a
 // payment from being reported.
 func TestAMalformedLogReadsAsEmpty(t *testing.T) {
@@ -84,7 +71,6 @@ func TestAMalformedLogReadsAsEmpty(t *testing.T) {
 		"wrong type":     {syncLogEntriesKey: "not a list"},
 		"a number":       {syncLogEntriesKey: 42},
 	} {
-		assert.Zero(t, syncAttemptsOf(logs), name)
 		assert.Empty(t, syncLogEntriesOf(logs), name)
 	}
 }
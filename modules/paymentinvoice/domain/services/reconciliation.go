@@ -0,0 +1,559 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// settlementDateLayout is how a settlement day is named, in the import and on the record.
+const settlementDateLayout = "2006-01-02"
+
+// ReconciliationDomainService compares a gateway's settlement report for one day with the
+// transactions this module recorded through that gateway on the same day.
+//
+// The report is parsed by the method's adapter, because only the adapter knows its gateway's
+// format; everything after the parse is the same for every gateway.
+type ReconciliationDomainService struct {
+	registry *itGateway.Registry
+
+	// location decides where a settlement day starts and ends. Gateways here settle by the
+	// Vietnamese calendar day, which is the server's local day in every deployment so far.
+	location *time.Location
+}
+
+func NewReconciliationDomainService(registry *itGateway.Registry) *ReconciliationDomainService {
+	return &ReconciliationDomainService{registry: registry, location: time.Local}
+}
+
+// ImportSettlementCommand carries one settlement report.
+type ImportSettlementCommand struct {
+	PaymentMethodId string
+
+	// SettlementDate is the day the report covers, as YYYY-MM-DD.
+	SettlementDate string
+
+	// FileName is kept on the reconciliation so the report it came from can be found again.
+	FileName string
+	Report   []byte
+	OrgId    string
+}
+
+// ImportSettlementResult is the reconciliation as filed, with its counts.
+type ImportSettlementResult struct {
+	ReconciliationId string
+	Status           string
+	LineCount        int
+	MatchedCount     int
+	MissingCount     int
+	ExtraCount       int
+	MismatchCount    int
+	SettledTotal     decimal.Decimal
+	ExpectedTotal    decimal.Decimal
+}
+
+// ImportSettlement reconciles one report and files the result.
+//
+// Importing a report for a method and day that were already reconciled replaces the earlier
+// reconciliation, items and all: a gateway re-issues a statement to correct it, and the corrected
+// one is the only one worth comparing against.
+func (this *ReconciliationDomainService) ImportSettlement(
+	ctx corectx.Context, cmd ImportSettlementCommand,
+) (*ImportSettlementResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+	day, ok := validateImportSettlement(cmd, vErrs)
+	if !ok {
+		return nil, vErrs, nil
+	}
+
+	parser, err := this.settlementParser(ctx, cmd.PaymentMethodId, vErrs)
+	if err != nil || parser == nil {
+		return nil, vErrs, err
+	}
+
+	lines, err := parser.ParseSettlement(bytes.NewReader(cmd.Report))
+	if err != nil {
+		appendFieldViolation(vErrs, "content",
+			"paymentinvoice.settlement_report_malformed", err.Error())
+		return nil, vErrs, nil
+	}
+	if !validateSettlementLines(lines, vErrs) {
+		return nil, vErrs, nil
+	}
+
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, this.location)
+	transactions, err := findSettledTransactions(ctx, cmd.PaymentMethodId, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, vErrs, err
+	}
+
+	report := reconcile(lines, transactions)
+	var reconciliationId string
+	err = withOrderTransaction(ctx, func(tranxCtx corectx.Context) error {
+		if err := deleteReconciliation(tranxCtx, cmd.PaymentMethodId, day); err != nil {
+			return err
+		}
+		reconciliationId, err = fileReconciliation(tranxCtx, cmd, day, report)
+		return err
+	})
+	if err != nil {
+		return nil, vErrs, err
+	}
+
+	return &ImportSettlementResult{
+		ReconciliationId: reconciliationId,
+		Status:           report.status(),
+		LineCount:        report.lineCount,
+		MatchedCount:     report.count(models.ReconciliationOutcomeMatched),
+		MissingCount:     report.count(models.ReconciliationOutcomeMissing),
+		ExtraCount:       report.count(models.ReconciliationOutcomeExtra),
+		MismatchCount:    report.count(models.ReconciliationOutcomeAmountMismatch),
+		SettledTotal:     report.settledTotal,
+		ExpectedTotal:    report.expectedTotal,
+	}, vErrs, nil
+}
+
+func validateImportSettlement(cmd ImportSettlementCommand, vErrs *ft.ClientErrors) (time.Time, bool) {
+	if cmd.PaymentMethodId == "" {
+		appendFieldViolation(vErrs, models.ReconciliationFieldPaymentMethodId,
+			"paymentinvoice.payment_method_required", "no payment method was given")
+	}
+	day, err := time.Parse(settlementDateLayout, cmd.SettlementDate)
+	if err != nil {
+		appendFieldViolation(vErrs, models.ReconciliationFieldSettlementDate,
+			"paymentinvoice.settlement_date_invalid", "the settlement date must be given as YYYY-MM-DD")
+	}
+	if len(bytes.TrimSpace(cmd.Report)) == 0 {
+		appendFieldViolation(vErrs, "content",
+			"paymentinvoice.settlement_report_required", "the settlement report is empty")
+	}
+	if cmd.OrgId == "" {
+		appendFieldViolation(vErrs, models.ReconciliationFieldOrgId,
+			"paymentinvoice.org_required", "no organization was given")
+	}
+	return day, vErrs.Count() == 0
+}
+
+// The longest identifiers a reconciliation item keeps, as its schema allows.
+const (
+	maxSettlementOrderCode = 30
+	maxSettlementRefId     = 100
+)
+
+// validateSettlementLines refuses a report whose identifiers no transaction here could carry. Such
+// a line is not an extra to be filed but a sign the wrong report, or the wrong column, was read.
+func validateSettlementLines(lines []itGateway.SettlementLine, vErrs *ft.ClientErrors) bool {
+	for _, line := range lines {
+		if len(line.OrderCode) > maxSettlementOrderCode || len(line.RefTransactionId) > maxSettlementRefId {
+			appendFieldViolation(vErrs, "content", "paymentinvoice.settlement_report_malformed",
+				fmt.Sprintf("line %d of the settlement report: the identifier is too long to be one of ours", line.LineNo))
+			return false
+		}
+	}
+	return true
+}
+
+// settlementParser finds the parser for a method's gateway.
+//
+// The method need not be active: a method withdrawn today still has yesterday's statement to
+// reconcile. Its gateway must be enabled here, though, since the parser is the adapter's.
+func (this *ReconciliationDomainService) settlementParser(
+	ctx corectx.Context, methodId string, vErrs *ft.ClientErrors,
+) (itGateway.SettlementParser, error) {
+	engine, err := engineFor(models.PaymentMethodSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.PaymentMethodFieldId: methodId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "settlementParser")
+	}
+	if found == nil || !found.HasData {
+		appendFieldViolation(vErrs, models.ReconciliationFieldPaymentMethodId,
+			"paymentinvoice.payment_method_not_found", "no payment method with id '"+methodId+"'")
+		return nil, nil
+	}
+
+	adapterCode := derefString(models.NewPaymentMethodFrom(found.Data).GetAdapterCode())
+	adapter, ok := this.registry.Get(adapterCode)
+	if !ok {
+		appendFieldViolation(vErrs, models.ReconciliationFieldPaymentMethodId,
+			"paymentinvoice.gateway_unavailable", "the gateway '"+adapterCode+"' is not enabled")
+		return nil, nil
+	}
+	parser, ok := adapter.(itGateway.SettlementParser)
+	if !ok {
+		appendFieldViolation(vErrs, models.ReconciliationFieldPaymentMethodId,
+			"paymentinvoice.settlement_unsupported",
+			"the gateway '"+adapterCode+"' has no settlement report to reconcile")
+		return nil, nil
+	}
+	return parser, nil
+}
+
+// settledTransaction is a completed transaction as reconciliation sees it: the order code comes
+// from its order, since a report names the order by the code the gateway was given.
+type settledTransaction struct {
+	Id               string
+	Type             string
+	OrderCode        string
+	OrderBusinessId  string
+	RefTransactionId string
+	Amount           decimal.Decimal
+}
+
+// reconciliationEntry is one item of a reconciliation before it is filed.
+type reconciliationEntry struct {
+	Outcome          string
+	TransactionType  string
+	TransactionId    string
+	OrderCode        string
+	RefTransactionId string
+	LineNo           int
+
+	// ExpectedAmount is nil for a line no transaction accounts for; SettledAmount is nil for a
+	// transaction no line accounts for.
+	ExpectedAmount *decimal.Decimal
+	SettledAmount  *decimal.Decimal
+}
+
+type reconciliationReport struct {
+	entries   []reconciliationEntry
+	lineCount int
+
+	// The totals are net: payments less refunds, as the gateway pays out.
+	settledTotal  decimal.Decimal
+	expectedTotal decimal.Decimal
+}
+
+func (this reconciliationReport) count(outcome string) int {
+	count := 0
+	for _, entry := range this.entries {
+		if entry.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+func (this reconciliationReport) status() string {
+	if this.count(models.ReconciliationOutcomeMatched) == len(this.entries) {
+		return models.ReconciliationStatusBalanced
+	}
+	return models.ReconciliationStatusDiscrepancies
+}
+
+// reconcile pairs report lines with transactions.
+//
+// A line is matched by the gateway's transaction id when it has one, because that is unique to
+// one movement of money; failing that, by order code and kind, which an order with a partial
+// refund and then another could share between two refunds — those are taken in line order. Each
+// transaction is matched once. A matched pair whose amounts differ is a mismatch; a line left over
+// is extra, and a transaction left over is missing.
+func reconcile(lines []itGateway.SettlementLine, transactions []settledTransaction) reconciliationReport {
+	report := reconciliationReport{lineCount: len(lines)}
+
+	consumed := make([]bool, len(transactions))
+	byRefId := map[string]int{}
+	byOrder := map[string][]int{}
+	for i, transaction := range transactions {
+		if transaction.RefTransactionId != "" {
+			byRefId[transaction.RefTransactionId] = i
+		}
+		for _, code := range []string{transaction.OrderCode, transaction.OrderBusinessId} {
+			if code != "" {
+				key := transaction.Type + "|" + code
+				byOrder[key] = append(byOrder[key], i)
+			}
+		}
+	}
+
+	take := func(line itGateway.SettlementLine) int {
+		if i, found := byRefId[line.RefTransactionId]; found && line.RefTransactionId != "" &&
+			!consumed[i] && transactions[i].Type == line.Kind {
+			return i
+		}
+		if line.OrderCode == "" {
+			return -1
+		}
+		for _, i := range byOrder[line.Kind+"|"+line.OrderCode] {
+			if !consumed[i] {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, line := range lines {
+		report.settledTotal = report.settledTotal.Add(signed(line.Kind, line.Amount))
+		settled := line.Amount
+
+		i := take(line)
+		if i < 0 {
+			report.entries = append(report.entries, reconciliationEntry{
+				Outcome:          models.ReconciliationOutcomeExtra,
+				TransactionType:  line.Kind,
+				OrderCode:        line.OrderCode,
+				RefTransactionId: line.RefTransactionId,
+				LineNo:           line.LineNo,
+				SettledAmount:    &settled,
+			})
+			continue
+		}
+
+		consumed[i] = true
+		transaction := transactions[i]
+		expected := transaction.Amount
+		outcome := models.ReconciliationOutcomeMatched
+		if !expected.Equal(settled) {
+			outcome = models.ReconciliationOutcomeAmountMismatch
+		}
+		report.entries = append(report.entries, reconciliationEntry{
+			Outcome:          outcome,
+			TransactionType:  transaction.Type,
+			TransactionId:    transaction.Id,
+			OrderCode:        firstNonEmpty(line.OrderCode, transaction.OrderCode),
+			RefTransactionId: firstNonEmpty(line.RefTransactionId, transaction.RefTransactionId),
+			LineNo:           line.LineNo,
+			ExpectedAmount:   &expected,
+			SettledAmount:    &settled,
+		})
+	}
+
+	for i, transaction := range transactions {
+		report.expectedTotal = report.expectedTotal.Add(signed(transaction.Type, transaction.Amount))
+		if consumed[i] {
+			continue
+		}
+		expected := transaction.Amount
+		report.entries = append(report.entries, reconciliationEntry{
+			Outcome:          models.ReconciliationOutcomeMissing,
+			TransactionType:  transaction.Type,
+			TransactionId:    transaction.Id,
+			OrderCode:        transaction.OrderCode,
+			RefTransactionId: transaction.RefTransactionId,
+			ExpectedAmount:   &expected,
+		})
+	}
+	return report
+}
+
+// signed is an amount as it moves the day's payout: a refund is money paid back.
+func signed(kind string, amount decimal.Decimal) decimal.Decimal {
+	if kind == itGateway.SettlementKindRefund {
+		return amount.Neg()
+	}
+	return amount
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// findSettledTransactions returns the completed transactions of one method created within
+// [from, until), with the order code each was sent to the gateway under.
+//
+// A transaction is placed on the day it was created. One created just before midnight and settled
+// just after will show as missing on the first day's report and extra on the next; that is rare
+// enough to be left to whoever reads the discrepancy rather than guessed at here.
+func findSettledTransactions(
+	ctx corectx.Context, methodId string, from time.Time, until time.Time,
+) ([]settledTransaction, error) {
+	engine, err := engineFor(models.TransactionSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.TransactionFieldPaymentMethodId, dmodel.Equals, methodId),
+		*dmodel.NewSearchNode().NewCondition(
+			models.TransactionFieldStatus, dmodel.Equals, models.TransactionStatusCompleted),
+		*dmodel.NewSearchNode().NewCondition(basemodel.FieldCreatedAt, dmodel.GreaterEqual, from),
+		*dmodel.NewSearchNode().NewCondition(basemodel.FieldCreatedAt, dmodel.LessThan, until),
+	)
+	graph.OrderBy(basemodel.FieldCreatedAt)
+
+	transactions := []settledTransaction{}
+	orderIds := []string{}
+	for page := 0; ; page++ {
+		found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+			Graph: graph,
+			Page:  page,
+			Size:  sweepPageSize,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "findSettledTransactions")
+		}
+		if found == nil || !found.HasData {
+			break
+		}
+		for _, item := range found.Data.Items {
+			transaction := models.NewTransactionFrom(item)
+			orderId := derefString(transaction.GetOrderId())
+			transactions = append(transactions, settledTransaction{
+				Id:               derefString(transaction.GetId()),
+				Type:             derefString(transaction.GetTransactionType()),
+				OrderCode:        orderId,
+				OrderBusinessId:  derefString(transaction.GetOrderBusinessId()),
+				RefTransactionId: derefString(transaction.GetRefTransactionId()),
+				Amount:           derefDecimal(transaction.GetAmount()),
+			})
+			orderIds = append(orderIds, orderId)
+		}
+		if len(found.Data.Items) < sweepPageSize {
+			break
+		}
+	}
+
+	codes, err := findOrderCodes(ctx, orderIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range transactions {
+		// OrderCode holds the order's primary key until here.
+		transactions[i].OrderCode = codes[transactions[i].OrderCode]
+	}
+	return transactions, nil
+}
+
+// findOrderCodes maps order primary keys to the codes the gateways were given.
+func findOrderCodes(ctx corectx.Context, orderPks []string) (map[string]string, error) {
+	codes := map[string]string{}
+	if len(orderPks) == 0 {
+		return codes, nil
+	}
+	engine, err := engineFor(models.OrderSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(orderPks); start += sweepPageSize {
+		end := min(start+sweepPageSize, len(orderPks))
+		graph := &dmodel.SearchGraph{}
+		graph.And(
+			*dmodel.NewSearchNode().NewCondition(models.OrderFieldId, dmodel.In, orderPks[start:end]),
+		)
+		found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+			Graph: graph,
+			Page:  0,
+			Size:  end - start,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "findOrderCodes")
+		}
+		if found == nil || !found.HasData {
+			continue
+		}
+		for _, item := range found.Data.Items {
+			order := models.NewOrderFrom(item)
+			codes[derefString(order.GetId())] = derefString(order.GetOrderCode())
+		}
+	}
+	return codes, nil
+}
+
+// deleteReconciliation removes a method's reconciliation of one day, if there is one. Its items
+// go with it, by the cascade on their edge.
+func deleteReconciliation(ctx corectx.Context, methodId string, day time.Time) error {
+	engine, err := engineFor(models.ReconciliationSchemaName)
+	if err != nil {
+		return err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.ReconciliationFieldPaymentMethodId, dmodel.Equals, methodId),
+		*dmodel.NewSearchNode().NewCondition(
+			models.ReconciliationFieldSettlementDate, dmodel.Equals, model.WrapModelDate(day)),
+	)
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  1,
+	})
+	if err != nil {
+		return errors.Wrap(err, "deleteReconciliation")
+	}
+	if found == nil || !found.HasData || len(found.Data.Items) == 0 {
+		return nil
+	}
+
+	reconciliationId := derefString(models.NewReconciliationFrom(found.Data.Items[0]).GetId())
+	_, err = engine.ResourceRepository().DeleteOne(ctx, dmodel.DynamicFields{
+		models.ReconciliationFieldId: reconciliationId,
+	})
+	return errors.Wrapf(err, "deleteReconciliation: '%s'", reconciliationId)
+}
+
+// fileReconciliation writes a reconciliation and its items, and returns the reconciliation's id.
+func fileReconciliation(
+	ctx corectx.Context, cmd ImportSettlementCommand, day time.Time, report reconciliationReport,
+) (string, error) {
+	created, err := createRecord(ctx, models.ReconciliationSchemaName, dmodel.DynamicFields{
+		models.ReconciliationFieldPaymentMethodId: cmd.PaymentMethodId,
+		models.ReconciliationFieldSettlementDate:  model.WrapModelDate(day),
+		models.ReconciliationFieldFileName:        cmd.FileName,
+		models.ReconciliationFieldStatus:          report.status(),
+		models.ReconciliationFieldLineCount:       int32(report.lineCount),
+		models.ReconciliationFieldMatchedCount:    int32(report.count(models.ReconciliationOutcomeMatched)),
+		models.ReconciliationFieldMissingCount:    int32(report.count(models.ReconciliationOutcomeMissing)),
+		models.ReconciliationFieldExtraCount:      int32(report.count(models.ReconciliationOutcomeExtra)),
+		models.ReconciliationFieldMismatchCount:   int32(report.count(models.ReconciliationOutcomeAmountMismatch)),
+		models.ReconciliationFieldSettledTotal:    report.settledTotal,
+		models.ReconciliationFieldExpectedTotal:   report.expectedTotal,
+		models.ReconciliationFieldOrgId:           cmd.OrgId,
+	})
+	if err != nil {
+		return "", err
+	}
+	reconciliationId := derefString(models.NewReconciliationFrom(created).GetId())
+
+	for _, entry := range report.entries {
+		fields := dmodel.DynamicFields{
+			models.ReconciliationItemFieldReconciliationId: reconciliationId,
+			models.ReconciliationItemFieldOutcome:          entry.Outcome,
+			models.ReconciliationItemFieldTransactionType:  entry.TransactionType,
+			models.ReconciliationItemFieldOrderCode:        entry.OrderCode,
+			models.ReconciliationItemFieldRefTransactionId: entry.RefTransactionId,
+			models.ReconciliationItemFieldOrgId:            cmd.OrgId,
+		}
+		if entry.LineNo > 0 {
+			fields[models.ReconciliationItemFieldLineNo] = int32(entry.LineNo)
+		}
+		if entry.TransactionId != "" {
+			fields[models.ReconciliationItemFieldTransactionId] = entry.TransactionId
+		}
+		if entry.ExpectedAmount != nil {
+			fields[models.ReconciliationItemFieldExpectedAmount] = *entry.ExpectedAmount
+		}
+		if entry.SettledAmount != nil {
+			fields[models.ReconciliationItemFieldSettledAmount] = *entry.SettledAmount
+		}
+		if _, err := createRecord(ctx, models.ReconciliationItemSchemaName, fields); err != nil {
+			return "", err
+		}
+	}
+	return reconciliationId, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+func settlementLine(lineNo int, orderCode string, refId string, kind string, amount string) itGateway.SettlementLine {
+	return itGateway.SettlementLine{
+		LineNo:           lineNo,
+		OrderCode:        orderCode,
+		RefTransactionId: refId,
+		Kind:             kind,
+		Amount:           decimal.RequireFromString(amount),
+	}
+}
+
+func settled(id string, orderCode string, refId string, kind string, amount string) settledTransaction {
+	return settledTransaction{
+		Id:               id,
+		Type:             kind,
+		OrderCode:        orderCode,
+		RefTransactionId: refId,
+		Amount:           decimal.RequireFromString(amount),
+	}
+}
+
+func outcomesOf(report reconciliationReport) []string {
+	outcomes := make([]string, 0, len(report.entries))
+	for _, entry := range report.entries {
+		outcomes = append(outcomes, entry.Outcome)
+	}
+	return outcomes
+}
+
+func TestAReportThatAgreesIsBalanced(t *testing.T) {
+	report := reconcile(
+		[]itGateway.SettlementLine{
+			settlementLine(2, "1QAI0001", "T1", itGateway.SettlementKindPayment, "150000"),
+			settlementLine(3, "1QAI0002", "", itGateway.SettlementKindPayment, "90000"),
+		},
+		[]settledTransaction{
+			settled("tx1", "1QAI0001", "T1", models.TransactionTypePayment, "150000"),
+			settled("tx2", "1QAI0002", "T2", models.TransactionTypePayment, "90000"),
+		},
+	)
+
+	assert.Equal(t, []string{models.ReconciliationOutcomeMatched, models.ReconciliationOutcomeMatched}, outcomesOf(report))
+	assert.Equal(t, models.ReconciliationStatusBalanced, report.status())
+	assert.Equal(t, "tx2", report.entries[1].TransactionId, "a line without the gateway's id is matched by order code")
+	assert.True(t, report.settledTotal.Equal(report.expectedTotal))
+}
+
+// The gateway's id is unique to one movement of money, so it wins over an order code that a
+// line may have wrong.
+func TestTheGatewayIdIsTrustedOverTheOrderCode(t *testing.T) {
+	report := reconcile(
+		[]itGateway.SettlementLine{
+			settlementLine(2, "WRONG", "T1", itGateway.SettlementKindPayment, "150000"),
+		},
+		[]settledTransaction{
+			settled("tx1", "1QAI0001", "T1", models.TransactionTypePayment, "150000"),
+		},
+	)
+
+	require.Len(t, report.entries, 1)
+	assert.Equal(t, models.ReconciliationOutcomeMatched, report.entries[0].Outcome)
+	assert.Equal(t, "tx1", report.entries[0].TransactionId)
+}
+
+func TestEachDiscrepancyIsFlagged(t *testing.T) {
+	report := reconcile(
+		[]itGateway.SettlementLine{
+			settlementLine(2, "1QAI0001", "T1", itGateway.SettlementKindPayment, "149000"),
+			settlementLine(3, "1QAI0009", "T9", itGateway.SettlementKindPayment, "50000"),
+		},
+		[]settledTransaction{
+			settled("tx1", "1QAI0001", "T1", models.TransactionTypePayment, "150000"),
+			settled("tx2", "1QAI0002", "T2", models.TransactionTypePayment, "90000"),
+		},
+	)
+
+	assert.Equal(t, []string{
+		models.ReconciliationOutcomeAmountMismatch,
+		models.ReconciliationOutcomeExtra,
+		models.ReconciliationOutcomeMissing,
+	}, outcomesOf(report))
+	assert.Equal(t, models.ReconciliationStatusDiscrepancies, report.status())
+
+	extra := report.entries[1]
+	assert.Empty(t, extra.TransactionId)
+	assert.Nil(t, extra.ExpectedAmount)
+	assert.Equal(t, 3, extra.LineNo)
+
+	missing := report.entries[2]
+	assert.Equal(t, "tx2", missing.TransactionId)
+	assert.Nil(t, missing.SettledAmount)
+	assert.Zero(t, missing.LineNo)
+}
+
+// A refund on the report is not the payment of the same order, and the totals are what the gateway
+// pays out: payments less refunds.
+func TestARefundMatchesOnlyARefund(t *testing.T) {
+	report := reconcile(
+		[]itGateway.SettlementLine{
+			settlementLine(2, "1QAI0001", "", itGateway.SettlementKindPayment, "150000"),
+			settlementLine(3, "1QAI0001", "", itGateway.SettlementKindRefund, "50000"),
+			settlementLine(4, "1QAI0001", "", itGateway.SettlementKindRefund, "20000"),
+		},
+		[]settledTransaction{
+			settled("tx1", "1QAI0001", "", models.TransactionTypePayment, "150000"),
+			settled("tx2", "1QAI0001", "", models.TransactionTypeRefund, "50000"),
+			settled("tx3", "1QAI0001", "", models.TransactionTypeRefund, "20000"),
+		},
+	)
+
+	assert.Equal(t, models.ReconciliationStatusBalanced, report.status())
+	assert.Equal(t, []string{"tx1", "tx2", "tx3"}, []string{
+		report.entries[0].TransactionId, report.entries[1].TransactionId, report.entries[2].TransactionId,
+	}, "two refunds of one order are taken in line order")
+	assert.Equal(t, "80000", report.settledTotal.String())
+	assert.Equal(t, "80000", report.expectedTotal.String())
+}
+
+// An empty report for a day with transactions is not balanced: every transaction is missing.
+func TestAnEmptyReportMissesEveryTransaction(t *testing.T) {
+	report := reconcile(nil, []settledTransaction{
+		settled("tx1", "1QAI0001", "T1", models.TransactionTypePayment, "150000"),
+	})
+
+	assert.Equal(t, []string{models.ReconciliationOutcomeMissing}, outcomesOf(report))
+	assert.Equal(t, models.ReconciliationStatusDiscrepancies, report.status())
+	assert.Zero(t, report.lineCount)
+}
+
+func TestImportRequiresADateAndAReport(t *testing.T) {
+	vErrs := ft.NewClientErrors()
+	_, ok := validateImportSettlement(ImportSettlementCommand{
+		PaymentMethodId: "01JMETHOD",
+		SettlementDate:  "19/10/2026",
+		OrgId:           "01JORG",
+	}, vErrs)
+
+	assert.False(t, ok)
+	assert.Equal(t, 2, vErrs.Count())
+}
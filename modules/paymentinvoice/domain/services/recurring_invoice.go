@@ -0,0 +1,387 @@
+package services
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// recurringCatchUpLimit bounds how many periods one schedule is invoiced for in one run.
+//
+// A month of daily periods is as far as one run catches up. A schedule left inactive for years
+// and then switched back on would otherwise raise every invoice it missed in a single pass, holding
+// the job while it did; the next runs work off the rest, oldest first.
+const recurringCatchUpLimit = 31
+
+// RecurringGeneration is what became of one period of one recurring invoice.
+type RecurringGeneration struct {
+	RecurringInvoiceId string
+	PeriodDate         time.Time
+
+	// InvoiceId is the invoice raised for the period; empty when the period had been invoiced
+	// already and nothing was raised.
+	InvoiceId string
+
+	// Status is the run's status: draft, issued or issue_failed.
+	Status string
+
+	// Detail says why issuing failed; empty otherwise.
+	Detail string
+}
+
+// FindDueRecurringInvoices returns the active schedules with a period due on or before today.
+//
+// A schedule that has never run has no next run date; its start date is what is due. Archived
+// schedules are left out alongside inactive ones, because archiving is how a schedule that has
+// served its purpose is put away.
+func FindDueRecurringInvoices(ctx corectx.Context, today time.Time) ([]*models.RecurringInvoice, error) {
+	engine, err := engineFor(models.RecurringInvoiceSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	todayDate := model.WrapModelDate(today)
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(models.RecurringInvoiceFieldIsActive, dmodel.Equals, true),
+		*dmodel.NewSearchNode().NewCondition(basemodel.FieldIsArchived, dmodel.Equals, false),
+		*dmodel.NewSearchNode().Or(
+			*dmodel.NewSearchNode().NewCondition(
+				models.RecurringInvoiceFieldNextRunDate, dmodel.LessEqual, todayDate),
+			*dmodel.NewSearchNode().And(
+				*dmodel.NewSearchNode().NewCondition(
+					models.RecurringInvoiceFieldNextRunDate, dmodel.IsNotSet),
+				*dmodel.NewSearchNode().NewCondition(
+					models.RecurringInvoiceFieldStartDate, dmodel.LessEqual, todayDate),
+			),
+		),
+	)
+	graph.OrderBy(models.RecurringInvoiceFieldNextRunDate)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  sweepPageSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "FindDueRecurringInvoices")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+
+	due := make([]*models.RecurringInvoice, 0, len(found.Data.Items))
+	for _, item := range found.Data.Items {
+		due = append(due, models.NewRecurringInvoiceFrom(item))
+	}
+	return due, nil
+}
+
+// GenerateRecurring raises an invoice for each period of a schedule that has fallen due.
+//
+// Each period is its own transaction: the invoice, its lines, the run that records the period as
+// invoiced and the schedule's advanced next run date are written together or not at all. The run
+// carries a unique key on the schedule and the period, so a period can be invoiced once however
+// the job is interrupted or overlapped — a second attempt fails on the key and raises nothing.
+//
+// Issuing happens after the period's transaction has committed, not inside it. A draft that could
+// not be issued — a tax withdrawn since the schedule was set up, say — is still the period's
+// invoice, and rolling it back would only raise it again on the next run and fail the same way.
+// The run records the failure instead, where someone can see it and issue the draft by hand.
+func (this *InvoiceDomainService) GenerateRecurring(
+	ctx corectx.Context, recurring *models.RecurringInvoice, today time.Time,
+) ([]RecurringGeneration, error) {
+	recurringId := derefString(recurring.GetId())
+	schedule, err := recurringScheduleOf(recurring)
+	if err != nil {
+		return nil, errors.Wrapf(err, "recurring invoice '%s'", recurringId)
+	}
+
+	due, following := duePeriods(*schedule, today, recurringCatchUpLimit)
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	lines, err := findRecurringInvoiceLines(ctx, recurringId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "recurring invoice '%s'", recurringId)
+	}
+	if len(lines) == 0 {
+		// An invoice with no lines totals zero, which is not a bill anyone meant to send, and it
+		// could not be issued either. The schedule is left where it is until it has lines.
+		return nil, errors.Errorf("recurring invoice '%s' has no lines to invoice", recurringId)
+	}
+
+	generations := make([]RecurringGeneration, 0, len(due))
+	for index, period := range due {
+		next := following
+		if index+1 < len(due) {
+			next = due[index+1]
+		}
+
+		generation, err := generateRecurringPeriod(ctx, recurring, lines, period, next)
+		if err != nil {
+			return generations, errors.Wrapf(err, "recurring invoice '%s' period %s",
+				recurringId, period.Format(time.DateOnly))
+		}
+		if generation.InvoiceId != "" && derefBool(recurring.GetAutoIssue()) {
+			this.issueRecurringPeriod(ctx, generation)
+		}
+		generations = append(generations, *generation)
+	}
+	return generations, nil
+}
+
+// generateRecurringPeriod raises the invoice for one period and advances the schedule past it.
+func generateRecurringPeriod(
+	ctx corectx.Context,
+	recurring *models.RecurringInvoice,
+	lines []*models.RecurringInvoiceLine,
+	period time.Time,
+	next time.Time,
+) (*RecurringGeneration, error) {
+	recurringId := derefString(recurring.GetId())
+	orgId := derefString(recurring.GetOrgId())
+	generation := &RecurringGeneration{RecurringInvoiceId: recurringId, PeriodDate: period}
+
+	err := withInvoiceTransaction(ctx, func(tranxCtx corectx.Context) error {
+		existing, err := findRecurringRun(tranxCtx, recurringId, period)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			fields := dmodel.DynamicFields{
+				models.InvoiceFieldStatus:      models.InvoiceStatusDraft,
+				models.InvoiceFieldPartnerName: derefString(recurring.GetPartnerName()),
+				models.InvoiceFieldCurrencyId:  derefString(recurring.GetCurrencyId()),
+				models.InvoiceFieldOrgId:       orgId,
+			}
+			if taxCode := recurring.GetPartnerTaxCode(); taxCode != nil {
+				fields[models.InvoiceFieldPartnerTaxCode] = *taxCode
+			}
+			if address := recurring.GetPartnerAddress(); address != nil {
+				fields[models.InvoiceFieldPartnerAddress] = *address
+			}
+			if note := recurring.GetNote(); note != nil {
+				fields[models.InvoiceFieldNote] = *note
+			}
+
+			created, err := createRecord(tranxCtx, models.InvoiceSchemaName, fields)
+			if err != nil {
+				return err
+			}
+			invoiceId := derefString(models.NewInvoiceFrom(created).GetId())
+
+			for _, line := range lines {
+				quantity := int32(0)
+				if line.GetQuantity() != nil {
+					quantity = *line.GetQuantity()
+				}
+				unitPrice := derefDecimal(line.GetUnitPrice())
+				if _, err := createRecord(tranxCtx, models.InvoiceLineSchemaName, dmodel.DynamicFields{
+					models.InvoiceLineFieldInvoiceId:      invoiceId,
+					models.InvoiceLineFieldDescription:    derefString(line.GetDescription()),
+					models.InvoiceLineFieldQuantity:       quantity,
+					models.InvoiceLineFieldUnitPrice:      unitPrice,
+					models.InvoiceLineFieldTaxRatePercent: derefDecimal(line.GetTaxRatePercent()),
+					models.InvoiceLineFieldTaxIds:         line.GetTaxIds(),
+					models.InvoiceLineFieldAmount:         unitPrice.Mul(decimal.NewFromInt32(quantity)),
+					models.InvoiceLineFieldOrgId:          orgId,
+				}); err != nil {
+					return err
+				}
+			}
+
+			if _, err := createRecord(tranxCtx, models.RecurringInvoiceRunSchemaName, dmodel.DynamicFields{
+				models.RecurringInvoiceRunFieldRecurringInvoiceId: recurringId,
+				models.RecurringInvoiceRunFieldPeriodDate:         model.WrapModelDate(period),
+				models.RecurringInvoiceRunFieldInvoiceId:          invoiceId,
+				models.RecurringInvoiceRunFieldStatus:             models.RecurringRunStatusDraft,
+				models.RecurringInvoiceRunFieldOrgId:              orgId,
+			}); err != nil {
+				return err
+			}
+			generation.InvoiceId = invoiceId
+			generation.Status = models.RecurringRunStatusDraft
+		}
+
+		// The schedule is advanced even when the period had been invoiced already: a run row
+		// without the advance is what a schedule edited by hand looks like, and leaving the date
+		// behind would find the same period due on every run.
+		return writeRecurringInvoiceFields(tranxCtx, recurringId, dmodel.DynamicFields{
+			models.RecurringInvoiceFieldNextRunDate:     model.WrapModelDate(next),
+			models.RecurringInvoiceFieldLastGeneratedAt: model.WrapModelDateTime(time.Now().UTC()),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return generation, nil
+}
+
+// issueRecurringPeriod issues a period's draft and records on its run how that went.
+func (this *InvoiceDomainService) issueRecurringPeriod(ctx corectx.Context, generation *RecurringGeneration) {
+	status := models.RecurringRunStatusIssued
+	detail := ""
+
+	_, vErrs, err := this.Issue(ctx, IssueCommand{InvoiceId: generation.InvoiceId})
+	switch {
+	case err != nil:
+		status, detail = models.RecurringRunStatusIssueFailed, err.Error()
+	case vErrs.Count() > 0:
+		status, detail = models.RecurringRunStatusIssueFailed, vErrs.ToError().Error()
+	}
+	generation.Status = status
+	generation.Detail = detail
+
+	if err := writeRecurringRunStatus(ctx, generation, status, detail); err != nil {
+		// The invoice's own status is the truth about it; a run left saying draft is stale but
+		// misleads nobody who opens the invoice.
+		generation.Detail = errors.Wrap(err, "the run could not be updated").Error()
+	}
+}
+
+// recurringScheduleOf reads the calendar out of a recurring invoice.
+func recurringScheduleOf(recurring *models.RecurringInvoice) (*recurringSchedule, error) {
+	start := recurring.GetStartDate()
+	if start == nil {
+		return nil, errors.New("no start date")
+	}
+
+	schedule := &recurringSchedule{
+		Start: start.GoTime(),
+		Unit:  derefString(recurring.GetIntervalUnit()),
+		Count: 1,
+	}
+	if count := recurring.GetIntervalCount(); count != nil && *count > 0 {
+		schedule.Count = *count
+	}
+	if end := recurring.GetEndDate(); end != nil {
+		schedule.End = ptrOf(end.GoTime())
+	}
+	if next := recurring.GetNextRunDate(); next != nil {
+		schedule.Next = ptrOf(next.GoTime())
+	}
+	return schedule, nil
+}
+
+// findRecurringInvoiceLines returns the lines each period's invoice is raised with.
+func findRecurringInvoiceLines(ctx corectx.Context, recurringId string) ([]*models.RecurringInvoiceLine, error) {
+	engine, err := engineFor(models.RecurringInvoiceLineSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.RecurringInvoiceLineFieldRecurringInvoiceId, dmodel.Equals, recurringId),
+	)
+	graph.OrderBy(basemodel.FieldCreatedAt)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  invoiceLinePageSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findRecurringInvoiceLines")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+
+	lines := make([]*models.RecurringInvoiceLine, 0, len(found.Data.Items))
+	for _, item := range found.Data.Items {
+		lines = append(lines, models.NewRecurringInvoiceLineFrom(item))
+	}
+	return lines, nil
+}
+
+// findRecurringRun fetches the run recording a period as invoiced, if there is one.
+func findRecurringRun(
+	ctx corectx.Context, recurringId string, period time.Time,
+) (*models.RecurringInvoiceRun, error) {
+	engine, err := engineFor(models.RecurringInvoiceRunSchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.RecurringInvoiceRunFieldRecurringInvoiceId, dmodel.Equals, recurringId),
+		*dmodel.NewSearchNode().NewCondition(
+			models.RecurringInvoiceRunFieldPeriodDate, dmodel.Equals, model.WrapModelDate(period)),
+	)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  1,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findRecurringRun")
+	}
+	if found == nil || !found.HasData || len(found.Data.Items) == 0 {
+		return nil, nil
+	}
+	return models.NewRecurringInvoiceRunFrom(found.Data.Items[0]), nil
+}
+
+// writeRecurringInvoiceFields updates a recurring invoice through the repository.
+//
+// next_run_date and last_generated_at are no_update so a client cannot move a schedule past
+// periods it has not invoiced; generation writes them here instead.
+func writeRecurringInvoiceFields(ctx corectx.Context, recurringPk string, fields dmodel.DynamicFields) error {
+	engine, err := engineFor(models.RecurringInvoiceSchemaName)
+	if err != nil {
+		return err
+	}
+
+	update := dmodel.DynamicFields{models.RecurringInvoiceFieldId: recurringPk}
+	for key, value := range fields {
+		update[key] = value
+	}
+	_, err = engine.ResourceRepository().Update(ctx, update)
+	return errors.Wrap(err, "writeRecurringInvoiceFields")
+}
+
+// writeRecurringRunStatus records how issuing a period's draft went.
+func writeRecurringRunStatus(
+	ctx corectx.Context, generation *RecurringGeneration, status string, detail string,
+) error {
+	run, err := findRecurringRun(ctx, generation.RecurringInvoiceId, generation.PeriodDate)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		return errors.Errorf("no run for period %s", generation.PeriodDate.Format(time.DateOnly))
+	}
+
+	engine, err := engineFor(models.RecurringInvoiceRunSchemaName)
+	if err != nil {
+		return err
+	}
+	_, err = engine.ResourceRepository().Update(ctx, dmodel.DynamicFields{
+		models.RecurringInvoiceRunFieldId:     derefString(run.GetId()),
+		models.RecurringInvoiceRunFieldStatus: status,
+		models.RecurringInvoiceRunFieldDetail: detail,
+	})
+	return errors.Wrap(err, "writeRecurringRunStatus")
+}
+
+func derefBool(value *bool) bool {
+	if value == nil {
+		return false
+	}
+	return *value
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// The calendar of a recurring invoice.
+//
+// Every period is counted from the start date rather than from the period before it. Adding a
+// month to the period before would drift: January 31st plus a month is March 3rd in Go's calendar,
+// and every later period would then fall on the 3rd. Counted from the start, a schedule that begins
+// on the 31st bills on the last day of each shorter month and is back on the 31st after it.
+
+// recurringSchedule is what the calendar needs of a recurring invoice.
+type recurringSchedule struct {
+	Start time.Time
+	// End is the last date a period may fall on; nil runs until the schedule is deactivated.
+	End *time.Time
+	// Next is the next period not yet invoiced; nil before the first one is.
+	Next  *time.Time
+	Unit  string
+	Count int32
+}
+
+// maxPeriodIndex bounds the search for a schedule's next period. A daily schedule reaches it after
+// some 270 years; anything beyond is a corrupt row, and refusing it is better than spinning on it.
+const maxPeriodIndex = 100000
+
+// periodDate is the date of period index of a schedule anchored at start.
+func periodDate(start time.Time, unit string, count int32, index int) time.Time {
+	step := index * int(count)
+	switch unit {
+	case models.RecurringIntervalDay:
+		return start.AddDate(0, 0, step)
+	case models.RecurringIntervalWeek:
+		return start.AddDate(0, 0, 7*step)
+	case models.RecurringIntervalYear:
+		return addMonthsClamped(start, 12*step)
+	default:
+		return addMonthsClamped(start, step)
+	}
+}
+
+// addMonthsClamped moves a date by whole months, landing on the last day of the target month when
+// it is shorter than the day it started on.
+func addMonthsClamped(date time.Time, months int) time.Time {
+	firstOfTarget := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()).AddDate(0, months, 0)
+	lastDay := firstOfTarget.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfTarget.Year(), firstOfTarget.Month(), day, 0, 0, 0, 0, date.Location())
+}
+
+// duePeriods lists the periods of a schedule that have fallen due by today and are not yet
+// invoiced, oldest first and at most limit of them, together with the period that follows the
+// last one listed.
+//
+// A schedule that was down for several periods gets each of them, not one invoice for the lot: a
+// customer billed monthly owes one invoice per month, however late they are raised. limit bounds
+// one run's catch-up so a schedule left off for years cannot hold the job for all of it; the rest
+// is caught up by the runs that follow.
+func duePeriods(schedule recurringSchedule, today time.Time, limit int) ([]time.Time, time.Time) {
+	next := schedule.Start
+	if schedule.Next != nil {
+		next = *schedule.Next
+	}
+
+	index := 0
+	for periodDate(schedule.Start, schedule.Unit, schedule.Count, index).Before(next) {
+		index++
+		if index > maxPeriodIndex {
+			return nil, next
+		}
+	}
+
+	due := []time.Time{}
+	for len(due) < limit {
+		period := periodDate(schedule.Start, schedule.Unit, schedule.Count, index)
+		if period.After(today) || (schedule.End != nil && period.After(*schedule.End)) {
+			break
+		}
+		due = append(due, period)
+		index++
+	}
+	return due, periodDate(schedule.Start, schedule.Unit, schedule.Count, index)
+}
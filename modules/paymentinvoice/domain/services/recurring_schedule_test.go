@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+func day(value string) time.Time {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func dates(values []time.Time) []string {
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		formatted = append(formatted, value.Format(time.DateOnly))
+	}
+	return formatted
+}
+
+// A schedule that starts on the 31st bills on the last day of a shorter month and returns to the
+// 31st after it, rather than drifting to whatever day the shorter month pushed it to.
+func TestAMonthlyScheduleDoesNotDriftOffTheEndOfTheMonth(t *testing.T) {
+	schedule := recurringSchedule{Start: day("2026-01-31"), Unit: models.RecurringIntervalMonth, Count: 1}
+
+	due, next := duePeriods(schedule, day("2026-04-30"), 10)
+
+	assert.Equal(t, []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}, dates(due))
+	assert.Equal(t, "2026-05-31", next.Format(time.DateOnly))
+}
+
+// After downtime every missed period is due, once each, and a run picks up where the last one
+// stopped rather than from the start.
+func TestCatchUpListsEachMissedPeriodFromWhereItStopped(t *testing.T) {
+	next := day("2026-03-01")
+	schedule := recurringSchedule{
+		Start: day("2026-01-01"), Next: &next, Unit: models.RecurringIntervalMonth, Count: 1,
+	}
+
+	due, following := duePeriods(schedule, day("2026-06-15"), 10)
+
+	assert.Equal(t, []string{"2026-03-01", "2026-04-01", "2026-05-01", "2026-06-01"}, dates(due))
+	assert.Equal(t, "2026-07-01", following.Format(time.DateOnly))
+}
+
+// One run's catch-up is bounded, and the next run starts where it left off.
+func TestCatchUpIsBoundedPerRun(t *testing.T) {
+	schedule := recurringSchedule{Start: day("2026-01-01"), Unit: models.RecurringIntervalWeek, Count: 1}
+
+	due, next := duePeriods(schedule, day("2026-12-31"), 3)
+
+	require.Len(t, due, 3)
+	assert.Equal(t, "2026-01-22", next.Format(time.DateOnly))
+
+	schedule.Next = &next
+	due, _ = duePeriods(schedule, day("2026-12-31"), 3)
+	assert.Equal(t, "2026-01-22", due[0].Format(time.DateOnly))
+}
+
+// No period is due past the end date, nor before the start.
+func TestNothingIsDueOutsideTheSchedule(t *testing.T) {
+	end := day("2026-02-15")
+	schedule := recurringSchedule{
+		Start: day("2026-01-01"), End: &end, Unit: models.RecurringIntervalMonth, Count: 1,
+	}
+
+	due, _ := duePeriods(schedule, day("2026-12-31"), 10)
+	assert.Equal(t, []string{"2026-01-01", "2026-02-01"}, dates(due))
+
+	due, _ = duePeriods(schedule, day("2025-12-31"), 10)
+	assert.Empty(t, due)
+}
+
+// A quarterly schedule is three months a period, and a yearly one lands on February 28th in the
+// years without a 29th.
+func TestIntervalCountsMultiplyTheUnit(t *testing.T) {
+	assert.Equal(t, "2026-10-15",
+		periodDate(day("2026-01-15"), models.RecurringIntervalMonth, 3, 3).Format(time.DateOnly))
+	assert.Equal(t, "2029-02-28",
+		periodDate(day("2028-02-29"), models.RecurringIntervalYear, 1, 1).Format(time.DateOnly))
+	assert.Equal(t, "2026-01-15",
+		periodDate(day("2026-01-01"), models.RecurringIntervalWeek, 2, 1).Format(time.DateOnly))
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	stdErr "errors"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+// SyncSender posts one notification to the ordering system.
+//
+// The app layer's result-sync client implements it: how a request is signed and how often it is
+// re-tried within one round is transport, while when a delivery is next attempted and when it is
+// given up on is recorded here.
+type SyncSender interface {
+	Deliver(ctx context.Context, dispatch SyncDispatch) SyncOutcome
+}
+
+// SyncDispatch is what the sender needs to post one notification.
+type SyncDispatch struct {
+	ReturnUrl string
+
+	// PaymentMethodCode selects the secret the request is signed with.
+	PaymentMethodCode string
+
+	Payload []byte
+}
+
+// SyncRetryPolicy bounds how long a failing delivery is retried.
+type SyncRetryPolicy struct {
+	// MaxAttempts is the number of attempts, counted across rounds, after which a delivery is
+	// dead-lettered.
+	MaxAttempts int
+
+	// BackoffBase is the wait after the first failed attempt. It doubles with every attempt after.
+	BackoffBase time.Duration
+
+	// BackoffCap bounds the doubling, so a delivery far into its attempts is still retried on a
+	// schedule someone can reason about.
+	BackoffCap time.Duration
+}
+
+// stalePendingAfter is how long a delivery may stay pending before the retry sweep takes it.
+//
+// A delivery is pending only between being recorded and its first round ending, which the sender's
+// own timeout bounds to seconds. One pending for longer belongs to a process that stopped mid-round
+// and will never record the outcome.
+const stalePendingAfter = 15 * time.Minute
+
+// SyncDeliveryDomainService records each notification to the ordering system and retries the
+// ones that did not get through.
+type SyncDeliveryDomainService struct {
+	sender SyncSender
+	policy SyncRetryPolicy
+
+	// now is injected so the schedule can be tested against a fixed clock.
+	now func() time.Time
+}
+
+func NewSyncDeliveryDomainService(sender SyncSender, policy SyncRetryPolicy) *SyncDeliveryDomainService {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BackoffBase <= 0 {
+		policy.BackoffBase = time.Minute
+	}
+	if policy.BackoffCap < policy.BackoffBase {
+		policy.BackoffCap = policy.BackoffBase
+	}
+	return &SyncDeliveryDomainService{sender: sender, policy: policy, now: time.Now}
+}
+
+// SyncNotice is one notification to send about one order.
+type SyncNotice struct {
+	OrderPk           string
+	PaymentMethodCode string
+	Payload           []byte
+}
+
+// SyncAttempt is what became of one round of a delivery.
+type SyncAttempt struct {
+	DeliveryId string
+	OrderRef   string
+
+	// Status is the delivery's status after the round.
+	Status  string
+	Outcome SyncOutcome
+}
+
+// Notify records a notification and makes its first round of attempts.
+//
+// The delivery is written before anything is sent, so a process that stops mid-round leaves a
+// pending row for the retry sweep rather than no trace at all. An order with no return_url has
+// nobody to notify; that is recorded on the order as delivered and no delivery is kept.
+func (this *SyncDeliveryDomainService) Notify(ctx corectx.Context, notice SyncNotice) (*SyncAttempt, error) {
+	order, err := findOrderById(ctx, notice.OrderPk)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, errors.Errorf("Notify: no order with id '%s'", notice.OrderPk)
+	}
+
+	returnUrl := derefString(order.GetReturnUrl())
+	if returnUrl == "" {
+		outcome := SyncOutcome{Status: SyncStatusSuccess, At: this.now()}
+		return &SyncAttempt{
+			OrderRef: derefString(order.GetOrderId()),
+			Status:   models.SyncDeliveryStatusDelivered,
+			Outcome:  outcome,
+		}, recordSyncOutcome(ctx, notice.OrderPk, outcome)
+	}
+
+	created, err := createRecord(ctx, models.SyncDeliverySchemaName, dmodel.DynamicFields{
+		models.SyncDeliveryFieldOrderId:           notice.OrderPk,
+		models.SyncDeliveryFieldOrderRef:          derefString(order.GetOrderId()),
+		models.SyncDeliveryFieldReturnUrl:         returnUrl,
+		models.SyncDeliveryFieldPaymentMethodCode: notice.PaymentMethodCode,
+		models.SyncDeliveryFieldPayload:           string(notice.Payload),
+		models.SyncDeliveryFieldStatus:            models.SyncDeliveryStatusPending,
+		models.SyncDeliveryFieldAttempts:          int32(0),
+		models.SyncDeliveryFieldOrgId:             derefString(order.GetOrgId()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return this.attempt(ctx, models.NewSyncDeliveryFrom(created), false)
+}
+
+// RetryDue makes another round of attempts at every delivery whose wait is over.
+//
+// One delivery's failure to be written does not stop the sweep; it is reported alongside the
+// others and taken again on the next run.
+func (this *SyncDeliveryDomainService) RetryDue(ctx corectx.Context) ([]SyncAttempt, error) {
+	due, err := this.findDueDeliveries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]SyncAttempt, 0, len(due))
+	var errs []error
+	for _, delivery := range due {
+		attempt, err := this.attempt(ctx, delivery, false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		attempts = append(attempts, *attempt)
+	}
+	return attempts, stdErr.Join(errs...)
+}
+
+// ReplayDeliveryCommand asks for one delivery to be sent again now.
+type ReplayDeliveryCommand struct {
+	DeliveryId string
+}
+
+// Replay sends a delivery again, whatever its status.
+//
+// It is how a dead-lettered delivery is sent once the tenant is back, and how one a tenant says it
+// never received is sent again. The payload is the stored one: a replay repeats what was said, it
+// does not re-read the order. A replay that fails does not bring a dead-lettered delivery back
+// into the retry schedule — whoever replayed it is already watching it.
+func (this *SyncDeliveryDomainService) Replay(
+	ctx corectx.Context, cmd ReplayDeliveryCommand,
+) (*SyncAttempt, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+	if cmd.DeliveryId == "" {
+		appendFieldViolation(vErrs, models.SyncDeliveryFieldId,
+			"paymentinvoice.sync_delivery_required", "no delivery was identified")
+		return nil, vErrs, nil
+	}
+
+	delivery, err := findSyncDeliveryById(ctx, cmd.DeliveryId)
+	if err != nil {
+		return nil, vErrs, err
+	}
+	if delivery == nil {
+		appendFieldViolation(vErrs, models.SyncDeliveryFieldId,
+			"paymentinvoice.sync_delivery_not_found", "no delivery with id '"+cmd.DeliveryId+"'")
+		return nil, vErrs, nil
+	}
+
+	attempt, err := this.attempt(ctx, delivery, true)
+	return attempt, vErrs, err
+}
+
+// attempt makes one round of attempts at a delivery and records how it went, on the delivery and
+// on its order.
+func (this *SyncDeliveryDomainService) attempt(
+	ctx corectx.Context, delivery *models.SyncDelivery, replay bool,
+) (*SyncAttempt, error) {
+	outcome := this.sender.Deliver(ctx, SyncDispatch{
+		ReturnUrl:         derefString(delivery.GetReturnUrl()),
+		PaymentMethodCode: derefString(delivery.GetPaymentMethodCode()),
+		Payload:           []byte(derefString(delivery.GetPayload())),
+	})
+	outcome.At = this.now()
+
+	priorAttempts := 0
+	if attempts := delivery.GetAttempts(); attempts != nil {
+		priorAttempts = int(*attempts)
+	}
+	state := nextDeliveryState(this.policy, priorAttempts, outcome)
+	if replay && !outcome.Succeeded() && derefString(delivery.GetStatus()) == models.SyncDeliveryStatusDeadLettered {
+		state.Status = models.SyncDeliveryStatusDeadLettered
+		state.NextAttemptAt = nil
+	}
+
+	fields := dmodel.DynamicFields{
+		models.SyncDeliveryFieldStatus:        state.Status,
+		models.SyncDeliveryFieldAttempts:      int32(state.Attempts),
+		models.SyncDeliveryFieldLastAttemptAt: model.WrapModelDateTime(outcome.At),
+		models.SyncDeliveryFieldLastDetail:    outcome.Detail,
+	}
+	if state.NextAttemptAt != nil {
+		fields[models.SyncDeliveryFieldNextAttemptAt] = model.WrapModelDateTime(*state.NextAttemptAt)
+	}
+	if outcome.Succeeded() {
+		fields[models.SyncDeliveryFieldDeliveredAt] = model.WrapModelDateTime(outcome.At)
+	}
+
+	deliveryId := derefString(delivery.GetId())
+	if err := writeSyncDeliveryFields(ctx, deliveryId, fields); err != nil {
+		return nil, err
+	}
+
+	result := &SyncAttempt{
+		DeliveryId: deliveryId,
+		OrderRef:   derefString(delivery.GetOrderRef()),
+		Status:     state.Status,
+		Outcome:    outcome,
+	}
+	// The order's summary is written after the delivery, and its failure is reported rather than
+	// undoing the delivery's: the delivery is the record the retry sweep works from.
+	return result, recordSyncOutcome(ctx, derefString(delivery.GetOrderId()), outcome)
+}
+
+// deliveryState is where a delivery stands after a round of attempts.
+type deliveryState struct {
+	Status   string
+	Attempts int
+
+	// NextAttemptAt is set only for a delivery still to be retried.
+	NextAttemptAt *time.Time
+}
+
+// nextDeliveryState decides where a delivery stands after a round.
+func nextDeliveryState(policy SyncRetryPolicy, priorAttempts int, outcome SyncOutcome) deliveryState {
+	attempts := priorAttempts + outcome.Attempts
+	if outcome.Succeeded() {
+		return deliveryState{Status: models.SyncDeliveryStatusDelivered, Attempts: attempts}
+	}
+	if attempts >= policy.MaxAttempts {
+		return deliveryState{Status: models.SyncDeliveryStatusDeadLettered, Attempts: attempts}
+	}
+	next := outcome.At.Add(deliveryBackoff(policy, attempts))
+	return deliveryState{Status: models.SyncDeliveryStatusFailed, Attempts: attempts, NextAttemptAt: &next}
+}
+
+// deliveryBackoff is the wait before the next round of a delivery that has failed attempts times.
+func deliveryBackoff(policy SyncRetryPolicy, attempts int) time.Duration {
+	wait := policy.BackoffBase
+	for doubling := 1; doubling < attempts; doubling++ {
+		wait *= 2
+		if wait >= policy.BackoffCap {
+			return policy.BackoffCap
+		}
+	}
+	return wait
+}
+
+// findDueDeliveries returns the failed deliveries whose wait is over, and the pending ones a
+// stopped process left behind, soonest due first.
+func (this *SyncDeliveryDomainService) findDueDeliveries(ctx corectx.Context) ([]*models.SyncDelivery, error) {
+	engine, err := engineFor(models.SyncDeliverySchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := this.now()
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().Or(
+			*dmodel.NewSearchNode().And(
+				*dmodel.NewSearchNode().NewCondition(
+					models.SyncDeliveryFieldStatus, dmodel.Equals, models.SyncDeliveryStatusFailed),
+				*dmodel.NewSearchNode().NewCondition(
+					models.SyncDeliveryFieldNextAttemptAt, dmodel.LessEqual, now),
+			),
+			*dmodel.NewSearchNode().And(
+				*dmodel.NewSearchNode().NewCondition(
+					models.SyncDeliveryFieldStatus, dmodel.Equals, models.SyncDeliveryStatusPending),
+				*dmodel.NewSearchNode().NewCondition(
+					basemodel.FieldCreatedAt, dmodel.LessThan, now.Add(-stalePendingAfter)),
+			),
+		),
+	)
+	graph.OrderBy(models.SyncDeliveryFieldNextAttemptAt)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Graph: graph,
+		Page:  0,
+		Size:  sweepPageSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findDueDeliveries")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+
+	due := make([]*models.SyncDelivery, 0, len(found.Data.Items))
+	for _, item := range found.Data.Items {
+		due = append(due, models.NewSyncDeliveryFrom(item))
+	}
+	return due, nil
+}
+
+// findSyncDeliveryById fetches one delivery by primary key.
+func findSyncDeliveryById(ctx corectx.Context, deliveryId string) (*models.SyncDelivery, error) {
+	engine, err := engineFor(models.SyncDeliverySchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		models.SyncDeliveryFieldId: deliveryId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "findSyncDeliveryById")
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return models.NewSyncDeliveryFrom(found.Data), nil
+}
+
+// writeSyncDeliveryFields updates a delivery through the repository. Every field after the
+// payload is no_update, so a delivery's history can be written only here.
+func writeSyncDeliveryFields(ctx corectx.Context, deliveryPk string, fields dmodel.DynamicFields) error {
+	engine, err := engineFor(models.SyncDeliverySchemaName)
+	if err != nil {
+		return err
+	}
+
+	update := dmodel.DynamicFields{models.SyncDeliveryFieldId: deliveryPk}
+	for key, value := range fields {
+		update[key] = value
+	}
+	_, err = engine.ResourceRepository().Update(ctx, update)
+	return errors.Wrap(err, "writeSyncDeliveryFields")
+}
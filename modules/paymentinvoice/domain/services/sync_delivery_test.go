@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+)
+
+var testRetryPolicy = SyncRetryPolicy{
+	MaxAttempts: 10,
+	BackoffBase: time.Minute,
+	BackoffCap:  time.Hour,
+}
+
+func failedRound(attempts int, at time.Time) SyncOutcome {
+	return SyncOutcome{Status: SyncStatusFailure, Attempts: attempts, Detail: "503", At: at}
+}
+
+func TestADeliveredRoundEndsTheSchedule(t *testing.T) {
+	state := nextDeliveryState(testRetryPolicy, 4, SyncOutcome{Status: SyncStatusSuccess, Attempts: 2})
+
+	assert.Equal(t, models.SyncDeliveryStatusDelivered, state.Status)
+	assert.Equal(t, 6, state.Attempts, "attempts are counted across rounds")
+	assert.Nil(t, state.NextAttemptAt)
+}
+
+// The wait after a failed round doubles with every attempt so far, so a tenant that is down for a
+// day is not called every few minutes for all of it.
+func TestTheWaitDoublesWithEveryFailedAttempt(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	first := nextDeliveryState(testRetryPolicy, 0, failedRound(1, at))
+	require.NotNil(t, first.NextAttemptAt)
+	assert.Equal(t, models.SyncDeliveryStatusFailed, first.Status)
+	assert.Equal(t, time.Minute, first.NextAttemptAt.Sub(at))
+
+	later := nextDeliveryState(testRetryPolicy, 1, failedRound(3, at))
+	require.NotNil(t, later.NextAttemptAt)
+	assert.Equal(t, 8*time.Minute, later.NextAttemptAt.Sub(at))
+}
+
+func TestTheWaitIsCapped(t *testing.T) {
+	assert.Equal(t, time.Hour, deliveryBackoff(testRetryPolicy, 9))
+	assert.Equal(t, time.Hour, deliveryBackoff(testRetryPolicy, 1000), "a large count must not overflow")
+}
+
+// A delivery that has used its attempts is dead-lettered rather than retried forever, and gets no
+// next attempt: only a replay sends it again.
+func TestADeliveryOutOfAttemptsIsDeadLettered(t *testing.T) {
+	state := nextDeliveryState(testRetryPolicy, 8, failedRound(3, time.Now()))
+
+	assert.Equal(t, models.SyncDeliveryStatusDeadLettered, state.Status)
+	assert.Equal(t, 11, state.Attempts)
+	assert.Nil(t, state.NextAttemptAt)
+}
+
+// A misconfigured policy must not become one that never retries or retries without waiting.
+func TestADegenerateRetryPolicyFallsBackToSafeValues(t *testing.T) {
+	service := NewSyncDeliveryDomainService(nil, SyncRetryPolicy{})
+
+	assert.Equal(t, 1, service.policy.MaxAttempts)
+	assert.Positive(t, service.policy.BackoffBase)
+	assert.GreaterOrEqual(t, service.policy.BackoffCap, service.policy.BackoffBase)
+}
@@ -18,6 +18,15 @@ func TestEngineSchemaNamesCoverEveryResource(t *testing.T) {
 			models.TransactionSchemaName,
 			models.InvoiceSchemaName,
 			models.InvoiceLineSchemaName,
+			models.PaymentAllocationSchemaName,
+			models.CreditNoteSchemaName,
+			models.CreditNoteLineSchemaName,
+			models.RecurringInvoiceSchemaName,
+			models.RecurringInvoiceLineSchemaName,
+			models.RecurringInvoiceRunSchemaName,
+			models.SyncDeliverySchemaName,
+			models.ReconciliationSchemaName,
+			models.ReconciliationItemSchemaName,
 		},
 		EngineSchemaNames())
 }
@@ -106,3 +106,103 @@ func TestAMissingInvoiceServiceIsAWiringError(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "SetInvoiceService")
 }
+
+// Allocating a payment is what marks an invoice paid, so it is not folded into "update" any more
+// than issuing is. Its path is scoped to the invoice, like issue's.
+func TestAllocatePaymentIsAnInvoiceScopedActionWithItsOwnPermission(t *testing.T) {
+	testEngine := newInvoiceTestEngine(t)
+	require.NoError(t, defineInvoiceActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionAllocatePayment)
+	require.True(t, exists)
+
+	assert.Equal(t, constants.ActionAllocatePayment, definition.Permission)
+	assert.Equal(t, drif.ActionTypeGeneric, definition.ActionType)
+	assert.Equal(t, ":id/allocate_payment", definition.RestPath)
+	assert.Regexp(t, drif.RestPathRegex, definition.RestPath)
+}
+
+// Releasing keeps the allocation as the record that it once counted, so it is its own permission
+// rather than "delete" — a role that may correct an allocation need not be able to erase one.
+func TestReleaseIsAnAllocationScopedActionWithItsOwnPermission(t *testing.T) {
+	schema := dmodel.DefineModel("paymentinvoice_payment_allocation").Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+
+	require.NoError(t, definePaymentAllocationActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionRelease)
+	require.True(t, exists)
+
+	assert.Equal(t, constants.ActionRelease, definition.Permission)
+	assert.NotEqual(t, drif.PermissionDelete, definition.Permission)
+	assert.Equal(t, ":id/release", definition.RestPath)
+}
+
+// Crediting takes money off an issued invoice and may return it to the customer, so it is neither
+// "update" nor folded into issue.
+func TestCreditIsAnInvoiceScopedActionWithItsOwnPermission(t *testing.T) {
+	testEngine := newInvoiceTestEngine(t)
+	require.NoError(t, defineInvoiceActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionCredit)
+	require.True(t, exists)
+
+	assert.Equal(t, constants.ActionCredit, definition.Permission)
+	assert.NotEqual(t, drif.PermissionUpdate, definition.Permission)
+	assert.Equal(t, ":id/credit", definition.RestPath)
+	assert.Regexp(t, drif.RestPathRegex, definition.RestPath)
+}
+
+// A quantity that is not a whole positive number is refused rather than truncated: crediting 1 of
+// a requested 1.5 would issue a note for less than the caller believes it issued.
+func TestAMalformedCreditLineIsRefused(t *testing.T) {
+	for name, line := range map[string]any{
+		"not_an_object": "01LINE00000000000000000000",
+		"no_quantity":   map[string]any{paramInvoiceLineId: "01LINE00000000000000000000"},
+		"fractional":    map[string]any{paramInvoiceLineId: "01LINE00000000000000000000", paramQuantity: 1.5},
+		"zero":          map[string]any{paramInvoiceLineId: "01LINE00000000000000000000", paramQuantity: float64(0)},
+		"negative":      map[string]any{paramInvoiceLineId: "01LINE00000000000000000000", paramQuantity: float64(-2)},
+	} {
+		_, vErrs := buildCreditCommand(dmodel.DynamicFields{
+			paramInvoiceId: "01INVOICE00000000000000000",
+			paramLines:     []any{line},
+		})
+
+		assert.Equal(t, 1, vErrs.Count(), name)
+	}
+}
+
+func TestACreditCommandIsReadFromItsParams(t *testing.T) {
+	cmd, vErrs := buildCreditCommand(dmodel.DynamicFields{
+		paramInvoiceId: "01INVOICE00000000000000000",
+		paramReason:    "damaged on delivery",
+		paramRefund:    true,
+		paramLines: []any{
+			map[string]any{paramInvoiceLineId: "01LINE00000000000000000000", paramQuantity: float64(2)},
+		},
+	})
+
+	require.Zero(t, vErrs.Count())
+	assert.Equal(t, "01INVOICE00000000000000000", cmd.InvoiceId)
+	assert.True(t, cmd.Refund)
+	require.NotNil(t, cmd.Reason)
+	assert.Equal(t, "damaged on delivery", *cmd.Reason)
+	require.Len(t, cmd.Lines, 1)
+	assert.Equal(t, int32(2), cmd.Lines[0].Quantity)
+}
+
+// Downloading carries "read" and is served as GET. Printing an invoice grants nothing the caller
+// could not already read, so a permission of its own would only let a role be granted a download
+// of documents it may not see.
+func TestDownloadPdfIsAReadOfTheInvoice(t *testing.T) {
+	testEngine := newInvoiceTestEngine(t)
+	require.NoError(t, defineInvoiceActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionDownloadPdf)
+	require.True(t, exists)
+
+	assert.Equal(t, drif.PermissionRead, definition.Permission)
+	assert.Equal(t, "GET", definition.ActionType.HttpMethod())
+	assert.Equal(t, ":id/download_pdf", definition.RestPath)
+}
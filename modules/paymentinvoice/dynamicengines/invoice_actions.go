@@ -1,14 +1,19 @@
 package dynamicengines
 
 import (
+	stdErr "errors"
+	"math"
 	"time"
 
 	"go.bryk.io/pkg/errors"
 
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
 )
 
 // paramInvoiceId names the invoice in the request path.
@@ -17,19 +22,63 @@ import (
 // which is the shape the engine's ":id" RestPath produces.
 const paramInvoiceId = "id"
 
-// defineInvoiceActions adds the issue action.
+// Parameter names of allocate_payment. They are the allocation's own field names, so a client
+// reads back under the same keys what it sent.
+const (
+	paramTransactionId = "transaction_id"
+	paramSourceKind    = "source"
+	paramReference     = "reference"
+	paramNote          = "note"
+)
+
+// Parameter names of credit.
+const (
+	paramLines         = "lines"
+	paramInvoiceLineId = "invoice_line_id"
+	paramQuantity      = "quantity"
+	paramReason        = "reason"
+	paramRefund        = "refund"
+)
+
+// defineInvoiceActions adds the issue, allocate_payment, credit and download_pdf actions.
 //
 // Issuing carries its own permission rather than reusing "update" for the same reason refunding
 // does: an issued invoice is an accounting document, and being allowed to correct a draft's note is
-// not the same authority as being allowed to close one and mint its number.
+// not the same authority as being allowed to close one and mint its number. Allocating a payment
+// has its own for the same reason: it is what marks an invoice paid. So does crediting, which
+// takes money off an issued document and may give it back to the customer. Downloading does not:
+// it carries "read".
 func defineInvoiceActions(engine drif.DynamicResourceEngine) error {
-	return engine.DefineAction(drif.DynamicActionDefinition{
-		ActionName:  constants.ActionIssue,
-		ActionType:  drif.ActionTypeGeneric,
-		RestPath:    ":id/" + constants.ActionIssue,
-		Permission:  constants.ActionIssue,
-		MainProcess: processIssue,
-	})
+	return stdErr.Join(
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  constants.ActionIssue,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/" + constants.ActionIssue,
+			Permission:  constants.ActionIssue,
+			MainProcess: processIssue,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  constants.ActionAllocatePayment,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/" + constants.ActionAllocatePayment,
+			Permission:  constants.ActionAllocatePayment,
+			MainProcess: processAllocatePayment,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  constants.ActionCredit,
+			ActionType:  drif.ActionTypeGeneric,
+			RestPath:    ":id/" + constants.ActionCredit,
+			Permission:  constants.ActionCredit,
+			MainProcess: processCredit,
+		}),
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  constants.ActionDownloadPdf,
+			ActionType:  drif.ActionTypeRead,
+			RestPath:    ":id/" + constants.ActionDownloadPdf,
+			Permission:  drif.PermissionRead,
+			MainProcess: processDownloadPdf,
+		}),
+	)
 }
 
 // processIssue closes a draft invoice.
@@ -61,12 +110,180 @@ func processIssue(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionRes
 			"subtotal_amount": result.SubtotalAmount.String(),
 			"tax_amount":      result.TaxAmount.String(),
 			"total_amount":    result.TotalAmount.String(),
+			"tax_breakdown":   taxBreakdownData(result.TaxBreakdown),
+		},
+	}, nil
+}
+
+// taxBreakdownData shapes a breakdown for the response, amounts as strings for the reason above.
+func taxBreakdownData(breakdown []itExt.TaxBreakdown) []map[string]any {
+	data := make([]map[string]any, 0, len(breakdown))
+	for _, entry := range breakdown {
+		data = append(data, map[string]any{
+			"tax_id":        entry.TaxId,
+			"code":          entry.Code,
+			"tax_type":      entry.TaxType,
+			"rate_percent":  entry.RatePercent.String(),
+			"account_label": entry.AccountLabel,
+			"base":          entry.Base.String(),
+			"amount":        entry.Amount.String(),
+		})
+	}
+	return data
+}
+
+// processAllocatePayment counts a payment or a manual receipt against an issued invoice.
+func processAllocatePayment(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireInvoiceService()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := services.AllocateCommand{
+		InvoiceId:     readString(input.Params, paramInvoiceId),
+		TransactionId: readString(input.Params, paramTransactionId),
+		Source:        readString(input.Params, paramSourceKind),
+		Reference:     readOptionalString(input.Params, paramReference),
+		Note:          readOptionalString(input.Params, paramNote),
+	}
+	amount, ok := readDecimal(input.Params, paramAmount)
+	if !ok {
+		vErrs := ft.NewClientErrors()
+		vErrs.Append(*ft.NewBusinessViolation(paramAmount,
+			"paymentinvoice.amount_malformed",
+			"the amount must be a number"))
+		return &drif.ActionResult{ClientErrors: *vErrs}, nil
+	}
+	cmd.Amount = amount
+
+	result, cErrs, err := service.Allocate(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+	return toAllocationActionResult(result), nil
+}
+
+// toAllocationActionResult shapes an allocation outcome, with the amounts as strings for the same
+// reason the issue action sends them so.
+func toAllocationActionResult(result *services.AllocationResult) *drif.ActionResult {
+	return &drif.ActionResult{
+		HasData: true,
+		Data: map[string]any{
+			"allocation_id":  result.AllocationId,
+			"invoice_id":     result.InvoiceId,
+			"invoice_status": result.InvoiceStatus,
+			"amount_paid":    result.AmountPaid.String(),
+			"amount_due":     result.AmountDue.String(),
+		},
+	}
+}
+
+// processCredit issues a credit note against an invoice.
+func processCredit(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireInvoiceService()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, vErrs := buildCreditCommand(input.Params)
+	if vErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *vErrs}, nil
+	}
+
+	result, cErrs, err := service.Credit(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+
+	data := map[string]any{
+		"credit_note_id":  result.CreditNoteId,
+		"number":          result.Number,
+		"issued_at":       result.IssuedAt.Format(time.RFC3339),
+		"subtotal_amount": result.SubtotalAmount.String(),
+		"tax_amount":      result.TaxAmount.String(),
+		"total_amount":    result.TotalAmount.String(),
+		"invoice_status":  result.InvoiceStatus,
+		"amount_due":      result.AmountDue.String(),
+		"refund_status":   result.RefundStatus,
+		"refund_amount":   result.RefundAmount.String(),
+	}
+	if result.RefundError != "" {
+		data["refund_error"] = result.RefundError
+	}
+	return &drif.ActionResult{HasData: true, Data: data}, nil
+}
+
+// processDownloadPdf renders an invoice with its organization's template.
+func processDownloadPdf(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireInvoicePrintService()
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErrs, err := service.RenderPdf(ctx, readString(input.Params, paramInvoiceId))
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+
+	return &drif.ActionResult{
+		HasData: true,
+		Data: drif.FileResultData{
+			FileName:    result.FileName,
+			ContentType: "application/pdf",
+			Content:     result.Content,
 		},
 	}, nil
 }
 
-// invoiceService is the domain service the issue action delegates to. Like the order service it is
-// a package variable rather than a derived resource service: issuing is not CRUD on an invoice.
+// buildCreditCommand reads the lines to credit, each an object naming an invoice line and a
+// quantity. A quantity arrives as a JSON number, which decodes as float64; one that is not whole
+// is refused rather than truncated.
+func buildCreditCommand(params dmodel.DynamicFields) (services.CreditCommand, *ft.ClientErrors) {
+	vErrs := ft.NewClientErrors()
+	cmd := services.CreditCommand{
+		InvoiceId: readString(params, paramInvoiceId),
+		Reason:    readOptionalString(params, paramReason),
+	}
+	if refund, ok := params[paramRefund].(bool); ok {
+		cmd.Refund = refund
+	}
+
+	rawLines, _ := params[paramLines].([]any)
+	for _, rawLine := range rawLines {
+		line, ok := rawLine.(map[string]any)
+		if !ok {
+			vErrs.Append(*ft.NewBusinessViolation(paramLines,
+				"paymentinvoice.credit_line_malformed",
+				"each credited line must name an invoice line and a quantity"))
+			return cmd, vErrs
+		}
+		quantity, ok := readDecimal(line, paramQuantity)
+		if !ok || !quantity.IsInteger() || !quantity.IsPositive() || quantity.IntPart() > math.MaxInt32 {
+			vErrs.Append(*ft.NewBusinessViolation(paramQuantity,
+				"paymentinvoice.quantity_malformed",
+				"a credited quantity must be a whole number greater than zero"))
+			return cmd, vErrs
+		}
+		cmd.Lines = append(cmd.Lines, services.CreditLineCommand{
+			InvoiceLineId: readString(line, paramInvoiceLineId),
+			Quantity:      int32(quantity.IntPart()),
+		})
+	}
+	return cmd, vErrs
+}
+
+// invoiceService is the domain service the invoice and allocation actions delegate to. Like the
+// order service it is a package variable rather than a derived resource service: issuing,
+// allocating and crediting are not CRUD on an invoice.
 var invoiceService *services.InvoiceDomainService
 
 // SetInvoiceService installs the service the invoice action delegates to. Init calls it before any
@@ -83,3 +300,22 @@ func requireInvoiceService() (*services.InvoiceDomainService, error) {
 	}
 	return invoiceService, nil
 }
+
+// invoicePrintService renders invoices for download_pdf. It is a package variable for the same
+// reason invoiceService is.
+var invoicePrintService *services.InvoicePrintDomainService
+
+// SetInvoicePrintService installs the service download_pdf delegates to. Init calls it before any
+// request is served.
+func SetInvoicePrintService(service *services.InvoicePrintDomainService) {
+	invoicePrintService = service
+}
+
+func requireInvoicePrintService() (*services.InvoicePrintDomainService, error) {
+	if invoicePrintService == nil {
+		return nil, errors.New(
+			"the invoice print service was not installed; PaymentInvoiceModule.Init must call " +
+				"dynamicengines.SetInvoicePrintService")
+	}
+	return invoicePrintService, nil
+}
@@ -0,0 +1,45 @@
+package dynamicengines
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
+)
+
+// paramAllocationId names the allocation in the request path, as paramInvoiceId does the invoice.
+const paramAllocationId = "id"
+
+// definePaymentAllocationActions adds the release action.
+//
+// Releasing is the correction for an allocation made in error. It is its own permission rather
+// than "delete" because nothing is deleted: the allocation stays as the record that it once
+// counted, and only stops counting.
+func definePaymentAllocationActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionRelease,
+		ActionType:  drif.ActionTypeGeneric,
+		RestPath:    ":id/" + constants.ActionRelease,
+		Permission:  constants.ActionRelease,
+		MainProcess: processReleaseAllocation,
+	})
+}
+
+// processReleaseAllocation stops what is left of one allocation from counting against its invoice.
+func processReleaseAllocation(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireInvoiceService()
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErrs, err := service.ReleaseAllocation(ctx, services.ReleaseAllocationCommand{
+		AllocationId: readString(input.Params, paramAllocationId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+	return toAllocationActionResult(result), nil
+}
@@ -0,0 +1,28 @@
+package dynamicengines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+)
+
+func TestTheImportSettlementActionIsAccepted(t *testing.T) {
+	schema := dmodel.DefineModel("paymentinvoice_reconciliation").Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+
+	require.NoError(t, defineReconciliationActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionImportSettlement)
+	require.True(t, exists)
+
+	// The import replaces a day's reconciliation, which create access must not allow.
+	assert.Equal(t, constants.ActionImportSettlement, definition.Permission)
+	assert.NotEqual(t, drif.PermissionCreate, definition.Permission)
+}
@@ -0,0 +1,85 @@
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
+)
+
+// paramSettlementContent carries the report itself, as the text the gateway's portal exported.
+const paramSettlementContent = "content"
+
+// defineReconciliationActions adds the settlement import.
+//
+// Importing is its own permission rather than "create": a reconciliation is never typed in, and
+// the import replaces the day's earlier one, which create access should not allow.
+func defineReconciliationActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionImportSettlement,
+		ActionType:  drif.ActionTypeGeneric,
+		RestPath:    constants.ActionImportSettlement,
+		Permission:  constants.ActionImportSettlement,
+		MainProcess: processImportSettlement,
+	})
+}
+
+// processImportSettlement reconciles one report and answers with the counts filed.
+func processImportSettlement(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireReconciliationService()
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErrs, err := service.ImportSettlement(ctx, services.ImportSettlementCommand{
+		PaymentMethodId: readString(input.Params, models.ReconciliationFieldPaymentMethodId),
+		SettlementDate:  readString(input.Params, models.ReconciliationFieldSettlementDate),
+		FileName:        readString(input.Params, models.ReconciliationFieldFileName),
+		Report:          []byte(readString(input.Params, paramSettlementContent)),
+		OrgId:           readString(input.Params, models.ReconciliationFieldOrgId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+
+	// Discrepancies are a completed import, not a failure: they are what the import is for.
+	return &drif.ActionResult{
+		HasData: true,
+		Data: map[string]any{
+			"reconciliation_id":                     result.ReconciliationId,
+			models.ReconciliationFieldStatus:        result.Status,
+			models.ReconciliationFieldLineCount:     result.LineCount,
+			models.ReconciliationFieldMatchedCount:  result.MatchedCount,
+			models.ReconciliationFieldMissingCount:  result.MissingCount,
+			models.ReconciliationFieldExtraCount:    result.ExtraCount,
+			models.ReconciliationFieldMismatchCount: result.MismatchCount,
+			models.ReconciliationFieldSettledTotal:  result.SettledTotal.String(),
+			models.ReconciliationFieldExpectedTotal: result.ExpectedTotal.String(),
+		},
+	}, nil
+}
+
+// reconciliationService is the domain service the import delegates to. It is a package variable
+// for the same reason invoiceService is.
+var reconciliationService *services.ReconciliationDomainService
+
+// SetReconciliationService installs the service the import delegates to. Init calls it before any
+// request is served.
+func SetReconciliationService(service *services.ReconciliationDomainService) {
+	reconciliationService = service
+}
+
+func requireReconciliationService() (*services.ReconciliationDomainService, error) {
+	if reconciliationService == nil {
+		return nil, errors.New(
+			"the reconciliation domain service was not installed; PaymentInvoiceModule.Init must call " +
+				"dynamicengines.SetReconciliationService")
+	}
+	return reconciliationService, nil
+}
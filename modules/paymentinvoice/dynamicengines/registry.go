@@ -42,6 +42,15 @@ var engineSpecs = []engineSpec{
 	transactionEngineSpec(),
 	invoiceEngineSpec(),
 	invoiceLineEngineSpec(),
+	paymentAllocationEngineSpec(),
+	creditNoteEngineSpec(),
+	creditNoteLineEngineSpec(),
+	recurringInvoiceEngineSpec(),
+	recurringInvoiceLineEngineSpec(),
+	recurringInvoiceRunEngineSpec(),
+	syncDeliveryEngineSpec(),
+	reconciliationEngineSpec(),
+	reconciliationItemEngineSpec(),
 }
 
 // EngineSchemaNames lists the schemas this module creates an engine for, so that route
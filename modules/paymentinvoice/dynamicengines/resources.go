@@ -66,6 +66,7 @@ func invoiceEngineSpec() engineSpec {
 			models.InvoiceFieldStatus,
 			models.InvoiceFieldPartnerName,
 			models.InvoiceFieldTotalAmount,
+			models.InvoiceFieldAmountDue,
 			models.InvoiceFieldCurrencyId,
 			models.InvoiceFieldIssuedAt,
 		},
@@ -82,7 +83,168 @@ func invoiceLineEngineSpec() engineSpec {
 			models.InvoiceLineFieldQuantity,
 			models.InvoiceLineFieldUnitPrice,
 			models.InvoiceLineFieldTaxRatePercent,
+			models.InvoiceLineFieldTaxIds,
 			models.InvoiceLineFieldAmount,
+			models.InvoiceLineFieldTaxAmount,
+		},
+	}
+}
+
+// The Payment Allocation engine.
+//
+// Allocations are written only by allocate_payment on the invoice and changed only by release, so
+// the IAM seed grants neither create, update nor delete: like a transaction, an allocation a person
+// could edit would be worthless as the record of what settled an invoice.
+func paymentAllocationEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.PaymentAllocationSchemaName,
+		DefaultFields: []string{
+			models.PaymentAllocationFieldInvoiceId,
+			models.PaymentAllocationFieldTransactionId,
+			models.PaymentAllocationFieldSource,
+			models.PaymentAllocationFieldReference,
+			models.PaymentAllocationFieldAmount,
+			models.PaymentAllocationFieldReleasedAmount,
+			models.PaymentAllocationFieldReceivedAt,
+		},
+		DefineActions: definePaymentAllocationActions,
+	}
+}
+
+// The Credit Note engines. Notes are written only by the invoice's credit action, which numbers
+// them and applies them to the invoice in one step, so the IAM seed grants read alone: a note
+// typed in directly would take nothing off its invoice.
+func creditNoteEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.CreditNoteSchemaName,
+		DefaultFields: []string{
+			models.CreditNoteFieldNumber,
+			models.CreditNoteFieldInvoiceId,
+			models.CreditNoteFieldTotalAmount,
+			models.CreditNoteFieldCurrencyId,
+			models.CreditNoteFieldIssuedAt,
+			models.CreditNoteFieldRefundStatus,
+		},
+	}
+}
+
+func creditNoteLineEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.CreditNoteLineSchemaName,
+		DefaultFields: []string{
+			models.CreditNoteLineFieldCreditNoteId,
+			models.CreditNoteLineFieldDescription,
+			models.CreditNoteLineFieldQuantity,
+			models.CreditNoteLineFieldUnitPrice,
+			models.CreditNoteLineFieldTaxRatePercent,
+			models.CreditNoteLineFieldAmount,
+		},
+	}
+}
+
+// The Recurring Invoice engines. A schedule and its lines are maintained by hand; the runs are
+// written only by the generation job, so the IAM seed grants read alone on them: a run typed in
+// directly would mark a period invoiced that never was.
+func recurringInvoiceEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.RecurringInvoiceSchemaName,
+		DefaultFields: []string{
+			models.RecurringInvoiceFieldName,
+			models.RecurringInvoiceFieldPartnerName,
+			models.RecurringInvoiceFieldCurrencyId,
+			models.RecurringInvoiceFieldIntervalUnit,
+			models.RecurringInvoiceFieldIntervalCount,
+			models.RecurringInvoiceFieldStartDate,
+			models.RecurringInvoiceFieldEndDate,
+			models.RecurringInvoiceFieldNextRunDate,
+			models.RecurringInvoiceFieldAutoIssue,
+			models.RecurringInvoiceFieldIsActive,
+		},
+	}
+}
+
+func recurringInvoiceLineEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.RecurringInvoiceLineSchemaName,
+		DefaultFields: []string{
+			models.RecurringInvoiceLineFieldRecurringInvoiceId,
+			models.RecurringInvoiceLineFieldDescription,
+			models.RecurringInvoiceLineFieldQuantity,
+			models.RecurringInvoiceLineFieldUnitPrice,
+			models.RecurringInvoiceLineFieldTaxRatePercent,
+			models.RecurringInvoiceLineFieldTaxIds,
+		},
+	}
+}
+
+func recurringInvoiceRunEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.RecurringInvoiceRunSchemaName,
+		DefaultFields: []string{
+			models.RecurringInvoiceRunFieldRecurringInvoiceId,
+			models.RecurringInvoiceRunFieldPeriodDate,
+			models.RecurringInvoiceRunFieldInvoiceId,
+			models.RecurringInvoiceRunFieldStatus,
+			models.RecurringInvoiceRunFieldDetail,
+		},
+	}
+}
+
+// The Sync Delivery engine. Deliveries are written only by the notification path, so the IAM seed
+// grants read and replay alone: a delivery typed in or edited would be a record of a notification
+// nobody sent.
+func syncDeliveryEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.SyncDeliverySchemaName,
+		DefaultFields: []string{
+			models.SyncDeliveryFieldOrderRef,
+			models.SyncDeliveryFieldReturnUrl,
+			models.SyncDeliveryFieldStatus,
+			models.SyncDeliveryFieldAttempts,
+			models.SyncDeliveryFieldNextAttemptAt,
+			models.SyncDeliveryFieldLastAttemptAt,
+			models.SyncDeliveryFieldDeliveredAt,
+			models.SyncDeliveryFieldLastDetail,
+		},
+		DefineActions: defineSyncDeliveryActions,
+	}
+}
+
+// The Reconciliation engine. A reconciliation is written only by the settlement import, so the IAM
+// seed grants read and import_settlement alone.
+func reconciliationEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.ReconciliationSchemaName,
+		DefaultFields: []string{
+			models.ReconciliationFieldPaymentMethodId,
+			models.ReconciliationFieldSettlementDate,
+			models.ReconciliationFieldFileName,
+			models.ReconciliationFieldStatus,
+			models.ReconciliationFieldLineCount,
+			models.ReconciliationFieldMatchedCount,
+			models.ReconciliationFieldMissingCount,
+			models.ReconciliationFieldExtraCount,
+			models.ReconciliationFieldMismatchCount,
+			models.ReconciliationFieldSettledTotal,
+			models.ReconciliationFieldExpectedTotal,
+		},
+		DefineActions: defineReconciliationActions,
+	}
+}
+
+func reconciliationItemEngineSpec() engineSpec {
+	return engineSpec{
+		SchemaName: models.ReconciliationItemSchemaName,
+		DefaultFields: []string{
+			models.ReconciliationItemFieldReconciliationId,
+			models.ReconciliationItemFieldOutcome,
+			models.ReconciliationItemFieldTransactionType,
+			models.ReconciliationItemFieldTransactionId,
+			models.ReconciliationItemFieldOrderCode,
+			models.ReconciliationItemFieldRefTransactionId,
+			models.ReconciliationItemFieldExpectedAmount,
+			models.ReconciliationItemFieldSettledAmount,
+			models.ReconciliationItemFieldLineNo,
 		},
 	}
 }
@@ -0,0 +1,29 @@
+package dynamicengines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+)
+
+func TestTheReplayActionIsAccepted(t *testing.T) {
+	schema := dmodel.DefineModel("paymentinvoice_sync_delivery").Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+
+	require.NoError(t, defineSyncDeliveryActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionReplay)
+	require.True(t, exists)
+
+	// Replaying calls out to a tenant's system, which read access must not allow, and the code is
+	// what the IAM seed grants.
+	assert.Equal(t, constants.ActionReplay, definition.Permission)
+	assert.NotEqual(t, drif.PermissionRead, definition.Permission)
+}
@@ -0,0 +1,77 @@
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
+)
+
+// paramDeliveryId names the delivery in the request path.
+const paramDeliveryId = "id"
+
+// defineSyncDeliveryActions adds the replay action.
+//
+// Replaying is its own permission rather than "update": it changes nothing a person wrote, but it
+// does call out to a tenant's system, which is not something read access should allow.
+func defineSyncDeliveryActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionReplay,
+		ActionType:  drif.ActionTypeGeneric,
+		RestPath:    ":id/" + constants.ActionReplay,
+		Permission:  constants.ActionReplay,
+		MainProcess: processReplayDelivery,
+	})
+}
+
+// processReplayDelivery sends one delivery again and reports how it went.
+func processReplayDelivery(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireSyncDeliveryService()
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErrs, err := service.Replay(ctx, services.ReplayDeliveryCommand{
+		DeliveryId: readString(input.Params, paramDeliveryId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+
+	// A replay the tenant refused is still a completed action: it was made, and what came of it is
+	// the answer. The caller learns whether it got through from the status, not from an error.
+	return &drif.ActionResult{
+		HasData: true,
+		Data: map[string]any{
+			"delivery_id": result.DeliveryId,
+			"order_id":    result.OrderRef,
+			"status":      result.Status,
+			"attempts":    result.Outcome.Attempts,
+			"detail":      result.Outcome.Detail,
+		},
+	}, nil
+}
+
+// syncDeliveryService is the domain service replay delegates to. It is a package variable for the
+// same reason invoiceService is.
+var syncDeliveryService *services.SyncDeliveryDomainService
+
+// SetSyncDeliveryService installs the service replay delegates to. Init calls it before any request
+// is served.
+func SetSyncDeliveryService(service *services.SyncDeliveryDomainService) {
+	syncDeliveryService = service
+}
+
+func requireSyncDeliveryService() (*services.SyncDeliveryDomainService, error) {
+	if syncDeliveryService == nil {
+		return nil, errors.New(
+			"the sync delivery domain service was not installed; PaymentInvoiceModule.Init must call " +
+				"dynamicengines.SetSyncDeliveryService")
+	}
+	return syncDeliveryService, nil
+}
@@ -9,6 +9,7 @@ package paymentinvoice
 
 import (
 	stdErr "errors"
+	"strings"
 	"time"
 
 	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
@@ -24,6 +25,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/dynamicengines"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/external"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway"
 	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/transport/restful"
@@ -48,6 +50,7 @@ func (*PaymentInvoiceModule) Name() string {
 
 func (*PaymentInvoiceModule) Deps() []string {
 	return []string{
+		"document",
 		"dynamicresource",
 		"essential",
 	}
@@ -63,10 +66,15 @@ func (*PaymentInvoiceModule) Version() semver.SemVer {
 
 // Init implements DynamicModule.
 //
-// The steps are ordered: the gateway registry must exist before the order service that selects
-// from it, that service before the engines whose actions delegate to it, and the engines before
-// the REST layer that registers their routes.
+// The steps are ordered: the ports onto other modules must be bound before the print service that
+// uses them, the gateway registry must exist before the order service that selects
+// from it, that service before the invoice service that refunds credit notes through it, both
+// before the engines whose actions delegate to them, and the engines before the REST layer that
+// registers their routes.
 func (*PaymentInvoiceModule) Init() error {
+	if err := external.InitExternal(); err != nil {
+		return err
+	}
 	if err := initOrderService(); err != nil {
 		return err
 	}
@@ -76,7 +84,7 @@ func (*PaymentInvoiceModule) Init() error {
 	return restful.InitRestfulHandlers()
 }
 
-// initOrderService builds the gateway registry and the domain services, and puts all three into
+// initOrderService builds the gateway registry and the domain services, and puts them all into
 // the dependency container.
 //
 // They are *registered* rather than only handed to the engine setters, because two later steps
@@ -99,6 +107,9 @@ func initOrderService() error {
 		},
 		services.NewOrderDomainService,
 		services.NewInvoiceDomainService,
+		services.NewInvoicePrintDomainService,
+		newSyncDeliveryService,
+		services.NewReconciliationDomainService,
 	)
 	if err != nil {
 		return err
@@ -110,18 +121,55 @@ func initOrderService() error {
 	return deps.Invoke(func(
 		orders *services.OrderDomainService,
 		invoices *services.InvoiceDomainService,
+		printer *services.InvoicePrintDomainService,
+		deliveries *services.SyncDeliveryDomainService,
+		reconciliations *services.ReconciliationDomainService,
 	) error {
 		dynamicengines.SetOrderService(orders)
 		dynamicengines.SetInvoiceService(invoices)
+		dynamicengines.SetInvoicePrintService(printer)
+		dynamicengines.SetSyncDeliveryService(deliveries)
+		dynamicengines.SetReconciliationService(reconciliations)
 		return nil
 	})
 }
 
+// newSyncDeliveryService builds the service that notifies the ordering system, with the client it
+// sends through.
+//
+// It is built at Init rather than with the sweeps because the replay action needs it, and actions
+// are served from the moment the engines are. Each payment method's signing secret is read when a
+// notification is sent rather than once here, so a secret file rotated on a compromise stops being
+// used at once. The file's trailing newline is not part of the secret.
+func newSyncDeliveryService(cfg config.ConfigService) *services.SyncDeliveryDomainService {
+	client := app.NewResultSyncClient(
+		time.Duration(cfg.GetInt(modconstants.SyncTimeoutSecs, defaultSyncTimeoutSecs))*time.Second,
+		cfg.GetInt(modconstants.SyncMaxRetries, defaultSyncMaxRetries),
+	).WithSigningSecrets(func(paymentMethodCode string) string {
+		if paymentMethodCode == "" {
+			return ""
+		}
+		return strings.TrimSpace(cfg.GetStr(modconstants.SyncSecretFor(paymentMethodCode), ""))
+	})
+
+	return services.NewSyncDeliveryDomainService(client, services.SyncRetryPolicy{
+		MaxAttempts: cfg.GetInt(modconstants.SyncMaxAttempts, defaultSyncMaxAttempts),
+		BackoffBase: time.Duration(
+			cfg.GetInt(modconstants.SyncBackoffBaseSecs, defaultSyncBackoffBaseSecs)) * time.Second,
+		BackoffCap: time.Duration(
+			cfg.GetInt(modconstants.SyncBackoffCapSecs, defaultSyncBackoffCapSecs)) * time.Second,
+	})
+}
+
 // RegisterModels implements DynamicModule.
 //
 // Schemas are registered referenced-before-referencing, because an edge is resolved against the
 // schema registry at registration time: the payment method is pointed at by both the order and the
-// transaction, the transaction points at the order, and the invoice line at the invoice.
+// transaction, the transaction points at the order, the invoice line at the invoice, the payment
+// allocation at both the invoice and the transaction, the credit note line at both the credit
+// note and the invoice line, the recurring invoice run at both its schedule and the invoice it
+// raised, the sync delivery at the order it reports on, the reconciliation at its payment method, and
+// the reconciliation item at both its reconciliation and the transaction it is about.
 //
 // The edges onto essential_currency resolve because Essential is named in Deps() and every
 // module's RegisterModels runs in dependency order, before any module's Init().
@@ -132,12 +180,21 @@ func (*PaymentInvoiceModule) RegisterModels() error {
 		dmodel.RegisterSchemaB(models.TransactionSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.InvoiceSchemaBuilder()),
 		dmodel.RegisterSchemaB(models.InvoiceLineSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.PaymentAllocationSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.CreditNoteSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.CreditNoteLineSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.RecurringInvoiceSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.RecurringInvoiceLineSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.RecurringInvoiceRunSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.SyncDeliverySchemaBuilder()),
+		dmodel.RegisterSchemaB(models.ReconciliationSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.ReconciliationItemSchemaBuilder()),
 	)
 }
 
 // OnAppStarted implements InCodeModuleAppStarted.
 //
-// The three sweeps are registered here rather than in Init because they must not start until the
+// The sweeps are registered here rather than in Init because they must not start until the
 // application is serving: the watchdog writes order state, and running it against a half-built
 // container would fail on the first tick.
 //
@@ -148,15 +205,12 @@ func (*PaymentInvoiceModule) OnAppStarted() error {
 	return deps.Invoke(func(
 		cfg config.ConfigService,
 		orders *services.OrderDomainService,
+		invoices *services.InvoiceDomainService,
+		deliveries *services.SyncDeliveryDomainService,
 		cronRegistry job.CronjobRegistry,
 		logger logging.LoggerService,
 	) error {
-		syncClient := app.NewResultSyncClient(
-			time.Duration(cfg.GetInt(modconstants.SyncTimeoutSecs, defaultSyncTimeoutSecs))*time.Second,
-			cfg.GetInt(modconstants.SyncMaxRetries, defaultSyncMaxRetries),
-		)
-
-		manager := app.NewJobsManager(orders, syncClient, app.JobsConfig{
+		manager := app.NewJobsManager(orders, invoices, deliveries, app.JobsConfig{
 			ExpireAfter: time.Duration(
 				cfg.GetInt(modconstants.OrderExpireAfterMins, defaultExpireAfterMins)) * time.Minute,
 			CleanAfter: time.Duration(
@@ -176,4 +230,8 @@ const (
 	defaultCleanAfterHours = 24
 	defaultSyncTimeoutSecs = 5
 	defaultSyncMaxRetries  = 3
+
+	defaultSyncMaxAttempts     = 15
+	defaultSyncBackoffBaseSecs = 60
+	defaultSyncBackoffCapSecs  = 6 * 60 * 60
 )
@@ -0,0 +1,32 @@
+// Package external binds Payment & Invoice's local ports to the services other modules publish.
+//
+// This is the ONLY package in Payment & Invoice that may import another module's services.
+// Everything else depends on the interfaces in interfaces/external, so splitting a module into its
+// own process changes this file and nothing else.
+package external
+
+import (
+	stdErr "errors"
+
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+	itCurrency "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/currency"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+	itExt "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/external"
+)
+
+// InitExternal binds every port Payment & Invoice consumes.
+func InitExternal() error {
+	return stdErr.Join(
+		deps.Register(func(renderSvc itRendering.DocumentRenderAppService) itExt.DocumentExtService {
+			// A direct hand-over: the upstream service has exactly the method the port declares.
+			return renderSvc
+		}),
+		deps.Register(func(currencySvc itCurrency.CurrencyAppService) itExt.CurrencyExtService {
+			return currencySvc
+		}),
+		deps.Register(func(taxSvc itTax.TaxAppService) itExt.TaxExtService {
+			return taxSvc
+		}),
+	)
+}
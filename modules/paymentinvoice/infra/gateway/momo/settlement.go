@@ -0,0 +1,29 @@
+package momo
+
+import (
+	"io"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/settlementcsv"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// settlementColumns is MoMo's transaction export from the merchant portal, under both of its
+// language settings. The order code is what MoMo calls orderId, and the transaction id is the
+// transId a callback carries, so a line matches on either.
+var settlementColumns = settlementcsv.Columns{
+	OrderCode:        []string{"Mã đơn hàng", "Order ID", "orderId"},
+	RefTransactionId: []string{"Mã giao dịch", "Transaction ID", "transId"},
+	Amount:           []string{"Số tiền", "Amount", "amount"},
+	Kind:             []string{"Loại giao dịch", "Transaction Type", "type"},
+	KindValues: map[string]string{
+		"thanh toán": itGateway.SettlementKindPayment,
+		"payment":    itGateway.SettlementKindPayment,
+		"hoàn tiền":  itGateway.SettlementKindRefund,
+		"refund":     itGateway.SettlementKindRefund,
+	},
+}
+
+// ParseSettlement implements itGateway.SettlementParser.
+func (this *Adapter) ParseSettlement(report io.Reader) ([]itGateway.SettlementLine, error) {
+	return settlementcsv.Read(report, settlementColumns)
+}
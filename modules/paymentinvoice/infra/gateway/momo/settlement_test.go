@@ -0,0 +1,28 @@
+package momo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// The portal exports the same report with Vietnamese or English headers depending on the user's
+// language setting, and both must read the same.
+func TestASettlementReportReadsInEitherLanguage(t *testing.T) {
+	for _, report := range []string{
+		"Mã đơn hàng,Mã giao dịch,Số tiền,Loại giao dịch\n1QAI0001,3100000001,\"150,000\",Thanh toán\n",
+		"Order ID,Transaction ID,Amount,Transaction Type\n1QAI0001,3100000001,\"150,000\",Payment\n",
+	} {
+		lines, err := newTestAdapter().ParseSettlement(strings.NewReader(report))
+		require.NoError(t, err)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "1QAI0001", lines[0].OrderCode)
+		assert.Equal(t, "3100000001", lines[0].RefTransactionId)
+		assert.Equal(t, itGateway.SettlementKindPayment, lines[0].Kind)
+		assert.Equal(t, "150000", lines[0].Amount.String())
+	}
+}
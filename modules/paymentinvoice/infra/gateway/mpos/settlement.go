@@ -0,0 +1,29 @@
+package mpos
+
+import (
+	"io"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/settlementcsv"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// settlementColumns is NextPay's settlement export. The transaction code is the transCode a
+// callback carries; a refund appears under its own code, which is why a refund is matched by code
+// before it is matched by order.
+var settlementColumns = settlementcsv.Columns{
+	OrderCode:        []string{"Mã đơn hàng", "Order ID", "orderId"},
+	RefTransactionId: []string{"Mã giao dịch", "Transaction Code", "transCode"},
+	Amount:           []string{"Số tiền", "Amount", "amount"},
+	Kind:             []string{"Loại giao dịch", "Transaction Type", "transType"},
+	KindValues: map[string]string{
+		"thanh toán": itGateway.SettlementKindPayment,
+		"sale":       itGateway.SettlementKindPayment,
+		"hoàn tiền":  itGateway.SettlementKindRefund,
+		"refund":     itGateway.SettlementKindRefund,
+	},
+}
+
+// ParseSettlement implements itGateway.SettlementParser.
+func (this *Adapter) ParseSettlement(report io.Reader) ([]itGateway.SettlementLine, error) {
+	return settlementcsv.Read(report, settlementColumns)
+}
@@ -0,0 +1,335 @@
+// Package sandbox is a payment gateway that collects no money, for development and tests.
+//
+// Every real adapter needs a merchant account, so without this nobody could run an order through
+// to its callback, its invoice and its notification on a laptop. The sandbox stands in for the
+// gateway at both ends: it accepts the payment when the order is created, and later posts the
+// verdict to this deployment's own webhook, signed, exactly as a wallet would. Everything between
+// those two — the order state machine, the watchdog, the sweeps — runs unmodified.
+//
+// What it does with a payment is chosen by the amount; see scenario.go. An amount with no magic
+// ending waits for someone to pay or decline it on the sandbox's pay page.
+//
+// Its state is in memory. A restart forgets every payment it had in flight, which the watchdog
+// then expires like any payment whose gateway never answered — which is itself worth exercising.
+// It must never be enabled where real customers pay: it settles orders without collecting a cent.
+package sandbox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/httpclient"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// Config is what the sandbox needs to call this deployment back.
+type Config struct {
+	// SecretKey signs the callbacks. The sandbox is both signer and verifier, so any value works
+	// on a single instance; instances behind one load balancer must share it.
+	SecretKey string
+
+	// PayPageUrl is the base of the pay page the payer is sent to; the order code is appended.
+	PayPageUrl string
+
+	// CallbackDelay is how long a scenario that settles by itself waits before calling back. It
+	// is what makes the callback asynchronous rather than a reply to the create request.
+	CallbackDelay time.Duration
+}
+
+// callbackTimeout bounds one callback post. It is sent from a timer rather than a request, so
+// nothing else would.
+const callbackTimeout = 10 * time.Second
+
+// rememberLimit bounds how many payments are kept. A development server left running would
+// otherwise grow the map forever; the oldest are forgotten first.
+const rememberLimit = 10000
+
+var (
+	ErrUnknownPayment = errors.New("the sandbox has no such payment")
+	ErrAlreadySettled = errors.New("the sandbox payment has already been settled")
+)
+
+// Payment is what the sandbox knows about one payment.
+type Payment struct {
+	OrderCode string
+	Amount    int64
+	Scenario  Scenario
+	Settled   bool
+	Paid      bool
+	TransId   string
+}
+
+// Adapter implements itGateway.PaymentGateway without a gateway behind it.
+type Adapter struct {
+	config Config
+	logger logging.LoggerService
+
+	// post and after are the two seams a test replaces: where a callback goes, and how it waits.
+	post  func(ctx context.Context, payload CallbackPayload) error
+	after func(delay time.Duration, fn func())
+	now   func() time.Time
+
+	mutex    sync.Mutex
+	payments map[string]*Payment
+	order    []string
+}
+
+// NewAdapter builds the sandbox. The caller's base URL is the webhook the sandbox calls back.
+func NewAdapter(config Config, caller *httpclient.HttpCaller, logger logging.LoggerService) *Adapter {
+	adapter := newAdapter(config, logger)
+	adapter.post = func(ctx context.Context, payload CallbackPayload) error {
+		_, err := caller.Do(ctx, &httpclient.Request{Method: http.MethodPost, Body: payload})
+		return err
+	}
+	return adapter
+}
+
+func newAdapter(config Config, logger logging.LoggerService) *Adapter {
+	return &Adapter{
+		config:   config,
+		logger:   logger,
+		after:    func(delay time.Duration, fn func()) { time.AfterFunc(delay, fn) },
+		now:      time.Now,
+		payments: map[string]*Payment{},
+	}
+}
+
+func (this *Adapter) AdapterCode() string {
+	return models.AdapterCodeSandbox
+}
+
+// ValidateOrder accepts everything: a real gateway asks nothing of an order that the sandbox
+// could usefully imitate, and the scenarios are chosen by amount.
+func (this *Adapter) ValidateOrder(
+	_ corectx.Context, _ itGateway.OrderRequest, _ *ft.ClientErrors,
+) error {
+	return nil
+}
+
+func (this *Adapter) PrepareMetadata(
+	_ corectx.Context, _ itGateway.OrderRequest,
+) (map[string]any, error) {
+	return map[string]any{}, nil
+}
+
+// CreatePayment accepts the payment and, for a scenario that settles by itself, schedules the
+// callback.
+func (this *Adapter) CreatePayment(
+	_ corectx.Context, req itGateway.CreatePaymentRequest,
+) (*itGateway.CreatePaymentResult, error) {
+	amount, err := toWholeUnits(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := ScenarioOf(req.Amount)
+	if scenario == ScenarioCreateRefused {
+		return nil, errors.Errorf("sandbox refused the payment: amount %d selects %s", amount, scenario)
+	}
+
+	this.remember(&Payment{OrderCode: req.OrderCode, Amount: amount, Scenario: scenario})
+
+	if scenario.callsBack() {
+		orderCode := req.OrderCode
+		this.after(this.config.CallbackDelay, func() {
+			this.settleInBackground(orderCode, scenario)
+		})
+	}
+
+	return &itGateway.CreatePaymentResult{
+		PayUrl: this.payUrlOf(req.OrderCode),
+		RawResponse: map[string]any{
+			"orderCode": req.OrderCode,
+			"scenario":  string(scenario),
+		},
+	}, nil
+}
+
+// Refund returns a payment the sandbox collected, unless its scenario refuses refunds.
+//
+// A payment the sandbox has forgotten is refunded anyway: refusing would make every refund after
+// a restart fail for a reason no real gateway has.
+func (this *Adapter) Refund(
+	_ corectx.Context, req itGateway.RefundRequest,
+) (*itGateway.RefundResult, error) {
+	if _, err := toWholeUnits(req.Amount); err != nil {
+		return nil, err
+	}
+
+	if payment, known := this.Payment(req.OrderCode); known {
+		if payment.Scenario == ScenarioRefundRefused {
+			return nil, errors.Errorf("sandbox refused the refund of '%s': its scenario is %s",
+				req.OrderCode, payment.Scenario)
+		}
+		if !payment.Paid {
+			return nil, errors.Errorf("sandbox cannot refund '%s': it was never collected", req.OrderCode)
+		}
+	}
+
+	refTransactionId := newTransId()
+	return &itGateway.RefundResult{
+		RefTransactionId: refTransactionId,
+		RawResponse: map[string]any{
+			"orderCode": req.OrderCode,
+			"transId":   refTransactionId,
+			"amount":    req.Amount.String(),
+		},
+	}, nil
+}
+
+// CheckOrder reports what the sandbox knows. A payment it has forgotten, like one in the timeout
+// scenario, is still in flight as far as the watchdog can tell.
+func (this *Adapter) CheckOrder(
+	_ corectx.Context, req itGateway.CheckOrderRequest,
+) (*itGateway.CheckOrderResult, error) {
+	payment, known := this.Payment(req.OrderCode)
+	if !known || !payment.Settled {
+		return &itGateway.CheckOrderResult{}, nil
+	}
+
+	return &itGateway.CheckOrderResult{
+		Settled:          true,
+		Paid:             payment.Paid,
+		RefTransactionId: payment.TransId,
+		RawResponse: map[string]any{
+			"orderCode": payment.OrderCode,
+			"scenario":  string(payment.Scenario),
+			"transId":   payment.TransId,
+		},
+	}, nil
+}
+
+// Pay settles a payment from the pay page and calls back at once.
+func (this *Adapter) Pay(ctx context.Context, orderCode string, paid bool) error {
+	payload, err := this.settle(orderCode, paid)
+	if err != nil {
+		return err
+	}
+	return this.post(ctx, payload)
+}
+
+// Payment returns a copy of what the sandbox knows about one payment.
+func (this *Adapter) Payment(orderCode string) (Payment, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	payment, exists := this.payments[orderCode]
+	if !exists {
+		return Payment{}, false
+	}
+	return *payment, true
+}
+
+// VerifyCallback reports whether a callback was signed by this sandbox.
+func (this *Adapter) VerifyCallback(payload CallbackPayload) bool {
+	return payload.verify(this.config.SecretKey)
+}
+
+// CallbackOutcome reports what a callback means for the order it names.
+func CallbackOutcome(payload CallbackPayload) bool {
+	return payload.ResultCode == ResultCodeSuccess
+}
+
+// settleInBackground is a scenario's own callback. Its failure is logged rather than returned,
+// because nothing is waiting on it: the order is left pending, as a lost callback would leave it.
+func (this *Adapter) settleInBackground(orderCode string, scenario Scenario) {
+	payload, err := this.settle(orderCode, scenario.paid())
+	if err != nil {
+		// Paid on the page before the timer fired, or forgotten since. Either way there is nothing
+		// left to report.
+		return
+	}
+
+	sends := 1
+	if scenario == ScenarioDuplicate {
+		sends = 2
+	}
+	for i := 0; i < sends; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+		err := this.post(ctx, payload)
+		cancel()
+		if err != nil {
+			this.logger.Warnf("paymentinvoice: sandbox callback for '%s' failed: %s", orderCode, err.Error())
+			return
+		}
+	}
+}
+
+// settle records a payment's verdict and returns the signed callback that reports it.
+func (this *Adapter) settle(orderCode string, paid bool) (CallbackPayload, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	payment, exists := this.payments[orderCode]
+	if !exists {
+		return CallbackPayload{}, ErrUnknownPayment
+	}
+	if payment.Settled {
+		return CallbackPayload{}, ErrAlreadySettled
+	}
+
+	payment.Settled = true
+	payment.Paid = paid
+	payment.TransId = newTransId()
+
+	resultCode := ResultCodeFailed
+	if paid {
+		resultCode = ResultCodeSuccess
+	}
+	payload := CallbackPayload{
+		OrderCode:  payment.OrderCode,
+		ResultCode: resultCode,
+		TransId:    payment.TransId,
+		Amount:     payment.Amount,
+		SentAt:     this.now().UnixMilli(),
+	}
+	payload.Signature = payload.sign(this.config.SecretKey)
+	return payload, nil
+}
+
+func (this *Adapter) remember(payment *Payment) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, exists := this.payments[payment.OrderCode]; !exists {
+		this.order = append(this.order, payment.OrderCode)
+	}
+	this.payments[payment.OrderCode] = payment
+
+	for len(this.order) > rememberLimit {
+		delete(this.payments, this.order[0])
+		this.order = this.order[1:]
+	}
+}
+
+func (this *Adapter) payUrlOf(orderCode string) string {
+	if this.config.PayPageUrl == "" {
+		return ""
+	}
+	return strings.TrimRight(this.config.PayPageUrl, "/") + "/" + url.PathEscape(orderCode)
+}
+
+func newTransId() string {
+	return "SBX" + strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", ""))[:16]
+}
+
+// toWholeUnits refuses a fractional amount, as every real adapter does: the sandbox passing one
+// that no gateway would is a bug it should surface rather than hide.
+func toWholeUnits(amount decimal.Decimal) (int64, error) {
+	if !amount.Equal(amount.Truncate(0)) {
+		return 0, errors.Errorf("sandbox accepts whole amounts only, got %s", amount.String())
+	}
+	return amount.IntPart(), nil
+}
@@ -0,0 +1,209 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// testAdapter is a sandbox whose timers fire only when told to and whose callbacks are collected
+// rather than posted.
+type testAdapter struct {
+	*Adapter
+	pending []func()
+	sent    []CallbackPayload
+}
+
+func newTestAdapter() *testAdapter {
+	test := &testAdapter{}
+	test.Adapter = newAdapter(Config{
+		SecretKey:     "sandbox-test-secret",
+		PayPageUrl:    "http://localhost:8080/v1/paymentinvoice/sandbox/pay/",
+		CallbackDelay: time.Second,
+	}, nil)
+	test.after = func(_ time.Duration, fn func()) { test.pending = append(test.pending, fn) }
+	test.post = func(_ context.Context, payload CallbackPayload) error {
+		test.sent = append(test.sent, payload)
+		return nil
+	}
+	return test
+}
+
+func (this *testAdapter) fireTimers() {
+	pending := this.pending
+	this.pending = nil
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+func (this *testAdapter) create(t *testing.T, orderCode string, amount string) *itGateway.CreatePaymentResult {
+	result, err := this.CreatePayment(nil, itGateway.CreatePaymentRequest{
+		OrderRequest: itGateway.OrderRequest{Amount: decimal.RequireFromString(amount)},
+		OrderCode:    orderCode,
+	})
+	require.NoError(t, err)
+	return result
+}
+
+// The scenarios are selected by the last two digits, so a test picks one by the amount it pays.
+func TestTheLastTwoDigitsSelectTheScenario(t *testing.T) {
+	for amount, expected := range map[string]Scenario{
+		"150001": ScenarioSucceed,
+		"150002": ScenarioFail,
+		"150003": ScenarioTimeout,
+		"150004": ScenarioDuplicate,
+		"150005": ScenarioRefundRefused,
+		"150006": ScenarioCreateRefused,
+		"150000": ScenarioManual,
+		"150107": ScenarioManual,
+		"1":      ScenarioSucceed,
+	} {
+		assert.Equal(t, expected, ScenarioOf(decimal.RequireFromString(amount)), amount)
+	}
+}
+
+// A payment row names the sandbox by this code, so it is a wire identifier like any adapter's.
+func TestSandboxAdapterCodeIsStable(t *testing.T) {
+	assert.Equal(t, "sandbox", newTestAdapter().AdapterCode())
+	assert.Equal(t, models.AdapterCodeSandbox, newTestAdapter().AdapterCode())
+}
+
+// The callback is asynchronous: nothing is sent by the create itself, only once the delay passes.
+func TestASucceedingPaymentCallsBackAfterTheDelay(t *testing.T) {
+	sandbox := newTestAdapter()
+	result := sandbox.create(t, "1QAI0001", "150001")
+
+	assert.Equal(t, "http://localhost:8080/v1/paymentinvoice/sandbox/pay/1QAI0001", result.PayUrl)
+	assert.Empty(t, sandbox.sent)
+
+	sandbox.fireTimers()
+	require.Len(t, sandbox.sent, 1)
+
+	callback := sandbox.sent[0]
+	assert.Equal(t, "1QAI0001", callback.OrderCode)
+	assert.Equal(t, int64(150001), callback.Amount)
+	assert.True(t, CallbackOutcome(callback))
+	assert.True(t, sandbox.VerifyCallback(callback))
+}
+
+func TestAFailingPaymentCallsBackDeclined(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0002", "150002")
+	sandbox.fireTimers()
+
+	require.Len(t, sandbox.sent, 1)
+	assert.False(t, CallbackOutcome(sandbox.sent[0]))
+
+	check, err := sandbox.CheckOrder(nil, itGateway.CheckOrderRequest{OrderCode: "1QAI0002"})
+	require.NoError(t, err)
+	assert.True(t, check.Settled)
+	assert.False(t, check.Paid)
+}
+
+// The duplicate is the same callback twice, which is what a gateway that retries sends.
+func TestADuplicatePaymentCallsBackTwiceWithTheSameResult(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0004", "150004")
+	sandbox.fireTimers()
+
+	require.Len(t, sandbox.sent, 2)
+	assert.Equal(t, sandbox.sent[0], sandbox.sent[1])
+}
+
+// A timeout never calls back and keeps reporting itself in flight, which is what leaves the
+// order for the watchdog to expire.
+func TestATimingOutPaymentNeverSettles(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0003", "150003")
+	sandbox.fireTimers()
+
+	assert.Empty(t, sandbox.sent)
+
+	check, err := sandbox.CheckOrder(nil, itGateway.CheckOrderRequest{OrderCode: "1QAI0003"})
+	require.NoError(t, err)
+	assert.False(t, check.Settled)
+}
+
+func TestARefusedCreateRemembersNothing(t *testing.T) {
+	sandbox := newTestAdapter()
+	_, err := sandbox.CreatePayment(nil, itGateway.CreatePaymentRequest{
+		OrderRequest: itGateway.OrderRequest{Amount: decimal.RequireFromString("150006")},
+		OrderCode:    "1QAI0006",
+	})
+
+	require.Error(t, err)
+	_, known := sandbox.Payment("1QAI0006")
+	assert.False(t, known)
+}
+
+// A manual payment waits for the page, and the page can settle it once.
+func TestAManualPaymentIsSettledFromThePageOnce(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0000", "150000")
+	sandbox.fireTimers()
+	assert.Empty(t, sandbox.sent)
+
+	require.NoError(t, sandbox.Pay(context.Background(), "1QAI0000", true))
+	require.Len(t, sandbox.sent, 1)
+	assert.True(t, CallbackOutcome(sandbox.sent[0]))
+
+	assert.ErrorIs(t, sandbox.Pay(context.Background(), "1QAI0000", false), ErrAlreadySettled)
+	assert.ErrorIs(t, sandbox.Pay(context.Background(), "NOSUCH", true), ErrUnknownPayment)
+}
+
+// Paying on the page before the timer fires must not have the timer report a second verdict.
+func TestAPagePaymentPreemptsTheScheduledCallback(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0101", "150002")
+
+	require.NoError(t, sandbox.Pay(context.Background(), "1QAI0101", true))
+	sandbox.fireTimers()
+
+	require.Len(t, sandbox.sent, 1)
+	assert.True(t, CallbackOutcome(sandbox.sent[0]))
+}
+
+func TestRefundsFollowTheScenario(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0001", "150001")
+	sandbox.create(t, "1QAI0005", "150005")
+	sandbox.create(t, "1QAI0002", "150002")
+	sandbox.fireTimers()
+
+	refund := func(orderCode string) error {
+		_, err := sandbox.Refund(nil, itGateway.RefundRequest{
+			OrderCode: orderCode,
+			Amount:    decimal.RequireFromString("1000"),
+		})
+		return err
+	}
+
+	assert.NoError(t, refund("1QAI0001"))
+	assert.Error(t, refund("1QAI0005"))
+	assert.Error(t, refund("1QAI0002"), "a declined payment was never collected")
+	assert.NoError(t, refund("FORGOTTEN"), "a payment lost at a restart is refunded anyway")
+}
+
+// A callback with any field altered is refused, which is what stops anyone settling a sandbox
+// order by posting to the webhook directly.
+func TestATamperedCallbackDoesNotVerify(t *testing.T) {
+	sandbox := newTestAdapter()
+	sandbox.create(t, "1QAI0002", "150002")
+	sandbox.fireTimers()
+
+	callback := sandbox.sent[0]
+	callback.ResultCode = ResultCodeSuccess
+	assert.False(t, sandbox.VerifyCallback(callback))
+
+	other := newTestAdapter()
+	other.config.SecretKey = "another-secret"
+	assert.False(t, other.VerifyCallback(sandbox.sent[0]))
+}
@@ -0,0 +1,75 @@
+package sandbox
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Scenario is what the sandbox does with one payment.
+//
+// It is chosen by the amount rather than by an input of its own, so a test drives it through the
+// same order request a real payment would use, and nothing outside this package has to know the
+// sandbox exists. The last two digits of the whole amount select it: 150001 succeeds, 150002
+// fails, and so on.
+type Scenario string
+
+const (
+	// ScenarioManual waits for someone to pay or decline on the sandbox's pay page. It is what
+	// every amount without a magic ending gets, so an ordinary amount behaves like a real payer.
+	ScenarioManual Scenario = "manual"
+
+	// ScenarioSucceed calls back with a successful payment after the configured delay.
+	ScenarioSucceed Scenario = "succeed"
+
+	// ScenarioFail calls back with a declined payment after the configured delay.
+	ScenarioFail Scenario = "fail"
+
+	// ScenarioTimeout never calls back, and answers a status query with "still in flight". It is
+	// the lost-callback case the watchdog exists for: the order sits until the watchdog expires it.
+	ScenarioTimeout Scenario = "timeout"
+
+	// ScenarioDuplicate calls back with a successful payment twice, as a gateway that did not get
+	// a clean answer the first time would.
+	ScenarioDuplicate Scenario = "duplicate"
+
+	// ScenarioRefundRefused succeeds like ScenarioSucceed, but refuses any refund of the payment.
+	ScenarioRefundRefused Scenario = "refund_refused"
+
+	// ScenarioCreateRefused refuses the payment at creation, as a gateway that is down would.
+	ScenarioCreateRefused Scenario = "create_refused"
+)
+
+// magicEndings maps the last two digits of a whole amount to the scenario they select.
+var magicEndings = map[int64]Scenario{
+	1: ScenarioSucceed,
+	2: ScenarioFail,
+	3: ScenarioTimeout,
+	4: ScenarioDuplicate,
+	5: ScenarioRefundRefused,
+	6: ScenarioCreateRefused,
+}
+
+// ScenarioOf returns the scenario an amount selects.
+func ScenarioOf(amount decimal.Decimal) Scenario {
+	ending := amount.IntPart() % 100
+	if ending < 0 {
+		ending = -ending
+	}
+	if scenario, exists := magicEndings[ending]; exists {
+		return scenario
+	}
+	return ScenarioManual
+}
+
+// callsBack reports whether the scenario sends its result by itself, without the pay page.
+func (this Scenario) callsBack() bool {
+	switch this {
+	case ScenarioSucceed, ScenarioFail, ScenarioDuplicate, ScenarioRefundRefused:
+		return true
+	}
+	return false
+}
+
+// paid reports the verdict a scenario that calls back by itself sends.
+func (this Scenario) paid() bool {
+	return this != ScenarioFail
+}
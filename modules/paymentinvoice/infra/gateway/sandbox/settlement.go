@@ -0,0 +1,26 @@
+package sandbox
+
+import (
+	"io"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/settlementcsv"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// settlementColumns is the sandbox's report, which nobody sends: a developer writes one by hand to
+// exercise reconciliation. Its names are the callback's own.
+var settlementColumns = settlementcsv.Columns{
+	OrderCode:        []string{"orderCode"},
+	RefTransactionId: []string{"transId"},
+	Amount:           []string{"amount"},
+	Kind:             []string{"type"},
+	KindValues: map[string]string{
+		"payment": itGateway.SettlementKindPayment,
+		"refund":  itGateway.SettlementKindRefund,
+	},
+}
+
+// ParseSettlement implements itGateway.SettlementParser.
+func (this *Adapter) ParseSettlement(report io.Reader) ([]itGateway.SettlementLine, error) {
+	return settlementcsv.Read(report, settlementColumns)
+}
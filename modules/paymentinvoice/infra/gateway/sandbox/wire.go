@@ -0,0 +1,51 @@
+package sandbox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// The result codes a sandbox callback carries. They mirror MoMo's, where zero is the one success
+// and anything else is a decline, so a receiver written against the sandbox has the same shape
+// as one written against a real wallet.
+const (
+	ResultCodeSuccess = 0
+	ResultCodeFailed  = 1
+)
+
+// CallbackPayload is the body the sandbox posts to its own webhook.
+type CallbackPayload struct {
+	OrderCode  string `json:"orderCode"`
+	ResultCode int    `json:"resultCode"`
+	TransId    string `json:"transId"`
+	Amount     int64  `json:"amount"`
+	SentAt     int64  `json:"sentAt"`
+	Signature  string `json:"signature"`
+}
+
+// The callback is signed like MoMo's: HMAC-SHA256 over "key=value" pairs joined with "&" in
+// alphabetical order of the key, hex-encoded lower case. The webhook verifies it exactly as it
+// would a real gateway's, so the sandbox exercises that path rather than bypassing it.
+func (this CallbackPayload) rawSignature() string {
+	return strings.Join([]string{
+		"amount=" + strconv.FormatInt(this.Amount, 10),
+		"orderCode=" + this.OrderCode,
+		"resultCode=" + strconv.Itoa(this.ResultCode),
+		"sentAt=" + strconv.FormatInt(this.SentAt, 10),
+		"transId=" + this.TransId,
+	}, "&")
+}
+
+func (this CallbackPayload) sign(secretKey string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(this.rawSignature()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether the payload's signature is its own, comparing in constant time.
+func (this CallbackPayload) verify(secretKey string) bool {
+	return hmac.Equal([]byte(this.sign(secretKey)), []byte(strings.ToLower(this.Signature)))
+}
@@ -0,0 +1,179 @@
+// Package settlementcsv reads a gateway's settlement report when it comes as CSV.
+//
+// The gateways agree on the format and on nothing else: each names its columns differently, some
+// in Vietnamese, and each has its own words for a payment and a refund. So each adapter declares
+// its columns here and keeps the parsing of its own report in its own package, and this package
+// only does what every CSV report needs — finding the columns by header, and reading an amount
+// the way a spreadsheet writes one.
+package settlementcsv
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"go.bryk.io/pkg/errors"
+
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// Columns describes one gateway's report.
+//
+// Each column is named by every header it is known to appear under, compared case-insensitively
+// with surrounding spaces ignored, because the same gateway's report differs between the merchant
+// portal's language settings.
+type Columns struct {
+	OrderCode        []string
+	RefTransactionId []string
+	Amount           []string
+
+	// Kind is optional. A report without it is taken to list payments only.
+	Kind []string
+
+	// KindValues translates the gateway's words for a kind, lower case, into the port's. A kind not
+	// listed here fails the line rather than guessing which way the money went.
+	KindValues map[string]string
+}
+
+// Read parses a CSV report into settlement lines.
+//
+// Empty lines, and lines of nothing but separators, are skipped. A report needs the amount column and at least one of the two
+// identifying columns; without those it cannot be matched against anything.
+func Read(report io.Reader, columns Columns) ([]itGateway.SettlementLine, error) {
+	reader := csv.NewReader(report)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the settlement report is empty")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "the settlement report's header could not be read")
+	}
+
+	index := indexHeader(header)
+	orderCodeAt := index.find(columns.OrderCode)
+	refIdAt := index.find(columns.RefTransactionId)
+	amountAt := index.find(columns.Amount)
+	kindAt := index.find(columns.Kind)
+
+	if amountAt < 0 {
+		return nil, errors.Errorf("the settlement report has no amount column (expected one of %s)",
+			strings.Join(columns.Amount, ", "))
+	}
+	if orderCodeAt < 0 && refIdAt < 0 {
+		return nil, errors.Errorf(
+			"the settlement report identifies no transaction (expected one of %s)",
+			strings.Join(append(append([]string{}, columns.OrderCode...), columns.RefTransactionId...), ", "))
+	}
+
+	lines := []itGateway.SettlementLine{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "the settlement report could not be read")
+		}
+		// The reader skips empty lines, so the position is asked of it rather than counted.
+		lineNo, _ := reader.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+
+		line := itGateway.SettlementLine{
+			LineNo:           lineNo,
+			OrderCode:        cell(record, orderCodeAt),
+			RefTransactionId: cell(record, refIdAt),
+			Kind:             itGateway.SettlementKindPayment,
+		}
+
+		line.Amount, err = ParseAmount(cell(record, amountAt))
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d of the settlement report", lineNo)
+		}
+
+		if kindAt >= 0 {
+			raw := cell(record, kindAt)
+			kind, known := columns.KindValues[strings.ToLower(raw)]
+			if !known {
+				return nil, errors.Errorf(
+					"line %d of the settlement report: '%s' is not a kind of transaction this gateway reports",
+					lineNo, raw)
+			}
+			line.Kind = kind
+		}
+
+		if line.OrderCode == "" && line.RefTransactionId == "" {
+			return nil, errors.Errorf("line %d of the settlement report identifies no transaction", lineNo)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// ParseAmount reads an amount as a spreadsheet exports it.
+//
+// Thousands separators and spaces are dropped: every gateway here deals in whole dong, where
+// "150,000" means one hundred and fifty thousand and nothing else. A minus sign is kept, because
+// some statements write a refund as a negative payment, but the amount is returned as its
+// magnitude — the kind, not the sign, says which way it went.
+func ParseAmount(raw string) (decimal.Decimal, error) {
+	cleaned := strings.NewReplacer(",", "", " ", "", "\u00a0", "").Replace(strings.TrimSpace(raw))
+	if cleaned == "" {
+		return decimal.Zero, errors.New("the amount is empty")
+	}
+	amount, err := decimal.NewFromString(cleaned)
+	if err != nil {
+		return decimal.Zero, errors.Errorf("'%s' is not an amount", raw)
+	}
+	return amount.Abs(), nil
+}
+
+type headerIndex map[string]int
+
+func indexHeader(header []string) headerIndex {
+	index := headerIndex{}
+	for position, name := range header {
+		// A report saved by Excel starts with a byte-order mark, which would otherwise make the
+		// first column's name never match.
+		key := normalize(strings.TrimPrefix(name, "\ufeff"))
+		if _, exists := index[key]; !exists {
+			index[key] = position
+		}
+	}
+	return index
+}
+
+// find returns the position of the first of the names present, or -1.
+func (this headerIndex) find(names []string) int {
+	for _, name := range names {
+		if position, exists := this[normalize(name)]; exists {
+			return position
+		}
+	}
+	return -1
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func cell(record []string, position int) string {
+	if position < 0 || position >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[position])
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
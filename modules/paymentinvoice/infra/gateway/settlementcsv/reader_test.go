@@ -0,0 +1,87 @@
+package settlementcsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+var testColumns = Columns{
+	OrderCode:        []string{"Mã đơn hàng", "orderId"},
+	RefTransactionId: []string{"Mã giao dịch", "transId"},
+	Amount:           []string{"Số tiền", "amount"},
+	Kind:             []string{"Loại giao dịch", "type"},
+	KindValues: map[string]string{
+		"thanh toán": itGateway.SettlementKindPayment,
+		"hoàn tiền":  itGateway.SettlementKindRefund,
+	},
+}
+
+func TestAReportIsReadByItsHeaders(t *testing.T) {
+	report := "Số tiền,Mã giao dịch,Loại giao dịch,Mã đơn hàng\n" +
+		"\"150,000\",3100000001,Thanh toán,1QAI0001\n" +
+		"\n" +
+		"50000,3100000002,Hoàn tiền,1QAI0001\n"
+
+	lines, err := Read(strings.NewReader(report), testColumns)
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, itGateway.SettlementLine{
+		LineNo:           2,
+		OrderCode:        "1QAI0001",
+		RefTransactionId: "3100000001",
+		Kind:             itGateway.SettlementKindPayment,
+		Amount:           decimal.RequireFromString("150000"),
+	}, lines[0])
+
+	// The blank line still counts, so the number points at the line in the file.
+	assert.Equal(t, 4, lines[1].LineNo)
+	assert.Equal(t, itGateway.SettlementKindRefund, lines[1].Kind)
+}
+
+// A report saved by Excel starts with a byte-order mark, and the first header must still match.
+func TestAByteOrderMarkIsIgnored(t *testing.T) {
+	report := "\ufeffamount,transId\n1000,ABC\n"
+
+	lines, err := Read(strings.NewReader(report), testColumns)
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.True(t, decimal.NewFromInt(1000).Equal(lines[0].Amount))
+	assert.Equal(t, itGateway.SettlementKindPayment, lines[0].Kind, "without a kind column, a line is a payment")
+}
+
+// A line that cannot be read fails the report: reconciling without it would report its money as
+// missing, which is a discrepancy that is not there.
+func TestAnUnreadableLineFailsTheReport(t *testing.T) {
+	for name, report := range map[string]string{
+		"amount":  "amount,transId\nabc,ABC\n",
+		"kind":    "amount,transId,type\n1000,ABC,chargeback\n",
+		"no id":   "amount,transId,orderId\n1000,,\n",
+		"no cols": "amount,something\n1000,ABC\n",
+		"empty":   "",
+	} {
+		_, err := Read(strings.NewReader(report), testColumns)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAmountsAreReadAsASpreadsheetWritesThem(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"150000":       "150000",
+		"150,000":      "150000",
+		"1 500 000":    "1500000",
+		"-50,000":      "50000",
+		"150000.00":    "150000",
+		"\u00a0150000": "150000",
+	} {
+		amount, err := ParseAmount(raw)
+		require.NoError(t, err, raw)
+		assert.True(t, decimal.RequireFromString(expected).Equal(amount), raw)
+	}
+}
@@ -0,0 +1,27 @@
+package vietqr
+
+import (
+	"io"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/settlementcsv"
+	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
+)
+
+// settlementColumns is the bank statement VietQR exports. It marks the direction of each movement
+// the way the gateway's own API does, C for money in and D for money out, and the reference number
+// is the one the bank's transaction callback carries.
+var settlementColumns = settlementcsv.Columns{
+	OrderCode:        []string{"Mã đơn hàng", "orderId"},
+	RefTransactionId: []string{"Số tham chiếu", "Reference Number", "referenceNumber"},
+	Amount:           []string{"Số tiền", "Amount", "amount"},
+	Kind:             []string{"Loại giao dịch", "transType"},
+	KindValues: map[string]string{
+		"c": itGateway.SettlementKindPayment,
+		"d": itGateway.SettlementKindRefund,
+	},
+}
+
+// ParseSettlement implements itGateway.SettlementParser.
+func (this *Adapter) ParseSettlement(report io.Reader) ([]itGateway.SettlementLine, error) {
+	return settlementcsv.Read(report, settlementColumns)
+}
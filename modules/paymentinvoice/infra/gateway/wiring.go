@@ -1,13 +1,16 @@
 // Package gateway builds the gateway registry this deployment will actually serve payments
 // through, from configuration.
 //
-// It is the only place that knows all three adapters exist. The domain layer selects one by
-// adapter code and the adapters themselves know nothing of each other, so this file is where
-// adding a fourth gateway takes its one edit outside its own package.
+// It is the only place that knows all the adapters exist. The domain layer selects one by adapter
+// code and the adapters themselves know nothing of each other, so this file is where adding a
+// gateway takes its one edit outside its own package.
 package gateway
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
+	"time"
 
 	"go.bryk.io/pkg/errors"
 
@@ -19,6 +22,7 @@ import (
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/momo"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/mpos"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/sandbox"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/vietqr"
 	itGateway "github.com/sky-as-code/nikki-erp/modules/paymentinvoice/interfaces/gateway"
 )
@@ -48,6 +52,9 @@ func BuildRegistry(
 	if err := registerVietQr(registry, cfg, httpClient, logger); err != nil {
 		return nil, err
 	}
+	if err := registerSandbox(registry, cfg, httpClient, logger); err != nil {
+		return nil, err
+	}
 
 	if len(registry.Codes()) == 0 {
 		// Not an error: a deployment that takes no card or wallet payments is legitimate, and
@@ -147,6 +154,56 @@ func registerVietQr(
 	return registry.Register(adapter)
 }
 
+// defaultSandboxCallbackDelaySecs matches config.default.yaml.
+const defaultSandboxCallbackDelaySecs = 3
+
+func registerSandbox(
+	registry *itGateway.Registry,
+	cfg config.ConfigService,
+	httpClient *httpclientclient.HttpClient,
+	logger logging.LoggerService,
+) error {
+	if !cfg.GetBool(constants.SandboxEnabled, false) {
+		return nil
+	}
+
+	callbackUrl, err := requireEndpoint(cfg, constants.SandboxCallbackUrl, "SANDBOX")
+	if err != nil {
+		return err
+	}
+
+	// The sandbox verifies its own signatures, so on one instance a key nobody chose is as good
+	// as one somebody did, and a developer need not invent one to get started.
+	secretKey := strings.TrimSpace(cfg.GetStr(constants.SandboxSecretKey, ""))
+	if secretKey == "" {
+		secretKey, err = randomSandboxKey()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Loud on purpose: this is the one gateway whose presence in the wrong environment gives
+	// goods away rather than failing to take payments.
+	logger.Warnf("paymentinvoice: the sandbox gateway is enabled; orders paid through it collect no money")
+
+	adapter := sandbox.NewAdapter(sandbox.Config{
+		SecretKey:  secretKey,
+		PayPageUrl: strings.TrimSpace(cfg.GetStr(constants.SandboxPayPageUrl, "")),
+		CallbackDelay: time.Duration(
+			cfg.GetInt(constants.SandboxCallbackDelaySecs, defaultSandboxCallbackDelaySecs)) * time.Second,
+	}, httpclient.NewHttpCaller(callbackUrl, httpClient, logger), logger)
+
+	return registry.Register(adapter)
+}
+
+func randomSandboxKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, "paymentinvoice: failed to generate a sandbox secret key")
+	}
+	return hex.EncodeToString(key), nil
+}
+
 // requireEndpoint reads a gateway's base URL, refusing an enabled gateway that has none.
 //
 // NewHttpCaller panics on an empty or malformed base URL, which during Init would take the whole
@@ -0,0 +1,36 @@
+// Package external declares Payment & Invoice's local ports onto the capabilities other modules
+// offer.
+//
+// Code in this module depends on these interfaces and never on another module's service directly,
+// so that splitting a module into its own process changes only the binding in infra/external. See
+// docs/wiki/01 "Microservice-ready Monolith".
+package external
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+// DocumentExtService is Payment & Invoice's port onto Document's rendering capability.
+//
+// The invoice describes itself — title, customer, lines, totals — and Document lays it out with the
+// organization's template. Neither module learns the other's records.
+type DocumentExtService interface {
+	RenderPdf(ctx corectx.Context, query RenderPdfQuery) (*RenderPdfResult, error)
+}
+
+type RenderPdfQuery = itRendering.RenderPdfQuery
+type RenderPdfResult = itRendering.RenderPdfResult
+type Document = itRendering.Document
+type Fact = itRendering.Fact
+type Partner = itRendering.Partner
+type Currency = itRendering.Currency
+type Line = itRendering.Line
+type Total = itRendering.Total
+
+const (
+	DocumentTypeInvoice = itRendering.DocumentTypeInvoice
+	LineKindItem        = itRendering.LineKindItem
+)
+
+var FormatDate = itRendering.FormatDate
@@ -0,0 +1,31 @@
+package external
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itCurrency "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/currency"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
+)
+
+// CurrencyExtService is Payment & Invoice's port onto Essential's currency capability.
+//
+// It is read-only and narrow: a printed invoice needs the code it is denominated in and how many
+// fractional digits that currency is quoted to, and nothing more.
+type CurrencyExtService interface {
+	GetCurrency(ctx corectx.Context, query GetCurrencyQuery) (*GetCurrencyResult, error)
+}
+
+type GetCurrencyQuery = itCurrency.GetCurrencyQuery
+type GetCurrencyResult = itCurrency.GetCurrencyResult
+
+// TaxExtService is Payment & Invoice's port onto Essential's tax computation.
+//
+// An invoice line that names taxes is taxed by Essential at issue, so that an invoice and a purchase
+// order carrying the same taxes come to the same amounts.
+type TaxExtService interface {
+	ComputeTaxes(ctx corectx.Context, query ComputeTaxesQuery) (*ComputeTaxesResult, error)
+}
+
+type ComputeTaxesQuery = itTax.ComputeTaxesQuery
+type ComputeTaxesResult = itTax.ComputeTaxesResult
+type TaxableLine = itTax.TaxableLine
+type TaxBreakdown = itTax.TaxBreakdown
@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"io"
+
+	"github.com/shopspring/decimal"
+)
+
+// SettlementParser is implemented by an adapter whose gateway sends settlement reports: the
+// statement of what it actually paid out, which reconciliation compares against the transactions.
+//
+// It is a separate interface rather than part of PaymentGateway because not every gateway has a
+// statement worth parsing, and every adapter that lacks one would otherwise have to stub it. The
+// reconciliation import finds it by type assertion and refuses a method whose adapter has none.
+type SettlementParser interface {
+	// ParseSettlement reads a report in the gateway's own format. A line the parser cannot read
+	// fails the whole report, naming the line: a statement that is reconciled with a line
+	// silently dropped reports that line's money as missing, which sends someone hunting for a
+	// discrepancy that is not there.
+	ParseSettlement(report io.Reader) ([]SettlementLine, error)
+}
+
+// The kinds of money movement a settlement line reports. They are the transaction types, so a line
+// is matched only against a transaction moving money the same way.
+const (
+	SettlementKindPayment = "payment"
+	SettlementKindRefund  = "refund"
+)
+
+// SettlementLine is one movement of money on a gateway's statement.
+type SettlementLine struct {
+	// LineNo is the line's position in the report, counting the header as line 1, so a
+	// discrepancy can be pointed at in the file it came from.
+	LineNo int
+
+	// OrderCode is the key the gateway knows the order by, when the report carries it.
+	OrderCode string
+
+	// RefTransactionId is the gateway's own identifier for the movement, when the report carries it.
+	RefTransactionId string
+
+	Kind   string
+	Amount decimal.Decimal
+}
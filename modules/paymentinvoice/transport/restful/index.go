@@ -9,6 +9,7 @@ import (
 	corelog "github.com/sky-as-code/nikki-erp/modules/core/logging"
 	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/constants"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/dynamicengines"
 	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/vietqr"
@@ -26,6 +27,11 @@ const (
 	pathWebhookMpos               = "/webhooks/mpos"
 	pathWebhookVietQrToken        = "/webhooks/vietqr/token_generate"
 	pathWebhookVietQrTransaction  = "/webhooks/vietqr/transaction_sync"
+
+	// The sandbox's own routes are not registered with anyone, so they are free to change. They
+	// are named here with the others only so the sandbox's CALLBACK_URL has one place to match.
+	pathWebhookSandbox = "/webhooks/sandbox"
+	pathSandboxPay     = "/sandbox/pay/:orderCode"
 )
 
 func InitRestfulHandlers() error {
@@ -82,6 +88,14 @@ func registerWebhookRoutes(
 	routeV1.POST(pathWebhookMpos, webhook.MposWebhook, m.PublicUnauthorized)
 	routeV1.POST(pathWebhookVietQrToken, webhook.VietQrTokenGenerate, m.PublicUnauthorized)
 	routeV1.POST(pathWebhookVietQrTransaction, webhook.VietQrTransactionSync, m.PublicUnauthorized)
+
+	// The sandbox's routes exist only where the sandbox is enabled, so a deployment that takes
+	// real payments does not even serve a page that settles orders.
+	if _, enabled := registry.Get(models.AdapterCodeSandbox); enabled {
+		routeV1.POST(pathWebhookSandbox, webhook.SandboxCallback, m.PublicUnauthorized)
+		routeV1.GET(pathSandboxPay, webhook.SandboxPayPage, m.PublicUnauthorized)
+		routeV1.POST(pathSandboxPay, webhook.SandboxPay, m.PublicUnauthorized)
+	}
 }
 
 // registerEngineRoutes exposes every Payment & Invoice resource engine over HTTP.
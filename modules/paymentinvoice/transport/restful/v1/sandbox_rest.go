@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/domain/services"
+	"github.com/sky-as-code/nikki-erp/modules/paymentinvoice/infra/gateway/sandbox"
+)
+
+// The outcomes the pay page's two buttons post.
+const (
+	sandboxOutcomePaid     = "paid"
+	sandboxOutcomeDeclined = "declined"
+)
+
+// SandboxCallback receives the sandbox gateway's verdict.
+//
+// It is handled exactly as a real wallet's IPN is — verified, then applied, answered 204 whatever
+// the outcome — because exercising that path is the sandbox's whole purpose.
+func (this *WebhookRest) SandboxCallback(echoCtx *echo.Context) error {
+	var payload sandbox.CallbackPayload
+	if err := echoCtx.Bind(&payload); err != nil {
+		this.logger.Warnf("paymentinvoice: malformed sandbox callback body: %s", err.Error())
+		return echoCtx.NoContent(http.StatusNoContent)
+	}
+
+	adapter, ok := adapterAs[*sandbox.Adapter](this.registry, models.AdapterCodeSandbox)
+	if !ok {
+		this.logger.Warnf("paymentinvoice: a sandbox callback arrived but the sandbox gateway is not enabled")
+		return echoCtx.NoContent(http.StatusNoContent)
+	}
+
+	if !adapter.VerifyCallback(payload) {
+		this.logger.Warnf("paymentinvoice: a sandbox callback failed signature verification")
+		return echoCtx.NoContent(http.StatusNoContent)
+	}
+
+	this.apply(echoCtx, services.GatewayResult{
+		OrderCode:        payload.OrderCode,
+		Paid:             sandbox.CallbackOutcome(payload),
+		RefTransactionId: payload.TransId,
+		RefPayload:       asPayloadMap(payload),
+	})
+	return echoCtx.NoContent(http.StatusNoContent)
+}
+
+// SandboxPayPage shows a sandbox payment and, while it is pending, the two buttons that settle it.
+//
+// It stands in for the wallet app a payer would otherwise be sent to, so it is public: whoever
+// holds the link can pay, which is also true of a real pay URL.
+func (this *WebhookRest) SandboxPayPage(echoCtx *echo.Context) error {
+	adapter, ok := adapterAs[*sandbox.Adapter](this.registry, models.AdapterCodeSandbox)
+	if !ok {
+		return echoCtx.NoContent(http.StatusNotFound)
+	}
+
+	payment, known := adapter.Payment(echoCtx.Param("orderCode"))
+	if !known {
+		return renderSandboxPage(echoCtx, http.StatusNotFound, sandboxPageView{
+			OrderCode: echoCtx.Param("orderCode"),
+			Message:   "The sandbox has no such payment. It may have been forgotten at a restart.",
+		})
+	}
+	return renderSandboxPage(echoCtx, http.StatusOK, sandboxPageView{
+		OrderCode: payment.OrderCode,
+		Payment:   &payment,
+	})
+}
+
+// SandboxPay settles a pending sandbox payment from the pay page.
+//
+// The sandbox calls back before this returns, so by the time the page is shown again the order
+// has its verdict. The page is then reloaded by redirect, so refreshing it does not post twice.
+func (this *WebhookRest) SandboxPay(echoCtx *echo.Context) error {
+	adapter, ok := adapterAs[*sandbox.Adapter](this.registry, models.AdapterCodeSandbox)
+	if !ok {
+		return echoCtx.NoContent(http.StatusNotFound)
+	}
+
+	orderCode := echoCtx.Param("orderCode")
+	outcome := strings.TrimSpace(echoCtx.FormValue("outcome"))
+	if outcome != sandboxOutcomePaid && outcome != sandboxOutcomeDeclined {
+		return renderSandboxPage(echoCtx, http.StatusBadRequest, sandboxPageView{
+			OrderCode: orderCode,
+			Message:   "Choose whether the payment is paid or declined.",
+		})
+	}
+
+	err := adapter.Pay(echoCtx.Request().Context(), orderCode, outcome == sandboxOutcomePaid)
+	switch {
+	case errors.Is(err, sandbox.ErrUnknownPayment):
+		return renderSandboxPage(echoCtx, http.StatusNotFound, sandboxPageView{
+			OrderCode: orderCode,
+			Message:   "The sandbox has no such payment. It may have been forgotten at a restart.",
+		})
+	case errors.Is(err, sandbox.ErrAlreadySettled):
+		return renderSandboxPage(echoCtx, http.StatusConflict, sandboxPageView{
+			OrderCode: orderCode,
+			Message:   "This payment has already been settled.",
+		})
+	case err != nil:
+		// The verdict is recorded in the sandbox but did not reach the webhook, which leaves the
+		// order as a lost callback would: pending, until the watchdog asks the sandbox about it.
+		this.logger.Warnf("paymentinvoice: sandbox callback for '%s' failed: %s", orderCode, err.Error())
+		return renderSandboxPage(echoCtx, http.StatusBadGateway, sandboxPageView{
+			OrderCode: orderCode,
+			Message:   "The payment was settled but the callback did not get through: " + err.Error(),
+		})
+	}
+
+	return echoCtx.Redirect(http.StatusSeeOther, echoCtx.Request().URL.Path)
+}
+
+type sandboxPageView struct {
+	OrderCode string
+	Payment   *sandbox.Payment
+	Message   string
+}
+
+var sandboxPage = template.Must(template.New("sandbox").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sandbox payment {{.OrderCode}}</title></head>
+<body style="font-family: sans-serif; max-width: 32em; margin: 3em auto;">
+<h1>Sandbox payment</h1>
+<p><strong>No money is collected here.</strong> This page stands in for a payment gateway during development.</p>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{with .Payment}}
+<table>
+<tr><th align="left">Order code</th><td>{{.OrderCode}}</td></tr>
+<tr><th align="left">Amount</th><td>{{.Amount}}</td></tr>
+<tr><th align="left">Scenario</th><td>{{.Scenario}}</td></tr>
+<tr><th align="left">Status</th><td>{{if .Settled}}{{if .Paid}}paid{{else}}declined{{end}} ({{.TransId}}){{else}}pending{{end}}</td></tr>
+</table>
+{{if not .Settled}}
+<form method="post">
+<button type="submit" name="outcome" value="paid">Pay</button>
+<button type="submit" name="outcome" value="declined">Decline</button>
+</form>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func renderSandboxPage(echoCtx *echo.Context, status int, view sandboxPageView) error {
+	var page strings.Builder
+	if err := sandboxPage.Execute(&page, view); err != nil {
+		return err
+	}
+	return echoCtx.HTML(status, page.String())
+}
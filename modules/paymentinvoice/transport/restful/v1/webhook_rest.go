@@ -10,6 +10,8 @@
 //     authentication.
 //   - VietQR has this deployment host a token endpoint the bank logs into, and then presents the
 //     bearer we issued.
+//   - The sandbox, which stands in for a gateway during development, signs its callback like
+//     MoMo does, and serves the page a sandbox payment is paid on.
 //
 // A body that fails its check is refused without touching an order. None of these endpoints tells
 // an unauthenticated caller whether an order exists: that would let anyone enumerate order codes.
@@ -39,7 +41,7 @@ type InboundAuth interface {
 	ValidateBearer(authorization string) bool
 }
 
-// WebhookRest serves the three gateways' callbacks.
+// WebhookRest serves the gateways' callbacks.
 type WebhookRest struct {
 	orders   *services.OrderDomainService
 	registry *itGateway.Registry
@@ -29,6 +29,7 @@ const (
 	PurchaseOrderLineFieldDiscountPercent   = "discount_percent"
 	PurchaseOrderLineFieldExpectedArrival   = "expected_arrival"
 	PurchaseOrderLineFieldSubtotal          = "subtotal"
+	PurchaseOrderLineFieldTaxIds            = "tax_ids"
 	PurchaseOrderLineFieldTaxAmount         = "tax_amount"
 	PurchaseOrderLineFieldTotal             = "total"
 	PurchaseOrderLineEdgeOrder              = "order"
@@ -181,6 +182,16 @@ func (this *PurchaseOrderLine) SetSubtotal(v *decimal.Decimal) {
 	this.fields.SetDecimal(PurchaseOrderLineFieldSubtotal, v)
 }
 
+// GetTaxIds reads the taxes the line names. A line read from the database carries them as []any, one
+// built in code as []string, so both are accepted.
+func (this PurchaseOrderLine) GetTaxIds() []model.Id {
+	return TaxIdsOf(this.fields)
+}
+
+func (this *PurchaseOrderLine) SetTaxIds(v []model.Id) {
+	this.fields.SetStrings(PurchaseOrderLineFieldTaxIds, v)
+}
+
 func (this PurchaseOrderLine) GetTaxAmount() *decimal.Decimal {
 	return this.fields.GetDecimal(PurchaseOrderLineFieldTaxAmount)
 }
@@ -196,3 +207,20 @@ func (this PurchaseOrderLine) GetTotal() *decimal.Decimal {
 func (this *PurchaseOrderLine) SetTotal(v *decimal.Decimal) {
 	this.fields.SetDecimal(PurchaseOrderLineFieldTotal, v)
 }
+
+// TaxIdsOf reads tax_ids from a line's raw fields, for the services that work on those directly.
+func TaxIdsOf(line dmodel.DynamicFields) []model.Id {
+	switch typed := line[PurchaseOrderLineFieldTaxIds].(type) {
+	case []string:
+		return typed
+	case []any:
+		ids := make([]model.Id, 0, len(typed))
+		for _, item := range typed {
+			if id, ok := item.(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	return nil
+}
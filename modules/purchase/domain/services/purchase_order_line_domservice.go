@@ -10,6 +10,7 @@ import (
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 
 	"github.com/sky-as-code/nikki-erp/modules/purchase/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/purchase/interfaces/external"
 )
 
 // The line service is where "the stored totals are always the totals of the lines" is actually
@@ -56,6 +57,9 @@ func (this *PurchaseOrderLineDomainServiceImpl) Create(
 		if err := this.prepareProduct(tranxCtx, params, vErrs); err != nil {
 			return err
 		}
+		if err := assertLineTaxesUsable(tranxCtx, params, vErrs); err != nil {
+			return err
+		}
 		if vErrs.Count() > 0 {
 			result = &dyn.OpResult[dmodel.DynamicFields]{ClientErrors: *vErrs}
 			return nil
@@ -96,6 +100,11 @@ func (this *PurchaseOrderLineDomainServiceImpl) Update(
 			if err := this.prepareProduct(tranxCtx, merged, vErrs); err != nil {
 				return err
 			}
+			// Only the taxes the request names are checked, not the merged line's: a tax withdrawn
+			// from use must not stop a buyer correcting the quantity of a line that already carries it.
+			if err := assertLineTaxesUsable(tranxCtx, params, vErrs); err != nil {
+				return err
+			}
 			if vErrs.Count() > 0 {
 				result = &dyn.OpResult[dyn.MutateResultData]{ClientErrors: *vErrs}
 				return nil
@@ -176,6 +185,29 @@ func (this *PurchaseOrderLineDomainServiceImpl) prepareProduct(
 	return this.products.PrepareLine(ctx, line, vErrs)
 }
 
+// assertLineTaxesUsable refuses taxes a line may not newly name. A line naming none is not checked,
+// nor is a section or a note, which carries no money for a tax to be charged on.
+func assertLineTaxesUsable(ctx corectx.Context, line dmodel.DynamicFields, vErrs *ft.ClientErrors) error {
+	taxIds := models.TaxIdsOf(line)
+	if len(taxIds) == 0 || !isMoneyBearingLine(line) {
+		return nil
+	}
+	if orderTaxService == nil {
+		return errors.New("a purchase line names taxes but the tax port is not bound; " +
+			"purchase/infra/external must bind it")
+	}
+
+	usable, err := orderTaxService.AssertUsable(ctx, itExt.AssertTaxesUsableQuery{
+		Ids:   taxIds,
+		Field: models.PurchaseOrderLineFieldTaxIds,
+	})
+	if err != nil {
+		return errors.Wrap(err, "assertLineTaxesUsable")
+	}
+	vErrs.Append(usable.ClientErrors...)
+	return nil
+}
+
 // mergeStoredLine reads the stored line and returns it with the incoming params laid over the top,
 // along with the id of the order that owns it.
 //
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
@@ -396,6 +397,13 @@ func timeOf(fields dmodel.DynamicFields, key string) (time.Time, bool) {
 			return time.Time{}, false
 		}
 		return *typed, !typed.IsZero()
+	case model.ModelDateTime:
+		return typed.GoTime(), !typed.GoTime().IsZero()
+	case *model.ModelDateTime:
+		if typed == nil {
+			return time.Time{}, false
+		}
+		return typed.GoTime(), !typed.GoTime().IsZero()
 	case string:
 		parsed, err := time.Parse(time.RFC3339, typed)
 		if err != nil {
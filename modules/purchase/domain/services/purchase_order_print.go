@@ -0,0 +1,271 @@
+package services
+
+import (
+	"sort"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/purchase/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/purchase/interfaces/external"
+)
+
+// OrderPrinter describes a purchase order to Document and returns the PDF it renders.
+//
+// It is not a method of the derived order service because it is the only order operation that
+// needs the party and document ports, and the lifecycle tests build that service with no ports at
+// all.
+type OrderPrinter struct {
+	parties    itExt.PartyExtService
+	documents  itExt.DocumentExtService
+	currencies itExt.CurrencyExtService
+	uoms       itExt.UomExtService
+}
+
+func NewOrderPrinter(
+	parties itExt.PartyExtService,
+	documents itExt.DocumentExtService,
+	currencies itExt.CurrencyExtService,
+	uoms itExt.UomExtService,
+) *OrderPrinter {
+	return &OrderPrinter{parties: parties, documents: documents, currencies: currencies, uoms: uoms}
+}
+
+// OrderPrintData is the rendered order.
+type OrderPrintData struct {
+	FileName string
+	Content  []byte
+}
+
+// RenderPdf renders one order, as a request for quotation or a purchase order depending on how far
+// it has come. It prints at every status: an RFQ is printed to be sent, and a cancelled order is
+// still a document someone may need to produce.
+func (this *OrderPrinter) RenderPdf(
+	ctx corectx.Context, orderId string,
+) (*dyn.OpResult[OrderPrintData], error) {
+	order, err := loadOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return &dyn.OpResult[OrderPrintData]{ClientErrors: orderNotFoundResult(orderId).ClientErrors}, nil
+	}
+
+	lineEngine, err := engineFor(models.PurchaseOrderLineSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := models.FindOrderLines(ctx, lineEngine.ResourceRepository(), orderId, models.MaxOrderLines)
+	if err != nil {
+		return nil, err
+	}
+
+	partner, err := this.partnerOf(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := this.currencyOf(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	units, err := this.unitSymbolsOf(ctx, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := this.documents.RenderPdf(ctx, itExt.RenderPdfQuery{
+		OrgId:        stringOf(order, models.PurchaseOrderFieldOrgId),
+		DocumentType: itExt.DocumentTypePurchaseOrder,
+		Document:     buildOrderDocument(order, lines, partner, currency, units),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "render purchase order")
+	}
+	if rendered.ClientErrors.Count() > 0 {
+		return &dyn.OpResult[OrderPrintData]{ClientErrors: rendered.ClientErrors}, nil
+	}
+
+	return &dyn.OpResult[OrderPrintData]{
+		HasData: true,
+		Data:    OrderPrintData{FileName: rendered.Data.FileName, Content: rendered.Data.Content},
+	}, nil
+}
+
+func (this *OrderPrinter) partnerOf(ctx corectx.Context, order dmodel.DynamicFields) (itExt.Partner, error) {
+	partner := itExt.Partner{Heading: "Vendor"}
+
+	vendorId := stringOf(order, models.PurchaseOrderFieldVendorId)
+	if vendorId == "" {
+		return partner, nil
+	}
+	found, err := this.parties.GetParty(ctx, itExt.GetPartyQuery{PartyId: vendorId})
+	if err != nil {
+		return partner, errors.Wrap(err, "render purchase order")
+	}
+	if found != nil && found.HasData {
+		partner.Name = found.Data.Name
+		partner.TaxCode = found.Data.TaxId
+		partner.Address = found.Data.Address
+	}
+	return partner, nil
+}
+
+// currencyOf reads the order's currency. One that no longer resolves prints the amounts at two
+// places with no code rather than failing the download, the same fallback the totals round with.
+func (this *OrderPrinter) currencyOf(ctx corectx.Context, order dmodel.DynamicFields) (itExt.Currency, error) {
+	currency := itExt.Currency{DecimalPlaces: 2}
+
+	currencyId := stringOf(order, models.PurchaseOrderFieldCurrencyId)
+	if currencyId == "" {
+		return currency, nil
+	}
+	found, err := this.currencies.GetCurrency(ctx, itExt.GetCurrencyQuery{Id: currencyId})
+	if err != nil {
+		return currency, errors.Wrap(err, "render purchase order")
+	}
+	if found != nil && found.HasData {
+		currency = itExt.Currency{
+			Code:          found.Data.Code,
+			Symbol:        found.Data.Symbol,
+			DecimalPlaces: found.Data.DecimalPlaces,
+		}
+	}
+	return currency, nil
+}
+
+// unitSymbolsOf resolves each distinct unit the lines use once, rather than once per line.
+func (this *OrderPrinter) unitSymbolsOf(
+	ctx corectx.Context, lines []dmodel.DynamicFields,
+) (map[string]string, error) {
+	symbols := map[string]string{}
+	for _, line := range lines {
+		uomId := stringOf(line, models.PurchaseOrderLineFieldUomId)
+		if _, seen := symbols[uomId]; uomId == "" || seen {
+			continue
+		}
+		found, err := this.uoms.GetUom(ctx, itExt.GetUomQuery{Id: uomId})
+		if err != nil {
+			return nil, errors.Wrap(err, "render purchase order")
+		}
+		symbols[uomId] = ""
+		if found != nil && found.HasData {
+			symbols[uomId] = found.Data.Symbol
+		}
+	}
+	return symbols, nil
+}
+
+// buildOrderDocument says what a printed order contains. It is pure so the content of the document
+// can be pinned without a renderer.
+func buildOrderDocument(
+	order dmodel.DynamicFields,
+	lines []dmodel.DynamicFields,
+	partner itExt.Partner,
+	currency itExt.Currency,
+	unitSymbols map[string]string,
+) itExt.Document {
+	status := models.PurchaseOrderStatus(stringOf(order, models.PurchaseOrderFieldStatus))
+	isRfq := status == models.PurchaseOrderStatusRfq || status == models.PurchaseOrderStatusRfqSent
+
+	doc := itExt.Document{
+		Title:    "Purchase Order",
+		Number:   stringOf(order, models.PurchaseOrderFieldCode),
+		Partner:  partner,
+		Currency: currency,
+		Notes:    stringOf(order, models.PurchaseOrderFieldTermsConditions),
+	}
+	if isRfq {
+		doc.Title = "Request for Quotation"
+	}
+
+	addDate := func(label string, field string) {
+		if value, ok := timeOf(order, field); ok {
+			doc.Facts = append(doc.Facts, itExt.Fact{Label: label, Value: itExt.FormatDate(value)})
+		}
+	}
+	if isRfq {
+		addDate("Quote by", models.PurchaseOrderFieldOrderDeadline)
+	} else {
+		addDate("Confirmed", models.PurchaseOrderFieldConfirmedAt)
+	}
+	addDate("Expected arrival", models.PurchaseOrderFieldExpectedArrival)
+	if reference := stringOf(order, models.PurchaseOrderFieldVendorReference); reference != "" {
+		doc.Facts = append(doc.Facts, itExt.Fact{Label: "Your reference", Value: reference})
+	}
+	if status == models.PurchaseOrderStatusCancelled {
+		doc.Facts = append(doc.Facts, itExt.Fact{Label: "Status", Value: "Cancelled"})
+	}
+
+	for _, line := range sortedBySequence(lines) {
+		doc.Lines = append(doc.Lines, printedLineOf(line, unitSymbols))
+	}
+
+	doc.Totals = []itExt.Total{
+		{Label: "Untaxed", Amount: decimalOf(order, models.PurchaseOrderFieldUntaxedAmount)},
+		{Label: "Tax", Amount: decimalOf(order, models.PurchaseOrderFieldTaxAmount)},
+		{Label: "Total", Amount: decimalOf(order, models.PurchaseOrderFieldTotalAmount), Emphasis: true},
+	}
+	return doc
+}
+
+// printedLineOf maps one order line. The amount printed is the line's subtotal, after discount and
+// before tax, so the column adds up to the untaxed total beneath it.
+func printedLineOf(line dmodel.DynamicFields, unitSymbols map[string]string) itExt.Line {
+	description := stringOf(line, models.PurchaseOrderLineFieldDescription)
+
+	switch models.PurchaseOrderLineType(stringOf(line, models.PurchaseOrderLineFieldLineType)) {
+	case models.PurchaseOrderLineTypeSection:
+		return itExt.Line{Kind: itExt.LineKindSection, Description: description}
+	case models.PurchaseOrderLineTypeSubsection:
+		return itExt.Line{Kind: itExt.LineKindSubsection, Description: description}
+	case models.PurchaseOrderLineTypeNote:
+		return itExt.Line{Kind: itExt.LineKindNote, Description: description}
+	}
+
+	printed := itExt.Line{
+		Kind:        itExt.LineKindItem,
+		Description: description,
+		Quantity:    decimalOf(line, models.PurchaseOrderLineFieldQuantity),
+		Unit:        unitSymbols[stringOf(line, models.PurchaseOrderLineFieldUomId)],
+		UnitPrice:   decimalOf(line, models.PurchaseOrderLineFieldUnitPrice),
+		Amount:      decimalOf(line, models.PurchaseOrderLineFieldSubtotal),
+	}
+	// A zero discount is left out so that an order without discounts prints no discount column.
+	if discount := decimalOf(line, models.PurchaseOrderLineFieldDiscountPercent); !discount.IsZero() {
+		printed.DiscountPercent = &discount
+	}
+	tax := decimalOf(line, models.PurchaseOrderLineFieldTaxAmount)
+	printed.TaxAmount = &tax
+	return printed
+}
+
+// sortedBySequence orders lines as the buyer arranged them. The search returns them in no order
+// the buyer chose, and a section heading printed after its own lines would head the wrong ones.
+func sortedBySequence(lines []dmodel.DynamicFields) []dmodel.DynamicFields {
+	sorted := make([]dmodel.DynamicFields, len(lines))
+	copy(sorted, lines)
+	sort.SliceStable(sorted, func(left, right int) bool {
+		return sequenceOf(sorted[left]) < sequenceOf(sorted[right])
+	})
+	return sorted
+}
+
+func sequenceOf(line dmodel.DynamicFields) int64 {
+	switch typed := line[models.PurchaseOrderLineFieldSequence].(type) {
+	case int32:
+		return int64(typed)
+	case *int32:
+		if typed != nil {
+			return int64(*typed)
+		}
+	case int:
+		return int64(typed)
+	case int64:
+		return typed
+	case float64:
+		return int64(typed)
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/purchase/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/purchase/interfaces/external"
+)
+
+func printedOrder(status models.PurchaseOrderStatus) dmodel.DynamicFields {
+	return dmodel.DynamicFields{
+		models.PurchaseOrderFieldCode:          "P00042",
+		models.PurchaseOrderFieldStatus:        string(status),
+		models.PurchaseOrderFieldOrderDeadline: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+		models.PurchaseOrderFieldConfirmedAt:   time.Date(2026, 3, 12, 9, 0, 0, 0, time.UTC),
+		models.PurchaseOrderFieldUntaxedAmount: dec("90"),
+		models.PurchaseOrderFieldTaxAmount:     dec("9"),
+		models.PurchaseOrderFieldTotalAmount:   dec("99"),
+	}
+}
+
+func sequenced(line dmodel.DynamicFields, sequence int32, description string) dmodel.DynamicFields {
+	line[models.PurchaseOrderLineFieldSequence] = sequence
+	line[models.PurchaseOrderLineFieldDescription] = description
+	return line
+}
+
+func printOrder(order dmodel.DynamicFields, lines []dmodel.DynamicFields) itExt.Document {
+	return buildOrderDocument(order, lines, itExt.Partner{}, itExt.Currency{}, nil)
+}
+
+// An order that has not been confirmed is still asking for a price, and a vendor handed a document
+// titled "Purchase Order" would take it as a commitment to buy.
+func TestAnUnconfirmedOrderPrintsAsARequestForQuotation(t *testing.T) {
+	rfq := printOrder(printedOrder(models.PurchaseOrderStatusRfqSent), nil)
+	assert.Equal(t, "Request for Quotation", rfq.Title)
+	require.NotEmpty(t, rfq.Facts)
+	assert.Equal(t, "Quote by", rfq.Facts[0].Label)
+
+	confirmed := printOrder(printedOrder(models.PurchaseOrderStatusPurchaseOrder), nil)
+	assert.Equal(t, "Purchase Order", confirmed.Title)
+	require.NotEmpty(t, confirmed.Facts)
+	assert.Equal(t, "Confirmed", confirmed.Facts[0].Label)
+}
+
+func TestOrderLinesPrintInSequence(t *testing.T) {
+	lines := []dmodel.DynamicFields{
+		sequenced(productLine("2", "45", "0", "9"), 20, "Paper"),
+		sequenced(dmodel.DynamicFields{
+			models.PurchaseOrderLineFieldLineType: string(models.PurchaseOrderLineTypeSection),
+		}, 10, "Office supplies"),
+	}
+
+	doc := printOrder(printedOrder(models.PurchaseOrderStatusPurchaseOrder), lines)
+
+	require.Len(t, doc.Lines, 2)
+	assert.Equal(t, itExt.LineKindSection, doc.Lines[0].Kind)
+	assert.Equal(t, "Office supplies", doc.Lines[0].Description)
+	assert.Equal(t, itExt.LineKindItem, doc.Lines[1].Kind)
+	assert.Equal(t, "Paper", doc.Lines[1].Description)
+}
+
+func TestAZeroDiscountIsNotPrinted(t *testing.T) {
+	lines := []dmodel.DynamicFields{
+		productLine("1", "10", "0", "1"),
+		productLine("1", "10", "5", "1"),
+	}
+
+	doc := printOrder(printedOrder(models.PurchaseOrderStatusPurchaseOrder), lines)
+
+	require.Len(t, doc.Lines, 2)
+	assert.Nil(t, doc.Lines[0].DiscountPercent)
+	require.NotNil(t, doc.Lines[1].DiscountPercent)
+	assert.True(t, doc.Lines[1].DiscountPercent.Equal(dec("5")))
+}
+
+// The totals are the stored ones, so the printed order agrees with the screen to the last digit.
+func TestOrderTotalsArePrintedAsStored(t *testing.T) {
+	doc := printOrder(printedOrder(models.PurchaseOrderStatusPurchaseOrder), nil)
+
+	require.Len(t, doc.Totals, 3)
+	assert.Equal(t, "Untaxed", doc.Totals[0].Label)
+	assert.True(t, doc.Totals[0].Amount.Equal(dec("90")))
+	assert.Equal(t, "Total", doc.Totals[2].Label)
+	assert.True(t, doc.Totals[2].Amount.Equal(dec("99")))
+	assert.True(t, doc.Totals[2].Emphasis)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/purchase/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/purchase/interfaces/external"
+)
+
+// fakeTaxes stands in for Essential: it records what it was asked and answers a fixed 10% added tax,
+// which is all these tests need to see how the answer is used.
+type fakeTaxes struct {
+	computed   []itExt.ComputeTaxesQuery
+	asserted   []itExt.AssertTaxesUsableQuery
+	violations ft.ClientErrors
+}
+
+func (this *fakeTaxes) AssertUsable(
+	_ corectx.Context, query itExt.AssertTaxesUsableQuery,
+) (*itExt.AssertTaxesUsableResult, error) {
+	this.asserted = append(this.asserted, query)
+	return &itExt.AssertTaxesUsableResult{ClientErrors: this.violations}, nil
+}
+
+func (this *fakeTaxes) ComputeTaxes(
+	_ corectx.Context, query itExt.ComputeTaxesQuery,
+) (*itExt.ComputeTaxesResult, error) {
+	this.computed = append(this.computed, query)
+	data := itExt.ComputeTaxesResultData{}
+	for _, line := range query.Lines {
+		tax := line.Amount.Div(dec("10")).Round(2)
+		data.Lines = append(data.Lines, itExt.TaxedLine{Untaxed: line.Amount, Tax: tax, Total: line.Amount.Add(tax)})
+	}
+	return &itExt.ComputeTaxesResult{HasData: true, Data: data}, nil
+}
+
+func withFakeTaxes(t *testing.T) *fakeTaxes {
+	original := orderTaxService
+	t.Cleanup(func() { orderTaxService = original })
+
+	fake := &fakeTaxes{}
+	SetOrderTaxService(fake)
+	return fake
+}
+
+// Only the product lines that name taxes go to Essential, after discount and in the order's
+// currency; the rest keep the tax their client stated.
+func TestOnlyLinesNamingTaxesAreTaxedByEssential(t *testing.T) {
+	fake := withFakeTaxes(t)
+
+	named := productLine("4", "25", "10", "999")
+	named[models.PurchaseOrderLineFieldTaxIds] = []any{"01VAT10"}
+	section := dmodel.DynamicFields{
+		models.PurchaseOrderLineFieldLineType: string(models.PurchaseOrderLineTypeSection),
+		models.PurchaseOrderLineFieldTaxIds:   []string{"01VAT10"},
+	}
+	stated := productLine("1", "50", "0", "5")
+	order := dmodel.DynamicFields{models.PurchaseOrderFieldCurrencyId: "01USD"}
+
+	totals, err := computeNamedTaxes(nil, order, []dmodel.DynamicFields{named, section, stated})
+
+	require.NoError(t, err)
+	require.Len(t, fake.computed, 1)
+	assert.Equal(t, "01USD", fake.computed[0].CurrencyId)
+	require.Len(t, fake.computed[0].Lines, 1)
+	assert.True(t, fake.computed[0].Lines[0].Amount.Equal(dec("90")))
+	assert.Equal(t, []string{"01VAT10"}, fake.computed[0].Lines[0].TaxIds)
+
+	require.Len(t, totals, 1)
+	assert.True(t, totals[0].Tax.Equal(dec("9")), "the stated 999 is overwritten by the computed tax")
+	assert.True(t, totals[0].Total.Equal(dec("99")))
+}
+
+// An order with no line naming a tax never calls Essential, so it totals as it always did.
+func TestAnOrderNamingNoTaxesIsNotSentToEssential(t *testing.T) {
+	fake := withFakeTaxes(t)
+
+	totals, err := computeNamedTaxes(nil, dmodel.DynamicFields{},
+		[]dmodel.DynamicFields{productLine("1", "50", "0", "5")})
+
+	require.NoError(t, err)
+	assert.Empty(t, totals)
+	assert.Empty(t, fake.computed)
+}
+
+// A line's taxes are checked under tax_ids, and a refusal from Essential is the line's refusal.
+func TestALineNamingAnUnusableTaxIsRefused(t *testing.T) {
+	fake := withFakeTaxes(t)
+	fake.violations = ft.ClientErrors{{Field: models.PurchaseOrderLineFieldTaxIds, Key: "tax.not_active"}}
+
+	line := productLine("1", "50", "0", "0")
+	line[models.PurchaseOrderLineFieldTaxIds] = []string{"01OLDVAT"}
+	vErrs := ft.NewClientErrors()
+
+	require.NoError(t, assertLineTaxesUsable(nil, line, vErrs))
+
+	require.Len(t, fake.asserted, 1)
+	assert.Equal(t, models.PurchaseOrderLineFieldTaxIds, fake.asserted[0].Field)
+	assert.Equal(t, 1, vErrs.Count())
+}
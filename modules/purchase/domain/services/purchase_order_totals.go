@@ -10,6 +10,7 @@ import (
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 
 	"github.com/sky-as-code/nikki-erp/modules/purchase/domain/models"
+	itExt "github.com/sky-as-code/nikki-erp/modules/purchase/interfaces/external"
 )
 
 // The money. PUR-R4 and D8: every line's subtotal, tax_amount and total are stored, and so are the
@@ -53,6 +54,17 @@ func scaleForOrder(ctx corectx.Context, order dmodel.DynamicFields) int32 {
 	return orderScaleResolver(ctx, stringOf(order, models.PurchaseOrderFieldCurrencyId))
 }
 
+// orderTaxService computes the tax of lines that name Essential taxes.
+//
+// It is a hook for the reason orderScaleResolver is. Unset, a line that names taxes cannot be
+// written, and totalling leaves every line at its stated tax as it did before taxes could be named.
+var orderTaxService itExt.TaxExtService
+
+// SetOrderTaxService installs Essential's tax computation. Called once during Init.
+func SetOrderTaxService(taxes itExt.TaxExtService) {
+	orderTaxService = taxes
+}
+
 // OrderTotals is the money summary of one order, computed from its lines.
 type OrderTotals struct {
 	Untaxed decimal.Decimal
@@ -71,10 +83,11 @@ type LineTotals struct {
 //
 // subtotal = quantity x unit_price, less discount_percent. total = subtotal + tax_amount.
 //
-// tax_amount is an INPUT here, not a calculation (D9). There is no tax engine in this module and
-// §55.15 forbids building one, so the client supplies a line's tax and the server sums it. That is
-// why tax_amount is writable on the line while subtotal and total are not: one is a number only
-// the caller knows, and the other two are arithmetic the caller must not be able to contradict.
+// tax_amount is an INPUT here, not a calculation (D9). This module builds no tax engine (§55.15), so
+// a line that names no taxes has its tax supplied by the client and the server sums it. That is why
+// tax_amount is writable on the line while subtotal and total are not: one is a number only the
+// caller knows, and the other two are arithmetic the caller must not be able to contradict. A line
+// that names taxes is priced by Essential instead, in computeNamedTaxes.
 //
 // A non-product line — a section, a subsection, a note — contributes nothing. It exists to organise
 // the printed order, and giving it a quantity and a price would let a heading carry money.
@@ -171,22 +184,78 @@ func RecomputeOrderTotals(ctx corectx.Context, orderId string) error {
 		return err
 	}
 
-	lines, err = rewriteStaleLines(ctx, lineEngine, lines, scaleForOrder(ctx, found.Data))
+	named, err := computeNamedTaxes(ctx, found.Data, lines)
+	if err != nil {
+		return err
+	}
+	lines, err = rewriteStaleLines(ctx, lineEngine, lines, scaleForOrder(ctx, found.Data), named)
 	if err != nil {
 		return err
 	}
 	return rewriteStaleHeader(ctx, orderEngine, found.Data, lines)
 }
 
+// computeNamedTaxes prices, in one call to Essential, every money-bearing line that names taxes.
+// The result is keyed by the line's index; a line missing from it is totalled by ComputeLineTotals.
+//
+// Essential is handed the line after discount, which is what the tax is charged on. Its untaxed
+// amount becomes the line's subtotal, so a price-included tax comes out of the subtotal rather than
+// being added on top of it.
+func computeNamedTaxes(
+	ctx corectx.Context, order dmodel.DynamicFields, lines []dmodel.DynamicFields,
+) (map[int]LineTotals, error) {
+	indexes := []int{}
+	taxable := []itExt.TaxableLine{}
+	for index, line := range lines {
+		taxIds := models.TaxIdsOf(line)
+		if len(taxIds) == 0 || !isMoneyBearingLine(line) {
+			continue
+		}
+		quantity := decimalOf(line, models.PurchaseOrderLineFieldQuantity)
+		unitPrice := decimalOf(line, models.PurchaseOrderLineFieldUnitPrice)
+		discount := decimalOf(line, models.PurchaseOrderLineFieldDiscountPercent)
+		kept := decimal.NewFromInt(100).Sub(discount).Div(decimal.NewFromInt(100))
+
+		indexes = append(indexes, index)
+		taxable = append(taxable, itExt.TaxableLine{Amount: quantity.Mul(unitPrice).Mul(kept), TaxIds: taxIds})
+	}
+	if len(taxable) == 0 || orderTaxService == nil {
+		return nil, nil
+	}
+
+	result, err := orderTaxService.ComputeTaxes(ctx, itExt.ComputeTaxesQuery{
+		CurrencyId: stringOf(order, models.PurchaseOrderFieldCurrencyId),
+		Lines:      taxable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "computeNamedTaxes")
+	}
+	if result.ClientErrors.Count() > 0 {
+		// The taxes were checked when the lines were written, so one that no longer resolves was
+		// deleted from under them. That is a data fault, not something this request can correct.
+		return nil, errors.Errorf("computeNamedTaxes: %v", result.ClientErrors.ToError())
+	}
+
+	named := make(map[int]LineTotals, len(indexes))
+	for position, index := range indexes {
+		taxed := result.Data.Lines[position]
+		named[index] = LineTotals{Subtotal: taxed.Untaxed, Tax: taxed.Tax, Total: taxed.Total}
+	}
+	return named, nil
+}
+
 // rewriteStaleLines stores each line's computed totals where they differ from what is there, and
 // returns the lines with the computed values in place so the header sums the new numbers rather
-// than the ones it just replaced.
+// than the ones it just replaced. named holds the lines Essential priced, by index.
 func rewriteStaleLines(
 	ctx corectx.Context, lineEngine drif.DynamicResourceEngine, lines []dmodel.DynamicFields,
-	scale int32,
+	scale int32, named map[int]LineTotals,
 ) ([]dmodel.DynamicFields, error) {
-	for _, line := range lines {
-		computed := ComputeLineTotals(line, scale)
+	for index, line := range lines {
+		computed, ok := named[index]
+		if !ok {
+			computed = ComputeLineTotals(line, scale)
+		}
 
 		stale := !decimalOf(line, models.PurchaseOrderLineFieldSubtotal).Equal(computed.Subtotal) ||
 			!decimalOf(line, models.PurchaseOrderLineFieldTaxAmount).Equal(computed.Tax) ||
@@ -35,6 +35,17 @@ func InitDomainServices() error {
 	// Totals round to the order's own currency from here on, instead of a fixed two places.
 	services.SetOrderScaleResolver(references.ScaleFor)
 
+	// And lines that name taxes are taxed by Essential rather than taking the client's word.
+	taxes, err := resolveTaxService()
+	if err != nil {
+		return err
+	}
+	services.SetOrderTaxService(taxes)
+
+	if orderPrinter, err = resolveOrderPrinter(); err != nil {
+		return err
+	}
+
 	if err := installDerivedService(models.PurchaseOrderSchemaName,
 		func(base drif.DynamicResourceService) drif.DynamicResourceService {
 			return services.NewPurchaseOrderDomainService(base, references)
@@ -93,6 +104,47 @@ func resolveOrderReferenceValidator() (*services.OrderReferenceValidator, error)
 	return services.NewOrderReferenceValidator(vendors, currencies), nil
 }
 
+// resolveTaxService pulls the tax port out of the container. A missing one fails Init rather than
+// leaving every line that names a tax unwritable with nothing to say why.
+func resolveTaxService() (itExt.TaxExtService, error) {
+	var taxes itExt.TaxExtService
+	if err := deps.Invoke(func(svc itExt.TaxExtService) { taxes = svc }); err != nil {
+		return nil, stdErr.Join(
+			errors.New("the tax port is not registered; purchase/infra/external must bind it"), err)
+	}
+	return taxes, nil
+}
+
+// orderPrinter is the service download_pdf delegates to. It is built here rather than in the action
+// because it needs four ports, and the action has only its ProcessInput.
+var orderPrinter *services.OrderPrinter
+
+// resolveOrderPrinter pulls the ports printing needs out of the container.
+func resolveOrderPrinter() (*services.OrderPrinter, error) {
+	var parties itExt.PartyExtService
+	var documents itExt.DocumentExtService
+	var currencies itExt.CurrencyExtService
+	var uoms itExt.UomExtService
+
+	if err := deps.Invoke(func(svc itExt.PartyExtService) { parties = svc }); err != nil {
+		return nil, stdErr.Join(
+			errors.New("the party port is not registered; purchase/infra/external must bind it"), err)
+	}
+	if err := deps.Invoke(func(svc itExt.DocumentExtService) { documents = svc }); err != nil {
+		return nil, stdErr.Join(
+			errors.New("the document port is not registered; purchase/infra/external must bind it"), err)
+	}
+	if err := deps.Invoke(func(svc itExt.CurrencyExtService) { currencies = svc }); err != nil {
+		return nil, stdErr.Join(
+			errors.New("the currency port is not registered; purchase/infra/external must bind it"), err)
+	}
+	if err := deps.Invoke(func(svc itExt.UomExtService) { uoms = svc }); err != nil {
+		return nil, stdErr.Join(
+			errors.New("the UoM port is not registered; purchase/infra/external must bind it"), err)
+	}
+	return services.NewOrderPrinter(parties, documents, currencies, uoms), nil
+}
+
 func installDerivedService(
 	schemaName string, derive func(drif.DynamicResourceService) drif.DynamicResourceService,
 ) error {
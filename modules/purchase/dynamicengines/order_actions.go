@@ -51,6 +51,7 @@ const (
 	ActionMerge               = "merge"
 	ActionCreateAlternative   = "create_alternative"
 	ActionCompareAlternatives = "compare_alternatives"
+	ActionDownloadPdf         = "download_pdf"
 )
 
 // Param names the order actions read from the request.
@@ -155,6 +156,15 @@ func defineOrderActions(engine drif.DynamicResourceEngine) error {
 			Permission:  drif.PermissionCreate,
 			MainProcess: processOrderDuplicate,
 		}),
+		// Printing shows what the caller can already read, so it carries read rather than a print
+		// permission of its own.
+		engine.DefineAction(drif.DynamicActionDefinition{
+			ActionName:  ActionDownloadPdf,
+			ActionType:  drif.ActionTypeRead,
+			RestPath:    ":id/download_pdf",
+			Permission:  drif.PermissionRead,
+			MainProcess: processOrderDownloadPdf,
+		}),
 	)
 }
 
@@ -280,6 +290,28 @@ func processOrderCompareAlternatives(ctx corectx.Context, input drif.ProcessInpu
 	return &drif.ActionResult{Data: comparison, HasData: true}, nil
 }
 
+func processOrderDownloadPdf(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	if orderPrinter == nil {
+		return nil, errors.New(
+			"the purchase order printer was not installed; dynamicengines.InitDomainServices must build it")
+	}
+	result, err := orderPrinter.RenderPdf(ctx, readOrderId(input))
+	if err != nil {
+		return nil, err
+	}
+	if !result.HasData {
+		return &drif.ActionResult{ClientErrors: result.ClientErrors}, nil
+	}
+	return &drif.ActionResult{
+		HasData: true,
+		Data: drif.FileResultData{
+			FileName:    result.Data.FileName,
+			ContentType: "application/pdf",
+			Content:     result.Data.Content,
+		},
+	}, nil
+}
+
 // readStringList reads a list of ids from the request body.
 //
 // A malformed list is read as empty rather than guessed at, and the merge then refuses for needing
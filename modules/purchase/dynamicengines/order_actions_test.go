@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 )
 
@@ -141,3 +143,19 @@ func TestSeededAgreementActionsAreDemanded(t *testing.T) {
 			"0007002_purchase_iam.sql seeds the %q action, which no engine action demands", code)
 	}
 }
+
+// Printing carries read and not a permission of its own, which is why the seed above must not have
+// a print row; and it is a GET, so a browser can open the link directly.
+func TestDownloadPdfIsAReadOfTheOrder(t *testing.T) {
+	schema := dmodel.DefineModel("purchase_order").Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+	require.NoError(t, defineOrderActions(testEngine))
+
+	definition, exists := testEngine.Action(ActionDownloadPdf)
+	require.True(t, exists)
+
+	assert.Equal(t, drif.PermissionRead, definition.Permission)
+	assert.Equal(t, "GET", definition.ActionType.HttpMethod())
+	assert.Equal(t, ":id/download_pdf", definition.RestPath)
+}
@@ -141,6 +141,7 @@ func purchaseOrderLineEngineSpec() engineSpec {
 			models.PurchaseOrderLineFieldUomId,
 			models.PurchaseOrderLineFieldUnitPrice,
 			models.PurchaseOrderLineFieldSubtotal,
+			models.PurchaseOrderLineFieldTaxIds,
 			models.PurchaseOrderLineFieldTaxAmount,
 			models.PurchaseOrderLineFieldTotal,
 		},
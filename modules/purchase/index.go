@@ -41,14 +41,16 @@ func (*PurchaseModule) Name() string {
 //
 // dynamicresource hosts the resource engines. essential supplies UoM conversion and currency,
 // inventory the product variant and its purchase_ok flag, contacts the vendor party and its vendor
-// profile. The last three were already declared before this module was rebuilt, but nothing
-// realized them — there was no interfaces/external and no infra/external at all.
+// profile, document the PDF renderer orders print through. The middle three were already declared
+// before this module was rebuilt, but nothing realized them — there was no interfaces/external and
+// no infra/external at all.
 func (*PurchaseModule) Deps() []string {
 	return []string{
 		"dynamicresource",
 		"essential",
 		"inventory",
 		"contacts",
+		"document",
 	}
 }
 
@@ -13,13 +13,16 @@ import (
 	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
 	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
 	"github.com/sky-as-code/nikki-erp/common/model"
+	contactsModels "github.com/sky-as-code/nikki-erp/modules/contacts/domain/models"
 	itVendor "github.com/sky-as-code/nikki-erp/modules/contacts/interfaces/vendor"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
 	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
 	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
 	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
 	itCurrency "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/currency"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
 	itUom "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/uom"
 	invModels "github.com/sky-as-code/nikki-erp/modules/inventory/domain/models"
 	itProduct "github.com/sky-as-code/nikki-erp/modules/inventory/interfaces/product"
@@ -48,9 +51,65 @@ func InitExternal() error {
 		deps.Register(func(currencySvc itCurrency.CurrencyAppService) itExt.CurrencyExtService {
 			return currencySvc
 		}),
+		deps.Register(func(taxSvc itTax.TaxAppService) itExt.TaxExtService {
+			return taxSvc
+		}),
+		deps.Register(func() itExt.PartyExtService {
+			return &partyAdapter{}
+		}),
+		deps.Register(func(renderSvc itRendering.DocumentRenderAppService) itExt.DocumentExtService {
+			return renderSvc
+		}),
 	)
 }
 
+// partyAdapter reads a party's printable identity straight from the contacts_party engine.
+//
+// Contacts publishes no party port — its vendor service answers questions about terms, not about
+// names — so this reads the resource the way inventoryUomOf reads stock configuration, from the one
+// package allowed to.
+type partyAdapter struct{}
+
+var _ itExt.PartyExtService = (*partyAdapter)(nil)
+
+func (this *partyAdapter) GetParty(
+	ctx corectx.Context, query itExt.GetPartyQuery,
+) (*itExt.GetPartyResult, error) {
+	if query.PartyId == "" {
+		return &itExt.GetPartyResult{}, nil
+	}
+
+	engine, ok := engineFor(contactsModels.PartySchemaName)
+	if !ok {
+		return nil, errors.Errorf("the '%s' engine is not registered", contactsModels.PartySchemaName)
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{
+		contactsModels.PartyFieldId: query.PartyId,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "GetParty")
+	}
+	if found == nil || !found.HasData {
+		return &itExt.GetPartyResult{}, nil
+	}
+
+	party := contactsModels.NewPartyFrom(found.Data)
+	name := derefString(party.GetLegalName())
+	if name == "" {
+		name = derefString(party.GetDisplayName())
+	}
+	return &itExt.GetPartyResult{
+		HasData: true,
+		Data: itExt.GetPartyResultData{
+			PartyId: query.PartyId,
+			Name:    name,
+			TaxId:   derefString(party.GetTaxId()),
+			Address: derefString(party.GetLegalAddress()),
+		},
+	}, nil
+}
+
 // productAdapter narrows Inventory's variant service to the two questions a purchase line asks.
 //
 // It is a real adapter rather than a hand-over because no single Inventory service answers both:
@@ -157,6 +216,13 @@ func derefId(value *model.Id) model.Id {
 	return *value
 }
 
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
 func derefBool(value *bool) bool {
 	return value != nil && *value
 }
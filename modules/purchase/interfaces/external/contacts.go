@@ -1,6 +1,7 @@
 package external
 
 import (
+	"github.com/sky-as-code/nikki-erp/common/model"
 	itVendor "github.com/sky-as-code/nikki-erp/modules/contacts/interfaces/vendor"
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
 	itCurrency "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/currency"
@@ -21,6 +22,40 @@ type VendorExtService interface {
 	AssertOrderable(ctx corectx.Context, query AssertOrderableQuery) (*AssertOrderableResult, error)
 }
 
+// PartyExtService is Purchase's port onto the vendor's party record, for what a printed order says
+// about who it is addressed to.
+//
+// The vendor port answers whether a party may be ordered from and on what terms; it says nothing
+// of the party's name or address, which Contacts publishes no port for. This one reads exactly those
+// and nothing else.
+type PartyExtService interface {
+	// GetParty reads one party's printable identity. HasData false means it does not exist.
+	GetParty(ctx corectx.Context, query GetPartyQuery) (*GetPartyResult, error)
+}
+
+// GetPartyQuery names the party to read.
+type GetPartyQuery struct {
+	PartyId model.Id
+}
+
+// GetPartyResultData is what a document addressed to a party prints of it.
+type GetPartyResultData struct {
+	PartyId model.Id
+
+	// Name is the legal name where the party has one — it is what belongs on a commercial
+	// document — and the display name otherwise.
+	Name    string
+	TaxId   string
+	Address string
+}
+
+// GetPartyResult carries the data alongside HasData, in the shape every other port in this
+// codebase uses.
+type GetPartyResult struct {
+	Data    GetPartyResultData
+	HasData bool
+}
+
 type GetVendorQuery = itVendor.GetVendorQuery
 type GetVendorResult = itVendor.GetVendorResult
 type GetVendorResultData = itVendor.GetVendorResultData
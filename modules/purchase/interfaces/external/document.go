@@ -0,0 +1,35 @@
+package external
+
+import (
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itRendering "github.com/sky-as-code/nikki-erp/modules/document/interfaces/rendering"
+)
+
+// DocumentExtService is Purchase's port onto Document's rendering capability.
+//
+// The order describes itself — title, vendor, lines, totals — and Document lays it out with the
+// organization's template. Neither module learns the other's records.
+type DocumentExtService interface {
+	RenderPdf(ctx corectx.Context, query RenderPdfQuery) (*RenderPdfResult, error)
+}
+
+type RenderPdfQuery = itRendering.RenderPdfQuery
+type RenderPdfResult = itRendering.RenderPdfResult
+type Document = itRendering.Document
+type Fact = itRendering.Fact
+type Partner = itRendering.Partner
+type Currency = itRendering.Currency
+type Line = itRendering.Line
+type LineKind = itRendering.LineKind
+type Total = itRendering.Total
+
+const (
+	DocumentTypePurchaseOrder = itRendering.DocumentTypePurchaseOrder
+
+	LineKindItem       = itRendering.LineKindItem
+	LineKindSection    = itRendering.LineKindSection
+	LineKindSubsection = itRendering.LineKindSubsection
+	LineKindNote       = itRendering.LineKindNote
+)
+
+var FormatDate = itRendering.FormatDate
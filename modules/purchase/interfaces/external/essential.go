@@ -11,6 +11,7 @@ package external
 
 import (
 	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	itTax "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/tax"
 	itUom "github.com/sky-as-code/nikki-erp/modules/essential/interfaces/uom"
 )
 
@@ -33,3 +34,24 @@ type GetUomQuery = itUom.GetUomQuery
 type GetUomResult = itUom.GetUomResult
 type ConvertQuantityQuery = itUom.ConvertQuantityQuery
 type ConvertQuantityResult = itUom.ConvertQuantityResult
+
+// TaxExtService is Purchase's port onto Essential's tax capability.
+//
+// A line that names taxes is taxed by Essential rather than here, for the reason conversion goes
+// through Convert: an order and the invoice billed against it must come to the same tax, and two
+// implementations of price-included or compound arithmetic would not.
+type TaxExtService interface {
+	// AssertUsable refuses taxes a line may not newly name: unknown, withdrawn or archived.
+	AssertUsable(ctx corectx.Context, query AssertTaxesUsableQuery) (*AssertTaxesUsableResult, error)
+
+	// ComputeTaxes works out what an order's lines owe, line by line and by tax.
+	ComputeTaxes(ctx corectx.Context, query ComputeTaxesQuery) (*ComputeTaxesResult, error)
+}
+
+type AssertTaxesUsableQuery = itTax.AssertUsableQuery
+type AssertTaxesUsableResult = itTax.AssertUsableResult
+type ComputeTaxesQuery = itTax.ComputeTaxesQuery
+type ComputeTaxesResult = itTax.ComputeTaxesResult
+type ComputeTaxesResultData = itTax.ComputeTaxesResultData
+type TaxableLine = itTax.TaxableLine
+type TaxedLine = itTax.TaxedLine
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/job"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+)
+
+// DeliverJobPayload names the delivery a job attempts.
+//
+// It carries the id only: the payload, the target and the attempt count are read from the
+// delivery log when the job runs, so a retry sends what the log says and a redelivery is the same
+// job as the first delivery.
+type DeliverJobPayload struct {
+	DeliveryId string `json:"delivery_id"`
+}
+
+var deliverJob = job.NewQueuedJobType[DeliverJobPayload]("webhook.deliver")
+
+// deliverJobMargin is what a delivery job takes beyond the HTTP timeout: reading the delivery and
+// its subscription, and writing the outcome back.
+const deliverJobMargin = 30 * time.Second
+
+// RegisterDeliverJob has the queue's workers attempt deliveries.
+//
+// The job fails, and so is retried after the queue's backoff, for as long as Deliver reports the
+// attempt failed; Deliver stops reporting so on the attempt that dead-letters the delivery, which
+// is the queue's last as well.
+func RegisterDeliverJob(
+	registry job.JobQueueRegistry, deliveries *services.WebhookDeliveryDomainService, sendTimeout time.Duration,
+) {
+	deliverJob.Register(registry, func(ctx context.Context, payload DeliverJobPayload) error {
+		return deliveries.Deliver(corectx.NewRequestContext(ctx), payload.DeliveryId)
+	}, sendTimeout+deliverJobMargin)
+}
+
+// NewDeliveryEnqueuer returns the function the delivery service queues deliveries with.
+//
+// The delivery id is the job's unique key, so a delivery queued twice is attempted by one job.
+// maxAttempts is the delivery policy's, so the queue gives up on the attempt the log dead-letters.
+func NewDeliveryEnqueuer(enqueuer job.JobEnqueuer, maxAttempts int) services.DeliveryEnqueueFn {
+	return func(ctx context.Context, deliveryId string) error {
+		_, err := deliverJob.Enqueue(ctx, enqueuer, DeliverJobPayload{DeliveryId: deliveryId}, job.EnqueueOptions{
+			MaxAttempts: maxAttempts,
+			UniqueKey:   deliveryId,
+		})
+		return err
+	}
+}
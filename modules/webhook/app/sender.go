@@ -0,0 +1,110 @@
+// Package app holds the webhook module's outbound side: the HTTP client that posts deliveries and
+// the queued job that drives their attempts.
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+)
+
+// HttpSender posts deliveries to subscription targets. It implements services.WebhookSender.
+type HttpSender struct {
+	httpClient *http.Client
+}
+
+// NewHttpSender builds the sender with the deployment's per-attempt timeout.
+//
+// A target URL is typed in by a tenant, so unless allowPrivateTargets is set the sender refuses to
+// connect to loopback, private and link-local addresses: otherwise a subscription could have this
+// server probe the network it runs in and report back what answered. The check is made on the
+// address actually dialed, after DNS, so a public name resolving to a private address is refused
+// as well. Redirects are not followed, for the same reason.
+func NewHttpSender(timeout time.Duration, allowPrivateTargets bool) *HttpSender {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateTargets {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &HttpSender{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+var _ services.WebhookSender = (*HttpSender)(nil)
+
+// Send makes one attempt, signed at the time it is made so that the timestamp a receiver checks is
+// that of this attempt rather than of the first.
+func (this *HttpSender) Send(ctx context.Context, req services.SendRequest) services.SendOutcome {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, req.TargetUrl, bytes.NewReader(req.Payload))
+	if err != nil {
+		return services.SendOutcome{Detail: "the target URL is not a usable address"}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(services.HeaderEvent, req.EventName)
+	request.Header.Set(services.HeaderDelivery, req.DeliveryId)
+	request.Header.Set(services.HeaderSignatureTimestamp, timestamp)
+	request.Header.Set(services.HeaderSignature, services.SignPayload(req.Secret, timestamp, req.Payload))
+
+	response, err := this.httpClient.Do(request)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return services.SendOutcome{Detail: "the target resolves to a private address"}
+		}
+		return services.SendOutcome{Detail: "the target could not be reached"}
+	}
+	defer response.Body.Close()
+
+	// The body is drained and discarded so the connection can be reused; the receiver's reply
+	// carries nothing this module acts on.
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+
+	outcome := services.SendOutcome{ResponseStatus: response.StatusCode}
+	if !outcome.Succeeded() {
+		outcome.Detail = "the target answered " + response.Status
+	}
+	return outcome
+}
+
+var errPrivateAddress = errors.New("the address is not a public one")
+
+// refusePrivateAddress is the dialer's Control hook, called with the resolved address of every
+// connection before it is made.
+func refusePrivateAddress(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateAddress(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
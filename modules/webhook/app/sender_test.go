@@ -0,0 +1,72 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+)
+
+func TestTheSenderPostsASignedDelivery(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	payload := []byte(`{"id":"01JEVENT"}`)
+	outcome := NewHttpSender(time.Second, true).Send(t.Context(), services.SendRequest{
+		TargetUrl:  target.URL,
+		Secret:     "s3cret-s3cret-s3cret",
+		DeliveryId: "01JDELIVERY",
+		EventName:  "purchase_order.confirm",
+		Payload:    payload,
+	})
+
+	require.True(t, outcome.Succeeded(), outcome.Detail)
+	assert.Equal(t, http.StatusAccepted, outcome.ResponseStatus)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "purchase_order.confirm", received.Header.Get(services.HeaderEvent))
+	assert.Equal(t, "01JDELIVERY", received.Header.Get(services.HeaderDelivery))
+
+	timestamp := received.Header.Get(services.HeaderSignatureTimestamp)
+	require.NotEmpty(t, timestamp)
+	assert.Equal(t, services.SignPayload("s3cret-s3cret-s3cret", timestamp, payload),
+		received.Header.Get(services.HeaderSignature))
+}
+
+func TestTheSenderReportsARefusal(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	outcome := NewHttpSender(time.Second, true).Send(t.Context(), services.SendRequest{TargetUrl: target.URL})
+
+	assert.False(t, outcome.Succeeded())
+	assert.Equal(t, http.StatusServiceUnavailable, outcome.ResponseStatus)
+	assert.Contains(t, outcome.Detail, "503")
+}
+
+// The test target listens on loopback, which is exactly what a tenant's subscription must not be
+// able to reach unless the deployment allows it.
+func TestTheSenderRefusesPrivateTargets(t *testing.T) {
+	called := false
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer target.Close()
+
+	outcome := NewHttpSender(time.Second, false).Send(t.Context(), services.SendRequest{TargetUrl: target.URL})
+
+	assert.False(t, called)
+	assert.Zero(t, outcome.ResponseStatus)
+	assert.Equal(t, "the target resolves to a private address", outcome.Detail)
+}
@@ -0,0 +1,11 @@
+package constants
+
+// Action codes beyond the engine's built-in CRUD.
+//
+// redeliver is its own permission rather than "update": it changes nothing a person wrote, but it
+// does call out to an integrator's system, which is not something read access should allow.
+// deliveries is a listing and carries "read" on the subscription.
+const (
+	ActionRedeliver  = "redeliver"
+	ActionDeliveries = "deliveries"
+)
@@ -0,0 +1,19 @@
+package constants
+
+import (
+	core "github.com/sky-as-code/nikki-erp/modules/core/constants"
+)
+
+// Configuration keys of the Webhook module.
+//
+// A delivery is retried by the job queue, with the queue's own backoff (CORE.JOB_QUEUE.BACKOFF_*);
+// MAX_ATTEMPTS is how many attempts it gets before it is dead-lettered.
+const (
+	WebhookTimeoutSecs core.ConfigName = "WEBHOOK.TIMEOUT_SECS"
+	WebhookMaxAttempts core.ConfigName = "WEBHOOK.MAX_ATTEMPTS"
+
+	// WebhookAllowPrivateTargets lets a subscription's target resolve to a loopback, private or
+	// link-local address. It is off by default: a subscription is written by a tenant, and one
+	// pointed at this deployment's own network would have the server call it on their behalf.
+	WebhookAllowPrivateTargets core.ConfigName = "WEBHOOK.ALLOW_PRIVATE_TARGETS"
+)
@@ -0,0 +1,3 @@
+package constants
+
+const WebhookModuleName = "webhook"
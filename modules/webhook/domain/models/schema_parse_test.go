@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// The JSON model files are parsed at start-up by RegisterModels; a malformed file panics the whole
+// app rather than failing the build. These tests turn that into a test failure instead.
+
+func requireField(t *testing.T, schema *dmodel.ModelSchema, fieldName string) *dmodel.ModelField {
+	t.Helper()
+	field, ok := schema.Fields()[fieldName]
+	require.Truef(t, ok, "schema %q has no field %q", schema.Name(), fieldName)
+	return field
+}
+
+func TestTheSchemasParseUnderTheirConstantNames(t *testing.T) {
+	// Normally done by CoreModule.RegisterModels during app start-up.
+	_ = basemodel.RegisterJsonBaseSchemas()
+
+	subscription := WebhookSubscriptionSchemaBuilder().Build()
+	assert.Equal(t, WebhookSubscriptionSchemaName, subscription.Name())
+	assert.Equal(t, "webhook_subscriptions", subscription.TableName())
+
+	delivery := WebhookDeliverySchemaBuilder().Build()
+	assert.Equal(t, WebhookDeliverySchemaName, delivery.Name())
+	assert.Equal(t, "webhook_deliveries", delivery.TableName())
+}
+
+// The secret is what a receiver tells a genuine delivery by, so it is typed secret: kept out of
+// the change history, and never listed back.
+func TestTheSubscriptionSecretIsASecret(t *testing.T) {
+	_ = basemodel.RegisterJsonBaseSchemas()
+	schema := WebhookSubscriptionSchemaBuilder().Build()
+
+	secret := requireField(t, schema, WebhookSubscriptionFieldSecret)
+	assert.Equal(t, dmodel.FieldDataTypeNameSecret, secret.DataType().String())
+	assert.True(t, requireField(t, schema, WebhookSubscriptionFieldActionNames).IsArray())
+}
+
+// A delivery is written by the event it records and by its attempts alone. A client able to edit
+// one could make the log say a target was told something it never was.
+func TestTheDeliveryLogCannotBeEditedByAClient(t *testing.T) {
+	_ = basemodel.RegisterJsonBaseSchemas()
+	schema := WebhookDeliverySchemaBuilder().Build()
+
+	for _, name := range []string{
+		WebhookDeliveryFieldPayload,
+		WebhookDeliveryFieldStatus,
+		WebhookDeliveryFieldAttempts,
+		WebhookDeliveryFieldDeliveredAt,
+	} {
+		assert.Truef(t, requireField(t, schema, name).IsNoUpdate(), "%s must be no_update", name)
+	}
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	_ "embed"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+const (
+	WebhookDeliverySchemaName = "webhook_delivery"
+
+	WebhookDeliveryFieldId             = basemodel.FieldId
+	WebhookDeliveryFieldSubscriptionId = "subscription_id"
+	WebhookDeliveryFieldEventId        = "event_id"
+	WebhookDeliveryFieldEventName      = "event_name"
+	WebhookDeliveryFieldRecordId       = "record_id"
+	WebhookDeliveryFieldPayload        = "payload"
+	WebhookDeliveryFieldStatus         = "status"
+	WebhookDeliveryFieldAttempts       = "attempts"
+	WebhookDeliveryFieldResponseStatus = "response_status"
+	WebhookDeliveryFieldLastAttemptAt  = "last_attempt_at"
+	WebhookDeliveryFieldDeliveredAt    = "delivered_at"
+	WebhookDeliveryFieldLastDetail     = "last_detail"
+	WebhookDeliveryFieldRedeliveryOf   = "redelivery_of"
+	WebhookDeliveryFieldOrgId          = "org_id"
+)
+
+const (
+	WebhookDeliveryStatusPending      = "pending"
+	WebhookDeliveryStatusDelivered    = "delivered"
+	WebhookDeliveryStatusFailed       = "failed"
+	WebhookDeliveryStatusDeadLettered = "dead_lettered"
+)
+
+//go:embed webhook_delivery.json
+var webhookDeliverySchemaJson string
+
+func WebhookDeliverySchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(webhookDeliverySchemaJson)
+}
+
+// WebhookDelivery is one event posted to one subscription's target, with every attempt to post it.
+// Table: webhook_deliveries.
+//
+// It is the subscription's delivery log: what an integrator's complaint is checked against, and
+// what a redelivery sends again.
+type WebhookDelivery struct {
+	basemodel.DynamicModelBase
+}
+
+func NewWebhookDelivery() *WebhookDelivery {
+	return &WebhookDelivery{basemodel.NewDynamicModel()}
+}
+
+func NewWebhookDeliveryFrom(src dmodel.DynamicFields) *WebhookDelivery {
+	return &WebhookDelivery{basemodel.NewDynamicModel(src)}
+}
+
+func (this WebhookDelivery) GetSubscriptionId() *model.Id {
+	return this.GetFieldData().GetModelId(WebhookDeliveryFieldSubscriptionId)
+}
+
+func (this *WebhookDelivery) SetSubscriptionId(v *model.Id) {
+	this.GetFieldData().SetModelId(WebhookDeliveryFieldSubscriptionId, v)
+}
+
+func (this WebhookDelivery) GetEventId() *model.Id {
+	return this.GetFieldData().GetModelId(WebhookDeliveryFieldEventId)
+}
+
+func (this *WebhookDelivery) SetEventId(v *model.Id) {
+	this.GetFieldData().SetModelId(WebhookDeliveryFieldEventId, v)
+}
+
+func (this WebhookDelivery) GetEventName() *string {
+	return this.GetFieldData().GetString(WebhookDeliveryFieldEventName)
+}
+
+func (this *WebhookDelivery) SetEventName(v *string) {
+	this.GetFieldData().SetString(WebhookDeliveryFieldEventName, v)
+}
+
+func (this WebhookDelivery) GetRecordId() *string {
+	return this.GetFieldData().GetString(WebhookDeliveryFieldRecordId)
+}
+
+func (this *WebhookDelivery) SetRecordId(v *string) {
+	this.GetFieldData().SetString(WebhookDeliveryFieldRecordId, v)
+}
+
+func (this WebhookDelivery) GetPayload() *string {
+	return this.GetFieldData().GetString(WebhookDeliveryFieldPayload)
+}
+
+func (this *WebhookDelivery) SetPayload(v *string) {
+	this.GetFieldData().SetString(WebhookDeliveryFieldPayload, v)
+}
+
+func (this WebhookDelivery) GetStatus() *string {
+	return this.GetFieldData().GetString(WebhookDeliveryFieldStatus)
+}
+
+func (this *WebhookDelivery) SetStatus(v *string) {
+	this.GetFieldData().SetString(WebhookDeliveryFieldStatus, v)
+}
+
+func (this WebhookDelivery) GetAttempts() *int32 {
+	return this.GetFieldData().GetInt32(WebhookDeliveryFieldAttempts)
+}
+
+func (this *WebhookDelivery) SetAttempts(v *int32) {
+	this.GetFieldData().SetInt32(WebhookDeliveryFieldAttempts, v)
+}
+
+func (this WebhookDelivery) GetResponseStatus() *int32 {
+	return this.GetFieldData().GetInt32(WebhookDeliveryFieldResponseStatus)
+}
+
+func (this *WebhookDelivery) SetResponseStatus(v *int32) {
+	this.GetFieldData().SetInt32(WebhookDeliveryFieldResponseStatus, v)
+}
+
+func (this WebhookDelivery) GetLastAttemptAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(WebhookDeliveryFieldLastAttemptAt)
+}
+
+func (this *WebhookDelivery) SetLastAttemptAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(WebhookDeliveryFieldLastAttemptAt, v)
+}
+
+func (this WebhookDelivery) GetDeliveredAt() *model.ModelDateTime {
+	return this.GetFieldData().GetModelDateTime(WebhookDeliveryFieldDeliveredAt)
+}
+
+func (this *WebhookDelivery) SetDeliveredAt(v *model.ModelDateTime) {
+	this.GetFieldData().SetModelDateTime(WebhookDeliveryFieldDeliveredAt, v)
+}
+
+func (this WebhookDelivery) GetLastDetail() *string {
+	return this.GetFieldData().GetString(WebhookDeliveryFieldLastDetail)
+}
+
+func (this *WebhookDelivery) SetLastDetail(v *string) {
+	this.GetFieldData().SetString(WebhookDeliveryFieldLastDetail, v)
+}
+
+func (this WebhookDelivery) GetRedeliveryOf() *model.Id {
+	return this.GetFieldData().GetModelId(WebhookDeliveryFieldRedeliveryOf)
+}
+
+func (this *WebhookDelivery) SetRedeliveryOf(v *model.Id) {
+	this.GetFieldData().SetModelId(WebhookDeliveryFieldRedeliveryOf, v)
+}
+
+func (this WebhookDelivery) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(WebhookDeliveryFieldOrgId)
+}
+
+func (this *WebhookDelivery) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(WebhookDeliveryFieldOrgId, v)
+}
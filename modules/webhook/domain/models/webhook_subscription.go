@@ -0,0 +1,114 @@
+package models
+
+import (
+	_ "embed"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/model"
+
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+)
+
+// WebhookSubscription is one organization's wish to be told of some actions of one resource: where
+// to post them, and the secret to sign them with. Table: webhook_subscriptions.
+const (
+	WebhookSubscriptionSchemaName = "webhook_subscription"
+
+	WebhookSubscriptionFieldId           = basemodel.FieldId
+	WebhookSubscriptionFieldOrgId        = basemodel.FieldOrgId
+	WebhookSubscriptionFieldTargetUrl    = "target_url"
+	WebhookSubscriptionFieldResourceName = "resource_name"
+	WebhookSubscriptionFieldActionNames  = "action_names"
+	WebhookSubscriptionFieldSecret       = "secret"
+	WebhookSubscriptionFieldIsActive     = "is_active"
+	WebhookSubscriptionFieldDescription  = "description"
+)
+
+//go:embed webhook_subscription.json
+var webhookSubscriptionSchemaJson string
+
+func WebhookSubscriptionSchemaBuilder() *dmodel.ModelSchemaBuilder {
+	return dmodel.ParseModelJson(webhookSubscriptionSchemaJson)
+}
+
+type WebhookSubscription struct {
+	basemodel.DynamicModelBase
+}
+
+func NewWebhookSubscription() *WebhookSubscription {
+	return &WebhookSubscription{basemodel.NewDynamicModel()}
+}
+
+func NewWebhookSubscriptionFrom(src dmodel.DynamicFields) *WebhookSubscription {
+	return &WebhookSubscription{basemodel.NewDynamicModel(src)}
+}
+
+func (this WebhookSubscription) GetOrgId() *model.Id {
+	return this.GetFieldData().GetModelId(WebhookSubscriptionFieldOrgId)
+}
+
+func (this *WebhookSubscription) SetOrgId(v *model.Id) {
+	this.GetFieldData().SetModelId(WebhookSubscriptionFieldOrgId, v)
+}
+
+func (this WebhookSubscription) GetTargetUrl() *string {
+	return this.GetFieldData().GetString(WebhookSubscriptionFieldTargetUrl)
+}
+
+func (this *WebhookSubscription) SetTargetUrl(v *string) {
+	this.GetFieldData().SetString(WebhookSubscriptionFieldTargetUrl, v)
+}
+
+func (this WebhookSubscription) GetResourceName() *string {
+	return this.GetFieldData().GetString(WebhookSubscriptionFieldResourceName)
+}
+
+func (this *WebhookSubscription) SetResourceName(v *string) {
+	this.GetFieldData().SetString(WebhookSubscriptionFieldResourceName, v)
+}
+
+// GetActionNames returns the actions reported. A request body decodes the list as []any and a row
+// read back as []string, so both are accepted; an entry that is not a string is skipped.
+func (this WebhookSubscription) GetActionNames() []string {
+	switch typed := this.GetFieldData().GetAny(WebhookSubscriptionFieldActionNames).(type) {
+	case []string:
+		return typed
+	case []any:
+		names := make([]string, 0, len(typed))
+		for _, item := range typed {
+			if name, ok := item.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func (this *WebhookSubscription) SetActionNames(v []string) {
+	this.GetFieldData().SetStrings(WebhookSubscriptionFieldActionNames, v)
+}
+
+func (this WebhookSubscription) GetSecret() *string {
+	return this.GetFieldData().GetString(WebhookSubscriptionFieldSecret)
+}
+
+func (this *WebhookSubscription) SetSecret(v *string) {
+	this.GetFieldData().SetString(WebhookSubscriptionFieldSecret, v)
+}
+
+func (this WebhookSubscription) GetIsActive() *bool {
+	return this.GetFieldData().GetBool(WebhookSubscriptionFieldIsActive)
+}
+
+func (this *WebhookSubscription) SetIsActive(v *bool) {
+	this.GetFieldData().SetBool(WebhookSubscriptionFieldIsActive, v)
+}
+
+func (this WebhookSubscription) GetDescription() *string {
+	return this.GetFieldData().GetString(WebhookSubscriptionFieldDescription)
+}
+
+func (this *WebhookSubscription) SetDescription(v *string) {
+	this.GetFieldData().SetString(WebhookSubscriptionFieldDescription, v)
+}
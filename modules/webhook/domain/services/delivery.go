@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	stdErr "errors"
+	"time"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	"github.com/sky-as-code/nikki-erp/common/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel/basemodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+// WebhookSender posts one delivery to its target.
+//
+// The app layer's HTTP sender implements it: how a request is signed and which addresses may be
+// called is transport, while when a delivery is given up on is recorded here.
+type WebhookSender interface {
+	Send(ctx context.Context, request SendRequest) SendOutcome
+}
+
+// SendRequest is what the sender needs to post one delivery.
+type SendRequest struct {
+	TargetUrl  string
+	Secret     string
+	DeliveryId string
+	EventName  string
+	Payload    []byte
+}
+
+// SendOutcome is what came of one attempt.
+type SendOutcome struct {
+	// ResponseStatus is the HTTP status the target answered, or 0 when it was not reached.
+	ResponseStatus int
+
+	// Detail explains a failure. It is read by a person deciding whether to redeliver.
+	Detail string
+}
+
+func (this SendOutcome) Succeeded() bool {
+	return this.ResponseStatus >= 200 && this.ResponseStatus < 300
+}
+
+// DeliveryEnqueueFn puts a recorded delivery on the job queue, which makes its attempts.
+type DeliveryEnqueueFn func(ctx context.Context, deliveryId string) error
+
+// DeliveryPolicy bounds how long a failing delivery is retried. The wait between attempts is the
+// job queue's backoff.
+type DeliveryPolicy struct {
+	// MaxAttempts is the number of attempts after which a delivery is dead-lettered.
+	MaxAttempts int
+}
+
+// WebhookDeliveryDomainService records each event posted to a subscription and the attempts to
+// post it.
+type WebhookDeliveryDomainService struct {
+	sender  WebhookSender
+	enqueue DeliveryEnqueueFn
+	policy  DeliveryPolicy
+
+	// now is injected so the log can be tested against a fixed clock.
+	now func() time.Time
+}
+
+func NewWebhookDeliveryDomainService(
+	sender WebhookSender, enqueue DeliveryEnqueueFn, policy DeliveryPolicy,
+) *WebhookDeliveryDomainService {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &WebhookDeliveryDomainService{sender: sender, enqueue: enqueue, policy: policy, now: time.Now}
+}
+
+// Dispatch records a delivery of the event for every active subscription of its organization that
+// asked for it, and queues each. It reports how many it recorded.
+//
+// The deliveries are written before anything is sent, so an event is in the log even when the
+// queue is down; and the payload is fixed now, so that a retry days later still says what the
+// event said. One subscription's failure does not keep the event from the others.
+func (this *WebhookDeliveryDomainService) Dispatch(ctx corectx.Context, event drif.ActionEvent) (int, error) {
+	subscriptions, err := findSubscriptionsFor(ctx, event.OrgId, event.ResourceName, event.ActionName)
+	if err != nil || len(subscriptions) == 0 {
+		return 0, err
+	}
+
+	payload, err := EncodeEventPayload(event)
+	if err != nil {
+		return 0, errors.Wrap(err, "Dispatch")
+	}
+
+	recorded := 0
+	var errs []error
+	for _, subscription := range subscriptions {
+		_, err := this.record(ctx, dmodel.DynamicFields{
+			models.WebhookDeliveryFieldSubscriptionId: derefString(subscription.GetId()),
+			models.WebhookDeliveryFieldEventId:        event.Id,
+			models.WebhookDeliveryFieldEventName:      EventName(event.ResourceName, event.ActionName),
+			models.WebhookDeliveryFieldRecordId:       event.RecordId,
+			models.WebhookDeliveryFieldPayload:        string(payload),
+			models.WebhookDeliveryFieldOrgId:          event.OrgId,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recorded++
+	}
+	return recorded, stdErr.Join(errs...)
+}
+
+// RedeliverCommand asks for one delivery to be sent again.
+type RedeliverCommand struct {
+	DeliveryId string
+}
+
+// RedeliverResult names the delivery a redelivery recorded.
+type RedeliverResult struct {
+	DeliveryId   string
+	RedeliveryOf string
+	Status       string
+}
+
+// Redeliver sends a delivery again, whatever its status.
+//
+// It is how a dead-lettered delivery is sent once the target is back, and how one an integrator
+// says never arrived is sent again. It is recorded as a new delivery of the same event with the
+// same payload, so the log keeps both: what was tried then, and what was tried now.
+func (this *WebhookDeliveryDomainService) Redeliver(
+	ctx corectx.Context, cmd RedeliverCommand,
+) (*RedeliverResult, *ft.ClientErrors, error) {
+	vErrs := ft.NewClientErrors()
+	if cmd.DeliveryId == "" {
+		appendFieldViolation(vErrs, models.WebhookDeliveryFieldId,
+			"webhook.delivery_required", "no delivery was identified")
+		return nil, vErrs, nil
+	}
+
+	found, err := findRecordById(ctx, models.WebhookDeliverySchemaName, cmd.DeliveryId)
+	if err != nil {
+		return nil, vErrs, err
+	}
+	if found == nil {
+		appendFieldViolation(vErrs, models.WebhookDeliveryFieldId,
+			"webhook.delivery_not_found", "no delivery with id '"+cmd.DeliveryId+"'")
+		return nil, vErrs, nil
+	}
+	original := models.NewWebhookDeliveryFrom(found)
+
+	deliveryId, err := this.record(ctx, dmodel.DynamicFields{
+		models.WebhookDeliveryFieldSubscriptionId: derefString(original.GetSubscriptionId()),
+		models.WebhookDeliveryFieldEventId:        derefString(original.GetEventId()),
+		models.WebhookDeliveryFieldEventName:      derefString(original.GetEventName()),
+		models.WebhookDeliveryFieldRecordId:       derefString(original.GetRecordId()),
+		models.WebhookDeliveryFieldPayload:        derefString(original.GetPayload()),
+		models.WebhookDeliveryFieldOrgId:          derefString(original.GetOrgId()),
+		models.WebhookDeliveryFieldRedeliveryOf:   cmd.DeliveryId,
+	})
+	if err != nil {
+		return nil, vErrs, err
+	}
+	return &RedeliverResult{
+		DeliveryId:   deliveryId,
+		RedeliveryOf: cmd.DeliveryId,
+		Status:       models.WebhookDeliveryStatusPending,
+	}, vErrs, nil
+}
+
+// record writes a pending delivery and queues it.
+//
+// A delivery the queue refused stays pending in the log, where a redelivery can pick it up; the
+// refusal is still reported, so that it is logged.
+func (this *WebhookDeliveryDomainService) record(ctx corectx.Context, fields dmodel.DynamicFields) (string, error) {
+	fields[models.WebhookDeliveryFieldStatus] = models.WebhookDeliveryStatusPending
+	fields[models.WebhookDeliveryFieldAttempts] = int32(0)
+
+	created, err := createRecord(ctx, models.WebhookDeliverySchemaName, fields)
+	if err != nil {
+		return "", err
+	}
+	deliveryId := readString(created, models.WebhookDeliveryFieldId)
+	if err := this.enqueue(ctx, deliveryId); err != nil {
+		return deliveryId, errors.Wrapf(err, "delivery '%s' could not be queued", deliveryId)
+	}
+	return deliveryId, nil
+}
+
+// Deliver makes one attempt at a delivery and records how it went. It is the job queue's handler.
+//
+// It returns an error for an attempt that failed with attempts remaining, which is what has the
+// queue retry it after its backoff. A delivery already delivered or dead-lettered, or one deleted
+// with its subscription, is done: there is nothing to retry.
+func (this *WebhookDeliveryDomainService) Deliver(ctx corectx.Context, deliveryId string) error {
+	found, err := findRecordById(ctx, models.WebhookDeliverySchemaName, deliveryId)
+	if err != nil || found == nil {
+		return err
+	}
+	delivery := models.NewWebhookDeliveryFrom(found)
+	status := derefString(delivery.GetStatus())
+	if status == models.WebhookDeliveryStatusDelivered || status == models.WebhookDeliveryStatusDeadLettered {
+		return nil
+	}
+
+	subscriptionFields, err := findRecordById(
+		ctx, models.WebhookSubscriptionSchemaName, derefString(delivery.GetSubscriptionId()))
+	if err != nil || subscriptionFields == nil {
+		return err
+	}
+	subscription := models.NewWebhookSubscriptionFrom(subscriptionFields)
+
+	outcome := this.sender.Send(ctx, SendRequest{
+		TargetUrl:  derefString(subscription.GetTargetUrl()),
+		Secret:     derefString(subscription.GetSecret()),
+		DeliveryId: deliveryId,
+		EventName:  derefString(delivery.GetEventName()),
+		Payload:    []byte(derefString(delivery.GetPayload())),
+	})
+	at := this.now()
+
+	priorAttempts := 0
+	if attempts := delivery.GetAttempts(); attempts != nil {
+		priorAttempts = int(*attempts)
+	}
+	state := nextDeliveryState(this.policy, priorAttempts, outcome)
+
+	fields := dmodel.DynamicFields{
+		models.WebhookDeliveryFieldId:             deliveryId,
+		models.WebhookDeliveryFieldStatus:         state.Status,
+		models.WebhookDeliveryFieldAttempts:       int32(state.Attempts),
+		models.WebhookDeliveryFieldLastAttemptAt:  model.WrapModelDateTime(at),
+		models.WebhookDeliveryFieldLastDetail:     outcome.Detail,
+		models.WebhookDeliveryFieldResponseStatus: nil,
+	}
+	if outcome.ResponseStatus > 0 {
+		fields[models.WebhookDeliveryFieldResponseStatus] = int32(outcome.ResponseStatus)
+	}
+	if outcome.Succeeded() {
+		fields[models.WebhookDeliveryFieldDeliveredAt] = model.WrapModelDateTime(at)
+	}
+	if err := writeDeliveryFields(ctx, fields); err != nil {
+		return err
+	}
+
+	if state.Status == models.WebhookDeliveryStatusFailed {
+		return errors.Errorf("delivery '%s' failed: %s", deliveryId, outcome.Detail)
+	}
+	return nil
+}
+
+// deliveryState is where a delivery stands after an attempt.
+type deliveryState struct {
+	Status   string
+	Attempts int
+}
+
+// nextDeliveryState decides where a delivery stands after an attempt.
+func nextDeliveryState(policy DeliveryPolicy, priorAttempts int, outcome SendOutcome) deliveryState {
+	attempts := priorAttempts + 1
+	switch {
+	case outcome.Succeeded():
+		return deliveryState{Status: models.WebhookDeliveryStatusDelivered, Attempts: attempts}
+	case attempts >= policy.MaxAttempts:
+		return deliveryState{Status: models.WebhookDeliveryStatusDeadLettered, Attempts: attempts}
+	default:
+		return deliveryState{Status: models.WebhookDeliveryStatusFailed, Attempts: attempts}
+	}
+}
+
+// ListDeliveriesQuery pages through one subscription's delivery log.
+type ListDeliveriesQuery struct {
+	SubscriptionId string
+	Page           int
+	Size           int
+}
+
+// ListDeliveries returns a subscription's deliveries, the latest first. The payload is left out:
+// it is read one delivery at a time, where it is asked for.
+func (this *WebhookDeliveryDomainService) ListDeliveries(
+	ctx corectx.Context, query ListDeliveriesQuery,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	engine, err := engineFor(models.WebhookDeliverySchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dmodel.SearchGraph{}
+	graph.And(
+		*dmodel.NewSearchNode().NewCondition(
+			models.WebhookDeliveryFieldSubscriptionId, dmodel.Equals, query.SubscriptionId),
+	)
+	graph.OrderBy(basemodel.FieldCreatedAt, dmodel.Desc)
+
+	found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+		Fields: deliveryLogFields,
+		Graph:  graph,
+		Page:   query.Page,
+		Size:   query.Size,
+	})
+	return found, errors.Wrap(err, "ListDeliveries")
+}
+
+// deliveryLogFields is what a delivery log lists of each delivery.
+var deliveryLogFields = []string{
+	models.WebhookDeliveryFieldId,
+	models.WebhookDeliveryFieldEventId,
+	models.WebhookDeliveryFieldEventName,
+	models.WebhookDeliveryFieldRecordId,
+	models.WebhookDeliveryFieldStatus,
+	models.WebhookDeliveryFieldAttempts,
+	models.WebhookDeliveryFieldResponseStatus,
+	models.WebhookDeliveryFieldLastAttemptAt,
+	models.WebhookDeliveryFieldDeliveredAt,
+	models.WebhookDeliveryFieldLastDetail,
+	models.WebhookDeliveryFieldRedeliveryOf,
+	basemodel.FieldCreatedAt,
+}
+
+// writeDeliveryFields updates a delivery through the repository. Every field after the payload is
+// no_update, so a delivery's attempts can be written only here.
+func writeDeliveryFields(ctx corectx.Context, fields dmodel.DynamicFields) error {
+	engine, err := engineFor(models.WebhookDeliverySchemaName)
+	if err != nil {
+		return err
+	}
+	result, err := engine.ResourceRepository().Update(ctx, fields)
+	if err != nil {
+		return errors.Wrap(err, "writeDeliveryFields")
+	}
+	if result != nil && result.ClientErrors.Count() > 0 {
+		return errors.Errorf("writeDeliveryFields: the delivery's own schema refused it: %v", result.ClientErrors)
+	}
+	return nil
+}
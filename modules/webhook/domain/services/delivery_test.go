@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+var testDeliveryPolicy = DeliveryPolicy{MaxAttempts: 3}
+
+func TestA2xxDelivers(t *testing.T) {
+	state := nextDeliveryState(testDeliveryPolicy, 1, SendOutcome{ResponseStatus: 204})
+
+	assert.Equal(t, models.WebhookDeliveryStatusDelivered, state.Status)
+	assert.Equal(t, 2, state.Attempts)
+}
+
+// A redirect is not a delivery: the sender does not follow one, so the target never saw the event.
+func TestAnythingElseFails(t *testing.T) {
+	for _, outcome := range []SendOutcome{
+		{ResponseStatus: 500, Detail: "the target answered 500"},
+		{ResponseStatus: 301, Detail: "the target answered 301"},
+		{Detail: "the target could not be reached"},
+	} {
+		state := nextDeliveryState(testDeliveryPolicy, 0, outcome)
+		assert.Equal(t, models.WebhookDeliveryStatusFailed, state.Status, outcome.Detail)
+		assert.Equal(t, 1, state.Attempts)
+	}
+}
+
+// The attempt that uses the last of the policy dead-letters the delivery, so that the queue's last
+// attempt and the log's agree.
+func TestTheLastAttemptDeadLetters(t *testing.T) {
+	state := nextDeliveryState(testDeliveryPolicy, 2, SendOutcome{ResponseStatus: 503})
+
+	assert.Equal(t, models.WebhookDeliveryStatusDeadLettered, state.Status)
+	assert.Equal(t, 3, state.Attempts)
+}
+
+// A deployment that configured no attempts still gets the one every delivery is made with.
+func TestADeliveryPolicyHasAtLeastOneAttempt(t *testing.T) {
+	service := NewWebhookDeliveryDomainService(nil, nil, DeliveryPolicy{})
+
+	assert.Equal(t, 1, service.policy.MaxAttempts)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// engineFor resolves a resource's engine from the registry.
+//
+// It is a variable rather than a plain function so that a test can supply its own engines: the
+// registry is a package singleton populated during Init, which a unit test has no way to build.
+var engineFor = func(schemaName string) (drif.DynamicResourceEngine, error) {
+	engine, ok := dynamicresource.Registry().GetEngine(schemaName)
+	if !ok {
+		return nil, errors.Errorf("no resource engine for '%s'", schemaName)
+	}
+	return engine, nil
+}
+
+// createRecord writes a record this module composed through the resource's service, so that the
+// schema's defaults and audit columns are applied as for any other create.
+func createRecord(
+	ctx corectx.Context, schemaName string, fields dmodel.DynamicFields,
+) (dmodel.DynamicFields, error) {
+	engine, err := engineFor(schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := engine.ResourceService().Create(ctx, fields)
+	if err != nil {
+		return nil, errors.Wrapf(err, "createRecord(%s)", schemaName)
+	}
+	if result == nil || result.ClientErrors.Count() > 0 {
+		return nil, errors.Errorf(
+			"createRecord(%s): the record this module composed was rejected by its own schema: %v",
+			schemaName, result.ClientErrors)
+	}
+	if !result.HasData {
+		return nil, errors.Errorf("createRecord(%s): no record was returned", schemaName)
+	}
+	return result.Data, nil
+}
+
+// findRecordById fetches one record by primary key, or nil when there is none.
+func findRecordById(ctx corectx.Context, schemaName string, id string) (dmodel.DynamicFields, error) {
+	engine, err := engineFor(schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := engine.ResourceRepository().FindByKeys(ctx, dmodel.DynamicFields{"id": id})
+	if err != nil {
+		return nil, errors.Wrapf(err, "findRecordById(%s)", schemaName)
+	}
+	if found == nil || !found.HasData {
+		return nil, nil
+	}
+	return found.Data, nil
+}
+
+// appendFieldViolation records a rule broken by one named input, so the caller is told which of
+// their fields to fix rather than only that something was wrong.
+func appendFieldViolation(vErrs *ft.ClientErrors, field string, key string, message string) {
+	vErrs.Append(*ft.NewBusinessViolation(field, key, message))
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func readString(params dmodel.DynamicFields, field string) string {
+	value, ok := params[field]
+	if !ok || value == nil {
+		return ""
+	}
+	if typed, ok := value.(string); ok {
+		return typed
+	}
+	return ""
+}
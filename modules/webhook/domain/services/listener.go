@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+// subscribedActionsTtl bounds how stale the listener's view of the subscriptions may be. A write
+// through this module invalidates it at once; the bound covers the writes of other instances.
+const subscribedActionsTtl = 30 * time.Second
+
+// ActionDispatcher records and queues the deliveries of one event.
+type ActionDispatcher interface {
+	Dispatch(ctx corectx.Context, event drif.ActionEvent) (int, error)
+}
+
+// SubscriptionLoader lists, per resource, the actions some active subscription listens to.
+type SubscriptionLoader func(ctx corectx.Context) (map[string][]string, error)
+
+// LoadSubscribedActions reads every active subscription.
+func LoadSubscribedActions(ctx corectx.Context) (map[string][]string, error) {
+	subscribed := map[string][]string{}
+	err := eachActiveSubscription(ctx, func(subscription *models.WebhookSubscription) {
+		resourceName := derefString(subscription.GetResourceName())
+		subscribed[resourceName] = append(subscribed[resourceName], subscription.GetActionNames()...)
+	})
+	return subscribed, err
+}
+
+// SubscriptionListener hands the events of subscribed actions to the dispatcher.
+//
+// ListensTo is asked on every action of every resource, so it answers from a map of the
+// subscribed actions rather than from the database. Only an action some subscription names in some
+// organization gets an event built at all; whether the event's organization is among them is
+// Dispatch's question.
+type SubscriptionListener struct {
+	dispatcher ActionDispatcher
+	load       SubscriptionLoader
+	logger     logging.LoggerService
+
+	mutex      sync.Mutex
+	subscribed map[string][]string
+	loadedAt   time.Time
+
+	// now is injected so the expiry can be tested against a fixed clock.
+	now func() time.Time
+}
+
+var _ drif.ActionEventListener = (*SubscriptionListener)(nil)
+
+func NewSubscriptionListener(
+	dispatcher ActionDispatcher, load SubscriptionLoader, logger logging.LoggerService,
+) *SubscriptionListener {
+	if load == nil {
+		load = LoadSubscribedActions
+	}
+	return &SubscriptionListener{dispatcher: dispatcher, load: load, logger: logger, now: time.Now}
+}
+
+// Invalidate has the next question read the subscriptions again.
+func (this *SubscriptionListener) Invalidate() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.subscribed = nil
+}
+
+func (this *SubscriptionListener) ListensTo(resourceName string, actionName string) bool {
+	if isWebhookResource(resourceName) {
+		return false
+	}
+	return slices.Contains(this.subscribedActions()[resourceName], actionName)
+}
+
+// OnActionSucceeded dispatches the event. An event with no organization has no subscriptions, as
+// every subscription belongs to one.
+//
+// The deliveries are written as the system rather than as the actor: they are the module's record
+// of what it sent, which the actor's permissions have no say in.
+func (this *SubscriptionListener) OnActionSucceeded(_ corectx.Context, event drif.ActionEvent) {
+	if event.OrgId == "" {
+		return
+	}
+	if _, err := this.dispatcher.Dispatch(corectx.NewRequestContext(context.Background()), event); err != nil {
+		this.logError("[SubscriptionListener] %s.%s event '%s': %s",
+			event.ResourceName, event.ActionName, event.Id, err.Error())
+	}
+}
+
+// subscribedActions returns the map of subscribed actions, reading it again once it has expired.
+// A failed read keeps the map it had, and is not tried again before the map would have expired:
+// a database that is down should not be asked once per action.
+func (this *SubscriptionListener) subscribedActions() map[string][]string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	now := this.now()
+	if this.subscribed != nil && now.Sub(this.loadedAt) < subscribedActionsTtl {
+		return this.subscribed
+	}
+
+	loaded, err := this.load(corectx.NewRequestContext(context.Background()))
+	this.loadedAt = now
+	if err != nil {
+		this.logError("[SubscriptionListener] reading the subscriptions: %s", err.Error())
+		if this.subscribed == nil {
+			this.subscribed = map[string][]string{}
+		}
+		return this.subscribed
+	}
+	this.subscribed = loaded
+	return this.subscribed
+}
+
+func (this *SubscriptionListener) logError(format string, args ...any) {
+	if this.logger != nil {
+		this.logger.Errorf(format, args...)
+	}
+}
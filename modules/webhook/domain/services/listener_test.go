@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+type recordingDispatcher struct {
+	events []drif.ActionEvent
+}
+
+func (this *recordingDispatcher) Dispatch(_ corectx.Context, event drif.ActionEvent) (int, error) {
+	this.events = append(this.events, event)
+	return 1, nil
+}
+
+// countingLoader answers with the subscriptions it holds, or its error, and counts the reads.
+type countingLoader struct {
+	subscribed map[string][]string
+	err        error
+	reads      int
+}
+
+func (this *countingLoader) load(_ corectx.Context) (map[string][]string, error) {
+	this.reads++
+	return this.subscribed, this.err
+}
+
+func newTestListener(loader *countingLoader) (*SubscriptionListener, *recordingDispatcher, *time.Time) {
+	dispatcher := &recordingDispatcher{}
+	listener := NewSubscriptionListener(dispatcher, loader.load, nil)
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	listener.now = func() time.Time { return now }
+	return listener, dispatcher, &now
+}
+
+func TestTheListenerListensToSubscribedActionsOnly(t *testing.T) {
+	loader := &countingLoader{subscribed: map[string][]string{"purchase_order": {"confirm"}}}
+	listener, _, _ := newTestListener(loader)
+
+	assert.True(t, listener.ListensTo("purchase_order", "confirm"))
+	assert.False(t, listener.ListensTo("purchase_order", "update"))
+	assert.False(t, listener.ListensTo("sales_order", "confirm"))
+	assert.Equal(t, 1, loader.reads, "the subscriptions are read once, not per question")
+}
+
+func TestTheListenerReadsTheSubscriptionsAgainWhenTheyChange(t *testing.T) {
+	loader := &countingLoader{subscribed: map[string][]string{}}
+	listener, _, now := newTestListener(loader)
+	assert.False(t, listener.ListensTo("purchase_order", "confirm"))
+
+	loader.subscribed = map[string][]string{"purchase_order": {"confirm"}}
+	assert.False(t, listener.ListensTo("purchase_order", "confirm"), "still cached")
+
+	listener.Invalidate()
+	assert.True(t, listener.ListensTo("purchase_order", "confirm"), "a write through this instance is seen at once")
+
+	loader.subscribed = map[string][]string{}
+	*now = now.Add(subscribedActionsTtl)
+	assert.False(t, listener.ListensTo("purchase_order", "confirm"), "another instance's write is seen once expired")
+	assert.Equal(t, 3, loader.reads)
+}
+
+// A database that is down is not asked again on every action until the map would have expired.
+func TestAFailedReadKeepsWhatWasKnown(t *testing.T) {
+	loader := &countingLoader{subscribed: map[string][]string{"purchase_order": {"confirm"}}}
+	listener, _, now := newTestListener(loader)
+	assert.True(t, listener.ListensTo("purchase_order", "confirm"))
+
+	loader.subscribed, loader.err = nil, errors.New("connection refused")
+	*now = now.Add(subscribedActionsTtl)
+	assert.True(t, listener.ListensTo("purchase_order", "confirm"))
+	assert.True(t, listener.ListensTo("purchase_order", "confirm"))
+	assert.Equal(t, 2, loader.reads)
+}
+
+func TestTheListenerDispatchesEventsOfAnOrganization(t *testing.T) {
+	listener, dispatcher, _ := newTestListener(&countingLoader{})
+	ctx := corectx.NewRequestContext(t.Context())
+
+	listener.OnActionSucceeded(ctx, drif.ActionEvent{ResourceName: "purchase_order", ActionName: "confirm"})
+	listener.OnActionSucceeded(ctx, drif.ActionEvent{ResourceName: "purchase_order", ActionName: "confirm", OrgId: "01JORG"})
+
+	assert.Len(t, dispatcher.events, 1, "an event of no organization has no subscription")
+	assert.Equal(t, "01JORG", dispatcher.events[0].OrgId)
+}
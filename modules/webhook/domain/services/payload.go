@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+// The headers a delivery is posted with.
+//
+// The signature is HMAC-SHA256, keyed by the subscription's secret, over the timestamp header, a
+// full stop and the raw body, hex-encoded behind "sha256=": the scheme the payment module already
+// signs its notifications with, so a receiver verifying one can verify the other. The timestamp is
+// signed so a receiver can refuse a captured request replayed later.
+const (
+	HeaderEvent              = "X-Nikki-Event"
+	HeaderDelivery           = "X-Nikki-Delivery"
+	HeaderSignature          = "X-Nikki-Signature"
+	HeaderSignatureTimestamp = "X-Nikki-Timestamp"
+
+	signatureScheme = "sha256="
+)
+
+// MaxPayloadBytes bounds a payload. Above it the data is left out and the payload says so: the
+// receiver reads the record by record_id instead. It keeps one bulk import from writing a log row
+// of many megabytes, and the target from being posted one.
+const MaxPayloadBytes = 256 * 1024
+
+// EventPayload is the body posted to a subscription's target.
+type EventPayload struct {
+	// Id is the event's, not the delivery's: every delivery of one event, a redelivery included,
+	// carries the same id, which is what a receiver deduplicates by.
+	Id string `json:"id"`
+
+	// Event is "resource.action", e.g. "purchase_order.confirm".
+	Event      string    `json:"event"`
+	Resource   string    `json:"resource"`
+	Action     string    `json:"action"`
+	OrgId      string    `json:"org_id"`
+	ActorId    string    `json:"actor_id,omitempty"`
+	RecordId   string    `json:"record_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// Data is the action's result, of the shape the action documents.
+	Data any `json:"data,omitempty"`
+
+	// DataOmitted is set when the data was too large to post, or could not be encoded.
+	DataOmitted bool `json:"data_omitted,omitempty"`
+}
+
+// EventName names an event the way the X-Nikki-Event header and the payload do.
+func EventName(resourceName string, actionName string) string {
+	return resourceName + "." + actionName
+}
+
+// EncodeEventPayload renders the body posted for an event.
+func EncodeEventPayload(event drif.ActionEvent) ([]byte, error) {
+	payload := EventPayload{
+		Id:         event.Id,
+		Event:      EventName(event.ResourceName, event.ActionName),
+		Resource:   event.ResourceName,
+		Action:     event.ActionName,
+		OrgId:      event.OrgId,
+		ActorId:    event.ActorId,
+		RecordId:   event.RecordId,
+		OccurredAt: event.OccurredAt.UTC(),
+		Data:       withoutSecrets(event.ResourceName, event.Data),
+	}
+
+	body, err := json.Marshal(payload)
+	if err == nil && len(body) <= MaxPayloadBytes {
+		return body, nil
+	}
+	payload.Data = nil
+	payload.DataOmitted = true
+	return json.Marshal(payload)
+}
+
+// withoutSecrets drops the secret fields of a record an action answered with. A record created
+// with a password, say, is answered with it; the subscription it is posted to was never granted it.
+func withoutSecrets(resourceName string, data any) any {
+	record, isRecord := data.(dmodel.DynamicFields)
+	if !isRecord {
+		return data
+	}
+	schema := dmodel.GetSchema(resourceName)
+	if schema == nil {
+		return data
+	}
+
+	scrubbed := make(dmodel.DynamicFields, len(record))
+	for key, value := range record {
+		if field, exists := schema.Field(key); exists && field.DataType().String() == dmodel.FieldDataTypeNameSecret {
+			continue
+		}
+		scrubbed[key] = value
+	}
+	return scrubbed
+}
+
+// SignPayload computes the signature header for a body sent at timestamp. It is exported as the
+// reference a receiver's verification can be checked against.
+func SignPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureScheme + hex.EncodeToString(mac.Sum(nil))
+}
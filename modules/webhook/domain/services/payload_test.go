@@ -0,0 +1,104 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+)
+
+const payloadTestSchema = "webhook_test_account"
+
+func registerPayloadTestSchema(t *testing.T) {
+	t.Helper()
+	if dmodel.GetSchemaRegistry().Get(payloadTestSchema) != nil {
+		return
+	}
+	require.NoError(t, dmodel.RegisterSchemaB(
+		dmodel.DefineModel(payloadTestSchema).
+			Field(dmodel.DefineField().Name("id").DataType(dmodel.FieldDataTypeUlid()).PrimaryKey(true)).
+			Field(dmodel.DefineField().Name("login").DataType(dmodel.FieldDataTypeString(1, 50))).
+			Field(dmodel.DefineField().Name("password").DataType(dmodel.FieldDataTypeSecret(8, 50)))))
+}
+
+func decodePayload(t *testing.T, body []byte) EventPayload {
+	t.Helper()
+	var payload EventPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	return payload
+}
+
+// The signature is the one a receiver computes from the headers and the raw body.
+func TestTheSignatureCoversTheTimestampAndTheBody(t *testing.T) {
+	body := []byte(`{"id":"01JEVENT"}`)
+	mac := hmac.New(sha256.New, []byte("a-shared-secret"))
+	mac.Write([]byte("1760000000." + string(body)))
+
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), SignPayload("a-shared-secret", "1760000000", body))
+	assert.NotEqual(t, SignPayload("a-shared-secret", "1760000000", body),
+		SignPayload("a-shared-secret", "1760000001", body), "a replayed body with a new timestamp fails")
+}
+
+func TestThePayloadNamesTheEvent(t *testing.T) {
+	occurredAt := time.Date(2026, 10, 1, 8, 30, 0, 0, time.FixedZone("ICT", 7*60*60))
+	body, err := EncodeEventPayload(drif.ActionEvent{
+		Id:           "01JEVENT",
+		ResourceName: "purchase_order",
+		ActionName:   "confirm",
+		OrgId:        "01JORG",
+		RecordId:     "01JREC",
+		OccurredAt:   occurredAt,
+		Data:         map[string]any{"status": "confirmed"},
+	})
+	require.NoError(t, err)
+
+	payload := decodePayload(t, body)
+	assert.Equal(t, "01JEVENT", payload.Id)
+	assert.Equal(t, "purchase_order.confirm", payload.Event)
+	assert.Equal(t, "01JORG", payload.OrgId)
+	assert.Equal(t, "01JREC", payload.RecordId)
+	assert.Equal(t, occurredAt.UTC(), payload.OccurredAt)
+	assert.Equal(t, map[string]any{"status": "confirmed"}, payload.Data)
+	assert.False(t, payload.DataOmitted)
+}
+
+func TestThePayloadLeavesOutTheSecretsOfARecord(t *testing.T) {
+	registerPayloadTestSchema(t)
+
+	body, err := EncodeEventPayload(drif.ActionEvent{
+		ResourceName: payloadTestSchema,
+		ActionName:   "create",
+		Data:         dmodel.DynamicFields{"id": "01JREC", "login": "jdoe", "password": "hunter2hunter2"},
+	})
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(body), "hunter2")
+	assert.Equal(t, map[string]any{"id": "01JREC", "login": "jdoe"}, decodePayload(t, body).Data)
+}
+
+// An oversized payload still tells the receiver what happened, and to which record.
+func TestAnOversizedPayloadLeavesOutItsData(t *testing.T) {
+	body, err := EncodeEventPayload(drif.ActionEvent{
+		Id:           "01JEVENT",
+		ResourceName: "product",
+		ActionName:   "import",
+		RecordId:     "01JREC",
+		Data:         strings.Repeat("x", MaxPayloadBytes),
+	})
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, len(body), MaxPayloadBytes)
+	payload := decodePayload(t, body)
+	assert.True(t, payload.DataOmitted)
+	assert.Nil(t, payload.Data)
+	assert.Equal(t, "01JREC", payload.RecordId)
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"slices"
+
+	"go.bryk.io/pkg/errors"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	ft "github.com/sky-as-code/nikki-erp/common/fault"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+// subscriptionPageSize is how many subscriptions are read per query when all of them are wanted.
+const subscriptionPageSize = 200
+
+// ActionNamesLookup lists the actions of a resource, and whether the resource exists at all.
+type ActionNamesLookup func(resourceName string) ([]string, bool)
+
+// RegisteredActionNames looks a resource's actions up in the engine registry.
+func RegisteredActionNames(resourceName string) ([]string, bool) {
+	engine, ok := dynamicresource.Registry().GetEngine(resourceName)
+	if !ok {
+		return nil, false
+	}
+	return engine.ActionNames(), true
+}
+
+// NewWebhookSubscriptionDomainService derives the subscription service from the engine's default.
+//
+// onChange is called after every write that succeeded, so that whatever caches the subscriptions
+// knows to read them again. It may be nil.
+func NewWebhookSubscriptionDomainService(
+	base drif.DynamicResourceService, actionsOf ActionNamesLookup, onChange func(),
+) *WebhookSubscriptionDomainServiceImpl {
+	if actionsOf == nil {
+		actionsOf = RegisteredActionNames
+	}
+	if onChange == nil {
+		onChange = func() {}
+	}
+	return &WebhookSubscriptionDomainServiceImpl{
+		DynamicResourceService: base,
+		actionsOf:              actionsOf,
+		onChange:               onChange,
+	}
+}
+
+// WebhookSubscriptionDomainServiceImpl checks what a subscription listens to, and keeps its secret
+// from being read back.
+//
+// A subscription to an action that does not exist would never fire, and the integrator would wait
+// for events nobody sends; so the resource and its actions are checked against the registry when
+// they are written. The secret is write-only: whoever set it has it, and a client reading the
+// subscription needs only to know that one is set.
+type WebhookSubscriptionDomainServiceImpl struct {
+	drif.DynamicResourceService
+
+	actionsOf ActionNamesLookup
+	onChange  func()
+}
+
+var _ drif.DynamicResourceService = (*WebhookSubscriptionDomainServiceImpl)(nil)
+
+func (this *WebhookSubscriptionDomainServiceImpl) Create(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dmodel.DynamicFields], error) {
+	vErrs := this.validateTarget(
+		readString(params, models.WebhookSubscriptionFieldResourceName),
+		params[models.WebhookSubscriptionFieldActionNames],
+	)
+	if vErrs.Count() > 0 {
+		return &dyn.OpResult[dmodel.DynamicFields]{ClientErrors: *vErrs}, nil
+	}
+
+	result, err := this.DynamicResourceService.Create(ctx, params)
+	if err != nil || result == nil || result.ClientErrors.Count() > 0 {
+		return result, err
+	}
+	this.onChange()
+	if result.HasData {
+		delete(result.Data, models.WebhookSubscriptionFieldSecret)
+	}
+	return result, nil
+}
+
+// Update checks the resource and actions the subscription will have once updated: a patch naming
+// only new actions is checked against the resource already stored, and the other way round.
+func (this *WebhookSubscriptionDomainServiceImpl) Update(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	_, hasResource := params[models.WebhookSubscriptionFieldResourceName]
+	_, hasActions := params[models.WebhookSubscriptionFieldActionNames]
+	if hasResource || hasActions {
+		existing, err := findRecordById(
+			ctx, models.WebhookSubscriptionSchemaName, readString(params, models.WebhookSubscriptionFieldId))
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			merged := models.NewWebhookSubscriptionFrom(existing)
+			resourceName := derefString(merged.GetResourceName())
+			var actionNames any = merged.GetActionNames()
+			if hasResource {
+				resourceName = readString(params, models.WebhookSubscriptionFieldResourceName)
+			}
+			if hasActions {
+				actionNames = params[models.WebhookSubscriptionFieldActionNames]
+			}
+			if vErrs := this.validateTarget(resourceName, actionNames); vErrs.Count() > 0 {
+				return &dyn.OpResult[dyn.MutateResultData]{ClientErrors: *vErrs}, nil
+			}
+		}
+	}
+	return this.afterWrite(this.DynamicResourceService.Update(ctx, params))
+}
+
+func (this *WebhookSubscriptionDomainServiceImpl) Delete(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	return this.afterWrite(this.DynamicResourceService.Delete(ctx, params))
+}
+
+func (this *WebhookSubscriptionDomainServiceImpl) GetById(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.SingleResultData[dmodel.DynamicFields]], error) {
+	result, err := this.DynamicResourceService.GetById(ctx, params)
+	if err == nil && result != nil && result.HasData {
+		delete(result.Data.Item, models.WebhookSubscriptionFieldSecret)
+	}
+	return result, err
+}
+
+func (this *WebhookSubscriptionDomainServiceImpl) GetOne(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.SingleResultData[dmodel.DynamicFields]], error) {
+	result, err := this.DynamicResourceService.GetOne(ctx, params)
+	if err == nil && result != nil && result.HasData {
+		delete(result.Data.Item, models.WebhookSubscriptionFieldSecret)
+	}
+	return result, err
+}
+
+// Search also serves the export, which reads its rows through it.
+func (this *WebhookSubscriptionDomainServiceImpl) Search(
+	ctx corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	result, err := this.DynamicResourceService.Search(ctx, params)
+	if err == nil && result != nil {
+		for _, item := range result.Data.Items {
+			delete(item, models.WebhookSubscriptionFieldSecret)
+		}
+	}
+	return result, err
+}
+
+func (this *WebhookSubscriptionDomainServiceImpl) afterWrite(
+	result *dyn.OpResult[dyn.MutateResultData], err error,
+) (*dyn.OpResult[dyn.MutateResultData], error) {
+	if err == nil && result != nil && result.ClientErrors.Count() == 0 {
+		this.onChange()
+	}
+	return result, err
+}
+
+// validateTarget checks that the resource is one this application has, other than the webhook
+// module's own, and that each action is defined on it.
+func (this *WebhookSubscriptionDomainServiceImpl) validateTarget(resourceName string, actionNames any) *ft.ClientErrors {
+	vErrs := ft.NewClientErrors()
+	if resourceName == "" {
+		return vErrs // A missing resource is reported by the schema, which requires it.
+	}
+	defined, ok := this.actionsOf(resourceName)
+	if !ok || isWebhookResource(resourceName) {
+		appendFieldViolation(vErrs, models.WebhookSubscriptionFieldResourceName,
+			"webhook.unknown_resource", "no resource named '"+resourceName+"' can be subscribed to")
+		return vErrs
+	}
+
+	names := models.NewWebhookSubscriptionFrom(dmodel.DynamicFields{
+		models.WebhookSubscriptionFieldActionNames: actionNames,
+	}).GetActionNames()
+	if len(names) == 0 {
+		appendFieldViolation(vErrs, models.WebhookSubscriptionFieldActionNames,
+			"webhook.actions_required", "at least one action must be subscribed to")
+		return vErrs
+	}
+	for _, name := range names {
+		if !slices.Contains(defined, name) {
+			appendFieldViolation(vErrs, models.WebhookSubscriptionFieldActionNames,
+				"webhook.unknown_action", "resource '"+resourceName+"' has no action '"+name+"'")
+		}
+	}
+	return vErrs
+}
+
+// isWebhookResource tells the webhook module's own resources. Subscribing to them would have each
+// delivery written announce itself, which is a loop rather than an integration.
+func isWebhookResource(resourceName string) bool {
+	return resourceName == models.WebhookSubscriptionSchemaName || resourceName == models.WebhookDeliverySchemaName
+}
+
+// findSubscriptionsFor fetches the active subscriptions of an organization to one action.
+//
+// The action names are an array column, so the query narrows by resource and the action is
+// matched here; an organization has few subscriptions to one resource.
+func findSubscriptionsFor(
+	ctx corectx.Context, orgId string, resourceName string, actionName string,
+) ([]*models.WebhookSubscription, error) {
+	var matching []*models.WebhookSubscription
+	err := eachActiveSubscription(ctx, func(subscription *models.WebhookSubscription) {
+		if slices.Contains(subscription.GetActionNames(), actionName) {
+			matching = append(matching, subscription)
+		}
+	},
+		*dmodel.NewSearchNode().NewCondition(models.WebhookSubscriptionFieldOrgId, dmodel.Equals, orgId),
+		*dmodel.NewSearchNode().NewCondition(
+			models.WebhookSubscriptionFieldResourceName, dmodel.Equals, resourceName),
+	)
+	return matching, errors.Wrap(err, "findSubscriptionsFor")
+}
+
+// eachActiveSubscription calls visit with every active subscription that also meets the
+// given conditions, reading them a page at a time.
+func eachActiveSubscription(
+	ctx corectx.Context, visit func(*models.WebhookSubscription), conditions ...dmodel.SearchNode,
+) error {
+	engine, err := engineFor(models.WebhookSubscriptionSchemaName)
+	if err != nil {
+		return err
+	}
+	graph := &dmodel.SearchGraph{}
+	graph.And(append([]dmodel.SearchNode{
+		*dmodel.NewSearchNode().NewCondition(models.WebhookSubscriptionFieldIsActive, dmodel.Equals, true),
+	}, conditions...)...)
+	graph.OrderBy(models.WebhookSubscriptionFieldId)
+
+	for page := 0; ; page++ {
+		found, err := engine.ResourceRepository().Search(ctx, dyn.RepoSearchParam{
+			Graph: graph,
+			Page:  page,
+			Size:  subscriptionPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		if found == nil || !found.HasData {
+			return nil
+		}
+		for _, item := range found.Data.Items {
+			visit(models.NewWebhookSubscriptionFrom(item))
+		}
+		if len(found.Data.Items) < subscriptionPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	dyn "github.com/sky-as-code/nikki-erp/modules/core/dynamicmodel"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+// stubSubscriptionService answers for the engine's default service. Only the methods a test calls
+// are implemented; the embedded interface is nil and panics on the others.
+type stubSubscriptionService struct {
+	drif.DynamicResourceService
+
+	created dmodel.DynamicFields
+}
+
+func (this *stubSubscriptionService) Create(
+	_ corectx.Context, params dmodel.DynamicFields,
+) (*dyn.OpResult[dmodel.DynamicFields], error) {
+	this.created = params
+	record := dmodel.DynamicFields{}
+	for key, value := range params {
+		record[key] = value
+	}
+	record[models.WebhookSubscriptionFieldId] = "01JSUB"
+	return &dyn.OpResult[dmodel.DynamicFields]{Data: record, HasData: true}, nil
+}
+
+func (this *stubSubscriptionService) Search(
+	_ corectx.Context, _ dmodel.DynamicFields,
+) (*dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]], error) {
+	return &dyn.OpResult[dyn.PagedResultData[dmodel.DynamicFields]]{
+		HasData: true,
+		Data: dyn.PagedResultData[dmodel.DynamicFields]{Items: []dmodel.DynamicFields{
+			{models.WebhookSubscriptionFieldId: "01JSUB", models.WebhookSubscriptionFieldSecret: "s3cret-s3cret-s3cret"},
+		}},
+	}, nil
+}
+
+func testActionsOf(resourceName string) ([]string, bool) {
+	switch resourceName {
+	case "purchase_order":
+		return []string{"create", "update", "confirm"}, true
+	case models.WebhookDeliverySchemaName:
+		return []string{"create", "redeliver"}, true
+	}
+	return nil, false
+}
+
+func newTestSubscriptionService(onChange func()) (*WebhookSubscriptionDomainServiceImpl, *stubSubscriptionService) {
+	base := &stubSubscriptionService{}
+	return NewWebhookSubscriptionDomainService(base, testActionsOf, onChange), base
+}
+
+func subscriptionParams(resourceName string, actionNames ...any) dmodel.DynamicFields {
+	return dmodel.DynamicFields{
+		models.WebhookSubscriptionFieldTargetUrl:    "https://hooks.example.com/nikki",
+		models.WebhookSubscriptionFieldResourceName: resourceName,
+		models.WebhookSubscriptionFieldActionNames:  actionNames,
+		models.WebhookSubscriptionFieldSecret:       "s3cret-s3cret-s3cret",
+	}
+}
+
+func TestASubscriptionIsWrittenWithoutReadingItsSecretBack(t *testing.T) {
+	changed := 0
+	service, base := newTestSubscriptionService(func() { changed++ })
+
+	result, err := service.Create(corectx.NewRequestContext(t.Context()), subscriptionParams("purchase_order", "confirm"))
+	require.NoError(t, err)
+	require.Zero(t, result.ClientErrors.Count())
+
+	assert.Equal(t, "s3cret-s3cret-s3cret", base.created[models.WebhookSubscriptionFieldSecret], "the secret is stored")
+	assert.NotContains(t, result.Data, models.WebhookSubscriptionFieldSecret, "and not answered with")
+	assert.Equal(t, 1, changed, "the listener is told to read the subscriptions again")
+}
+
+func TestASubscriptionToAnUnknownActionIsRefused(t *testing.T) {
+	changed := 0
+	service, base := newTestSubscriptionService(func() { changed++ })
+
+	result, err := service.Create(corectx.NewRequestContext(t.Context()),
+		subscriptionParams("purchase_order", "confirm", "approve"))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, models.WebhookSubscriptionFieldActionNames, result.ClientErrors[0].Field)
+	assert.Nil(t, base.created, "nothing is written")
+	assert.Zero(t, changed)
+}
+
+func TestASubscriptionToNoActionIsRefused(t *testing.T) {
+	service, _ := newTestSubscriptionService(nil)
+
+	result, err := service.Create(corectx.NewRequestContext(t.Context()), subscriptionParams("purchase_order"))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, result.ClientErrors.Count())
+	assert.Equal(t, models.WebhookSubscriptionFieldActionNames, result.ClientErrors[0].Field)
+}
+
+// The delivery log is a resource like any other to the registry, but an event for every delivery
+// written would have each delivery announce itself.
+func TestTheWebhookResourcesCannotBeSubscribedTo(t *testing.T) {
+	service, _ := newTestSubscriptionService(nil)
+
+	for _, resourceName := range []string{"no_such_resource", models.WebhookDeliverySchemaName} {
+		result, err := service.Create(corectx.NewRequestContext(t.Context()), subscriptionParams(resourceName, "create"))
+		require.NoError(t, err)
+
+		require.Equal(t, 1, result.ClientErrors.Count(), resourceName)
+		assert.Equal(t, models.WebhookSubscriptionFieldResourceName, result.ClientErrors[0].Field)
+	}
+}
+
+func TestASearchDoesNotAnswerWithSecrets(t *testing.T) {
+	service, _ := newTestSubscriptionService(nil)
+
+	result, err := service.Search(corectx.NewRequestContext(t.Context()), dmodel.DynamicFields{})
+	require.NoError(t, err)
+
+	require.Len(t, result.Data.Items, 1)
+	assert.NotContains(t, result.Data.Items[0], models.WebhookSubscriptionFieldSecret)
+}
@@ -0,0 +1,44 @@
+package dynamicengines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource/engine"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/constants"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+func TestTheRedeliverActionIsItsOwnPermission(t *testing.T) {
+	schema := dmodel.DefineModel(models.WebhookDeliverySchemaName).Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+
+	require.NoError(t, defineDeliveryActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionRedeliver)
+	require.True(t, exists)
+
+	// Redelivering calls out to an integrator's system, which read access must not allow, and the
+	// code is what the IAM seed grants.
+	assert.Equal(t, constants.ActionRedeliver, definition.Permission)
+	assert.Equal(t, drif.ActionTypeGeneric, definition.ActionType)
+}
+
+func TestTheDeliveryLogIsReadWithTheSubscription(t *testing.T) {
+	schema := dmodel.DefineModel(models.WebhookSubscriptionSchemaName).Build()
+	testEngine := engine.NewDynamicResourceEngine(engine.NewEngineParam{Schema: schema})
+	require.NoError(t, engine.DefineBuiltinActions(testEngine))
+
+	require.NoError(t, defineSubscriptionActions(testEngine))
+
+	definition, exists := testEngine.Action(constants.ActionDeliveries)
+	require.True(t, exists)
+	assert.Equal(t, drif.PermissionRead, definition.Permission)
+	assert.Equal(t, ":id/deliveries", definition.RestPath)
+	assert.NotNil(t, definition.KeysToFetch, "another organization's subscription answers not found")
+}
@@ -0,0 +1,72 @@
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/constants"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+)
+
+// defineDeliveryActions adds the redeliver action.
+//
+// The delivery is fetched first, so that a delivery of another organization answers not found
+// rather than being sent again.
+func defineDeliveryActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionRedeliver,
+		ActionType:  drif.ActionTypeGeneric,
+		RestPath:    ":id/" + constants.ActionRedeliver,
+		Permission:  constants.ActionRedeliver,
+		KeysToFetch: keysToFetchById,
+		MainProcess: processRedeliver,
+	})
+}
+
+// processRedeliver queues the delivery again. The answer names the new delivery, whose attempts
+// are then read from the log like any other's.
+func processRedeliver(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireDeliveryService()
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErrs, err := service.Redeliver(ctx, services.RedeliverCommand{
+		DeliveryId: readString(input.Params, models.WebhookDeliveryFieldId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cErrs.Count() > 0 {
+		return &drif.ActionResult{ClientErrors: *cErrs}, nil
+	}
+	return &drif.ActionResult{
+		HasData: true,
+		Data: map[string]any{
+			"delivery_id":   result.DeliveryId,
+			"redelivery_of": result.RedeliveryOf,
+			"status":        result.Status,
+		},
+	}, nil
+}
+
+// deliveryService is the domain service the actions delegate to. It is a package variable because
+// an action callback is handed only its own engine.
+var deliveryService *services.WebhookDeliveryDomainService
+
+// SetDeliveryService installs the service the actions delegate to. Init calls it before any request
+// is served.
+func SetDeliveryService(service *services.WebhookDeliveryDomainService) {
+	deliveryService = service
+}
+
+func requireDeliveryService() (*services.WebhookDeliveryDomainService, error) {
+	if deliveryService == nil {
+		return nil, errors.New(
+			"the webhook delivery domain service was not installed; WebhookModule.Init must call " +
+				"dynamicengines.SetDeliveryService")
+	}
+	return deliveryService, nil
+}
@@ -0,0 +1,97 @@
+// Package dynamicengines declares the resource engines the Webhook module serves through the
+// dynamic resource engine, and creates them during the module's Init().
+//
+// Its imports point one way only: the domain and the dynamicresource module, never app/ or
+// transport/. That keeps it importable by both webhook, which creates the engines, and
+// webhook/transport/restful, which registers their routes.
+package dynamicengines
+
+import (
+	"go.bryk.io/pkg/errors"
+
+	"github.com/sky-as-code/nikki-erp/common/array"
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+)
+
+// engineSpec declares one resource engine the Webhook module owns.
+type engineSpec struct {
+	// SchemaName is the dynamic-model schema the engine serves.
+	SchemaName string
+
+	// DefaultFields is the field set a listing search returns.
+	DefaultFields []string
+
+	// DefineActions adds the resource's own actions on top of the built-in CRUD ones.
+	DefineActions func(drif.DynamicResourceEngine) error
+}
+
+var engineSpecs = []engineSpec{
+	{
+		SchemaName: models.WebhookSubscriptionSchemaName,
+		DefaultFields: []string{
+			models.WebhookSubscriptionFieldTargetUrl,
+			models.WebhookSubscriptionFieldResourceName,
+			models.WebhookSubscriptionFieldActionNames,
+			models.WebhookSubscriptionFieldIsActive,
+			models.WebhookSubscriptionFieldOrgId,
+		},
+		DefineActions: defineSubscriptionActions,
+	},
+	// The delivery log is written by the module alone, so the IAM seed grants read and redeliver.
+	{
+		SchemaName: models.WebhookDeliverySchemaName,
+		DefaultFields: []string{
+			models.WebhookDeliveryFieldSubscriptionId,
+			models.WebhookDeliveryFieldEventName,
+			models.WebhookDeliveryFieldRecordId,
+			models.WebhookDeliveryFieldStatus,
+			models.WebhookDeliveryFieldAttempts,
+			models.WebhookDeliveryFieldResponseStatus,
+			models.WebhookDeliveryFieldLastAttemptAt,
+		},
+		DefineActions: defineDeliveryActions,
+	},
+}
+
+// EngineSchemaNames lists the schemas this module creates an engine for, so that route
+// registration and engine creation cannot drift apart.
+func EngineSchemaNames() []string {
+	return array.Map(engineSpecs, func(spec engineSpec) string {
+		return spec.SchemaName
+	})
+}
+
+// InitDynamicEngines creates the resource engines this module owns and publishes them into the
+// dependency container.
+func InitDynamicEngines() error {
+	for _, spec := range engineSpecs {
+		if err := initEngine(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func initEngine(spec engineSpec) error {
+	engine, err := dynamicresource.Registry().NewEngine(spec.SchemaName, drif.NewEngineOptions{
+		DefaultSearchFields: spec.DefaultFields,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the '%s' resource engine", spec.SchemaName)
+	}
+
+	if spec.DefineActions != nil {
+		if err := spec.DefineActions(engine); err != nil {
+			return errors.Wrapf(err, "failed to define actions of the '%s' resource engine", spec.SchemaName)
+		}
+	}
+
+	err = deps.RegisterNamed(
+		dynamicresource.EngineDependencyName(spec.SchemaName),
+		func() drif.DynamicResourceEngine { return engine },
+	)
+	return errors.Wrapf(err, "failed to register the '%s' resource engine", spec.SchemaName)
+}
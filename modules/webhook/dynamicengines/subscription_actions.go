@@ -0,0 +1,90 @@
+package dynamicengines
+
+import (
+	"strconv"
+
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	corectx "github.com/sky-as-code/nikki-erp/modules/core/context"
+	drif "github.com/sky-as-code/nikki-erp/modules/dynamicresource/interfaces"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/constants"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+)
+
+const (
+	paramPage = "page"
+	paramSize = "size"
+
+	defaultDeliveriesPageSize = 20
+)
+
+// defineSubscriptionActions adds the subscription's delivery log.
+//
+// The subscription is fetched first, so a subscription of another organization, or none at all,
+// answers not found rather than an empty log.
+func defineSubscriptionActions(engine drif.DynamicResourceEngine) error {
+	return engine.DefineAction(drif.DynamicActionDefinition{
+		ActionName:  constants.ActionDeliveries,
+		ActionType:  drif.ActionTypeRead,
+		RestPath:    ":id/" + constants.ActionDeliveries,
+		Permission:  drif.PermissionRead,
+		KeysToFetch: keysToFetchById,
+		MainProcess: processListDeliveries,
+	})
+}
+
+// keysToFetchById identifies the record an action on ":id" is about. Both resources key by "id".
+func keysToFetchById(params dmodel.DynamicFields) dmodel.DynamicFields {
+	return dmodel.DynamicFields{models.WebhookSubscriptionFieldId: params[models.WebhookSubscriptionFieldId]}
+}
+
+// processListDeliveries pages through one subscription's deliveries, the latest first.
+func processListDeliveries(ctx corectx.Context, input drif.ProcessInput) (*drif.ActionResult, error) {
+	service, err := requireDeliveryService()
+	if err != nil {
+		return nil, err
+	}
+
+	size := readInt(input.Params, paramSize)
+	if size <= 0 {
+		size = defaultDeliveriesPageSize
+	}
+	result, err := service.ListDeliveries(ctx, services.ListDeliveriesQuery{
+		SubscriptionId: readString(input.Params, models.WebhookSubscriptionFieldId),
+		Page:           readInt(input.Params, paramPage),
+		Size:           size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &drif.ActionResult{
+		ClientErrors: result.ClientErrors,
+		HasData:      true,
+		Data:         result.Data,
+	}, nil
+}
+
+func readString(params dmodel.DynamicFields, field string) string {
+	value, ok := params[field]
+	if !ok || value == nil {
+		return ""
+	}
+	if typed, ok := value.(string); ok {
+		return typed
+	}
+	return ""
+}
+
+// readInt reads a paging number as a query string or a JSON body carries it. Anything else is zero.
+func readInt(params dmodel.DynamicFields, field string) int {
+	switch typed := params[field].(type) {
+	case int:
+		return typed
+	case float64:
+		return int(typed)
+	case string:
+		parsed, _ := strconv.Atoi(typed)
+		return parsed
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+// Package webhook tells integrators' systems of what happens to the resources they subscribe to.
+//
+// An organization subscribes a URL to some actions of one resource. Each time one of those actions
+// succeeds, the module records a delivery of the event to every matching subscription and posts
+// it, signed with the subscription's secret, from the job queue. A delivery that fails is retried
+// with the queue's backoff until it is dead-lettered, and any delivery can be sent again by hand.
+package webhook
+
+import (
+	stdErr "errors"
+	"time"
+
+	deps "github.com/sky-as-code/nikki-erp/common/deps_inject"
+	dmodel "github.com/sky-as-code/nikki-erp/common/dynamicmodel/model"
+	"github.com/sky-as-code/nikki-erp/common/semver"
+	"github.com/sky-as-code/nikki-erp/modules"
+	"github.com/sky-as-code/nikki-erp/modules/core/config"
+	"github.com/sky-as-code/nikki-erp/modules/core/job"
+	"github.com/sky-as-code/nikki-erp/modules/core/logging"
+	"github.com/sky-as-code/nikki-erp/modules/dynamicresource"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/app"
+	modconstants "github.com/sky-as-code/nikki-erp/modules/webhook/constants"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/models"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/domain/services"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/dynamicengines"
+	"github.com/sky-as-code/nikki-erp/modules/webhook/transport/restful"
+)
+
+// ModuleSingleton is the exported symbol that will be looked up by the plugin loader.
+//
+// It is typed DynamicModule rather than InCodeModule so that dropping RegisterModels fails the
+// build. Under the wider interface the method is found by a type assertion instead, and a module
+// that has lost it still compiles, still loads, and silently registers no schemas at all.
+var ModuleSingleton modules.DynamicModule = &WebhookModule{}
+
+type WebhookModule struct {
+}
+
+// LabelKey implements NikkiModule.
+func (*WebhookModule) LabelKey() string {
+	return "webhook.moduleLabel"
+}
+
+// Name implements NikkiModule.
+func (*WebhookModule) Name() string {
+	return modconstants.WebhookModuleName
+}
+
+// Deps implements NikkiModule.
+func (*WebhookModule) Deps() []string {
+	return []string{
+		"dynamicresource",
+	}
+}
+
+// IsInternal implements InCodeModule.
+func (*WebhookModule) IsInternal() bool {
+	return false
+}
+
+// Version implements NikkiModule.
+func (*WebhookModule) Version() semver.SemVer {
+	return *semver.MustParseSemVer("v1.0.0")
+}
+
+// Init implements NikkiModule.
+//
+// The order is load-bearing: the engines must exist before their services are installed on them,
+// the delivery job must be registered before the listener can queue one, and the listener is added
+// last, once everything it dispatches to is in place. It listens to the engines of every module,
+// including those that initialize after this one, as the registry hands it their events too.
+func (*WebhookModule) Init() error {
+	if err := dynamicengines.InitDynamicEngines(); err != nil {
+		return err
+	}
+	err := deps.Invoke(func(
+		cfg config.ConfigService,
+		enqueuer job.JobEnqueuer,
+		queueRegistry job.JobQueueRegistry,
+		logger logging.LoggerService,
+	) error {
+		sendTimeout := time.Duration(
+			cfg.GetInt(modconstants.WebhookTimeoutSecs, defaultTimeoutSecs)) * time.Second
+		maxAttempts := cfg.GetInt(modconstants.WebhookMaxAttempts, defaultMaxAttempts)
+
+		deliveries := services.NewWebhookDeliveryDomainService(
+			app.NewHttpSender(sendTimeout, cfg.GetBool(modconstants.WebhookAllowPrivateTargets, false)),
+			app.NewDeliveryEnqueuer(enqueuer, maxAttempts),
+			services.DeliveryPolicy{MaxAttempts: maxAttempts},
+		)
+		listener := services.NewSubscriptionListener(deliveries, nil, logger)
+
+		subscriptionEngine, ok := dynamicresource.Registry().GetEngine(models.WebhookSubscriptionSchemaName)
+		if !ok {
+			return stdErr.New("the '" + models.WebhookSubscriptionSchemaName + "' engine is not registered")
+		}
+		subscriptionEngine.SetResourceService(services.NewWebhookSubscriptionDomainService(
+			subscriptionEngine.ResourceService(), nil, listener.Invalidate))
+
+		dynamicengines.SetDeliveryService(deliveries)
+		app.RegisterDeliverJob(queueRegistry, deliveries, sendTimeout)
+		dynamicresource.Registry().AddActionEventListener(listener)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return restful.InitRestfulHandlers()
+}
+
+// RegisterModels implements DynamicModule.
+//
+// The subscription is registered before the delivery, whose edge points at it.
+func (*WebhookModule) RegisterModels() error {
+	return stdErr.Join(
+		dmodel.RegisterSchemaB(models.WebhookSubscriptionSchemaBuilder()),
+		dmodel.RegisterSchemaB(models.WebhookDeliverySchemaBuilder()),
+	)
+}
+
+// Fallbacks for the delivery tuning, used when a deployment's configuration omits a key. They
+// match config.default.yaml.
+const (
+	defaultTimeoutSecs = 10
+	defaultMaxAttempts = 8
+)
@@ -0,0 +1,21 @@
+-- Create "core_job_runs" table
+--
+-- One row per run of a cron job on one instance, written by the scheduler as the run starts and
+-- completed as it ends. A row still "running" long after its job's timeout belongs to an instance
+-- that stopped mid-run.
+CREATE TABLE "core_job_runs" (
+  "id" character varying NOT NULL,
+  "job_name" character varying NOT NULL,
+  "node" character varying NOT NULL,
+  "tick_at" timestamptz NOT NULL,
+  "started_at" timestamptz NOT NULL,
+  "ended_at" timestamptz NULL,
+  "outcome" character varying NOT NULL,
+  "detail" character varying NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "core_job_runs_job_name_started_at" to table: "core_job_runs"
+CREATE INDEX "core_job_runs_job_name_started_at" ON "core_job_runs" ("job_name", "started_at");
+-- Create index "core_job_runs_started_at" to table: "core_job_runs"
+-- Serves the newest-first listing across jobs and the daily pruning of old runs.
+CREATE INDEX "core_job_runs_started_at" ON "core_job_runs" ("started_at");
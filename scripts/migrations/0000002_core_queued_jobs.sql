@@ -0,0 +1,32 @@
+-- Create "core_queued_jobs" table
+--
+-- The background job queue. Workers on every instance claim due rows with FOR UPDATE SKIP LOCKED,
+-- so a row is run by one worker at a time. A row still "running" past its lease belonged to an
+-- instance that stopped mid-run, and is claimed again.
+CREATE TABLE "core_queued_jobs" (
+  "id" character varying NOT NULL,
+  "job_type" character varying NOT NULL,
+  "payload" text NOT NULL,
+  "status" character varying NOT NULL,
+  "priority" integer NOT NULL DEFAULT 0,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL,
+  "run_at" timestamptz NOT NULL,
+  "unique_key" character varying NULL,
+  "last_error" text NULL,
+  "locked_by" character varying NULL,
+  "locked_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "finished_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "core_queued_jobs_status_check" CHECK ("status" IN ('pending', 'running', 'succeeded', 'failed', 'canceled'))
+);
+-- Create index "core_queued_jobs_due" to table: "core_queued_jobs"
+-- Serves the workers' claim, which only ever looks at live rows.
+CREATE INDEX "core_queued_jobs_due" ON "core_queued_jobs" ("job_type", "priority" DESC, "run_at") WHERE "status" IN ('pending', 'running');
+-- Create index "core_queued_jobs_unique_key" to table: "core_queued_jobs"
+-- A unique key is held only while its job is live, so the same work can be queued again once done.
+CREATE UNIQUE INDEX "core_queued_jobs_unique_key" ON "core_queued_jobs" ("job_type", "unique_key") WHERE "status" IN ('pending', 'running');
+-- Create index "core_queued_jobs_created_at" to table: "core_queued_jobs"
+CREATE INDEX "core_queued_jobs_created_at" ON "core_queued_jobs" ("created_at");
@@ -0,0 +1,18 @@
+-- Create "core_change_histories" table
+--
+-- One row per insert, update or delete of a record whose schema declares track_history, written in
+-- the transaction of the change itself. There is no foreign key to the record: its history outlives
+-- it, and the delete row keeps its last values.
+CREATE TABLE "core_change_histories" (
+  "id" character varying NOT NULL,
+  "schema_name" character varying NOT NULL,
+  "record_key" character varying NOT NULL,
+  "operation" character varying NOT NULL,
+  "action" character varying NOT NULL,
+  "actor_id" character varying NULL,
+  "changed_at" timestamptz NOT NULL,
+  "changes" jsonb NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "core_change_histories_record_idx" to table: "core_change_histories"
+CREATE INDEX "core_change_histories_record_idx" ON "core_change_histories" ("schema_name", "record_key", "changed_at");
@@ -0,0 +1,69 @@
+-- Create "essential_taxes" table
+CREATE TABLE "essential_taxes" (
+  "id" character varying NOT NULL,
+  "code" character varying NOT NULL,
+  "name" jsonb NOT NULL,
+  "tax_type" character varying NOT NULL,
+  "rate_percent" numeric NOT NULL,
+  "price_included" boolean NOT NULL,
+  "compound" boolean NOT NULL,
+  "rounding" character varying NOT NULL,
+  "sequence" integer NOT NULL,
+  "account_label" character varying NULL,
+  "is_active" boolean NOT NULL,
+  "is_archived" boolean NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "essent_taxes_code_ukey" UNIQUE ("code")
+);
+-- Create index "essent_taxes_is_active_idx" to table: "essential_taxes"
+CREATE INDEX "essent_taxes_is_active_idx" ON "essential_taxes" ("is_active");
+
+-- IAM resource and actions for Essential's Tax master.
+--
+-- As with Currency, the resource code is the "essential_tax" schema name, and withdrawing a tax
+-- from use is is_active rather than an action: documents already carrying it keep computing.
+--
+-- The system role every user holds is granted domain-wide read, because anyone writing an invoice
+-- or an order line has to be able to pick the taxes on it. Writing the master stays with the roles
+-- an administrator grants it to.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0TAX1QK4N7VZBX2M9TPE5RD', 'Tax', 'essential_tax', 'Taxes a document line may be charged', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		('01M0TAX1QM6P9XB0Z4Q1VRG7TF', 'Create', 'create', NULL, '01M0TAX1QK4N7VZBX2M9TPE5RD', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0TAX1QN8R1ZD2B6S3XTJ9VG', 'Update', 'update', NULL, '01M0TAX1QK4N7VZBX2M9TPE5RD', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0TAX1QP0T3BF4D8V5ZWK1XH', 'Delete', 'delete', NULL, '01M0TAX1QK4N7VZBX2M9TPE5RD', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0TAX1QQ2V5DH6F0X7BYM3ZJ', 'Read', 'read', NULL, '01M0TAX1QK4N7VZBX2M9TPE5RD', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0TAX1QR4X7FK8H2Z9DAP5BK', 'Set archived status', 'set_archived', 'Archive a tax so it is out of the working set', '01M0TAX1QK4N7VZBX2M9TPE5RD', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_entitlements'
+	) THEN
+		INSERT INTO "iam_entitlements" (
+			"id", "name", "description", "expression", "action_id", "resource_id", "role_id", "scope", "org_id", "org_unit_id", "is_archived", "created_at", "etag"
+		) VALUES
+		('01M0TAX1QS6Z9HM0K4B1FCR7DM', 'User - Read Taxes', 'Read taxes', 'read:essential_tax:domain', '01M0TAX1QQ2V5DH6F0X7BYM3ZJ', '01M0TAX1QK4N7VZBX2M9TPE5RD', '01KZJ5XRJDXSXZY0DKNNE6S086', 'domain', NULL, NULL, false, NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
@@ -0,0 +1,9 @@
+-- Time-bound role grants: remember whether the receiver was warned, and let the
+-- expiry sweep find due assignments without scanning the permanent ones.
+ALTER TABLE "iam_role_user_assignments" ADD COLUMN "expiry_notified_at" timestamptz NULL;
+ALTER TABLE "iam_role_group_assignments" ADD COLUMN "expiry_notified_at" timestamptz NULL;
+
+CREATE INDEX "iam_role_user_assignments_expires_at_idx"
+  ON "iam_role_user_assignments" ("expires_at") WHERE "expires_at" IS NOT NULL;
+CREATE INDEX "iam_role_group_assignments_expires_at_idx"
+  ON "iam_role_group_assignments" ("expires_at") WHERE "expires_at" IS NOT NULL;
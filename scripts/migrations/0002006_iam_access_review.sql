@@ -0,0 +1,80 @@
+-- Access certification campaigns and the holdings snapshotted for review when one is launched.
+CREATE TABLE "iam_access_review_campaigns" (
+  "id" character varying NOT NULL,
+  "name" character varying NOT NULL,
+  "description" character varying NULL,
+  "scope_type" character varying NOT NULL,
+  "scope_id" character varying NOT NULL,
+  "status" character varying NOT NULL,
+  "deadline_at" timestamptz NOT NULL,
+  "auto_revoke_unreviewed" boolean NOT NULL DEFAULT false,
+  "launched_at" timestamptz NULL,
+  "closed_at" timestamptz NULL,
+  "owner_id" character varying NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "iam_access_review_campaigns_owner_id_fkey" FOREIGN KEY ("owner_id") REFERENCES "iam_users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- The deadline sweep looks up open campaigns past their deadline.
+CREATE INDEX "iam_access_review_campaigns_status_deadline_at_idx"
+  ON "iam_access_review_campaigns" ("status", "deadline_at");
+
+CREATE TABLE "iam_access_review_items" (
+  "id" character varying NOT NULL,
+  "campaign_id" character varying NOT NULL,
+  "item_type" character varying NOT NULL,
+  "role_id" character varying NOT NULL,
+  "receiver_user_id" character varying NULL,
+  "receiver_group_id" character varying NULL,
+  "reviewer_user_id" character varying NULL,
+  "reviewer_group_id" character varying NULL,
+  "decision" character varying NOT NULL,
+  "decision_comment" character varying NULL,
+  "decided_by_id" character varying NULL,
+  "decided_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "iam_access_review_items_campaign_id_fkey" FOREIGN KEY ("campaign_id") REFERENCES "iam_access_review_campaigns" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "iam_access_review_items_role_id_fkey" FOREIGN KEY ("role_id") REFERENCES "iam_roles" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "iam_access_review_items_receiver_user_id_fkey" FOREIGN KEY ("receiver_user_id") REFERENCES "iam_users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "iam_access_review_items_receiver_group_id_fkey" FOREIGN KEY ("receiver_group_id") REFERENCES "iam_groups" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "iam_access_review_items_reviewer_user_id_fkey" FOREIGN KEY ("reviewer_user_id") REFERENCES "iam_users" ("id") ON UPDATE NO ACTION ON DELETE SET NULL,
+  CONSTRAINT "iam_access_review_items_reviewer_group_id_fkey" FOREIGN KEY ("reviewer_group_id") REFERENCES "iam_groups" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE INDEX "iam_access_review_items_campaign_id_decision_idx"
+  ON "iam_access_review_items" ("campaign_id", "decision");
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01KZM78BE8XTDD9SA8CC1TF46T', 'IAM Access Review Campaign', 'iam_access_review_campaign', NULL, 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZMM8BHEW8C0JG5H7TACQ42V', 'IAM Access Review Item', 'iam_access_review_item', NULL, 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text);
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- IamAccessReviewCampaign
+		('01KZMZQARGRBEBKCT1EC3MPS6Z', 'Create', 'create', NULL, '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZM9ZDWVATFXJXEF20VTGW80', 'Delete', 'delete', NULL, '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZMXQQ5ESC687JW49CA5WMYS', 'Update', 'update', NULL, '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZMSS4NFNQ5N6SJSX85SF38K', 'Read', 'read', 'Read campaigns and export their results', '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZMYC19Y1X1F47T23HYNMSPD', 'Launch', 'launch', 'Snapshot the holdings in scope and open them for review', '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZM2PC1N77Q44CAGWSZBEPRG', 'Close', 'close', 'Close a campaign before its deadline', '01KZM78BE8XTDD9SA8CC1TF46T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		-- IamAccessReviewItem
+		('01KZMDR2HZEQMJHQ9VM2MEMRAN', 'Read', 'read', NULL, '01KZMM8BHEW8C0JG5H7TACQ42V', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01KZMAQZ0R02M7ZKGGTH7R5YA4', 'Review any', 'review_any', 'Keep or revoke review items despite not being role owners', '01KZMM8BHEW8C0JG5H7TACQ42V', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text);
+	END IF;
+END $$;
@@ -0,0 +1,51 @@
+-- Bearer tokens an organization's identity provider provisions users and groups with.
+-- Only the token's hash is stored.
+CREATE TABLE "iam_scim_tokens" (
+  "id" character varying NOT NULL,
+  "name" character varying NOT NULL,
+  "org_id" character varying NOT NULL,
+  "owner_id" character varying NOT NULL,
+  "token_hash" character varying NOT NULL,
+  "expires_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "iam_scim_tokens_token_hash_ukey" UNIQUE ("token_hash"),
+  CONSTRAINT "iam_scim_tokens_org_id_fkey" FOREIGN KEY ("org_id") REFERENCES "iam_organizations" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "iam_scim_tokens_owner_id_fkey" FOREIGN KEY ("owner_id") REFERENCES "iam_users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+
+-- The organization a provisioned group belongs to. No foreign key, like "iam_roles"."org_id".
+ALTER TABLE "iam_groups" ADD COLUMN "org_id" character varying NULL;
+CREATE INDEX "iam_groups_org_id_idx" ON "iam_groups" ("org_id");
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M5A0M7KRHJFQ1VNS7PCKBPE9', 'IAM SCIM Token', 'iam_scim_token', NULL, 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A0QD2XBPP5TQMQD4M367FF', 'IAM Group Membership', 'iam_group_user_rel', NULL, 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text);
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- IamScimToken
+		('01M5A0RW6RPMJG6J1S0FFG41RM', 'Create', 'create', 'Issue tokens', '01M5A0M7KRHJFQ1VNS7PCKBPE9', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A0T3H4CS35PZFM9YXVR2YA', 'Delete', 'delete', 'Revoke tokens', '01M5A0M7KRHJFQ1VNS7PCKBPE9', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A0VB8KBCDPQCX21FRX0TMX', 'Update', 'update', NULL, '01M5A0M7KRHJFQ1VNS7PCKBPE9', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A0WQ1MB9FJ06X88142R021', 'Read', 'read', NULL, '01M5A0M7KRHJFQ1VNS7PCKBPE9', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		-- IamGroupUserRel
+		('01M5A0XZ7NKQ2R8D4VJ3T6W1HC', 'Create', 'create', 'Add a user to a group', '01M5A0QD2XBPP5TQMQD4M367FF', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A0Z4E2GT7Y9P5MXB1C8S3D', 'Delete', 'delete', 'Remove a user from a group', '01M5A0QD2XBPP5TQMQD4M367FF', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5A10G3FH6V2N9R7KQ4W8E5J', 'Read', 'read', NULL, '01M5A0QD2XBPP5TQMQD4M367FF', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text);
+	END IF;
+END $$;
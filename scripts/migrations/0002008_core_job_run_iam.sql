@@ -0,0 +1,28 @@
+-- Register the record of cron runs as an IAM resource, so that reading it through the admin API at
+-- /v1/core/jobs/runs can be granted. It is one record for the whole deployment, so it is granted at
+-- domain scope only.
+--
+-- It is numbered after the IAM schema rather than with core's own tables: migrations run in version
+-- order, and the rows it seeds need iam_resources and iam_actions to exist.
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M5AJ6F1AC20XTX29DZAYP3TC', 'Job Run', 'core_job_run', 'One run of a cron job on one instance', 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		('01M5AJ6F1CBSFHG3KB4TRGAMF4', 'Read', 'read', NULL, '01M5AJ6F1AC20XTX29DZAYP3TC', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
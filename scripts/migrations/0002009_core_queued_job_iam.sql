@@ -0,0 +1,29 @@
+-- Register the background job queue as an IAM resource, so that its admin API at /v1/core/jobs can
+-- be granted. The queue is one for the whole deployment, so it is granted at domain scope only.
+--
+-- It is numbered after the IAM schema rather than with core_queued_jobs: migrations run in version
+-- order, and the rows it seeds need iam_resources and iam_actions to exist.
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M5AJ6F1E44P1NSVBMTG886D8', 'Queued Job', 'core_queued_job', 'A background job on the durable job queue', 'nikkierp', 'domain', 'domain', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		('01M5AJ6F1HGJJP63PT6YAYCG4X', 'Read', 'read', NULL, '01M5AJ6F1E44P1NSVBMTG886D8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5AJ6F1K9XPZAW4FN93X16NC', 'Retry', 'retry', NULL, '01M5AJ6F1E44P1NSVBMTG886D8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M5AJ6F1NNT62KWFNYW4PZ7N2', 'Cancel', 'cancel', NULL, '01M5AJ6F1E44P1NSVBMTG886D8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
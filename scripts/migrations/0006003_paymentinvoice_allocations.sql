@@ -0,0 +1,69 @@
+-- Modify "paymentinvoice_invoices" table
+--
+-- An invoice issued before allocations existed is taken as owing its whole total, and one already
+-- marked paid as owing nothing, which is what each status said about it.
+ALTER TABLE "paymentinvoice_invoices"
+  ADD COLUMN "amount_paid" numeric NOT NULL DEFAULT 0,
+  ADD COLUMN "amount_due" numeric NOT NULL DEFAULT 0;
+UPDATE "paymentinvoice_invoices" SET "amount_due" = "total_amount" WHERE "status" = 'issued';
+UPDATE "paymentinvoice_invoices" SET "amount_paid" = "total_amount" WHERE "status" = 'paid';
+-- Create index "payinv_invoices_amount_due_idx" to table: "paymentinvoice_invoices"
+CREATE INDEX "payinv_invoices_amount_due_idx" ON "paymentinvoice_invoices" ("amount_due");
+-- Create "paymentinvoice_payment_allocations" table
+CREATE TABLE "paymentinvoice_payment_allocations" (
+  "id" character varying NOT NULL,
+  "invoice_id" character varying NOT NULL,
+  "transaction_id" character varying NULL,
+  "source" character varying NOT NULL,
+  "reference" character varying NULL,
+  "amount" numeric NOT NULL,
+  "released_amount" numeric NOT NULL,
+  "received_at" timestamptz NOT NULL,
+  "released_at" timestamptz NULL,
+  "note" character varying NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_payment_allocations_invoice_id_fkey" FOREIGN KEY ("invoice_id") REFERENCES "paymentinvoice_invoices" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "paymentinvoice_payment_allocations_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "paymentinvoice_transactions" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "payinv_alloc_invoice_id_idx" to table: "paymentinvoice_payment_allocations"
+CREATE INDEX "payinv_alloc_invoice_id_idx" ON "paymentinvoice_payment_allocations" ("invoice_id");
+-- Create index "payinv_alloc_transaction_id_idx" to table: "paymentinvoice_payment_allocations"
+CREATE INDEX "payinv_alloc_transaction_id_idx" ON "paymentinvoice_payment_allocations" ("transaction_id");
+
+-- IAM resource and actions for payment allocations, and the allocate_payment action on invoices.
+--
+-- Payment Allocation carries no create, update or delete. An allocation is written only through the
+-- invoice's allocate_payment, which checks that the money was received and not already used, and
+-- is corrected only through release, which keeps the row as the record that it once counted.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0PAY2A7QK4M1V9XRD3TBN6C', 'Payment Allocation', 'paymentinvoice_payment_allocation', 'Money counted against an invoice', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Invoice
+		('01M0PAY2CH8W5TZJ0PGE4YQS1M', 'Allocate payment', 'allocate_payment', 'Count a completed payment or a recorded receipt against an issued invoice', '01M0PAY16EZ1JQ1TS64Q21TPE7', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+
+		-- Payment Allocation. No create, update or delete: see the note above.
+		('01M0PAY2EV3N9B6RKX7DHM2FQA', 'Read', 'read', NULL, '01M0PAY2A7QK4M1V9XRD3TBN6C', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY2G1XT6C8YPS5JZ4WKVB', 'Release', 'release', 'Stop an allocation made in error from counting against its invoice', '01M0PAY2A7QK4M1V9XRD3TBN6C', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
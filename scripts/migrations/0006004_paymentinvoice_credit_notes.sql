@@ -0,0 +1,86 @@
+-- Modify "paymentinvoice_invoices" table
+ALTER TABLE "paymentinvoice_invoices"
+  ADD COLUMN "amount_credited" numeric NOT NULL DEFAULT 0;
+-- Create "paymentinvoice_credit_notes" table
+CREATE TABLE "paymentinvoice_credit_notes" (
+  "id" character varying NOT NULL,
+  "number" character varying NOT NULL,
+  "invoice_id" character varying NOT NULL,
+  "currency_id" character varying NOT NULL,
+  "subtotal_amount" numeric NOT NULL,
+  "tax_amount" numeric NOT NULL,
+  "total_amount" numeric NOT NULL,
+  "issued_at" timestamptz NOT NULL,
+  "reason" character varying NULL,
+  "refund_status" character varying NOT NULL,
+  "refund_amount" numeric NOT NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_credit_notes_number_ukey" UNIQUE ("number"),
+  CONSTRAINT "paymentinvoice_credit_notes_invoice_id_fkey" FOREIGN KEY ("invoice_id") REFERENCES "paymentinvoice_invoices" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "payinv_credit_notes_invoice_id_idx" to table: "paymentinvoice_credit_notes"
+CREATE INDEX "payinv_credit_notes_invoice_id_idx" ON "paymentinvoice_credit_notes" ("invoice_id");
+-- Create index "payinv_credit_notes_issued_at_idx" to table: "paymentinvoice_credit_notes"
+CREATE INDEX "payinv_credit_notes_issued_at_idx" ON "paymentinvoice_credit_notes" ("issued_at");
+-- Create "paymentinvoice_credit_note_lines" table
+CREATE TABLE "paymentinvoice_credit_note_lines" (
+  "id" character varying NOT NULL,
+  "credit_note_id" character varying NOT NULL,
+  "invoice_id" character varying NOT NULL,
+  "invoice_line_id" character varying NOT NULL,
+  "description" character varying NOT NULL,
+  "quantity" integer NOT NULL,
+  "unit_price" numeric NOT NULL,
+  "tax_rate_percent" numeric NOT NULL,
+  "amount" numeric NOT NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_credit_note_lines_credit_note_id_fkey" FOREIGN KEY ("credit_note_id") REFERENCES "paymentinvoice_credit_notes" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "paymentinvoice_credit_note_lines_invoice_line_id_fkey" FOREIGN KEY ("invoice_line_id") REFERENCES "paymentinvoice_invoice_lines" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "payinv_cn_lines_credit_note_id_idx" to table: "paymentinvoice_credit_note_lines"
+CREATE INDEX "payinv_cn_lines_credit_note_id_idx" ON "paymentinvoice_credit_note_lines" ("credit_note_id");
+-- Create index "payinv_cn_lines_invoice_id_idx" to table: "paymentinvoice_credit_note_lines"
+CREATE INDEX "payinv_cn_lines_invoice_id_idx" ON "paymentinvoice_credit_note_lines" ("invoice_id");
+
+-- IAM resources and actions for credit notes, and the credit action on invoices.
+--
+-- Credit Note and Credit Note Line carry read alone. A note is written only through the invoice's
+-- credit action, which numbers it and takes it off the invoice in the same transaction; one typed
+-- in directly would correct nothing, and one edited afterwards would disagree with amount_credited.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0PAY3B2KD7QW9XN4HTR6V1S', 'Credit Note', 'paymentinvoice_credit_note', 'A correction that takes all or part of an issued invoice back', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY3D8FM2ZC5YQ1JWK9E4T', 'Credit Note Line', 'paymentinvoice_credit_note_line', 'An invoice line credited by a credit note', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Invoice
+		('01M0PAY3F4VS8HB1NX6RQD2C7A', 'Credit', 'credit', 'Issue a credit note against an invoice, optionally refunding it through the gateway', '01M0PAY16EZ1JQ1TS64Q21TPE7', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+
+		-- Credit Note and Credit Note Line. Read only: see the note above.
+		('01M0PAY3H6TX1KE9WM3ZPB5Y8R', 'Read', 'read', NULL, '01M0PAY3B2KD7QW9XN4HTR6V1S', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY3K9NQ4JD6RT2VXC8M1G', 'Read', 'read', NULL, '01M0PAY3D8FM2ZC5YQ1JWK9E4T', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
@@ -0,0 +1,12 @@
+-- Modify "paymentinvoice_invoice_lines" table
+ALTER TABLE "paymentinvoice_invoice_lines"
+  ADD COLUMN "tax_ids" character varying[] NULL,
+  ADD COLUMN "tax_amount" numeric NOT NULL DEFAULT 0;
+-- Modify "paymentinvoice_invoices" table
+ALTER TABLE "paymentinvoice_invoices"
+  ADD COLUMN "tax_breakdown" jsonb NULL;
+
+-- Lines written before taxes could be named were taxed at their own rate. Their tax is filled in
+-- here so that a credit or a reprint of an invoice issued then reads what issue charged.
+UPDATE "paymentinvoice_invoice_lines"
+SET "tax_amount" = "amount" * "tax_rate_percent" / 100;
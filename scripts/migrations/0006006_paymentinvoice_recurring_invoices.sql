@@ -0,0 +1,107 @@
+-- Create "paymentinvoice_recurring_invoices" table
+CREATE TABLE "paymentinvoice_recurring_invoices" (
+  "id" character varying NOT NULL,
+  "name" character varying NOT NULL,
+  "partner_name" character varying NOT NULL,
+  "partner_tax_code" character varying NULL,
+  "partner_address" character varying NULL,
+  "currency_id" character varying NOT NULL,
+  "note" character varying NULL,
+  "interval_unit" character varying NOT NULL,
+  "interval_count" integer NOT NULL,
+  "start_date" date NOT NULL,
+  "end_date" date NULL,
+  "next_run_date" date NULL,
+  "auto_issue" boolean NOT NULL,
+  "is_active" boolean NOT NULL,
+  "last_generated_at" timestamptz NULL,
+  "org_id" character varying NOT NULL,
+  "is_archived" boolean NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "payinv_recurring_next_run_date" to table: "paymentinvoice_recurring_invoices"
+CREATE INDEX "payinv_recurring_next_run_date" ON "paymentinvoice_recurring_invoices" ("next_run_date");
+-- Create index "payinv_recurring_is_active" to table: "paymentinvoice_recurring_invoices"
+CREATE INDEX "payinv_recurring_is_active" ON "paymentinvoice_recurring_invoices" ("is_active");
+-- Create "paymentinvoice_recurring_invoice_lines" table
+CREATE TABLE "paymentinvoice_recurring_invoice_lines" (
+  "id" character varying NOT NULL,
+  "recurring_invoice_id" character varying NOT NULL,
+  "description" character varying NOT NULL,
+  "quantity" integer NOT NULL,
+  "unit_price" numeric NOT NULL,
+  "tax_rate_percent" numeric NOT NULL,
+  "tax_ids" character varying[] NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_recurring_invoice_lines_recurring_invoice_id_fkey" FOREIGN KEY ("recurring_invoice_id") REFERENCES "paymentinvoice_recurring_invoices" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "payinv_recurring_lines_recurring_invoice_id" to table: "paymentinvoice_recurring_invoice_lines"
+CREATE INDEX "payinv_recurring_lines_recurring_invoice_id" ON "paymentinvoice_recurring_invoice_lines" ("recurring_invoice_id");
+-- Create "paymentinvoice_recurring_invoice_runs" table
+CREATE TABLE "paymentinvoice_recurring_invoice_runs" (
+  "id" character varying NOT NULL,
+  "recurring_invoice_id" character varying NOT NULL,
+  "period_date" date NOT NULL,
+  "invoice_id" character varying NOT NULL,
+  "status" character varying NOT NULL,
+  "detail" character varying NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "payinv_recurring_runs_period" UNIQUE ("recurring_invoice_id", "period_date"),
+  CONSTRAINT "paymentinvoice_recurring_invoice_runs_recurring_invoice_id_fkey" FOREIGN KEY ("recurring_invoice_id") REFERENCES "paymentinvoice_recurring_invoices" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "paymentinvoice_recurring_invoice_runs_invoice_id_fkey" FOREIGN KEY ("invoice_id") REFERENCES "paymentinvoice_invoices" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+
+-- IAM resources and actions for recurring invoices.
+--
+-- A schedule and its lines carry the usual CRUD. Runs carry read alone: a run is written by the
+-- generation job in the same transaction as the invoice it records, and one typed in directly
+-- would mark a period invoiced that never was.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0PAY4A3RN6XW1KD8QTF2J5B', 'Recurring Invoice', 'paymentinvoice_recurring_invoice', 'A schedule that raises an invoice every period', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4C7HV2MZ9PT5WXE1K4N', 'Recurring Invoice Line', 'paymentinvoice_recurring_invoice_line', 'A line every invoice of a schedule is raised with', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4E1QD8JB4YR7NVC3S6M', 'Recurring Invoice Run', 'paymentinvoice_recurring_invoice_run', 'The record of one period of a schedule being invoiced', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Recurring Invoice
+		('01M0PAY4G5TK3NF8WQ1ZXB6H2D', 'Create', 'create', NULL, '01M0PAY4A3RN6XW1KD8QTF2J5B', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4J9WM6RC2XT5DYV8K3P', 'Read', 'read', NULL, '01M0PAY4A3RN6XW1KD8QTF2J5B', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4M2XQ9VH5ZB8EKN1R4T', 'Update', 'update', NULL, '01M0PAY4A3RN6XW1KD8QTF2J5B', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4P6ZS2YK8DC1HRW4V7F', 'Delete', 'delete', NULL, '01M0PAY4A3RN6XW1KD8QTF2J5B', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+
+		-- Recurring Invoice Line
+		('01M0PAY4R1BV5EN3FG6JXT9Z2A', 'Create', 'create', NULL, '01M0PAY4C7HV2MZ9PT5WXE1K4N', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4T4DX8HQ6JK9MZA2C5B', 'Read', 'read', NULL, '01M0PAY4C7HV2MZ9PT5WXE1K4N', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4V8FZ1KS9MN2QBD5E8C', 'Update', 'update', NULL, '01M0PAY4C7HV2MZ9PT5WXE1K4N', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY4X2HB4MV3PR5SCF8G1D', 'Delete', 'delete', NULL, '01M0PAY4C7HV2MZ9PT5WXE1K4N', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+
+		-- Recurring Invoice Run. Read only: see the note above.
+		('01M0PAY4Z6KD7PX6RT8VEH1J4E', 'Read', 'read', NULL, '01M0PAY4E1QD8JB4YR7NVC3S6M', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
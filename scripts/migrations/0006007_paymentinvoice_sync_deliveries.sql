@@ -0,0 +1,103 @@
+-- Create "paymentinvoice_sync_deliveries" table
+CREATE TABLE "paymentinvoice_sync_deliveries" (
+  "id" character varying NOT NULL,
+  "order_id" character varying NOT NULL,
+  "order_ref" character varying NOT NULL,
+  "return_url" character varying NOT NULL,
+  "payment_method_code" character varying NULL,
+  "payload" character varying NOT NULL,
+  "status" character varying NOT NULL,
+  "attempts" integer NOT NULL,
+  "next_attempt_at" timestamptz NULL,
+  "last_attempt_at" timestamptz NULL,
+  "delivered_at" timestamptz NULL,
+  "last_detail" character varying NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_sync_deliveries_order_id_fkey" FOREIGN KEY ("order_id") REFERENCES "paymentinvoice_orders" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "payinv_sync_deliveries_order_id" to table: "paymentinvoice_sync_deliveries"
+CREATE INDEX "payinv_sync_deliveries_order_id" ON "paymentinvoice_sync_deliveries" ("order_id");
+-- Create index "payinv_sync_deliveries_due" to table: "paymentinvoice_sync_deliveries"
+CREATE INDEX "payinv_sync_deliveries_due" ON "paymentinvoice_sync_deliveries" ("status", "next_attempt_at");
+
+-- Adopt the notifications the order-level retry was still chasing.
+--
+-- Until now a failed notification was retried by re-reading the order, and the sweep that did so
+-- is gone. Without this, an order whose ordering system was down when the release went out would
+-- never be reported. Each becomes a failed delivery due at once, carrying the payload the old
+-- sweep would have sent, with its attempts counted from the order's sync log. One that had already
+-- used up the old limit of twenty is dead-lettered instead, to be replayed by hand.
+INSERT INTO "paymentinvoice_sync_deliveries" (
+  "id", "order_id", "order_ref", "return_url", "payment_method_code", "payload", "status",
+  "attempts", "next_attempt_at", "org_id", "created_at", "etag"
+)
+SELECT
+  '01' || substr(upper(replace(gen_random_uuid()::text, '-', '')), 1, 24),
+  o."id",
+  o."order_id",
+  o."return_url",
+  pm."code",
+  json_build_object(
+    'type', 'paymentResult',
+    'status', CASE o."status"
+      WHEN 'payment_success' THEN 'paymentSuccess'
+      WHEN 'payment_failed' THEN 'paymentFailed'
+      ELSE o."status"
+    END,
+    'orderId', o."order_id",
+    'amount', trunc(o."amount")::bigint,
+    'paymentMethod', COALESCE(pm."code", ''),
+    'responseTime', (EXTRACT(EPOCH FROM NOW()) * 1000)::bigint
+  )::text,
+  CASE WHEN a."attempts" >= 20 THEN 'dead_lettered' ELSE 'failed' END,
+  a."attempts",
+  CASE WHEN a."attempts" >= 20 THEN NULL ELSE NOW() END,
+  o."org_id",
+  NOW(),
+  (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text
+FROM "paymentinvoice_orders" o
+LEFT JOIN "paymentinvoice_payment_methods" pm ON pm."id" = o."payment_method_id"
+CROSS JOIN LATERAL (
+  SELECT CASE
+    WHEN jsonb_typeof(o."sync_logs" -> 'entries') = 'array'
+      THEN jsonb_array_length(o."sync_logs" -> 'entries')
+    ELSE 0
+  END AS "attempts"
+) a
+WHERE o."last_sync_status" = 'failure'
+  AND o."return_url" IS NOT NULL AND o."return_url" <> ''
+  AND o."status" IN ('payment_success', 'payment_failed', 'expired');
+
+-- IAM resources and actions for sync deliveries.
+--
+-- A delivery carries read and replay alone: it is written by the notification it records, and one
+-- created or edited by hand would claim the ordering system was told something it never was.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0PAY5B2KX7QN4TD9WRF3H6M', 'Sync Delivery', 'paymentinvoice_sync_delivery', 'One notification to the ordering system and its attempts', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Sync Delivery
+		('01M0PAY5D6MZ1RP8VF3XBJ5K9N', 'Read', 'read', NULL, '01M0PAY5B2KX7QN4TD9WRF3H6M', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY5F9QB4TS2XH6ZCM8N3P', 'Replay', 'replay', NULL, '01M0PAY5B2KX7QN4TD9WRF3H6M', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
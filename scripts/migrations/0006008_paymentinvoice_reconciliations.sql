@@ -0,0 +1,77 @@
+-- Create "paymentinvoice_reconciliations" table
+CREATE TABLE "paymentinvoice_reconciliations" (
+  "id" character varying NOT NULL,
+  "payment_method_id" character varying NOT NULL,
+  "settlement_date" date NOT NULL,
+  "file_name" character varying NULL,
+  "status" character varying NOT NULL,
+  "line_count" integer NOT NULL,
+  "matched_count" integer NOT NULL,
+  "missing_count" integer NOT NULL,
+  "extra_count" integer NOT NULL,
+  "mismatch_count" integer NOT NULL,
+  "settled_total" numeric NOT NULL,
+  "expected_total" numeric NOT NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "payinv_reconciliations_method_day" UNIQUE ("payment_method_id", "settlement_date"),
+  CONSTRAINT "paymentinvoice_reconciliations_payment_method_id_fkey" FOREIGN KEY ("payment_method_id") REFERENCES "paymentinvoice_payment_methods" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "paymentinvoice_reconciliation_items" table
+CREATE TABLE "paymentinvoice_reconciliation_items" (
+  "id" character varying NOT NULL,
+  "reconciliation_id" character varying NOT NULL,
+  "outcome" character varying NOT NULL,
+  "transaction_type" character varying NOT NULL,
+  "transaction_id" character varying NULL,
+  "order_code" character varying NULL,
+  "ref_transaction_id" character varying NULL,
+  "expected_amount" numeric NULL,
+  "settled_amount" numeric NULL,
+  "line_no" integer NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "paymentinvoice_reconciliation_items_reconciliation_id_fkey" FOREIGN KEY ("reconciliation_id") REFERENCES "paymentinvoice_reconciliations" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "paymentinvoice_reconciliation_items_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "paymentinvoice_transactions" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "payinv_reconciliation_items_reconciliation_id" to table: "paymentinvoice_reconciliation_items"
+CREATE INDEX "payinv_reconciliation_items_reconciliation_id" ON "paymentinvoice_reconciliation_items" ("reconciliation_id");
+
+-- IAM resources and actions for settlement reconciliation.
+--
+-- Both are written only by the import. A reconciliation carries read and import_settlement; its
+-- items carry read alone, since they are filed with it and replaced with it.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0PAY6A3RK8TN2VD5XQF7H4M', 'Reconciliation', 'paymentinvoice_reconciliation', 'A gateway settlement report compared with the day''s transactions', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY6C7MB2QW9XF4TZH6K3N', 'Reconciliation Item', 'paymentinvoice_reconciliation_item', 'One match or discrepancy of a reconciliation', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Reconciliation
+		('01M0PAY6E2NX5RK8TB3VHQ9M7P', 'Read', 'read', NULL, '01M0PAY6A3RK8TN2VD5XQF7H4M', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0PAY6G5QT9VM3XC7KZB2N8R', 'Import Settlement', 'import_settlement', NULL, '01M0PAY6A3RK8TN2VD5XQF7H4M', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		-- Reconciliation Item
+		('01M0PAY6J8WB4ZP6TF2XMH5Q3S', 'Read', 'read', NULL, '01M0PAY6C7MB2QW9XF4TZH6K3N', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
@@ -0,0 +1,3 @@
+-- Modify "purchase_order_lines" table
+ALTER TABLE "purchase_order_lines"
+  ADD COLUMN "tax_ids" character varying[] NULL;
@@ -0,0 +1,22 @@
+-- Create "document_templates" table
+CREATE TABLE "document_templates" (
+  "id" character varying NOT NULL,
+  "org_id" character varying NOT NULL,
+  "document_type" character varying NOT NULL,
+  "company_name" character varying NULL,
+  "company_address" character varying NULL,
+  "company_tax_code" character varying NULL,
+  "header_text" character varying NULL,
+  "footer_text" character varying NULL,
+  "accent_color" character varying NULL,
+  "paper_size" character varying NOT NULL,
+  "logo_object_key" character varying NULL,
+  "font_object_key" character varying NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "document_templates_org_id_type" UNIQUE ("org_id", "document_type")
+);
+-- Create index "document_templates_org_id" to table: "document_templates"
+CREATE INDEX "document_templates_org_id" ON "document_templates" ("org_id");
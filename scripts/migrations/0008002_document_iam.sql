@@ -0,0 +1,38 @@
+-- IAM resources and actions for the Document module.
+--
+-- The resource code must stay byte-identical to the "document_template" schema name: the dynamic
+-- resource engine asserts permissions using the schema name as the resource code.
+--
+-- Deliberate omissions, each of which would otherwise look like something forgotten:
+--
+--   * upload_asset gets NO action row. Replacing a template's logo or font is editing the template,
+--     so it carries update.
+--   * There is no "print" or "render" action anywhere. A document is downloaded from the resource
+--     it prints, under read on that resource: printing grants nothing the caller could not already
+--     read.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0D0C1A7RT3KX9QW5NZB2HMV', 'Document Template', 'document_template', 'How an organization''s printed invoices and purchase orders look', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		('01M0D0C1C4WP8HJ2TF6YXE9KDA', 'Create', 'create', NULL, '01M0D0C1A7RT3KX9QW5NZB2HMV', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0D0C1E9NM5GV3RB1SQZ7XPC', 'Update', 'update', NULL, '01M0D0C1A7RT3KX9QW5NZB2HMV', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0D0C1G2KY6DT8WH4MCN3VRF', 'Delete', 'delete', NULL, '01M0D0C1A7RT3KX9QW5NZB2HMV', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0D0C1J5QX1ZP7VE9BKR2TWS', 'Read', 'read', NULL, '01M0D0C1A7RT3KX9QW5NZB2HMV', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;
@@ -0,0 +1,43 @@
+-- Create "webhook_subscriptions" table
+CREATE TABLE "webhook_subscriptions" (
+  "id" character varying NOT NULL,
+  "org_id" character varying NOT NULL,
+  "target_url" character varying NOT NULL,
+  "resource_name" character varying NOT NULL,
+  "action_names" character varying[] NOT NULL,
+  "secret" character varying NOT NULL,
+  "is_active" boolean NOT NULL,
+  "description" character varying NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "webhook_subscriptions_org_resource" to table: "webhook_subscriptions"
+CREATE INDEX "webhook_subscriptions_org_resource" ON "webhook_subscriptions" ("org_id", "resource_name");
+-- Create "webhook_deliveries" table
+CREATE TABLE "webhook_deliveries" (
+  "id" character varying NOT NULL,
+  "subscription_id" character varying NOT NULL,
+  "event_id" character varying NOT NULL,
+  "event_name" character varying NOT NULL,
+  "record_id" character varying NULL,
+  "payload" character varying NOT NULL,
+  "status" character varying NOT NULL,
+  "attempts" integer NOT NULL,
+  "response_status" integer NULL,
+  "last_attempt_at" timestamptz NULL,
+  "delivered_at" timestamptz NULL,
+  "last_detail" character varying NULL,
+  "redelivery_of" character varying NULL,
+  "org_id" character varying NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NULL,
+  "etag" character varying NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "webhook_deliveries_subscription_id_fkey" FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "webhook_deliveries_subscription" to table: "webhook_deliveries"
+CREATE INDEX "webhook_deliveries_subscription" ON "webhook_deliveries" ("subscription_id", "created_at");
+-- Create index "webhook_deliveries_event_id" to table: "webhook_deliveries"
+CREATE INDEX "webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
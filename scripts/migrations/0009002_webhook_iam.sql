@@ -0,0 +1,43 @@
+-- IAM resources and actions for the Webhook module.
+--
+-- The resource codes must stay byte-identical to the "webhook_subscription" and "webhook_delivery"
+-- schema names: the dynamic resource engine asserts permissions using the schema name as the
+-- resource code.
+--
+-- Deliberate omissions, each of which would otherwise look like something forgotten:
+--
+--   * A delivery carries read and redeliver alone. It is written by the event it records, and one
+--     created or edited by hand would claim an integrator was told something they never were.
+--   * The subscription's "deliveries" action gets NO action row. Listing a subscription's delivery
+--     log is reading the subscription, so it carries read.
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_resources'
+	) THEN
+		INSERT INTO "iam_resources" (
+			"id", "name", "code", "description", "owner_type", "max_scope", "min_scope", "created_at", "etag"
+		) VALUES
+		('01M0WEBH4PT7M32H9X2EJQS1F8', 'Webhook Subscription', 'webhook_subscription', 'Where an organization''s integrations are told of the actions they follow', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0WEBH6VRMPHS3TD4GM8ZEMG', 'Webhook Delivery', 'webhook_delivery', 'One event posted to a subscription and its attempts', 'nikkierp', 'domain', 'org', NOW(), (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+
+	IF EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'iam_actions'
+	) THEN
+		INSERT INTO "iam_actions" ("id", "name", "code", "description", "resource_id", "etag") VALUES
+		-- Webhook Subscription
+		('01M0WEBHVZZRH190C5K43Q3VM4', 'Create', 'create', NULL, '01M0WEBH4PT7M32H9X2EJQS1F8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0WEBHYCVKYAGWN66TZX54EF', 'Update', 'update', NULL, '01M0WEBH4PT7M32H9X2EJQS1F8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0WEBHTN0BQQ67BGV5GQ8X7X', 'Delete', 'delete', NULL, '01M0WEBH4PT7M32H9X2EJQS1F8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0WEBH8R1G7NMVF0E2M4ZWG1', 'Read', 'read', NULL, '01M0WEBH4PT7M32H9X2EJQS1F8', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		-- Webhook Delivery
+		('01M0WEBHTG7WE1Y9GSAHEAEKPD', 'Read', 'read', NULL, '01M0WEBH6VRMPHS3TD4GM8ZEMG', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text),
+		('01M0WEBH27V2755VCHHCYWJM0K', 'Redeliver', 'redeliver', NULL, '01M0WEBH6VRMPHS3TD4GM8ZEMG', (EXTRACT(EPOCH FROM clock_timestamp()) * 1e9)::bigint::text)
+		ON CONFLICT ("id") DO NOTHING;
+	END IF;
+END $$;